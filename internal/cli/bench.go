@@ -0,0 +1,260 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/javanstorm/vmterminal/internal/config"
+	"github.com/javanstorm/vmterminal/internal/distro"
+	"github.com/javanstorm/vmterminal/internal/timing"
+	"github.com/javanstorm/vmterminal/internal/vm"
+	"github.com/spf13/cobra"
+)
+
+// benchBootTimeout bounds the wait for the guest's login prompt.
+const benchBootTimeout = 3 * time.Minute
+
+var (
+	benchVM       string
+	benchDuration time.Duration
+)
+
+var benchCmd = &cobra.Command{
+	Use:   "bench",
+	Short: "Measure VM performance against earlier runs",
+	Long: `Boot the VM, run benchmarks on its console, and compare the scores with
+the best ones recorded on this hypervisor, to check that it is configured
+well.
+
+The benchmarks are a 1 GiB dd through memory and, if sysbench is installed
+in the guest, sysbench's CPU test for --duration. The VM must be set up and
+not running, and root must be able to log in on the console without a
+password. Results are appended to ~/.vmterminal/bench_history.jsonl.
+
+Examples:
+  vmterminal bench
+  vmterminal bench --vm dev --duration 10s
+  vmterminal bench --json`,
+	Args: cobra.NoArgs,
+	RunE: runBench,
+}
+
+func init() {
+	benchCmd.Flags().StringVar(&benchVM, "vm", "", "VM to benchmark (default: the active VM)")
+	benchCmd.Flags().DurationVar(&benchDuration, "duration", 30*time.Second, "How long to run the CPU benchmark")
+	rootCmd.AddCommand(benchCmd)
+}
+
+// benchResult is the structured output of bench.
+type benchResult struct {
+	vm.BenchResult
+	Baseline *vm.BenchBaseline `json:"baseline,omitempty"`
+	Previous *vm.BenchResult   `json:"previous,omitempty"`
+}
+
+// RenderHuman prints the scores next to the best recorded and the previous
+// run.
+func (r *benchResult) RenderHuman(w io.Writer) {
+	fmt.Fprintf(w, "Benchmark of VM '%s' (%s, %s/%s, %d CPUs, %d MB):\n",
+		r.VM, r.Distro, r.Hypervisor, r.Arch, r.CPUs, r.MemoryMB)
+	fmt.Fprintf(w, "  %-18s %s\n", "Boot to login:", timing.FormatDuration(r.BootTime))
+
+	var baseMem, baseCPU, prevMem, prevCPU float64
+	if r.Baseline != nil {
+		baseMem, baseCPU = r.Baseline.MemoryMBps, r.Baseline.CPUEventsPerSec
+	}
+	if r.Previous != nil {
+		prevMem, prevCPU = r.Previous.MemoryMBps, r.Previous.CPUEventsPerSec
+	}
+	fmt.Fprintf(w, "  %-18s %.0f MB/s%s\n", "Memory (dd):", r.MemoryMBps, benchComparison(r.MemoryMBps, baseMem, prevMem))
+	if r.CPUEventsPerSec > 0 {
+		fmt.Fprintf(w, "  %-18s %.1f events/s%s\n", "CPU (sysbench):", r.CPUEventsPerSec, benchComparison(r.CPUEventsPerSec, baseCPU, prevCPU))
+	} else {
+		fmt.Fprintf(w, "  %-18s skipped (sysbench not installed in the guest)\n", "CPU (sysbench):")
+	}
+	if r.Baseline == nil {
+		fmt.Fprintf(w, "No earlier runs on %s/%s to compare with.\n", r.Hypervisor, r.Arch)
+	}
+}
+
+// benchComparison describes score relative to a baseline and a previous
+// score, either of which is 0 when unknown.
+func benchComparison(score, baseline, previous float64) string {
+	s := ""
+	if baseline > 0 {
+		s += fmt.Sprintf("  (%.0f%% of best)", score/baseline*100)
+	}
+	if previous > 0 {
+		s += fmt.Sprintf("  (%+.0f%% since last run)", (score/previous-1)*100)
+	}
+	return s
+}
+
+func runBench(cmd *cobra.Command, args []string) error {
+	if benchDuration < time.Second {
+		return fmt.Errorf("--duration must be at least 1s")
+	}
+
+	cfg, err := config.LoadState()
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+	baseDir, err := baseDirectory()
+	if err != nil {
+		return err
+	}
+
+	vmName, entry, err := resolveVM(baseDir, benchVM)
+	if err != nil {
+		return err
+	}
+	if running, pid := isVMRunning(baseDir, vmName); running {
+		return fmt.Errorf("VM '%s' is already running (PID %d); stop it first with 'vmterminal stop --vm %s'", vmName, pid, vmName)
+	}
+
+	effective := cfg
+	if entry != nil {
+		effective = entry.EffectiveState(cfg)
+	}
+	distroID := distro.DefaultID()
+	if effective.Distro != "" {
+		distroID = distro.ID(effective.Distro)
+	}
+	provider, err := distro.Get(distroID)
+	if err != nil {
+		return fmt.Errorf("get distro: %w", err)
+	}
+
+	dataDir := filepath.Join(baseDir, "data", vmName)
+	cacheDir := filepath.Join(baseDir, "cache")
+	state, err := vm.NewRootfsManager(dataDir, nil).CheckSetupState("disk")
+	if err != nil || !state.RootfsExtracted {
+		return fmt.Errorf("VM '%s' is not set up; run 'vmterminal run' first", vmName)
+	}
+	kernelArgs, err := kernelArgsFor(entry, nil)
+	if err != nil {
+		return err
+	}
+
+	// The benchmarks need neither networking nor shared directories
+	managerCfg := vm.ManagerConfig{
+		CacheDir:    cacheDir,
+		DataDir:     dataDir,
+		CPUs:        effective.CPUs,
+		MemoryMB:    effective.MemoryMB,
+		DiskSizeMB:  int64(effective.DiskSizeMB),
+		DiskName:    "disk",
+		KernelArgs:  kernelArgs,
+		StopTimeout: time.Duration(effective.StopTimeoutSeconds) * time.Second,
+		Hostname:    vmHostname(vmName, entry),
+		Provider:    provider,
+		SkipVerify:  skipVerify,
+		Mirrors:     assetMirrors(effective.Mirrors, distroID),
+		Progress:    newProgress(),
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	progressf("Booting VM '%s'...\n", vmName)
+	start := time.Now()
+	mgr, err := bootVM(ctx, managerCfg)
+	if err != nil {
+		return err
+	}
+	pauseSignals := handlePauseSignals(mgr, dataDir)
+	defer pauseSignals.Stop()
+	if err := writePIDFile(baseDir, vmName); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not write PID file: %v\n", err)
+	}
+	defer cleanupPIDFile(baseDir, vmName)
+	defer func() {
+		mgr.CloseConsole()
+		if mgr.State() == vm.StateRunning {
+			if err := mgr.Stop(context.Background()); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: stop VM: %v\n", err)
+			}
+		}
+		mgr.Wait()
+	}()
+
+	vmIn, vmOut, err := mgr.Console()
+	if err != nil {
+		return fmt.Errorf("get console: %w", err)
+	}
+	runner := vm.NewConsoleRunner(vmIn, vmOut)
+
+	bootCtx, cancel := context.WithTimeout(ctx, benchBootTimeout)
+	err = runner.Login(bootCtx, "root")
+	cancel()
+	if err != nil {
+		return err
+	}
+
+	info := mgr.DriverInfo()
+	mc := mgr.Config()
+	res := &benchResult{BenchResult: vm.BenchResult{
+		Time:       time.Now(),
+		VM:         vmName,
+		Distro:     string(distroID),
+		Hypervisor: info.Name,
+		Arch:       info.Arch,
+		CPUs:       mc.CPUs,
+		MemoryMB:   mc.MemoryMB,
+		BootTime:   time.Since(start),
+	}}
+
+	progressf("Measuring memory bandwidth...\n")
+	out, status, err := runner.Run(ctx, vm.BenchMemoryCommand)
+	if err != nil {
+		return fmt.Errorf("memory benchmark: %w", err)
+	}
+	if status != 0 {
+		return fmt.Errorf("memory benchmark: dd exited with status %d: %s", status, out)
+	}
+	if res.MemoryMBps, err = vm.ParseDDThroughput(out); err != nil {
+		return fmt.Errorf("memory benchmark: %w", err)
+	}
+
+	if _, status, err := runner.Run(ctx, "command -v sysbench >/dev/null"); err != nil {
+		return fmt.Errorf("look for sysbench: %w", err)
+	} else if status == 0 {
+		progressf("Measuring CPU speed for %s...\n", benchDuration)
+		cpuCtx, cancel := context.WithTimeout(ctx, benchDuration+time.Minute)
+		out, status, err := runner.Run(cpuCtx, vm.BenchCPUCommand(benchDuration))
+		cancel()
+		if err != nil {
+			return fmt.Errorf("CPU benchmark: %w", err)
+		}
+		if status != 0 {
+			return fmt.Errorf("CPU benchmark: sysbench exited with status %d: %s", status, out)
+		}
+		if res.CPUEventsPerSec, err = vm.ParseSysbenchCPU(out); err != nil {
+			return fmt.Errorf("CPU benchmark: %w", err)
+		}
+	}
+
+	historyPath := filepath.Join(baseDir, vm.BenchHistoryFile)
+	if history, err := vm.LoadBenchHistory(historyPath); err == nil {
+		if baseline, ok := vm.BenchBaselineFrom(history, res.Hypervisor, res.Arch); ok {
+			res.Baseline = &baseline
+		}
+		for i := len(history) - 1; i >= 0; i-- {
+			if history[i].VM == vmName {
+				res.Previous = &history[i]
+				break
+			}
+		}
+	}
+	if err := vm.AppendBenchResult(historyPath, res.BenchResult); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: record benchmark: %v\n", err)
+	}
+
+	return printResult(res)
+}
@@ -2,11 +2,13 @@ package cli
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
 
 	"github.com/javanstorm/vmterminal/internal/distro"
+	"github.com/javanstorm/vmterminal/internal/vm"
 	"github.com/spf13/cobra"
 )
 
@@ -35,22 +37,78 @@ var cacheListCmd = &cobra.Command{
 	RunE:  runCacheList,
 }
 
-var cacheClearDisk bool
+var cacheGCCmd = &cobra.Command{
+	Use:   "gc",
+	Short: "Remove downloads no cached distro version uses",
+	Long: `Downloaded assets are kept once, by content, in ~/.vmterminal/cas and
+linked from the per-version cache. 'cache clear' only removes the links,
+so assets shared with other versions stay available.
+
+gc removes every stored asset that no link in the cache points to.
+
+Examples:
+  vmt cache clear ubuntu
+  vmt cache gc`,
+	Args: cobra.NoArgs,
+	RunE: runCacheGC,
+}
+
+var (
+	cacheClearDisk bool
+	cacheClearVM   string
+)
 
 func init() {
 	cacheCmd.AddCommand(cacheClearCmd)
 	cacheCmd.AddCommand(cacheListCmd)
+	cacheCmd.AddCommand(cacheGCCmd)
 	cacheClearCmd.Flags().BoolVar(&cacheClearDisk, "disk", false, "Also remove disk images (full reset)")
+	cacheClearCmd.Flags().StringVar(&cacheClearVM, "vm", "", "VM whose disk --disk removes (default: active VM)")
+}
+
+// cacheClearResult is the structured output of cache clear.
+type cacheClearResult struct {
+	Distro      string `json:"distro,omitempty"`
+	Cleared     bool   `json:"cleared"`
+	DiskRemoved bool   `json:"disk_removed"`
+	StateReset  bool   `json:"state_reset"`
+}
+
+// RenderHuman prints the cache clear outcome as text.
+func (r *cacheClearResult) RenderHuman(w io.Writer) {
+	switch {
+	case r.Distro != "" && r.Cleared:
+		fmt.Fprintf(w, "Cleared cache for %s\n", r.Distro)
+	case r.Distro != "":
+		fmt.Fprintf(w, "No cache found for %s\n", r.Distro)
+	case r.Cleared:
+		fmt.Fprintln(w, "Cleared all cached assets")
+	default:
+		fmt.Fprintln(w, "No cached assets to clear")
+	}
+	if r.DiskRemoved {
+		fmt.Fprintln(w, "Removed disk image")
+	}
+	if r.StateReset {
+		fmt.Fprintln(w, "Reset VM state")
+	}
+	if r.Cleared {
+		fmt.Fprintln(w, "Downloads stay in the content store; run 'vmt cache gc' to free their space")
+	}
 }
 
 func runCacheClear(cmd *cobra.Command, args []string) error {
-	homeDir, err := os.UserHomeDir()
+	baseDir, err := baseDirectory()
 	if err != nil {
-		return fmt.Errorf("get home dir: %w", err)
+		return err
 	}
-	baseDir := filepath.Join(homeDir, ".vmterminal")
 	cacheDir := filepath.Join(baseDir, "cache")
-	dataDir := filepath.Join(baseDir, "data", "default")
+	vmName, _, err := resolveVM(baseDir, cacheClearVM)
+	if err != nil {
+		return err
+	}
+	dataDir := filepath.Join(baseDir, "data", vmName)
+	res := &cacheClearResult{}
 
 	if len(args) > 0 {
 		// Clear specific distro
@@ -66,110 +124,125 @@ func runCacheClear(cmd *cobra.Command, args []string) error {
 			return fmt.Errorf("unknown distro: %s (valid: %s)", distroID, strings.Join(validNames, ", "))
 		}
 
-		// Cache structure is <distro>/<version>/<arch>, so we clear the distro dir
-		targetDir := filepath.Join(cacheDir, distroID)
+		res.Distro = distroID
+	}
 
-		if _, err := os.Stat(targetDir); os.IsNotExist(err) {
-			fmt.Printf("No cache found for %s\n", distroID)
-		} else {
-			if err := os.RemoveAll(targetDir); err != nil {
-				return fmt.Errorf("clear %s cache: %w", distroID, err)
-			}
-			fmt.Printf("Cleared cache for %s\n", distroID)
-		}
+	res.Cleared, err = clearCache(cacheDir, res.Distro)
+	if err != nil {
+		return err
+	}
 
-		// Also clear disk if requested
-		if cacheClearDisk {
-			// Remove disk.raw and any rootfs.raw that might be in cache
-			diskPath := filepath.Join(dataDir, "disk.raw")
-			if _, err := os.Stat(diskPath); err == nil {
-				if err := os.Remove(diskPath); err != nil {
-					return fmt.Errorf("remove disk: %w", err)
-				}
-				fmt.Println("Removed disk image")
-			}
-			// Reset state
-			statePath := filepath.Join(dataDir, "state.json")
-			if err := os.Remove(statePath); err != nil && !os.IsNotExist(err) {
-				return fmt.Errorf("remove state: %w", err)
+	// Also clear disk if requested
+	if cacheClearDisk {
+		diskPath := filepath.Join(dataDir, "disk.raw")
+		if _, err := os.Stat(diskPath); err == nil {
+			if err := os.Remove(diskPath); err != nil {
+				return fmt.Errorf("remove disk: %w", err)
 			}
-			fmt.Println("Reset VM state")
+			res.DiskRemoved = true
 		}
-	} else {
-		// Clear all
-		if _, err := os.Stat(cacheDir); os.IsNotExist(err) {
-			fmt.Println("No cached assets to clear")
-		} else {
-			if err := os.RemoveAll(cacheDir); err != nil {
-				return fmt.Errorf("clear cache: %w", err)
-			}
-			fmt.Println("Cleared all cached assets")
+		// Reset state
+		statePath := filepath.Join(dataDir, "state.json")
+		if err := os.Remove(statePath); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("remove state: %w", err)
 		}
+		res.StateReset = true
+	}
 
-		if cacheClearDisk {
-			// Remove all disk images
-			diskPath := filepath.Join(dataDir, "disk.raw")
-			if _, err := os.Stat(diskPath); err == nil {
-				if err := os.Remove(diskPath); err != nil {
-					return fmt.Errorf("remove disk: %w", err)
-				}
-				fmt.Println("Removed disk image")
-			}
-			// Reset state
-			statePath := filepath.Join(dataDir, "state.json")
-			if err := os.Remove(statePath); err != nil && !os.IsNotExist(err) {
-				return fmt.Errorf("remove state: %w", err)
-			}
-			fmt.Println("Reset VM state")
+	return printResult(res)
+}
+
+// clearCache removes the cached assets of distroID, or the whole cache if
+// distroID is empty. It reports whether there was anything to remove.
+// Downloads are symlinks into the content store, which is left alone.
+func clearCache(cacheDir, distroID string) (bool, error) {
+	// Cache structure is <distro>/<version>/<arch>, so we clear the distro dir
+	targetDir := cacheDir
+	if distroID != "" {
+		targetDir = filepath.Join(cacheDir, distroID)
+	}
+	if _, err := os.Stat(targetDir); err != nil {
+		return false, nil
+	}
+	if err := os.RemoveAll(targetDir); err != nil {
+		if distroID != "" {
+			return false, fmt.Errorf("clear %s cache: %w", distroID, err)
 		}
+		return false, fmt.Errorf("clear cache: %w", err)
+	}
+	return true, nil
+}
+
+// cacheEntry is the cached size of one distro.
+type cacheEntry struct {
+	Distro string `json:"distro"`
+	Bytes  int64  `json:"bytes"`
+}
+
+// cacheListResult is the structured output of cache list.
+type cacheListResult struct {
+	Entries    []cacheEntry `json:"entries"`
+	TotalBytes int64        `json:"total_bytes"`
+	noCache    bool
+}
+
+// RenderHuman prints the cache listing as text.
+func (r *cacheListResult) RenderHuman(w io.Writer) {
+	if r.noCache {
+		fmt.Fprintln(w, "No cached assets")
+		return
 	}
 
-	return nil
+	fmt.Fprintln(w, "Cached assets:")
+	if len(r.Entries) == 0 {
+		fmt.Fprintln(w, "  (none)")
+		return
+	}
+	for _, e := range r.Entries {
+		fmt.Fprintf(w, "  %s: %s\n", e.Distro, formatSize(e.Bytes))
+	}
+	fmt.Fprintf(w, "\nTotal: %s\n", formatSize(r.TotalBytes))
 }
 
 func runCacheList(cmd *cobra.Command, args []string) error {
-	homeDir, err := os.UserHomeDir()
+	baseDir, err := baseDirectory()
 	if err != nil {
-		return fmt.Errorf("get home dir: %w", err)
+		return err
 	}
-	cacheDir := filepath.Join(homeDir, ".vmterminal", "cache")
+	cacheDir := filepath.Join(baseDir, "cache")
+	res := &cacheListResult{Entries: []cacheEntry{}}
 
 	if _, err := os.Stat(cacheDir); os.IsNotExist(err) {
-		fmt.Println("No cached assets")
-		return nil
+		res.noCache = true
+		return printResult(res)
 	}
 
-	fmt.Println("Cached assets:")
-
-	var totalSize int64
-	found := false
-
 	for _, d := range distro.AllDistros() {
 		distroDir := filepath.Join(cacheDir, string(d))
 		if _, err := os.Stat(distroDir); err == nil {
 			size, _ := dirSize(distroDir)
-			fmt.Printf("  %s: %s\n", d, formatSize(size))
-			totalSize += size
-			found = true
+			res.Entries = append(res.Entries, cacheEntry{Distro: string(d), Bytes: size})
+			res.TotalBytes += size
 		}
 	}
 
-	if !found {
-		fmt.Println("  (none)")
-	} else {
-		fmt.Printf("\nTotal: %s\n", formatSize(totalSize))
-	}
-
-	return nil
+	return printResult(res)
 }
 
+// dirSize returns the size of the files under path, counting symlinks
+// into the content store as the size of what they point to.
 func dirSize(path string) (int64, error) {
 	var size int64
-	err := filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+	err := filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
 		if err != nil {
 			// Return error instead of silently skipping
 			return err
 		}
+		if info != nil && info.Mode()&os.ModeSymlink != 0 {
+			if target, err := os.Stat(p); err == nil {
+				info = target
+			}
+		}
 		if info != nil && !info.IsDir() {
 			size += info.Size()
 		}
@@ -178,6 +251,34 @@ func dirSize(path string) (int64, error) {
 	return size, err
 }
 
+// cacheGCResult is the structured output of cache gc.
+type cacheGCResult struct {
+	*vm.GCResult
+}
+
+// RenderHuman prints what gc removed as text.
+func (r *cacheGCResult) RenderHuman(w io.Writer) {
+	if r.Removed == 0 {
+		fmt.Fprintf(w, "Nothing to remove (%d stored assets in use)\n", r.Kept)
+		return
+	}
+	fmt.Fprintf(w, "Removed %d unused assets, freeing %s (%d still in use)\n", r.Removed, formatSize(r.FreedBytes), r.Kept)
+}
+
+func runCacheGC(cmd *cobra.Command, args []string) error {
+	baseDir, err := baseDirectory()
+	if err != nil {
+		return err
+	}
+	cacheDir := filepath.Join(baseDir, "cache")
+
+	res, err := vm.NewContentStore(vm.ContentStoreDir(cacheDir)).GC(cacheDir)
+	if err != nil {
+		return err
+	}
+	return printResult(&cacheGCResult{res})
+}
+
 func formatSize(bytes int64) string {
 	const (
 		KB = 1024
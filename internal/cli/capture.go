@@ -0,0 +1,161 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/javanstorm/vmterminal/internal/config"
+	"github.com/javanstorm/vmterminal/internal/vm"
+	"github.com/javanstorm/vmterminal/pkg/hypervisor"
+	"github.com/spf13/cobra"
+)
+
+var captureCmd = &cobra.Command{
+	Use:   "capture --output <file.pcap>",
+	Short: "Capture a running VM's network traffic to a pcap file",
+	Long: `Write the packets a running VM sends and receives to a pcap file that
+tcpdump or Wireshark can open, until interrupted or --duration passes.
+
+The capture is taken on the host side of the VM's network interface: the
+tap device on Linux, and on macOS the vmnet bridge for NAT or the bridged
+host interface. The vmnet bridge and bridged interfaces also carry traffic
+of other VMs and of the host, so there only frames to or from the VM's MAC
+address are kept. Use --interface to capture everything on another host
+interface.
+
+Capturing needs root, CAP_NET_RAW on Linux, or read access to /dev/bpf*
+on macOS. To capture from the first moment of boot, use
+'vmterminal run --pcap-out' instead.
+
+Examples:
+  sudo vmt capture -o vm.pcap
+  sudo vmt capture --vm dev --duration 30s -o dev.pcap
+  sudo vmt capture --interface bridge101 -o nat.pcap`,
+	Args: cobra.NoArgs,
+	RunE: runCapture,
+}
+
+var (
+	captureVM        string
+	captureNIC       int
+	captureInterface string
+	captureOutput    string
+	captureDuration  time.Duration
+)
+
+func init() {
+	captureCmd.Flags().StringVar(&captureVM, "vm", "", "VM whose traffic to capture (default: the active VM)")
+	captureCmd.Flags().IntVar(&captureNIC, "nic", 0, "Index of the VM network interface to capture")
+	captureCmd.Flags().StringVar(&captureInterface, "interface", "", "Capture on this host interface instead of the VM's")
+	captureCmd.Flags().StringVarP(&captureOutput, "output", "o", "", "pcap file to write (required)")
+	captureCmd.Flags().DurationVar(&captureDuration, "duration", 0, "Stop after this long (default: until interrupted)")
+	captureCmd.MarkFlagRequired("output")
+}
+
+// captureResult is the structured output of capture.
+type captureResult struct {
+	Interface string  `json:"interface"`
+	Output    string  `json:"output"`
+	Packets   int64   `json:"packets"`
+	Seconds   float64 `json:"seconds"`
+}
+
+// RenderHuman prints the capture summary as text.
+func (r *captureResult) RenderHuman(w io.Writer) {
+	fmt.Fprintf(w, "Captured %d packets on %s in %.1fs to %s\n", r.Packets, r.Interface, r.Seconds, r.Output)
+}
+
+func runCapture(cmd *cobra.Command, args []string) error {
+	if captureDuration < 0 {
+		return fmt.Errorf("--duration must not be negative")
+	}
+
+	iface, mac := captureInterface, ""
+	if iface == "" {
+		var err error
+		if iface, mac, err = captureHostInterface(captureVM, captureNIC); err != nil {
+			return err
+		}
+	}
+
+	capture, err := vm.CaptureInterface(iface, mac, captureOutput)
+	if err != nil {
+		return err
+	}
+	started := time.Now()
+	progressf("Capturing on %s to %s; press Ctrl+C to stop.\n", iface, captureOutput)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if captureDuration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, captureDuration)
+		defer cancel()
+	}
+	<-ctx.Done()
+
+	packets, err := capture.Stop()
+	if err != nil {
+		return fmt.Errorf("capture on %s: %w", iface, err)
+	}
+	return printResult(&captureResult{
+		Interface: iface,
+		Output:    captureOutput,
+		Packets:   packets,
+		Seconds:   time.Since(started).Seconds(),
+	})
+}
+
+// captureHostInterface returns the host interface carrying the traffic of
+// NIC number nic of the named VM, or the active VM if name is empty, and
+// the MAC to filter on as HostInterface does. The VM must be running.
+func captureHostInterface(name string, nic int) (iface, mac string, err error) {
+	cfg, err := config.LoadState()
+	if err != nil {
+		return "", "", fmt.Errorf("load config: %w", err)
+	}
+
+	baseDir, err := baseDirectory()
+	if err != nil {
+		return "", "", err
+	}
+
+	vmName, entry, err := resolveVM(baseDir, name)
+	if err != nil {
+		return "", "", err
+	}
+	effective := cfg
+	if entry != nil {
+		effective = entry.EffectiveState(cfg)
+	}
+
+	if !isVMRunningCheck(baseDir, vmName) {
+		return "", "", &vm.ErrVMNotRunning{Name: vmName}
+	}
+
+	state, err := vm.NewStateFile(filepath.Join(baseDir, "data", vmName)).Load()
+	if err != nil {
+		return "", "", fmt.Errorf("load VM state: %w", err)
+	}
+	networks, err := config.NetworkInterfaces(effective)
+	if err != nil {
+		return "", "", err
+	}
+	vmCfg := &hypervisor.VMConfig{
+		EnableNetwork: effective.EnableNetwork || state.TapDevice != "",
+		MACAddress:    effective.MACAddress,
+		Networks:      networks,
+	}
+	// The first NIC's MAC may have been generated when the VM started
+	nics := vmCfg.NetworkInterfaces()
+	if len(nics) > 0 && nics[0].MAC == "" {
+		nics[0].MAC = state.MACAddress
+	}
+	return vm.HostInterface(nics, nic, state.TapDevice)
+}
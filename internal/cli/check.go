@@ -0,0 +1,145 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/javanstorm/vmterminal/internal/vm"
+	"github.com/spf13/cobra"
+)
+
+var checkCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Check the VM disk image for filesystem errors",
+	Long: `Check the filesystem on a VM's disk image without mounting it.
+
+The checker depends on the filesystem: e2fsck -n for ext4, xfs_repair -n
+for xfs and btrfs check --readonly for btrfs. The VM must be stopped.
+
+'vmterminal run' does this automatically when the previous shutdown was
+not clean, for example after a power loss or a killed process.
+
+Cloud-image distros (Ubuntu, Debian) boot a partitioned image from the
+cache and cannot be checked this way.
+
+Examples:
+  vmt check               # Check the active VM
+  vmt check --vm dev      # Check a specific VM
+  vmt check --repair      # Fix any errors found`,
+	Args: cobra.NoArgs,
+	RunE: runCheck,
+}
+
+var (
+	checkVM     string
+	checkRepair bool
+)
+
+func init() {
+	checkCmd.Flags().StringVar(&checkVM, "vm", "", "VM whose disk to check (default: active VM)")
+	checkCmd.Flags().BoolVar(&checkRepair, "repair", false, "Repair errors instead of only reporting them")
+}
+
+// diskCheckResult is the structured output of check.
+type diskCheckResult struct {
+	VM       string `json:"vm"`
+	Disk     string `json:"disk"`
+	FSType   string `json:"fs_type"`
+	ExitCode int    `json:"exit_code"`
+	OK       bool   `json:"ok"`
+	Repaired bool   `json:"repaired,omitempty"`
+	Output   string `json:"output,omitempty"`
+}
+
+// RenderHuman prints the check outcome followed by the checker's output.
+func (r *diskCheckResult) RenderHuman(w io.Writer) {
+	fmt.Fprintf(w, "Disk:   %s (%s)\n", r.Disk, r.FSType)
+	switch {
+	case r.OK && r.Repaired:
+		fmt.Fprintln(w, "Status: repaired")
+	case r.OK:
+		fmt.Fprintln(w, "Status: clean")
+	default:
+		fmt.Fprintf(w, "Status: errors found (exit code %d)\n", r.ExitCode)
+	}
+	if r.Output != "" && !r.OK {
+		fmt.Fprintf(w, "\n%s\n", r.Output)
+	}
+	if !r.OK && !r.Repaired {
+		fmt.Fprintf(w, "\nRun 'vmterminal check --vm %s --repair' to fix them.\n", r.VM)
+	}
+}
+
+func runCheck(cmd *cobra.Command, args []string) error {
+	baseDir, err := baseDirectory()
+	if err != nil {
+		return err
+	}
+
+	name := checkVM
+	if name == "" {
+		name = "default"
+		if entry := activeVMEntry(baseDir); entry != nil {
+			name = entry.Name
+		}
+	}
+	if running, pid := isVMRunning(baseDir, name); running {
+		return fmt.Errorf("VM %q is running (PID %d); stop it before checking its disk", name, pid)
+	}
+
+	if checkRepair {
+		progressf("Repairing disk of VM %q...\n", name)
+	}
+	res, err := checkVMDisk(baseDir, name, checkRepair)
+	if err != nil {
+		return err
+	}
+	if err := printResult(res); err != nil {
+		return err
+	}
+	if !res.OK {
+		return &ExitCodeError{Code: 1}
+	}
+	return nil
+}
+
+// checkVMDisk checks the disk image of the named VM. With repair, errors
+// are fixed first and the result reflects a read-only check afterwards.
+func checkVMDisk(baseDir, name string, repair bool) (*diskCheckResult, error) {
+	diskPath := vm.NewRootfsManager(filepath.Join(baseDir, "data", name), nil).DiskPath("disk")
+	if _, err := os.Stat(diskPath); os.IsNotExist(err) {
+		return nil, fmt.Errorf("VM %q has no disk image at %s", name, diskPath)
+	}
+
+	res := &diskCheckResult{VM: name, Disk: diskPath}
+	fsType := ""
+	if repair {
+		fixed, err := vm.RepairDisk(diskPath, "")
+		if err != nil {
+			return nil, diskCheckError(diskPath, err)
+		}
+		fsType = fixed.FSType
+		res.Repaired = true
+	}
+
+	check, err := vm.RunDiskCheck(diskPath, fsType, false)
+	if err != nil {
+		return nil, diskCheckError(diskPath, err)
+	}
+	res.FSType = check.FSType
+	res.ExitCode = check.ExitCode
+	res.OK = check.OK()
+	res.Output = check.Output
+	return res, nil
+}
+
+// diskCheckError explains an unsupported disk; other errors pass through.
+func diskCheckError(diskPath string, err error) error {
+	if errors.Is(err, vm.ErrDiskCheckUnsupported) {
+		return fmt.Errorf("%s: no ext4, xfs or btrfs filesystem found", diskPath)
+	}
+	return err
+}
@@ -0,0 +1,80 @@
+package cli
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckVMDiskMissing(t *testing.T) {
+	if _, err := checkVMDisk(t.TempDir(), "dev", false); err == nil {
+		t.Error("checkVMDisk() should fail when the VM has no disk")
+	}
+}
+
+func TestCheckVMDiskUnformatted(t *testing.T) {
+	baseDir := t.TempDir()
+	dataDir := filepath.Join(baseDir, "data", "dev")
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dataDir, "disk.raw"), make([]byte, 1024), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := checkVMDisk(baseDir, "dev", false)
+	if err == nil || !strings.Contains(err.Error(), "no ext4, xfs or btrfs filesystem") {
+		t.Errorf("checkVMDisk() error = %v, want unsupported filesystem", err)
+	}
+}
+
+func TestCheckVMDiskExt4(t *testing.T) {
+	for _, tool := range []string{"mkfs.ext4", "e2fsck", "blkid"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			t.Skipf("%s not available", tool)
+		}
+	}
+
+	baseDir := t.TempDir()
+	dataDir := filepath.Join(baseDir, "data", "dev")
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	diskPath := filepath.Join(dataDir, "disk.raw")
+	if err := os.WriteFile(diskPath, make([]byte, 8<<20), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := exec.Command("mkfs.ext4", "-q", "-F", diskPath).CombinedOutput(); err != nil {
+		t.Fatalf("mkfs.ext4: %v: %s", err, out)
+	}
+
+	res, err := checkVMDisk(baseDir, "dev", false)
+	if err != nil {
+		t.Fatalf("checkVMDisk() error = %v", err)
+	}
+	if !res.OK || res.FSType != "ext4" || res.ExitCode != 0 {
+		t.Errorf("checkVMDisk() = %+v, want clean ext4", res)
+	}
+
+	var buf bytes.Buffer
+	res.RenderHuman(&buf)
+	if !strings.Contains(buf.String(), "Status: clean") {
+		t.Errorf("RenderHuman() = %q, want clean status", buf.String())
+	}
+}
+
+func TestDiskCheckResultRenderErrors(t *testing.T) {
+	res := &diskCheckResult{VM: "dev", Disk: "disk.raw", FSType: "ext4", ExitCode: 4, Output: "Bad magic number"}
+
+	var buf bytes.Buffer
+	res.RenderHuman(&buf)
+	out := buf.String()
+	for _, want := range []string{"exit code 4", "Bad magic number", "vmterminal check --vm dev --repair"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("RenderHuman() missing %q:\n%s", want, out)
+		}
+	}
+}
@@ -2,15 +2,25 @@ package cli
 
 import (
 	"bufio"
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"reflect"
 	"runtime"
+	"sort"
 	"strconv"
 	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/javanstorm/vmterminal/internal/config"
 	"github.com/javanstorm/vmterminal/internal/distro"
+	"github.com/javanstorm/vmterminal/internal/vm"
+	"github.com/javanstorm/vmterminal/pkg/hypervisor"
 	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
 )
 
 var configCmd = &cobra.Command{
@@ -26,9 +36,489 @@ VM start. Run 'vmterminal reload' or restart the VM to apply changes.`,
 	RunE: runConfig,
 }
 
+var configResetCmd = &cobra.Command{
+	Use:   "reset",
+	Short: "Restore the default configuration",
+	Long: `Replace the internal state file with the default configuration, to
+recover when it no longer loads, e.g. after a bad manual edit. config.yaml
+is not changed.
+
+With --hard, also delete the VM registry (vms.json), the active VM marker,
+and the default VM's state file. Disk images and snapshots are kept.
+
+The files to be changed are listed before asking for confirmation.`,
+	Args: cobra.NoArgs,
+	RunE: runConfigReset,
+}
+
+var configResetHard bool
+
+func init() {
+	configResetCmd.Flags().BoolVar(&configResetHard, "hard", false, "Also delete the VM registry, active VM and default VM state")
+	configCmd.AddCommand(configResetCmd)
+	noJSON(configCmd)
+}
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Check the configuration for problems",
+	Long: `Check the effective configuration against this host and hypervisor.
+
+Errors stop the VM from starting; warnings let it start with degraded
+functionality, e.g. more CPUs or memory than the host has. Exits with
+status 1 if there are errors, so it can gate configuration changes in CI.
+
+Examples:
+  vmterminal config validate
+  vmterminal config validate --json`,
+	Args: cobra.NoArgs,
+	RunE: runConfigValidate,
+}
+
+func init() {
+	configCmd.AddCommand(configValidateCmd)
+}
+
+// configValidateResult is the structured output of config validate.
+type configValidateResult struct {
+	Issues []config.ValidationIssue `json:"issues"`
+	OK     bool                     `json:"ok"`
+
+	color bool
+}
+
+// RenderHuman prints each issue, errors in red and warnings in yellow.
+func (r *configValidateResult) RenderHuman(w io.Writer) {
+	for _, issue := range r.Issues {
+		label, color := "warning", colorYellow
+		if issue.Level == config.LevelError {
+			label, color = "error", colorRed
+		}
+		if r.color {
+			label = color + label + colorReset
+		}
+		fmt.Fprintf(w, "%s [%s]: %s\n", label, issue.Field, issue.Message)
+	}
+	if len(r.Issues) == 0 {
+		fmt.Fprintln(w, "Configuration is valid.")
+	}
+}
+
+func runConfigValidate(cmd *cobra.Command, args []string) error {
+	cfg, err := config.LoadState()
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+	driver, err := hypervisor.NewDriver()
+	if err != nil {
+		return fmt.Errorf("create driver: %w", err)
+	}
+
+	res := &configValidateResult{
+		Issues: []config.ValidationIssue{},
+		color:  useColor(os.Stdout),
+	}
+	if err := config.ValidateState(cfg); err != nil {
+		res.Issues = append(res.Issues, config.ValidationIssue{
+			Level:   config.LevelError,
+			Field:   "State",
+			Message: err.Error(),
+		})
+	}
+	res.Issues = append(res.Issues, config.ValidateConfig(cfg, driver.Capabilities())...)
+	res.OK = !config.HasValidationErrors(res.Issues)
+
+	if err := printResult(res); err != nil {
+		return err
+	}
+	if !res.OK {
+		return &ExitCodeError{Code: 1}
+	}
+	return nil
+}
+
+var configMigrateDataCmd = &cobra.Command{
+	Use:   "migrate-data",
+	Short: "Move VM data into a relocated data directory",
+	Long: `Move disk images, snapshots, the asset cache and the VM registry from
+~/.vmterminal into the data directory set by data_dir or VMT_DATA_DIR.
+config.yaml, state.json and custom distros stay in ~/.vmterminal.
+
+Run it once after changing the data directory. The entries to be moved are
+listed before asking for confirmation; --dry-run only lists them. Stop all
+VMs first.
+
+Examples:
+  VMT_DATA_DIR=/mnt/big/vmterminal vmterminal config migrate-data --dry-run
+  VMT_DATA_DIR=/mnt/big/vmterminal vmterminal config migrate-data`,
+	Args: cobra.NoArgs,
+	RunE: runConfigMigrateData,
+}
+
+var configMigrateDryRun bool
+
+func init() {
+	configMigrateDataCmd.Flags().BoolVar(&configMigrateDryRun, "dry-run", false, "List what would be moved without moving it")
+	configCmd.AddCommand(configMigrateDataCmd)
+}
+
+// migrateDataResult is the outcome of 'config migrate-data'.
+type migrateDataResult struct {
+	From    string   `json:"from"`
+	To      string   `json:"to"`
+	Entries []string `json:"entries"`
+	Action  string   `json:"action"` // unchanged, nothing, dry_run, aborted, moved
+}
+
+// RenderHuman prints the migration outcome as text. The entries of a real
+// move were already listed before the confirmation prompt.
+func (r *migrateDataResult) RenderHuman(w io.Writer) {
+	switch r.Action {
+	case "unchanged":
+		fmt.Fprintf(w, "The data directory is %s; set data_dir or %s to move it.\n", r.To, config.DataDirEnv)
+	case "nothing":
+		fmt.Fprintf(w, "Nothing to move: %s holds no VM data.\n", r.From)
+	case "dry_run":
+		printMigrationEntries(w, r.From, r.To, r.Entries)
+	case "aborted":
+		fmt.Fprintln(w, "Aborted.")
+	case "moved":
+		fmt.Fprintf(w, "VM data moved to %s.\n", r.To)
+	}
+}
+
+// printMigrationEntries lists what a data directory migration moves.
+func printMigrationEntries(w io.Writer, from, to string, entries []string) {
+	fmt.Fprintf(w, "This will move from %s to %s:\n", from, to)
+	for _, name := range entries {
+		fmt.Fprintf(w, "  %s\n", name)
+	}
+}
+
+func runConfigMigrateData(cmd *cobra.Command, args []string) error {
+	m, err := config.PlanDataDirMigration()
+	if err != nil {
+		return fmt.Errorf("plan migration: %w", err)
+	}
+	result := &migrateDataResult{From: m.From, To: m.To, Entries: m.Entries}
+	if result.Entries == nil {
+		result.Entries = []string{}
+	}
+	if m.From == m.To {
+		result.Action = "unchanged"
+		return printResult(result)
+	}
+	if len(m.Entries) == 0 {
+		result.Action = "nothing"
+		return printResult(result)
+	}
+	if configMigrateDryRun {
+		result.Action = "dry_run"
+		return printResult(result)
+	}
+
+	running, err := listRunningVMs(m.From, time.Now())
+	if err != nil {
+		return err
+	}
+	if len(running) > 0 {
+		return fmt.Errorf("VM '%s' is running; stop all VMs before moving their data", running[0].Name)
+	}
+	printMigrationEntries(promptWriter(), m.From, m.To, m.Entries)
+	if !promptYesNo("Continue?", false) {
+		result.Action = "aborted"
+		return printResult(result)
+	}
+
+	if err := m.Run(); err != nil {
+		return fmt.Errorf("migrate data: %w", err)
+	}
+	result.Action = "moved"
+	return printResult(result)
+}
+
+// warnUnmigratedData points to 'config migrate-data' when the data
+// directory has moved but VMs are still in ~/.vmterminal.
+func warnUnmigratedData() {
+	m, err := config.PlanDataDirMigration()
+	if err != nil || m.From == m.To {
+		return
+	}
+	for _, name := range m.Entries {
+		if name == "data" || name == "vms.json" {
+			fmt.Fprintf(os.Stderr, "Warning: VM data is still in %s; run 'vmterminal config migrate-data' to move it to %s\n", m.From, m.To)
+			return
+		}
+	}
+}
+
+var configShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Print the effective configuration",
+	Long: `Print every configuration field with its effective value, after
+config.yaml, the state file, VMT_* environment variables and, with --vm,
+the VM's own overrides, together with what the field does.
+
+Formats are table (the default), yaml, with each description as a comment,
+and json. --diff-from-default only prints fields changed from the
+defaults.
+
+Examples:
+  vmterminal config show
+  vmterminal config show --format yaml > config.yaml
+  vmterminal config show --vm dev --diff-from-default`,
+	Args: cobra.NoArgs,
+	RunE: runConfigShow,
+}
+
+var (
+	configShowFormat string
+	configShowDiff   bool
+	configShowVM     string
+)
+
+func init() {
+	configShowCmd.Flags().StringVar(&configShowFormat, "format", "table", "Output format: table, yaml, or json")
+	configShowCmd.Flags().BoolVar(&configShowDiff, "diff-from-default", false, "Only print fields that differ from the defaults")
+	configShowCmd.Flags().StringVar(&configShowVM, "vm", "", "Include the overrides of this VM")
+	configCmd.AddCommand(configShowCmd)
+}
+
+// fieldAnnotations describes each config.State field, keyed by its YAML
+// name.
+var fieldAnnotations = map[string]string{
+	"distro":                "Linux distribution to run",
+	"cpus":                  "Virtual CPUs",
+	"memory_mb":             "Memory in MB",
+	"disk_size_mb":          "Root disk size in MB",
+	"shared_dirs":           "Host directories shared with the VM",
+	"enable_network":        "Enable networking (ignored when networks is set)",
+	"mac_address":           "MAC address (empty = generated)",
+	"networks":              "Network interfaces as <mode>[:<iface>][,mac=<addr>]",
+	"ssh_host_port":         "Host port forwarded to the guest's SSH (0 = disabled)",
+	"is_default_terminal":   "VMTerminal is the login shell",
+	"port_forwards":         "Extra host-to-guest port forwards",
+	"max_snapshots":         "Snapshots kept per VM (0 = unlimited)",
+	"snapshot_max_age_days": "Prune snapshots older than this many days (0 = never)",
+	"snapshot_schedule":     "Automatic snapshots while a VM runs",
+	"mirrors":               "Download mirrors as [<distro>=]<url>",
+	"version_override":      "Distro releases pinned instead of the built-in ones",
+	"extra_cmdline_file":    "File of extra kernel arguments (empty = ~/.vmterminal/extra_cmdline)",
+	"stop_timeout_seconds":  "Seconds the guest gets to shut down before it is killed (0 = 30)",
+	"cache_max_gb":          "Asset cache size limit in GB (0 = unlimited)",
+	"data_dir":              "Data directory for disks, snapshots and cache (empty = VMT_DATA_DIR or ~/.vmterminal)",
+}
+
+// configField is one configuration field and its value.
+type configField struct {
+	Field       string      `json:"field"`
+	Value       interface{} `json:"value"`
+	Description string      `json:"description"`
+}
+
+// configFields returns the fields of s in declaration order. If def is not
+// nil, fields equal to def's are left out; empty and unset lists count as
+// equal.
+func configFields(s, def *config.State) []configField {
+	v := reflect.ValueOf(s).Elem()
+	var defV reflect.Value
+	if def != nil {
+		defV = reflect.ValueOf(def).Elem()
+	}
+
+	fields := []configField{}
+	for i := 0; i < v.NumField(); i++ {
+		name, _, _ := strings.Cut(v.Type().Field(i).Tag.Get("yaml"), ",")
+		value := v.Field(i)
+		if def != nil && configValuesEqual(value, defV.Field(i)) {
+			continue
+		}
+		fields = append(fields, configField{
+			Field:       name,
+			Value:       value.Interface(),
+			Description: fieldAnnotations[name],
+		})
+	}
+	return fields
+}
+
+// configValuesEqual reports whether a and b hold the same value, taking
+// nil and empty slices and maps to be equal.
+func configValuesEqual(a, b reflect.Value) bool {
+	switch a.Kind() {
+	case reflect.Slice, reflect.Map:
+		if a.Len() == 0 && b.Len() == 0 {
+			return true
+		}
+	}
+	return reflect.DeepEqual(a.Interface(), b.Interface())
+}
+
+// formatConfigValue formats a field value for the table. Lists are joined
+// with commas and maps are shown as sorted key=value pairs.
+func formatConfigValue(v interface{}) string {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Slice:
+		items := make([]string, rv.Len())
+		for i := range items {
+			items[i] = fmt.Sprint(rv.Index(i).Interface())
+		}
+		return strings.Join(items, ", ")
+	case reflect.Map:
+		items := make([]string, 0, rv.Len())
+		for _, k := range rv.MapKeys() {
+			items = append(items, fmt.Sprintf("%v=%v", k.Interface(), rv.MapIndex(k).Interface()))
+		}
+		sort.Strings(items)
+		return strings.Join(items, ", ")
+	case reflect.Ptr:
+		if rv.IsNil() {
+			return ""
+		}
+		data, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(rv.Elem().Interface())
+		}
+		return string(data)
+	}
+	return fmt.Sprint(v)
+}
+
+// configShowResult is the structured output of config show.
+type configShowResult struct {
+	Fields []configField `json:"fields"`
+
+	format string
+}
+
+// RenderHuman prints the fields as a table or as commented YAML.
+func (r *configShowResult) RenderHuman(w io.Writer) {
+	if r.format == "yaml" {
+		renderConfigYAML(w, r.Fields)
+		return
+	}
+	if len(r.Fields) == 0 {
+		fmt.Fprintln(w, "All fields have their default values.")
+		return
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "FIELD\tVALUE\tDESCRIPTION")
+	for _, f := range r.Fields {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", f.Field, formatConfigValue(f.Value), f.Description)
+	}
+	tw.Flush()
+}
+
+// renderConfigYAML prints fields as a YAML mapping with each description
+// as a comment on its line.
+func renderConfigYAML(w io.Writer, fields []configField) {
+	doc := &yaml.Node{Kind: yaml.MappingNode}
+	for _, f := range fields {
+		key := &yaml.Node{Kind: yaml.ScalarNode, Value: f.Field}
+		value := &yaml.Node{}
+		if err := value.Encode(f.Value); err != nil {
+			value = &yaml.Node{Kind: yaml.ScalarNode, Value: fmt.Sprint(f.Value)}
+		}
+		// A block list or map starts on the next line, so its comment goes
+		// after the key
+		if len(value.Content) > 0 {
+			key.LineComment = f.Description
+		} else {
+			value.LineComment = f.Description
+		}
+		doc.Content = append(doc.Content, key, value)
+	}
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	enc.Encode(doc)
+	enc.Close()
+}
+
+func runConfigShow(cmd *cobra.Command, args []string) error {
+	switch configShowFormat {
+	case "table", "yaml", "json":
+	default:
+		return fmt.Errorf("invalid format %q: use table, yaml, or json", configShowFormat)
+	}
+
+	cfg, err := config.LoadState()
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+	if configShowVM != "" {
+		baseDir, err := baseDirectory()
+		if err != nil {
+			return err
+		}
+		entry, err := vm.NewRegistry(baseDir).GetVM(configShowVM)
+		if err != nil {
+			return err
+		}
+		cfg = entry.EffectiveState(cfg)
+	}
+
+	var def *config.State
+	if configShowDiff {
+		def = config.DefaultState()
+	}
+	res := &configShowResult{Fields: configFields(cfg, def), format: configShowFormat}
+	if configShowFormat == "json" {
+		return JSONFormatter{}.Format(os.Stdout, res)
+	}
+	return printResult(res)
+}
+
+// configResetResult is the outcome of 'config reset'.
+type configResetResult struct {
+	State   string   `json:"state"`
+	Removed []string `json:"removed"`
+	Reset   bool     `json:"reset"`
+}
+
+// RenderHuman prints the reset outcome as text.
+func (r *configResetResult) RenderHuman(w io.Writer) {
+	if !r.Reset {
+		fmt.Fprintln(w, "Aborted.")
+		return
+	}
+	fmt.Fprintln(w, "Configuration reset to defaults.")
+}
+
+func runConfigReset(cmd *cobra.Command, args []string) error {
+	paths, err := config.GetPaths()
+	if err != nil {
+		return fmt.Errorf("get paths: %w", err)
+	}
+	remove, err := config.ResetFiles(configResetHard)
+	if err != nil {
+		return fmt.Errorf("list files: %w", err)
+	}
+
+	result := &configResetResult{State: filepath.Join(paths.StateDir, "state.json"), Removed: []string{}}
+	w := promptWriter()
+	fmt.Fprintf(w, "This will replace %s with the default configuration.\n", result.State)
+	if len(remove) > 0 {
+		fmt.Fprintln(w, "It will also delete:")
+		for _, path := range remove {
+			fmt.Fprintf(w, "  %s\n", path)
+		}
+	}
+	if !promptYesNo("Continue?", false) {
+		return printResult(result)
+	}
+
+	if err := config.ResetState(configResetHard); err != nil {
+		return fmt.Errorf("reset config: %w", err)
+	}
+	result.Removed = append(result.Removed, remove...)
+	result.Reset = true
+	return printResult(result)
+}
+
 func runConfig(cmd *cobra.Command, args []string) error {
 	// Load current config
-	cfg, err := config.LoadState()
+	cfg, err := config.LoadSavedState()
 	if err != nil {
 		cfg = config.DefaultState()
 	}
@@ -92,12 +582,12 @@ func runConfig(cmd *cobra.Command, args []string) error {
 }
 
 // formatSharedDirs formats the shared directories for display.
-func formatSharedDirs(dirs []string) string {
+func formatSharedDirs(dirs []config.SharedDir) string {
 	if len(dirs) == 0 {
 		return "(none)"
 	}
 	if len(dirs) == 1 {
-		return dirs[0]
+		return dirs[0].String()
 	}
 	return fmt.Sprintf("%s (+%d more)", dirs[0], len(dirs)-1)
 }
@@ -156,7 +646,7 @@ func editBool(reader *bufio.Reader, name string, current bool) bool {
 }
 
 // editSharedDirs allows editing the shared directories.
-func editSharedDirs(reader *bufio.Reader, current []string) []string {
+func editSharedDirs(reader *bufio.Reader, current []config.SharedDir) []config.SharedDir {
 	fmt.Println()
 	fmt.Println("Shared Directories:")
 	if len(current) == 0 {
@@ -168,13 +658,13 @@ func editSharedDirs(reader *bufio.Reader, current []string) []string {
 	}
 	fmt.Println()
 	fmt.Println("Options:")
-	fmt.Println("  a <path> - Add a directory")
-	fmt.Println("  d <num>  - Delete a directory by number")
-	fmt.Println("  c        - Clear all directories")
-	fmt.Println("  q        - Done editing")
+	fmt.Println("  a <path>[:ro] - Add a directory (:ro = read-only)")
+	fmt.Println("  d <num>       - Delete a directory by number")
+	fmt.Println("  c             - Clear all directories")
+	fmt.Println("  q             - Done editing")
 	fmt.Println()
 
-	dirs := make([]string, len(current))
+	dirs := make([]config.SharedDir, len(current))
 	copy(dirs, current)
 
 	for {
@@ -193,21 +683,25 @@ func editSharedDirs(reader *bufio.Reader, current []string) []string {
 		switch cmd {
 		case "a", "add":
 			if len(parts) < 2 {
-				fmt.Println("Usage: a <path>")
+				fmt.Println("Usage: a <path>[:ro]")
+				continue
+			}
+			dir, err := config.ParseSharedDir(strings.TrimSpace(parts[1]))
+			if err != nil {
+				fmt.Println("Usage: a <path>[:ro]")
 				continue
 			}
-			path := strings.TrimSpace(parts[1])
 			// Expand ~ to home directory
-			if strings.HasPrefix(path, "~") {
+			if strings.HasPrefix(dir.Path, "~") {
 				home, _ := os.UserHomeDir()
-				path = home + path[1:]
+				dir.Path = home + dir.Path[1:]
 			}
 			// Check if path exists
-			if _, err := os.Stat(path); os.IsNotExist(err) {
-				fmt.Printf("Warning: Path does not exist: %s\n", path)
+			if _, err := os.Stat(dir.Path); os.IsNotExist(err) {
+				fmt.Printf("Warning: Path does not exist: %s\n", dir.Path)
 			}
-			dirs = append(dirs, path)
-			fmt.Printf("Added: %s\n", path)
+			dirs = append(dirs, dir)
+			fmt.Printf("Added: %s\n", dir)
 
 		case "d", "delete":
 			if len(parts) < 2 {
@@ -224,7 +718,7 @@ func editSharedDirs(reader *bufio.Reader, current []string) []string {
 			fmt.Printf("Removed: %s\n", removed)
 
 		case "c", "clear":
-			dirs = []string{}
+			dirs = []config.SharedDir{}
 			fmt.Println("Cleared all directories.")
 
 		default:
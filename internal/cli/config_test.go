@@ -0,0 +1,72 @@
+package cli
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/javanstorm/vmterminal/internal/config"
+	"github.com/javanstorm/vmterminal/internal/distro"
+	"gopkg.in/yaml.v3"
+)
+
+func TestFieldAnnotationsCoverState(t *testing.T) {
+	fields := configFields(config.DefaultState(), nil)
+	if len(fields) != reflect.TypeOf(config.State{}).NumField() {
+		t.Fatalf("configFields returned %d fields, want one per State field", len(fields))
+	}
+	for _, f := range fields {
+		if f.Description == "" {
+			t.Errorf("field %s has no annotation", f.Field)
+		}
+	}
+	if len(fieldAnnotations) != len(fields) {
+		t.Errorf("fieldAnnotations has %d entries for %d fields", len(fieldAnnotations), len(fields))
+	}
+}
+
+func TestConfigFieldsDiffFromDefault(t *testing.T) {
+	s := config.DefaultState()
+	s.MemoryMB = 8192
+	s.Mirrors = []string{} // Empty is the same as unset
+	s.VersionOverride = map[distro.ID]string{}
+
+	fields := configFields(s, config.DefaultState())
+	if len(fields) != 1 || fields[0].Field != "memory_mb" || fields[0].Value != 8192 {
+		t.Errorf("configFields = %+v, want only memory_mb", fields)
+	}
+}
+
+func TestConfigShowResultRender(t *testing.T) {
+	s := config.DefaultState()
+	s.CPUs = 4
+	s.Mirrors = []string{"https://a.example.com", "alpine=https://b.example.com"}
+	res := &configShowResult{Fields: configFields(s, nil)}
+
+	var buf bytes.Buffer
+	res.RenderHuman(&buf)
+	if !strings.HasPrefix(buf.String(), "FIELD") ||
+		!strings.Contains(buf.String(), "https://a.example.com, alpine=https://b.example.com") {
+		t.Errorf("table output:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	res.format = "yaml"
+	res.RenderHuman(&buf)
+	out := buf.String()
+	if !strings.Contains(out, "cpus: 4 # Virtual CPUs\n") {
+		t.Errorf("yaml output lacks an annotated cpus line:\n%s", out)
+	}
+	if !strings.Contains(out, "mirrors: # Download mirrors") {
+		t.Errorf("yaml output lacks an annotated mirrors key:\n%s", out)
+	}
+	// The output is a config.yaml that loads back
+	var back config.State
+	if err := yaml.Unmarshal(buf.Bytes(), &back); err != nil {
+		t.Fatalf("yaml output does not parse: %v", err)
+	}
+	if back.CPUs != 4 || !reflect.DeepEqual(back.Mirrors, s.Mirrors) {
+		t.Errorf("yaml output loads as %+v", back)
+	}
+}
@@ -0,0 +1,214 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/javanstorm/vmterminal/internal/config"
+	"github.com/javanstorm/vmterminal/internal/vm"
+	"github.com/spf13/cobra"
+)
+
+// vmPathPrefix marks the VM side of a cp argument.
+const vmPathPrefix = "vm:"
+
+// sshRemote is the login used for every SSH-based command.
+const sshRemote = "root@localhost"
+
+var (
+	cpVM        string
+	cpRecursive bool
+	sftpVM      string
+)
+
+var cpCmd = &cobra.Command{
+	Use:   "cp <src> <dst>",
+	Short: "Copy files to or from the VM",
+	Long: `Copy files between the host and the VM with scp.
+
+Prefix the VM side with "vm:". Exactly one of src and dst must be a VM
+path. The SSH port and key are taken from the configuration.
+
+Examples:
+  vmterminal cp ./notes.txt vm:/root/
+  vmterminal cp vm:/var/log/messages ./messages
+  vmterminal cp -r ./project vm:/root/project`,
+	Args: cobra.ExactArgs(2),
+	RunE: runCp,
+}
+
+var sftpCmd = &cobra.Command{
+	Use:   "sftp",
+	Short: "Open an interactive SFTP session with the VM",
+	Args:  cobra.NoArgs,
+	RunE:  runSFTP,
+}
+
+func init() {
+	cpCmd.Flags().StringVar(&cpVM, "vm", "", "VM to copy to or from (default: active VM)")
+	cpCmd.Flags().BoolVarP(&cpRecursive, "recursive", "r", false, "Copy directories recursively")
+	sftpCmd.Flags().StringVar(&sftpVM, "vm", "", "VM to connect to (default: active VM)")
+	noJSON(cpCmd, sftpCmd)
+}
+
+// sshTarget is what an SSH-based command needs to reach a running VM.
+type sshTarget struct {
+	State   *config.State // Effective config of the target VM
+	DataDir string        // The VM's data directory
+	KeyPath string
+
+	// VsockSocket is the VM process's proxy to the guest's SSH vsock
+	// port, or empty if the VM has none.
+	VsockSocket string
+}
+
+// resolveSSHTarget returns the SSH target for the named VM, or the active VM
+// if name is empty. It fails if the VM is not running or not reachable over
+// TCP, which ssh, scp and sftp need.
+func resolveSSHTarget(name string) (*sshTarget, error) {
+	target, err := resolveVMTarget(name)
+	if err != nil {
+		return nil, err
+	}
+	if target.State.SSHHostPort == 0 {
+		return nil, fmt.Errorf("SSH port forwarding is disabled; set an SSH host port with 'vmterminal config'")
+	}
+	return target, nil
+}
+
+// resolveVMTarget is resolveSSHTarget for commands that speak SSH
+// themselves and can also use the vsock proxy, so the SSH host port may be
+// disabled as long as the proxy exists.
+func resolveVMTarget(name string) (*sshTarget, error) {
+	cfg, err := config.LoadState()
+	if err != nil {
+		return nil, fmt.Errorf("load config: %w", err)
+	}
+
+	baseDir, err := baseDirectory()
+	if err != nil {
+		return nil, err
+	}
+
+	vmName, entry, err := resolveVM(baseDir, name)
+	if err != nil {
+		return nil, err
+	}
+	effective := cfg
+	if entry != nil {
+		effective = entry.EffectiveState(cfg)
+	}
+
+	if !isVMRunningCheck(baseDir, vmName) {
+		return nil, &vm.ErrVMNotRunning{Name: vmName}
+	}
+	socket := vsockSocket(baseDir, vmName)
+	if effective.SSHHostPort == 0 && socket == "" {
+		return nil, fmt.Errorf("SSH port forwarding is disabled; set an SSH host port with 'vmterminal config'")
+	}
+
+	keyPath, err := vm.NewSSHKeyManager(baseDir).PrivateKeyPath()
+	if err != nil {
+		return nil, fmt.Errorf("no SSH key found; generate one with 'vmterminal ssh keygen'")
+	}
+
+	return &sshTarget{
+		State:       effective,
+		DataDir:     filepath.Join(baseDir, "data", vmName),
+		KeyPath:     keyPath,
+		VsockSocket: socket,
+	}, nil
+}
+
+// vsockSocket returns the named VM's vsock proxy socket if its VM process
+// created one.
+func vsockSocket(baseDir, vmName string) string {
+	path := vm.VsockSocketPath(filepath.Join(baseDir, "data", vmName))
+	if fi, err := os.Stat(path); err != nil || fi.Mode()&os.ModeSocket == 0 {
+		return ""
+	}
+	return path
+}
+
+// sshOptions returns the options shared by ssh, scp and sftp. portFlag is
+// "-p" for ssh and "-P" for scp and sftp.
+func (t *sshTarget) sshOptions(portFlag string) []string {
+	return []string{
+		"-i", t.KeyPath,
+		portFlag, strconv.Itoa(t.State.SSHHostPort),
+		"-o", "StrictHostKeyChecking=no",
+		"-o", "UserKnownHostsFile=/dev/null",
+		"-o", "LogLevel=ERROR",
+	}
+}
+
+// scpArgs returns the scp arguments for copying src to dst, where exactly
+// one of them is a vm: path.
+func scpArgs(t *sshTarget, src, dst string, recursive bool) ([]string, error) {
+	srcVM := strings.HasPrefix(src, vmPathPrefix)
+	dstVM := strings.HasPrefix(dst, vmPathPrefix)
+	if srcVM == dstVM {
+		return nil, fmt.Errorf("exactly one of source and destination must start with %q", vmPathPrefix)
+	}
+
+	remote := func(p string) (string, error) {
+		p = strings.TrimPrefix(p, vmPathPrefix)
+		if p == "" {
+			return "", fmt.Errorf("empty VM path")
+		}
+		return sshRemote + ":" + p, nil
+	}
+
+	var err error
+	if srcVM {
+		src, err = remote(src)
+	} else {
+		dst, err = remote(dst)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	args := t.sshOptions("-P")
+	if recursive {
+		args = append(args, "-r")
+	}
+	return append(args, src, dst), nil
+}
+
+// execInteractive runs name with args attached to the terminal.
+func execInteractive(name string, args []string) error {
+	c := exec.Command(name, args...)
+	c.Stdin = os.Stdin
+	c.Stdout = os.Stdout
+	c.Stderr = os.Stderr
+	if err := c.Run(); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return nil
+}
+
+func runCp(cmd *cobra.Command, args []string) error {
+	target, err := resolveSSHTarget(cpVM)
+	if err != nil {
+		return err
+	}
+
+	scp, err := scpArgs(target, args[0], args[1], cpRecursive)
+	if err != nil {
+		return err
+	}
+	return execInteractive("scp", scp)
+}
+
+func runSFTP(cmd *cobra.Command, args []string) error {
+	target, err := resolveSSHTarget(sftpVM)
+	if err != nil {
+		return err
+	}
+	return execInteractive("sftp", append(target.sshOptions("-P"), sshRemote))
+}
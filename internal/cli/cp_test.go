@@ -0,0 +1,104 @@
+package cli
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/javanstorm/vmterminal/internal/config"
+	"github.com/javanstorm/vmterminal/internal/vm"
+)
+
+func TestSCPArgs(t *testing.T) {
+	target := &sshTarget{State: &config.State{SSHHostPort: 2222}, KeyPath: "/k/id"}
+	opts := []string{
+		"-i", "/k/id", "-P", "2222",
+		"-o", "StrictHostKeyChecking=no",
+		"-o", "UserKnownHostsFile=/dev/null",
+		"-o", "LogLevel=ERROR",
+	}
+
+	tests := []struct {
+		name      string
+		src, dst  string
+		recursive bool
+		want      []string
+	}{
+		{"to VM", "notes.txt", "vm:/root/", false, append(opts[:len(opts):len(opts)], "notes.txt", "root@localhost:/root/")},
+		{"from VM", "vm:/var/log/messages", ".", false, append(opts[:len(opts):len(opts)], "root@localhost:/var/log/messages", ".")},
+		{"recursive", "src", "vm:src", true, append(opts[:len(opts):len(opts)], "-r", "src", "root@localhost:src")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := scpArgs(target, tt.src, tt.dst, tt.recursive)
+			if err != nil {
+				t.Fatalf("scpArgs failed: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("scpArgs = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSCPArgsInvalid(t *testing.T) {
+	target := &sshTarget{State: &config.State{SSHHostPort: 2222}, KeyPath: "/k/id"}
+
+	for _, tt := range []struct{ src, dst string }{
+		{"a", "b"},
+		{"vm:/a", "vm:/b"},
+		{"vm:", "b"},
+	} {
+		if _, err := scpArgs(target, tt.src, tt.dst, false); err == nil {
+			t.Errorf("scpArgs(%q, %q) should fail", tt.src, tt.dst)
+		}
+	}
+}
+
+func TestVsockSocket(t *testing.T) {
+	baseDir := t.TempDir()
+	dataDir := filepath.Join(baseDir, "data", "dev")
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := vsockSocket(baseDir, "dev"); got != "" {
+		t.Errorf("vsockSocket() without socket = %q, want empty", got)
+	}
+
+	// A regular file left at the path is not a proxy
+	path := vm.VsockSocketPath(dataDir)
+	if err := os.WriteFile(path, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := vsockSocket(baseDir, "dev"); got != "" {
+		t.Errorf("vsockSocket() with regular file = %q, want empty", got)
+	}
+	os.Remove(path)
+
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	defer ln.Close()
+	if got := vsockSocket(baseDir, "dev"); got != path {
+		t.Errorf("vsockSocket() = %q, want %q", got, path)
+	}
+}
+
+func TestResolveVMTargetNotRunning(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	_, err := resolveVMTarget("")
+	var notRunning *vm.ErrVMNotRunning
+	if !errors.As(err, &notRunning) {
+		t.Fatalf("resolveVMTarget() error = %v, want ErrVMNotRunning", err)
+	}
+	if notRunning.Name != "default" {
+		t.Errorf("ErrVMNotRunning.Name = %q, want %q", notRunning.Name, "default")
+	}
+}
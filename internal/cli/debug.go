@@ -0,0 +1,35 @@
+package cli
+
+import (
+	"os"
+
+	"github.com/javanstorm/vmterminal/internal/vm"
+	"github.com/spf13/cobra"
+)
+
+var debugCmd = &cobra.Command{
+	Use:   "debug",
+	Short: "Debugging aids for contributors and bug reports",
+}
+
+var debugStateDiagramCmd = &cobra.Command{
+	Use:   "state-diagram",
+	Short: "Print the VM state machine as a Graphviz graph",
+	Long: `Print the valid transitions between VM lifecycle states in Graphviz DOT
+format. Solid edges check and set the state while holding the manager lock;
+dashed edges release it around a hypervisor call, so other callers may see
+the intermediate state.
+
+Examples:
+  vmterminal debug state-diagram | dot -Tsvg -o states.svg`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return vm.WriteDOT(os.Stdout)
+	},
+}
+
+func init() {
+	debugCmd.AddCommand(debugStateDiagramCmd)
+	rootCmd.AddCommand(debugCmd)
+	noJSON(debugStateDiagramCmd)
+}
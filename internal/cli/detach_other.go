@@ -0,0 +1,8 @@
+//go:build !darwin && !linux
+
+package cli
+
+import "os/exec"
+
+// detachProcess does nothing on this platform.
+func detachProcess(cmd *exec.Cmd) {}
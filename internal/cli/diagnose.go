@@ -0,0 +1,194 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/javanstorm/vmterminal/internal/config"
+	"github.com/javanstorm/vmterminal/internal/distro"
+	"github.com/javanstorm/vmterminal/internal/vm"
+	"github.com/javanstorm/vmterminal/pkg/hypervisor"
+	"github.com/spf13/cobra"
+)
+
+var diagnoseCmd = &cobra.Command{
+	Use:   "diagnose",
+	Short: "Find out why a VM fails to start",
+	Long: `Collect what matters when a VM fails to boot and match it against
+known causes.
+
+Looks at the cached kernel and initramfs, the VM disk, the end of the
+console log (if the VM was run with --console-log), the host kernel log and
+hypervisor access, then prints what it found and advice for each known
+problem. Exits with status 1 if a problem was found.
+
+Examples:
+  vmterminal diagnose
+  vmterminal diagnose --vm dev
+  vmterminal diagnose --json`,
+	Args: cobra.NoArgs,
+	RunE: runDiagnose,
+}
+
+var diagnoseVM string
+
+// diagnoseLogLines is how many lines of the boot log and dmesg are shown.
+const diagnoseLogLines = 20
+
+func init() {
+	diagnoseCmd.Flags().StringVar(&diagnoseVM, "vm", "", "VM to diagnose (default: active VM)")
+}
+
+// diagnoseResult is the structured output of diagnose.
+type diagnoseResult struct {
+	*vm.DiagInfo
+	Advice []string `json:"advice"`
+}
+
+// RenderHuman prints the collected information followed by the advice.
+func (r *diagnoseResult) RenderHuman(w io.Writer) {
+	fmt.Fprintf(w, "VM:     %s (%s)\n", r.VM, r.Distro)
+	for _, f := range []struct {
+		label string
+		file  vm.DiagFile
+		used  bool
+	}{{"Kernel", r.Kernel, !r.UEFI}, {"Initrd", r.Initrd, !r.UEFI && r.HasInitrd}, {"Disk", r.Disk, true}} {
+		if !f.used {
+			fmt.Fprintf(w, "%-7s (not used)\n", f.label+":")
+		} else if f.file.Exists {
+			fmt.Fprintf(w, "%-7s %s (%s)\n", f.label+":", f.file.Path, formatSize(f.file.Size))
+		} else {
+			fmt.Fprintf(w, "%-7s %s (missing)\n", f.label+":", f.file.Path)
+		}
+	}
+
+	fmt.Fprintln(w, "\nHost:")
+	for _, c := range r.Host {
+		status := "ok"
+		if !c.OK {
+			status = "FAILED"
+		}
+		fmt.Fprintf(w, "  %s: %s\n", c.Name, status)
+	}
+	if r.BootLog != "" {
+		fmt.Fprintf(w, "\nBoot log (last %d lines):\n%s\n", diagnoseLogLines, indent(r.BootLog))
+	}
+	if r.Dmesg != "" {
+		fmt.Fprintf(w, "\ndmesg (last %d lines):\n%s\n", diagnoseLogLines, indent(r.Dmesg))
+	}
+
+	if len(r.Advice) == 0 {
+		fmt.Fprintln(w, "\nNo known problem found.")
+		return
+	}
+	fmt.Fprintln(w, "\nProblems found:")
+	for _, a := range r.Advice {
+		fmt.Fprintf(w, "  - %s\n", a)
+	}
+}
+
+// indent indents every line of s by two spaces.
+func indent(s string) string {
+	lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+	return "  " + strings.Join(lines, "\n  ")
+}
+
+func runDiagnose(cmd *cobra.Command, args []string) error {
+	cfg, err := config.LoadState()
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+	baseDir, err := baseDirectory()
+	if err != nil {
+		return err
+	}
+	vmName, entry, err := resolveVM(baseDir, diagnoseVM)
+	if err != nil {
+		return err
+	}
+	effective := cfg
+	if entry != nil {
+		effective = entry.EffectiveState(cfg)
+	}
+	distroID := distro.ID(effective.Distro)
+	if distroID == "" {
+		distroID = distro.DefaultID()
+	}
+	provider, err := distro.Get(distroID)
+	if err != nil {
+		return fmt.Errorf("get distro: %w", err)
+	}
+
+	info := collectDiagInfo(baseDir, vmName, provider)
+	res := &diagnoseResult{DiagInfo: info, Advice: []string{}}
+	for _, rule := range vm.Diagnose(info) {
+		res.Advice = append(res.Advice, rule.Advice)
+	}
+	if err := printResult(res); err != nil {
+		return err
+	}
+	if len(res.Advice) > 0 {
+		return &ExitCodeError{Code: 1}
+	}
+	return nil
+}
+
+// hasInitrd reports whether provider boots with an initramfs, either
+// downloaded or extracted from its image.
+func hasInitrd(provider distro.Provider) bool {
+	if locator := provider.KernelLocator(); locator != nil {
+		return len(locator.InitrdPatterns) > 0
+	}
+	urls, err := provider.AssetURLs(distro.CurrentArch())
+	return err == nil && urls.Initrd != ""
+}
+
+// collectDiagInfo gathers the boot files, logs and host checks of a VM.
+func collectDiagInfo(baseDir, vmName string, provider distro.Provider) *vm.DiagInfo {
+	cacheSubdir := filepath.Join(baseDir, "cache", provider.CacheSubdir(distro.CurrentArch()))
+	dataDir := filepath.Join(baseDir, "data", vmName)
+
+	// Cloud-image distros copy the converted image from the cache on
+	// their first boot
+	diskPath := vm.NewRootfsManager(dataDir, nil).DiskPath("disk")
+	reqs := provider.SetupRequirements()
+	boot := provider.BootConfig(distro.CurrentArch())
+	if (reqs != nil && !reqs.NeedsExtraction) || (boot != nil && boot.UEFI) {
+		if _, err := os.Stat(diskPath); err != nil {
+			diskPath = filepath.Join(cacheSubdir, "rootfs.raw")
+		}
+	}
+
+	info := &vm.DiagInfo{
+		VM:        vmName,
+		Distro:    string(provider.ID()),
+		Kernel:    vm.StatDiagFile(filepath.Join(cacheSubdir, "vmlinuz")),
+		Initrd:    vm.StatDiagFile(filepath.Join(cacheSubdir, "initramfs")),
+		Disk:      vm.StatDiagFile(diskPath),
+		UEFI:      boot != nil && boot.UEFI,
+		HasInitrd: hasInitrd(provider),
+		Host:      hypervisor.CheckHost(),
+	}
+
+	if path, _, err := consoleLogPath(baseDir, vmName); err == nil {
+		var buf bytes.Buffer
+		if vm.TailFile(path, diagnoseLogLines, false, &buf) == nil {
+			info.BootLog = buf.String()
+		}
+	}
+
+	// dmesg is often restricted to root; without it there is no kernel log
+	if out, err := exec.Command("dmesg").Output(); err == nil {
+		lines := strings.Split(strings.TrimRight(string(out), "\n"), "\n")
+		if len(lines) > diagnoseLogLines {
+			lines = lines[len(lines)-diagnoseLogLines:]
+		}
+		info.Dmesg = strings.Join(lines, "\n")
+	}
+	return info
+}
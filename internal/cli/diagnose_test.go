@@ -0,0 +1,64 @@
+package cli
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/javanstorm/vmterminal/internal/vm"
+	"github.com/javanstorm/vmterminal/pkg/hypervisor"
+)
+
+func TestDiagnoseRenderHuman(t *testing.T) {
+	res := &diagnoseResult{
+		DiagInfo: &vm.DiagInfo{
+			VM:        "dev",
+			Distro:    "alpine",
+			Kernel:    vm.DiagFile{Path: "/cache/vmlinuz", Exists: true, Size: 512},
+			Initrd:    vm.DiagFile{Path: "/cache/initramfs"},
+			Disk:      vm.DiagFile{Path: "/data/dev/disk.raw", Exists: true, Size: 10 << 30},
+			HasInitrd: true,
+			Host:      []hypervisor.HostCheck{{Name: "/dev/kvm present"}},
+			BootLog:   "line one\nKernel panic\n",
+		},
+		Advice: []string{"The cached kernel is too small to be valid"},
+	}
+
+	var buf strings.Builder
+	res.RenderHuman(&buf)
+	out := buf.String()
+
+	for _, want := range []string{
+		"VM:     dev (alpine)",
+		"Kernel: /cache/vmlinuz (512 B)",
+		"Initrd: /cache/initramfs (missing)",
+		"Disk:   /data/dev/disk.raw (10.0 GB)",
+		"/dev/kvm present: FAILED",
+		"  line one\n  Kernel panic\n",
+		"Problems found:\n  - The cached kernel is too small to be valid",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestDiagnoseRenderHumanUEFI(t *testing.T) {
+	res := &diagnoseResult{
+		DiagInfo: &vm.DiagInfo{
+			VM:     "dev",
+			Distro: "opensuse",
+			Disk:   vm.DiagFile{Path: "/data/dev/disk.raw", Exists: true, Size: 10 << 30},
+			UEFI:   true,
+		},
+	}
+
+	var buf strings.Builder
+	res.RenderHuman(&buf)
+	out := buf.String()
+
+	for _, want := range []string{"Kernel: (not used)", "Initrd: (not used)"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/javanstorm/vmterminal/internal/vm"
+	"github.com/spf13/cobra"
+)
+
+var diskCmd = &cobra.Command{
+	Use:   "disk",
+	Short: "Manage data disks",
+	Long: `Attach data disks to a VM to keep data apart from its root disk.
+
+Data disks are attached after the root disk (and after the overlay disk
+with --overlay), in the order they were added, so the first one is
+usually /dev/vdb. They are kept when the VM switches distro.`,
+}
+
+var diskAddCmd = &cobra.Command{
+	Use:   "add",
+	Short: "Add a data disk",
+	Long: `Create a sparse data disk image and attach it to the VM from its next
+boot. The size is in MB. The disk is blank; format it inside the VM, e.g.
+with mkfs.ext4 /dev/vdb.
+
+Examples:
+  vmterminal disk add --name data --size 20480
+  vmterminal disk add --name media --size 102400 --vm dev --read-only`,
+	Args: cobra.NoArgs,
+	RunE: runDiskAdd,
+}
+
+var diskListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List data disks",
+	Long:  `List the data disks attached to a VM, in the order they are attached.`,
+	Args:  cobra.NoArgs,
+	RunE:  runDiskList,
+}
+
+var (
+	diskVM       string
+	diskName     string
+	diskSizeMB   int64
+	diskReadOnly bool
+)
+
+func init() {
+	diskCmd.PersistentFlags().StringVar(&diskVM, "vm", "", "VM to manage disks of (default: active VM)")
+	diskAddCmd.Flags().StringVar(&diskName, "name", "", "disk name, used as the image file name")
+	diskAddCmd.Flags().Int64Var(&diskSizeMB, "size", 0, "disk size in MB")
+	diskAddCmd.Flags().BoolVar(&diskReadOnly, "read-only", false, "attach the disk read-only")
+	diskAddCmd.MarkFlagRequired("name")
+	diskAddCmd.MarkFlagRequired("size")
+
+	diskCmd.AddCommand(diskAddCmd)
+	diskCmd.AddCommand(diskListCmd)
+}
+
+// diskImages returns the image manager of a VM's data directory, which
+// holds its data disk images.
+func diskImages(baseDir, vmName string) *vm.ImageManager {
+	return vm.NewImageManager(filepath.Join(baseDir, "data", vmName))
+}
+
+func runDiskAdd(cmd *cobra.Command, args []string) error {
+	baseDir, err := baseDirectory()
+	if err != nil {
+		return err
+	}
+	vmName, entry, err := resolveVM(baseDir, diskVM)
+	if err != nil {
+		return err
+	}
+	if entry == nil {
+		return fmt.Errorf("VM '%s' is not registered; create it with 'vmterminal vm create' to add disks", vmName)
+	}
+
+	disk := vm.DiskConfig{Name: diskName, SizeMB: diskSizeMB, ReadOnly: diskReadOnly}
+	if err := disk.Validate(); err != nil {
+		return err
+	}
+
+	reg := vm.NewRegistry(baseDir)
+	err = reg.UpdateVM(vmName, func(e *vm.VMEntry) error {
+		for _, d := range e.Disks {
+			if d.Name == disk.Name {
+				return fmt.Errorf("VM '%s' already has a disk named %s", vmName, disk.Name)
+			}
+		}
+		e.Disks = append(e.Disks, disk)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Create the image now so that a full host disk shows up here rather
+	// than at boot
+	path, err := diskImages(baseDir, vmName).EnsureDisk(disk.Name, disk.SizeMB)
+	if err != nil {
+		return fmt.Errorf("create disk image: %w", err)
+	}
+
+	progressf("Added disk %s (%s) to VM '%s': %s\n", disk.Name, formatSize(disk.SizeMB*1024*1024), vmName, path)
+	progressf("It will be attached on the next boot.\n")
+	return nil
+}
+
+// diskListEntry is one data disk of a VM.
+type diskListEntry struct {
+	Name     string `json:"name"`
+	SizeMB   int64  `json:"size_mb"`
+	ReadOnly bool   `json:"read_only"`
+	Path     string `json:"path"`
+}
+
+// diskListResult lists a VM's data disks.
+type diskListResult struct {
+	VM    string          `json:"vm"`
+	Disks []diskListEntry `json:"disks"`
+}
+
+// RenderHuman prints a table of data disks.
+func (r *diskListResult) RenderHuman(w io.Writer) {
+	if len(r.Disks) == 0 {
+		fmt.Fprintf(w, "VM '%s' has no data disks.\n", r.VM)
+		return
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tSIZE\tMODE\tPATH")
+	for _, d := range r.Disks {
+		mode := "rw"
+		if d.ReadOnly {
+			mode = "ro"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", d.Name, formatSize(d.SizeMB*1024*1024), mode, d.Path)
+	}
+	tw.Flush()
+}
+
+func runDiskList(cmd *cobra.Command, args []string) error {
+	baseDir, err := baseDirectory()
+	if err != nil {
+		return err
+	}
+	vmName, entry, err := resolveVM(baseDir, diskVM)
+	if err != nil {
+		return err
+	}
+
+	images := diskImages(baseDir, vmName)
+	res := &diskListResult{VM: vmName, Disks: []diskListEntry{}}
+	for _, d := range vmDisks(entry) {
+		res.Disks = append(res.Disks, diskListEntry{
+			Name:     d.Name,
+			SizeMB:   d.SizeMB,
+			ReadOnly: d.ReadOnly,
+			Path:     images.DiskPath(d.Name),
+		})
+	}
+	return printResult(res)
+}
@@ -0,0 +1,31 @@
+package cli
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestDiskListResultRenderHuman(t *testing.T) {
+	var buf bytes.Buffer
+	(&diskListResult{VM: "dev", Disks: []diskListEntry{}}).RenderHuman(&buf)
+	if got := buf.String(); got != "VM 'dev' has no data disks.\n" {
+		t.Errorf("empty list = %q", got)
+	}
+
+	buf.Reset()
+	res := &diskListResult{VM: "dev", Disks: []diskListEntry{
+		{Name: "data", SizeMB: 20480, Path: "/data/dev/data.raw"},
+		{Name: "media", SizeMB: 512, ReadOnly: true, Path: "/data/dev/media.raw"},
+	}}
+	res.RenderHuman(&buf)
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], "NAME") {
+		t.Fatalf("output = %q, want a header and two rows", buf.String())
+	}
+	for i, want := range []string{"data 20.0 GB rw /data/dev/data.raw", "media 512.0 MB ro /data/dev/media.raw"} {
+		if got := strings.Join(strings.Fields(lines[i+1]), " "); got != want {
+			t.Errorf("row %d = %q, want %q", i, got, want)
+		}
+	}
+}
@@ -0,0 +1,436 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/javanstorm/vmterminal/internal/config"
+	"github.com/javanstorm/vmterminal/internal/distro"
+	"github.com/spf13/cobra"
+)
+
+var (
+	distroAddFile     string
+	distroManifestURL string
+)
+
+// manifestTimeout bounds the version manifest download.
+const manifestTimeout = 30 * time.Second
+
+var distroCmd = &cobra.Command{
+	Use:   "distro",
+	Short: "Manage custom distribution definitions",
+	Long: `Manage distributions defined in YAML or JSON files.
+
+Custom definitions live in ~/.vmterminal/distros and are loaded on every
+start, so internal or modified distributions can be used without
+recompiling. See docs/custom-distros.md for the file format.`,
+}
+
+var distroAddCmd = &cobra.Command{
+	Use:   "add --file <spec.yaml>",
+	Short: "Validate and install a custom distro definition",
+	Args:  cobra.NoArgs,
+	RunE:  runDistroAdd,
+}
+
+var distroUpdateCmd = &cobra.Command{
+	Use:   "update [distro]",
+	Short: "Update built-in distros to their latest versions",
+	Long: `Fetch the distro version manifest and switch to newer releases.
+
+For each distro with a newer version, the cached assets are cleared and the
+version is recorded in ~/.vmterminal/distro_versions.json, which overrides
+the compiled-in version on every start. Distros pinned with 'vmterminal
+distro pin' are left alone. Existing VM disks keep the release
+they were installed from; use 'vmterminal cache clear <distro> --disk' to
+reinstall.
+
+Examples:
+  vmterminal distro update
+  vmterminal distro update alpine
+  vmterminal distro update --manifest-url https://example.com/versions.json`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runDistroUpdate,
+}
+
+var distroPinCmd = &cobra.Command{
+	Use:   "pin <distro> <version>",
+	Short: "Use a specific release of a built-in distro",
+	Long: `Record a release of a built-in distro in
+~/.vmterminal/distro_versions.json, which overrides the compiled-in version
+on every start. Without a pin a distro uses the latest release vmterminal
+knows of. 'vmterminal distro update' leaves pinned distros alone until
+'vmterminal distro unpin' releases them.
+
+Alpine also accepts 'edge', which pins the current snapshot of the edge
+branch (e.g. edge-20250108); pin edge again to move to a newer snapshot.
+
+Each release is cached separately. Existing VM disks keep the release they
+were installed from; use 'vmterminal cache clear <distro> --disk' to
+reinstall.
+
+Examples:
+  vmterminal distro pin alpine 3.18
+  vmterminal distro pin alpine 3.19.1
+  vmterminal distro pin alpine edge
+  vmterminal distro pin rocky 9.2`,
+	Args: cobra.ExactArgs(2),
+	RunE: runDistroPin,
+}
+
+var distroUnpinCmd = &cobra.Command{
+	Use:   "unpin <distro>",
+	Short: "Go back to the default release of a pinned distro",
+	Long: `Remove a pin made with 'vmterminal distro pin', so the distro uses the
+compiled-in version again and 'vmterminal distro update' manages it.
+
+Examples:
+  vmterminal distro unpin alpine`,
+	Args: cobra.ExactArgs(1),
+	RunE: runDistroUnpin,
+}
+
+func init() {
+	distro.SetLoader(loadDistros)
+
+	distroAddCmd.Flags().StringVarP(&distroAddFile, "file", "f", "", "YAML or JSON distro definition")
+	distroAddCmd.MarkFlagRequired("file")
+
+	distroUpdateCmd.Flags().StringVar(&distroManifestURL, "manifest-url", distro.DefaultManifestURL, "URL of the distro version manifest")
+
+	distroCmd.AddCommand(distroAddCmd)
+	distroCmd.AddCommand(distroUpdateCmd)
+	distroCmd.AddCommand(distroPinCmd)
+	distroCmd.AddCommand(distroUnpinCmd)
+}
+
+// distroAddResult is the structured output of the distro add command.
+type distroAddResult struct {
+	ID       string   `json:"id"`
+	Name     string   `json:"name"`
+	Version  string   `json:"version"`
+	Archs    []string `json:"archs"`
+	Path     string   `json:"path"`
+	Replaced bool     `json:"replaced"`
+}
+
+// RenderHuman prints the distro add result as text.
+func (r distroAddResult) RenderHuman(w io.Writer) {
+	verb := "Added"
+	if r.Replaced {
+		verb = "Updated"
+	}
+	fmt.Fprintf(w, "%s distro '%s' (%s %s, %s).\n", verb, r.ID, r.Name, r.Version, strings.Join(r.Archs, ", "))
+	fmt.Fprintf(w, "Definition saved to %s\n", r.Path)
+	fmt.Fprintf(w, "Use it with: vmterminal run --distro %s\n", r.ID)
+}
+
+// loadDistros registers what the user has changed about the built-in
+// distros. It is the distro registry's loader, so it only runs for commands
+// that look up a distro.
+func loadDistros() {
+	loadCustomDistros()
+	loadDistroVersions()
+	loadVersionOverrides()
+}
+
+// loadCustomDistros registers the user's custom distros. Problems are
+// reported as warnings so one bad file does not block every command.
+func loadCustomDistros() {
+	paths, err := config.GetPaths()
+	if err != nil {
+		return
+	}
+	if err := distro.LoadCustomDistros(paths.DistrosDir); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: custom distros: %v\n", err)
+	}
+}
+
+// loadDistroVersions applies versions recorded by 'distro update'.
+func loadDistroVersions() {
+	paths, err := config.GetPaths()
+	if err != nil {
+		return
+	}
+	if err := distro.ApplyVersions(filepath.Join(paths.DataDir, distro.VersionsFile)); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: distro versions: %v\n", err)
+	}
+}
+
+// loadVersionOverrides pins distros listed under version_override in the
+// config. Pins are applied after 'distro update' versions so they win.
+func loadVersionOverrides() {
+	cfg, err := config.LoadState()
+	if err != nil {
+		return // Reported by the command that loads the config
+	}
+	for id, version := range cfg.VersionOverride {
+		if err := distro.PinVersion(id, version); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: version_override: %v\n", err)
+		}
+	}
+}
+
+func runDistroAdd(cmd *cobra.Command, args []string) error {
+	data, err := os.ReadFile(distroAddFile)
+	if err != nil {
+		return fmt.Errorf("read distro file: %w", err)
+	}
+	if !distro.IsCustomDistroFile(distroAddFile) {
+		return fmt.Errorf("distro file must end in .yaml, .yml, or .json")
+	}
+
+	spec, err := distro.ParseCustomDistroSpec(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", distroAddFile, err)
+	}
+
+	replaced := distro.IsCustom(distro.ID(spec.ID))
+	if err := distro.RegisterCustom(distro.NewCustomProvider(*spec)); err != nil {
+		return err
+	}
+
+	paths, err := config.GetPaths()
+	if err != nil {
+		return fmt.Errorf("get paths: %w", err)
+	}
+	if err := os.MkdirAll(paths.DistrosDir, 0755); err != nil {
+		return fmt.Errorf("create distros dir: %w", err)
+	}
+
+	// One file per distro ID, so re-adding replaces the old definition
+	dest := filepath.Join(paths.DistrosDir, spec.ID+strings.ToLower(filepath.Ext(distroAddFile)))
+	for _, ext := range []string{".yaml", ".yml", ".json"} {
+		if old := filepath.Join(paths.DistrosDir, spec.ID+ext); old != dest {
+			os.Remove(old)
+		}
+	}
+	if err := os.WriteFile(dest, data, 0644); err != nil {
+		return fmt.Errorf("save distro file: %w", err)
+	}
+
+	archs := make([]string, len(spec.Archs))
+	for i, a := range spec.Archs {
+		archs[i] = string(a)
+	}
+	return printResult(distroAddResult{
+		ID:       spec.ID,
+		Name:     spec.Name,
+		Version:  spec.Version,
+		Archs:    archs,
+		Path:     dest,
+		Replaced: replaced,
+	})
+}
+
+// distroUpdateEntry is the outcome for one distro.
+type distroUpdateEntry struct {
+	ID           string `json:"id"`
+	Current      string `json:"current"`
+	Latest       string `json:"latest"`
+	Updated      bool   `json:"updated"`
+	CacheCleared bool   `json:"cache_cleared"`
+	Pinned       bool   `json:"pinned,omitempty"`
+	Error        string `json:"error,omitempty"`
+}
+
+// distroUpdateResult is the structured output of the distro update command.
+type distroUpdateResult struct {
+	Manifest string              `json:"manifest"`
+	Distros  []distroUpdateEntry `json:"distros"`
+}
+
+// RenderHuman prints the distro update result as text.
+func (r *distroUpdateResult) RenderHuman(w io.Writer) {
+	if len(r.Distros) == 0 {
+		fmt.Fprintln(w, "No matching distros in the manifest.")
+		return
+	}
+	for _, d := range r.Distros {
+		switch {
+		case d.Error != "":
+			fmt.Fprintf(w, "  %s: %s available, not applied: %s\n", d.ID, d.Latest, d.Error)
+		case d.Pinned:
+			fmt.Fprintf(w, "  %s: pinned to %s (%s available; 'vmterminal distro unpin %s' to follow updates)\n", d.ID, d.Current, d.Latest, d.ID)
+		case d.Updated:
+			fmt.Fprintf(w, "  %s: %s -> %s", d.ID, d.Current, d.Latest)
+			if d.CacheCleared {
+				fmt.Fprint(w, " (cache cleared)")
+			}
+			fmt.Fprintln(w)
+		default:
+			fmt.Fprintf(w, "  %s: %s (up to date)\n", d.ID, d.Current)
+		}
+	}
+}
+
+func runDistroUpdate(cmd *cobra.Command, args []string) error {
+	var only distro.ID
+	if len(args) > 0 {
+		id, err := distro.ParseID(args[0])
+		if err != nil {
+			return err
+		}
+		only = id
+	}
+
+	paths, err := config.GetPaths()
+	if err != nil {
+		return fmt.Errorf("get paths: %w", err)
+	}
+	versionsPath := filepath.Join(paths.DataDir, distro.VersionsFile)
+	cacheDir := filepath.Join(paths.DataDir, "cache")
+
+	ctx, cancel := context.WithTimeout(cmd.Context(), manifestTimeout)
+	defer cancel()
+	progressf("Fetching %s...\n", distroManifestURL)
+	manifest, err := distro.FetchManifest(ctx, distroManifestURL)
+	if err != nil {
+		return err
+	}
+
+	local, err := distro.LoadVersions(versionsPath)
+	if err != nil {
+		return err
+	}
+
+	res := &distroUpdateResult{Manifest: distroManifestURL, Distros: []distroUpdateEntry{}}
+	changed := false
+	for _, id := range manifest.IDs() {
+		if (only != "" && id != only) || !distro.IsRegistered(id) || distro.IsCustom(id) {
+			continue
+		}
+		p, err := distro.Get(id)
+		if err != nil {
+			continue
+		}
+
+		entry := distroUpdateEntry{ID: string(id), Current: p.Version(), Latest: manifest.Versions[id]}
+		if local.IsPinned(id) {
+			entry.Pinned = true
+		} else if distro.CompareVersions(entry.Latest, entry.Current) > 0 {
+			if err := distro.SetVersion(id, entry.Latest); err != nil {
+				entry.Error = err.Error()
+			} else {
+				entry.Updated = true
+				entry.CacheCleared, err = clearCache(cacheDir, string(id))
+				if err != nil {
+					return err
+				}
+				local.Versions[id] = entry.Latest
+				changed = true
+			}
+		}
+		res.Distros = append(res.Distros, entry)
+	}
+
+	if changed {
+		local.UpdatedAt = time.Now().UTC()
+		if err := local.Save(versionsPath); err != nil {
+			return err
+		}
+	}
+	return printResult(res)
+}
+
+// distroPinResult is the structured output of the distro pin command.
+type distroPinResult struct {
+	ID       string `json:"id"`
+	Previous string `json:"previous"`
+	Version  string `json:"version"`
+	Path     string `json:"path"`
+}
+
+// RenderHuman prints the distro pin result as text.
+func (r *distroPinResult) RenderHuman(w io.Writer) {
+	fmt.Fprintf(w, "Pinned %s to %s (was %s).\n", r.ID, r.Version, r.Previous)
+	fmt.Fprintf(w, "Recorded in %s\n", r.Path)
+}
+
+func runDistroPin(cmd *cobra.Command, args []string) error {
+	id, err := distro.ParseID(args[0])
+	if err != nil {
+		return err
+	}
+	p, err := distro.Get(id)
+	if err != nil {
+		return err
+	}
+	res := &distroPinResult{ID: string(id), Previous: p.Version(), Version: args[1]}
+
+	// Edge moves daily; pin the snapshot that is current now
+	if id == distro.Alpine && res.Version == "edge" {
+		ctx, cancel := context.WithTimeout(cmd.Context(), manifestTimeout)
+		defer cancel()
+		progressf("Looking up the current Alpine edge snapshot...\n")
+		if res.Version, err = distro.ResolveAlpineEdge(ctx, distro.CurrentArch()); err != nil {
+			return err
+		}
+	}
+	if err := distro.PinVersion(id, res.Version); err != nil {
+		return err
+	}
+
+	paths, err := config.GetPaths()
+	if err != nil {
+		return fmt.Errorf("get paths: %w", err)
+	}
+	res.Path = filepath.Join(paths.DataDir, distro.VersionsFile)
+	local, err := distro.LoadVersions(res.Path)
+	if err != nil {
+		return err
+	}
+	local.Pin(id, res.Version)
+	local.UpdatedAt = time.Now().UTC()
+	if err := local.Save(res.Path); err != nil {
+		return err
+	}
+	return printResult(res)
+}
+
+// distroUnpinResult is the structured output of the distro unpin command.
+type distroUnpinResult struct {
+	ID      string `json:"id"`
+	Removed string `json:"removed,omitempty"`
+	Path    string `json:"path"`
+}
+
+// RenderHuman prints the distro unpin result as text.
+func (r *distroUnpinResult) RenderHuman(w io.Writer) {
+	if r.Removed == "" {
+		fmt.Fprintf(w, "%s was not pinned.\n", r.ID)
+		return
+	}
+	fmt.Fprintf(w, "Unpinned %s from %s; it uses the default release from the next start.\n", r.ID, r.Removed)
+}
+
+func runDistroUnpin(cmd *cobra.Command, args []string) error {
+	id, err := distro.ParseID(args[0])
+	if err != nil {
+		return err
+	}
+	paths, err := config.GetPaths()
+	if err != nil {
+		return fmt.Errorf("get paths: %w", err)
+	}
+	res := &distroUnpinResult{ID: string(id), Path: filepath.Join(paths.DataDir, distro.VersionsFile)}
+	local, err := distro.LoadVersions(res.Path)
+	if err != nil {
+		return err
+	}
+	if local.IsPinned(id) {
+		res.Removed = local.Versions[id]
+		local.Unpin(id)
+		local.UpdatedAt = time.Now().UTC()
+		if err := local.Save(res.Path); err != nil {
+			return err
+		}
+	}
+	return printResult(res)
+}
@@ -0,0 +1,154 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/javanstorm/vmterminal/internal/vm"
+	"github.com/javanstorm/vmterminal/pkg/hypervisor"
+	"github.com/spf13/cobra"
+)
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check that this host has everything vmterminal needs",
+	Long: `Check host tools and hypervisor access before they fail mid-operation.
+
+Lists every external tool vmterminal may call and whether it is installed,
+with the command that installs anything missing. Also checks /dev/kvm and
+kvm group membership on Linux, and the Virtualization.framework
+entitlement on macOS. Exits with status 1 if a required check fails, so it
+can gate CI setup scripts.
+
+Examples:
+  vmterminal doctor
+  vmterminal doctor --json`,
+	Args: cobra.NoArgs,
+	RunE: runDoctor,
+}
+
+// doctorDependency is the status of one external tool.
+type doctorDependency struct {
+	Name           string `json:"name"`
+	Description    string `json:"description"`
+	Installed      bool   `json:"installed"`
+	Required       bool   `json:"required"`
+	InstallCommand string `json:"install_command,omitempty"`
+}
+
+// doctorResult is the structured output of the doctor command.
+type doctorResult struct {
+	HostOS       string                 `json:"host_os"`
+	Host         []hypervisor.HostCheck `json:"host"`
+	Dependencies []doctorDependency     `json:"dependencies"`
+	OK           bool                   `json:"ok"`
+
+	color bool
+}
+
+// RenderHuman prints a checklist: a check mark for passing checks, a cross
+// for failed required ones and an exclamation mark for optional ones.
+func (r *doctorResult) RenderHuman(w io.Writer) {
+	fmt.Fprintf(w, "Host (%s):\n", r.HostOS)
+	for _, c := range r.Host {
+		r.printItem(w, c.Name, c.OK, c.Required, c.Detail)
+	}
+
+	fmt.Fprintln(w, "\nTools:")
+	for _, d := range r.Dependencies {
+		detail := ""
+		if !d.Installed {
+			detail = "install: " + d.InstallCommand
+			if d.InstallCommand == "" {
+				detail = "no package known for " + r.HostOS + "; install it manually"
+			}
+		}
+		r.printItem(w, fmt.Sprintf("%-10s %s", d.Name, d.Description), d.Installed, d.Required, detail)
+	}
+
+	fmt.Fprintln(w)
+	if r.OK {
+		fmt.Fprintln(w, "All required checks passed.")
+	} else {
+		fmt.Fprintln(w, "Some required checks failed.")
+	}
+}
+
+// ANSI colors for the checklist.
+const (
+	colorRed    = "\033[31m"
+	colorGreen  = "\033[32m"
+	colorYellow = "\033[33m"
+	colorReset  = "\033[0m"
+)
+
+func (r *doctorResult) printItem(w io.Writer, label string, ok, required bool, detail string) {
+	mark, color := "✓", colorGreen
+	switch {
+	case ok:
+	case required:
+		mark, color = "✗", colorRed
+	default:
+		mark, color = "!", colorYellow
+	}
+	if r.color {
+		mark = color + mark + colorReset
+	}
+	fmt.Fprintf(w, "  %s %s\n", mark, label)
+	if !ok && detail != "" {
+		fmt.Fprintf(w, "      %s\n", detail)
+	}
+}
+
+func runDoctor(cmd *cobra.Command, args []string) error {
+	dm := vm.NewDependencyManager()
+	res := &doctorResult{
+		HostOS: dm.HostOS(),
+		Host:   hypervisor.CheckHost(),
+		OK:     true,
+		color:  useColor(os.Stdout),
+	}
+
+	for _, c := range res.Host {
+		if c.Required && !c.OK {
+			res.OK = false
+		}
+	}
+	for _, dep := range vm.AllDependencies() {
+		if !dm.Applicable(dep) {
+			continue
+		}
+		d := doctorDependency{
+			Name:        dep.Name,
+			Description: dep.Description,
+			Installed:   dm.CheckDependency(dep),
+			Required:    dep.Required,
+		}
+		if !d.Installed {
+			d.InstallCommand = dm.InstallCommand(dep)
+			if d.Required {
+				res.OK = false
+			}
+		}
+		res.Dependencies = append(res.Dependencies, d)
+	}
+
+	if err := printResult(res); err != nil {
+		return err
+	}
+	if !res.OK {
+		return &ExitCodeError{Code: 1}
+	}
+	return nil
+}
+
+// useColor reports whether f is a terminal that should get ANSI colors.
+// NO_COLOR disables them, see https://no-color.org.
+func useColor(f *os.File) bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,43 @@
+package cli
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/javanstorm/vmterminal/pkg/hypervisor"
+)
+
+func TestDoctorRenderHuman(t *testing.T) {
+	res := &doctorResult{
+		HostOS: "ubuntu",
+		Host: []hypervisor.HostCheck{
+			{Name: "/dev/kvm present", OK: true, Required: true},
+			{Name: "user in kvm group", Detail: "sudo usermod -aG kvm $USER"},
+		},
+		Dependencies: []doctorDependency{
+			{Name: "mkfs.ext4", Required: true, InstallCommand: "sudo apt-get install -y e2fsprogs"},
+			{Name: "zstd", Installed: true},
+		},
+	}
+
+	var buf strings.Builder
+	res.RenderHuman(&buf)
+	out := buf.String()
+
+	for _, want := range []string{
+		"✓ /dev/kvm present",
+		"! user in kvm group",
+		"sudo usermod -aG kvm $USER",
+		"✗ mkfs.ext4",
+		"install: sudo apt-get install -y e2fsprogs",
+		"✓ zstd",
+		"Some required checks failed.",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "\033[") {
+		t.Error("colors should be off unless enabled")
+	}
+}
@@ -0,0 +1,39 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/javanstorm/vmterminal/internal/distro"
+	"github.com/javanstorm/vmterminal/internal/vm"
+	"github.com/javanstorm/vmterminal/pkg/hypervisor"
+)
+
+// ErrorHint returns advice on what to do about err, or "" if there is none.
+func ErrorHint(err error) string {
+	var (
+		notRunning  *vm.ErrVMNotRunning
+		notFound    *vm.ErrVMNotFound
+		corrupted   *vm.ErrSnapshotCorrupted
+		download    *distro.ErrAssetDownloadFailed
+		unavailable *hypervisor.ErrHypervisorUnavailable
+	)
+	switch {
+	case errors.As(err, &notRunning) && notRunning.Name != "":
+		return "Start the VM with 'vmterminal run --vm " + notRunning.Name + "'."
+	case errors.As(err, &notRunning):
+		return "Start the VM with 'vmterminal run'."
+	case errors.As(err, &notFound):
+		return "List VMs with 'vmterminal vm list', or create one with 'vmterminal vm create " + notFound.Name + "'."
+	case errors.As(err, &corrupted):
+		return fmt.Sprintf("Delete the snapshot with 'vmterminal snapshot delete %s' and take a new one.", corrupted.Name)
+	case errors.As(err, &download) && download.StatusCode == http.StatusNotFound:
+		return "The download has moved; update distro versions with 'vmterminal distro update'."
+	case errors.As(err, &download):
+		return "Check the network connection, or download through a proxy with --proxy."
+	case errors.As(err, &unavailable):
+		return "Run 'vmterminal doctor' to check the host's virtualization support."
+	}
+	return ""
+}
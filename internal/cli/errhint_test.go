@@ -0,0 +1,35 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/javanstorm/vmterminal/internal/distro"
+	"github.com/javanstorm/vmterminal/internal/vm"
+	"github.com/javanstorm/vmterminal/pkg/hypervisor"
+)
+
+func TestErrorHint(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{fmt.Errorf("get console: %w", &vm.ErrVMNotRunning{}), "vmterminal run"},
+		{&vm.ErrVMNotRunning{Name: "dev"}, "vmterminal run --vm dev"},
+		{&vm.ErrVMNotFound{Name: "dev"}, "vmterminal vm create dev"},
+		{&vm.ErrSnapshotCorrupted{Name: "nightly"}, "vmterminal snapshot delete nightly"},
+		{&distro.ErrAssetDownloadFailed{URL: "https://example.com/a", StatusCode: 404}, "vmterminal distro update"},
+		{&distro.ErrAssetDownloadFailed{URL: "https://example.com/a", Err: errors.New("timeout")}, "--proxy"},
+		{&hypervisor.ErrHypervisorUnavailable{Reason: "no /dev/kvm"}, "vmterminal doctor"},
+	}
+	for _, tt := range tests {
+		if got := ErrorHint(tt.err); !strings.Contains(got, tt.want) {
+			t.Errorf("ErrorHint(%v) = %q, want it to mention %q", tt.err, got, tt.want)
+		}
+	}
+	if got := ErrorHint(errors.New("boom")); got != "" {
+		t.Errorf("ErrorHint(untyped) = %q, want none", got)
+	}
+}
@@ -0,0 +1,78 @@
+package cli
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/javanstorm/vmterminal/internal/vm"
+	"github.com/spf13/cobra"
+)
+
+var execVM string
+
+var execCmd = &cobra.Command{
+	Use:   "exec -- <command> [args...]",
+	Short: "Run a single command in the VM",
+	Long: `Run a command in the running VM over SSH and exit with its exit code.
+
+On macOS the connection goes over virtio-vsock when the guest serves SSH
+on vsock port 22, so it works before networking is up and without an SSH
+port forward. Otherwise it uses the forwarded SSH port.
+
+Output is streamed to the host's stdout and stderr, and stdin is passed
+through. A single argument is handed to the remote shell as-is, so it may
+contain pipes and redirections; several arguments are quoted individually.
+
+Examples:
+  vmterminal exec -- uname -a
+  vmterminal exec -- 'cd /root/project && make test'
+  echo hello | vmterminal exec -- cat`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runExec,
+}
+
+func init() {
+	execCmd.Flags().StringVar(&execVM, "vm", "", "VM to run the command in (default: active VM)")
+	noJSON(execCmd)
+}
+
+// execCommandLine builds the remote command line from exec's arguments.
+func execCommandLine(args []string) string {
+	if len(args) == 1 {
+		return args[0]
+	}
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = shellQuote(arg)
+	}
+	return strings.Join(quoted, " ")
+}
+
+func runExec(cmd *cobra.Command, args []string) error {
+	target, err := resolveVMTarget(execVM)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	code, err := vm.ExecOverSSH(ctx, vm.SSHConfig{
+		Port:    target.State.SSHHostPort,
+		Socket:  target.VsockSocket,
+		KeyPath: target.KeyPath,
+		Stdin:   os.Stdin,
+		Stdout:  os.Stdout,
+		Stderr:  os.Stderr,
+	}, execCommandLine(args))
+	if err != nil {
+		return err
+	}
+	if code != 0 {
+		return &ExitCodeError{Code: code}
+	}
+	return nil
+}
@@ -0,0 +1,23 @@
+package cli
+
+import "testing"
+
+func TestExecCommandLine(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"single arg passed verbatim", []string{"cd /root && make test"}, "cd /root && make test"},
+		{"plain args", []string{"uname", "-a"}, "uname -a"},
+		{"args quoted", []string{"echo", "hello world", "it's"}, `echo 'hello world' 'it'\''s'`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := execCommandLine(tt.args); got != tt.want {
+				t.Errorf("execCommandLine(%q) = %q, want %q", tt.args, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,307 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/javanstorm/vmterminal/internal/config"
+	"github.com/javanstorm/vmterminal/internal/vm"
+	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+)
+
+var startGroupCmd = &cobra.Command{
+	Use:   "start-group <vm>...",
+	Short: "Start several VMs at once",
+	Long: `Start all listed VMs in parallel, each headless in the background, and
+wait until every one shows its login prompt or --timeout passes.
+
+Each VM writes its console to ~/.vmterminal/data/<vm>/console.log (the
+previous boot's log is kept as console.log.1), which 'vmterminal logs --vm
+<vm>' shows. VMs that are already running are left alone. Prints a status
+table and exits with status 1 if any VM failed to boot in time; VMs that
+timed out are left running.
+
+Examples:
+  vmterminal start-group api db cache
+  vmterminal start-group api db --timeout 10m`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runStartGroup,
+}
+
+var stopGroupCmd = &cobra.Command{
+	Use:   "stop-group <vm>...",
+	Short: "Stop several VMs at once",
+	Long: `Stop all listed VMs in parallel, as 'vmterminal stop' does, and wait for
+them to exit. A VM that has not shut down after --timeout (plus the same
+10s grace period as stop) is killed.
+Prints a status table.
+
+Examples:
+  vmterminal stop-group api db cache
+  vmterminal stop-group api db --timeout 1m`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runStopGroup,
+}
+
+// groupConsoleLog is the console log of each VM started by start-group.
+const groupConsoleLog = "console.log"
+
+var (
+	startGroupTimeout time.Duration
+	stopGroupTimeout  time.Duration
+)
+
+func init() {
+	startGroupCmd.Flags().DurationVar(&startGroupTimeout, "timeout", 5*time.Minute, "How long to wait for each VM's login prompt")
+	stopGroupCmd.Flags().DurationVar(&stopGroupTimeout, "timeout", 0, "How long to wait for a graceful shutdown before SIGKILL (default: config stop_timeout_seconds, or 30s)")
+}
+
+// groupVMStatus is the outcome of starting or stopping one VM of a group.
+type groupVMStatus struct {
+	VM     string `json:"vm"`
+	Status string `json:"status"`
+	PID    int    `json:"pid,omitempty"`
+	Detail string `json:"detail,omitempty"`
+	OK     bool   `json:"ok"`
+}
+
+// groupResult is the structured output of start-group and stop-group.
+type groupResult struct {
+	VMs []groupVMStatus `json:"vms"`
+	OK  bool            `json:"ok"`
+}
+
+// RenderHuman prints a table with one row per VM.
+func (r *groupResult) RenderHuman(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "VM\tSTATUS\tPID\tDETAIL")
+	for _, s := range r.VMs {
+		pid := "-"
+		if s.PID > 0 {
+			pid = strconv.Itoa(s.PID)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", s.VM, s.Status, pid, s.Detail)
+	}
+	tw.Flush()
+}
+
+// runGroup runs do for every name in parallel and prints the results in
+// the order the names were given.
+func runGroup(names []string, do func(name string) groupVMStatus) error {
+	seen := make(map[string]bool)
+	for _, name := range names {
+		if seen[name] {
+			return fmt.Errorf("VM '%s' is listed more than once", name)
+		}
+		seen[name] = true
+	}
+
+	res := &groupResult{VMs: make([]groupVMStatus, len(names)), OK: true}
+	var g errgroup.Group
+	for i, name := range names {
+		g.Go(func() error {
+			res.VMs[i] = do(name)
+			return nil
+		})
+	}
+	g.Wait()
+
+	for _, s := range res.VMs {
+		if !s.OK {
+			res.OK = false
+		}
+	}
+	if err := printResult(res); err != nil {
+		return err
+	}
+	if !res.OK {
+		return &ExitCodeError{Code: 1}
+	}
+	return nil
+}
+
+func runStartGroup(cmd *cobra.Command, args []string) error {
+	if startGroupTimeout <= 0 {
+		return fmt.Errorf("--timeout must be positive")
+	}
+	baseDir, err := baseDirectory()
+	if err != nil {
+		return err
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("find vmterminal binary: %w", err)
+	}
+
+	if err := checkGroupConflicts(baseDir, args); err != nil {
+		return err
+	}
+
+	progressf("Starting %d VMs...\n", len(args))
+	return runGroup(args, func(name string) groupVMStatus {
+		return startGroupVM(context.Background(), exe, baseDir, name, startGroupTimeout)
+	})
+}
+
+// checkGroupConflicts rejects a group in which two VMs would forward SSH
+// from the same host port or boot the same disk image: only one of them
+// could bind the port, and both would write to the disk. VMs that cannot
+// be resolved are left for startGroupVM to report.
+func checkGroupConflicts(baseDir string, names []string) error {
+	cfg, err := config.LoadState()
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+
+	ports := make(map[int]string)
+	disks := make(map[string]string)
+	for _, name := range names {
+		_, entry, err := resolveVM(baseDir, name)
+		if err != nil {
+			continue
+		}
+		effective := cfg
+		if entry != nil {
+			effective = entry.EffectiveState(cfg)
+		}
+
+		if port := effective.SSHHostPort; port > 0 {
+			if other, ok := ports[port]; ok {
+				return fmt.Errorf("VMs '%s' and '%s' both forward SSH from host port %d; start them separately or give one its own --ssh-port", other, name, port)
+			}
+			ports[port] = name
+		}
+
+		// Disks are per VM, but may be symlinks to a shared image
+		disk := vm.NewImageManager(filepath.Join(baseDir, "data", name)).DiskPath(vm.RootDiskName)
+		if real, err := filepath.EvalSymlinks(disk); err == nil {
+			if other, ok := disks[real]; ok {
+				return fmt.Errorf("VMs '%s' and '%s' both boot the disk image %s; start them separately", other, name, real)
+			}
+			disks[real] = name
+		}
+	}
+	return nil
+}
+
+// startGroupVM starts the VM called name with 'exe run --headless' in the
+// background and waits for the login prompt in its console log.
+func startGroupVM(ctx context.Context, exe, baseDir, name string, timeout time.Duration) groupVMStatus {
+	st := groupVMStatus{VM: name, Status: "failed"}
+	if _, _, err := resolveVM(baseDir, name); err != nil {
+		st.Detail = err.Error()
+		return st
+	}
+	if running, pid := isVMRunning(baseDir, name); running {
+		st.Status, st.PID, st.OK = "already running", pid, true
+		return st
+	}
+
+	// Start from an empty log so only this boot's login prompt counts
+	dataDir := filepath.Join(baseDir, "data", name)
+	logPath := filepath.Join(dataDir, groupConsoleLog)
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		st.Detail = err.Error()
+		return st
+	}
+	if err := os.Rename(logPath, logPath+".1"); err != nil && !errors.Is(err, os.ErrNotExist) {
+		st.Detail = fmt.Sprintf("keep previous console log: %v", err)
+		return st
+	}
+	if err := os.WriteFile(logPath, nil, 0644); err != nil {
+		st.Detail = fmt.Sprintf("create console log: %v", err)
+		return st
+	}
+
+	// Output goes nowhere: the VM outlives this process, and writing to a
+	// pipe nobody reads would kill it
+	run := exec.Command(exe, "run", "--vm", name, "--headless", "--console-log", logPath)
+	detachProcess(run)
+	if err := run.Start(); err != nil {
+		st.Detail = fmt.Sprintf("start: %v", err)
+		return st
+	}
+	st.PID = run.Process.Pid
+	exited := make(chan struct{})
+	go func() {
+		run.Wait()
+		close(exited)
+	}()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-exited:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	pr, pw := io.Pipe()
+	defer pr.Close()
+	go func() {
+		pw.CloseWithError(vm.TailFileContext(ctx, logPath, 0, time.Time{}, true, pw))
+	}()
+
+	err := vm.WaitForLoginPrompt(ctx, pr, timeout)
+	select {
+	case <-exited:
+		st.Detail = fmt.Sprintf("exited before the login prompt; see 'vmterminal diagnose --vm %s'", name)
+		return st
+	default:
+	}
+	if err != nil {
+		st.Status = "timeout"
+		st.Detail = fmt.Sprintf("%v; still running", err)
+		return st
+	}
+	st.Status, st.OK = "ready", true
+	return st
+}
+
+func runStopGroup(cmd *cobra.Command, args []string) error {
+	baseDir, err := baseDirectory()
+	if err != nil {
+		return err
+	}
+	timeout := stopGroupTimeout
+	if timeout <= 0 {
+		timeout = effectiveStopTimeout()
+	}
+
+	progressf("Stopping %d VMs...\n", len(args))
+	return runGroup(args, func(name string) groupVMStatus {
+		return stopGroupVM(baseDir, name, timeout)
+	})
+}
+
+// stopGroupVM stops the VM called name as 'vmterminal stop' does.
+func stopGroupVM(baseDir, name string, timeout time.Duration) groupVMStatus {
+	st := groupVMStatus{VM: name, Status: "failed"}
+	res, err := stopNamedVM(baseDir, name, timeout, false, func(string, ...interface{}) {})
+	if err != nil {
+		st.Detail = err.Error()
+		return st
+	}
+	st.PID, st.OK = res.PID, true
+	switch res.Action {
+	case "not_running":
+		st.Status = "not running"
+	case "stale":
+		st.Status, st.Detail = "not running", "removed stale PID file"
+	case "killed":
+		st.Status, st.Detail = "killed", fmt.Sprintf("did not shut down within %s", timeout)
+	default:
+		st.Status = res.Action
+	}
+	return st
+}
@@ -0,0 +1,147 @@
+package cli
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/javanstorm/vmterminal/internal/vm"
+)
+
+// fakeRunScript writes a stand-in for the vmterminal binary that runs
+// body with the console log path (the last argument of 'run') in $LOG.
+func fakeRunScript(t *testing.T, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "vmterminal")
+	script := "#!/bin/sh\nfor LOG; do :; done\n" + body + "\n"
+	if err := os.WriteFile(path, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestStartGroupVM(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	baseDir := filepath.Join(home, ".vmterminal")
+	logPath := filepath.Join(baseDir, "data", "default", groupConsoleLog)
+	if err := os.MkdirAll(filepath.Dir(logPath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(logPath, []byte("old boot\nalpine login: "), 0644)
+
+	exe := fakeRunScript(t, `sleep 0.2; printf 'Welcome\nalpine login: ' >> "$LOG"; sleep 5`)
+	st := startGroupVM(context.Background(), exe, baseDir, "default", 5*time.Second)
+	if st.Status != "ready" || !st.OK || st.PID == 0 {
+		t.Errorf("startGroupVM = %+v, want ready", st)
+	}
+	if old, _ := os.ReadFile(logPath + ".1"); string(old) != "old boot\nalpine login: " {
+		t.Errorf("previous log = %q, want it kept as %s.1", old, groupConsoleLog)
+	}
+}
+
+func TestStartGroupVMFailures(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	baseDir := filepath.Join(home, ".vmterminal")
+
+	exited := startGroupVM(context.Background(), fakeRunScript(t, "exit 1"), baseDir, "default", 5*time.Second)
+	if exited.OK || !strings.Contains(exited.Detail, "exited before the login prompt") {
+		t.Errorf("startGroupVM with a failing run = %+v", exited)
+	}
+
+	slow := startGroupVM(context.Background(), fakeRunScript(t, "sleep 5"), baseDir, "default", 200*time.Millisecond)
+	if slow.OK || slow.Status != "timeout" {
+		t.Errorf("startGroupVM with a slow boot = %+v, want timeout", slow)
+	}
+
+	missing := startGroupVM(context.Background(), fakeRunScript(t, "exit 0"), baseDir, "nope", time.Second)
+	if missing.OK || missing.Status != "failed" {
+		t.Errorf("startGroupVM for an unknown VM = %+v, want failed", missing)
+	}
+}
+
+func TestRunGroupOrderAndDuplicates(t *testing.T) {
+	orig := jsonOutput
+	t.Cleanup(func() { jsonOutput = orig })
+	jsonOutput = true
+
+	err := runGroup([]string{"a", "b", "c"}, func(name string) groupVMStatus {
+		// Finish in reverse order; results still follow the arguments
+		time.Sleep(time.Duration('d'-name[0]) * 10 * time.Millisecond)
+		return groupVMStatus{VM: name, Status: "ready", OK: name != "b"}
+	})
+	if code, ok := err.(*ExitCodeError); !ok || code.Code != 1 {
+		t.Errorf("runGroup with a failed VM = %v, want exit status 1", err)
+	}
+
+	if err := runGroup([]string{"a", "a"}, func(string) groupVMStatus { return groupVMStatus{OK: true} }); err == nil {
+		t.Error("runGroup with a VM listed twice should fail")
+	}
+}
+
+func TestGroupRenderHuman(t *testing.T) {
+	res := &groupResult{VMs: []groupVMStatus{
+		{VM: "api", Status: "ready", PID: 4242, OK: true},
+		{VM: "db", Status: "timeout", Detail: "no login prompt on the console after 5m0s; still running"},
+	}}
+	var buf strings.Builder
+	res.RenderHuman(&buf)
+	out := buf.String()
+	for _, want := range []string{"VM   STATUS   PID   DETAIL", "api  ready    4242", "db   timeout  -     no login prompt"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestStopGroupVM(t *testing.T) {
+	cmd, _ := startStopTarget(t, "api", "sleep", "30")
+	baseDir := filepath.Join(os.Getenv("HOME"), ".vmterminal")
+
+	st := stopGroupVM(baseDir, "api", 5*time.Second)
+	if st.Status != "stopped" || !st.OK || st.PID != cmd.Process.Pid {
+		t.Errorf("stopGroupVM = %+v, want stopped", st)
+	}
+	if st := stopGroupVM(baseDir, "api", time.Second); st.Status != "not running" || !st.OK {
+		t.Errorf("stopGroupVM on a stopped VM = %+v, want not running", st)
+	}
+}
+
+func TestCheckGroupConflicts(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	baseDir := filepath.Join(home, ".vmterminal")
+	reg := vm.NewRegistry(baseDir)
+	for name, port := range map[string]int{"api": 2300, "db": 2301, "cache": 2300} {
+		if err := reg.CreateVM(vm.VMEntry{Name: name, Config: &vm.VMConfig{SSHHostPort: &port}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := checkGroupConflicts(baseDir, []string{"api", "db"}); err != nil {
+		t.Errorf("distinct ports: %v", err)
+	}
+	if err := checkGroupConflicts(baseDir, []string{"api", "cache"}); err == nil || !strings.Contains(err.Error(), "port 2300") {
+		t.Errorf("shared port: got %v, want an error naming port 2300", err)
+	}
+
+	// db's disk is a symlink to api's
+	apiDisk := filepath.Join(baseDir, "data", "api", vm.RootDiskName+".raw")
+	dbDisk := filepath.Join(baseDir, "data", "db", vm.RootDiskName+".raw")
+	for _, dir := range []string{filepath.Dir(apiDisk), filepath.Dir(dbDisk)} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	os.WriteFile(apiDisk, nil, 0644)
+	if err := os.Symlink(apiDisk, dbDisk); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkGroupConflicts(baseDir, []string{"api", "db"}); err == nil || !strings.Contains(err.Error(), "disk image") {
+		t.Errorf("shared disk: got %v, want an error", err)
+	}
+}
@@ -0,0 +1,103 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/javanstorm/vmterminal/internal/config"
+	"github.com/javanstorm/vmterminal/internal/vm"
+	"github.com/spf13/cobra"
+)
+
+// defaultHealthTimeout is how long to wait for SSH before giving up.
+const defaultHealthTimeout = 60 * time.Second
+
+var (
+	healthTimeout time.Duration
+	healthVM      string
+)
+
+var healthCheckCmd = &cobra.Command{
+	Use:   "health-check",
+	Short: "Wait until the VM accepts SSH connections",
+	Long: `Repeatedly try to run a command in the VM over SSH until it succeeds or
+the timeout expires. Exits non-zero if the VM never becomes reachable.
+
+SSH over virtio-vsock is tried first when the VM has it (macOS), since it
+answers as soon as sshd starts, without waiting for networking.
+
+Useful after 'vmterminal run --headless' to know when the VM has booted.`,
+	RunE: runHealthCheck,
+}
+
+func init() {
+	healthCheckCmd.Flags().DurationVar(&healthTimeout, "timeout", defaultHealthTimeout, "How long to wait for SSH")
+	healthCheckCmd.Flags().StringVar(&healthVM, "vm", "", "VM to check (default: active VM)")
+}
+
+// healthResult is the structured output of the health-check command.
+type healthResult struct {
+	Healthy bool   `json:"healthy"`
+	Port    int    `json:"port"`
+	Elapsed string `json:"elapsed"`
+}
+
+// RenderHuman prints the health-check result as text.
+func (r healthResult) RenderHuman(w io.Writer) {
+	if r.Port == 0 {
+		fmt.Fprintf(w, "VM is ready: SSH answered over vsock after %s.\n", r.Elapsed)
+		return
+	}
+	fmt.Fprintf(w, "VM is ready: SSH answered on port %d after %s.\n", r.Port, r.Elapsed)
+}
+
+// waitForVMSSH waits for SSH using the VMTerminal key, through the vsock
+// proxy socket if there is one and otherwise on the forwarded port.
+func waitForVMSSH(ctx context.Context, socket string, port int, timeout time.Duration) error {
+	if port == 0 && socket == "" {
+		return fmt.Errorf("SSH port forwarding is disabled; set an SSH host port with 'vmterminal config'")
+	}
+
+	baseDir, err := baseDirectory()
+	if err != nil {
+		return err
+	}
+	privKeyPath, err := vm.NewSSHKeyManager(baseDir).PrivateKeyPath()
+	if err != nil {
+		return fmt.Errorf("no SSH key found; generate one with 'vmterminal ssh keygen'")
+	}
+
+	return vm.WaitForSSH(ctx, vm.SSHConfig{Port: port, Socket: socket, KeyPath: privKeyPath}, timeout)
+}
+
+func runHealthCheck(cmd *cobra.Command, args []string) error {
+	cfg, err := config.LoadState()
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+
+	baseDir, err := baseDirectory()
+	if err != nil {
+		return err
+	}
+	vmName, entry, err := resolveVM(baseDir, healthVM)
+	if err != nil {
+		return err
+	}
+	if entry != nil {
+		cfg = entry.EffectiveState(cfg)
+	}
+
+	start := time.Now()
+	if err := waitForVMSSH(cmd.Context(), vsockSocket(baseDir, vmName), cfg.SSHHostPort, healthTimeout); err != nil {
+		return err
+	}
+
+	return printResult(healthResult{
+		Healthy: true,
+		Port:    cfg.SSHHostPort,
+		Elapsed: time.Since(start).Round(100 * time.Millisecond).String(),
+	})
+}
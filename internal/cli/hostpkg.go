@@ -0,0 +1,124 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/javanstorm/vmterminal/internal/terminal"
+	"github.com/javanstorm/vmterminal/internal/vm"
+	"github.com/spf13/cobra"
+)
+
+var hostPkgCmd = &cobra.Command{
+	Use:   "host-pkg",
+	Short: "Manage packages on the host",
+	Long: `Run the host's package manager, to install the tools VMTerminal uses
+without leaving it.
+
+The package manager is Homebrew on macOS and the distribution's own on
+Linux: apt, dnf, yum, pacman, zypper, apk or xbps. On Linux, commands that
+change the system run through sudo.
+
+Commands that change the host (install, remove, update, upgrade) show
+what they will run and ask first; pass --yes to skip the question, which
+is required when stdin is not a terminal.
+
+Examples:
+  vmterminal host-pkg install qemu
+  vmterminal host-pkg install libguestfs-tools qemu-utils
+  vmterminal host-pkg search zstd`,
+}
+
+var hostPkgInstallCmd = &cobra.Command{
+	Use:   "install <packages...>",
+	Short: "Install packages on the host",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runHostPkgCommand(true, func(pm vm.HostPackageManager) []string { return pm.Install(args...) })
+	},
+}
+
+var hostPkgRemoveCmd = &cobra.Command{
+	Use:   "remove <packages...>",
+	Short: "Remove packages from the host",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runHostPkgCommand(true, func(pm vm.HostPackageManager) []string { return pm.Remove(args...) })
+	},
+}
+
+var hostPkgSearchCmd = &cobra.Command{
+	Use:   "search <query>",
+	Short: "Search for host packages",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runHostPkgCommand(false, func(pm vm.HostPackageManager) []string { return pm.Search(args[0]) })
+	},
+}
+
+var hostPkgUpdateCmd = &cobra.Command{
+	Use:   "update",
+	Short: "Update the host's package index",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runHostPkgCommand(true, vm.HostPackageManager.Update)
+	},
+}
+
+var hostPkgUpgradeCmd = &cobra.Command{
+	Use:   "upgrade",
+	Short: "Upgrade all installed host packages",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runHostPkgCommand(true, vm.HostPackageManager.Upgrade)
+	},
+}
+
+var hostPkgListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List installed host packages",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runHostPkgCommand(false, vm.HostPackageManager.List)
+	},
+}
+
+var hostPkgYes bool
+
+func init() {
+	hostPkgCmd.PersistentFlags().BoolVarP(&hostPkgYes, "yes", "y", false, "Change host packages without asking")
+	hostPkgCmd.AddCommand(hostPkgInstallCmd)
+	hostPkgCmd.AddCommand(hostPkgRemoveCmd)
+	hostPkgCmd.AddCommand(hostPkgSearchCmd)
+	hostPkgCmd.AddCommand(hostPkgUpdateCmd)
+	hostPkgCmd.AddCommand(hostPkgUpgradeCmd)
+	hostPkgCmd.AddCommand(hostPkgListCmd)
+	rootCmd.AddCommand(hostPkgCmd)
+	noJSON(hostPkgCmd.Commands()...)
+}
+
+// runHostPkgCommand runs a command of the host's package manager. A
+// command that changes the host is shown and confirmed first, unless
+// --yes was given.
+func runHostPkgCommand(changesHost bool, build func(vm.HostPackageManager) []string) error {
+	pm, err := vm.DetectHostPackageManager()
+	if err != nil {
+		return err
+	}
+	args := build(pm)
+	if changesHost && !hostPkgYes {
+		if !terminal.IsTerminal(int(os.Stdin.Fd())) {
+			return fmt.Errorf("refusing to run '%s' without confirmation; pass --yes", strings.Join(args, " "))
+		}
+		fmt.Printf("This will run: %s\n", strings.Join(args, " "))
+		if !promptYesNo("Continue?", false) {
+			fmt.Println("Aborted.")
+			return nil
+		}
+	}
+	if err := execInteractive(args[0], args[1:]); err != nil {
+		return fmt.Errorf("%s: %w", pm.Name(), err)
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"github.com/javanstorm/vmterminal/internal/vm"
+	"github.com/spf13/cobra"
+)
+
+var listRunningCmd = &cobra.Command{
+	Use:   "list-running",
+	Short: "List the VMs that are currently running",
+	Long: `List every VM with a live vmterminal process, with its PID and uptime.
+
+Each VM started with 'vmterminal run --vm <name>' keeps its own PID file
+and state in ~/.vmterminal/data/<name>, so several VMs can run at once.`,
+	Args: cobra.NoArgs,
+	RunE: runListRunning,
+}
+
+// runningVM is a single row of list-running output.
+type runningVM struct {
+	Name          string    `json:"name"`
+	PID           int       `json:"pid"`
+	LastBoot      time.Time `json:"last_boot,omitempty"`
+	UptimeSeconds int64     `json:"uptime_seconds"`
+}
+
+// listRunningResult is the structured output of list-running.
+type listRunningResult struct {
+	VMs []runningVM `json:"vms"`
+}
+
+// RenderHuman prints the running VMs as a table.
+func (r *listRunningResult) RenderHuman(w io.Writer) {
+	if len(r.VMs) == 0 {
+		fmt.Fprintln(w, "No VMs are running.")
+		return
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tPID\tUPTIME")
+	for _, v := range r.VMs {
+		uptime := "-"
+		if !v.LastBoot.IsZero() {
+			uptime = (time.Duration(v.UptimeSeconds) * time.Second).String()
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%s\n", v.Name, v.PID, uptime)
+	}
+	tw.Flush()
+}
+
+func runListRunning(cmd *cobra.Command, args []string) error {
+	baseDir, err := baseDirectory()
+	if err != nil {
+		return err
+	}
+	vms, err := listRunningVMs(baseDir, time.Now())
+	if err != nil {
+		return err
+	}
+	return printResult(&listRunningResult{VMs: vms})
+}
+
+// listRunningVMs checks the PID file in every VM data directory under
+// baseDir and returns the VMs whose process is alive, in name order.
+// Uptime is measured from the LastBoot recorded in each VM's state file.
+func listRunningVMs(baseDir string, now time.Time) ([]runningVM, error) {
+	entries, err := os.ReadDir(filepath.Join(baseDir, "data"))
+	if os.IsNotExist(err) {
+		return []runningVM{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read VM data dirs: %w", err)
+	}
+
+	vms := []runningVM{}
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		running, pid := isVMRunning(baseDir, e.Name())
+		if !running {
+			continue
+		}
+		r := runningVM{Name: e.Name(), PID: pid}
+		state, err := vm.NewStateFile(filepath.Join(baseDir, "data", e.Name())).Load()
+		if err == nil && !state.LastBoot.IsZero() {
+			r.LastBoot = state.LastBoot
+			r.UptimeSeconds = int64(now.Sub(state.LastBoot) / time.Second)
+		}
+		vms = append(vms, r)
+	}
+	return vms, nil
+}
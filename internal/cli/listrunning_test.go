@@ -0,0 +1,95 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/javanstorm/vmterminal/internal/vm"
+)
+
+func TestListRunningVMs(t *testing.T) {
+	baseDir := t.TempDir()
+	writePID := func(name string, pid int) string {
+		dataDir := filepath.Join(baseDir, "data", name)
+		if err := os.MkdirAll(dataDir, 0755); err != nil {
+			t.Fatalf("MkdirAll: %v", err)
+		}
+		os.WriteFile(filepath.Join(dataDir, "vm.pid"), []byte(fmt.Sprintf("%d\n", pid)), 0644)
+		return dataDir
+	}
+
+	// Two live VMs: this test process stands in for both
+	boot := time.Date(2026, 1, 2, 3, 0, 0, 0, time.UTC)
+	prodDir := writePID("prod", os.Getpid())
+	vm.NewStateFile(prodDir).Save(&vm.PersistentState{LastBoot: boot})
+	writePID("dev", os.Getpid())
+
+	// A stale PID file from a process that has exited
+	exited := exec.Command("true")
+	if err := exited.Run(); err != nil {
+		t.Skipf("run true: %v", err)
+	}
+	writePID("old", exited.Process.Pid)
+
+	// A VM that was never started
+	os.MkdirAll(filepath.Join(baseDir, "data", "idle"), 0755)
+
+	vms, err := listRunningVMs(baseDir, boot.Add(90*time.Minute))
+	if err != nil {
+		t.Fatalf("listRunningVMs: %v", err)
+	}
+	if len(vms) != 2 || vms[0].Name != "dev" || vms[1].Name != "prod" {
+		t.Fatalf("listRunningVMs = %+v, want dev and prod", vms)
+	}
+	if vms[0].PID != os.Getpid() || !vms[0].LastBoot.IsZero() {
+		t.Errorf("dev = %+v, want our PID and no boot time", vms[0])
+	}
+	if vms[1].UptimeSeconds != 90*60 {
+		t.Errorf("prod uptime = %ds, want %d", vms[1].UptimeSeconds, 90*60)
+	}
+}
+
+func TestListRunningVMsNoDataDir(t *testing.T) {
+	vms, err := listRunningVMs(t.TempDir(), time.Now())
+	if err != nil || len(vms) != 0 {
+		t.Errorf("listRunningVMs = %v, %v, want none", vms, err)
+	}
+}
+
+func TestResolveRunVM(t *testing.T) {
+	baseDir := t.TempDir()
+	reg := vm.NewRegistry(baseDir)
+	orig := runVM
+	t.Cleanup(func() { runVM = orig })
+
+	// Nothing registered: fall back to the default VM
+	runVM = ""
+	if name, entry, err := resolveRunVM(baseDir); err != nil || name != "default" || entry != nil {
+		t.Errorf("empty registry = %q, %v, %v, want default", name, entry, err)
+	}
+
+	for _, name := range []string{"dev", "prod"} {
+		if err := reg.CreateVM(vm.VMEntry{Name: name}); err != nil {
+			t.Fatalf("CreateVM: %v", err)
+		}
+	}
+	reg.SetActive("dev")
+	if name, _, _ := resolveRunVM(baseDir); name != "dev" {
+		t.Errorf("active VM = %q, want dev", name)
+	}
+
+	// --vm wins over the active VM
+	runVM = "prod"
+	if name, entry, err := resolveRunVM(baseDir); err != nil || name != "prod" || entry == nil {
+		t.Errorf("--vm prod = %q, %v, %v", name, entry, err)
+	}
+
+	runVM = "missing"
+	if _, _, err := resolveRunVM(baseDir); err == nil {
+		t.Error("expected an error for an unknown VM")
+	}
+}
@@ -0,0 +1,100 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/javanstorm/vmterminal/internal/vm"
+	"github.com/spf13/cobra"
+)
+
+var logsCmd = &cobra.Command{
+	Use:   "logs",
+	Short: "Show the VM console log",
+	Long: `Show the console log written by 'vmterminal run --console-log'.
+
+The log path is recorded when the VM boots, so there is no need to pass it
+again. --since needs a log written with 'run --console-log-timestamps',
+which prefixes each line with the time it was received. With both --since
+and --lines, the last n of the lines since the cutoff are printed.
+
+Examples:
+  vmt logs                  # Print the whole log
+  vmt logs --lines 50       # Print the last 50 lines
+  vmt logs -f               # Follow new output, across log rotation
+  vmt logs --since 10m      # Only lines from the last 10 minutes
+  vmt logs --vm dev -n 20   # Last 20 lines of a specific VM`,
+	Args: cobra.NoArgs,
+	RunE: runLogs,
+}
+
+var (
+	logsVM     string
+	logsFollow bool
+	logsLines  int
+	logsSince  time.Duration
+)
+
+func init() {
+	logsCmd.Flags().StringVar(&logsVM, "vm", "", "VM whose console log to show (default: active VM)")
+	logsCmd.Flags().BoolVarP(&logsFollow, "follow", "f", false, "Keep printing new output as it is written")
+	logsCmd.Flags().IntVarP(&logsLines, "lines", "n", 0, "Only print the last n lines (0 = all)")
+	logsCmd.Flags().DurationVar(&logsSince, "since", 0, "Only print lines from this long ago onwards (e.g. 30s, 10m, 2h)")
+	noJSON(logsCmd)
+}
+
+func runLogs(cmd *cobra.Command, args []string) error {
+	if logsLines < 0 {
+		return fmt.Errorf("--lines must not be negative")
+	}
+	if logsSince < 0 {
+		return fmt.Errorf("--since must not be negative")
+	}
+
+	baseDir, err := baseDirectory()
+	if err != nil {
+		return err
+	}
+	vmName, _, err := resolveVM(baseDir, logsVM)
+	if err != nil {
+		return err
+	}
+	path, stamped, err := consoleLogPath(baseDir, vmName)
+	if err != nil {
+		return err
+	}
+
+	var since time.Time
+	if logsSince > 0 {
+		if !stamped {
+			return fmt.Errorf("--since needs a timestamped console log; start the VM with 'vmterminal run --console-log <path> --console-log-timestamps'")
+		}
+		since = time.Now().Add(-logsSince)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return vm.TailFileContext(ctx, path, logsLines, since, logsFollow, cmd.OutOrStdout())
+}
+
+// consoleLogPath returns the console log recorded for the named VM, and
+// whether its lines are timestamped.
+func consoleLogPath(baseDir, name string) (path string, stamped bool, err error) {
+	state, err := vm.NewStateFile(filepath.Join(baseDir, "data", name)).Load()
+	if err != nil {
+		return "", false, err
+	}
+	if state.ConsoleLogPath == "" {
+		return "", false, fmt.Errorf("no console log recorded for VM '%s'; start it with 'vmterminal run --console-log <path>'", name)
+	}
+	if _, err := os.Stat(state.ConsoleLogPath); errors.Is(err, os.ErrNotExist) {
+		return "", false, fmt.Errorf("console log %s no longer exists", state.ConsoleLogPath)
+	}
+	return state.ConsoleLogPath, state.ConsoleLogTimestamps, nil
+}
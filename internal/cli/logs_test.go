@@ -0,0 +1,33 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/javanstorm/vmterminal/internal/vm"
+)
+
+func TestConsoleLogPath(t *testing.T) {
+	baseDir := t.TempDir()
+	stateFile := vm.NewStateFile(filepath.Join(baseDir, "data", "dev"))
+
+	if _, _, err := consoleLogPath(baseDir, "dev"); err == nil || !strings.Contains(err.Error(), "--console-log") {
+		t.Errorf("no recorded log: error = %v, want a --console-log hint", err)
+	}
+
+	logPath := filepath.Join(baseDir, "console.log")
+	if err := stateFile.RecordConsoleLog(logPath, true); err != nil {
+		t.Fatalf("RecordConsoleLog: %v", err)
+	}
+	if _, _, err := consoleLogPath(baseDir, "dev"); err == nil || !strings.Contains(err.Error(), "no longer exists") {
+		t.Errorf("missing log: error = %v", err)
+	}
+
+	os.WriteFile(logPath, []byte("boot\n"), 0644)
+	got, stamped, err := consoleLogPath(baseDir, "dev")
+	if err != nil || got != logPath || !stamped {
+		t.Errorf("consoleLogPath = %q, %v, %v, want %q with timestamps", got, stamped, err, logPath)
+	}
+}
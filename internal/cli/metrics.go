@@ -0,0 +1,81 @@
+package cli
+
+import (
+	"sync"
+	"time"
+
+	"github.com/javanstorm/vmterminal/internal/metrics"
+	"github.com/javanstorm/vmterminal/internal/vm"
+)
+
+// vmMetricsSource reports metrics for the VM hosted by this 'run' process.
+type vmMetricsSource struct {
+	mu        sync.RWMutex
+	mgr       *vm.Manager
+	stateFile *vm.StateFile
+	snapshots *vm.SnapshotManager
+	vmName    string
+}
+
+// manager returns the current VM manager.
+func (s *vmMetricsSource) manager() *vm.Manager {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.mgr
+}
+
+// setManager switches to the manager of a restarted VM.
+func (s *vmMetricsSource) setManager(mgr *vm.Manager) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.mgr = mgr
+}
+
+func (s *vmMetricsSource) State() string {
+	return s.manager().State().String()
+}
+
+func (s *vmMetricsSource) BootCount() (int, error) {
+	state, err := s.stateFile.Load()
+	if err != nil {
+		return 0, err
+	}
+	return state.BootCount, nil
+}
+
+func (s *vmMetricsSource) SnapshotCount() (int, error) {
+	snaps, err := s.snapshots.ListSnapshots(s.vmName)
+	if err != nil {
+		return 0, err
+	}
+	return len(snaps), nil
+}
+
+func (s *vmMetricsSource) DiskPath() string {
+	return s.manager().DiskPath()
+}
+
+func (s *vmMetricsSource) Uptime() time.Duration {
+	if st := s.manager().State(); st != vm.StateRunning && st != vm.StateSuspended {
+		return 0
+	}
+	state, err := s.stateFile.Load()
+	if err != nil || state.LastBoot.IsZero() {
+		return 0
+	}
+	return time.Since(state.LastBoot)
+}
+
+// vmStateNames lists every VM state name for the vm_state metric.
+func vmStateNames() []string {
+	var names []string
+	for s := vm.StateNew; s <= vm.StateSuspended; s++ {
+		names = append(names, s.String())
+	}
+	return names
+}
+
+// newMetricsServer creates the /metrics server for a running VM.
+func newMetricsServer(addr string, src *vmMetricsSource) *metrics.Server {
+	return metrics.NewServer(addr, metrics.NewCollector(src, vmStateNames()))
+}
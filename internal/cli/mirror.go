@@ -0,0 +1,215 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/javanstorm/vmterminal/internal/config"
+	"github.com/javanstorm/vmterminal/internal/distro"
+	"github.com/javanstorm/vmterminal/internal/vm"
+	"github.com/spf13/cobra"
+)
+
+var mirrorCmd = &cobra.Command{
+	Use:   "mirror",
+	Short: "Manage distro download mirrors",
+	Long: `Distro assets (kernels, rootfs tarballs, cloud images) are downloaded
+from each distro's official servers unless mirrors are configured.
+
+Mirrors are set with the 'mirrors' list in config.yaml, or for one run
+with 'vmterminal run --mirror'. Each entry is <url> for every distro or
+<distro>=<url> for one distro; a distro with mirrors of its own ignores
+the ones for every distro. A mirror must serve the same paths as the
+official server, optionally below a path of its own. Downloads try the
+mirrors in turn and fall back to the official server.`,
+}
+
+var mirrorTestCmd = &cobra.Command{
+	Use:   "test",
+	Short: "Measure how quickly each mirror serves distro assets",
+	Long: `Send a HEAD request for the distro's rootfs to each configured mirror
+and to the official server, and report whether it has the file and how
+long it took to answer, fastest first.
+
+Mirrors for every distro are tested against the configured distro, or the
+one given with --distro.
+
+Examples:
+  vmt mirror test
+  vmt mirror test --mirror https://mirror.example.com --distro alpine`,
+	Args: cobra.NoArgs,
+	RunE: runMirrorTest,
+}
+
+var (
+	mirrorTestDistro  string
+	mirrorTestMirrors []string
+	mirrorTestTimeout time.Duration
+)
+
+func init() {
+	mirrorTestCmd.Flags().StringVarP(&mirrorTestDistro, "distro", "d", "", "Distro to test mirrors for every distro against (default: configured distro)")
+	mirrorTestCmd.Flags().StringArrayVar(&mirrorTestMirrors, "mirror", nil, "Test this mirror, as [<distro>=]<url>, instead of the configured ones (repeatable)")
+	mirrorTestCmd.Flags().DurationVar(&mirrorTestTimeout, "timeout", 5*time.Second, "Give up on a mirror after this long")
+
+	mirrorCmd.AddCommand(mirrorTestCmd)
+}
+
+// mirrorTarget is one mirror to probe for one distro's rootfs.
+type mirrorTarget struct {
+	Mirror   string // Empty for the official server
+	Distro   distro.ID
+	AssetURL string
+}
+
+// mirrorProbeResult is a single row of mirror test output.
+type mirrorProbeResult struct {
+	Mirror    string    `json:"mirror"`
+	Distro    distro.ID `json:"distro"`
+	URL       string    `json:"url"`
+	OK        bool      `json:"ok"`
+	Status    int       `json:"status,omitempty"`
+	LatencyMS int64     `json:"latency_ms"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// mirrorTestResult is the structured output of mirror test.
+type mirrorTestResult struct {
+	Results []mirrorProbeResult `json:"results"`
+}
+
+// RenderHuman prints the probes as a table.
+func (r *mirrorTestResult) RenderHuman(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "MIRROR\tDISTRO\tRESULT\tLATENCY")
+	for _, p := range r.Results {
+		result := "ok"
+		switch {
+		case p.Error != "":
+			result = "error: " + p.Error
+		case !p.OK:
+			result = fmt.Sprintf("HTTP %d", p.Status)
+		}
+		latency := "-"
+		if p.Error == "" {
+			latency = fmt.Sprintf("%d ms", p.LatencyMS)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", p.Mirror, p.Distro, result, latency)
+	}
+	tw.Flush()
+}
+
+func runMirrorTest(cmd *cobra.Command, args []string) error {
+	cfg, err := config.LoadState()
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+
+	mirrors := mirrorTestMirrors
+	if len(mirrors) == 0 {
+		mirrors = cfg.Mirrors
+	}
+	if len(mirrors) == 0 {
+		return fmt.Errorf("no mirrors configured; add them to 'mirrors' in config.yaml or pass --mirror")
+	}
+
+	defaultID := distro.DefaultID()
+	switch {
+	case mirrorTestDistro != "":
+		if defaultID, err = distro.ParseID(mirrorTestDistro); err != nil {
+			return err
+		}
+	case cfg.Distro != "":
+		defaultID = distro.ID(cfg.Distro)
+	}
+
+	targets, err := mirrorTargets(mirrors, defaultID)
+	if err != nil {
+		return err
+	}
+
+	progressf("Testing %d mirrors...\n", len(targets))
+	client := &http.Client{Timeout: mirrorTestTimeout}
+	return printResult(&mirrorTestResult{Results: probeMirrors(context.Background(), client, targets)})
+}
+
+// mirrorTargets resolves each mirror entry to the rootfs it should serve,
+// with mirrors for every distro tested against defaultID. The official
+// server of each distro involved is added so mirrors can be compared
+// with it.
+func mirrorTargets(mirrors []string, defaultID distro.ID) ([]mirrorTarget, error) {
+	var targets []mirrorTarget
+	official := make(map[distro.ID]bool)
+	for _, entry := range mirrors {
+		id, mirrorURL, err := config.ParseMirror(entry)
+		if err != nil {
+			return nil, err
+		}
+		if id == "" {
+			id = defaultID
+		}
+		asset, err := mirrorTestAsset(id)
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, mirrorTarget{Mirror: mirrorURL, Distro: id, AssetURL: asset})
+		if !official[id] {
+			official[id] = true
+			targets = append(targets, mirrorTarget{Distro: id, AssetURL: asset})
+		}
+	}
+	return targets, nil
+}
+
+// mirrorTestAsset returns the URL of the asset mirrors of id are tested
+// with: the rootfs, or the kernel for distros without one.
+func mirrorTestAsset(id distro.ID) (string, error) {
+	provider, err := distro.Get(id)
+	if err != nil {
+		return "", err
+	}
+	urls, err := provider.AssetURLs(distro.CurrentArch())
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", id, err)
+	}
+	if urls.Rootfs != "" {
+		return urls.Rootfs, nil
+	}
+	return urls.Kernel, nil
+}
+
+// probeMirrors probes each target in turn and returns the results, mirrors
+// that have the asset first, fastest first.
+func probeMirrors(ctx context.Context, client *http.Client, targets []mirrorTarget) []mirrorProbeResult {
+	results := []mirrorProbeResult{}
+	for _, t := range targets {
+		probe := vm.ProbeMirror(ctx, client, t.AssetURL, t.Mirror)
+		res := mirrorProbeResult{
+			Mirror:    t.Mirror,
+			Distro:    t.Distro,
+			URL:       probe.URL,
+			OK:        probe.OK(),
+			Status:    probe.Status,
+			LatencyMS: probe.Latency.Milliseconds(),
+		}
+		if res.Mirror == "" {
+			res.Mirror = "(official)"
+		}
+		if probe.Err != nil {
+			res.Error = probe.Err.Error()
+		}
+		results = append(results, res)
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].OK != results[j].OK {
+			return results[i].OK
+		}
+		return results[i].LatencyMS < results[j].LatencyMS
+	})
+	return results
+}
@@ -0,0 +1,78 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/javanstorm/vmterminal/internal/distro"
+)
+
+func TestMirrorTargets(t *testing.T) {
+	targets, err := mirrorTargets([]string{
+		"https://all.example.com",
+		"ubuntu=https://ubuntu.example.com",
+	}, distro.Alpine)
+	if err != nil {
+		t.Fatalf("mirrorTargets: %v", err)
+	}
+
+	var got []string
+	for _, tgt := range targets {
+		if tgt.AssetURL == "" {
+			t.Errorf("%s/%s has no asset URL", tgt.Mirror, tgt.Distro)
+		}
+		got = append(got, tgt.Mirror+"|"+string(tgt.Distro))
+	}
+	want := []string{
+		"https://all.example.com|alpine",
+		"|alpine",
+		"https://ubuntu.example.com|ubuntu",
+		"|ubuntu",
+	}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("targets = %v, want %v", got, want)
+	}
+
+	if _, err := mirrorTargets([]string{"nosuch=https://x.example.com"}, distro.Alpine); err == nil {
+		t.Error("expected error for an unknown distro")
+	}
+}
+
+func TestProbeMirrors(t *testing.T) {
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer fast.Close()
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+	}))
+	defer slow.Close()
+	missing := httptest.NewServer(http.NotFoundHandler())
+	defer missing.Close()
+
+	asset := "https://cdn.example.org/alpine/rootfs.tar.gz"
+	results := probeMirrors(context.Background(), http.DefaultClient, []mirrorTarget{
+		{Mirror: missing.URL, Distro: distro.Alpine, AssetURL: asset},
+		{Mirror: slow.URL, Distro: distro.Alpine, AssetURL: asset},
+		{Mirror: fast.URL, Distro: distro.Alpine, AssetURL: asset},
+	})
+
+	order := []string{fast.URL, slow.URL, missing.URL}
+	for i, want := range order {
+		if results[i].Mirror != want {
+			t.Errorf("results[%d] = %s, want %s", i, results[i].Mirror, want)
+		}
+	}
+	if results[2].OK || results[2].Status != http.StatusNotFound {
+		t.Errorf("missing mirror = %+v, want 404", results[2])
+	}
+
+	var buf bytes.Buffer
+	(&mirrorTestResult{Results: results}).RenderHuman(&buf)
+	if !strings.Contains(buf.String(), "HTTP 404") || !strings.Contains(buf.String(), " ms") {
+		t.Errorf("output:\n%s", buf.String())
+	}
+}
@@ -2,8 +2,7 @@ package cli
 
 import (
 	"fmt"
-	"os"
-	"path/filepath"
+	"io"
 	"runtime"
 
 	"github.com/javanstorm/vmterminal/internal/config"
@@ -15,6 +14,8 @@ import (
 var (
 	mountTag   string
 	mountCheck bool
+	mountUnion string
+	mountType  string
 )
 
 var mountCmd = &cobra.Command{
@@ -23,11 +24,20 @@ var mountCmd = &cobra.Command{
 	Long: `Display shell commands to mount shared directories inside the VM.
 
 The output can be copy-pasted into the VM shell or piped to execute.
-Shared directories use virtio-fs (macOS only).
+Shared directories use virtio-fs on macOS and 9p on Linux KVM, which lacks
+virtio-fs; --type picks the protocol explicitly.
+
+With --union, the output is a script that mounts every share in one tree
+under the given directory, at the path its tag names, so a share tagged
+"src/web" lands inside the one tagged "src". Shares that map to the same
+path are combined in a read-only overlay, and the script says so in a
+warning comment.
 
 Examples:
   vmterminal mount              # Show mount script for all shares
   vmterminal mount --tag home   # Show command for single share
+  vmterminal mount --union /mnt/host  # Mount all shares in one tree
+  vmterminal mount --type 9p    # Mount with 9p instead of virtio-fs
   vmterminal mount --check      # Verify platform capabilities`,
 	RunE: runMount,
 }
@@ -35,14 +45,72 @@ Examples:
 func init() {
 	mountCmd.Flags().StringVar(&mountTag, "tag", "", "Show mount command for specific share tag only")
 	mountCmd.Flags().BoolVar(&mountCheck, "check", false, "Check platform capabilities for shared directories")
+	mountCmd.Flags().StringVar(&mountUnion, "union", "", "Print a script that mounts all shares in one tree under this directory")
+	mountCmd.Flags().StringVar(&mountType, "type", "", "Mount with virtiofs or 9p (default: what the hypervisor supports)")
 	rootCmd.AddCommand(mountCmd)
 }
 
+// mountShare is the mount command for one shared directory.
+type mountShare struct {
+	Tag        string `json:"tag"`
+	HostPath   string `json:"host_path"`
+	Mountpoint string `json:"mountpoint"`
+	Command    string `json:"command"`
+}
+
+// mountResult holds the mount commands 'vmterminal mount' prints.
+type mountResult struct {
+	Protocol string       `json:"protocol,omitempty"`
+	Shares   []mountShare `json:"shares"`
+	Script   string       `json:"script,omitempty"` // --union
+	// Unavailable is set when the hypervisor supports no sharing protocol.
+	Unavailable bool `json:"unavailable,omitempty"`
+	single      bool
+}
+
+// RenderHuman prints the commands as a script to paste into the VM shell.
+func (r *mountResult) RenderHuman(w io.Writer) {
+	switch {
+	case r.Unavailable:
+		printLinuxWarning(w)
+	case len(r.Shares) == 0 && r.Script == "":
+		fmt.Fprintln(w, "# No shared directories configured")
+		fmt.Fprintln(w, "# Use 'vmterminal config' to add shared directories")
+	case r.Script != "":
+		fmt.Fprintln(w, r.Script)
+	case r.single:
+		fmt.Fprintln(w, r.Shares[0].Command)
+	default:
+		fmt.Fprintln(w, "# Mount shared directories in your VM:")
+		fmt.Fprintln(w, "# Copy and paste the following into your VM shell")
+		fmt.Fprintln(w)
+
+		// Print simple one-liner commands for easy copy-paste
+		for _, s := range r.Shares {
+			fmt.Fprintln(w, s.Command)
+		}
+
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "# Or generate a full script with error handling:")
+		fmt.Fprintln(w, "# vmterminal mount > mount-shares.sh && chmod +x mount-shares.sh")
+	}
+}
+
 func runMount(cmd *cobra.Command, args []string) error {
 	// Check capabilities first if requested
 	if mountCheck {
 		return runMountCheck()
 	}
+	if mountUnion != "" && mountTag != "" {
+		return fmt.Errorf("--union and --tag cannot be used together")
+	}
+	var protocol vm.MountProtocol
+	if mountType != "" {
+		var err error
+		if protocol, err = vm.ParseMountProtocol(mountType); err != nil {
+			return err
+		}
+	}
 
 	// Load config
 	cfg, err := config.LoadState()
@@ -50,60 +118,86 @@ func runMount(cmd *cobra.Command, args []string) error {
 		cfg = config.DefaultState()
 	}
 
-	// Convert SharedDirs slice to map (tag derived from basename)
-	shares := make(map[string]string)
-	for _, dir := range cfg.SharedDirs {
-		tag := filepath.Base(dir)
-		// Handle home directory specially
-		if dir == os.Getenv("HOME") || tag == filepath.Base(os.Getenv("HOME")) {
-			tag = "home"
-		}
-		shares[tag] = dir
-	}
+	// Tag the shares the way run does when it attaches them
+	shares, _ := sharedDirMaps(cfg.SharedDirs)
 
+	result := &mountResult{Shares: []mountShare{}}
 	if len(shares) == 0 {
-		fmt.Println("# No shared directories configured")
-		fmt.Println("# Use 'vmterminal config' to add shared directories")
-		return nil
+		return printResult(result)
 	}
 
 	helper := vm.NewMountHelper(shares)
 
-	// Check capabilities and warn if needed
-	driver, err := hypervisor.NewDriver()
-	if err == nil {
-		caps := driver.Capabilities()
-		if !caps.SharedDirs {
-			printLinuxWarning()
-			return nil
+	// Without --type, use what the hypervisor supports, and warn if nothing
+	if protocol == "" {
+		if driver, err := hypervisor.NewDriver(); err == nil {
+			p, ok := vm.MountProtocolFor(driver.Capabilities())
+			if !ok {
+				result.Unavailable = true
+				return printResult(result)
+			}
+			protocol = p
 		}
 	}
+	if protocol != "" {
+		helper.SetProtocol(protocol)
+	}
+	result.Protocol = string(protocol)
 
+	tags := helper.Tags()
 	if mountTag != "" {
 		// Single share
 		if _, ok := shares[mountTag]; !ok {
-			return fmt.Errorf("unknown mount tag %q, available: %v", mountTag, helper.Tags())
+			return fmt.Errorf("unknown mount tag %q, available: %v", mountTag, tags)
 		}
-		mountpoint := "/mnt/host/" + mountTag
-		fmt.Println(helper.GenerateMountCommand(mountTag, mountpoint))
-	} else {
-		// All shares
-		fmt.Println("# Mount shared directories in your VM:")
-		fmt.Println("# Copy and paste the following into your VM shell")
-		fmt.Println()
+		tags = []string{mountTag}
+		result.single = true
+	}
+	for _, tag := range tags {
+		mountpoint := "/mnt/host/" + tag
+		result.Shares = append(result.Shares, mountShare{
+			Tag:        tag,
+			HostPath:   shares[tag],
+			Mountpoint: mountpoint,
+			Command:    helper.GenerateMountCommand(tag, mountpoint),
+		})
+	}
+	if mountUnion != "" {
+		result.Script = helper.GenerateUnionMountScript(mountUnion)
+	}
+	return printResult(result)
+}
 
-		// Print simple one-liner commands for easy copy-paste
-		for _, tag := range helper.Tags() {
-			mountpoint := "/mnt/host/" + tag
-			fmt.Println(helper.GenerateMountCommand(tag, mountpoint))
-		}
+// capabilitiesResult is the platform support 'mount --check' reports.
+type capabilitiesResult struct {
+	Hypervisor        string `json:"hypervisor"`
+	HypervisorVersion string `json:"hypervisor_version"`
+	Arch              string `json:"arch"`
+	Platform          string `json:"platform"`
+	VirtioFS          bool   `json:"virtiofs"`
+	NineP             bool   `json:"9p"`
+	Networking        bool   `json:"networking"`
+	Snapshots         bool   `json:"snapshots"`
+}
 
-		fmt.Println()
-		fmt.Println("# Or generate a full script with error handling:")
-		fmt.Println("# vmterminal mount > mount-shares.sh && chmod +x mount-shares.sh")
+// RenderHuman prints the capabilities as text.
+func (r *capabilitiesResult) RenderHuman(w io.Writer) {
+	fmt.Fprintln(w, "Platform Capabilities")
+	fmt.Fprintln(w, "=====================")
+	fmt.Fprintf(w, "  Hypervisor: %s v%s (%s)\n", r.Hypervisor, r.HypervisorVersion, r.Arch)
+	fmt.Fprintf(w, "  Platform: %s\n", r.Platform)
+	fmt.Fprintln(w)
+
+	fmt.Fprintln(w, "Feature Support:")
+	fmt.Fprintf(w, "  Shared Directories (virtio-fs): %s\n", capabilityStatus(r.VirtioFS))
+	fmt.Fprintf(w, "  Shared Directories (9p): %s\n", capabilityStatus(r.NineP))
+	fmt.Fprintf(w, "  Networking: %s\n", capabilityStatus(r.Networking))
+	fmt.Fprintf(w, "  Snapshots: %s\n", capabilityStatus(r.Snapshots))
+
+	if !r.VirtioFS && !r.NineP {
+		fmt.Fprintln(w)
+		printLinuxWarning(w)
 	}
-
-	return nil
 }
 
 func runMountCheck() error {
@@ -114,24 +208,16 @@ func runMountCheck() error {
 
 	info := driver.Info()
 	caps := driver.Capabilities()
-
-	fmt.Println("Platform Capabilities")
-	fmt.Println("=====================")
-	fmt.Printf("  Hypervisor: %s v%s (%s)\n", info.Name, info.Version, info.Arch)
-	fmt.Printf("  Platform: %s/%s\n", runtime.GOOS, runtime.GOARCH)
-	fmt.Println()
-
-	fmt.Println("Feature Support:")
-	fmt.Printf("  Shared Directories: %s\n", capabilityStatus(caps.SharedDirs))
-	fmt.Printf("  Networking: %s\n", capabilityStatus(caps.Networking))
-	fmt.Printf("  Snapshots: %s\n", capabilityStatus(caps.Snapshots))
-
-	if !caps.SharedDirs {
-		fmt.Println()
-		printLinuxWarning()
-	}
-
-	return nil
+	return printResult(&capabilitiesResult{
+		Hypervisor:        info.Name,
+		HypervisorVersion: info.Version,
+		Arch:              info.Arch,
+		Platform:          runtime.GOOS + "/" + runtime.GOARCH,
+		VirtioFS:          caps.SharedDirs,
+		NineP:             caps.Sharing9P,
+		Networking:        caps.Networking,
+		Snapshots:         caps.Snapshots,
+	})
 }
 
 func capabilityStatus(supported bool) string {
@@ -141,12 +227,12 @@ func capabilityStatus(supported bool) string {
 	return "not available"
 }
 
-func printLinuxWarning() {
-	fmt.Println("# Shared directories not available on Linux KVM")
-	fmt.Println("#")
-	fmt.Println("# The hype library lacks virtio-fs support.")
-	fmt.Println("# Workarounds:")
-	fmt.Println("#   1. Use SSH to transfer files (see 'vmterminal ssh')")
-	fmt.Println("#   2. Mount host directories via NFS/SSHFS from guest")
-	fmt.Println("#   3. Use a different hypervisor with virtio-fs support")
+func printLinuxWarning(w io.Writer) {
+	fmt.Fprintln(w, "# Shared directories not available with this hypervisor")
+	fmt.Fprintln(w, "#")
+	fmt.Fprintln(w, "# It supports neither virtio-fs nor 9p.")
+	fmt.Fprintln(w, "# Workarounds:")
+	fmt.Fprintln(w, "#   1. Use SSH to transfer files (see 'vmterminal ssh')")
+	fmt.Fprintln(w, "#   2. Mount host directories via NFS/SSHFS from guest")
+	fmt.Fprintln(w, "#   3. Use a different hypervisor with virtio-fs support")
 }
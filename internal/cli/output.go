@@ -0,0 +1,119 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/javanstorm/vmterminal/internal/progress"
+	"github.com/spf13/cobra"
+)
+
+// jsonOutput is set by the global --json flag.
+var jsonOutput bool
+
+// OutputFormatter renders a command result to a writer.
+// Sub-commands assemble a result struct and delegate rendering here so
+// the same data can be shown to humans or consumed by scripts.
+type OutputFormatter interface {
+	Format(w io.Writer, result interface{}) error
+}
+
+// HumanRenderer is implemented by result structs that know how to print
+// themselves as human-readable text.
+type HumanRenderer interface {
+	RenderHuman(w io.Writer)
+}
+
+// HumanFormatter renders results as free-form text.
+type HumanFormatter struct{}
+
+// Format writes the human-readable form of result.
+func (HumanFormatter) Format(w io.Writer, result interface{}) error {
+	if r, ok := result.(HumanRenderer); ok {
+		r.RenderHuman(w)
+		return nil
+	}
+	_, err := fmt.Fprintln(w, result)
+	return err
+}
+
+// JSONFormatter renders results as a single indented JSON object.
+type JSONFormatter struct{}
+
+// Format writes result as JSON.
+func (JSONFormatter) Format(w io.Writer, result interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(result)
+}
+
+// noJSONAnnotation marks commands that are interactive or pass through
+// another program's output, so have no result for --json to print.
+const noJSONAnnotation = "vmterminal/no-json"
+
+// noJSON marks cmds as having no --json output.
+func noJSON(cmds ...*cobra.Command) {
+	for _, cmd := range cmds {
+		if cmd.Annotations == nil {
+			cmd.Annotations = map[string]string{}
+		}
+		cmd.Annotations[noJSONAnnotation] = "true"
+	}
+}
+
+// checkJSONSupported rejects --json for commands marked with noJSON, rather
+// than printing human text where a script expects a JSON document.
+func checkJSONSupported(cmd *cobra.Command) error {
+	if jsonOutput && cmd.Annotations[noJSONAnnotation] != "" {
+		return fmt.Errorf("--json is not supported by '%s', which is interactive or prints another program's output", cmd.CommandPath())
+	}
+	return nil
+}
+
+// outputFormatter returns the formatter selected by the --json flag.
+func outputFormatter() OutputFormatter {
+	if jsonOutput {
+		return JSONFormatter{}
+	}
+	return HumanFormatter{}
+}
+
+// printResult renders a command result to stdout using the active formatter.
+func printResult(result interface{}) error {
+	return outputFormatter().Format(os.Stdout, result)
+}
+
+// progressf prints a progress message unless machine-readable output is enabled.
+func progressf(format string, args ...interface{}) {
+	if !jsonOutput {
+		fmt.Printf(format, args...)
+	}
+}
+
+// progressln is progressf for a line of values, as fmt.Println prints them.
+func progressln(args ...interface{}) {
+	if !jsonOutput {
+		fmt.Println(args...)
+	}
+}
+
+// promptWriter returns where questions, and what they ask about, are
+// printed: stdout, or stderr with --json so stdout stays a single result
+// document.
+func promptWriter() io.Writer {
+	if jsonOutput {
+		return os.Stderr
+	}
+	return os.Stdout
+}
+
+// newProgress returns the progress reporter selected by the --json flag.
+// JSON events go to stderr so stdout stays a single result document.
+func newProgress() progress.Progress {
+	if jsonOutput {
+		return progress.NewJSONProgress(os.Stderr)
+	}
+	return progress.NewTextProgress(os.Stdout)
+}
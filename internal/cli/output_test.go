@@ -0,0 +1,76 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+func TestJSONFormatter(t *testing.T) {
+	res := &versionResult{Version: "1.2.3", Commit: "abc", BuildDate: "today"}
+
+	var buf bytes.Buffer
+	if err := (JSONFormatter{}).Format(&buf, res); err != nil {
+		t.Fatalf("Format: %v", err)
+	}
+
+	var got map[string]string
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("output is not valid JSON: %v\n%s", err, buf.String())
+	}
+	if got["version"] != "1.2.3" || got["commit"] != "abc" || got["build_date"] != "today" {
+		t.Errorf("unexpected JSON fields: %v", got)
+	}
+}
+
+func TestHumanFormatter(t *testing.T) {
+	res := &versionResult{Version: "1.2.3", Commit: "abc", BuildDate: "today"}
+
+	var buf bytes.Buffer
+	if err := (HumanFormatter{}).Format(&buf, res); err != nil {
+		t.Fatalf("Format: %v", err)
+	}
+	if !strings.HasPrefix(buf.String(), "VMTerminal 1.2.3 (commit: abc, built: today,") {
+		t.Errorf("unexpected human output: %q", buf.String())
+	}
+}
+
+func TestOutputFormatterSelection(t *testing.T) {
+	orig := jsonOutput
+	defer func() { jsonOutput = orig }()
+
+	jsonOutput = false
+	if _, ok := outputFormatter().(HumanFormatter); !ok {
+		t.Error("expected HumanFormatter when --json is not set")
+	}
+
+	jsonOutput = true
+	if _, ok := outputFormatter().(JSONFormatter); !ok {
+		t.Error("expected JSONFormatter when --json is set")
+	}
+}
+
+func TestCheckJSONSupported(t *testing.T) {
+	old := jsonOutput
+	defer func() { jsonOutput = old }()
+
+	interactive := &cobra.Command{Use: "interactive"}
+	noJSON(interactive)
+	plain := &cobra.Command{Use: "plain"}
+
+	jsonOutput = true
+	if err := checkJSONSupported(interactive); err == nil || !strings.Contains(err.Error(), "--json") {
+		t.Errorf("interactive command with --json: err = %v", err)
+	}
+	if err := checkJSONSupported(plain); err != nil {
+		t.Errorf("plain command with --json: %v", err)
+	}
+
+	jsonOutput = false
+	if err := checkJSONSupported(interactive); err != nil {
+		t.Errorf("interactive command without --json: %v", err)
+	}
+}
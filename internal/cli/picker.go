@@ -0,0 +1,221 @@
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/javanstorm/vmterminal/internal/distro"
+	"github.com/javanstorm/vmterminal/internal/terminal"
+)
+
+// errPickerCancelled is returned when the user leaves the picker with Esc
+// or Ctrl+C.
+var errPickerCancelled = errors.New("distro selection cancelled")
+
+// SelectDistro asks the user to choose one of providers, with current as
+// the default. On a terminal it shows an interactive list that is filtered
+// by typing and navigated with the arrow keys; otherwise, as when input is
+// piped, it prints a numbered list and reads the choice from a line of
+// stdin, where an empty or invalid line picks current.
+func SelectDistro(providers []distro.Provider, current distro.ID) (distro.ID, error) {
+	if len(providers) == 0 {
+		return "", fmt.Errorf("no distributions available")
+	}
+	if !terminal.IsTerminal(int(os.Stdin.Fd())) || !terminal.IsTerminal(int(os.Stdout.Fd())) {
+		return selectDistroNumbered(providers, current, os.Stdin, os.Stdout), nil
+	}
+
+	m, err := tea.NewProgram(newPicker(providers, current)).Run()
+	if err != nil {
+		return "", fmt.Errorf("distro picker: %w", err)
+	}
+	p := m.(*picker)
+	if p.cancelled {
+		return "", errPickerCancelled
+	}
+	return p.chosen, nil
+}
+
+// selectDistroNumbered prints providers as a numbered list and reads the
+// choice from in.
+func selectDistroNumbered(providers []distro.Provider, current distro.ID, in io.Reader, out io.Writer) distro.ID {
+	fmt.Fprintln(out, "\nAvailable Linux distributions:")
+	def := 1
+	for i, p := range providers {
+		marker := ""
+		if p.ID() == current {
+			marker = " (default)"
+			def = i + 1
+		}
+		fmt.Fprintf(out, "  %d. %s %s%s\n", i+1, p.Name(), p.Version(), marker)
+	}
+
+	fmt.Fprintf(out, "\nSelect distro [%d]: ", def)
+	input, _ := bufio.NewReader(in).ReadString('\n')
+	var choice int
+	if _, err := fmt.Sscanf(strings.TrimSpace(input), "%d", &choice); err != nil || choice < 1 || choice > len(providers) {
+		return providers[def-1].ID()
+	}
+	return providers[choice-1].ID()
+}
+
+// pickerItem is one row of the picker: the distro and its two columns.
+type pickerItem struct {
+	id     distro.ID
+	label  string // Name and version
+	detail string // Supported architectures
+}
+
+// picker is the interactive distro list, run as a bubbletea program.
+type picker struct {
+	items  []pickerItem
+	filter string
+	cursor int // Index into visible()
+
+	chosen    distro.ID // Set when the user presses Enter
+	cancelled bool      // Set when the user presses Esc or Ctrl+C
+}
+
+func newPicker(providers []distro.Provider, current distro.ID) *picker {
+	p := &picker{}
+	host := distro.CurrentArch()
+	for i, prov := range providers {
+		archs := make([]string, 0, len(prov.SupportedArchs()))
+		for _, a := range prov.SupportedArchs() {
+			archs = append(archs, string(a))
+		}
+		detail := strings.Join(archs, ", ")
+		if !prov.SupportsArch(host) {
+			detail += " (not on this host)"
+		}
+		p.items = append(p.items, pickerItem{
+			id:     prov.ID(),
+			label:  prov.Name() + " " + prov.Version(),
+			detail: detail,
+		})
+		if prov.ID() == current {
+			p.cursor = i
+		}
+	}
+	return p
+}
+
+// visible returns the indexes of the items matching the filter.
+func (p *picker) visible() []int {
+	var out []int
+	for i, it := range p.items {
+		if fuzzyMatch(p.filter, string(it.id)+" "+it.label) {
+			out = append(out, i)
+		}
+	}
+	return out
+}
+
+// fuzzyMatch reports whether the characters of pattern appear in s in
+// order, ignoring case, so "dbn" matches "Debian".
+func fuzzyMatch(pattern, s string) bool {
+	s = strings.ToLower(s)
+	for _, r := range strings.ToLower(pattern) {
+		i := strings.IndexRune(s, r)
+		if i < 0 {
+			return false
+		}
+		s = s[i+utf8.RuneLen(r):]
+	}
+	return true
+}
+
+// Init implements tea.Model.
+func (p *picker) Init() tea.Cmd {
+	return nil
+}
+
+// Update implements tea.Model, applying a key press.
+func (p *picker) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	key, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return p, nil
+	}
+	n := len(p.visible())
+	switch key.Type {
+	case tea.KeyUp, tea.KeyCtrlP:
+		if p.cursor > 0 {
+			p.cursor--
+		}
+	case tea.KeyDown, tea.KeyCtrlN:
+		if p.cursor < n-1 {
+			p.cursor++
+		}
+	case tea.KeyEnter:
+		if id, ok := p.selected(); ok {
+			p.chosen = id
+			return p, tea.Quit
+		}
+	case tea.KeyEsc, tea.KeyCtrlC:
+		p.cancelled = true
+		return p, tea.Quit
+	case tea.KeyBackspace:
+		if p.filter != "" {
+			_, size := utf8.DecodeLastRuneInString(p.filter)
+			p.filter = p.filter[:len(p.filter)-size]
+			p.cursor = 0
+		}
+	case tea.KeyRunes, tea.KeySpace:
+		p.filter += string(key.Runes)
+		p.cursor = 0
+	}
+	return p, nil
+}
+
+// selected returns the highlighted distro, if any item matches the filter.
+func (p *picker) selected() (distro.ID, bool) {
+	vis := p.visible()
+	if len(vis) == 0 {
+		return "", false
+	}
+	return p.items[vis[p.cursor]].id, true
+}
+
+// pickerSelectedStyle highlights the selected row.
+var pickerSelectedStyle = lipgloss.NewStyle().Reverse(true)
+
+// View implements tea.Model, drawing the list in two columns: name and
+// version, then supported architectures. It draws nothing once the user
+// has chosen or cancelled, so the list does not stay on screen.
+func (p *picker) View() string {
+	if p.chosen != "" || p.cancelled {
+		return ""
+	}
+
+	width := 0
+	for _, it := range p.items {
+		width = max(width, utf8.RuneCountInString(it.label))
+	}
+
+	var b strings.Builder
+	b.WriteString("Select a Linux distribution (type to filter, ↑/↓ to move, Enter to select, Esc to cancel)\n")
+	fmt.Fprintf(&b, "Filter: %s\n", p.filter)
+
+	vis := p.visible()
+	if len(vis) == 0 {
+		b.WriteString("  No matching distributions\n")
+		return b.String()
+	}
+	for row, i := range vis {
+		it := p.items[i]
+		line := fmt.Sprintf("%s%s  %s", it.label, strings.Repeat(" ", width-utf8.RuneCountInString(it.label)), it.detail)
+		if row == p.cursor {
+			fmt.Fprintf(&b, "> %s\n", pickerSelectedStyle.Render(line))
+		} else {
+			fmt.Fprintf(&b, "  %s\n", line)
+		}
+	}
+	return b.String()
+}
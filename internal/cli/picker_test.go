@@ -0,0 +1,126 @@
+package cli
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/javanstorm/vmterminal/internal/distro"
+)
+
+// testProviders returns the Alpine, Debian and Ubuntu providers.
+func testProviders(t *testing.T) []distro.Provider {
+	t.Helper()
+	var providers []distro.Provider
+	for _, id := range []distro.ID{distro.Alpine, distro.Debian, distro.Ubuntu} {
+		p, err := distro.Get(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		providers = append(providers, p)
+	}
+	return providers
+}
+
+func TestFuzzyMatch(t *testing.T) {
+	for _, tc := range []struct {
+		pattern, s string
+		want       bool
+	}{
+		{"", "Debian 12", true},
+		{"deb", "Debian 12", true},
+		{"DBN", "debian", true},
+		{"d12", "Debian 12", true},
+		{"bd", "Debian", false},
+		{"ubuntu", "Debian", false},
+	} {
+		if got := fuzzyMatch(tc.pattern, tc.s); got != tc.want {
+			t.Errorf("fuzzyMatch(%q, %q) = %v, want %v", tc.pattern, tc.s, got, tc.want)
+		}
+	}
+}
+
+// pickerKeys sends keys to p as a bubbletea program would and returns
+// the command of the last one.
+func pickerKeys(p *picker, keys ...tea.KeyMsg) tea.Cmd {
+	var cmd tea.Cmd
+	for _, k := range keys {
+		_, cmd = p.Update(k)
+	}
+	return cmd
+}
+
+// typed returns the key messages for typing s.
+func typed(s string) []tea.KeyMsg {
+	var keys []tea.KeyMsg
+	for _, r := range s {
+		keys = append(keys, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+	}
+	return keys
+}
+
+func TestPicker(t *testing.T) {
+	enter := tea.KeyMsg{Type: tea.KeyEnter}
+	backspace := tea.KeyMsg{Type: tea.KeyBackspace}
+
+	// Starts on the current distro, moves down one
+	p := newPicker(testProviders(t), distro.Alpine)
+	// Two columns: name and version, then architectures
+	if view := p.View(); !strings.Contains(view, "amd64, arm64") || !strings.Contains(view, "> Alpine") {
+		t.Errorf("unexpected rendering:\n%s", view)
+	}
+	if cmd := pickerKeys(p, tea.KeyMsg{Type: tea.KeyDown}, enter); cmd == nil || p.chosen != distro.Debian {
+		t.Errorf("arrow down = %q, want debian", p.chosen)
+	}
+	if p.View() != "" {
+		t.Errorf("list still drawn after choosing:\n%s", p.View())
+	}
+
+	// Typing filters; the cursor moves to the first match
+	p = newPicker(testProviders(t), distro.Alpine)
+	pickerKeys(p, append(typed("ubu"), enter)...)
+	if p.chosen != distro.Ubuntu {
+		t.Errorf("filter = %q, want ubuntu", p.chosen)
+	}
+
+	// Enter does nothing while nothing matches
+	p = newPicker(testProviders(t), distro.Alpine)
+	if cmd := pickerKeys(p, append(typed("zz"), enter)...); cmd != nil || p.chosen != "" {
+		t.Errorf("Enter with no match chose %q", p.chosen)
+	}
+	if !strings.Contains(p.View(), "No matching distributions") {
+		t.Errorf("unexpected rendering:\n%s", p.View())
+	}
+	pickerKeys(p, backspace, backspace, enter)
+	if p.chosen != distro.Alpine {
+		t.Errorf("after clearing the filter = %q, want alpine", p.chosen)
+	}
+
+	p = newPicker(testProviders(t), distro.Alpine)
+	if cmd := pickerKeys(p, tea.KeyMsg{Type: tea.KeyCtrlC}); cmd == nil || !p.cancelled {
+		t.Error("Ctrl+C did not cancel")
+	}
+}
+
+func TestSelectDistroNumbered(t *testing.T) {
+	providers := testProviders(t)
+	for _, tc := range []struct {
+		input string
+		want  distro.ID
+	}{
+		{"3\n", distro.Ubuntu},
+		{"\n", distro.Debian},
+		{"", distro.Debian},
+		{"debain\n", distro.Debian},
+		{"9\n", distro.Debian},
+	} {
+		var out bytes.Buffer
+		if got := selectDistroNumbered(providers, distro.Debian, strings.NewReader(tc.input), &out); got != tc.want {
+			t.Errorf("input %q = %q, want %q", tc.input, got, tc.want)
+		}
+		if !strings.Contains(out.String(), "2. Debian") || !strings.Contains(out.String(), "[2]") {
+			t.Errorf("unexpected list:\n%s", out.String())
+		}
+	}
+}
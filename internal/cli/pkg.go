@@ -0,0 +1,185 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/javanstorm/vmterminal/internal/distro"
+	"github.com/javanstorm/vmterminal/internal/vm"
+	"github.com/spf13/cobra"
+)
+
+var pkgVM string
+
+var pkgCmd = &cobra.Command{
+	Use:   "pkg",
+	Short: "Manage packages inside the VM",
+	Long: `Run the VM distribution's package manager over SSH.
+
+The package manager is chosen from the VM's distro: apk on Alpine,
+apt on Debian and Ubuntu, dnf on Rocky, pacman on Arch, zypper on
+openSUSE, and xbps on Void.
+
+Examples:
+  vmterminal pkg install git vim curl
+  vmterminal pkg search ripgrep
+  vmterminal pkg upgrade`,
+}
+
+var pkgInstallCmd = &cobra.Command{
+	Use:   "install <packages...>",
+	Short: "Install packages",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runSSHApkCommand(func(pm vm.PackageManager) []string { return pm.Install(args...) })
+	},
+}
+
+var pkgRemoveCmd = &cobra.Command{
+	Use:   "remove <packages...>",
+	Short: "Remove packages",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runSSHApkCommand(func(pm vm.PackageManager) []string { return pm.Remove(args...) })
+	},
+}
+
+var pkgSearchCmd = &cobra.Command{
+	Use:   "search <query>",
+	Short: "Search for packages",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runSSHApkCommand(func(pm vm.PackageManager) []string { return pm.Search(args[0]) })
+	},
+}
+
+var pkgUpdateCmd = &cobra.Command{
+	Use:   "update",
+	Short: "Update the package index",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runSSHApkCommand(vm.PackageManager.Update)
+	},
+}
+
+var pkgUpgradeCmd = &cobra.Command{
+	Use:   "upgrade",
+	Short: "Upgrade all installed packages",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runSSHApkCommand(vm.PackageManager.Upgrade)
+	},
+}
+
+var pkgListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List installed packages",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runSSHApkCommand(vm.PackageManager.List)
+	},
+}
+
+func init() {
+	pkgCmd.PersistentFlags().StringVar(&pkgVM, "vm", "", "VM to target (default: active VM)")
+
+	pkgCmd.AddCommand(pkgInstallCmd)
+	pkgCmd.AddCommand(pkgRemoveCmd)
+	pkgCmd.AddCommand(pkgSearchCmd)
+	pkgCmd.AddCommand(pkgUpdateCmd)
+	pkgCmd.AddCommand(pkgUpgradeCmd)
+	pkgCmd.AddCommand(pkgListCmd)
+	noJSON(pkgCmd.Commands()...)
+}
+
+// shellQuote quotes s for the remote shell that ssh hands the command to.
+func shellQuote(s string) string {
+	if s != "" && strings.IndexFunc(s, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_.+:/=@", r))
+	}) < 0 {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// pkgDetectTimeout bounds the package manager detection in the VM.
+const pkgDetectTimeout = 10 * time.Second
+
+// packageManagerFor returns the package manager recorded in the target
+// VM's state.json. If none is recorded it asks the VM, recording the answer,
+// and falls back to the one of the VM's distro in the registry if the VM
+// cannot be asked. cached reports whether the recorded one was used.
+func packageManagerFor(target *sshTarget) (pm vm.PackageManager, cached bool) {
+	state, err := vm.NewStateFile(target.DataDir).Load()
+	if err == nil {
+		if pm, ok := vm.PackageManagerNamed(state.PackageManager); ok {
+			return pm, true
+		}
+	}
+
+	if pm, err := detectPackageManager(target); err == nil {
+		return pm, false
+	}
+	return vm.DetectPackageManager(distro.ID(target.State.Distro)), false
+}
+
+// detectPackageManager asks the target VM which package manager it has
+// and records it in the VM's state.json.
+func detectPackageManager(target *sshTarget) (vm.PackageManager, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), pkgDetectTimeout)
+	defer cancel()
+	pm, err := vm.DetectPackageManagerInVM(ctx, vm.SSHConfig{
+		Port:    target.State.SSHHostPort,
+		Socket:  target.VsockSocket,
+		KeyPath: target.KeyPath,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	stateFile := vm.NewStateFile(target.DataDir)
+	if state, err := stateFile.Load(); err != nil || state.PackageManager != pm.Name() {
+		if err := stateFile.RecordPackageManager(pm.Name()); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: could not record package manager: %v\n", err)
+		}
+	}
+	return pm, nil
+}
+
+// runSSHApkCommand runs a package manager command in the VM over SSH,
+// using the package manager recorded for the VM. If the command fails, the
+// VM is asked again in case its package manager changed, and the command
+// is retried with the new one.
+func runSSHApkCommand(build func(vm.PackageManager) []string) error {
+	target, err := resolveSSHTarget(pkgVM)
+	if err != nil {
+		return err
+	}
+
+	pm, cached := packageManagerFor(target)
+	err = runPkgOverSSH(target, build(pm))
+	if err != nil && cached {
+		if fresh, detectErr := detectPackageManager(target); detectErr == nil && fresh.Name() != pm.Name() {
+			pm = fresh
+			err = runPkgOverSSH(target, build(pm))
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", pm.Name(), err)
+	}
+	return nil
+}
+
+// runPkgOverSSH runs the package manager argv remote in the target VM.
+func runPkgOverSSH(target *sshTarget, remote []string) error {
+	quoted := make([]string, len(remote))
+	for i, arg := range remote {
+		quoted[i] = shellQuote(arg)
+	}
+
+	sshArgs := append(target.sshOptions("-p"), sshRemote, strings.Join(quoted, " "))
+	return execInteractive("ssh", sshArgs)
+}
@@ -0,0 +1,43 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/javanstorm/vmterminal/internal/config"
+	"github.com/javanstorm/vmterminal/internal/vm"
+)
+
+func TestShellQuote(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"git", "git"},
+		{"--noconfirm", "--noconfirm"},
+		{"py3-pip", "py3-pip"},
+		{"", "''"},
+		{"a b", "'a b'"},
+		{"x;rm -rf /", "'x;rm -rf /'"},
+		{"it's", `'it'\''s'`},
+	}
+
+	for _, tt := range tests {
+		if got := shellQuote(tt.in); got != tt.want {
+			t.Errorf("shellQuote(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestPackageManagerForCached(t *testing.T) {
+	dataDir := t.TempDir()
+	if err := vm.NewStateFile(dataDir).RecordPackageManager("pacman"); err != nil {
+		t.Fatalf("RecordPackageManager: %v", err)
+	}
+
+	// The recorded one wins over the distro's without asking the VM
+	target := &sshTarget{State: &config.State{Distro: "alpine"}, DataDir: dataDir}
+	pm, cached := packageManagerFor(target)
+	if pm.Name() != "pacman" || !cached {
+		t.Errorf("packageManagerFor = %s, cached %v; want pacman, cached true", pm.Name(), cached)
+	}
+}
@@ -0,0 +1,197 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/javanstorm/vmterminal/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var portForwardCmd = &cobra.Command{
+	Use:   "port-forward",
+	Short: "Manage host-to-guest port forwarding",
+	Long: `Manage port forwarding rules from the host to the VM.
+
+Rules are stored in the global config and applied on the next VM start.
+The SSH forward is configured separately with 'vmterminal config'.`,
+}
+
+var portForwardAddCmd = &cobra.Command{
+	Use:   "add <host-port>:<guest-port>[/tcp|/udp]",
+	Short: "Add a port forwarding rule",
+	Long: `Add a port forwarding rule. The protocol defaults to tcp.
+
+Examples:
+  vmterminal port-forward add 8080:80
+  vmterminal port-forward add 5353:53/udp`,
+	Args: cobra.ExactArgs(1),
+	RunE: runPortForwardAdd,
+}
+
+var portForwardRemoveCmd = &cobra.Command{
+	Use:   "remove <host-port>[/tcp|/udp]",
+	Short: "Remove a port forwarding rule",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runPortForwardRemove,
+}
+
+var portForwardListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List port forwarding rules",
+	RunE:  runPortForwardList,
+}
+
+func init() {
+	portForwardCmd.AddCommand(portForwardAddCmd)
+	portForwardCmd.AddCommand(portForwardRemoveCmd)
+	portForwardCmd.AddCommand(portForwardListCmd)
+}
+
+// parsePort parses a port number in the range 1-65535.
+func parsePort(s string) (int, error) {
+	port, err := strconv.Atoi(s)
+	if err != nil || port < 1 || port > 65535 {
+		return 0, fmt.Errorf("invalid port %q (must be 1-65535)", s)
+	}
+	return port, nil
+}
+
+// splitProto splits an optional /tcp or /udp suffix from spec.
+func splitProto(spec string) (string, string, error) {
+	rest, proto, found := strings.Cut(spec, "/")
+	if !found {
+		return spec, "tcp", nil
+	}
+	proto = strings.ToLower(proto)
+	if proto != "tcp" && proto != "udp" {
+		return "", "", fmt.Errorf("invalid protocol %q (must be tcp or udp)", proto)
+	}
+	return rest, proto, nil
+}
+
+// parsePortForwardRule parses host:guest[/proto].
+func parsePortForwardRule(spec string) (config.PortForwardRule, error) {
+	ports, proto, err := splitProto(spec)
+	if err != nil {
+		return config.PortForwardRule{}, err
+	}
+
+	hostStr, guestStr, found := strings.Cut(ports, ":")
+	if !found {
+		return config.PortForwardRule{}, fmt.Errorf("invalid rule %q (expected <host-port>:<guest-port>)", spec)
+	}
+
+	host, err := parsePort(hostStr)
+	if err != nil {
+		return config.PortForwardRule{}, err
+	}
+	guest, err := parsePort(guestStr)
+	if err != nil {
+		return config.PortForwardRule{}, err
+	}
+
+	return config.PortForwardRule{Host: host, Guest: guest, Proto: proto}, nil
+}
+
+// portForwardResult is the structured output of port-forward add/remove.
+type portForwardResult struct {
+	Rule   string `json:"rule"`
+	Action string `json:"action"` // added, removed
+}
+
+// RenderHuman prints the change as text.
+func (r *portForwardResult) RenderHuman(w io.Writer) {
+	fmt.Fprintf(w, "Port forward %s %s.\n", r.Rule, r.Action)
+	fmt.Fprintln(w, "Restart the VM to apply changes.")
+}
+
+// portForwardListResult is the structured output of port-forward list.
+type portForwardListResult struct {
+	SSHHostPort int                      `json:"ssh_host_port"`
+	Rules       []config.PortForwardRule `json:"rules"`
+}
+
+// RenderHuman prints the rules as text.
+func (r *portForwardListResult) RenderHuman(w io.Writer) {
+	if r.SSHHostPort == 0 && len(r.Rules) == 0 {
+		fmt.Fprintln(w, "No port forwarding rules. Add one with: vmterminal port-forward add <host>:<guest>")
+		return
+	}
+
+	fmt.Fprintln(w, "Port forwarding rules:")
+	if r.SSHHostPort > 0 {
+		fmt.Fprintf(w, "  %d:22/tcp (ssh)\n", r.SSHHostPort)
+	}
+	for _, rule := range r.Rules {
+		fmt.Fprintf(w, "  %s\n", rule)
+	}
+	if len(r.Rules) == 0 {
+		fmt.Fprintln(w, "No additional port forwarding rules. Add one with: vmterminal port-forward add <host>:<guest>")
+	}
+}
+
+func runPortForwardAdd(cmd *cobra.Command, args []string) error {
+	rule, err := parsePortForwardRule(args[0])
+	if err != nil {
+		return err
+	}
+
+	cfg, err := config.LoadSavedState()
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+
+	cfg.PortForwards = append(cfg.PortForwards, rule)
+	if err := config.ValidateState(cfg); err != nil {
+		return err
+	}
+	if err := config.SaveState(cfg); err != nil {
+		return fmt.Errorf("save config: %w", err)
+	}
+
+	return printResult(&portForwardResult{Rule: rule.String(), Action: "added"})
+}
+
+func runPortForwardRemove(cmd *cobra.Command, args []string) error {
+	hostStr, proto, err := splitProto(args[0])
+	if err != nil {
+		return err
+	}
+	host, err := parsePort(hostStr)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := config.LoadSavedState()
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+
+	for i, rule := range cfg.PortForwards {
+		if rule.Host == host && rule.Proto == proto {
+			cfg.PortForwards = append(cfg.PortForwards[:i], cfg.PortForwards[i+1:]...)
+			if err := config.SaveState(cfg); err != nil {
+				return fmt.Errorf("save config: %w", err)
+			}
+			return printResult(&portForwardResult{Rule: rule.String(), Action: "removed"})
+		}
+	}
+
+	return fmt.Errorf("no port forward for host port %d/%s", host, proto)
+}
+
+func runPortForwardList(cmd *cobra.Command, args []string) error {
+	cfg, err := config.LoadState()
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+
+	res := &portForwardListResult{SSHHostPort: cfg.SSHHostPort, Rules: cfg.PortForwards}
+	if res.Rules == nil {
+		res.Rules = []config.PortForwardRule{}
+	}
+	return printResult(res)
+}
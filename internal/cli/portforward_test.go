@@ -0,0 +1,91 @@
+package cli
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/javanstorm/vmterminal/internal/config"
+)
+
+func TestParsePortForwardRule(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    string
+		wantErr bool
+	}{
+		{"8080:80", "8080:80/tcp", false},
+		{"5353:53/udp", "5353:53/udp", false},
+		{"443:8443/TCP", "443:8443/tcp", false},
+		{"8080", "", true},
+		{"0:80", "", true},
+		{"8080:70000", "", true},
+		{"8080:80/sctp", "", true},
+		{"abc:80", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			rule, err := parsePortForwardRule(tt.spec)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected error for %q, got %s", tt.spec, rule)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parsePortForwardRule(%q): %v", tt.spec, err)
+			}
+			if rule.String() != tt.want {
+				t.Errorf("parsePortForwardRule(%q) = %s, want %s", tt.spec, rule, tt.want)
+			}
+		})
+	}
+}
+
+func TestPortForwardListRenderHuman(t *testing.T) {
+	rule := config.PortForwardRule{Host: 8080, Guest: 80, Proto: "tcp"}
+	tests := []struct {
+		name    string
+		res     portForwardListResult
+		want    []string
+		notWant []string
+	}{
+		{
+			name:    "none",
+			res:     portForwardListResult{},
+			want:    []string{"No port forwarding rules."},
+			notWant: []string{"Port forwarding rules:"},
+		},
+		{
+			name:    "ssh only",
+			res:     portForwardListResult{SSHHostPort: 2222},
+			want:    []string{"Port forwarding rules:", "2222:22/tcp (ssh)", "No additional port forwarding rules."},
+			notWant: []string{"No port forwarding rules."},
+		},
+		{
+			name:    "ssh and rules",
+			res:     portForwardListResult{SSHHostPort: 2222, Rules: []config.PortForwardRule{rule}},
+			want:    []string{"Port forwarding rules:", "2222:22/tcp (ssh)", "8080:80/tcp"},
+			notWant: []string{"No port forwarding rules.", "No additional"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			tt.res.RenderHuman(&buf)
+			out := buf.String()
+			for _, s := range tt.want {
+				if !strings.Contains(out, s) {
+					t.Errorf("output missing %q:\n%s", s, out)
+				}
+			}
+			for _, s := range tt.notWant {
+				if strings.Contains(out, s) {
+					t.Errorf("output contains %q:\n%s", s, out)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,23 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// processRunsExecutable reports whether process pid is running the binary
+// at exe. It returns true when this cannot be determined.
+func processRunsExecutable(pid int, exe string) bool {
+	actual, err := os.Readlink(fmt.Sprintf("/proc/%d/exe", pid))
+	if err != nil {
+		return true
+	}
+	// The binary may have been replaced by an upgrade while the VM runs
+	actual = strings.TrimSuffix(actual, " (deleted)")
+	if resolved, err := filepath.EvalSymlinks(exe); err == nil {
+		exe = resolved
+	}
+	return actual == exe
+}
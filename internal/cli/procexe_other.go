@@ -0,0 +1,25 @@
+//go:build !linux
+
+package cli
+
+import (
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// processRunsExecutable reports whether process pid is running the binary
+// at exe. Without /proc only the command name can be compared. It returns
+// true when this cannot be determined.
+func processRunsExecutable(pid int, exe string) bool {
+	out, err := exec.Command("ps", "-o", "comm=", "-p", strconv.Itoa(pid)).Output()
+	if err != nil {
+		return true
+	}
+	comm := strings.TrimSpace(string(out))
+	if comm == "" {
+		return true
+	}
+	return filepath.Base(comm) == filepath.Base(exe)
+}
@@ -21,6 +21,10 @@ to apply configuration changes.`,
 	RunE: runReload,
 }
 
+func init() {
+	noJSON(reloadCmd)
+}
+
 func runReload(cmd *cobra.Command, args []string) error {
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
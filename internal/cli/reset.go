@@ -2,6 +2,7 @@ package cli
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"syscall"
@@ -28,20 +29,58 @@ Examples:
 	RunE: runReset,
 }
 
-var resetAll bool
+var (
+	resetAll bool
+	resetVM  string
+)
 
 func init() {
 	resetCmd.Flags().BoolVar(&resetAll, "all", false, "Reset all distros and data")
+	resetCmd.Flags().StringVar(&resetVM, "vm", "", "VM to reset (default: active VM)")
+}
+
+// resetResult is the outcome of 'vmterminal reset'.
+type resetResult struct {
+	VM           string `json:"vm"`
+	KilledPID    int    `json:"killed_pid,omitempty"`
+	ClearedCache string `json:"cleared_cache,omitempty"` // "all" or a distro ID
+	RemovedDisk  bool   `json:"removed_disk"`
+	Distro       string `json:"distro,omitempty"`
+}
+
+// RenderHuman prints the reset outcome as text.
+func (r *resetResult) RenderHuman(w io.Writer) {
+	if r.KilledPID != 0 {
+		fmt.Fprintf(w, "Killed VM (PID %d)\n", r.KilledPID)
+	}
+	switch r.ClearedCache {
+	case "":
+	case "all":
+		fmt.Fprintln(w, "Cleared all cached assets")
+	default:
+		fmt.Fprintf(w, "Cleared cache for %s\n", r.ClearedCache)
+	}
+	if r.RemovedDisk {
+		fmt.Fprintln(w, "Removed disk image")
+	}
+	fmt.Fprintln(w, "\nReset complete. Run 'vmt run' to start fresh.")
+	if r.Distro != "" {
+		fmt.Fprintf(w, "Tip: vmt run --distro %s\n", r.Distro)
+	}
 }
 
 func runReset(cmd *cobra.Command, args []string) error {
-	homeDir, err := os.UserHomeDir()
+	baseDir, err := baseDirectory()
 	if err != nil {
-		return fmt.Errorf("get home dir: %w", err)
+		return err
 	}
-	baseDir := filepath.Join(homeDir, ".vmterminal")
-	dataDir := filepath.Join(baseDir, "data", "default")
+	vmName, _, err := resolveVM(baseDir, resetVM)
+	if err != nil {
+		return err
+	}
+	dataDir := filepath.Join(baseDir, "data", vmName)
 	cacheDir := filepath.Join(baseDir, "cache")
+	result := &resetResult{VM: vmName}
 
 	// Step 1: Kill any running VM
 	pidFile := filepath.Join(dataDir, "vm.pid")
@@ -50,8 +89,9 @@ func runReset(cmd *cobra.Command, args []string) error {
 		if _, err := fmt.Sscanf(string(data), "%d", &pid); err == nil {
 			if process, err := os.FindProcess(pid); err == nil {
 				if err := process.Signal(syscall.Signal(0)); err == nil {
-					fmt.Printf("Killing VM (PID %d)...\n", pid)
+					progressf("Killing VM (PID %d)...\n", pid)
 					process.Signal(syscall.SIGKILL)
+					result.KilledPID = pid
 					time.Sleep(500 * time.Millisecond)
 				}
 			}
@@ -68,7 +108,7 @@ func runReset(cmd *cobra.Command, args []string) error {
 			if err := os.RemoveAll(cacheDir); err != nil {
 				return fmt.Errorf("clear cache: %w", err)
 			}
-			fmt.Println("Cleared all cached assets")
+			result.ClearedCache = "all"
 		}
 	} else if len(args) > 0 {
 		// Clear specific distro cache
@@ -81,7 +121,7 @@ func runReset(cmd *cobra.Command, args []string) error {
 			if err := os.RemoveAll(targetDir); err != nil {
 				return fmt.Errorf("clear %s cache: %w", distroID, err)
 			}
-			fmt.Printf("Cleared cache for %s\n", distroID)
+			result.ClearedCache = distroID
 		}
 	}
 
@@ -91,7 +131,7 @@ func runReset(cmd *cobra.Command, args []string) error {
 		if err := os.Remove(diskPath); err != nil {
 			return fmt.Errorf("remove disk: %w", err)
 		}
-		fmt.Println("Removed disk image")
+		result.RemovedDisk = true
 	}
 
 	// Step 4: Reset state file
@@ -100,10 +140,8 @@ func runReset(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("remove state: %w", err)
 	}
 
-	fmt.Println("\nReset complete. Run 'vmt run' to start fresh.")
 	if len(args) > 0 {
-		fmt.Printf("Tip: vmt run --distro %s\n", args[0])
+		result.Distro = args[0]
 	}
-
-	return nil
+	return printResult(result)
 }
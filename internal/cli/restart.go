@@ -0,0 +1,80 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/javanstorm/vmterminal/internal/vm"
+)
+
+// restartWindow is the period --max-restarts is counted over.
+const restartWindow = 60 * time.Second
+
+// panicScanBytes is how much trailing console output is kept to look for
+// a kernel panic once the VM exits.
+const panicScanBytes = 64 * 1024
+
+// restartLimiter caps automatic restarts to max within a sliding window,
+// so a VM that panics on every boot does not restart forever.
+type restartLimiter struct {
+	max    int
+	window time.Duration
+	times  []time.Time
+}
+
+// newRestartLimiter creates a limiter allowing max restarts per window.
+func newRestartLimiter(max int, window time.Duration) *restartLimiter {
+	return &restartLimiter{max: max, window: window}
+}
+
+// Allow records a restart at now and reports whether it is within the limit.
+func (l *restartLimiter) Allow(now time.Time) bool {
+	recent := l.times[:0]
+	for _, t := range l.times {
+		if now.Sub(t) < l.window {
+			recent = append(recent, t)
+		}
+	}
+	l.times = recent
+
+	if len(l.times) >= l.max {
+		return false
+	}
+	l.times = append(l.times, now)
+	return true
+}
+
+// waitRestartDelay sleeps for d before a restart. It returns false if
+// SIGINT or SIGTERM arrives first.
+func waitRestartDelay(d time.Duration) bool {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigCh)
+
+	select {
+	case <-time.After(d):
+		return true
+	case <-sigCh:
+		return false
+	}
+}
+
+// bootVM creates, prepares, and starts a fresh VM from cfg. Drivers cannot
+// be reused once a VM has exited, so every restart needs a new manager.
+func bootVM(ctx context.Context, cfg vm.ManagerConfig) (*vm.Manager, error) {
+	mgr, err := vm.NewManager(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("create manager: %w", err)
+	}
+	if err := mgr.Prepare(ctx); err != nil {
+		return nil, fmt.Errorf("prepare VM: %w", err)
+	}
+	if err := mgr.Start(ctx); err != nil {
+		return nil, fmt.Errorf("start VM: %w", err)
+	}
+	return mgr, nil
+}
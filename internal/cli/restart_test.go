@@ -0,0 +1,28 @@
+package cli
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRestartLimiter(t *testing.T) {
+	start := time.Date(2026, 1, 23, 10, 0, 0, 0, time.UTC)
+	l := newRestartLimiter(3, time.Minute)
+
+	for i := 0; i < 3; i++ {
+		if !l.Allow(start.Add(time.Duration(i) * 10 * time.Second)) {
+			t.Fatalf("restart %d should be allowed", i+1)
+		}
+	}
+	if l.Allow(start.Add(30 * time.Second)) {
+		t.Error("fourth restart within the window should be refused")
+	}
+
+	// Once the first restart leaves the window, one more is allowed
+	if !l.Allow(start.Add(61 * time.Second)) {
+		t.Error("restart after the window slides should be allowed")
+	}
+	if l.Allow(start.Add(62 * time.Second)) {
+		t.Error("window should be full again")
+	}
+}
@@ -4,6 +4,7 @@ package cli
 import (
 	"fmt"
 
+	"github.com/javanstorm/vmterminal/internal/config"
 	"github.com/spf13/cobra"
 )
 
@@ -18,12 +19,42 @@ No separate setup steps, no static config files.
 Just run 'vmterminal' or 'vmterminal run --distro arch' and it does everything.`,
 	SilenceUsage:  true,
 	SilenceErrors: true,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := checkJSONSupported(cmd); err != nil {
+			return err
+		}
+		// Machine-readable output implies no progress chatter
+		if jsonOutput {
+			SetQuietMode(true)
+		}
+		return nil
+	},
 	// When run without subcommand, execute 'run'
 	RunE: func(cmd *cobra.Command, args []string) error {
 		return runRun(cmd, args)
 	},
 }
 
+// ExitCodeError makes the process exit with Code without printing an
+// error, e.g. to pass on the exit status of a command run in the VM.
+type ExitCodeError struct {
+	Code int
+}
+
+func (e *ExitCodeError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
+// baseDirectory returns the data directory: ~/.vmterminal unless data_dir
+// or VMT_DATA_DIR moves it.
+func baseDirectory() (string, error) {
+	paths, err := config.GetPaths()
+	if err != nil {
+		return "", fmt.Errorf("get data dir: %w", err)
+	}
+	return paths.DataDir, nil
+}
+
 // Execute runs the root command.
 func Execute() error {
 	if err := rootCmd.Execute(); err != nil {
@@ -33,15 +64,40 @@ func Execute() error {
 }
 
 func init() {
+	rootCmd.PersistentFlags().BoolVar(&jsonOutput, "json", false, "Print machine-readable JSON output")
+	rootCmd.PersistentFlags().BoolVar(&skipVerify, "skip-verify", false, "Skip OpenPGP signature checks on downloaded distro assets")
+
 	// Add subcommands
 	rootCmd.AddCommand(runCmd)
+	rootCmd.AddCommand(shellCmd)
+	rootCmd.AddCommand(setupCmd)
 	rootCmd.AddCommand(stopCmd)
+	rootCmd.AddCommand(startGroupCmd)
+	rootCmd.AddCommand(stopGroupCmd)
+	rootCmd.AddCommand(logsCmd)
+	rootCmd.AddCommand(suspendCmd)
+	rootCmd.AddCommand(resumeCmd)
 	rootCmd.AddCommand(resetCmd)
 	rootCmd.AddCommand(reloadCmd)
 	rootCmd.AddCommand(switchCmd)
 	rootCmd.AddCommand(statusCmd)
+	rootCmd.AddCommand(listRunningCmd)
+	rootCmd.AddCommand(mirrorCmd)
+	rootCmd.AddCommand(captureCmd)
+	rootCmd.AddCommand(checkCmd)
 	rootCmd.AddCommand(configCmd)
 	rootCmd.AddCommand(snapshotCmd)
 	rootCmd.AddCommand(cacheCmd)
+	rootCmd.AddCommand(portForwardCmd)
+	rootCmd.AddCommand(pkgCmd)
+	rootCmd.AddCommand(cpCmd)
+	rootCmd.AddCommand(sftpCmd)
+	rootCmd.AddCommand(execCmd)
+	rootCmd.AddCommand(tunnelCmd)
+	rootCmd.AddCommand(diskCmd)
+	rootCmd.AddCommand(healthCheckCmd)
+	rootCmd.AddCommand(distroCmd)
 	rootCmd.AddCommand(versionCmd)
+	rootCmd.AddCommand(doctorCmd)
+	rootCmd.AddCommand(diagnoseCmd)
 }
@@ -3,18 +3,26 @@ package cli
 import (
 	"bufio"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
+	"os/signal"
 	"path/filepath"
 	"runtime"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"syscall"
+	"time"
 
 	"github.com/javanstorm/vmterminal/internal/config"
 	"github.com/javanstorm/vmterminal/internal/distro"
 	"github.com/javanstorm/vmterminal/internal/gui"
+	"github.com/javanstorm/vmterminal/internal/terminal"
 	"github.com/javanstorm/vmterminal/internal/timing"
 	"github.com/javanstorm/vmterminal/internal/vm"
 	"github.com/javanstorm/vmterminal/pkg/hypervisor"
@@ -59,14 +67,10 @@ func printlnIfNotQuiet(args ...interface{}) {
 // isVMRunning checks if a VM is already running.
 func isVMRunning(baseDir, vmName string) (bool, int) {
 	pidFile := filepath.Join(baseDir, "data", vmName, "vm.pid")
-	data, err := os.ReadFile(pidFile)
+	pid, exe, err := readPIDFile(pidFile)
 	if err != nil {
 		return false, 0
 	}
-	var pid int
-	if _, err := fmt.Sscanf(string(data), "%d", &pid); err != nil {
-		return false, 0
-	}
 	// Check if process is running
 	process, err := os.FindProcess(pid)
 	if err != nil {
@@ -75,13 +79,41 @@ func isVMRunning(baseDir, vmName string) (bool, int) {
 	if err := process.Signal(syscall.Signal(0)); err != nil {
 		return false, 0
 	}
+	// The PID may have been reused by an unrelated process
+	if exe != "" && !processRunsExecutable(pid, exe) {
+		return false, 0
+	}
 	return true, pid
 }
 
-// writePIDFile creates a PID file for the current process.
+// writePIDFile creates a PID file for the current process. The first line
+// is the PID; the second is the path of the running binary, used to tell a
+// live VM from an unrelated process that reused the PID.
 func writePIDFile(baseDir, vmName string) error {
 	pidFile := filepath.Join(baseDir, "data", vmName, "vm.pid")
-	return os.WriteFile(pidFile, []byte(fmt.Sprintf("%d", os.Getpid())), 0644)
+	content := fmt.Sprintf("%d\n", os.Getpid())
+	if exe, err := os.Executable(); err == nil {
+		content += exe + "\n"
+	}
+	return os.WriteFile(pidFile, []byte(content), 0644)
+}
+
+// readPIDFile parses a PID file written by writePIDFile. exe is empty for
+// PID files from older versions, which only hold the PID.
+func readPIDFile(pidFile string) (pid int, exe string, err error) {
+	data, err := os.ReadFile(pidFile)
+	if err != nil {
+		return 0, "", err
+	}
+	lines := strings.SplitN(strings.TrimSpace(string(data)), "\n", 2)
+	pid, err = strconv.Atoi(strings.TrimSpace(lines[0]))
+	if err != nil {
+		return 0, "", fmt.Errorf("parse PID: %w", err)
+	}
+	if len(lines) == 2 {
+		exe = strings.TrimSpace(lines[1])
+	}
+	return pid, exe, nil
 }
 
 // cleanupPIDFile removes the PID file.
@@ -100,37 +132,333 @@ interactively guide you through the setup process:
 2. Check for optional dependencies (FuseFS)
 3. Download the Linux distribution if needed
 4. Set up filesystem (may require sudo)
-5. Start VM and open GUI terminal window`,
+5. Start VM and open GUI terminal window (or, with --attach, attach the
+   console to this terminal)`,
 	RunE: runRun,
 }
 
-var runDistro string
+var (
+	runVM                string
+	runDistro            string
+	runDistroVersion     string
+	runTapDevice         string
+	runNetworks          []string
+	runConsoleLog        string
+	runConsoleLogMaxSize int
+	runConsoleLogStamps  bool
+	runHeadless          bool
+	runAttach            bool
+	runWait              bool
+	runWaitForNetwork    bool
+	runMetricsAddr       string
+	runCloudInitFile     string
+	runEphemeral         bool
+	runOverlay           bool
+	runOverlaySizeMB     int64
+	runKeepOverlay       bool
+	runAutoRestart       bool
+	runRestartDelay      time.Duration
+	runMaxRestarts       int
+	runDryRun            bool
+	runKernelArgs        []string
+	runMirrors           []string
+	runPcapOut           string
+	runAllowPaste        bool
+	runCacheMaxGB        float64
+)
+
+// downloadLimitKbps is set by --download-limit-kbps on run and switch.
+var downloadLimitKbps int64
+
+// downloadProxy is set by --proxy on run and switch.
+var downloadProxy string
 
 func init() {
+	runCmd.Flags().StringVar(&runVM, "vm", "", "VM to run (default: the active VM)")
 	runCmd.Flags().StringVarP(&runDistro, "distro", "d", "", "Linux distribution to use")
+	runCmd.Flags().StringVar(&runDistroVersion, "distro-version", "", "Pin the distro to this version (e.g. 9.2), overriding version_override")
+	runCmd.Flags().StringVar(&runTapDevice, "tap-device", "", "Tap interface for VM networking on Linux KVM (e.g. tap0)")
+	runCmd.Flags().StringArrayVar(&runNetworks, "network", nil, "Add a network interface: nat or bridge:<iface>, with optional ,mac=<addr> (repeatable)")
+	runCmd.Flags().StringVar(&runConsoleLog, "console-log", "", "Append VM console output to this file")
+	runCmd.Flags().IntVar(&runConsoleLogMaxSize, "console-log-max-size", 10, "Rotate the console log to <path>.1 after this many MB (0 = never)")
+	runCmd.Flags().BoolVar(&runConsoleLogStamps, "console-log-timestamps", false, "Prefix each console log line with the UTC time it was received (for 'logs --since')")
+	runCmd.Flags().BoolVar(&runHeadless, "headless", false, "Run without opening a GUI window")
+	runCmd.Flags().BoolVar(&runAttach, "attach", false, "Attach the VM console to this terminal instead of opening a GUI window")
+	runCmd.Flags().BoolVar(&runAttach, "no-gui", false, "Alias for --attach")
+	runCmd.Flags().BoolVar(&runAllowPaste, "allow-clipboard-paste", true, "Paste the host clipboard into the GUI terminal with Cmd+V (Ctrl+Shift+V on Linux)")
+	runCmd.Flags().BoolVar(&runWait, "wait", false, "With --headless, wait until the VM accepts SSH, then return and leave it running in the background")
+	runCmd.Flags().BoolVar(&runWaitForNetwork, "wait-for-network", false, "With --headless, wait until the guest's network is up, print its IP address, then return and leave the VM running")
+	runCmd.Flags().StringVar(&runMetricsAddr, "metrics-addr", "", "Serve Prometheus metrics on this address (e.g. :9090)")
+	runCmd.Flags().StringVar(&runPcapOut, "pcap-out", "", "Write the VM's network traffic to this pcap file (needs root)")
+	runCmd.Flags().StringVar(&runCloudInitFile, "cloud-init-file", "", "Provision the VM with this cloud-init user-data file")
+	addDownloadLimitFlag(runCmd)
+	addProxyFlag(runCmd)
+	runCmd.Flags().BoolVar(&runEphemeral, "ephemeral", false, "Boot from a throwaway copy of the disk; changes are discarded on exit")
+	runCmd.Flags().BoolVar(&runOverlay, "overlay", false, "Mount the root disk read-only under an overlay that is discarded on the next boot (Ubuntu only)")
+	runCmd.Flags().Int64Var(&runOverlaySizeMB, "overlay-size", vm.DefaultOverlaySizeMB, "Size of the overlay disk in MB (sparse)")
+	runCmd.Flags().BoolVar(&runKeepOverlay, "keep-overlay", false, "With --overlay, keep overlay.raw after the VM stops instead of deleting it")
+	runCmd.Flags().BoolVar(&runAutoRestart, "auto-restart", false, "With --headless, restart the VM when it exits with a kernel panic")
+	runCmd.Flags().DurationVar(&runRestartDelay, "restart-delay", 5*time.Second, "Wait this long before an automatic restart")
+	runCmd.Flags().IntVar(&runMaxRestarts, "max-restarts", 3, "Give up after this many automatic restarts within 60 seconds")
+	runCmd.Flags().BoolVar(&runDryRun, "dry-run", false, "Print the resolved VM configuration as JSON and exit without starting the VM")
+	runCmd.Flags().StringArrayVar(&runKernelArgs, "kernel-arg", nil, "Append an argument to the kernel command line for this boot (repeatable)")
+	runCmd.Flags().Float64Var(&runCacheMaxGB, "cache-max-gb", 0, "Evict the least recently used distro versions from the asset cache beyond this many GB (default: config cache_max_gb, 0 = unlimited)")
+	runCmd.Flags().StringArrayVar(&runMirrors, "mirror", nil, "Download distro assets from this mirror, as [<distro>=]<url>, instead of the configured mirrors (repeatable)")
 }
 
-func runRun(cmd *cobra.Command, args []string) error {
-	// Initialize timing if VMT_TIMING=1
-	var timer *timing.Timer
-	if os.Getenv("VMT_TIMING") == "1" {
-		timer = timing.New()
+// skipVerify is set by the global --skip-verify flag.
+var skipVerify bool
+
+// addDownloadLimitFlag registers --download-limit-kbps on cmd.
+func addDownloadLimitFlag(cmd *cobra.Command) {
+	cmd.Flags().Int64Var(&downloadLimitKbps, "download-limit-kbps", 0, "Limit asset downloads to this many kilobits per second (0 = unlimited)")
+}
+
+// downloadLimit returns --download-limit-kbps in bytes per second.
+func downloadLimit() int64 {
+	return downloadLimitKbps * 1000 / 8
+}
+
+// addProxyFlag registers --proxy on cmd.
+func addProxyFlag(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&downloadProxy, "proxy", "", "Download assets through this HTTP proxy instead of the one in HTTP_PROXY/HTTPS_PROXY")
+}
+
+// newAssetManager returns an asset manager that reports progress and honours
+// --skip-verify, --download-limit-kbps, --proxy and the configured mirrors and cache
+// limit.
+func newAssetManager(cacheDir string, provider distro.Provider) *vm.AssetManager {
+	var configured []string
+	var maxGB float64
+	if cfg, err := config.LoadState(); err == nil {
+		configured = cfg.Mirrors
+		maxGB = cfg.CacheMaxGB
 	}
+	assets := vm.NewAssetManager(cacheDir, provider, newProgress())
+	assets.SetSkipVerify(skipVerify)
+	return assets.WithBandwidthLimit(downloadLimit()).
+		WithMirrors(assetMirrors(configured, provider.ID())).
+		WithProxy(downloadProxy).
+		WithCacheLimit(cacheMaxBytes(maxGB))
+}
 
-	// Load or create config
-	cfg, err := config.LoadState()
+// cacheMaxBytes converts a cache limit in GB to bytes.
+func cacheMaxBytes(gb float64) int64 {
+	return int64(gb * (1 << 30))
+}
+
+// assetMirrors returns the mirror URLs distro id downloads from: those
+// given with --mirror, or else the configured ones.
+func assetMirrors(configured []string, id distro.ID) []string {
+	if len(runMirrors) > 0 {
+		return config.MirrorsFor(runMirrors, id)
+	}
+	return config.MirrorsFor(configured, id)
+}
+
+// ephemeralBaseDisk returns the disk image an ephemeral run copies: the
+// VM's own disk, which for distros that boot a cloud image directly is
+// first copied from the converted image.
+func ephemeralBaseDisk(provider distro.Provider, cacheDir, dataDir string) (string, error) {
+	if reqs := provider.SetupRequirements(); reqs != nil && !reqs.NeedsExtraction {
+		paths, err := newAssetManager(cacheDir, provider).EnsureAssets()
+		if err != nil {
+			return "", fmt.Errorf("ensure assets: %w", err)
+		}
+		if paths.Rootfs != "" {
+			return vm.NewImageManager(dataDir).EnsureImageDisk("disk", paths.Rootfs)
+		}
+	}
+	return filepath.Join(dataDir, "disk.raw"), nil
+}
+
+// vmHostname returns the hostname the VM boots with; an unregistered
+// VM is named after vmName.
+func vmHostname(vmName string, entry *vm.VMEntry) string {
+	if entry == nil {
+		entry = &vm.VMEntry{Name: vmName}
+	}
+	return entry.EffectiveHostname()
+}
+
+// resolveRunVM returns the VM run should start: --vm if given, otherwise
+// the registry's active VM, otherwise "default". entry is nil for an
+// unregistered "default" VM, which has no per-VM overrides.
+func resolveRunVM(baseDir string) (string, *vm.VMEntry, error) {
+	return resolveVM(baseDir, runVM)
+}
+
+// resolveVM returns the VM named by a --vm flag, as resolveRunVM does.
+func resolveVM(baseDir, name string) (string, *vm.VMEntry, error) {
+	if name != "" {
+		entry, err := vm.NewRegistry(baseDir).GetVM(name)
+		if err != nil {
+			if name == "default" {
+				return name, nil, nil
+			}
+			return "", nil, err
+		}
+		return name, entry, nil
+	}
+	if entry := activeVMEntry(baseDir); entry != nil {
+		return entry.Name, entry, nil
+	}
+	return "default", nil, nil
+}
+
+// vmDisks returns the data disks of entry, which may be nil.
+func vmDisks(entry *vm.VMEntry) []vm.DiskConfig {
+	if entry == nil {
+		return nil
+	}
+	return entry.Disks
+}
+
+// kernelArgsFor returns the kernel arguments stored for the VM followed by
+// extra from --kernel-arg, which replace stored ones with the same key.
+// entry may be nil.
+func kernelArgsFor(entry *vm.VMEntry, extra []string) ([]string, error) {
+	var args []string
+	if entry != nil {
+		args = append(args, entry.KernelArgs...)
+	}
+	for _, arg := range extra {
+		if err := vm.ValidateKernelArg(arg); err != nil {
+			return nil, err
+		}
+		args = vm.SetKernelArg(args, arg)
+	}
+	return args, nil
+}
+
+// withPanicReboot adds panic=1 to args unless they set panic= already. A
+// panicked kernel otherwise hangs forever instead of rebooting, so the VM
+// never exits and --auto-restart never sees the panic.
+func withPanicReboot(args []string) []string {
+	for _, a := range args {
+		if k, _, _ := strings.Cut(a, "="); k == "panic" {
+			return args
+		}
+	}
+	return append(args, "panic=1")
+}
+
+// dryRunResult is the output of run --dry-run.
+type dryRunResult struct {
+	Manager   vm.ManagerConfig     `json:"manager"`
+	VM        *hypervisor.VMConfig `json:"vm"`
+	TapDevice string               `json:"tap_device,omitempty"`
+	Ephemeral bool                 `json:"ephemeral,omitempty"`
+}
+
+// printDryRun writes the configuration mgr would boot with as JSON.
+func printDryRun(mgr *vm.Manager) error {
+	vmCfg, err := mgr.DryRunConfig()
+	if err != nil {
+		return fmt.Errorf("resolve VM config: %w", err)
+	}
+	res := dryRunResult{
+		Manager:   mgr.Config(),
+		VM:        vmCfg,
+		TapDevice: runTapDevice,
+		Ephemeral: runEphemeral,
+	}
+	data, err := json.MarshalIndent(res, "", "  ")
 	if err != nil {
-		// First run - create default config
-		cfg = config.DefaultState()
+		return err
 	}
-	if timer != nil {
-		timer.Mark("config_load")
+	fmt.Println(string(data))
+	return nil
+}
+
+// attachTapDevice opens the named tap interface, creating it if it does
+// not exist. The returned cleanup closes it and removes it if it was created here.
+func attachTapDevice(name string) (*os.File, func(), error) {
+	created := !vm.TapDeviceExists(name)
+	if created {
+		if err := vm.SetupTapDevice(name); err != nil {
+			return nil, nil, fmt.Errorf("create tap device: %w", err)
+		}
 	}
 
-	// Override distro from flag if specified
-	if runDistro != "" {
-		cfg.Distro = runDistro
+	f, err := vm.OpenTapDevice(name)
+	if err != nil {
+		if created {
+			vm.TeardownTapDevice(name)
+		}
+		return nil, nil, fmt.Errorf("open tap device: %w", err)
+	}
+
+	cleanup := func() {
+		f.Close()
+		if created {
+			if err := vm.TeardownTapDevice(name); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: could not remove tap device %s: %v\n", name, err)
+			}
+		}
+	}
+	return f, cleanup, nil
+}
+
+func runRun(cmd *cobra.Command, args []string) error {
+	if runAttach && runHeadless {
+		return fmt.Errorf("--attach and --headless cannot be combined")
+	}
+	if runWait && !runHeadless {
+		return fmt.Errorf("--wait requires --headless")
+	}
+	if runWaitForNetwork && !runHeadless {
+		return fmt.Errorf("--wait-for-network requires --headless")
+	}
+	if runOverlay {
+		if runEphemeral {
+			return fmt.Errorf("--overlay and --ephemeral cannot be combined")
+		}
+		if runOverlaySizeMB < 1 {
+			return fmt.Errorf("--overlay-size must be at least 1")
+		}
+	}
+	if runAutoRestart {
+		// The GUI window cannot be reopened once closed, so restarts
+		// are only possible without one
+		if !runHeadless {
+			return fmt.Errorf("--auto-restart requires --headless")
+		}
+		if runMaxRestarts < 1 {
+			return fmt.Errorf("--max-restarts must be at least 1")
+		}
+		if runRestartDelay < 0 {
+			return fmt.Errorf("--restart-delay must not be negative")
+		}
+	}
+
+	// Keep stdout to the JSON and never prompt for a distro
+	if runDryRun {
+		SetQuietMode(true)
+	}
+
+	// Boot timings are always recorded; VMT_TIMING=1 also prints them and
+	// VMT_OTLP_ENDPOINT sends them to a collector as a trace
+	shutdownTracing, err := timing.InitTracing(context.Background(), os.Getenv(timing.OTLPEndpointEnv))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	} else {
+		defer func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := shutdownTracing(ctx); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: export boot trace: %v\n", err)
+			}
+		}()
 	}
+	timer := timing.New()
+
+	// Load config (defaults on first run)
+	cfg, err := config.LoadState()
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+	timer.Mark("config_load")
 
 	// Print system information (skip in quiet mode)
 	if !quietMode {
@@ -138,54 +466,133 @@ func runRun(cmd *cobra.Command, args []string) error {
 	}
 
 	// Setup paths
-	homeDir, err := os.UserHomeDir()
+	baseDir, err := baseDirectory()
 	if err != nil {
-		return fmt.Errorf("get home dir: %w", err)
+		return err
 	}
-	baseDir := filepath.Join(homeDir, ".vmterminal")
+	warnUnmigratedData()
 
 	// Ensure base directory exists
-	if err := os.MkdirAll(baseDir, 0755); err != nil {
-		return fmt.Errorf("create base dir: %w", err)
+	if !runDryRun {
+		if err := os.MkdirAll(baseDir, 0755); err != nil {
+			return fmt.Errorf("create base dir: %w", err)
+		}
+	}
+
+	vmName, entry, err := resolveRunVM(baseDir)
+	if err != nil {
+		return err
+	}
+	kernelArgs, err := kernelArgsFor(entry, runKernelArgs)
+	if err != nil {
+		return err
+	}
+	if runAutoRestart {
+		kernelArgs = withPanicReboot(kernelArgs)
+	}
+	for _, m := range runMirrors {
+		if _, _, err := config.ParseMirror(m); err != nil {
+			return err
+		}
 	}
 
 	// Check if VM is already running
-	running, pid := isVMRunning(baseDir, "default")
-	if running {
-		fmt.Printf("VM is already running (PID %d).\n", pid)
-		fmt.Println("You can:")
-		fmt.Println("  - Run 'vmterminal stop' to stop the VM")
-		fmt.Println("  - Run 'vmterminal status' to see VM state")
-		return nil
+	running, pid := isVMRunning(baseDir, vmName)
+	if running && !runDryRun {
+		// A new terminal (e.g. a login shell in a new tab) picks up the
+		// console where the VM is, rather than starting another
+		if !runHeadless && terminal.IsTerminal(int(os.Stdin.Fd())) {
+			if vmIn, vmOut, err := vm.ReconnectConsole(vmName, baseDir); err == nil {
+				return reconnectConsole(pid, vmIn, vmOut)
+			}
+		}
+		return printResult(&runResult{VM: vmName, PID: pid, Action: "already_running"})
+	}
+
+	// --wait hands the prompt back once the VM is ready, so the VM itself
+	// runs in a detached copy of this command
+	if (runWait || runWaitForNetwork) && !runDryRun && os.Getenv(runDetachedEnv) == "" {
+		return runDetachedUntilReady(baseDir, vmName)
+	}
+
+	// Merge per-VM overrides (per-VM wins over global). EffectiveState
+	// returns a copy, so overrides are never saved to global state.
+	effective := cfg
+	if entry != nil {
+		effective = entry.EffectiveState(cfg)
+	}
+	hostname := vmHostname(vmName, entry)
+
+	// Override distro from flag if specified
+	if runDistro != "" {
+		effective.Distro = runDistro
+	}
+	if len(runNetworks) > 0 {
+		effective.Networks = runNetworks
+	}
+	if runCacheMaxGB > 0 {
+		effective.CacheMaxGB = runCacheMaxGB
+	}
+	networks, err := config.NetworkInterfaces(effective)
+	if err != nil {
+		return err
 	}
 
 	// Get or prompt for distro
-	distroID, err := resolveDistro(cfg)
+	distroID, err := resolveDistro(effective)
 	if err != nil {
 		return err
 	}
-	cfg.Distro = string(distroID)
+	effective.Distro = string(distroID)
+	if runDistroVersion != "" {
+		if err := distro.PinVersion(distroID, runDistroVersion); err != nil {
+			return err
+		}
+	}
 
 	provider, err := distro.Get(distroID)
 	if err != nil {
 		return fmt.Errorf("get distro: %w", err)
 	}
-	if timer != nil {
-		timer.Mark("distro_resolve")
+	timer.Mark("distro_resolve")
+
+	// Check before downloading anything; the manager checks again
+	if runOverlay && !provider.BootConfig(distro.CurrentArch()).Overlayroot {
+		return fmt.Errorf("--overlay is not supported for %s: its initramfs has no overlayroot hook", provider.Name())
+	}
+
+	var cloudInit *distro.CloudInitConfig
+	if runCloudInitFile != "" {
+		cloudInit, err = distro.LoadCloudInitFile(expandHome(runCloudInitFile), "vmterminal")
+		if err != nil {
+			return err
+		}
 	}
 
 	// Setup data directory for VM
-	dataDir := filepath.Join(baseDir, "data", "default")
+	dataDir := filepath.Join(baseDir, "data", vmName)
 	cacheDir := filepath.Join(baseDir, "cache")
-	if err := os.MkdirAll(dataDir, 0755); err != nil {
-		return fmt.Errorf("create data dir: %w", err)
+	if !runDryRun {
+		if err := os.MkdirAll(dataDir, 0755); err != nil {
+			return fmt.Errorf("create data dir: %w", err)
+		}
+		if err := os.MkdirAll(cacheDir, 0755); err != nil {
+			return fmt.Errorf("create cache dir: %w", err)
+		}
 	}
-	if err := os.MkdirAll(cacheDir, 0755); err != nil {
-		return fmt.Errorf("create cache dir: %w", err)
+
+	// VMs created from a template with a provisioning script run it on
+	// their first boot; an explicit --cloud-init-file takes precedence
+	provisionPath := filepath.Join(dataDir, vm.ProvisionScriptFile)
+	if _, statErr := os.Stat(provisionPath); cloudInit == nil && statErr == nil {
+		cloudInit, err = distro.LoadProvisionScript(provisionPath, vmName, hostname)
+		if err != nil {
+			return err
+		}
 	}
 
 	// Check setup state
-	rootfs := vm.NewRootfsManager(dataDir)
+	rootfs := vm.NewRootfsManager(dataDir, newProgress())
 	state, err := rootfs.CheckSetupState("disk")
 	if err != nil {
 		// Disk might not exist yet, that's OK
@@ -193,13 +600,19 @@ func runRun(cmd *cobra.Command, args []string) error {
 	}
 
 	// If not set up, run interactive setup
-	if !state.RootfsExtracted {
-		fmt.Println()
-		if err := interactiveSetup(cfg, provider, dataDir, cacheDir); err != nil {
+	if !state.RootfsExtracted && !runDryRun {
+		progressln()
+		if err := interactiveSetup(effective, provider, dataDir, cacheDir, hostname, vm.NewSSHKeyManager(baseDir)); err != nil {
 			return err
 		}
 	}
 
+	// A VM that did not shut down cleanly may have left its filesystem
+	// inconsistent, so look before booting it again
+	if !runDryRun {
+		checkAfterUncleanShutdown(baseDir, vmName)
+	}
+
 	// Early capability check - warn about unsupported features
 	driver, err := hypervisor.NewDriver()
 	if err != nil {
@@ -207,44 +620,121 @@ func runRun(cmd *cobra.Command, args []string) error {
 	}
 	caps := driver.Capabilities()
 
-	warnings := config.ValidateConfig(cfg, caps)
-	if len(warnings) > 0 {
-		fmt.Fprint(os.Stderr, config.FormatValidationErrors(warnings))
-		// Continue anyway - warnings are informational
+	// A tap device provides the virtio-net backend on Linux KVM
+	var tapFile *os.File
+	if runTapDevice != "" && runDryRun {
+		effective.EnableNetwork = true
+		caps.Networking = true
+	} else if runTapDevice != "" {
+		var cleanupTap func()
+		tapFile, cleanupTap, err = attachTapDevice(runTapDevice)
+		if err != nil {
+			return err
+		}
+		defer cleanupTap()
+		effective.EnableNetwork = true
+		caps.Networking = true
+		printIfNotQuiet("Networking: tap device %s\n", runTapDevice)
+	}
+
+	issues := config.ValidateConfig(effective, caps)
+	if len(issues) > 0 {
+		fmt.Fprint(os.Stderr, config.FormatValidationIssues(issues))
+		// Continue anyway unless something would break the boot
+		if config.HasValidationErrors(issues) {
+			return fmt.Errorf("invalid configuration; run 'vmterminal config validate' for details")
+		}
+	}
+
+	sharedDirs, sharedDirsReadOnly := sharedDirMaps(effective.SharedDirs)
+
+	var portForwards []hypervisor.PortForward
+	for _, r := range effective.PortForwards {
+		portForwards = append(portForwards, hypervisor.PortForward{Host: r.Host, Guest: r.Guest, Proto: r.Proto})
+	}
+
+	// Boot from a throwaway copy so the base disk stays clean
+	var ephemeralDisk string
+	if runEphemeral && !runDryRun {
+		base, err := ephemeralBaseDisk(provider, cacheDir, dataDir)
+		if err != nil {
+			return err
+		}
+		printlnIfNotQuiet("Creating ephemeral disk...")
+		var cleanup func()
+		ephemeralDisk, cleanup, err = vm.CreateEphemeralDisk(base)
+		if err != nil {
+			return fmt.Errorf("ephemeral disk: %w", err)
+		}
+		defer cleanup()
 	}
 
-	// Build shared dirs map from config
-	sharedDirs := make(map[string]string)
-	for i, dir := range cfg.SharedDirs {
-		tag := fmt.Sprintf("share%d", i)
-		sharedDirs[tag] = dir
+	extraCmdlineFile := filepath.Join(baseDir, vm.DefaultExtraCmdlineFile)
+	if effective.ExtraCmdlineFile != "" {
+		extraCmdlineFile = expandHome(effective.ExtraCmdlineFile)
 	}
 
 	// Create VM manager
 	managerCfg := vm.ManagerConfig{
-		CacheDir:      cacheDir,
-		DataDir:       dataDir,
-		CPUs:          cfg.CPUs,
-		MemoryMB:      cfg.MemoryMB,
-		DiskSizeMB:    int64(cfg.DiskSizeMB),
-		DiskName:      "disk",
-		SharedDirs:    sharedDirs,
-		EnableNetwork: cfg.EnableNetwork,
-		MACAddress:    cfg.MACAddress,
-		SSHHostPort:   cfg.SSHHostPort,
-		Provider:      provider,
+		CacheDir:           cacheDir,
+		DataDir:            dataDir,
+		CPUs:               effective.CPUs,
+		MemoryMB:           effective.MemoryMB,
+		DiskSizeMB:         int64(effective.DiskSizeMB),
+		DiskName:           "disk",
+		DiskPath:           ephemeralDisk,
+		SharedDirs:         sharedDirs,
+		SharedDirsReadOnly: sharedDirsReadOnly,
+		EnableNetwork:      effective.EnableNetwork,
+		MACAddress:         effective.MACAddress,
+		Networks:           networks,
+		SSHHostPort:        effective.SSHHostPort,
+		PortForwards:       portForwards,
+		TapFile:            tapFile,
+		TapDevice:          runTapDevice,
+		EnableVsock:        caps.Vsock,
+		KernelArgs:         kernelArgs,
+		AdditionalDisks:    vmDisks(entry),
+		ExtraCmdlineFile:   extraCmdlineFile,
+		Overlay:            runOverlay,
+		OverlaySizeMB:      runOverlaySizeMB,
+		StopTimeout:        time.Duration(effective.StopTimeoutSeconds) * time.Second,
+		Hostname:           hostname,
+		Provider:           provider,
+		CloudInit:          cloudInit,
+		SkipVerify:         skipVerify,
+		DownloadLimit:      downloadLimit(),
+		Proxy:              downloadProxy,
+		Mirrors:            assetMirrors(effective.Mirrors, distroID),
+		CacheMaxBytes:      cacheMaxBytes(effective.CacheMaxGB),
+		Progress:           newProgress(),
 	}
 
 	mgr, err := vm.NewManager(managerCfg)
 	if err != nil {
 		return fmt.Errorf("create manager: %w", err)
 	}
-	if timer != nil {
-		timer.Mark("manager_create")
+	timer.Mark("manager_create")
+
+	if runDryRun {
+		return printDryRun(mgr)
+	}
+
+	// The overlay disk holds nothing once the VM has stopped; the next
+	// overlay boot starts a fresh one
+	if runOverlay && !runKeepOverlay {
+		defer vm.NewImageManager(dataDir).DeleteDisk(vm.OverlayDiskName)
 	}
 
-	// Save config state
-	if err := config.SaveState(cfg); err != nil {
+	// Save config state, recording the distro that was run (which may
+	// have just been picked at the prompt or replaced a stale one). Other
+	// environment, flag, and per-VM overrides are not persisted.
+	saved, err := config.LoadSavedState()
+	if err == nil {
+		saved.Distro = string(distroID)
+		err = config.SaveState(saved)
+	}
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "Warning: failed to save config: %v\n", err)
 	}
 
@@ -252,43 +742,98 @@ func runRun(cmd *cobra.Command, args []string) error {
 
 	// Show shared directories
 	if len(sharedDirs) > 0 && !quietMode {
-		fmt.Println("Shared directories (mount with: mount -t virtiofs <tag> <mountpoint>):")
+		helper := vm.NewMountHelper(sharedDirs)
+		if p, ok := vm.MountProtocolFor(caps); ok {
+			helper.SetProtocol(p)
+		}
+		fmt.Printf("Shared directories (mount with: mount %s <tag> <mountpoint>):\n", helper.MountArgs())
 		for tag, path := range sharedDirs {
 			fmt.Printf("  %s -> %s\n", tag, path)
 		}
 	}
 
+	// Serve metrics until the VM stops (deferred shutdown runs after Wait).
+	// Bind before booting, so an address in use fails the run up front.
+	var metricsSrc *vmMetricsSource
+	if runMetricsAddr != "" {
+		metricsSrc = &vmMetricsSource{
+			mgr:       mgr,
+			stateFile: vm.NewStateFile(dataDir),
+			snapshots: vm.NewSnapshotManager(baseDir, nil),
+			vmName:    vmName,
+		}
+		metricsSrv := newMetricsServer(runMetricsAddr, metricsSrc)
+		if err := metricsSrv.Start(func(err error) {
+			fmt.Fprintf(os.Stderr, "Warning: metrics server: %v\n", err)
+		}); err != nil {
+			return fmt.Errorf("metrics server: %w", err)
+		}
+		defer metricsSrv.Shutdown(5 * time.Second)
+		printlnIfNotQuiet("Serving metrics on " + runMetricsAddr + "/metrics")
+	}
+
 	printlnIfNotQuiet("\nPreparing VM...")
 
 	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+	defer func() { cancel() }()
 
-	if err := mgr.Prepare(ctx); err != nil {
+	if err := mgr.Prepare(timer.Context(ctx)); err != nil {
 		return fmt.Errorf("prepare VM: %w", err)
 	}
-	if timer != nil {
-		timer.Mark("vm_prepare")
-	}
+	timer.Mark("vm_prepare")
 
 	printlnIfNotQuiet("Starting VM...")
 	if err := mgr.Start(ctx); err != nil {
 		return fmt.Errorf("start VM: %w", err)
 	}
-	if timer != nil {
-		timer.Mark("vm_start")
+	timer.Mark("vm_start")
+	bootStart := time.Now()
+
+	// Capture from the start of boot so DHCP and the like are included
+	if runPcapOut != "" {
+		stopCapture, err := mgr.StartCapture(0, runPcapOut)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: could not capture packets: %v\n", err)
+		} else {
+			defer stopCapture()
+			printIfNotQuiet("Capturing network traffic to %s\n", runPcapOut)
+		}
 	}
 
+	// Let other vmterminal processes reach the guest's SSH over vsock
+	vsockSocketPath, stopVsockProxy := startVsockProxy(ctx, mgr, dataDir)
+	defer func() { stopVsockProxy() }()
+
+	// Handle 'vmterminal suspend' / 'vmterminal resume' from other
+	// terminals. Their signals would end the process if sent before this
+	pauseSignals := handlePauseSignals(mgr, dataDir)
+	defer pauseSignals.Stop()
+
 	// Write PID file for other processes to detect running VM
-	if err := writePIDFile(baseDir, "default"); err != nil {
+	if err := writePIDFile(baseDir, vmName); err != nil {
 		fmt.Fprintf(os.Stderr, "Warning: could not write PID file: %v\n", err)
 	}
-	defer cleanupPIDFile(baseDir, "default")
+	defer cleanupPIDFile(baseDir, vmName)
+
+	// Take scheduled snapshots for as long as this process runs the VM
+	stopSnapshotSchedule := startSnapshotSchedule(baseDir, vmName, effective.SnapshotSchedule, mgr)
+	defer func() { stopSnapshotSchedule() }()
 
 	// Record boot in state
 	stateFile := vm.NewStateFile(dataDir)
 	if err := stateFile.RecordBoot(); err != nil {
 		fmt.Fprintf(os.Stderr, "Warning: could not record boot: %v\n", err)
 	}
+	if tapFile != nil {
+		if err := stateFile.RecordTapDevice(runTapDevice); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: could not record tap device: %v\n", err)
+		}
+	}
+	if mac := mgr.MACAddress(); mac != "" {
+		if err := stateFile.RecordMACAddress(mac); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: could not record MAC address: %v\n", err)
+		}
+	}
 
 	// Track whether we had a clean shutdown
 	cleanShutdown := false
@@ -304,34 +849,177 @@ func runRun(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("get console: %w", err)
 	}
 
-	// Print timing report if enabled (before blocking on GUI)
-	if timer != nil {
-		timer.Mark("gui_launch")
+	// Mirror console output to the log file, if requested, optionally with
+	// each line timestamped for 'vmterminal logs --since', and keep its tail
+	// to tell a kernel panic from a clean exit
+	var consoleLog io.Writer
+	if runConsoleLog != "" {
+		logWriter, err := terminal.NewRotatingLogWriter(runConsoleLog, int64(runConsoleLogMaxSize)*1024*1024)
+		if err != nil {
+			return err
+		}
+		defer logWriter.Close()
+		var w io.Writer = logWriter
+		if runConsoleLogStamps {
+			w = terminal.NewTimestampWriter(logWriter)
+		}
+		// A full disk stops the log, never the console
+		consoleLog = &consoleTap{
+			w: w,
+			onErr: func(err error) {
+				fmt.Fprintf(os.Stderr, "Warning: console log stopped: %v\n", err)
+			},
+		}
+		if logPath, err := filepath.Abs(runConsoleLog); err == nil {
+			if err := stateFile.RecordConsoleLog(logPath, runConsoleLogStamps); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: could not record console log path: %v\n", err)
+			}
+		}
+	}
+	consoleTail := terminal.NewTailBuffer(panicScanBytes)
+	watchConsole := func(r io.Reader) io.Reader {
+		r = io.TeeReader(r, watchKernelVersion(ctx, stateFile))
+		if consoleLog != nil {
+			r = io.TeeReader(r, consoleLog)
+		}
+		if runAutoRestart {
+			r = io.TeeReader(r, consoleTail)
+		}
+		return r
+	}
+	// Let 'vmterminal run' in other terminals reconnect to the console
+	consoleSrv, stopConsoleServer := startConsoleServer(ctx, vmIn, dataDir)
+	defer func() { stopConsoleServer() }()
+	vmOut = io.TeeReader(watchConsole(vmOut), consoleSrv)
+
+	// Record boot timings and print the report if enabled (before blocking on GUI)
+	timer.Mark("gui_launch")
+	if err := timing.Append(filepath.Join(dataDir, timing.HistoryFile), timer.Snapshot()); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: record boot timing: %v\n", err)
+	}
+	timer.End()
+	if os.Getenv("VMT_TIMING") == "1" {
 		timer.Report(os.Stderr)
 	}
 
-	// shutdownOnce ensures we only run the shutdown sequence once,
-	// whether triggered by signal, GUI window close, or VM connection end.
-	var shutdownOnce sync.Once
-	shutdown := func() {
-		shutdownOnce.Do(func() {
-			cancel()
-			mgr.CloseConsole()
-			if stopErr := mgr.Stop(context.Background()); stopErr != nil {
-				fmt.Fprintf(os.Stderr, "Stop error: %v\n", stopErr)
+	// newShutdown returns the shutdown sequence for one boot of the VM.
+	// The sync.Once ensures it runs only once, whether triggered by signal,
+	// GUI window close, or VM connection end.
+	newShutdown := func(mgr *vm.Manager, cancel context.CancelFunc) func() {
+		var shutdownOnce sync.Once
+		return func() {
+			shutdownOnce.Do(func() {
+				cancel()
+				mgr.CloseConsole()
+				// A paused VM cannot process a shutdown request
+				if mgr.State() == vm.StateSuspended {
+					if err := mgr.Resume(context.Background()); err != nil {
+						fmt.Fprintf(os.Stderr, "Resume error: %v\n", err)
+					}
+				}
+				// Stop kills the VM if the guest has not shut down within
+				// the configured stop timeout
+				if stopErr := mgr.Stop(context.Background()); stopErr != nil {
+					fmt.Fprintf(os.Stderr, "Stop error: %v\n", stopErr)
+				}
+				cleanShutdown = true
+			})
+		}
+	}
+	shutdown := newShutdown(mgr, cancel)
+
+	if runHeadless {
+		restarts := newRestartLimiter(runMaxRestarts, restartWindow)
+		for {
+			// Report the boot's progress, since there is no console to watch
+			bootOut := vm.NewBootMonitor(vmOut, bootStart, bootMilestoneReporter(stateFile))
+			guestExit, err := runHeadlessVM(ctx, mgr, bootOut, vsockSocketPath, effective.SSHHostPort, shutdown)
+			if err != nil || !guestExit || !runAutoRestart {
+				return err
 			}
-			cleanShutdown = true
-		})
+			if !terminal.ContainsKernelPanic(consoleTail.Bytes()) {
+				return nil
+			}
+
+			if err := stateFile.RecordPanic(); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: could not record kernel panic: %v\n", err)
+			}
+			if !restarts.Allow(time.Now()) {
+				return fmt.Errorf("kernel panic: giving up after %d restarts within %s", runMaxRestarts, restartWindow)
+			}
+			fmt.Fprintf(os.Stderr, "Kernel panic detected; restarting in %s...\n", runRestartDelay)
+			if !waitRestartDelay(runRestartDelay) {
+				return nil
+			}
+
+			consoleTail.Reset()
+			cleanShutdown = false
+
+			ctx, cancel = context.WithCancel(context.Background())
+			mgr, err = bootVM(ctx, managerCfg)
+			bootStart = time.Now()
+			if err != nil {
+				cancel()
+				return fmt.Errorf("restart VM: %w", err)
+			}
+			shutdown = newShutdown(mgr, cancel)
+			pauseSignals.setManager(mgr)
+			stopSnapshotSchedule()
+			stopSnapshotSchedule = startSnapshotSchedule(baseDir, vmName, effective.SnapshotSchedule, mgr)
+			stopVsockProxy()
+			vsockSocketPath, stopVsockProxy = startVsockProxy(ctx, mgr, dataDir)
+			if metricsSrc != nil {
+				metricsSrc.setManager(mgr)
+			}
+			if vmIn, vmOut, err = mgr.Console(); err != nil {
+				shutdown()
+				return fmt.Errorf("get console: %w", err)
+			}
+			stopConsoleServer()
+			consoleSrv, stopConsoleServer = startConsoleServer(ctx, vmIn, dataDir)
+			vmOut = io.TeeReader(watchConsole(vmOut), consoleSrv)
+			printlnIfNotQuiet("VM restarted.")
+		}
 	}
 
-	// Build window title
-	windowTitle := fmt.Sprintf("VMTerminal - %s %s", provider.Name(), provider.Version())
+	if runAttach {
+		printlnIfNotQuiet("Attached to the VM console. Press Ctrl+] or power off the guest to shut down.")
+
+		// The terminal is in raw mode, so Ctrl+C goes to the guest and
+		// only the detach key gets back out
+		attachErr := terminal.AttachConsole(os.Stdin, os.Stdout, vmIn, vmOut)
+		shutdown()
+		if err := mgr.Wait(); err != nil {
+			return fmt.Errorf("VM exited with error: %w", err)
+		}
+		if attachErr != nil && !errors.Is(attachErr, terminal.ErrDetached) {
+			return fmt.Errorf("console: %w", attachErr)
+		}
+		return nil
+	}
+
+	// Title the window after the VM, saying it is booting until the login
+	// prompt shows
+	title := &bootTitle{
+		vmName:  vmName,
+		distro:  provider.Name(),
+		version: provider.Version(),
+		set:     gui.UpdateWindowTitle,
+	}
+	vmOut = io.TeeReader(vmOut, watchBootTitle(ctx, title))
+
+	// Only drivers with a resizable console follow the window size; on the
+	// others the guest keeps its default size
+	var resize func(rows, cols uint16) error
+	if r := mgr.ConsoleResizer(); r != nil {
+		resize = r.ResizeConsole
+	}
 
 	printlnIfNotQuiet("Opening GUI terminal...")
 
 	// Launch GUI terminal window (blocks until window is closed).
 	// Signal handling (Ctrl+C) is done inside RunTerminal.
-	gui.RunTerminal(vmIn, vmOut, windowTitle, shutdown)
+	gui.RunTerminal(vmIn, vmOut, windowTitle(vmName, provider.Name(), provider.Version(), "booting..."), shutdown, runAllowPaste, resize)
 
 	// Ensure shutdown runs even if window closed without triggering onClose
 	shutdown()
@@ -344,6 +1032,524 @@ func runRun(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// scheduledSnapshotter is the part of vm.SnapshotManager used by the
+// snapshot schedule.
+type scheduledSnapshotter interface {
+	CreateSnapshot(vmName, snapshotName, description string, opts ...vm.SnapshotOption) error
+	PruneScheduled(vmName string, keep int) ([]string, error)
+}
+
+// scheduledVM is the part of vm.Manager used by the snapshot schedule.
+type scheduledVM interface {
+	State() vm.State
+	Suspend(ctx context.Context) error
+	Resume(ctx context.Context) error
+	DiskPath() string
+}
+
+// startSnapshotSchedule snapshots the disk of the VM run by mgr each time
+// sched fires until stop is called. It does nothing unless sched is
+// enabled. stop waits for a snapshot in progress to finish, so the VM is
+// never left paused.
+func startSnapshotSchedule(baseDir, vmName string, sched *config.SnapshotSchedule, mgr scheduledVM) (stop func()) {
+	if sched == nil || !sched.Enabled {
+		return func() {}
+	}
+	if err := sched.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: snapshot schedule disabled: %v\n", err)
+		return func() {}
+	}
+
+	snaps := vm.NewSnapshotManager(baseDir, nil)
+	snaps.SetRetention(snapshotRetention())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			wait, err := timing.ParseCron(sched.Interval)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: snapshot schedule stopped: %v\n", err)
+				return
+			}
+			t := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				t.Stop()
+				return
+			case <-t.C:
+			}
+			if err := takeScheduledSnapshot(snaps, mgr, vmName, sched, time.Now()); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: scheduled snapshot: %v\n", err)
+			}
+		}
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// takeScheduledSnapshot snapshots the disk of the VM run by mgr as
+// scheduled at now and prunes scheduled snapshots beyond sched.MaxRetain.
+// The VM is paused for the copy so the snapshot is consistent rather than
+// a disk caught mid-write; a VM the user already suspended stays so.
+func takeScheduledSnapshot(snaps scheduledSnapshotter, mgr scheduledVM, vmName string, sched *config.SnapshotSchedule, now time.Time) error {
+	if mgr.State() != vm.StateSuspended {
+		if err := mgr.Suspend(context.Background()); err != nil {
+			return fmt.Errorf("pause VM: %w", err)
+		}
+		defer func() {
+			if err := mgr.Resume(context.Background()); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: resume VM after snapshot: %v\n", err)
+			}
+		}()
+	}
+
+	name := sched.SnapshotName(vmName, now)
+	opts := []vm.SnapshotOption{vm.WithScheduled(), vm.WithDiskPath(mgr.DiskPath())}
+	if err := snaps.CreateSnapshot(vmName, name, "scheduled snapshot ("+sched.Interval+")", opts...); err != nil {
+		return fmt.Errorf("create %s: %w", name, err)
+	}
+	if _, err := snaps.PruneScheduled(vmName, sched.MaxRetain); err != nil {
+		return fmt.Errorf("prune scheduled snapshots: %w", err)
+	}
+	return nil
+}
+
+// kernelVersionTimeout is how long the console is watched for the kernel
+// version banner after the VM starts.
+const kernelVersionTimeout = 2 * time.Minute
+
+// watchKernelVersion returns a writer to tee console output into. The
+// kernel version found in it is recorded in stateFile. Writes never fail
+// or block for long, and the output is discarded once the search ends.
+func watchKernelVersion(ctx context.Context, stateFile *vm.StateFile) io.Writer {
+	pr, pw := io.Pipe()
+	go func() {
+		defer pr.Close()
+		version, err := vm.WaitForKernelVersion(ctx, pr, kernelVersionTimeout)
+		if err != nil {
+			// Quiet kernels and UEFI boots may never print a banner
+			return
+		}
+		if err := stateFile.RecordKernelVersion(version); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: could not record kernel version: %v\n", err)
+		}
+	}()
+	return &consoleTap{w: pw}
+}
+
+// consoleTap forwards writes to w until w fails, then drops them, so a
+// finished reader never stalls the console. onErr, if set, is told about
+// the failure.
+type consoleTap struct {
+	w      io.Writer
+	onErr  func(error)
+	closed bool
+}
+
+// Write forwards p while the tap is open and always reports success.
+func (t *consoleTap) Write(p []byte) (int, error) {
+	if !t.closed {
+		if _, err := t.w.Write(p); err != nil {
+			t.closed = true
+			if t.onErr != nil {
+				t.onErr(err)
+			}
+		}
+	}
+	return len(p), nil
+}
+
+// startVsockProxy serves the VM's vsock SSH port on a Unix socket in
+// dataDir until stop is called or ctx is done. It returns an empty socket
+// path and a no-op stop if the VM has no vsock device. stop waits for the
+// socket to be removed.
+func startVsockProxy(ctx context.Context, mgr *vm.Manager, dataDir string) (socket string, stop func()) {
+	dialer := mgr.Vsock()
+	if dialer == nil {
+		return "", func() {}
+	}
+
+	socket = vm.VsockSocketPath(dataDir)
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if err := vm.ServeVsockProxy(ctx, dialer, vm.VsockSSHPort, socket); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: vsock proxy: %v\n", err)
+		}
+	}()
+	return socket, func() {
+		cancel()
+		<-done
+	}
+}
+
+// bootMilestoneReporter returns a BootMonitor callback that prints each
+// milestone to stderr, e.g. "[+2.3s] kernel started", and records it in
+// stateFile.
+func bootMilestoneReporter(stateFile *vm.StateFile) func(vm.BootMilestone) {
+	return func(m vm.BootMilestone) {
+		if !quietMode {
+			fmt.Fprintf(os.Stderr, "[+%.1fs] %s\n", m.Elapsed.Seconds(), m.Name)
+		}
+		if err := stateFile.RecordBootMilestone(m.Name, m.Elapsed); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: could not record boot milestone: %v\n", err)
+		}
+	}
+}
+
+// startConsoleServer serves the VM console to 'vmterminal run' in other
+// terminals until stop is called. Console output must be written to srv.
+func startConsoleServer(ctx context.Context, vmIn io.Writer, dataDir string) (srv *vm.ConsoleServer, stop func()) {
+	srv = vm.NewConsoleServer(vmIn)
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if err := srv.Serve(ctx, vm.ConsoleSocketPath(dataDir)); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: console server: %v\n", err)
+		}
+	}()
+	return srv, func() {
+		cancel()
+		<-done
+	}
+}
+
+// reconnectConsole attaches the terminal to the console of a VM run by
+// another process until the VM stops or the terminal closes. The VM keeps
+// running after the terminal detaches.
+func reconnectConsole(pid int, vmIn io.Writer, vmOut io.Reader) error {
+	progressf("VMTerminal: reconnecting to running VM (PID %d)...\n", pid)
+	progressln("Press Ctrl+] to detach; the VM keeps running.")
+	if c, ok := vmIn.(io.Closer); ok {
+		defer c.Close()
+	}
+	err := terminal.AttachConsole(os.Stdin, os.Stdout, vmIn, vmOut)
+	if err != nil && !errors.Is(err, terminal.ErrDetached) {
+		return fmt.Errorf("console: %w", err)
+	}
+	return nil
+}
+
+// networkReadyTimeout is how long --wait-for-network waits for a lease.
+const networkReadyTimeout = 2 * time.Minute
+
+// networkReady is the outcome of waiting for a headless VM's network.
+type networkReady struct {
+	ip  string
+	err error
+}
+
+// runHeadlessVM keeps a VM running without a GUI. Console output is drained
+// (and logged, if --console-log is set) until the VM connection ends or
+// SIGINT/SIGTERM arrives; a second signal forces exit. With --wait it
+// reports when SSH first answers, or shuts down if it never does; with
+// --wait-for-network it likewise reports the guest's IP address once its
+// DHCP client prints a lease or, on macOS, the NAT's DHCP server records one.
+// guestExit reports that the VM ended on its own rather than by signal.
+func runHeadlessVM(ctx context.Context, mgr *vm.Manager, vmOut io.Reader, vsockSocket string, sshPort int, shutdown func()) (guestExit bool, err error) {
+	var networkCh chan networkReady
+	if runWaitForNetwork {
+		networkCh = make(chan networkReady, 1)
+		netR, netW := io.Pipe()
+		vmOut = io.TeeReader(vmOut, &consoleTap{w: netW})
+		var mac string
+		if runtime.GOOS == "darwin" {
+			mac = mgr.MACAddress()
+		}
+		go func() {
+			networkCh <- waitForNetwork(ctx, netR, mac)
+		}()
+		printlnIfNotQuiet("Waiting for the network...")
+	}
+
+	done := make(chan struct{})
+	go func() {
+		_, _ = io.Copy(io.Discard, vmOut)
+		close(done)
+	}()
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigCh)
+
+	var readyCh chan error
+	if runWait {
+		readyCh = make(chan error, 1)
+		go func() {
+			readyCh <- waitForVMSSH(ctx, vsockSocket, sshPort, defaultHealthTimeout)
+		}()
+		printlnIfNotQuiet("Waiting for SSH...")
+	} else {
+		printlnIfNotQuiet("VM running headless. Press Ctrl+C or run 'vmterminal stop' to shut down.")
+	}
+
+	var waitErr error
+loop:
+	for {
+		select {
+		case err := <-readyCh:
+			readyCh = nil
+			if err != nil {
+				waitErr = err
+				break loop
+			}
+			if sshPort == 0 {
+				fmt.Println("VM is ready (SSH over vsock).")
+			} else {
+				fmt.Printf("VM is ready (SSH on port %d).\n", sshPort)
+			}
+		case n := <-networkCh:
+			networkCh = nil
+			if n.err != nil {
+				waitErr = fmt.Errorf("wait for network: %w", n.err)
+				break loop
+			}
+			if n.ip == "" {
+				fmt.Println("Network is up.")
+			} else {
+				fmt.Printf("Network is up (IP %s).\n", n.ip)
+			}
+		case <-done:
+			guestExit = true
+			break loop
+		case <-sigCh:
+			go func() {
+				<-sigCh
+				os.Exit(1)
+			}()
+			break loop
+		}
+	}
+
+	shutdown()
+	if err := mgr.Wait(); err != nil && waitErr == nil {
+		return guestExit, fmt.Errorf("VM exited with error: %w", err)
+	}
+	return guestExit, waitErr
+}
+
+// runDetachedEnv is set in the environment of the detached copy of 'run'
+// that runDetachedUntilReady starts.
+const runDetachedEnv = "VMT_RUN_DETACHED"
+
+// runLogFile is where a detached 'run' writes its output, in the VM's data
+// directory.
+const runLogFile = "run.log"
+
+// runDetachedUntilReady starts this 'run' command again in a new session
+// with its output going to the VM's run log, and relays that output until
+// the readiness lines --wait and --wait-for-network ask for have appeared.
+// It then returns, leaving the VM running. If the VM process exits first,
+// the rest of its output is relayed and an error returned; a signal is
+// passed on to it so Ctrl+C still shuts the VM down while waiting.
+func runDetachedUntilReady(baseDir, vmName string) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("find vmterminal binary: %w", err)
+	}
+	dataDir := filepath.Join(baseDir, "data", vmName)
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		return fmt.Errorf("create data dir: %w", err)
+	}
+	logPath := filepath.Join(dataDir, runLogFile)
+	logFile, err := os.Create(logPath)
+	if err != nil {
+		return fmt.Errorf("create run log: %w", err)
+	}
+	defer logFile.Close()
+
+	child := exec.Command(exe, os.Args[1:]...)
+	child.Env = append(os.Environ(), runDetachedEnv+"=1")
+	child.Stdout = logFile
+	child.Stderr = logFile
+	detachProcess(child)
+	if err := child.Start(); err != nil {
+		return fmt.Errorf("start VM process: %w", err)
+	}
+	exited := make(chan error, 1)
+	go func() {
+		exited <- child.Wait()
+	}()
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigCh)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// With --json the output is progress, and stdout is kept for the result
+	var out io.Writer = os.Stdout
+	if jsonOutput {
+		out = os.Stderr
+	}
+	relay := newReadyRelay(out, runWait, runWaitForNetwork, cancel)
+	tailDone := make(chan error, 1)
+	go func() {
+		tailDone <- vm.TailFileContext(ctx, logPath, 0, time.Time{}, true, relay)
+	}()
+
+	for {
+		select {
+		case err := <-tailDone:
+			// Following only ends cleanly once the relay has seen the VM
+			// become ready; otherwise the VM is fine and only the relay
+			// stopped
+			if err != nil {
+				return fmt.Errorf("follow run log: %w", err)
+			}
+			return printResult(&runResult{
+				VM:      vmName,
+				PID:     child.Process.Pid,
+				Action:  "ready",
+				SSHPort: relay.sshPort,
+				IP:      relay.ip,
+				Log:     logPath,
+			})
+		case <-sigCh:
+			child.Process.Signal(syscall.SIGTERM)
+		case err := <-exited:
+			cancel()
+			<-tailDone
+			// Relay whatever was written after the tail's last read
+			if f, ferr := os.Open(logPath); ferr == nil {
+				f.Seek(relay.written, io.SeekStart)
+				io.Copy(out, f)
+				f.Close()
+			}
+			if err != nil {
+				return fmt.Errorf("VM process exited before it was ready: %w", err)
+			}
+			return fmt.Errorf("VM process exited before it was ready")
+		}
+	}
+}
+
+// runResult is the outcome of a 'run' that returns while the VM keeps
+// running: one already running, or one started with --wait.
+type runResult struct {
+	VM      string `json:"vm"`
+	PID     int    `json:"pid"`
+	Action  string `json:"action"` // already_running, ready
+	SSHPort int    `json:"ssh_port,omitempty"`
+	IP      string `json:"ip,omitempty"`
+	Log     string `json:"log,omitempty"`
+}
+
+// RenderHuman prints the outcome as text.
+func (r *runResult) RenderHuman(w io.Writer) {
+	switch r.Action {
+	case "already_running":
+		fmt.Fprintf(w, "VM '%s' is already running (PID %d).\n", r.VM, r.PID)
+		fmt.Fprintln(w, "You can:")
+		fmt.Fprintf(w, "  - Run 'vmterminal stop --vm %s' to stop the VM\n", r.VM)
+		fmt.Fprintln(w, "  - Run 'vmterminal list-running' to see running VMs")
+	case "ready":
+		fmt.Fprintf(w, "VM '%s' is running in the background (PID %d); its output goes to %s.\n", r.VM, r.PID, r.Log)
+	}
+}
+
+// readyRelay copies a detached run's output to w and calls ready once it
+// has seen every readiness line asked for, noting the SSH port and IP
+// address they report.
+type readyRelay struct {
+	w       io.Writer
+	ready   func()
+	written int64
+	sshPort int
+	ip      string
+
+	needSSH, needNetwork bool
+	line                 []byte
+}
+
+func newReadyRelay(w io.Writer, ssh, network bool, ready func()) *readyRelay {
+	return &readyRelay{w: w, ready: ready, needSSH: ssh, needNetwork: network}
+}
+
+func (r *readyRelay) Write(p []byte) (int, error) {
+	n, err := r.w.Write(p)
+	r.written += int64(n)
+	for _, b := range p[:n] {
+		if b != '\n' {
+			r.line = append(r.line, b)
+			continue
+		}
+		switch line := string(r.line); {
+		case strings.HasPrefix(line, "VM is ready"):
+			r.needSSH = false
+			fmt.Sscanf(line, "VM is ready (SSH on port %d).", &r.sshPort)
+		case strings.HasPrefix(line, "Network is up"):
+			r.needNetwork = false
+			if ip, ok := strings.CutPrefix(line, "Network is up (IP "); ok {
+				r.ip = strings.TrimSuffix(ip, ").")
+			}
+		}
+		r.line = r.line[:0]
+		if !r.needSSH && !r.needNetwork && r.ready != nil {
+			r.ready()
+			r.ready = nil
+		}
+	}
+	return n, err
+}
+
+// leaseGrace is how long waitForNetwork keeps polling the lease table after
+// the console shows a link without printing a lease.
+const leaseGrace = 10 * time.Second
+
+// natLeasesFile is the lease table waitForNetwork polls. A variable so
+// tests can point it at a file of their own.
+var natLeasesFile = vm.NATLeasesFile
+
+// waitForNetwork waits for the guest's network from its console and, if mac
+// is set, from the NAT's DHCP lease table, whichever reports an address
+// first. Guests that print no lease, or a quiet console, still get their
+// address from the lease table. console is closed on return.
+func waitForNetwork(ctx context.Context, console io.ReadCloser, mac string) networkReady {
+	ctx, cancel := context.WithTimeout(ctx, networkReadyTimeout)
+	defer cancel()
+
+	consoleCh := make(chan networkReady, 1)
+	go func() {
+		defer console.Close()
+		ip, err := vm.WaitForNetworkReady(ctx, console, networkReadyTimeout)
+		consoleCh <- networkReady{ip, err}
+	}()
+	leaseCh := make(chan string, 1)
+	if mac != "" {
+		go func() {
+			ip, _ := vm.WaitForDHCPLease(ctx, natLeasesFile, mac, time.Second)
+			leaseCh <- ip
+		}()
+	}
+
+	select {
+	case ip := <-leaseCh:
+		if ip != "" {
+			return networkReady{ip: ip}
+		}
+		return <-consoleCh
+	case res := <-consoleCh:
+		if res.ip != "" || res.err != nil || mac == "" {
+			return res
+		}
+		grace := time.NewTimer(leaseGrace)
+		defer grace.Stop()
+		select {
+		case ip := <-leaseCh:
+			res.ip = ip
+		case <-grace.C:
+		}
+		return res
+	}
+}
+
 // printSystemInfo displays system architecture and OS information.
 func printSystemInfo() {
 	arch := runtime.GOARCH
@@ -397,7 +1603,7 @@ func resolveDistro(cfg *config.State) (distro.ID, error) {
 			return id, nil
 		}
 		if !quietMode {
-			fmt.Printf("Warning: configured distro %q not found\n", cfg.Distro)
+			fmt.Fprintf(os.Stderr, "Warning: configured distro %q not found\n", cfg.Distro)
 		}
 	}
 
@@ -407,82 +1613,78 @@ func resolveDistro(cfg *config.State) (distro.ID, error) {
 	}
 
 	// For first run, prompt user to select distro
-	fmt.Println("\nAvailable Linux distributions:")
 	providers := distro.ListProviders()
-	for i, p := range providers {
-		marker := ""
-		if p.ID() == distro.Alpine {
-			marker = " (default)"
-		}
-		fmt.Printf("  %d. %s %s%s\n", i+1, p.Name(), p.Version(), marker)
-	}
-
-	fmt.Print("\nSelect distro [1]: ")
-	reader := bufio.NewReader(os.Stdin)
-	input, _ := reader.ReadString('\n')
-	input = strings.TrimSpace(input)
-
-	if input == "" {
-		return distro.DefaultID(), nil
-	}
-
-	var choice int
-	if _, err := fmt.Sscanf(input, "%d", &choice); err != nil || choice < 1 || choice > len(providers) {
-		return distro.DefaultID(), nil
-	}
-
-	return providers[choice-1].ID(), nil
+	sort.Slice(providers, func(i, j int) bool { return providers[i].Name() < providers[j].Name() })
+	return SelectDistro(providers, distro.DefaultID())
 }
 
-// interactiveSetup guides the user through initial VM setup.
-func interactiveSetup(cfg *config.State, provider distro.Provider, dataDir, cacheDir string) error {
-	fmt.Println("Creating File Structure...")
+// interactiveSetup guides the user through initial VM setup. The public
+// key from keys is authorized for root in the new disk so that
+// 'vmterminal ssh' works on first boot, and the guest is given hostname.
+func interactiveSetup(cfg *config.State, provider distro.Provider, dataDir, cacheDir, hostname string, keys *vm.SSHKeyManager) error {
+	progressln("Creating File Structure...")
 
 	// Check for FuseFS (optional)
 	if err := checkFuseFS(); err != nil {
-		fmt.Println("FuseFS not installed.")
+		fmt.Fprintln(promptWriter(), "FuseFS not installed.")
 		if promptYesNo("Install Fuse?", false) {
-			fmt.Println("Please install FuseFS manually for your system.")
-			fmt.Println("Continuing with default filesystem...")
+			progressln("Please install FuseFS manually for your system.")
+			progressln("Continuing with default filesystem...")
 		} else {
-			fmt.Println("Using default filesystem to mount Linux Kernel.")
+			progressln("Using default filesystem to mount Linux Kernel.")
 		}
 	} else {
-		fmt.Println("FuseFS available.")
+		progressln("FuseFS available.")
 	}
 
 	// Download distro assets
-	fmt.Printf("Downloading %s...\n", provider.Name())
+	progressf("Downloading %s...\n", provider.Name())
 
-	assets := vm.NewAssetManager(cacheDir, provider)
+	assets := newAssetManager(cacheDir, provider)
 	assetPaths, err := assets.EnsureAssets()
 	if err != nil {
 		return fmt.Errorf("get asset paths: %w", err)
 	}
 
+	privKeyPath, _, err := keys.EnsureKeyPair()
+	if err != nil {
+		return fmt.Errorf("generate SSH key: %w", err)
+	}
+
 	// Check setup requirements - some distros (like Ubuntu) use qcow2 directly
 	reqs := provider.SetupRequirements()
 
-	if reqs != nil && !reqs.NeedsExtraction {
-		// For qcow2-based distros (Ubuntu, Debian, etc.), the converted rootfs.raw
-		// IS the disk image - no need to create a separate disk or extract
-		fmt.Printf("Using %s cloud image as disk.\n", provider.Name())
+	if (reqs != nil && !reqs.NeedsExtraction) || assets.BootConfig().UEFI {
+		// For qcow2-based distros (Ubuntu, Debian, etc.) and UEFI boots, the
+		// VM's disk is a copy of the converted rootfs.raw - no need to
+		// create an empty disk or extract
+		progressf("Using %s cloud image as disk.\n", provider.Name())
+		diskPath, err := vm.NewImageManager(dataDir).EnsureImageDisk("disk", assetPaths.Rootfs)
+		if err != nil {
+			return fmt.Errorf("create disk: %w", err)
+		}
+
+		// The image is never mounted on the host, so write the key with
+		// guestfish. A missing guestfish only costs key-based login.
+		if err := keys.InjectSSHKeyImage(diskPath); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: could not add SSH key to disk image: %v\n", err)
+		}
 	} else {
 		// For tarball-based distros (Alpine, Arch), create and populate a disk
 		images := vm.NewImageManager(dataDir)
 		if !images.DiskExists("disk") {
-			fmt.Println("Creating disk image...")
+			progressln("Creating disk image...")
 			if _, err := images.EnsureDisk("disk", int64(cfg.DiskSizeMB)); err != nil {
 				return fmt.Errorf("create disk: %w", err)
 			}
 		}
 
 		// Setup filesystem (requires sudo)
-		rootfs := vm.NewRootfsManager(dataDir)
+		rootfs := vm.NewRootfsManager(dataDir, newProgress()).WithSSHKey(keys).WithHostname(hostname)
 		state, _ := rootfs.CheckSetupState("disk")
 
 		if !state.DiskFormatted {
-			fmt.Println("\nDisk formatting requires sudo permissions.")
+			fmt.Fprintln(promptWriter(), "\nDisk formatting requires sudo permissions.")
 			if !promptYesNo("Give sudo permission to run file building?", true) {
 				return fmt.Errorf("sudo permission required for setup")
 			}
@@ -492,26 +1694,72 @@ func interactiveSetup(cfg *config.State, provider distro.Provider, dataDir, cach
 				fsType = reqs.FSType
 			}
 
-			fmt.Printf("Formatting disk with %s filesystem...\n", fsType)
+			progressf("Formatting disk with %s filesystem...\n", fsType)
 			if err := rootfs.FormatDisk("disk", fsType); err != nil {
 				return fmt.Errorf("format disk: %w", err)
 			}
 		}
 
 		if !state.RootfsExtracted {
-			fmt.Println("Extracting rootfs to disk...")
+			progressln("Extracting rootfs to disk...")
 			if err := rootfs.ExtractRootfs("disk", assetPaths.Rootfs); err != nil {
 				return fmt.Errorf("extract rootfs: %w", err)
 			}
 		}
 	}
 
-	fmt.Printf("Installed %s.\n", provider.Name())
+	progressf("Installed %s.\n", provider.Name())
 
-	fmt.Printf("\nWelcome to %s.\n", provider.Name())
+	progressf("\nSSH private key: %s\n", privKeyPath)
+	if cfg.SSHHostPort > 0 {
+		progressf("Connect with:    ssh -i %s -p %d root@localhost\n", privKeyPath, cfg.SSHHostPort)
+	} else {
+		progressln("Connect with:    vmterminal ssh")
+	}
+
+	progressf("\nWelcome to %s.\n", provider.Name())
 	return nil
 }
 
+// checkAfterUncleanShutdown runs a read-only check of the VM's disk when
+// its previous boot did not end in a clean shutdown, and offers to repair
+// any errors found. Problems are reported but never stop the boot.
+func checkAfterUncleanShutdown(baseDir, vmName string) {
+	dataDir := filepath.Join(baseDir, "data", vmName)
+	st, err := vm.NewStateFile(dataDir).Load()
+	if err != nil || st.BootCount == 0 || st.CleanShutdown {
+		return
+	}
+	diskPath := vm.NewRootfsManager(dataDir, nil).DiskPath("disk")
+	if _, err := os.Stat(diskPath); err != nil {
+		return
+	}
+
+	printlnIfNotQuiet("Previous shutdown was not clean, checking disk...")
+	ok, output, err := vm.CheckDisk(diskPath, "")
+	if err != nil {
+		if !errors.Is(err, vm.ErrDiskCheckUnsupported) {
+			fmt.Fprintf(os.Stderr, "Warning: disk check skipped: %v\n", err)
+		}
+		return
+	}
+	if ok {
+		return
+	}
+
+	fmt.Fprintf(os.Stderr, "Warning: filesystem errors found on %s:\n%s\n\n", diskPath, output)
+	if quietMode || !promptYesNo("Run 'vmterminal check --repair' now?", false) {
+		fmt.Fprintf(os.Stderr, "Run 'vmterminal check --vm %s --repair' to fix them.\n", vmName)
+		return
+	}
+	res, err := checkVMDisk(baseDir, vmName, true)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: disk repair failed: %v\n", err)
+		return
+	}
+	res.RenderHuman(os.Stdout)
+}
+
 // checkFuseFS checks if FuseFS is available on the system.
 func checkFuseFS() error {
 	switch runtime.GOOS {
@@ -542,7 +1790,7 @@ func promptYesNo(question string, defaultYes bool) bool {
 		defaultStr = "y/N"
 	}
 
-	fmt.Printf("%s [%s]: ", question, defaultStr)
+	fmt.Fprintf(promptWriter(), "%s [%s]: ", question, defaultStr)
 	reader := bufio.NewReader(os.Stdin)
 	input, _ := reader.ReadString('\n')
 	input = strings.TrimSpace(strings.ToLower(input))
@@ -562,3 +1810,33 @@ func getHypervisorInfo() (string, string, error) {
 	info := driver.Info()
 	return info.Name, info.Version, nil
 }
+
+// sharedDirMaps converts configured shared directories into the tag maps
+// used by the hypervisor. Directories without a tag get "share<index>", or
+// "share<index>-<n>" if another directory was given that tag explicitly, so
+// no share replaces another.
+func sharedDirMaps(dirs []config.SharedDir) (map[string]string, map[string]bool) {
+	paths := make(map[string]string)
+	readOnly := make(map[string]bool)
+	taken := make(map[string]bool)
+	for _, dir := range dirs {
+		if dir.Tag != "" {
+			taken[dir.Tag] = true
+		}
+	}
+	for i, dir := range dirs {
+		tag := dir.Tag
+		if tag == "" {
+			tag = fmt.Sprintf("share%d", i)
+			for n := 1; taken[tag]; n++ {
+				tag = fmt.Sprintf("share%d-%d", i, n)
+			}
+			taken[tag] = true
+		}
+		paths[tag] = dir.Path
+		if dir.ReadOnly {
+			readOnly[tag] = true
+		}
+	}
+	return paths, readOnly
+}
@@ -1,10 +1,20 @@
 package cli
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/javanstorm/vmterminal/internal/config"
+	"github.com/javanstorm/vmterminal/internal/vm"
 )
 
 func TestQuietMode(t *testing.T) {
@@ -132,3 +142,188 @@ func TestIsVMRunningInvalidPID(t *testing.T) {
 		t.Error("invalid PID file should not be detected as running")
 	}
 }
+
+func TestSharedDirMaps(t *testing.T) {
+	paths, readOnly := sharedDirMaps([]config.SharedDir{
+		{Path: "/home/user"},
+		{Path: "/data", ReadOnly: true},
+		{Path: "/src", Tag: "code", ReadOnly: true},
+	})
+
+	want := map[string]string{"share0": "/home/user", "share1": "/data", "code": "/src"}
+	if len(paths) != len(want) {
+		t.Fatalf("paths = %v, want %v", paths, want)
+	}
+	for tag, path := range want {
+		if paths[tag] != path {
+			t.Errorf("paths[%q] = %q, want %q", tag, paths[tag], path)
+		}
+	}
+	if readOnly["share0"] || !readOnly["share1"] || !readOnly["code"] {
+		t.Errorf("readOnly = %v, want share1 and code", readOnly)
+	}
+}
+
+func TestSharedDirMapsUniqueTags(t *testing.T) {
+	// An explicit tag that matches a generated one must not replace a share
+	paths, _ := sharedDirMaps([]config.SharedDir{
+		{Path: "/a/project"},
+		{Path: "/b/project"},
+		{Path: "/c", Tag: "share1"},
+	})
+
+	want := map[string]string{"share0": "/a/project", "share1-1": "/b/project", "share1": "/c"}
+	if !reflect.DeepEqual(paths, want) {
+		t.Errorf("paths = %v, want %v", paths, want)
+	}
+}
+
+func TestKernelArgsFor(t *testing.T) {
+	entry := &vm.VMEntry{Name: "dev", KernelArgs: []string{"debug", "systemd.log_level=info"}}
+
+	got, err := kernelArgsFor(entry, []string{"systemd.log_level=debug", "net.ifnames=0"})
+	if err != nil {
+		t.Fatalf("kernelArgsFor: %v", err)
+	}
+	want := []string{"debug", "systemd.log_level=debug", "net.ifnames=0"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("kernelArgsFor() = %q, want %q", got, want)
+	}
+
+	if got, err := kernelArgsFor(nil, nil); err != nil || len(got) != 0 {
+		t.Errorf("kernelArgsFor(nil, nil) = %q, %v", got, err)
+	}
+	if _, err := kernelArgsFor(nil, []string{""}); err == nil {
+		t.Error("kernelArgsFor should reject an empty argument")
+	}
+}
+
+func TestWithPanicReboot(t *testing.T) {
+	if got := withPanicReboot([]string{"debug"}); !reflect.DeepEqual(got, []string{"debug", "panic=1"}) {
+		t.Errorf("withPanicReboot() = %q, want panic=1 appended", got)
+	}
+	if got := withPanicReboot([]string{"panic=10"}); !reflect.DeepEqual(got, []string{"panic=10"}) {
+		t.Errorf("withPanicReboot() = %q, want the user's panic= kept", got)
+	}
+}
+
+func TestConsoleTapDropsAfterReaderCloses(t *testing.T) {
+	pr, pw := io.Pipe()
+	tap := &consoleTap{w: pw}
+
+	go func() {
+		buf := make([]byte, 5)
+		io.ReadFull(pr, buf)
+		pr.Close()
+	}()
+
+	for i := 0; i < 3; i++ {
+		if n, err := tap.Write([]byte("hello")); n != 5 || err != nil {
+			t.Fatalf("Write %d = %d, %v; want 5, nil", i, n, err)
+		}
+	}
+	if !tap.closed {
+		t.Error("tap should stop forwarding once the reader is closed")
+	}
+}
+
+func TestConsoleTapReportsErrorOnce(t *testing.T) {
+	var errs []error
+	writeErr := errors.New("no space left on device")
+	tap := &consoleTap{w: failingWriter{writeErr}, onErr: func(err error) { errs = append(errs, err) }}
+
+	for i := 0; i < 3; i++ {
+		if n, err := tap.Write([]byte("login: ")); n != 7 || err != nil {
+			t.Fatalf("Write %d = %d, %v; want 7, nil", i, n, err)
+		}
+	}
+	if len(errs) != 1 || !errors.Is(errs[0], writeErr) {
+		t.Errorf("onErr called with %v, want the write error once", errs)
+	}
+}
+
+// failingWriter fails every write with err.
+type failingWriter struct{ err error }
+
+func (w failingWriter) Write(p []byte) (int, error) { return 0, w.err }
+
+func TestWaitForNetworkFromLeaseTable(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dhcpd_leases")
+	lease := "{\n\tip_address=192.168.64.7\n\thw_address=1,52:54:0:12:34:56\n}\n"
+	if err := os.WriteFile(path, []byte(lease), 0644); err != nil {
+		t.Fatal(err)
+	}
+	orig := natLeasesFile
+	t.Cleanup(func() { natLeasesFile = orig })
+	natLeasesFile = path
+
+	// The console stays quiet; the lease table alone reports the address
+	console, consoleW := io.Pipe()
+	defer consoleW.Close()
+	res := waitForNetwork(context.Background(), console, "52:54:00:12:34:56")
+	if res.err != nil || res.ip != "192.168.64.7" {
+		t.Errorf("waitForNetwork = %q, %v; want 192.168.64.7", res.ip, res.err)
+	}
+}
+
+func TestWatchBootTitle(t *testing.T) {
+	titles := make(chan string, 10)
+	title := &bootTitle{
+		vmName:  "dev",
+		distro:  "Alpine Linux",
+		version: "3.21",
+		set:     func(s string) { titles <- s },
+	}
+	w := watchBootTitle(context.Background(), title)
+
+	next := func() string {
+		t.Helper()
+		select {
+		case s := <-titles:
+			return s
+		case <-time.After(5 * time.Second):
+			t.Fatal("window title not updated")
+			return ""
+		}
+	}
+	if got, want := next(), "dev (Alpine Linux 3.21) — VMTerminal — booting..."; got != want {
+		t.Errorf("initial title = %q, want %q", got, want)
+	}
+	io.WriteString(w, "[    0.000000] Linux version 6.6.58-0-virt (buildozer@build) #1-Alpine SMP\r\n")
+	if got, want := next(), "dev (Alpine Linux 3.21) — VMTerminal — booting Linux 6.6.58-0-virt..."; got != want {
+		t.Errorf("title after kernel banner = %q, want %q", got, want)
+	}
+	io.WriteString(w, "\r\nWelcome to Alpine Linux 3.21\r\n\r\ndev login: ")
+	if got, want := next(), "dev (Alpine Linux 3.21) — VMTerminal"; got != want {
+		t.Errorf("title after login prompt = %q, want %q", got, want)
+	}
+
+	// A late kernel banner must not bring back the booting status
+	title.booting("6.6.58-0-virt")
+	select {
+	case s := <-titles:
+		t.Errorf("title changed after boot to %q", s)
+	default:
+	}
+}
+
+func TestReadyRelay(t *testing.T) {
+	var out bytes.Buffer
+	ready := 0
+	r := newReadyRelay(&out, true, true, func() { ready++ })
+
+	io.WriteString(r, "Waiting for SSH...\nVM is ready (SSH on port 2222).\nNetwork is")
+	if ready != 0 {
+		t.Fatal("ready before the network line")
+	}
+	io.WriteString(r, " up (IP 192.168.64.5).\nlater output\n")
+	if ready != 1 {
+		t.Errorf("ready called %d times, want 1", ready)
+	}
+	if int64(out.Len()) != r.written || !strings.HasSuffix(out.String(), "later output\n") {
+		t.Errorf("relayed %q (%d bytes counted)", out.String(), r.written)
+	}
+	if r.sshPort != 2222 || r.ip != "192.168.64.5" {
+		t.Errorf("sshPort, ip = %d, %q, want 2222, 192.168.64.5", r.sshPort, r.ip)
+	}
+}
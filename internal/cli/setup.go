@@ -0,0 +1,149 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/javanstorm/vmterminal/internal/config"
+	"github.com/javanstorm/vmterminal/internal/distro"
+	"github.com/javanstorm/vmterminal/internal/vm"
+	"github.com/spf13/cobra"
+)
+
+var setupCmd = &cobra.Command{
+	Use:   "setup",
+	Short: "Set up the VM disk with the Linux rootfs",
+	Long: `Download the distro's assets and set up the VM's disk, as the first
+'vmterminal run' does, without booting the VM. Formatting the disk needs
+sudo.
+
+With --dry-run, nothing is downloaded or written. Instead setup checks that
+it can succeed: the download URLs answer a HEAD request, the tools it runs
+(guestfish, mkfs.ext4, ...) are installed, there is enough free space for
+the disk image and the downloads, and sudo works without a password
+prompt ('sudo -n true'). It prints a table of the checks and exits with
+status 1 if any fails.
+
+Examples:
+  vmterminal setup
+  vmterminal setup --vm dev --distro debian
+  vmterminal setup --dry-run`,
+	Args: cobra.NoArgs,
+	RunE: runSetup,
+}
+
+var (
+	setupVM     string
+	setupDistro string
+	setupDryRun bool
+)
+
+func init() {
+	setupCmd.Flags().StringVar(&setupVM, "vm", "", "VM to set up (default: active VM)")
+	setupCmd.Flags().StringVarP(&setupDistro, "distro", "d", "", "Linux distribution to set up (default: configured distro)")
+	setupCmd.Flags().BoolVar(&setupDryRun, "dry-run", false, "Check that setup can succeed without changing anything")
+	addProxyFlag(setupCmd)
+}
+
+// setupCheckResult is the structured output of setup --dry-run.
+type setupCheckResult struct {
+	VM     string           `json:"vm"`
+	Distro string           `json:"distro"`
+	Checks []vm.CheckResult `json:"checks"`
+	OK     bool             `json:"ok"`
+}
+
+// RenderHuman prints the checks as a table.
+func (r *setupCheckResult) RenderHuman(w io.Writer) {
+	fmt.Fprintf(w, "Setup checks for VM '%s' (%s):\n\n", r.VM, r.Distro)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "CHECK\tSTATUS\tDETAIL")
+	for _, c := range r.Checks {
+		status := "PASS"
+		if !c.OK {
+			status = "FAIL"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", c.Name, status, c.Detail)
+	}
+	tw.Flush()
+}
+
+func runSetup(cmd *cobra.Command, args []string) error {
+	cfg, err := config.LoadState()
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+	baseDir, err := baseDirectory()
+	if err != nil {
+		return err
+	}
+	vmName, entry, err := resolveVM(baseDir, setupVM)
+	if err != nil {
+		return err
+	}
+
+	effective := cfg
+	if entry != nil {
+		effective = entry.EffectiveState(cfg)
+	}
+	if setupDistro != "" {
+		effective.Distro = setupDistro
+	}
+	distroID, err := resolveDistro(effective)
+	if err != nil {
+		return err
+	}
+	provider, err := distro.Get(distroID)
+	if err != nil {
+		return fmt.Errorf("get distro: %w", err)
+	}
+
+	dataDir := filepath.Join(baseDir, "data", vmName)
+	cacheDir := filepath.Join(baseDir, "cache")
+	state, err := vm.NewRootfsManager(dataDir, nil).CheckSetupState("disk")
+	if err == nil && state.RootfsExtracted {
+		fmt.Printf("VM '%s' is already set up.\n", vmName)
+		return nil
+	}
+
+	if setupDryRun {
+		sc, err := vm.SetupConfigFor(newAssetManager(cacheDir, provider), dataDir, int64(effective.DiskSizeMB))
+		if err != nil {
+			return err
+		}
+		sc.Proxy = downloadProxy
+		res := &setupCheckResult{VM: vmName, Distro: string(distroID), Checks: vm.DryRunSetup(sc)}
+		res.OK = vm.SetupChecksPassed(res.Checks)
+		if err := printResult(res); err != nil {
+			return err
+		}
+		if !res.OK {
+			return &ExitCodeError{Code: 1}
+		}
+		return nil
+	}
+
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		return fmt.Errorf("create data dir: %w", err)
+	}
+	if err := os.MkdirAll(cacheDir, 0755); err != nil {
+		return fmt.Errorf("create cache dir: %w", err)
+	}
+	if err := interactiveSetup(effective, provider, dataDir, cacheDir, vmHostname(vmName, entry), vm.NewSSHKeyManager(baseDir)); err != nil {
+		return err
+	}
+
+	// Record the distro if none was configured, as run does
+	saved, err := config.LoadSavedState()
+	if err == nil && saved.Distro == "" {
+		saved.Distro = string(distroID)
+		err = config.SaveState(saved)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to save config: %v\n", err)
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/javanstorm/vmterminal/internal/vm"
+	"github.com/spf13/cobra"
+)
+
+var shellCmd = &cobra.Command{
+	Use:   "shell",
+	Short: "Attach to the VM's shell, or run one command in it",
+	Long: `Start the VM and attach its console to this terminal like
+'vmterminal run --attach', or reconnect to it if it is already running.
+
+With --command, run one command in the shell on the console of the running
+VM instead, print its output and exit with its status. This needs no SSH,
+so it also works for VMs without an SSH key or port forward, e.g. in CI.
+The console must be logged in at a shell prompt. The command is typed into
+the console, so it also shows in the VM's window.
+
+Examples:
+  vmterminal shell
+  vmterminal shell -c 'uname -a'
+  vmterminal shell --vm dev -c 'apk info' --timeout 2m`,
+	Args: cobra.NoArgs,
+	RunE: runShell,
+}
+
+var (
+	shellVM      string
+	shellCommand string
+	shellTimeout time.Duration
+)
+
+func init() {
+	shellCmd.Flags().StringVar(&shellVM, "vm", "", "VM to use (default: active VM)")
+	shellCmd.Flags().StringVarP(&shellCommand, "command", "c", "", "Run this command in the running VM's console and exit")
+	shellCmd.Flags().DurationVar(&shellTimeout, "timeout", 5*time.Minute, "Give up on --command after this long")
+	noJSON(shellCmd)
+}
+
+func runShell(cmd *cobra.Command, args []string) error {
+	if shellCommand == "" {
+		// run's flags are not registered here, so set the ones shell
+		// implies: the console goes to this terminal, not a GUI window
+		runVM = shellVM
+		runAttach = true
+		return runRun(cmd, args)
+	}
+
+	baseDir, err := baseDirectory()
+	if err != nil {
+		return err
+	}
+	vmName, _, err := resolveVM(baseDir, shellVM)
+	if err != nil {
+		return err
+	}
+	if running, _ := isVMRunning(baseDir, vmName); !running {
+		return &vm.ErrVMNotRunning{Name: vmName}
+	}
+
+	vmIn, vmOut, err := vm.ReconnectConsole(vmName, baseDir)
+	if err != nil {
+		return err
+	}
+	if c, ok := vmIn.(io.Closer); ok {
+		defer c.Close()
+	}
+
+	output, code, err := vm.RunInConsole(context.Background(), vmIn, vmOut, shellCommand, shellTimeout)
+	if err != nil {
+		return err
+	}
+	fmt.Print(output)
+	if code != 0 {
+		return &ExitCodeError{Code: code}
+	}
+	return nil
+}
@@ -1,10 +1,18 @@
 package cli
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
 
+	"github.com/javanstorm/vmterminal/internal/config"
+	"github.com/javanstorm/vmterminal/internal/timing"
 	"github.com/javanstorm/vmterminal/internal/vm"
 	"github.com/spf13/cobra"
 )
@@ -26,16 +34,29 @@ var snapshotCreateCmd = &cobra.Command{
 var snapshotListCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List snapshots",
-	Long:  `List all snapshots for the VM.`,
-	RunE:  runSnapshotList,
+	Long: `List snapshots for the VM, newest first.
+
+Dates accept YYYY-MM-DD (local time) or RFC 3339. --after includes the
+given moment and --before excludes it. Sizes are disk sizes in MB.
+
+Examples:
+  vmt snapshot list --after 2024-01-01
+  vmt snapshot list --description "pre-upgrade"
+  vmt snapshot list --min-size 1024 --sort-by size`,
+	Args: cobra.NoArgs,
+	RunE: runSnapshotList,
 }
 
 var snapshotRestoreCmd = &cobra.Command{
 	Use:   "restore <name>",
 	Short: "Restore a snapshot",
-	Long:  `Restore the VM disk from a snapshot. VM must be stopped.`,
-	Args:  cobra.ExactArgs(1),
-	RunE:  runSnapshotRestore,
+	Long: `Restore the VM disk from a snapshot. VM must be stopped.
+
+With --from, the disk is taken to be the one saved in that snapshot and
+only the blocks that differ between the two snapshots are rewritten. If the
+disk has changed since, a full restore is done instead.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runSnapshotRestore,
 }
 
 var snapshotDeleteCmd = &cobra.Command{
@@ -46,6 +67,22 @@ var snapshotDeleteCmd = &cobra.Command{
 	RunE:  runSnapshotDelete,
 }
 
+var snapshotVerifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Check every snapshot against its checksum",
+	Long: `Verify the checksum of every snapshot of a VM, several at a time, and
+print each one's status: OK, CORRUPTED, MISSING or UNVERIFIED. Exits with
+status 1 if any snapshot is corrupted or missing, so it can check backups
+from scripts. Snapshots taken before checksums were recorded are
+UNVERIFIED and do not fail the run.
+
+Examples:
+  vmterminal snapshot verify
+  vmterminal snapshot verify --vm dev --json`,
+	Args: cobra.NoArgs,
+	RunE: runSnapshotVerify,
+}
+
 var snapshotShowCmd = &cobra.Command{
 	Use:   "show <name>",
 	Short: "Show snapshot details",
@@ -54,28 +91,185 @@ var snapshotShowCmd = &cobra.Command{
 	RunE:  runSnapshotShow,
 }
 
-var snapshotDescription string
+var snapshotPushCmd = &cobra.Command{
+	Use:   "push <name>",
+	Short: "Upload a snapshot to S3",
+	Long: `Upload a snapshot to an S3 bucket or S3-compatible object store.
+
+The snapshot is stored as <prefix>/<vm>/<name>.raw.gz (or .raw.zst) under
+the --bucket URI, tagged with its SHA-256 checksum. Credentials and region
+come from the standard AWS environment variables (AWS_ACCESS_KEY_ID,
+AWS_SECRET_ACCESS_KEY, AWS_SESSION_TOKEN, AWS_REGION, AWS_PROFILE) or
+~/.aws/credentials and ~/.aws/config. Set AWS_ENDPOINT_URL to use an
+S3-compatible store such as MinIO.
+
+Examples:
+  vmt snapshot push clean-install --bucket s3://backups/vmterminal
+  AWS_ENDPOINT_URL=http://nas:9000 vmt snapshot push nightly --bucket s3://vms`,
+	Args: cobra.ExactArgs(1),
+	RunE: runSnapshotPush,
+}
+
+var snapshotPullCmd = &cobra.Command{
+	Use:   "pull <s3-uri>",
+	Short: "Download a snapshot from S3",
+	Long: `Download a snapshot uploaded with 'snapshot push' and add it to the
+VM's snapshots. The download is verified against the object's SHA-256 tag
+before it is kept. The snapshot keeps its pushed name unless --name is given.
+
+Examples:
+  vmt snapshot pull s3://backups/vmterminal/default/clean-install.raw.gz
+  vmt snapshot pull s3://backups/vmterminal/dev/nightly.raw.zst --name from-dev`,
+	Args: cobra.ExactArgs(1),
+	RunE: runSnapshotPull,
+}
+
+var snapshotScheduleCmd = &cobra.Command{
+	Use:   "schedule",
+	Short: "Manage scheduled snapshots",
+	Long: `Take snapshots automatically while 'vmterminal run' keeps a VM running.
+
+The schedule is a standard five-field cron expression (minute hour
+day-of-month month day-of-week) in local time. Each run only takes the
+snapshots that fall due while it is running; missed times are skipped.`,
+}
+
+var snapshotScheduleSetCmd = &cobra.Command{
+	Use:   "set <cron>",
+	Short: "Set and enable the snapshot schedule",
+	Long: `Set and enable the snapshot schedule.
+
+Snapshot names come from --name-template, in which {vm}, {date} (YYYYMMDD)
+and {time} (HHMMSS) are replaced. --max-retain limits how many scheduled
+snapshots are kept; snapshots created by hand are never pruned by it.
+
+Examples:
+  vmt snapshot schedule set "0 9 * * 1-5"                 # 09:00 on weekdays
+  vmt snapshot schedule set "0 */6 * * *" --max-retain 4  # Every 6 hours, keep a day's worth
+  vmt snapshot schedule set "30 8 * * *" --name-template "{vm}-daily-{date}"`,
+	Args: cobra.ExactArgs(1),
+	RunE: runSnapshotScheduleSet,
+}
+
+var snapshotScheduleShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Show the snapshot schedule",
+	Args:  cobra.NoArgs,
+	RunE:  runSnapshotScheduleShow,
+}
+
+var snapshotScheduleClearCmd = &cobra.Command{
+	Use:   "clear",
+	Short: "Remove the snapshot schedule",
+	Long:  `Remove the snapshot schedule. Existing scheduled snapshots are kept.`,
+	Args:  cobra.NoArgs,
+	RunE:  runSnapshotScheduleClear,
+}
+
+var (
+	snapshotDescription string
+	snapshotMaxCount    int
+	snapshotMaxAgeDays  int
+	snapshotCodec       string
+	snapshotLevel       int
+
+	snapshotListBefore      string
+	snapshotListAfter       string
+	snapshotListMinSize     int64
+	snapshotListMaxSize     int64
+	snapshotListDescription string
+	snapshotListSortBy      string
+
+	snapshotScheduleRetain   int
+	snapshotScheduleTemplate string
+
+	snapshotRestoreFrom string
+
+	snapshotPushBucket string
+	snapshotPullName   string
+
+	snapshotVM string
+)
 
 func init() {
 	snapshotCreateCmd.Flags().StringVarP(&snapshotDescription, "description", "d", "", "Description for the snapshot")
+	snapshotCreateCmd.Flags().IntVar(&snapshotMaxCount, "max-snapshots", 0, "Keep at most this many snapshots, pruning the oldest (default: config max_snapshots)")
+	snapshotCreateCmd.Flags().IntVar(&snapshotMaxAgeDays, "snapshot-max-age-days", 0, "Prune snapshots older than this many days (default: config snapshot_max_age_days)")
+	snapshotCreateCmd.Flags().StringVar(&snapshotCodec, "compression-codec", vm.CodecGzip, "Compression codec: gzip or zstd (zstd needs the zstd tool)")
+	snapshotCreateCmd.Flags().IntVar(&snapshotLevel, "compression-level", 0, "Compression level, 1 (fastest) to 9 (smallest); zstd accepts up to 19 (default: codec default)")
+
+	snapshotListCmd.Flags().StringVar(&snapshotListBefore, "before", "", "Only snapshots created before this date")
+	snapshotListCmd.Flags().StringVar(&snapshotListAfter, "after", "", "Only snapshots created on or after this date")
+	snapshotListCmd.Flags().Int64Var(&snapshotListMinSize, "min-size", 0, "Only snapshots with a disk of at least this many MB")
+	snapshotListCmd.Flags().Int64Var(&snapshotListMaxSize, "max-size", 0, "Only snapshots with a disk of at most this many MB")
+	snapshotListCmd.Flags().StringVar(&snapshotListDescription, "description", "", "Only snapshots whose description contains this text (case-insensitive)")
+	snapshotListCmd.Flags().StringVar(&snapshotListSortBy, "sort-by", vm.SnapshotSortCreated, "Sort order: created or size, largest/newest first")
+
+	snapshotScheduleSetCmd.Flags().IntVar(&snapshotScheduleRetain, "max-retain", 0, "Keep at most this many scheduled snapshots (0 = unlimited)")
+	snapshotScheduleSetCmd.Flags().StringVar(&snapshotScheduleTemplate, "name-template", config.DefaultSnapshotNameTemplate, "Snapshot name; {vm}, {date} and {time} are replaced")
+
+	snapshotRestoreCmd.Flags().StringVar(&snapshotRestoreFrom, "from", "", "Snapshot the disk currently holds; only changed blocks are written")
+
+	snapshotPushCmd.Flags().StringVar(&snapshotPushBucket, "bucket", "", "Destination as s3://bucket[/prefix]")
+	snapshotPushCmd.MarkFlagRequired("bucket")
+	for _, c := range []*cobra.Command{snapshotCreateCmd, snapshotListCmd, snapshotRestoreCmd, snapshotDeleteCmd, snapshotShowCmd, snapshotVerifyCmd, snapshotPushCmd, snapshotPullCmd} {
+		c.Flags().StringVar(&snapshotVM, "vm", "", "VM whose snapshots to use (default: active VM)")
+	}
+
+	snapshotPullCmd.Flags().StringVar(&snapshotPullName, "name", "", "Local name for the snapshot (default: its pushed name)")
+
+	snapshotScheduleCmd.AddCommand(snapshotScheduleSetCmd)
+	snapshotScheduleCmd.AddCommand(snapshotScheduleShowCmd)
+	snapshotScheduleCmd.AddCommand(snapshotScheduleClearCmd)
 
 	snapshotCmd.AddCommand(snapshotCreateCmd)
 	snapshotCmd.AddCommand(snapshotListCmd)
 	snapshotCmd.AddCommand(snapshotRestoreCmd)
 	snapshotCmd.AddCommand(snapshotDeleteCmd)
 	snapshotCmd.AddCommand(snapshotShowCmd)
+	snapshotCmd.AddCommand(snapshotVerifyCmd)
+	snapshotCmd.AddCommand(snapshotPushCmd)
+	snapshotCmd.AddCommand(snapshotPullCmd)
+	snapshotCmd.AddCommand(snapshotScheduleCmd)
 }
 
-// getSnapshotManager returns a SnapshotManager for the default VM.
+// getSnapshotManager returns a SnapshotManager and the VM named by --vm,
+// or the active VM.
 func getSnapshotManager() (*vm.SnapshotManager, string, error) {
-	homeDir, err := os.UserHomeDir()
+	baseDir, err := baseDirectory()
 	if err != nil {
-		return nil, "", fmt.Errorf("get home dir: %w", err)
+		return nil, "", err
 	}
-	baseDir := filepath.Join(homeDir, ".vmterminal")
+	vmName, _, err := resolveVM(baseDir, snapshotVM)
+	if err != nil {
+		return nil, "", err
+	}
+
+	mgr := vm.NewSnapshotManager(baseDir, newProgress())
+	mgr.SetRetention(snapshotRetention())
+	return mgr, vmName, nil
+}
 
-	mgr := vm.NewSnapshotManager(baseDir)
-	return mgr, "default", nil
+// snapshotRetention returns the retention policy from the config file.
+func snapshotRetention() vm.RetentionPolicy {
+	cfg, err := config.LoadState()
+	if err != nil {
+		return vm.RetentionPolicy{}
+	}
+	return vm.RetentionPolicy{
+		MaxCount:   cfg.MaxSnapshots,
+		MaxAgeDays: cfg.SnapshotMaxAgeDays,
+	}
+}
+
+// snapshotNames returns the set of a VM's snapshot names.
+func snapshotNames(mgr *vm.SnapshotManager, vmName string) map[string]bool {
+	names := make(map[string]bool)
+	snaps, _ := mgr.ListSnapshots(vmName)
+	for _, s := range snaps {
+		names[s.Name] = true
+	}
+	return names
 }
 
 // isSnapshotVMRunning checks if the VM appears to be running.
@@ -104,6 +298,126 @@ func isSnapshotVMRunning(baseDir, vmName string) bool {
 	return false
 }
 
+// snapshotInfo is the structured form of a single snapshot.
+type snapshotInfo struct {
+	Name           string    `json:"name"`
+	VM             string    `json:"vm"`
+	Description    string    `json:"description,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+	DiskSize       int64     `json:"disk_size"`
+	DiskAllocated  int64     `json:"disk_allocated,omitempty"`
+	CompressedSize int64     `json:"compressed_size,omitempty"`
+	Codec          string    `json:"codec"`
+	Level          int       `json:"compression_level,omitempty"`
+}
+
+// newSnapshotInfo builds a snapshotInfo from a registry entry.
+func newSnapshotInfo(mgr *vm.SnapshotManager, vmName string, snap *vm.SnapshotEntry) snapshotInfo {
+	size, _ := mgr.SnapshotFileSize(vmName, snap.Name)
+	return snapshotInfo{
+		Name:           snap.Name,
+		VM:             vmName,
+		Description:    snap.Description,
+		CreatedAt:      snap.CreatedAt,
+		DiskSize:       snap.DiskSize,
+		DiskAllocated:  snap.DiskAllocated,
+		CompressedSize: size,
+		Codec:          snap.CompressionCodec(),
+		Level:          snap.CompressionLevel,
+	}
+}
+
+// snapshotActionResult is the structured output of create/restore/delete
+// and push/pull.
+type snapshotActionResult struct {
+	VM             string   `json:"vm"`
+	Snapshot       string   `json:"snapshot"`
+	Action         string   `json:"action"`
+	CompressedSize int64    `json:"compressed_size,omitempty"`
+	Pruned         []string `json:"pruned,omitempty"`
+	Location       string   `json:"location,omitempty"`
+}
+
+// RenderHuman prints the outcome of a snapshot action as text.
+func (r *snapshotActionResult) RenderHuman(w io.Writer) {
+	switch r.Action {
+	case "created":
+		if r.CompressedSize > 0 {
+			fmt.Fprintf(w, "Snapshot created: %s (%.2f MB compressed)\n", r.Snapshot, float64(r.CompressedSize)/(1024*1024))
+		} else {
+			fmt.Fprintf(w, "Snapshot created: %s\n", r.Snapshot)
+		}
+		if len(r.Pruned) > 0 {
+			fmt.Fprintf(w, "Pruned %d old snapshot(s): %s\n", len(r.Pruned), strings.Join(r.Pruned, ", "))
+		}
+	case "restored":
+		fmt.Fprintf(w, "Snapshot '%s' restored successfully.\n", r.Snapshot)
+		fmt.Fprintln(w, "You can now start the VM with: vmterminal run")
+	case "deleted":
+		fmt.Fprintf(w, "Snapshot '%s' deleted.\n", r.Snapshot)
+	case "pushed":
+		fmt.Fprintf(w, "Snapshot '%s' uploaded to %s\n", r.Snapshot, r.Location)
+	case "pulled":
+		fmt.Fprintf(w, "Snapshot '%s' downloaded from %s\n", r.Snapshot, r.Location)
+		fmt.Fprintf(w, "Restore it with: vmterminal snapshot restore %s\n", r.Snapshot)
+	}
+}
+
+// snapshotListResult is the structured output of snapshot list.
+type snapshotListResult struct {
+	VM        string         `json:"vm"`
+	Snapshots []snapshotInfo `json:"snapshots"`
+}
+
+// RenderHuman prints the snapshot list as text.
+func (r *snapshotListResult) RenderHuman(w io.Writer) {
+	if len(r.Snapshots) == 0 {
+		fmt.Fprintln(w, "No snapshots found. Create one with: vmterminal snapshot create <name>")
+		return
+	}
+
+	fmt.Fprintln(w, "Snapshots:")
+	for _, snap := range r.Snapshots {
+		fmt.Fprintf(w, "  %s\n", snap.Name)
+		fmt.Fprintf(w, "    Created: %s\n", snap.CreatedAt.Format("2006-01-02 15:04:05"))
+		if snap.Description != "" {
+			fmt.Fprintf(w, "    Description: %s\n", snap.Description)
+		}
+		fmt.Fprintf(w, "    Original size: %.2f MB\n", float64(snap.DiskSize)/(1024*1024))
+		if snap.CompressedSize > 0 {
+			fmt.Fprintf(w, "    Compressed size: %.2f MB\n", float64(snap.CompressedSize)/(1024*1024))
+		}
+	}
+}
+
+// snapshotShowResult is the structured output of snapshot show.
+type snapshotShowResult struct {
+	snapshotInfo
+}
+
+// RenderHuman prints snapshot details as text.
+func (r *snapshotShowResult) RenderHuman(w io.Writer) {
+	fmt.Fprintf(w, "Snapshot: %s\n", r.Name)
+	fmt.Fprintf(w, "  Created: %s\n", r.CreatedAt.Format("2006-01-02 15:04:05"))
+	if r.Description != "" {
+		fmt.Fprintf(w, "  Description: %s\n", r.Description)
+	}
+	fmt.Fprintf(w, "  Original disk size: %.2f MB\n", float64(r.DiskSize)/(1024*1024))
+	if r.DiskAllocated > 0 {
+		fmt.Fprintf(w, "  Allocated on host: %.2f MB\n", float64(r.DiskAllocated)/(1024*1024))
+	}
+	if r.CompressedSize > 0 {
+		fmt.Fprintf(w, "  Compressed size: %.2f MB\n", float64(r.CompressedSize)/(1024*1024))
+		ratio := float64(r.CompressedSize) / float64(r.DiskSize) * 100
+		fmt.Fprintf(w, "  Compression ratio: %.1f%%\n", ratio)
+	}
+	if r.Level > 0 {
+		fmt.Fprintf(w, "  Compression: %s, level %d\n", r.Codec, r.Level)
+	} else {
+		fmt.Fprintf(w, "  Compression: %s\n", r.Codec)
+	}
+}
+
 func runSnapshotCreate(cmd *cobra.Command, args []string) error {
 	name := args[0]
 
@@ -112,21 +426,53 @@ func runSnapshotCreate(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	fmt.Printf("Creating snapshot '%s'...\n", name)
-	fmt.Println("This may take a while depending on disk size...")
+	if cmd.Flags().Changed("max-snapshots") || cmd.Flags().Changed("snapshot-max-age-days") {
+		if snapshotMaxCount < 0 || snapshotMaxAgeDays < 0 {
+			return fmt.Errorf("retention limits must not be negative")
+		}
+		policy := snapshotRetention()
+		if cmd.Flags().Changed("max-snapshots") {
+			policy.MaxCount = snapshotMaxCount
+		}
+		if cmd.Flags().Changed("snapshot-max-age-days") {
+			policy.MaxAgeDays = snapshotMaxAgeDays
+		}
+		mgr.SetRetention(policy)
+	}
 
-	if err := mgr.CreateSnapshot(vmName, name, snapshotDescription); err != nil {
+	if err := vm.ValidateCompression(snapshotCodec, snapshotLevel); err != nil {
+		return err
+	}
+
+	progressf("Creating snapshot '%s'...\n", name)
+	progressf("This may take a while depending on disk size...\n")
+
+	before, _ := mgr.ListSnapshots(vmName)
+	opts := []vm.SnapshotOption{
+		vm.WithCompressionCodec(snapshotCodec),
+		vm.WithCompressionLevel(snapshotLevel),
+	}
+	if err := mgr.CreateSnapshot(vmName, name, snapshotDescription, opts...); err != nil {
 		return fmt.Errorf("create snapshot: %w", err)
 	}
 
-	size, err := mgr.SnapshotFileSize(vmName, name)
-	if err == nil {
-		fmt.Printf("Snapshot created: %s (%.2f MB compressed)\n", name, float64(size)/(1024*1024))
-	} else {
-		fmt.Printf("Snapshot created: %s\n", name)
+	// Report what the retention policy pruned
+	after := snapshotNames(mgr, vmName)
+	var pruned []string
+	for _, snap := range before {
+		if !after[snap.Name] {
+			pruned = append(pruned, snap.Name)
+		}
 	}
 
-	return nil
+	size, _ := mgr.SnapshotFileSize(vmName, name)
+	return printResult(&snapshotActionResult{
+		VM:             vmName,
+		Snapshot:       name,
+		Action:         "created",
+		CompressedSize: size,
+		Pruned:         pruned,
+	})
 }
 
 func runSnapshotList(cmd *cobra.Command, args []string) error {
@@ -135,41 +481,31 @@ func runSnapshotList(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	snapshots, err := mgr.ListSnapshots(vmName)
+	filter, err := snapshotListFilter(cmd)
 	if err != nil {
-		return fmt.Errorf("list snapshots: %w", err)
+		return err
 	}
 
-	if len(snapshots) == 0 {
-		fmt.Println("No snapshots found. Create one with: vmterminal snapshot create <name>")
-		return nil
+	snapshots, err := mgr.ListSnapshotsFiltered(vmName, filter)
+	if err != nil {
+		return fmt.Errorf("list snapshots: %w", err)
 	}
 
-	fmt.Println("Snapshots:")
-	for _, snap := range snapshots {
-		size, _ := mgr.SnapshotFileSize(vmName, snap.Name)
-		fmt.Printf("  %s\n", snap.Name)
-		fmt.Printf("    Created: %s\n", snap.CreatedAt.Format("2006-01-02 15:04:05"))
-		if snap.Description != "" {
-			fmt.Printf("    Description: %s\n", snap.Description)
-		}
-		fmt.Printf("    Original size: %.2f MB\n", float64(snap.DiskSize)/(1024*1024))
-		if size > 0 {
-			fmt.Printf("    Compressed size: %.2f MB\n", float64(size)/(1024*1024))
-		}
+	res := &snapshotListResult{VM: vmName, Snapshots: []snapshotInfo{}}
+	for i := range snapshots {
+		res.Snapshots = append(res.Snapshots, newSnapshotInfo(mgr, vmName, &snapshots[i]))
 	}
 
-	return nil
+	return printResult(res)
 }
 
 func runSnapshotRestore(cmd *cobra.Command, args []string) error {
 	name := args[0]
 
-	homeDir, err := os.UserHomeDir()
+	baseDir, err := baseDirectory()
 	if err != nil {
-		return fmt.Errorf("get home dir: %w", err)
+		return err
 	}
-	baseDir := filepath.Join(homeDir, ".vmterminal")
 
 	mgr, vmName, err := getSnapshotManager()
 	if err != nil {
@@ -178,10 +514,10 @@ func runSnapshotRestore(cmd *cobra.Command, args []string) error {
 
 	// Check if VM is running
 	if isSnapshotVMRunning(baseDir, vmName) {
-		fmt.Println("Error: VM appears to be running.")
-		fmt.Println("Please stop the VM before restoring a snapshot:")
-		fmt.Println("  Press Ctrl+C in the VM terminal, or")
-		fmt.Println("  Run 'vmterminal stop'")
+		progressf("Error: VM appears to be running.\n")
+		progressf("Please stop the VM before restoring a snapshot:\n")
+		progressf("  Press Ctrl+C in the VM terminal, or\n")
+		progressf("  Run 'vmterminal stop'\n")
 		return fmt.Errorf("VM is running")
 	}
 
@@ -191,20 +527,22 @@ func runSnapshotRestore(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("get snapshot: %w", err)
 	}
 
-	fmt.Printf("Restoring from snapshot '%s'...\n", name)
-	fmt.Printf("  Created: %s\n", snap.CreatedAt.Format("2006-01-02 15:04:05"))
-	fmt.Println()
-	fmt.Println("WARNING: This will overwrite the current disk!")
-	fmt.Println("Restoring...")
+	progressf("Restoring from snapshot '%s'...\n", name)
+	progressf("  Created: %s\n", snap.CreatedAt.Format("2006-01-02 15:04:05"))
+	progressf("\n")
+	progressf("WARNING: This will overwrite the current disk!\n")
+	progressf("Restoring...\n")
 
-	if err := mgr.RestoreSnapshot(vmName, name); err != nil {
+	if snapshotRestoreFrom != "" {
+		err = mgr.DeltaRestore(vmName, snapshotRestoreFrom, name)
+	} else {
+		err = mgr.RestoreSnapshot(vmName, name)
+	}
+	if err != nil {
 		return fmt.Errorf("restore snapshot: %w", err)
 	}
 
-	fmt.Printf("Snapshot '%s' restored successfully.\n", name)
-	fmt.Println("You can now start the VM with: vmterminal run")
-
-	return nil
+	return printResult(&snapshotActionResult{VM: vmName, Snapshot: name, Action: "restored"})
 }
 
 func runSnapshotDelete(cmd *cobra.Command, args []string) error {
@@ -219,9 +557,42 @@ func runSnapshotDelete(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("delete snapshot: %w", err)
 	}
 
-	fmt.Printf("Snapshot '%s' deleted.\n", name)
+	return printResult(&snapshotActionResult{VM: vmName, Snapshot: name, Action: "deleted"})
+}
 
-	return nil
+func runSnapshotPush(cmd *cobra.Command, args []string) error {
+	name := args[0]
+
+	mgr, vmName, err := getSnapshotManager()
+	if err != nil {
+		return err
+	}
+
+	location, err := mgr.SnapshotObjectURI(vmName, name, snapshotPushBucket)
+	if err != nil {
+		return err
+	}
+	if err := mgr.PushSnapshot(vmName, name, snapshotPushBucket); err != nil {
+		return fmt.Errorf("push snapshot: %w", err)
+	}
+
+	return printResult(&snapshotActionResult{VM: vmName, Snapshot: name, Action: "pushed", Location: location})
+}
+
+func runSnapshotPull(cmd *cobra.Command, args []string) error {
+	uri := args[0]
+
+	mgr, vmName, err := getSnapshotManager()
+	if err != nil {
+		return err
+	}
+
+	name, err := mgr.PullSnapshot(vmName, uri, snapshotPullName)
+	if err != nil {
+		return fmt.Errorf("pull snapshot: %w", err)
+	}
+
+	return printResult(&snapshotActionResult{VM: vmName, Snapshot: name, Action: "pulled", Location: uri})
 }
 
 func runSnapshotShow(cmd *cobra.Command, args []string) error {
@@ -237,19 +608,234 @@ func runSnapshotShow(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("get snapshot: %w", err)
 	}
 
-	size, _ := mgr.SnapshotFileSize(vmName, name)
+	return printResult(&snapshotShowResult{newSnapshotInfo(mgr, vmName, snap)})
+}
 
-	fmt.Printf("Snapshot: %s\n", snap.Name)
-	fmt.Printf("  Created: %s\n", snap.CreatedAt.Format("2006-01-02 15:04:05"))
-	if snap.Description != "" {
-		fmt.Printf("  Description: %s\n", snap.Description)
+// Snapshot verification statuses.
+const (
+	snapshotStatusOK         = "OK"
+	snapshotStatusCorrupted  = "CORRUPTED"
+	snapshotStatusMissing    = "MISSING"
+	snapshotStatusUnverified = "UNVERIFIED"
+)
+
+// snapshotVerifyEntry is one snapshot's verification result.
+type snapshotVerifyEntry struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// snapshotVerifyResult is the structured output of snapshot verify.
+type snapshotVerifyResult struct {
+	VM        string                `json:"vm"`
+	Snapshots []snapshotVerifyEntry `json:"snapshots"`
+	OK        bool                  `json:"ok"`
+}
+
+// RenderHuman prints a table of snapshots and their status.
+func (r *snapshotVerifyResult) RenderHuman(w io.Writer) {
+	if len(r.Snapshots) == 0 {
+		fmt.Fprintf(w, "VM '%s' has no snapshots.\n", r.VM)
+		return
 	}
-	fmt.Printf("  Original disk size: %.2f MB\n", float64(snap.DiskSize)/(1024*1024))
-	if size > 0 {
-		fmt.Printf("  Compressed size: %.2f MB\n", float64(size)/(1024*1024))
-		ratio := float64(size) / float64(snap.DiskSize) * 100
-		fmt.Printf("  Compression ratio: %.1f%%\n", ratio)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SNAPSHOT\tSTATUS\tERROR")
+	for _, snap := range r.Snapshots {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", snap.Name, snap.Status, snap.Error)
 	}
+	tw.Flush()
+}
 
+// newSnapshotVerifyResult sorts VerifyAll results by snapshot name.
+func newSnapshotVerifyResult(vmName string, results map[string]error) *snapshotVerifyResult {
+	res := &snapshotVerifyResult{VM: vmName, Snapshots: []snapshotVerifyEntry{}, OK: true}
+	for name, err := range results {
+		entry := snapshotVerifyEntry{Name: name, Status: snapshotStatusOK}
+		var noChecksum *vm.ErrSnapshotNoChecksum
+		switch {
+		case err == nil:
+		case errors.As(err, &noChecksum):
+			// Nothing to check it against, but nothing shows it is damaged
+			entry.Status = snapshotStatusUnverified
+			entry.Error = err.Error()
+		case errors.Is(err, os.ErrNotExist):
+			entry.Status = snapshotStatusMissing
+			entry.Error = err.Error()
+			res.OK = false
+		default:
+			entry.Status = snapshotStatusCorrupted
+			entry.Error = err.Error()
+			res.OK = false
+		}
+		res.Snapshots = append(res.Snapshots, entry)
+	}
+	sort.Slice(res.Snapshots, func(i, j int) bool { return res.Snapshots[i].Name < res.Snapshots[j].Name })
+	return res
+}
+
+func runSnapshotVerify(cmd *cobra.Command, args []string) error {
+	mgr, vmName, err := getSnapshotManager()
+	if err != nil {
+		return err
+	}
+	results, err := mgr.VerifyAll(vmName)
+	if err != nil {
+		return fmt.Errorf("list snapshots: %w", err)
+	}
+	res := newSnapshotVerifyResult(vmName, results)
+	if err := printResult(res); err != nil {
+		return err
+	}
+	if !res.OK {
+		return &ExitCodeError{Code: 1}
+	}
+	return nil
+}
+
+// snapshotListFilter builds the list filter from the flags that were set.
+func snapshotListFilter(cmd *cobra.Command) (vm.SnapshotFilter, error) {
+	filter := vm.SnapshotFilter{
+		Description: snapshotListDescription,
+		SortBy:      snapshotListSortBy,
+	}
+	if snapshotListBefore != "" {
+		t, err := parseSnapshotTime(snapshotListBefore)
+		if err != nil {
+			return filter, fmt.Errorf("--before: %w", err)
+		}
+		filter.Before = &t
+	}
+	if snapshotListAfter != "" {
+		t, err := parseSnapshotTime(snapshotListAfter)
+		if err != nil {
+			return filter, fmt.Errorf("--after: %w", err)
+		}
+		filter.After = &t
+	}
+	if cmd.Flags().Changed("min-size") {
+		size := snapshotListMinSize * 1024 * 1024
+		filter.MinSize = &size
+	}
+	if cmd.Flags().Changed("max-size") {
+		size := snapshotListMaxSize * 1024 * 1024
+		filter.MaxSize = &size
+	}
+	return filter, nil
+}
+
+// parseSnapshotTime parses a date (YYYY-MM-DD, local midnight) or an
+// RFC 3339 timestamp.
+func parseSnapshotTime(s string) (time.Time, error) {
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q: use YYYY-MM-DD or RFC 3339", s)
+	}
+	return t, nil
+}
+
+// snapshotScheduleResult is the structured output of snapshot schedule.
+type snapshotScheduleResult struct {
+	Schedule *config.SnapshotSchedule `json:"schedule"`
+	NextRun  *time.Time               `json:"next_run,omitempty"`
+}
+
+// newSnapshotScheduleResult describes sched, which may be nil, as of now.
+func newSnapshotScheduleResult(sched *config.SnapshotSchedule, now time.Time) *snapshotScheduleResult {
+	res := &snapshotScheduleResult{Schedule: sched}
+	if sched != nil && sched.Enabled {
+		if cron, err := timing.ParseCronSchedule(sched.Interval); err == nil {
+			if next := cron.Next(now); !next.IsZero() {
+				res.NextRun = &next
+			}
+		}
+	}
+	return res
+}
+
+// RenderHuman prints the schedule as text.
+func (r *snapshotScheduleResult) RenderHuman(w io.Writer) {
+	s := r.Schedule
+	if s == nil {
+		fmt.Fprintln(w, "No snapshot schedule set.")
+		return
+	}
+	status := "enabled"
+	if !s.Enabled {
+		status = "disabled"
+	}
+	template := s.NameTemplate
+	if template == "" {
+		template = config.DefaultSnapshotNameTemplate
+	}
+	fmt.Fprintf(w, "Snapshot schedule: %s\n", status)
+	fmt.Fprintf(w, "  Interval: %s\n", s.Interval)
+	if s.MaxRetain > 0 {
+		fmt.Fprintf(w, "  Keep: %d scheduled snapshots\n", s.MaxRetain)
+	} else {
+		fmt.Fprintln(w, "  Keep: all scheduled snapshots")
+	}
+	fmt.Fprintf(w, "  Name template: %s\n", template)
+	if r.NextRun != nil {
+		fmt.Fprintf(w, "  Next run: %s (if a VM is running)\n", r.NextRun.Format("2006-01-02 15:04"))
+	}
+}
+
+func runSnapshotScheduleSet(cmd *cobra.Command, args []string) error {
+	sched := &config.SnapshotSchedule{
+		Enabled:      true,
+		Interval:     args[0],
+		MaxRetain:    snapshotScheduleRetain,
+		NameTemplate: snapshotScheduleTemplate,
+	}
+	if sched.NameTemplate == config.DefaultSnapshotNameTemplate {
+		sched.NameTemplate = ""
+	}
+	if err := sched.Validate(); err != nil {
+		return err
+	}
+
+	cfg, err := config.LoadSavedState()
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+	cfg.SnapshotSchedule = sched
+	if err := config.SaveState(cfg); err != nil {
+		return fmt.Errorf("save config: %w", err)
+	}
+
+	progressf("Snapshot schedule set. It applies from the next 'vmterminal run'.\n")
+	return printResult(newSnapshotScheduleResult(sched, time.Now()))
+}
+
+func runSnapshotScheduleShow(cmd *cobra.Command, args []string) error {
+	cfg, err := config.LoadState()
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+	return printResult(newSnapshotScheduleResult(cfg.SnapshotSchedule, time.Now()))
+}
+
+func runSnapshotScheduleClear(cmd *cobra.Command, args []string) error {
+	cfg, err := config.LoadSavedState()
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+	if cfg.SnapshotSchedule == nil {
+		progressf("No snapshot schedule set.\n")
+		return nil
+	}
+	cfg.SnapshotSchedule = nil
+	if err := config.SaveState(cfg); err != nil {
+		return fmt.Errorf("save config: %w", err)
+	}
+	// config.yaml is never written, so a schedule set there survives
+	if saved, err := config.LoadSavedState(); err == nil && saved.SnapshotSchedule != nil {
+		return fmt.Errorf("the snapshot schedule is set in config.yaml; remove snapshot_schedule there to clear it")
+	}
+	progressf("Snapshot schedule cleared.\n")
 	return nil
 }
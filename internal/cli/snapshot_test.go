@@ -0,0 +1,189 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/javanstorm/vmterminal/internal/config"
+	"github.com/javanstorm/vmterminal/internal/vm"
+)
+
+func TestParseSnapshotTime(t *testing.T) {
+	got, err := parseSnapshotTime("2024-01-02")
+	if err != nil {
+		t.Fatalf("parse date: %v", err)
+	}
+	if want := time.Date(2024, 1, 2, 0, 0, 0, 0, time.Local); !got.Equal(want) {
+		t.Errorf("date = %v, want %v", got, want)
+	}
+
+	got, err = parseSnapshotTime("2024-01-02T15:04:05Z")
+	if err != nil {
+		t.Fatalf("parse RFC 3339: %v", err)
+	}
+	if want := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("timestamp = %v, want %v", got, want)
+	}
+
+	if _, err := parseSnapshotTime("yesterday"); err == nil {
+		t.Error("expected error for invalid date")
+	}
+}
+
+// fakeScheduledVM records how a scheduled snapshot pauses the VM.
+type fakeScheduledVM struct {
+	diskPath string
+	state    vm.State
+	calls    []string
+}
+
+func (f *fakeScheduledVM) State() vm.State  { return f.state }
+func (f *fakeScheduledVM) DiskPath() string { return f.diskPath }
+
+func (f *fakeScheduledVM) Suspend(context.Context) error {
+	f.calls = append(f.calls, "suspend")
+	f.state = vm.StateSuspended
+	return nil
+}
+
+func (f *fakeScheduledVM) Resume(context.Context) error {
+	f.calls = append(f.calls, "resume")
+	f.state = vm.StateRunning
+	return nil
+}
+
+func TestTakeScheduledSnapshot(t *testing.T) {
+	baseDir := t.TempDir()
+	diskDir := filepath.Join(baseDir, "data", "dev")
+	if err := os.MkdirAll(diskDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(diskDir, "disk.raw"), []byte("disk"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// The VM boots from its own image rather than disk.raw
+	bootDisk := filepath.Join(diskDir, "ubuntu.raw")
+	if err := os.WriteFile(bootDisk, []byte("boot disk"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	snaps := vm.NewSnapshotManager(baseDir, nil)
+	if err := snaps.CreateSnapshot("dev", "manual", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	mgr := &fakeScheduledVM{diskPath: bootDisk, state: vm.StateRunning}
+	sched := &config.SnapshotSchedule{Enabled: true, Interval: "0 9 * * *", MaxRetain: 2}
+	start := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	for day := 0; day < 3; day++ {
+		if err := takeScheduledSnapshot(snaps, mgr, "dev", sched, start.AddDate(0, 0, day)); err != nil {
+			t.Fatalf("takeScheduledSnapshot: %v", err)
+		}
+	}
+	if want := []string{"suspend", "resume", "suspend", "resume", "suspend", "resume"}; !reflect.DeepEqual(mgr.calls, want) {
+		t.Errorf("calls = %v, want %v", mgr.calls, want)
+	}
+
+	// A VM the user suspended is snapshotted without resuming it
+	mgr.calls = nil
+	mgr.state = vm.StateSuspended
+	sched.MaxRetain = 3
+	if err := takeScheduledSnapshot(snaps, mgr, "dev", sched, start.AddDate(0, 0, 3)); err != nil {
+		t.Fatalf("takeScheduledSnapshot: %v", err)
+	}
+	if len(mgr.calls) != 0 || mgr.state != vm.StateSuspended {
+		t.Errorf("suspended VM: calls = %v, state = %v", mgr.calls, mgr.state)
+	}
+	snap, err := snaps.GetSnapshot("dev", "scheduled-20240104-090000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snap.DiskSize != int64(len("boot disk")) {
+		t.Errorf("DiskSize = %d, want the size of the boot disk", snap.DiskSize)
+	}
+	sched.MaxRetain = 2
+	if _, err := snaps.PruneScheduled("dev", sched.MaxRetain); err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := snaps.ListSnapshots("dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, s := range list {
+		names = append(names, s.Name)
+		if s.Scheduled == (s.Name == "manual") {
+			t.Errorf("%s: Scheduled = %v", s.Name, s.Scheduled)
+		}
+	}
+	// The oldest scheduled snapshot is pruned; the manual one is kept
+	want := []string{"manual", "scheduled-20240103-090000", "scheduled-20240104-090000"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("snapshots = %v, want %v", names, want)
+	}
+}
+
+func TestSnapshotScheduleResult(t *testing.T) {
+	now := time.Date(2024, 1, 1, 10, 0, 0, 0, time.Local)
+
+	var buf bytes.Buffer
+	newSnapshotScheduleResult(nil, now).RenderHuman(&buf)
+	if !strings.Contains(buf.String(), "No snapshot schedule") {
+		t.Errorf("nil schedule output = %q", buf.String())
+	}
+
+	res := newSnapshotScheduleResult(&config.SnapshotSchedule{Enabled: true, Interval: "0 9 * * *"}, now)
+	if want := time.Date(2024, 1, 2, 9, 0, 0, 0, time.Local); res.NextRun == nil || !res.NextRun.Equal(want) {
+		t.Errorf("NextRun = %v, want %v", res.NextRun, want)
+	}
+	buf.Reset()
+	res.RenderHuman(&buf)
+	for _, want := range []string{"enabled", "0 9 * * *", "scheduled-{date}-{time}", "2024-01-02 09:00"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, buf.String())
+		}
+	}
+
+	if res := newSnapshotScheduleResult(&config.SnapshotSchedule{Interval: "0 9 * * *"}, now); res.NextRun != nil {
+		t.Errorf("disabled schedule has next run %v", res.NextRun)
+	}
+}
+
+func TestSnapshotVerifyResult(t *testing.T) {
+	res := newSnapshotVerifyResult("dev", map[string]error{
+		"b-corrupt": errors.New("checksum mismatch"),
+		"a-good":    nil,
+		"c-missing": fmt.Errorf("compute checksum: %w", os.ErrNotExist),
+		"d-old":     &vm.ErrSnapshotNoChecksum{Name: "d-old"},
+	})
+	if res.OK {
+		t.Error("OK = true with failed snapshots")
+	}
+	want := []snapshotVerifyEntry{
+		{Name: "a-good", Status: snapshotStatusOK},
+		{Name: "b-corrupt", Status: snapshotStatusCorrupted, Error: "checksum mismatch"},
+		{Name: "c-missing", Status: snapshotStatusMissing, Error: "compute checksum: file does not exist"},
+		{Name: "d-old", Status: snapshotStatusUnverified, Error: "snapshot 'd-old' has no checksum (created before checksum support)"},
+	}
+	if !reflect.DeepEqual(res.Snapshots, want) {
+		t.Errorf("Snapshots = %+v, want %+v", res.Snapshots, want)
+	}
+
+	var buf bytes.Buffer
+	res.RenderHuman(&buf)
+	if lines := strings.Split(strings.TrimSpace(buf.String()), "\n"); len(lines) != 5 || !strings.HasPrefix(lines[0], "SNAPSHOT") {
+		t.Errorf("table =\n%s", buf.String())
+	}
+
+	if res := newSnapshotVerifyResult("dev", map[string]error{"a": nil, "b": &vm.ErrSnapshotNoChecksum{Name: "b"}}); !res.OK {
+		t.Error("OK = false with only good and unverified snapshots")
+	}
+}
@@ -2,9 +2,10 @@ package cli
 
 import (
 	"fmt"
-	"os"
+	"io"
 	"path/filepath"
 	"runtime"
+	"strings"
 
 	"github.com/javanstorm/vmterminal/internal/config"
 	"github.com/javanstorm/vmterminal/internal/vm"
@@ -24,7 +25,8 @@ SSH access requires networking support:
 Examples:
   vmterminal ssh keygen    # Generate SSH key pair
   vmterminal ssh pubkey    # Print public key (for manual injection)
-  vmterminal ssh connect   # Show SSH connection command`,
+  vmterminal ssh connect   # Show SSH connection command
+  vmterminal ssh config-entry  # Add "Host vmterminal" to ~/.ssh/config`,
 }
 
 var sshKeygenCmd = &cobra.Command{
@@ -48,30 +50,58 @@ var sshConnectCmd = &cobra.Command{
 	RunE:  runSSHConnect,
 }
 
+var sshConnectVM string
+
 func init() {
+	sshConnectCmd.Flags().StringVar(&sshConnectVM, "vm", "", "VM to connect to (default: active VM)")
 	sshCmd.AddCommand(sshKeygenCmd)
 	sshCmd.AddCommand(sshPubkeyCmd)
 	sshCmd.AddCommand(sshConnectCmd)
 	rootCmd.AddCommand(sshCmd)
 }
 
+// sshKeyResult describes the SSH key pair for 'ssh keygen' and 'ssh pubkey'.
+type sshKeyResult struct {
+	PrivateKey string `json:"private_key"`
+	PublicKey  string `json:"public_key"`
+	Generated  bool   `json:"generated,omitempty"`
+	Content    string `json:"content,omitempty"` // public key line, for 'ssh pubkey'
+}
+
+// RenderHuman prints the key pair as text; for 'ssh pubkey' just the key,
+// for easy copy-paste or piping.
+func (r *sshKeyResult) RenderHuman(w io.Writer) {
+	if r.Content != "" {
+		fmt.Fprint(w, r.Content)
+		return
+	}
+	if !r.Generated {
+		fmt.Fprintln(w, "SSH key pair already exists:")
+		fmt.Fprintf(w, "  Private key: %s\n", r.PrivateKey)
+		fmt.Fprintf(w, "  Public key: %s\n", r.PublicKey)
+		return
+	}
+	fmt.Fprintln(w, "SSH key pair generated:")
+	fmt.Fprintf(w, "  Private key: %s\n", r.PrivateKey)
+	fmt.Fprintf(w, "  Public key: %s\n", r.PublicKey)
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "The public key will be automatically injected into new VMs.")
+	fmt.Fprintln(w, "For existing VMs, use 'vmterminal ssh pubkey' to get the key.")
+}
+
 func runSSHKeygen(cmd *cobra.Command, args []string) error {
 	// Get data directory
-	homeDir, err := os.UserHomeDir()
+	dataDir, err := baseDirectory()
 	if err != nil {
-		return fmt.Errorf("get home dir: %w", err)
+		return err
 	}
-	dataDir := filepath.Join(homeDir, ".vmterminal")
 
 	manager := vm.NewSSHKeyManager(dataDir)
 
 	// Check if keys already exist
 	if manager.KeyPairExists() {
 		privPath, _ := manager.PrivateKeyPath()
-		fmt.Println("SSH key pair already exists:")
-		fmt.Printf("  Private key: %s\n", privPath)
-		fmt.Printf("  Public key: %s.pub\n", privPath)
-		return nil
+		return printResult(&sshKeyResult{PrivateKey: privPath, PublicKey: privPath + ".pub"})
 	}
 
 	// Generate keys
@@ -79,23 +109,14 @@ func runSSHKeygen(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return fmt.Errorf("generate key pair: %w", err)
 	}
-
-	fmt.Println("SSH key pair generated:")
-	fmt.Printf("  Private key: %s\n", privPath)
-	fmt.Printf("  Public key: %s\n", pubPath)
-	fmt.Println()
-	fmt.Println("The public key will be automatically injected into new VMs.")
-	fmt.Println("For existing VMs, use 'vmterminal ssh pubkey' to get the key.")
-
-	return nil
+	return printResult(&sshKeyResult{PrivateKey: privPath, PublicKey: pubPath, Generated: true})
 }
 
 func runSSHPubkey(cmd *cobra.Command, args []string) error {
-	homeDir, err := os.UserHomeDir()
+	dataDir, err := baseDirectory()
 	if err != nil {
-		return fmt.Errorf("get home dir: %w", err)
+		return err
 	}
-	dataDir := filepath.Join(homeDir, ".vmterminal")
 
 	manager := vm.NewSSHKeyManager(dataDir)
 
@@ -103,10 +124,37 @@ func runSSHPubkey(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return err
 	}
+	privPath, _ := manager.PrivateKeyPath()
+	return printResult(&sshKeyResult{PrivateKey: privPath, PublicKey: privPath + ".pub", Content: content})
+}
+
+// sshConnectResult is the SSH command 'ssh connect' suggests.
+type sshConnectResult struct {
+	VM         string `json:"vm"`
+	PrivateKey string `json:"private_key,omitempty"`
+	Command    string `json:"command,omitempty"`
+	// TapDevice is the running VM's tap device on hosts without NAT
+	// networking; without one, Command only works once it is set up.
+	TapDevice  string `json:"tap_device,omitempty"`
+	Networking bool   `json:"networking"`
+}
 
-	// Print just the key for easy copy-paste or piping
-	fmt.Print(content)
-	return nil
+// RenderHuman prints the SSH command with platform-specific guidance.
+func (r *sshConnectResult) RenderHuman(w io.Writer) {
+	switch {
+	case r.PrivateKey == "":
+		fmt.Fprintln(w, "# No SSH key found. Generate one with:")
+		fmt.Fprintln(w, "#   vmterminal ssh keygen")
+	case r.Networking:
+		// macOS with virtio-net - networking works out of the box
+		fmt.Fprintln(w, "# SSH connection command:")
+		fmt.Fprintln(w, r.Command)
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "# Or to skip host key checking (for testing):")
+		fmt.Fprintln(w, strings.Replace(r.Command, "ssh ", "ssh -o StrictHostKeyChecking=no -o UserKnownHostsFile=/dev/null ", 1))
+	default:
+		printLinuxSSHGuide(w, r.Command, r.TapDevice)
+	}
 }
 
 func runSSHConnect(cmd *cobra.Command, args []string) error {
@@ -117,19 +165,25 @@ func runSSHConnect(cmd *cobra.Command, args []string) error {
 	}
 
 	// Get private key path
-	homeDir, err := os.UserHomeDir()
+	dataDir, err := baseDirectory()
 	if err != nil {
-		return fmt.Errorf("get home dir: %w", err)
+		return err
+	}
+	vmName, entry, err := resolveVM(dataDir, sshConnectVM)
+	if err != nil {
+		return err
+	}
+	if entry != nil {
+		cfg = entry.EffectiveState(cfg)
 	}
-	dataDir := filepath.Join(homeDir, ".vmterminal")
 	manager := vm.NewSSHKeyManager(dataDir)
+	result := &sshConnectResult{VM: vmName}
 
 	privKeyPath, err := manager.PrivateKeyPath()
 	if err != nil {
-		fmt.Println("# No SSH key found. Generate one with:")
-		fmt.Println("#   vmterminal ssh keygen")
-		return nil
+		return printResult(result)
 	}
+	result.PrivateKey = privKeyPath
 
 	// Check platform capabilities
 	driver, err := hypervisor.NewDriver()
@@ -137,41 +191,56 @@ func runSSHConnect(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("hypervisor not available: %w", err)
 	}
 
-	caps := driver.Capabilities()
-
-	if caps.Networking {
-		// macOS with virtio-net - networking works out of the box
-		fmt.Println("# SSH connection command:")
-		fmt.Printf("ssh -i %s -p %d root@localhost\n", privKeyPath, cfg.SSHHostPort)
-		fmt.Println()
-		fmt.Println("# Or to skip host key checking (for testing):")
-		fmt.Printf("ssh -o StrictHostKeyChecking=no -o UserKnownHostsFile=/dev/null -i %s -p %d root@localhost\n", privKeyPath, cfg.SSHHostPort)
+	if driver.Capabilities().Networking {
+		result.Networking = true
+		result.Command = fmt.Sprintf("ssh -i %s -p %d root@localhost", privKeyPath, cfg.SSHHostPort)
 	} else {
-		// Linux without virtio-net - needs manual networking setup
-		printLinuxSSHGuide(privKeyPath)
+		// Linux KVM - networking only via a tap device given to 'run'
+		vmDataDir := filepath.Join(dataDir, "data", vmName)
+		if isVMRunningCheck(dataDir, vmName) {
+			if state, err := vm.NewStateFile(vmDataDir).Load(); err == nil {
+				result.TapDevice = state.TapDevice
+			}
+		}
+		result.Command = fmt.Sprintf("ssh -i %s -p 22 root@192.168.100.2", privKeyPath)
 	}
-
-	return nil
+	return printResult(result)
 }
 
-func printLinuxSSHGuide(privKeyPath string) {
-	fmt.Println("# SSH not directly available on Linux KVM (no virtio-net)")
-	fmt.Println("#")
-	fmt.Println("# The hype library lacks networking support.")
-	fmt.Println("# To enable SSH access, set up tap networking:")
-	fmt.Println("#")
-	fmt.Printf("# On host (%s):\n", runtime.GOOS)
-	fmt.Println("#   sudo ip tuntap add dev tap0 mode tap user $USER")
-	fmt.Println("#   sudo ip addr add 192.168.100.1/24 dev tap0")
-	fmt.Println("#   sudo ip link set tap0 up")
-	fmt.Println("#")
-	fmt.Println("# In VM (via console):")
-	fmt.Println("#   ip addr add 192.168.100.2/24 dev eth0")
-	fmt.Println("#   ip link set eth0 up")
-	fmt.Println("#")
-	fmt.Println("# Once networking configured:")
-	fmt.Printf("ssh -i %s -p 22 root@192.168.100.2\n", privKeyPath)
-	fmt.Println()
-	fmt.Println("# Alternative: Use serial console for direct access")
-	fmt.Println("# (no networking required)")
+func printLinuxSSHGuide(w io.Writer, command, tapDevice string) {
+	if tapDevice != "" {
+		fmt.Fprintf(w, "# VM is running with tap networking on %s\n", tapDevice)
+		fmt.Fprintln(w, "#")
+		fmt.Fprintf(w, "# On host (%s):\n", runtime.GOOS)
+		fmt.Fprintf(w, "#   sudo ip addr add 192.168.100.1/24 dev %s\n", tapDevice)
+		fmt.Fprintf(w, "#   sudo ip link set %s up\n", tapDevice)
+		fmt.Fprintln(w, "#")
+		fmt.Fprintln(w, "# In VM (via console):")
+		fmt.Fprintln(w, "#   ip addr add 192.168.100.2/24 dev eth0")
+		fmt.Fprintln(w, "#   ip link set eth0 up")
+		fmt.Fprintln(w, "#")
+		fmt.Fprintln(w, "# SSH connection command:")
+		fmt.Fprintln(w, command)
+		return
+	}
+
+	fmt.Fprintln(w, "# SSH not directly available on Linux KVM without a tap device")
+	fmt.Fprintln(w, "#")
+	fmt.Fprintln(w, "# To enable SSH access, start the VM with tap networking:")
+	fmt.Fprintln(w, "#")
+	fmt.Fprintf(w, "# On host (%s):\n", runtime.GOOS)
+	fmt.Fprintln(w, "#   sudo ip tuntap add dev tap0 mode tap user $USER")
+	fmt.Fprintln(w, "#   sudo ip addr add 192.168.100.1/24 dev tap0")
+	fmt.Fprintln(w, "#   sudo ip link set tap0 up")
+	fmt.Fprintln(w, "#   vmterminal run --tap-device tap0")
+	fmt.Fprintln(w, "#")
+	fmt.Fprintln(w, "# In VM (via console):")
+	fmt.Fprintln(w, "#   ip addr add 192.168.100.2/24 dev eth0")
+	fmt.Fprintln(w, "#   ip link set eth0 up")
+	fmt.Fprintln(w, "#")
+	fmt.Fprintln(w, "# Once networking configured:")
+	fmt.Fprintln(w, command)
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "# Alternative: Use serial console for direct access")
+	fmt.Fprintln(w, "# (no networking required)")
 }
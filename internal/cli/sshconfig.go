@@ -0,0 +1,274 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/javanstorm/vmterminal/internal/config"
+	"github.com/javanstorm/vmterminal/internal/vm"
+	"github.com/spf13/cobra"
+)
+
+// sshConfigHost is the Host alias managed in ~/.ssh/config.
+const sshConfigHost = "vmterminal"
+
+var sshConfigEntryCmd = &cobra.Command{
+	Use:   "config-entry",
+	Short: "Add or update the VM in ~/.ssh/config",
+	Long: `Add a 'Host vmterminal' block to ~/.ssh/config so the VM can be
+reached with a plain 'ssh vmterminal'. Running it again updates the
+existing block instead of adding a duplicate.`,
+	RunE: runSSHConfigEntry,
+}
+
+var sshConfigRemoveCmd = &cobra.Command{
+	Use:   "config-remove",
+	Short: "Remove the VM from ~/.ssh/config",
+	RunE:  runSSHConfigRemove,
+}
+
+func init() {
+	sshCmd.AddCommand(sshConfigEntryCmd)
+	sshCmd.AddCommand(sshConfigRemoveCmd)
+}
+
+// sshConfigEntry holds the options written to the managed Host block.
+type sshConfigEntry struct {
+	Host         string
+	HostName     string
+	Port         int
+	User         string
+	IdentityFile string
+}
+
+// options returns the block's keywords and values in write order.
+func (e sshConfigEntry) options() [][2]string {
+	return [][2]string{
+		{"HostName", e.HostName},
+		{"Port", strconv.Itoa(e.Port)},
+		{"User", e.User},
+		{"IdentityFile", e.IdentityFile},
+		{"StrictHostKeyChecking", "no"},
+	}
+}
+
+// sshConfigKeyword returns the lowercased keyword of an ssh_config line,
+// or "" for blank lines and comments.
+func sshConfigKeyword(line string) string {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ""
+	}
+	kw, _, _ := strings.Cut(strings.ReplaceAll(line, "=", " "), " ")
+	return strings.ToLower(kw)
+}
+
+// findSSHConfigBlock returns the line range [start, end) of the Host block
+// for host, or start == -1 if there is none.
+func findSSHConfigBlock(lines []string, host string) (int, int) {
+	start := -1
+	for i, line := range lines {
+		kw := sshConfigKeyword(line)
+		if start >= 0 && (kw == "host" || kw == "match") {
+			return start, i
+		}
+		if kw == "host" {
+			fields := strings.Fields(strings.ReplaceAll(line, "=", " "))
+			if len(fields) == 2 && fields[1] == host {
+				start = i
+			}
+		}
+	}
+	if start >= 0 {
+		// Trailing blank lines belong to the gap before the next block
+		end := len(lines)
+		for end > start+1 && strings.TrimSpace(lines[end-1]) == "" {
+			end--
+		}
+		return start, end
+	}
+	return -1, -1
+}
+
+// upsertSSHConfig returns content with the entry's Host block added or
+// updated in place. Unrelated lines inside the block are preserved.
+func upsertSSHConfig(content string, e sshConfigEntry) string {
+	lines := strings.Split(strings.TrimRight(content, "\n"), "\n")
+	if content == "" {
+		lines = nil
+	}
+
+	start, end := findSSHConfigBlock(lines, e.Host)
+	if start < 0 {
+		if len(lines) > 0 {
+			lines = append(lines, "")
+		}
+		lines = append(lines, "Host "+e.Host)
+		for _, opt := range e.options() {
+			lines = append(lines, fmt.Sprintf("    %s %s", opt[0], opt[1]))
+		}
+		return strings.Join(lines, "\n") + "\n"
+	}
+
+	block := append([]string(nil), lines[start:end]...)
+	for _, opt := range e.options() {
+		replaced := false
+		for i := 1; i < len(block); i++ {
+			if sshConfigKeyword(block[i]) == strings.ToLower(opt[0]) {
+				block[i] = fmt.Sprintf("    %s %s", opt[0], opt[1])
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			block = append(block, fmt.Sprintf("    %s %s", opt[0], opt[1]))
+		}
+	}
+
+	out := append(append(append([]string(nil), lines[:start]...), block...), lines[end:]...)
+	return strings.Join(out, "\n") + "\n"
+}
+
+// removeSSHConfig returns content without the Host block for host, and
+// whether a block was removed.
+func removeSSHConfig(content, host string) (string, bool) {
+	lines := strings.Split(strings.TrimRight(content, "\n"), "\n")
+	start, end := findSSHConfigBlock(lines, host)
+	if start < 0 {
+		return content, false
+	}
+
+	// Drop the blank separator line we added before the block
+	if start > 0 && strings.TrimSpace(lines[start-1]) == "" {
+		start--
+	}
+	out := append(append([]string(nil), lines[:start]...), lines[end:]...)
+	for len(out) > 0 && strings.TrimSpace(out[0]) == "" {
+		out = out[1:]
+	}
+	if len(out) == 0 {
+		return "", true
+	}
+	return strings.Join(out, "\n") + "\n", true
+}
+
+// sshConfigPath returns ~/.ssh/config.
+func sshConfigPath() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("get home dir: %w", err)
+	}
+	return filepath.Join(homeDir, ".ssh", "config"), nil
+}
+
+// readSSHConfig reads path, treating a missing file as empty.
+func readSSHConfig(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return "", fmt.Errorf("read %s: %w", path, err)
+	}
+	return string(data), nil
+}
+
+// writeSSHConfigEntry adds or updates the entry's block in the config at path.
+func writeSSHConfigEntry(path string, e sshConfigEntry) error {
+	content, err := readSSHConfig(path)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("create ssh dir: %w", err)
+	}
+	return os.WriteFile(path, []byte(upsertSSHConfig(content, e)), 0600)
+}
+
+// removeSSHConfigEntry deletes the block for host from the config at path.
+func removeSSHConfigEntry(path, host string) (bool, error) {
+	content, err := readSSHConfig(path)
+	if err != nil {
+		return false, err
+	}
+	updated, removed := removeSSHConfig(content, host)
+	if !removed {
+		return false, nil
+	}
+	return true, os.WriteFile(path, []byte(updated), 0600)
+}
+
+// sshConfigResult is the outcome of 'ssh config-entry' and 'ssh config-remove'.
+type sshConfigResult struct {
+	Path   string `json:"path"`
+	Host   string `json:"host"`
+	Action string `json:"action"` // updated, removed, not_found
+}
+
+// RenderHuman prints the ~/.ssh/config change as text.
+func (r *sshConfigResult) RenderHuman(w io.Writer) {
+	switch r.Action {
+	case "updated":
+		fmt.Fprintf(w, "Updated %s with 'Host %s'.\n", r.Path, r.Host)
+		fmt.Fprintf(w, "Connect with: ssh %s\n", r.Host)
+	case "removed":
+		fmt.Fprintf(w, "Removed 'Host %s' from %s.\n", r.Host, r.Path)
+	case "not_found":
+		fmt.Fprintf(w, "No 'Host %s' entry in %s.\n", r.Host, r.Path)
+	}
+}
+
+func runSSHConfigEntry(cmd *cobra.Command, args []string) error {
+	cfg, err := config.LoadState()
+	if err != nil {
+		cfg = config.DefaultState()
+	}
+	if cfg.SSHHostPort == 0 {
+		return fmt.Errorf("SSH port forwarding is disabled; set an SSH host port with 'vmterminal config'")
+	}
+
+	baseDir, err := baseDirectory()
+	if err != nil {
+		return err
+	}
+	privKeyPath, err := vm.NewSSHKeyManager(baseDir).PrivateKeyPath()
+	if err != nil {
+		return fmt.Errorf("no SSH key found; generate one with 'vmterminal ssh keygen'")
+	}
+
+	path, err := sshConfigPath()
+	if err != nil {
+		return err
+	}
+
+	entry := sshConfigEntry{
+		Host:         sshConfigHost,
+		HostName:     "localhost",
+		Port:         cfg.SSHHostPort,
+		User:         "root",
+		IdentityFile: privKeyPath,
+	}
+	if err := writeSSHConfigEntry(path, entry); err != nil {
+		return err
+	}
+
+	return printResult(&sshConfigResult{Path: path, Host: sshConfigHost, Action: "updated"})
+}
+
+func runSSHConfigRemove(cmd *cobra.Command, args []string) error {
+	path, err := sshConfigPath()
+	if err != nil {
+		return err
+	}
+
+	removed, err := removeSSHConfigEntry(path, sshConfigHost)
+	if err != nil {
+		return err
+	}
+	result := &sshConfigResult{Path: path, Host: sshConfigHost, Action: "removed"}
+	if !removed {
+		result.Action = "not_found"
+	}
+	return printResult(result)
+}
@@ -0,0 +1,103 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testSSHEntry(port int) sshConfigEntry {
+	return sshConfigEntry{
+		Host:         "vmterminal",
+		HostName:     "localhost",
+		Port:         port,
+		User:         "root",
+		IdentityFile: "/home/test/.vmterminal/ssh/vmterminal",
+	}
+}
+
+func TestSSHConfigEntryInsert(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".ssh", "config")
+	existing := "Host github.com\n    User git\n"
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(existing), 0600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	if err := writeSSHConfigEntry(path, testSSHEntry(2222)); err != nil {
+		t.Fatalf("writeSSHConfigEntry: %v", err)
+	}
+	first, _ := os.ReadFile(path)
+
+	if !strings.HasPrefix(string(first), existing) {
+		t.Errorf("existing entries should be preserved:\n%s", first)
+	}
+	for _, want := range []string{"Host vmterminal", "HostName localhost", "Port 2222", "User root", "IdentityFile /home/test/.vmterminal/ssh/vmterminal", "StrictHostKeyChecking no"} {
+		if !strings.Contains(string(first), want) {
+			t.Errorf("config missing %q:\n%s", want, first)
+		}
+	}
+
+	// Writing the same entry again is a no-op
+	if err := writeSSHConfigEntry(path, testSSHEntry(2222)); err != nil {
+		t.Fatalf("writeSSHConfigEntry: %v", err)
+	}
+	second, _ := os.ReadFile(path)
+	if string(first) != string(second) {
+		t.Errorf("second write changed config:\n--- first\n%s--- second\n%s", first, second)
+	}
+}
+
+func TestSSHConfigEntryUpdate(t *testing.T) {
+	content := "Host vmterminal\n    Port 2222\n    ForwardAgent yes\n\nHost other\n    Port 22\n"
+
+	updated := upsertSSHConfig(content, testSSHEntry(2223))
+
+	if strings.Count(updated, "Host vmterminal") != 1 {
+		t.Errorf("expected exactly one vmterminal block:\n%s", updated)
+	}
+	if !strings.Contains(updated, "Port 2223") || strings.Contains(updated, "Port 2222") {
+		t.Errorf("port not updated:\n%s", updated)
+	}
+	if !strings.Contains(updated, "ForwardAgent yes") {
+		t.Errorf("user options in the block should be preserved:\n%s", updated)
+	}
+	if !strings.HasSuffix(updated, "Host other\n    Port 22\n") {
+		t.Errorf("following block should be untouched:\n%s", updated)
+	}
+}
+
+func TestSSHConfigEntryRemove(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config")
+	existing := "Host github.com\n    User git\n"
+	if err := os.WriteFile(path, []byte(existing), 0600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if err := writeSSHConfigEntry(path, testSSHEntry(2222)); err != nil {
+		t.Fatalf("writeSSHConfigEntry: %v", err)
+	}
+
+	removed, err := removeSSHConfigEntry(path, "vmterminal")
+	if err != nil {
+		t.Fatalf("removeSSHConfigEntry: %v", err)
+	}
+	if !removed {
+		t.Error("expected block to be removed")
+	}
+	data, _ := os.ReadFile(path)
+	if string(data) != existing {
+		t.Errorf("config after removal = %q, want %q", data, existing)
+	}
+
+	// Removing again reports nothing to do
+	removed, err = removeSSHConfigEntry(path, "vmterminal")
+	if err != nil {
+		t.Fatalf("removeSSHConfigEntry: %v", err)
+	}
+	if removed {
+		t.Error("second removal should report no block")
+	}
+}
@@ -2,9 +2,10 @@ package cli
 
 import (
 	"fmt"
-	"os"
+	"io"
 	"path/filepath"
 	"runtime"
+	"sort"
 
 	"github.com/javanstorm/vmterminal/internal/config"
 	"github.com/javanstorm/vmterminal/internal/distro"
@@ -20,6 +21,51 @@ var statusCmd = &cobra.Command{
 	RunE:  runStatus,
 }
 
+var statusVM string
+
+func init() {
+	statusCmd.Flags().StringVar(&statusVM, "vm", "", "VM to show (default: active VM)")
+}
+
+// statusResult is the structured output of the status command.
+type statusResult struct {
+	VM               string   `json:"vm"`
+	Arch             string   `json:"arch"`
+	HostOS           string   `json:"host_os"`
+	Hypervisor       string   `json:"hypervisor,omitempty"`
+	HypervisorVer    string   `json:"hypervisor_version,omitempty"`
+	HypervisorErr    string   `json:"hypervisor_error,omitempty"`
+	Distro           string   `json:"distro"`
+	DistroName       string   `json:"distro_name,omitempty"`
+	DistroVersion    string   `json:"distro_version,omitempty"`
+	DistroArchs      []string `json:"distro_archs,omitempty"`
+	KernelVersion    string   `json:"kernel_version,omitempty"`
+	Available        []string `json:"available_distros"`
+	Running          bool     `json:"running"`
+	Suspended        bool     `json:"suspended"`
+	DiskCreated      bool     `json:"disk_created"`
+	DiskMB           float64  `json:"disk_mb,omitempty"`
+	DiskAllocatedMB  float64  `json:"disk_allocated_mb,omitempty"`
+	Setup            string   `json:"setup"`
+	FSType           string   `json:"fs_type,omitempty"`
+	BootCount        int      `json:"boot_count"`
+	LastBoot         string   `json:"last_boot,omitempty"`
+	PanicCount       int      `json:"panic_count,omitempty"`
+	LastPanic        string   `json:"last_panic,omitempty"`
+	CPUs             int      `json:"cpus"`
+	MemoryMB         int      `json:"memory_mb"`
+	DiskSizeMB       int      `json:"disk_size_mb"`
+	Network          bool     `json:"network"`
+	SSHPort          int      `json:"ssh_port"`
+	SharedDirs       []string `json:"shared_dirs"`
+	DefaultTerminal  bool     `json:"default_terminal"`
+	Assets           string   `json:"assets,omitempty"`
+	Kernel           string   `json:"kernel,omitempty"`
+	Initramfs        string   `json:"initramfs,omitempty"`
+	Rootfs           string   `json:"rootfs,omitempty"`
+	providerResolved bool
+}
+
 func runStatus(cmd *cobra.Command, args []string) error {
 	// Load config
 	cfg, err := config.LoadState()
@@ -28,138 +74,228 @@ func runStatus(cmd *cobra.Command, args []string) error {
 	}
 
 	// Get paths
-	homeDir, err := os.UserHomeDir()
+	baseDir, err := baseDirectory()
+	if err != nil {
+		return err
+	}
+	vmName, entry, err := resolveVM(baseDir, statusVM)
 	if err != nil {
-		return fmt.Errorf("get home dir: %w", err)
+		return err
+	}
+	if entry != nil {
+		cfg = entry.EffectiveState(cfg)
 	}
-	baseDir := filepath.Join(homeDir, ".vmterminal")
-	dataDir := filepath.Join(baseDir, "data", "default")
+	dataDir := filepath.Join(baseDir, "data", vmName)
 	cacheDir := filepath.Join(baseDir, "cache")
 
-	fmt.Println("VMTerminal Status")
-	fmt.Println("=================")
-	fmt.Println()
-
-	// System Information
-	fmt.Println("System:")
-	fmt.Printf("  Architecture: %s\n", formatArch(runtime.GOARCH))
-	fmt.Printf("  Host OS: %s\n", formatOS(runtime.GOOS))
+	res := &statusResult{
+		VM:              vmName,
+		Arch:            runtime.GOARCH,
+		HostOS:          runtime.GOOS,
+		Distro:          cfg.Distro,
+		CPUs:            cfg.CPUs,
+		MemoryMB:        cfg.MemoryMB,
+		DiskSizeMB:      cfg.DiskSizeMB,
+		Network:         cfg.EnableNetwork,
+		SSHPort:         cfg.SSHHostPort,
+		SharedDirs:      sharedDirStrings(cfg.SharedDirs),
+		DefaultTerminal: cfg.IsDefaultTerminal,
+	}
 
 	// Get hypervisor info
 	driver, err := hypervisor.NewDriver()
 	if err != nil {
-		fmt.Printf("  Hypervisor: unavailable (%v)\n", err)
+		res.HypervisorErr = err.Error()
 	} else {
 		info := driver.Info()
-		fmt.Printf("  Hypervisor: %s v%s (%s)\n", info.Name, info.Version, info.Arch)
+		res.Hypervisor = info.Name
+		res.HypervisorVer = info.Version
 	}
-	fmt.Println()
 
 	// Distro Information
-	fmt.Println("Distro:")
 	distroID := distro.ID(cfg.Distro)
 	if distroID == "" {
 		distroID = distro.DefaultID()
 	}
 
 	provider, err := distro.Get(distroID)
-	if err != nil {
-		fmt.Printf("  Current: %s (unknown)\n", cfg.Distro)
-	} else {
-		fmt.Printf("  Current: %s %s\n", provider.Name(), provider.Version())
-		fmt.Printf("  Supported architectures: %v\n", provider.SupportedArchs())
+	if err == nil {
+		res.providerResolved = true
+		res.DistroName = provider.Name()
+		res.DistroVersion = provider.Version()
+		for _, a := range provider.SupportedArchs() {
+			res.DistroArchs = append(res.DistroArchs, string(a))
+		}
+	}
+	for _, id := range distro.List() {
+		res.Available = append(res.Available, string(id))
 	}
-	fmt.Printf("  Available: %v\n", distro.List())
-	fmt.Println()
+	sort.Strings(res.Available)
 
 	// VM State
-	fmt.Println("VM State:")
-	isRunning := isVMRunningCheck(baseDir, "default")
-	if isRunning {
-		fmt.Println("  Status: RUNNING")
-	} else {
-		fmt.Println("  Status: stopped")
-	}
+	res.Running = isVMRunningCheck(baseDir, vmName)
 
 	// Check disk and setup state
 	images := vm.NewImageManager(dataDir)
-	rootfs := vm.NewRootfsManager(dataDir)
+	rootfs := vm.NewRootfsManager(dataDir, nil)
 
+	res.Setup = "not done"
 	if images.DiskExists("disk") {
 		diskPath := images.DiskPath("disk")
-		info, err := os.Stat(diskPath)
+		allocated, total, err := vm.DiskActualSize(diskPath)
 		if err == nil {
-			fmt.Printf("  Disk: %.2f MB (allocated)\n", float64(info.Size())/(1024*1024))
+			res.DiskCreated = true
+			res.DiskMB = float64(total) / (1024 * 1024)
+			res.DiskAllocatedMB = float64(allocated) / (1024 * 1024)
 
 			state, err := rootfs.CheckSetupState("disk")
 			if err != nil {
-				fmt.Printf("  Setup: error checking (%v)\n", err)
+				res.Setup = fmt.Sprintf("error checking (%v)", err)
 			} else if state.RootfsExtracted {
-				fmt.Printf("  Setup: complete (%s)\n", state.FSType)
+				res.Setup = "complete"
+				res.FSType = state.FSType
 			} else if state.DiskFormatted {
-				fmt.Println("  Setup: formatted, rootfs not extracted")
-			} else {
-				fmt.Println("  Setup: not done")
+				res.Setup = "formatted, rootfs not extracted"
 			}
 		}
-	} else {
-		fmt.Println("  Disk: not created")
-		fmt.Println("  Setup: not done (run 'vmterminal run' to set up)")
 	}
 
 	// Boot history
 	stateFile := vm.NewStateFile(dataDir)
 	vmState, err := stateFile.Load()
+	if err == nil && res.Running {
+		res.Suspended = vmState.Suspended
+	}
+	if err == nil {
+		res.KernelVersion = vmState.KernelVersion
+	}
 	if err == nil && vmState.BootCount > 0 {
-		fmt.Printf("  Boot count: %d\n", vmState.BootCount)
+		res.BootCount = vmState.BootCount
 		if !vmState.LastBoot.IsZero() {
-			fmt.Printf("  Last boot: %s\n", vmState.LastBoot.Format("2006-01-02 15:04:05"))
+			res.LastBoot = vmState.LastBoot.Format("2006-01-02 15:04:05")
 		}
-	}
-	fmt.Println()
-
-	// Configuration
-	fmt.Println("Configuration:")
-	fmt.Printf("  CPUs: %d\n", cfg.CPUs)
-	fmt.Printf("  Memory: %d MB\n", cfg.MemoryMB)
-	fmt.Printf("  Disk Size: %d MB\n", cfg.DiskSizeMB)
-	fmt.Printf("  Network: %s\n", formatEnabled(cfg.EnableNetwork))
-	fmt.Printf("  SSH Port: %d\n", cfg.SSHHostPort)
-	if len(cfg.SharedDirs) > 0 {
-		fmt.Printf("  Shared Dirs: %s\n", cfg.SharedDirs[0])
-		for _, dir := range cfg.SharedDirs[1:] {
-			fmt.Printf("               %s\n", dir)
+		res.PanicCount = vmState.PanicCount
+		if !vmState.LastPanic.IsZero() {
+			res.LastPanic = vmState.LastPanic.Format("2006-01-02 15:04:05")
 		}
-	} else {
-		fmt.Println("  Shared Dirs: (none)")
 	}
-	fmt.Printf("  Default Terminal: %s\n", formatEnabled(cfg.IsDefaultTerminal))
-	fmt.Println()
 
 	// Assets
 	if provider != nil {
-		fmt.Println("Assets:")
-		assets := vm.NewAssetManager(cacheDir, provider)
+		assets := vm.NewAssetManager(cacheDir, provider, nil)
 		assetPaths, err := assets.GetAssetPaths()
 		if err != nil {
-			fmt.Println("  Status: not downloaded")
+			res.Assets = "not downloaded"
 		} else {
 			hasKernel := assetPaths.Kernel != ""
 			hasInitramfs := assetPaths.Initramfs != ""
 			hasRootfs := assetPaths.Rootfs != ""
 
 			if hasKernel && hasInitramfs && hasRootfs {
-				fmt.Println("  Status: downloaded")
-				fmt.Printf("  Kernel: %s\n", filepath.Base(assetPaths.Kernel))
-				fmt.Printf("  Initramfs: %s\n", filepath.Base(assetPaths.Initramfs))
-				fmt.Printf("  Rootfs: %s\n", filepath.Base(assetPaths.Rootfs))
+				res.Assets = "downloaded"
+				res.Kernel = filepath.Base(assetPaths.Kernel)
+				res.Initramfs = filepath.Base(assetPaths.Initramfs)
+				res.Rootfs = filepath.Base(assetPaths.Rootfs)
 			} else {
-				fmt.Println("  Status: partially downloaded")
+				res.Assets = "partially downloaded"
 			}
 		}
 	}
 
-	return nil
+	return printResult(res)
+}
+
+// RenderHuman prints the status report as text.
+func (r *statusResult) RenderHuman(w io.Writer) {
+	fmt.Fprintln(w, "VMTerminal Status")
+	fmt.Fprintln(w, "=================")
+	fmt.Fprintln(w)
+
+	// System Information
+	fmt.Fprintln(w, "System:")
+	fmt.Fprintf(w, "  Architecture: %s\n", formatArch(r.Arch))
+	fmt.Fprintf(w, "  Host OS: %s\n", formatOS(r.HostOS))
+	if r.HypervisorErr != "" {
+		fmt.Fprintf(w, "  Hypervisor: unavailable (%s)\n", r.HypervisorErr)
+	} else {
+		fmt.Fprintf(w, "  Hypervisor: %s v%s (%s)\n", r.Hypervisor, r.HypervisorVer, r.Arch)
+	}
+	fmt.Fprintln(w)
+
+	// Distro Information
+	fmt.Fprintln(w, "Distro:")
+	if !r.providerResolved {
+		fmt.Fprintf(w, "  Current: %s (unknown)\n", r.Distro)
+	} else {
+		if r.KernelVersion != "" {
+			fmt.Fprintf(w, "  Current: %s %s (kernel %s)\n", r.DistroName, r.DistroVersion, r.KernelVersion)
+		} else {
+			fmt.Fprintf(w, "  Current: %s %s\n", r.DistroName, r.DistroVersion)
+		}
+		fmt.Fprintf(w, "  Supported architectures: %v\n", r.DistroArchs)
+	}
+	fmt.Fprintf(w, "  Available: %v\n", r.Available)
+	fmt.Fprintln(w)
+
+	// VM State
+	fmt.Fprintln(w, "VM State:")
+	if r.Suspended {
+		fmt.Fprintln(w, "  Status: SUSPENDED (run 'vmterminal resume' to continue)")
+	} else if r.Running {
+		fmt.Fprintln(w, "  Status: RUNNING")
+	} else {
+		fmt.Fprintln(w, "  Status: stopped")
+	}
+	if r.DiskCreated {
+		fmt.Fprintf(w, "  Disk: %.2f MB allocated of %.2f MB\n", r.DiskAllocatedMB, r.DiskMB)
+		if r.FSType != "" {
+			fmt.Fprintf(w, "  Setup: %s (%s)\n", r.Setup, r.FSType)
+		} else {
+			fmt.Fprintf(w, "  Setup: %s\n", r.Setup)
+		}
+	} else {
+		fmt.Fprintln(w, "  Disk: not created")
+		fmt.Fprintln(w, "  Setup: not done (run 'vmterminal run' to set up)")
+	}
+	if r.BootCount > 0 {
+		fmt.Fprintf(w, "  Boot count: %d\n", r.BootCount)
+		if r.LastBoot != "" {
+			fmt.Fprintf(w, "  Last boot: %s\n", r.LastBoot)
+		}
+	}
+	if r.PanicCount > 0 {
+		fmt.Fprintf(w, "  Kernel panics: %d (last %s)\n", r.PanicCount, r.LastPanic)
+	}
+	fmt.Fprintln(w)
+
+	// Configuration
+	fmt.Fprintln(w, "Configuration:")
+	fmt.Fprintf(w, "  CPUs: %d\n", r.CPUs)
+	fmt.Fprintf(w, "  Memory: %d MB\n", r.MemoryMB)
+	fmt.Fprintf(w, "  Disk Size: %d MB\n", r.DiskSizeMB)
+	fmt.Fprintf(w, "  Network: %s\n", formatEnabled(r.Network))
+	fmt.Fprintf(w, "  SSH Port: %d\n", r.SSHPort)
+	if len(r.SharedDirs) > 0 {
+		fmt.Fprintf(w, "  Shared Dirs: %s\n", r.SharedDirs[0])
+		for _, dir := range r.SharedDirs[1:] {
+			fmt.Fprintf(w, "               %s\n", dir)
+		}
+	} else {
+		fmt.Fprintln(w, "  Shared Dirs: (none)")
+	}
+	fmt.Fprintf(w, "  Default Terminal: %s\n", formatEnabled(r.DefaultTerminal))
+	fmt.Fprintln(w)
+
+	// Assets
+	if r.Assets != "" {
+		fmt.Fprintln(w, "Assets:")
+		fmt.Fprintf(w, "  Status: %s\n", r.Assets)
+		if r.Kernel != "" {
+			fmt.Fprintf(w, "  Kernel: %s\n", r.Kernel)
+			fmt.Fprintf(w, "  Initramfs: %s\n", r.Initramfs)
+			fmt.Fprintf(w, "  Rootfs: %s\n", r.Rootfs)
+		}
+	}
 }
 
 // formatArch returns a human-readable architecture name.
@@ -193,3 +329,12 @@ func formatEnabled(b bool) string {
 	}
 	return "disabled"
 }
+
+// sharedDirStrings formats shared directories in <path>[:ro] notation.
+func sharedDirStrings(dirs []config.SharedDir) []string {
+	out := make([]string, len(dirs))
+	for i, d := range dirs {
+		out[i] = d.String()
+	}
+	return out
+}
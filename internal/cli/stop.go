@@ -2,11 +2,14 @@ package cli
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"syscall"
 	"time"
 
+	"github.com/javanstorm/vmterminal/internal/config"
+	"github.com/javanstorm/vmterminal/internal/vm"
 	"github.com/spf13/cobra"
 )
 
@@ -15,86 +18,189 @@ var stopCmd = &cobra.Command{
 	Short: "Stop the running VM",
 	Long: `Stop the running VM gracefully.
 
-If the VM is running in the foreground, use Ctrl+C instead.
-This command attempts to stop a VM that may be running in another terminal.
+Sends SIGTERM to the vmterminal process running the VM and waits up to
+--stop-timeout for it to shut down, then sends SIGKILL if it is still
+running after a further 10s grace period. The timeout defaults to
+stop_timeout_seconds in the config, or 30s; the VM process gives its guest
+the same time before killing it, and the grace period lets it clean up
+after doing so.
+Exits non-zero if the VM was not running, so scripts can use
+'vmterminal stop || true'.
 
 Examples:
-  vmt stop           # Graceful shutdown (SIGTERM)
-  vmt stop --force   # Force kill (SIGKILL)
-  vmt stop -f        # Force kill (short form)`,
+  vmt stop                     # Graceful shutdown, SIGKILL after 30s + 10s
+  vmt stop --stop-timeout 2m   # Wait longer for the guest to shut down
+  vmt stop --vm dev       # Stop a specific VM
+  vmt stop --force        # Force kill (SIGKILL) immediately`,
+	Args: cobra.NoArgs,
 	RunE: runStop,
 }
 
-var stopForce bool
+// stopPollInterval is how often stop checks whether the VM has exited.
+const stopPollInterval = 100 * time.Millisecond
+
+// stopKillGrace is how long stop waits past the stop timeout before
+// SIGKILL. The VM process kills its own guest at the stop timeout and
+// then records the stop and removes its files; killing it at the same
+// moment would cut that short.
+const stopKillGrace = 10 * time.Second
+
+var (
+	stopForce   bool
+	stopVM      string
+	stopTimeout time.Duration
+)
 
 func init() {
 	stopCmd.Flags().BoolVarP(&stopForce, "force", "f", false, "Force kill the VM (SIGKILL)")
+	stopCmd.Flags().StringVar(&stopVM, "vm", "", "VM to stop (default: active VM)")
+	stopCmd.Flags().DurationVar(&stopTimeout, "stop-timeout", 0, "How long to wait for a graceful shutdown before SIGKILL (default: config stop_timeout_seconds, or 30s)")
+	stopCmd.Flags().DurationVar(&stopTimeout, "timeout", 0, "How long to wait for a graceful shutdown before SIGKILL")
+	stopCmd.Flags().MarkDeprecated("timeout", "use --stop-timeout")
+}
+
+// stopResult is the structured output of the stop command.
+type stopResult struct {
+	VM       string `json:"vm"`
+	PID      int    `json:"pid,omitempty"`
+	Action   string `json:"action"` // not_running, stale, stopped, killed
+	TimedOut bool   `json:"timed_out,omitempty"`
+}
+
+// RenderHuman prints the stop outcome as text.
+func (r *stopResult) RenderHuman(w io.Writer) {
+	switch r.Action {
+	case "not_running":
+		fmt.Fprintf(w, "VM '%s' is not running (no PID file found).\n", r.VM)
+		fmt.Fprintln(w, "To start the VM, run: vmterminal run")
+	case "stale":
+		fmt.Fprintf(w, "VM process (PID %d) is not running (stale PID).\n", r.PID)
+		fmt.Fprintln(w, "Cleaned up stale PID file.")
+	case "stopped":
+		fmt.Fprintf(w, "VM '%s' stopped.\n", r.VM)
+	case "killed":
+		if r.TimedOut {
+			fmt.Fprintf(w, "VM '%s' did not shut down in time and was killed.\n", r.VM)
+		} else {
+			fmt.Fprintln(w, "VM force killed.")
+		}
+	}
 }
 
 func runStop(cmd *cobra.Command, args []string) error {
-	homeDir, err := os.UserHomeDir()
+	baseDir, err := baseDirectory()
+	if err != nil {
+		return err
+	}
+	// An explicit name is used as given, so a VM whose registry entry is
+	// gone can still be stopped
+	vmName := stopVM
+	if vmName == "" {
+		if vmName, _, err = resolveVM(baseDir, ""); err != nil {
+			return err
+		}
+	}
+	res, err := stopNamedVM(baseDir, vmName, effectiveStopTimeout(), stopForce, progressf)
 	if err != nil {
-		return fmt.Errorf("get home dir: %w", err)
+		return err
 	}
-	baseDir := filepath.Join(homeDir, ".vmterminal")
-	vmName := "default"
-	dataDir := filepath.Join(baseDir, "data", vmName)
+	if res.Action == "not_running" || res.Action == "stale" {
+		return notRunning(res)
+	}
+	return printResult(res)
+}
+
+// stopNamedVM stops the VM called name: SIGTERM, then SIGKILL if it has
+// not exited after timeout plus stopKillGrace, or SIGKILL at once with
+// force. Progress goes
+// to logf.
+func stopNamedVM(baseDir, name string, timeout time.Duration, force bool, logf func(format string, args ...interface{})) (*stopResult, error) {
+	dataDir := filepath.Join(baseDir, "data", name)
+	res := &stopResult{VM: name}
 
 	// Check for PID file
 	pidFile := filepath.Join(dataDir, "vm.pid")
-	data, err := os.ReadFile(pidFile)
+	pid, exe, err := readPIDFile(pidFile)
 	if err != nil {
 		if os.IsNotExist(err) {
-			fmt.Println("VM is not running (no PID file found).")
-			fmt.Println("To start the VM, run: vmterminal run")
-			return nil
+			res.Action = "not_running"
+			return res, nil
 		}
-		return fmt.Errorf("read PID file: %w", err)
-	}
-
-	var pid int
-	if _, err := fmt.Sscanf(string(data), "%d", &pid); err != nil {
-		return fmt.Errorf("parse PID: %w", err)
+		return nil, fmt.Errorf("read PID file: %w", err)
 	}
+	res.PID = pid
 
-	// Check if process exists
+	// Check the process exists and is still a vmterminal VM, not an
+	// unrelated process that reused the PID
 	process, err := os.FindProcess(pid)
-	if err != nil {
-		fmt.Printf("VM process (PID %d) not found.\n", pid)
-		cleanupVMFiles(dataDir, pidFile)
-		return nil
-	}
-
-	// Try to signal the process to check if it's running
-	if err := process.Signal(syscall.Signal(0)); err != nil {
-		fmt.Printf("VM process (PID %d) is not running (stale PID).\n", pid)
+	if err != nil || process.Signal(syscall.Signal(0)) != nil ||
+		(exe != "" && !processRunsExecutable(pid, exe)) {
 		cleanupVMFiles(dataDir, pidFile)
-		fmt.Println("Cleaned up stale PID file.")
-		return nil
+		res.Action = "stale"
+		return res, nil
 	}
 
-	if stopForce {
-		// Force kill with SIGKILL
-		fmt.Printf("Force killing VM (PID %d)...\n", pid)
-		if err := process.Signal(syscall.SIGKILL); err != nil {
-			return fmt.Errorf("send SIGKILL: %w", err)
-		}
-		// Wait a moment for process to die
-		time.Sleep(500 * time.Millisecond)
-		cleanupVMFiles(dataDir, pidFile)
-		fmt.Println("VM force killed.")
-	} else {
+	if !force {
 		// Send SIGTERM for graceful shutdown
-		fmt.Printf("Stopping VM (PID %d)...\n", pid)
+		logf("Stopping VM (PID %d)...\n", pid)
 		if err := process.Signal(syscall.SIGTERM); err != nil {
-			return fmt.Errorf("send SIGTERM: %w", err)
+			return nil, fmt.Errorf("send SIGTERM: %w", err)
 		}
-		fmt.Println("Stop signal sent.")
-		fmt.Println("The VM should shut down gracefully.")
-		fmt.Println("Use 'vmt stop --force' if it doesn't respond.")
+		if waitForExit(process, timeout+stopKillGrace) {
+			cleanupVMFiles(dataDir, pidFile)
+			res.Action = "stopped"
+			return res, nil
+		}
+		logf("VM did not stop within %s, killing it...\n", timeout+stopKillGrace)
+		res.TimedOut = true
+	} else {
+		logf("Force killing VM (PID %d)...\n", pid)
+	}
+
+	if err := process.Signal(syscall.SIGKILL); err != nil {
+		return nil, fmt.Errorf("send SIGKILL: %w", err)
+	}
+	// SIGKILL cannot be caught, so this only waits for the kernel to reap it
+	waitForExit(process, time.Second)
+	cleanupVMFiles(dataDir, pidFile)
+	res.Action = "killed"
+	return res, nil
+}
+
+// effectiveStopTimeout returns --stop-timeout, or else the configured
+// stop_timeout_seconds, or else vm.DefaultStopTimeout.
+func effectiveStopTimeout() time.Duration {
+	if stopTimeout > 0 {
+		return stopTimeout
+	}
+	if cfg, err := config.LoadState(); err == nil && cfg.StopTimeoutSeconds > 0 {
+		return time.Duration(cfg.StopTimeoutSeconds) * time.Second
+	}
+	return vm.DefaultStopTimeout
+}
+
+// notRunning prints res and returns an error so stop exits non-zero when
+// there was no VM to stop.
+func notRunning(res *stopResult) error {
+	if err := printResult(res); err != nil {
+		return err
 	}
+	return &ExitCodeError{Code: 1}
+}
 
-	return nil
+// waitForExit polls process until it exits or timeout passes, and reports
+// whether it exited.
+func waitForExit(process *os.Process, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for {
+		if err := process.Signal(syscall.Signal(0)); err != nil {
+			return true
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(stopPollInterval)
+	}
 }
 
 // cleanupVMFiles removes PID file and lock file
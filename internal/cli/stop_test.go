@@ -0,0 +1,124 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// startStopTarget starts a long-running child process and writes a legacy
+// PID file for it under a temporary HOME. The child is reaped in the
+// background so it disappears as soon as it exits.
+func startStopTarget(t *testing.T, vmName string, args ...string) (*exec.Cmd, string) {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	dataDir := filepath.Join(home, ".vmterminal", "data", vmName)
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+
+	cmd := exec.Command(args[0], args[1:]...)
+	if err := cmd.Start(); err != nil {
+		t.Skipf("start %s: %v", args[0], err)
+	}
+	go cmd.Wait()
+	t.Cleanup(func() { cmd.Process.Kill() })
+
+	pidFile := filepath.Join(dataDir, "vm.pid")
+	os.WriteFile(pidFile, []byte(fmt.Sprintf("%d", cmd.Process.Pid)), 0644)
+	return cmd, pidFile
+}
+
+func setStopFlags(t *testing.T, vmName string, force bool, timeout time.Duration) {
+	t.Helper()
+	origVM, origForce, origTimeout := stopVM, stopForce, stopTimeout
+	t.Cleanup(func() { stopVM, stopForce, stopTimeout = origVM, origForce, origTimeout })
+	stopVM, stopForce, stopTimeout = vmName, force, timeout
+}
+
+func TestRunStopGraceful(t *testing.T) {
+	_, pidFile := startStopTarget(t, "dev", "sleep", "30")
+	setStopFlags(t, "dev", false, 5*time.Second)
+
+	if err := runStop(stopCmd, nil); err != nil {
+		t.Fatalf("runStop: %v", err)
+	}
+	if _, err := os.Stat(pidFile); !os.IsNotExist(err) {
+		t.Error("PID file should be removed after stop")
+	}
+}
+
+func TestRunStopEscalatesToKill(t *testing.T) {
+	// The shell ignores SIGTERM, so stop has to fall back to SIGKILL
+	cmd, _ := startStopTarget(t, "dev", "sh", "-c", "trap '' TERM; while :; do sleep 0.1; done")
+	setStopFlags(t, "dev", false, 300*time.Millisecond)
+	time.Sleep(100 * time.Millisecond) // let the trap be installed
+
+	start := time.Now()
+	if err := runStop(stopCmd, nil); err != nil {
+		t.Fatalf("runStop: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 300*time.Millisecond {
+		t.Errorf("stop returned after %s, before the timeout", elapsed)
+	}
+	if !waitForExit(cmd.Process, time.Second) {
+		t.Error("process should have been killed")
+	}
+}
+
+func TestRunStopNotRunning(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	setStopFlags(t, "missing", false, time.Second)
+
+	var exitErr *ExitCodeError
+	if err := runStop(stopCmd, nil); !errors.As(err, &exitErr) || exitErr.Code != 1 {
+		t.Errorf("runStop error = %v, want exit code 1", err)
+	}
+}
+
+func TestReadPIDFile(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		content string
+		pid     int
+		exe     string
+		wantErr bool
+	}{
+		{"1234", 1234, "", false},
+		{"1234\n", 1234, "", false},
+		{"1234\n/usr/local/bin/vmterminal\n", 1234, "/usr/local/bin/vmterminal", false},
+		{"not-a-number", 0, "", true},
+	}
+	for i, tt := range tests {
+		path := filepath.Join(dir, fmt.Sprintf("vm%d.pid", i))
+		os.WriteFile(path, []byte(tt.content), 0644)
+
+		pid, exe, err := readPIDFile(path)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("readPIDFile(%q) error = %v, wantErr %v", tt.content, err, tt.wantErr)
+			continue
+		}
+		if pid != tt.pid || exe != tt.exe {
+			t.Errorf("readPIDFile(%q) = %d, %q; want %d, %q", tt.content, pid, exe, tt.pid, tt.exe)
+		}
+	}
+}
+
+func TestIsVMRunningReusedPID(t *testing.T) {
+	tmpDir := t.TempDir()
+	dataDir := filepath.Join(tmpDir, "data", "test-vm")
+	os.MkdirAll(dataDir, 0755)
+
+	// The PID is live (this process) but belongs to a different binary
+	content := fmt.Sprintf("%d\n/nonexistent/vmterminal\n", os.Getpid())
+	os.WriteFile(filepath.Join(dataDir, "vm.pid"), []byte(content), 0644)
+
+	if running, _ := isVMRunning(tmpDir, "test-vm"); running {
+		t.Error("a PID reused by another binary should not count as running")
+	}
+}
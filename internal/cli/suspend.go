@@ -0,0 +1,210 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/javanstorm/vmterminal/internal/vm"
+	"github.com/spf13/cobra"
+)
+
+// Signals sent to the 'vmterminal run' process to pause and continue its VM.
+const (
+	suspendSignal = syscall.SIGUSR1
+	resumeSignal  = syscall.SIGUSR2
+)
+
+// pauseAckTimeout bounds how long suspend and resume wait for the VM
+// process to acknowledge a request, in case it is hung.
+const pauseAckTimeout = 10 * time.Second
+
+var suspendCmd = &cobra.Command{
+	Use:   "suspend",
+	Short: "Pause the running VM",
+	Long: `Pause the running VM without shutting it down.
+
+The VM keeps its memory but stops using CPU until resumed with
+'vmterminal resume'. Suspend is supported on macOS only; Linux KVM
+cannot pause a running VM.`,
+	RunE: runSuspend,
+}
+
+var resumeCmd = &cobra.Command{
+	Use:   "resume",
+	Short: "Continue a suspended VM",
+	Long:  `Continue a VM previously paused with 'vmterminal suspend'.`,
+	RunE:  runResume,
+}
+
+var pauseVM string
+
+func init() {
+	suspendCmd.Flags().StringVar(&pauseVM, "vm", "", "VM to suspend (default: active VM)")
+	resumeCmd.Flags().StringVar(&pauseVM, "vm", "", "VM to resume (default: active VM)")
+}
+
+// suspendResult is the structured output of the suspend and resume commands.
+type suspendResult struct {
+	VM     string `json:"vm"`
+	PID    int    `json:"pid,omitempty"`
+	Action string `json:"action"` // not_running, suspended, resumed
+}
+
+// RenderHuman prints the suspend/resume outcome as text.
+func (r *suspendResult) RenderHuman(w io.Writer) {
+	switch r.Action {
+	case "not_running":
+		fmt.Fprintln(w, "VM is not running.")
+		fmt.Fprintln(w, "To start the VM, run: vmterminal run")
+	case "suspended":
+		fmt.Fprintln(w, "VM suspended.")
+		fmt.Fprintln(w, "Run 'vmterminal resume' to continue.")
+	case "resumed":
+		fmt.Fprintln(w, "VM resumed.")
+	}
+}
+
+func runSuspend(cmd *cobra.Command, args []string) error {
+	return signalPauseState(suspendSignal, true)
+}
+
+func runResume(cmd *cobra.Command, args []string) error {
+	return signalPauseState(resumeSignal, false)
+}
+
+// signalPauseState asks the running VM process to suspend or resume, then
+// waits for it to acknowledge the request in the state file.
+func signalPauseState(sig syscall.Signal, suspend bool) error {
+	baseDir, err := baseDirectory()
+	if err != nil {
+		return err
+	}
+	vmName, _, err := resolveVM(baseDir, pauseVM)
+	if err != nil {
+		return err
+	}
+	dataDir := filepath.Join(baseDir, "data", vmName)
+	res := &suspendResult{VM: vmName}
+
+	running, pid := isVMRunning(baseDir, vmName)
+	if !running {
+		res.Action = "not_running"
+		return printResult(res)
+	}
+	res.PID = pid
+
+	stateFile := vm.NewStateFile(dataDir)
+	if state, err := stateFile.Load(); err == nil && state.Suspended == suspend {
+		if suspend {
+			return fmt.Errorf("VM is already suspended")
+		}
+		return fmt.Errorf("VM is not suspended")
+	}
+
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return fmt.Errorf("find VM process: %w", err)
+	}
+	sent := time.Now()
+	if err := process.Signal(sig); err != nil {
+		return fmt.Errorf("signal VM process: %w", err)
+	}
+
+	// The run process acknowledges the request in the state file once the
+	// driver has carried it out or refused it
+	deadline := time.Now().Add(pauseAckTimeout)
+	for time.Now().Before(deadline) {
+		state, err := stateFile.Load()
+		if err != nil || !state.PauseAck.After(sent) {
+			time.Sleep(50 * time.Millisecond)
+			continue
+		}
+		if state.PauseError != "" {
+			return errors.New(state.PauseError)
+		}
+		if suspend {
+			res.Action = "suspended"
+		} else {
+			res.Action = "resumed"
+		}
+		return printResult(res)
+	}
+	return fmt.Errorf("VM process %d did not answer within %s", pid, pauseAckTimeout)
+}
+
+// pauseHandler suspends and resumes the VM of a 'vmterminal run' process
+// in response to signals from 'vmterminal suspend' and 'vmterminal resume',
+// and acknowledges each request in the VM's state file.
+type pauseHandler struct {
+	mu        sync.Mutex
+	mgr       *vm.Manager
+	stateFile *vm.StateFile
+	sigCh     chan os.Signal
+	done      chan struct{}
+}
+
+// handlePauseSignals starts handling pause signals for mgr, whose data is
+// in dataDir. Call it before writing the PID file: the default action of
+// the signals ends the process. Call Stop to stop handling.
+func handlePauseSignals(mgr *vm.Manager, dataDir string) *pauseHandler {
+	h := &pauseHandler{
+		mgr:       mgr,
+		stateFile: vm.NewStateFile(dataDir),
+		sigCh:     make(chan os.Signal, 1),
+		done:      make(chan struct{}),
+	}
+	signal.Notify(h.sigCh, suspendSignal, resumeSignal)
+
+	go func() {
+		for {
+			select {
+			case sig := <-h.sigCh:
+				h.handle(sig)
+			case <-h.done:
+				return
+			}
+		}
+	}()
+	return h
+}
+
+// handle carries out one pause signal and acknowledges it.
+func (h *pauseHandler) handle(sig os.Signal) {
+	h.mu.Lock()
+	mgr := h.mgr
+	h.mu.Unlock()
+
+	var err error
+	if sig == suspendSignal {
+		err = mgr.Suspend(context.Background())
+	} else {
+		err = mgr.Resume(context.Background())
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+	if err := h.stateFile.RecordPauseAck(err); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not record pause state: %v\n", err)
+	}
+}
+
+// setManager switches to the manager of a restarted VM.
+func (h *pauseHandler) setManager(mgr *vm.Manager) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.mgr = mgr
+}
+
+// Stop stops handling pause signals.
+func (h *pauseHandler) Stop() {
+	signal.Stop(h.sigCh)
+	close(h.done)
+}
@@ -6,6 +6,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/javanstorm/vmterminal/internal/config"
 	"github.com/javanstorm/vmterminal/internal/distro"
@@ -20,15 +21,66 @@ var switchCmd = &cobra.Command{
 
 This command shows available distributions and lets you select a new one.
 The current VM data will be preserved, and a new VM will be set up for
-the selected distribution.`,
+the selected distribution.
+
+Before the disk is replaced, a snapshot named pre-switch-<distro>-<timestamp>
+is created so the previous VM can be restored with 'vmterminal snapshot restore'.
+If the snapshot cannot be created the switch is aborted unless --force is given.`,
 	RunE: runSwitch,
 }
 
+var (
+	switchForce bool
+	switchVM    string
+)
+
+func init() {
+	switchCmd.Flags().StringVar(&switchVM, "vm", "", "VM to switch (default: active VM)")
+	switchCmd.Flags().BoolVarP(&switchForce, "force", "f", false, "Switch even if the pre-switch snapshot cannot be created")
+	addDownloadLimitFlag(switchCmd)
+	addProxyFlag(switchCmd)
+	noJSON(switchCmd)
+}
+
+// snapshotCreator is the part of vm.SnapshotManager used by switch.
+type snapshotCreator interface {
+	CreateSnapshot(vmName, snapshotName, description string, opts ...vm.SnapshotOption) error
+}
+
+// preSwitchSnapshot snapshots the VM disk before a distro switch.
+// It returns the snapshot name, or "" if the snapshot failed and force is set.
+func preSwitchSnapshot(snaps snapshotCreator, vmName, distroID string, now time.Time, force bool) (string, error) {
+	name := fmt.Sprintf("pre-switch-%s-%s", distroID, now.Format("20060102-150405"))
+
+	fmt.Printf("Creating snapshot '%s'...\n", name)
+	if err := snaps.CreateSnapshot(vmName, name, "automatic pre-switch backup"); err != nil {
+		if !force {
+			return "", fmt.Errorf("create pre-switch snapshot: %w (use --force to switch anyway)", err)
+		}
+		fmt.Printf("Warning: could not create snapshot: %v\n", err)
+		return "", nil
+	}
+	return name, nil
+}
+
 func runSwitch(cmd *cobra.Command, args []string) error {
 	// Load current config
-	cfg, err := config.LoadState()
+	saved, err := config.LoadSavedState()
+	if err != nil {
+		saved = config.DefaultState()
+	}
+
+	baseDir, err := baseDirectory()
+	if err != nil {
+		return err
+	}
+	vmName, entry, err := resolveVM(baseDir, switchVM)
 	if err != nil {
-		cfg = config.DefaultState()
+		return err
+	}
+	cfg := saved
+	if entry != nil {
+		cfg = entry.EffectiveState(saved)
 	}
 
 	// Show current distro
@@ -84,16 +136,11 @@ func runSwitch(cmd *cobra.Command, args []string) error {
 	fmt.Printf("\nSwitching to %s %s...\n", selectedProvider.Name(), selectedProvider.Version())
 
 	// Setup paths
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		return fmt.Errorf("get home dir: %w", err)
-	}
-	baseDir := filepath.Join(homeDir, ".vmterminal")
 	cacheDir := filepath.Join(baseDir, "cache")
-	dataDir := filepath.Join(baseDir, "data", "default")
+	dataDir := filepath.Join(baseDir, "data", vmName)
 
 	// Check if current VM is running
-	if isVMRunningCheck(baseDir, "default") {
+	if isVMRunningCheck(baseDir, vmName) {
 		fmt.Println("\nWarning: The current VM appears to be running.")
 		fmt.Println("Please stop it first with 'vmterminal stop' or Ctrl+C.")
 		return fmt.Errorf("VM is running")
@@ -108,10 +155,18 @@ func runSwitch(cmd *cobra.Command, args []string) error {
 		return nil
 	}
 
-	// Backup current disk if exists
-	currentDisk := filepath.Join(dataDir, "disk.img")
+	// Snapshot and back up current disk if it exists
+	var snapshotName string
+	currentDisk := filepath.Join(dataDir, "disk.raw")
 	if _, err := os.Stat(currentDisk); err == nil {
-		backupDisk := filepath.Join(dataDir, fmt.Sprintf("disk-%s.img.bak", currentDistro))
+		snaps := vm.NewSnapshotManager(baseDir, newProgress())
+		snaps.SetRetention(snapshotRetention())
+		snapshotName, err = preSwitchSnapshot(snaps, vmName, currentDistro, time.Now(), switchForce)
+		if err != nil {
+			return err
+		}
+
+		backupDisk := filepath.Join(dataDir, fmt.Sprintf("disk-%s.raw.bak", currentDistro))
 		fmt.Printf("Backing up current disk to %s...\n", filepath.Base(backupDisk))
 		if err := os.Rename(currentDisk, backupDisk); err != nil {
 			fmt.Printf("Warning: Could not backup disk: %v\n", err)
@@ -120,7 +175,7 @@ func runSwitch(cmd *cobra.Command, args []string) error {
 
 	// Download new distro assets
 	fmt.Printf("Downloading %s...\n", selectedProvider.Name())
-	assets := vm.NewAssetManager(cacheDir, selectedProvider)
+	assets := newAssetManager(cacheDir, selectedProvider)
 	assetPaths, err := assets.EnsureAssets()
 	if err != nil {
 		return fmt.Errorf("get asset paths: %w", err)
@@ -139,7 +194,7 @@ func runSwitch(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("sudo permission required for setup")
 	}
 
-	rootfs := vm.NewRootfsManager(dataDir)
+	rootfs := vm.NewRootfsManager(dataDir, newProgress())
 	reqs := selectedProvider.SetupRequirements()
 	fsType := reqs.FSType
 	if fsType == "" {
@@ -156,14 +211,28 @@ func runSwitch(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("extract rootfs: %w", err)
 	}
 
-	// Update config
-	cfg.Distro = selectedID
-	if err := config.SaveState(cfg); err != nil {
-		return fmt.Errorf("save config: %w", err)
+	// Update config; a registered VM keeps its distro in the registry
+	if entry != nil {
+		err = vm.NewRegistry(baseDir).UpdateVM(vmName, func(e *vm.VMEntry) error {
+			e.Distro = selectedID
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("update VM: %w", err)
+		}
+	} else {
+		saved.Distro = selectedID
+		if err := config.SaveState(saved); err != nil {
+			return fmt.Errorf("save config: %w", err)
+		}
 	}
 
 	fmt.Printf("\nSwitched to %s %s.\n", selectedProvider.Name(), selectedProvider.Version())
 	fmt.Println("Run 'vmterminal run' to start the new VM.")
+	if snapshotName != "" {
+		fmt.Printf("Previous disk saved as snapshot '%s'.\n", snapshotName)
+		fmt.Printf("Restore it with: vmterminal snapshot restore %s\n", snapshotName)
+	}
 
 	return nil
 }
@@ -0,0 +1,69 @@
+package cli
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/javanstorm/vmterminal/internal/vm"
+)
+
+// mockSnapshotManager records CreateSnapshot calls.
+type mockSnapshotManager struct {
+	err         error
+	vmName      string
+	name        string
+	description string
+}
+
+func (m *mockSnapshotManager) CreateSnapshot(vmName, snapshotName, description string, opts ...vm.SnapshotOption) error {
+	m.vmName = vmName
+	m.name = snapshotName
+	m.description = description
+	return m.err
+}
+
+func TestPreSwitchSnapshot(t *testing.T) {
+	now := time.Date(2026, 1, 23, 10, 30, 45, 0, time.UTC)
+	mock := &mockSnapshotManager{}
+
+	name, err := preSwitchSnapshot(mock, "default", "alpine", now, false)
+	if err != nil {
+		t.Fatalf("preSwitchSnapshot: %v", err)
+	}
+
+	want := "pre-switch-alpine-20260123-103045"
+	if name != want {
+		t.Errorf("name = %q, want %q", name, want)
+	}
+	if mock.name != want || mock.vmName != "default" {
+		t.Errorf("CreateSnapshot called with (%q, %q)", mock.vmName, mock.name)
+	}
+	if mock.description != "automatic pre-switch backup" {
+		t.Errorf("description = %q", mock.description)
+	}
+}
+
+func TestPreSwitchSnapshotFailure(t *testing.T) {
+	now := time.Now()
+	mock := &mockSnapshotManager{err: errors.New("disk full")}
+
+	// Without --force the switch is aborted
+	_, err := preSwitchSnapshot(mock, "default", "alpine", now, false)
+	if err == nil {
+		t.Fatal("expected error when snapshot fails without --force")
+	}
+	if !strings.Contains(err.Error(), "--force") {
+		t.Errorf("error should mention --force: %v", err)
+	}
+
+	// With --force the switch proceeds without a snapshot
+	name, err := preSwitchSnapshot(mock, "default", "alpine", now, true)
+	if err != nil {
+		t.Fatalf("expected no error with --force, got %v", err)
+	}
+	if name != "" {
+		t.Errorf("name = %q, want empty when snapshot failed", name)
+	}
+}
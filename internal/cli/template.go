@@ -0,0 +1,265 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/javanstorm/vmterminal/internal/config"
+	"github.com/javanstorm/vmterminal/internal/vm"
+	"github.com/spf13/cobra"
+)
+
+var templateCmd = &cobra.Command{
+	Use:   "template",
+	Short: "Manage VM templates",
+	Long: `Define named templates of VM settings and create fresh VMs from them.
+
+A template holds what 'vm create' accepts (distro, CPUs, memory, disk
+size, networking, shared directories, kernel arguments), optionally a
+snapshot to use as the starting disk, and a provisioning script. Templates
+are stored as JSON files in ~/.vmterminal/templates.`,
+}
+
+var templateCreateCmd = &cobra.Command{
+	Use:   "create <name>",
+	Short: "Create a template",
+	Long: `Create a template from the given settings. Settings left out fall back
+to the global config when a VM created from the template runs.
+
+--base-image names a snapshot, as <vm>@<snapshot>, whose disk each new VM
+starts from. --provision-script is a shell script that cloud-init runs once
+on each new VM's first boot; it needs a distro whose image runs cloud-init.
+
+Examples:
+  vmterminal template create web --distro debian --cpus 2 --memory 2048 \
+    --provision-script ./web-setup.sh
+  vmterminal template create golden --base-image dev@clean-install`,
+	Args: cobra.ExactArgs(1),
+	RunE: runTemplateCreate,
+}
+
+var templateListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List templates",
+	Args:  cobra.NoArgs,
+	RunE:  runTemplateList,
+}
+
+var templateDeleteCmd = &cobra.Command{
+	Use:   "delete <name>",
+	Short: "Delete a template",
+	Long:  `Delete a template. VMs created from it are kept.`,
+	Args:  cobra.ExactArgs(1),
+	RunE:  runTemplateDelete,
+}
+
+var templateApplyCmd = &cobra.Command{
+	Use:   "apply <template> <vm-name>",
+	Short: "Create a VM from a template",
+	Long: `Create a new VM with the template's settings. If the template has a
+base image, the snapshot's disk is copied to the new VM. The VM gets its
+own MAC address.
+
+Examples:
+  vmterminal template apply web web1
+  vmterminal template apply web web2 --use`,
+	Args: cobra.ExactArgs(2),
+	RunE: runTemplateApply,
+}
+
+var (
+	templateBaseImage       string
+	templateProvisionScript string
+	templateApplyUse        bool
+)
+
+func init() {
+	// Resource flags are shared with 'vm create'
+	templateCreateCmd.Flags().IntVarP(&vmCreateCPUs, "cpus", "c", 0, "Number of virtual CPUs (default: global config)")
+	templateCreateCmd.Flags().IntVarP(&vmCreateMemoryMB, "memory", "m", 0, "Memory in MB (default: global config)")
+	templateCreateCmd.Flags().IntVarP(&vmCreateDiskMB, "disk-size", "s", 0, "Disk size in MB (default: global config)")
+	templateCreateCmd.Flags().StringVarP(&vmCreateDistro, "distro", "d", "", "Linux distribution (default: global config)")
+	templateCreateCmd.Flags().BoolVar(&vmCreateNetwork, "network", true, "Enable networking for VMs from this template")
+	templateCreateCmd.Flags().IntVar(&vmCreateSSHPort, "ssh-port", 0, "Host port for SSH forwarding (0 = disabled)")
+	templateCreateCmd.Flags().StringArrayVar(&vmCreateShares, "share", nil, "Host directory to share as <path>[:ro] (repeatable)")
+	templateCreateCmd.Flags().StringArrayVar(&vmCreateKernel, "kernel-arg", nil, "Append an argument to the kernel command line on every boot (repeatable)")
+	templateCreateCmd.Flags().StringVar(&templateBaseImage, "base-image", "", "Snapshot to start new VMs from, as <vm>@<snapshot>")
+	templateCreateCmd.Flags().StringVar(&templateProvisionScript, "provision-script", "", "Shell script to run on each new VM's first boot")
+
+	templateApplyCmd.Flags().BoolVar(&templateApplyUse, "use", false, "Make the new VM the active VM")
+
+	templateCmd.AddCommand(templateCreateCmd)
+	templateCmd.AddCommand(templateListCmd)
+	templateCmd.AddCommand(templateDeleteCmd)
+	templateCmd.AddCommand(templateApplyCmd)
+	rootCmd.AddCommand(templateCmd)
+}
+
+func runTemplateCreate(cmd *cobra.Command, args []string) error {
+	name := args[0]
+	if err := vm.ValidateTemplateName(name); err != nil {
+		return err
+	}
+
+	reg, err := getRegistry()
+	if err != nil {
+		return err
+	}
+
+	entry, err := vmEntryFromFlags(cmd, name)
+	if err != nil {
+		return err
+	}
+	tmpl := vm.VMTemplate{VMEntry: entry, BaseImage: templateBaseImage}
+	if templateProvisionScript != "" {
+		script, err := os.ReadFile(expandHome(templateProvisionScript))
+		if err != nil {
+			return fmt.Errorf("read provisioning script: %w", err)
+		}
+		tmpl.ProvisionScript = string(script)
+	}
+
+	if err := reg.CreateTemplate(tmpl); err != nil {
+		return err
+	}
+
+	progressf("Created template '%s'.\n", name)
+	progressf("Run 'vmterminal template apply %s <vm-name>' to create a VM from it.\n", name)
+	return nil
+}
+
+// templateListItem is a single row of template list output.
+type templateListItem struct {
+	Name       string `json:"name"`
+	Distro     string `json:"distro,omitempty"`
+	CPUs       int    `json:"cpus,omitempty"`
+	MemoryMB   int    `json:"memory_mb,omitempty"`
+	DiskSizeMB int    `json:"disk_size_mb,omitempty"`
+	BaseImage  string `json:"base_image,omitempty"`
+	Provision  bool   `json:"provision"`
+}
+
+// templateListResult is the structured output of template list.
+type templateListResult struct {
+	Templates []templateListItem `json:"templates"`
+}
+
+// RenderHuman prints the templates as a table. Settings the template
+// leaves to the global config are shown as "-".
+func (r *templateListResult) RenderHuman(w io.Writer) {
+	if len(r.Templates) == 0 {
+		fmt.Fprintln(w, "No templates. Create one with: vmterminal template create <name>")
+		return
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tDISTRO\tCPUS\tMEMORY\tBASE IMAGE\tPROVISION")
+	for _, t := range r.Templates {
+		cpus, memory := "-", "-"
+		if t.CPUs > 0 {
+			cpus = fmt.Sprint(t.CPUs)
+		}
+		if t.MemoryMB > 0 {
+			memory = fmt.Sprintf("%d MB", t.MemoryMB)
+		}
+		provision := "-"
+		if t.Provision {
+			provision = "yes"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			t.Name, dashIfEmpty(t.Distro), cpus, memory, dashIfEmpty(t.BaseImage), provision)
+	}
+	tw.Flush()
+}
+
+// dashIfEmpty returns s, or "-" if it is empty.
+func dashIfEmpty(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func runTemplateList(cmd *cobra.Command, args []string) error {
+	reg, err := getRegistry()
+	if err != nil {
+		return err
+	}
+
+	templates, err := reg.ListTemplates()
+	if err != nil {
+		return err
+	}
+
+	res := &templateListResult{Templates: []templateListItem{}}
+	for _, t := range templates {
+		res.Templates = append(res.Templates, templateListItem{
+			Name:       t.Name,
+			Distro:     t.Distro,
+			CPUs:       t.CPUs,
+			MemoryMB:   t.MemoryMB,
+			DiskSizeMB: t.DiskSizeMB,
+			BaseImage:  t.BaseImage,
+			Provision:  t.ProvisionScript != "",
+		})
+	}
+	return printResult(res)
+}
+
+func runTemplateDelete(cmd *cobra.Command, args []string) error {
+	reg, err := getRegistry()
+	if err != nil {
+		return err
+	}
+
+	if err := reg.DeleteTemplate(args[0]); err != nil {
+		return err
+	}
+
+	progressf("Deleted template '%s'.\n", args[0])
+	return nil
+}
+
+func runTemplateApply(cmd *cobra.Command, args []string) error {
+	templateName, name := args[0], args[1]
+
+	reg, err := getRegistry()
+	if err != nil {
+		return err
+	}
+
+	tmpl, err := reg.GetTemplate(templateName)
+	if err != nil {
+		return err
+	}
+	if tmpl.BaseImage != "" {
+		progressf("Copying base image %s...\n", tmpl.BaseImage)
+	}
+	cfg, err := config.LoadState()
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+	entry, err := reg.CreateFromTemplate(templateName, name, cfg.SSHHostPort)
+	if err != nil {
+		return err
+	}
+
+	if entry.Config != nil && entry.Config.SSHHostPort != nil {
+		progressf("Created VM '%s' from template '%s' (SSH on port %d).\n", name, templateName, *entry.Config.SSHHostPort)
+	} else {
+		progressf("Created VM '%s' from template '%s'.\n", name, templateName)
+	}
+	if tmpl.ProvisionScript != "" {
+		progressf("The provisioning script runs on its first boot.\n")
+	}
+	if templateApplyUse {
+		if err := reg.SetActive(name); err != nil {
+			return err
+		}
+		progressf("Active VM set to '%s'.\n", name)
+	} else {
+		progressf("Run 'vmterminal vm use %s' to make it active.\n", name)
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"github.com/javanstorm/vmterminal/internal/timing"
+	"github.com/spf13/cobra"
+)
+
+var timingCmd = &cobra.Command{
+	Use:   "timing",
+	Short: "Inspect VM boot timings",
+	Long: `Inspect the startup phase timings recorded on every 'vmterminal run'.
+Set VMT_TIMING=1 to also print each boot's timings as it starts.`,
+}
+
+var timingReportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "Show boot timing percentiles",
+	Long: fmt.Sprintf(`Show p50/p95/p99, min and max latencies per startup phase over all
+recorded boots. Phases whose median got at least 20%% slower in the last %d
+boots compared with the %d before them are flagged as regressions.
+
+Examples:
+  vmterminal timing report
+  vmterminal timing report --vm dev --json`, timing.RegressionWindow, timing.RegressionWindow),
+	Args: cobra.NoArgs,
+	RunE: runTimingReport,
+}
+
+var timingVM string
+
+func init() {
+	timingReportCmd.Flags().StringVar(&timingVM, "vm", "", "VM whose boot timings to report (default: active VM)")
+	timingCmd.AddCommand(timingReportCmd)
+	rootCmd.AddCommand(timingCmd)
+}
+
+// timingReportResult is the structured output of timing report.
+type timingReportResult struct {
+	VM          string              `json:"vm"`
+	Boots       int                 `json:"boots"`
+	Phases      []timing.PhaseStats `json:"phases"`
+	Regressions []timing.Regression `json:"regressions"`
+}
+
+// RenderHuman prints the percentile table and any regressions.
+func (r *timingReportResult) RenderHuman(w io.Writer) {
+	if r.Boots == 0 {
+		fmt.Fprintf(w, "No boot timings recorded for VM '%s'.\n", r.VM)
+		return
+	}
+
+	regressed := make(map[string]bool)
+	for _, reg := range r.Regressions {
+		regressed[reg.Name] = true
+	}
+
+	fmt.Fprintf(w, "Boot timings for VM '%s' (%d boots):\n", r.VM, r.Boots)
+	fmt.Fprintf(w, "  %-16s %7s %8s %8s %8s %8s %8s\n", "PHASE", "SAMPLES", "MIN", "P50", "P95", "P99", "MAX")
+	for _, p := range r.Phases {
+		mark := ""
+		if regressed[p.Name] {
+			mark = "  (regressed)"
+		}
+		fmt.Fprintf(w, "  %-16s %7d %8s %8s %8s %8s %8s%s\n", p.Name, p.Count,
+			timing.FormatDuration(p.Min), timing.FormatDuration(p.P50), timing.FormatDuration(p.P95),
+			timing.FormatDuration(p.P99), timing.FormatDuration(p.Max), mark)
+	}
+
+	if len(r.Regressions) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\nRegressions (median, previous %d boots -> last %d):\n", timing.RegressionWindow, timing.RegressionWindow)
+	for _, reg := range r.Regressions {
+		fmt.Fprintf(w, "  %-16s %s -> %s (+%.0f%%)\n", reg.Name,
+			timing.FormatDuration(reg.Previous), timing.FormatDuration(reg.Recent), reg.Change*100)
+	}
+}
+
+func runTimingReport(cmd *cobra.Command, args []string) error {
+	baseDir, err := baseDirectory()
+	if err != nil {
+		return err
+	}
+	vmName, _, err := resolveVM(baseDir, timingVM)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(baseDir, "data", vmName, timing.HistoryFile)
+
+	snapshots, err := timing.Load(path)
+	if err != nil {
+		return err
+	}
+
+	history := &timing.TimingHistory{Snapshots: snapshots}
+	return printResult(&timingReportResult{
+		VM:          vmName,
+		Boots:       len(snapshots),
+		Phases:      history.Stats(),
+		Regressions: history.Regressions(),
+	})
+}
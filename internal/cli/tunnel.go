@@ -0,0 +1,85 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+
+	"github.com/javanstorm/vmterminal/internal/vm"
+	"github.com/spf13/cobra"
+)
+
+var tunnelVM string
+
+var tunnelCmd = &cobra.Command{
+	Use:   "tunnel <guest-port>:<host-port>[,...]",
+	Short: "Forward host ports to the VM over SSH until interrupted",
+	Long: `Forward host ports to ports in the VM through an SSH tunnel, without
+adding permanent port forward rules. The tunnels stay open until Ctrl+C.
+
+Each tunnel is <guest-port>:<host-port>; separate several with commas. A
+bare port forwards the same port on both sides. Host ports are bound to
+localhost only. The SSH port and key are taken from the configuration.
+
+Examples:
+  vmterminal tunnel 8080:8080
+  vmterminal tunnel 5432:15432,6379:6379
+  vmterminal tunnel 3000 --vm web`,
+	Args: cobra.ExactArgs(1),
+	RunE: runTunnel,
+}
+
+func init() {
+	tunnelCmd.Flags().StringVar(&tunnelVM, "vm", "", "VM to forward to (default: active VM)")
+	noJSON(tunnelCmd)
+}
+
+func runTunnel(cmd *cobra.Command, args []string) error {
+	tunnels, err := vm.ParseTunnels(args[0])
+	if err != nil {
+		return err
+	}
+	target, err := resolveSSHTarget(tunnelVM)
+	if err != nil {
+		return err
+	}
+
+	sshArgs := vm.BuildSSHTunnelArgs(tunnels, vm.SSHConfig{
+		Port:    target.State.SSHHostPort,
+		KeyPath: target.KeyPath,
+	})
+	for _, t := range tunnels {
+		fmt.Printf("Forwarding: http://localhost:%d -> VM port %d\n", t.HostPort, t.GuestPort)
+	}
+	printlnIfNotQuiet("Press Ctrl+C to close the tunnels.")
+
+	c := exec.Command("ssh", sshArgs...)
+	c.Stdin = os.Stdin
+	c.Stdout = os.Stdout
+	c.Stderr = os.Stderr
+
+	// Ctrl+C reaches ssh through the terminal; pass on anything else
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigCh)
+
+	if err := c.Start(); err != nil {
+		return fmt.Errorf("ssh: %w", err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- c.Wait() }()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			return fmt.Errorf("ssh: %w", err)
+		}
+		return nil
+	case sig := <-sigCh:
+		c.Process.Signal(sig)
+		<-done
+		return nil
+	}
+}
@@ -0,0 +1,118 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/javanstorm/vmterminal/internal/version"
+	"github.com/spf13/cobra"
+)
+
+// updateLockTimeout bounds how long update waits for another update.
+const updateLockTimeout = 5 * time.Second
+
+var (
+	updateChannel string
+	updateCheck   bool
+)
+
+var updateCmd = &cobra.Command{
+	Use:   "update",
+	Short: "Update vmterminal to the latest release",
+	Long: `Download the latest release from GitHub and replace the running
+vmterminal binary with it.
+
+The archive for this platform (vmterminal_<version>_<os>_<arch>.tar.gz) is
+checked against the release's checksums.txt before the binary inside it
+replaces the current one, and the release notes are shown. --channel
+nightly follows the newest release including prereleases. The executable's
+directory must be writable, so an install in /usr/local/bin needs sudo.
+
+Examples:
+  vmterminal update
+  vmterminal update --check
+  sudo vmterminal update --channel nightly`,
+	Args: cobra.NoArgs,
+	RunE: runUpdate,
+}
+
+func init() {
+	updateCmd.Flags().StringVar(&updateChannel, "channel", version.ChannelStable, "Release channel: stable or nightly")
+	updateCmd.Flags().BoolVar(&updateCheck, "check", false, "Only report whether an update is available")
+	rootCmd.AddCommand(updateCmd)
+}
+
+// updateResult is the structured output of update.
+type updateResult struct {
+	Current   string `json:"current"`
+	Latest    string `json:"latest"`
+	Channel   string `json:"channel"`
+	Available bool   `json:"available"`
+	Updated   bool   `json:"updated"`
+	Notes     string `json:"notes,omitempty"`
+}
+
+// RenderHuman prints the outcome of an update as text.
+func (r *updateResult) RenderHuman(w io.Writer) {
+	switch {
+	case !r.Available:
+		fmt.Fprintf(w, "VMTerminal %s is up to date (latest %s release: %s).\n", r.Current, r.Channel, r.Latest)
+		return
+	case r.Updated:
+		fmt.Fprintf(w, "Updated VMTerminal %s -> %s.\n", r.Current, r.Latest)
+	default:
+		fmt.Fprintf(w, "VMTerminal %s is available (installed: %s). Run 'vmterminal update' to install it.\n", r.Latest, r.Current)
+	}
+	if r.Notes != "" {
+		fmt.Fprintf(w, "\nRelease notes:\n\n%s\n", r.Notes)
+	}
+}
+
+func runUpdate(cmd *cobra.Command, args []string) error {
+	baseDir, err := baseDirectory()
+	if err != nil {
+		return err
+	}
+	lock, err := version.AcquireUpdateLock(baseDir, updateLockTimeout)
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+
+	ctx := context.Background()
+	release, err := version.FetchRelease(ctx, updateChannel)
+	if err != nil {
+		return err
+	}
+
+	res := &updateResult{
+		Current:   version.Version,
+		Latest:    release.TagName,
+		Channel:   updateChannel,
+		Available: version.IsNewer(version.Version, release, updateChannel),
+		Notes:     strings.TrimSpace(release.Body),
+	}
+	if !res.Available || updateCheck {
+		return printResult(res)
+	}
+
+	exePath, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("find executable: %w", err)
+	}
+	if exePath, err = filepath.EvalSymlinks(exePath); err != nil {
+		return fmt.Errorf("find executable: %w", err)
+	}
+
+	progressf("Downloading VMTerminal %s for %s...\n", release.TagName, version.Platform())
+	if err := version.Install(ctx, release, exePath); err != nil {
+		return fmt.Errorf("update: %w", err)
+	}
+	res.Updated = true
+	return printResult(res)
+}
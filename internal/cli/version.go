@@ -2,18 +2,67 @@ package cli
 
 import (
 	"fmt"
+	"io"
 
 	"github.com/javanstorm/vmterminal/internal/version"
 	"github.com/spf13/cobra"
 )
 
+var versionCheck bool
+
 var versionCmd = &cobra.Command{
 	Use:   "version",
 	Short: "Print version information",
-	Long:  "Print the version, commit hash, and build date of VMTerminal.",
-	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Printf("VMTerminal %s\n", version.Version)
-		fmt.Printf("  Commit:     %s\n", version.Commit)
-		fmt.Printf("  Build Date: %s\n", version.BuildDate)
+	Long: `Print the version, commit hash, build date, Go version and target
+platform of VMTerminal.
+
+With --check, also look up the latest release on GitHub and report
+whether a newer version is available.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		res := &versionResult{
+			Version:   version.Version,
+			Commit:    version.Commit,
+			BuildDate: version.BuildDate,
+			GoVersion: version.GoVersion(),
+			Platform:  version.Platform(),
+		}
+		if versionCheck {
+			latest, err := version.CheckForUpdates()
+			if err != nil {
+				return fmt.Errorf("check for updates: %w", err)
+			}
+			res.Latest = latest
+			res.UpdateAvailable = version.IsOutdated(res.Version, latest)
+		}
+		return printResult(res)
 	},
 }
+
+func init() {
+	versionCmd.Flags().BoolVar(&versionCheck, "check", false, "Check GitHub for a newer release")
+}
+
+// versionResult is the structured output of the version command.
+type versionResult struct {
+	Version         string `json:"version"`
+	Commit          string `json:"commit"`
+	BuildDate       string `json:"build_date"`
+	GoVersion       string `json:"go_version"`
+	Platform        string `json:"platform"`
+	Latest          string `json:"latest,omitempty"`
+	UpdateAvailable bool   `json:"update_available,omitempty"`
+}
+
+// RenderHuman prints version information as text.
+func (r *versionResult) RenderHuman(w io.Writer) {
+	fmt.Fprintf(w, "VMTerminal %s (commit: %s, built: %s, go: %s, os/arch: %s)\n",
+		r.Version, r.Commit, r.BuildDate, r.GoVersion, r.Platform)
+	if r.Latest == "" {
+		return
+	}
+	fmt.Fprintf(w, "Latest release: %s\n", r.Latest)
+	if r.UpdateAvailable {
+		fmt.Fprintf(w, "A newer version is available; download it from https://github.com/javanstorm/vmterminal/releases/latest\n")
+	}
+}
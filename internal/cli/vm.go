@@ -0,0 +1,798 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/javanstorm/vmterminal/internal/config"
+	"github.com/javanstorm/vmterminal/internal/distro"
+	"github.com/javanstorm/vmterminal/internal/vm"
+	"github.com/spf13/cobra"
+)
+
+var vmCmd = &cobra.Command{
+	Use:   "vm",
+	Short: "Manage multiple VMs",
+	Long: `Create, list, select, inspect, and delete VM instances.
+
+Each VM has its own data directory and may override global settings
+such as networking, SSH port, and shared directories.`,
+}
+
+var vmCreateCmd = &cobra.Command{
+	Use:   "create <name>",
+	Short: "Create a new VM",
+	Long: `Create a new VM instance.
+
+Examples:
+  vmterminal vm create dev --cpus 4 --memory 8192
+  vmterminal vm create web --network=false --ssh-port 2223
+  vmterminal vm create work --share ~/src --share ~/notes:ro
+  vmterminal vm create debug --kernel-arg systemd.log_level=debug
+  vmterminal vm create web2 --hostname web2.lab.internal`,
+	Args: cobra.ExactArgs(1),
+	RunE: runVMCreate,
+}
+
+var vmSetKernelArgCmd = &cobra.Command{
+	Use:   "set-kernel-arg <name> <arg>...",
+	Short: "Add arguments to a VM's kernel command line",
+	Long: `Add arguments to the kernel command line of a VM. They are appended to
+the distro's command line on every boot of that VM, without touching the
+global config. An argument replaces an earlier one with the same key, so
+'systemd.log_level=info' replaces 'systemd.log_level=debug'.
+
+Takes effect on the next boot. UEFI boots ignore these arguments because
+the bootloader on the disk chooses the command line.
+
+Examples:
+  vmterminal vm set-kernel-arg dev debug
+  vmterminal vm set-kernel-arg dev systemd.log_level=debug net.ifnames=0`,
+	Args: cobra.MinimumNArgs(2),
+	RunE: runVMSetKernelArg,
+}
+
+var vmClearKernelArgsCmd = &cobra.Command{
+	Use:   "clear-kernel-args <name>",
+	Short: "Remove all custom kernel arguments from a VM",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runVMClearKernelArgs,
+}
+
+var vmSetHostnameCmd = &cobra.Command{
+	Use:   "set-hostname <name> <hostname>",
+	Short: "Set the hostname a VM boots with",
+	Long: `Set the hostname of a VM. Before each boot it is written to the guest's
+/etc/hostname and mapped to 127.0.1.1 in /etc/hosts. VMs without one are
+named after the VM.
+
+Disks that were set up by extracting a rootfs are given their hostname
+during setup; changing it later, and naming VMs that boot a cloud image,
+needs guestfish (libguestfs-tools). Takes effect on the next boot.
+
+Pass an empty hostname to go back to the VM name.
+
+Examples:
+  vmterminal vm set-hostname dev devbox
+  vmterminal vm set-hostname dev ""`,
+	Args: cobra.ExactArgs(2),
+	RunE: runVMSetHostname,
+}
+
+var vmListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List all VMs",
+	Long:  `List all VMs. The active VM is marked with '*'.`,
+	RunE:  runVMList,
+}
+
+var vmUseCmd = &cobra.Command{
+	Use:   "use <name>",
+	Short: "Set the active VM",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runVMUse,
+}
+
+var vmShowCmd = &cobra.Command{
+	Use:   "show [name]",
+	Short: "Show VM details",
+	Long:  `Show VM details and its effective configuration. Defaults to the active VM.`,
+	Args:  cobra.MaximumNArgs(1),
+	RunE:  runVMShow,
+}
+
+var vmDeleteCmd = &cobra.Command{
+	Use:   "delete <name>",
+	Short: "Delete a VM",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runVMDelete,
+}
+
+var vmCloneCmd = &cobra.Command{
+	Use:   "clone <source> <name>",
+	Short: "Clone a VM",
+	Long: `Clone a stopped VM, including its disk image.
+
+On filesystems with reflink support (btrfs, XFS, APFS) the disk is cloned
+copy-on-write and takes no extra space until either VM writes to it.
+Elsewhere the disk is copied, keeping sparse regions sparse.`,
+	Args: cobra.ExactArgs(2),
+	RunE: runVMClone,
+}
+
+var vmImportCmd = &cobra.Command{
+	Use:   "import --disk <path.qcow2>",
+	Short: "Import a qcow2 disk image as a new VM",
+	Long: `Create a VM from an existing qcow2 disk image, such as one from QEMU or
+libvirt. The image is converted to raw with qemu-img and used as-is: the
+usual download and setup steps are skipped.
+
+The VM name defaults to the image file name without its extension.
+
+Examples:
+  vmterminal vm import --disk ~/images/debian.qcow2
+  vmterminal vm import --disk fedora.qcow2 --name fedora --distro fedora`,
+	Args: cobra.NoArgs,
+	RunE: runVMImport,
+}
+
+var vmImportVagrantCmd = &cobra.Command{
+	Use:   "import-vagrant <box-file>",
+	Short: "Import a Vagrant .box file as a new VM",
+	Long: `Create a VM from a Vagrant box. The box's first .vmdk or qcow2 disk is
+converted to raw with qemu-img and used as-is, like 'vm import'.
+
+The distro is guessed from the base box name in the box's Vagrantfile
+(for example generic/rocky9 becomes rocky), falling back to the box file
+name. CPU and memory settings in the Vagrantfile become the VM's
+defaults; anything not found there uses the global config.
+
+The VM name defaults to the box file name without .box.
+
+Examples:
+  vmterminal vm import-vagrant ~/boxes/rocky9.box
+  vmterminal vm import-vagrant virtualbox.box --name legacy`,
+	Args: cobra.ExactArgs(1),
+	RunE: runVMImportVagrant,
+}
+
+var vmExportCmd = &cobra.Command{
+	Use:   "export [name] --output <path>",
+	Short: "Export a VM's disk image",
+	Long: `Export a stopped VM's disk image to a file outside the VMTerminal data
+directory. Defaults to the active VM, or the default VM when none is
+registered. A disk.raw that is a symlink is exported from its target.
+
+Formats:
+  qcow2   Compressed qcow2 image (needs qemu-img)
+  raw     Sparse copy of disk.raw
+  raw.gz  gzip-compressed disk.raw
+
+If qemu-img is not installed, a qcow2 export falls back to raw.gz.
+
+Examples:
+  vmterminal vm export --output ~/backup/dev.qcow2
+  vmterminal vm export web --format raw.gz --output web.raw.gz`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runVMExport,
+}
+
+var (
+	vmCreateCPUs     int
+	vmCreateMemoryMB int
+	vmCreateDiskMB   int
+	vmCreateDistro   string
+	vmCreateNetwork  bool
+	vmCreateSSHPort  int
+	vmCreateShares   []string
+	vmCreateKernel   []string
+	vmCreateHostname string
+	vmDeleteData     bool
+	vmImportDisk     string
+	vmImportName     string
+	vmImportDistro   string
+	vmVagrantName    string
+	vmExportFormat   string
+	vmExportOutput   string
+)
+
+func init() {
+	vmCreateCmd.Flags().IntVarP(&vmCreateCPUs, "cpus", "c", 0, "Number of virtual CPUs (default: global config)")
+	vmCreateCmd.Flags().IntVarP(&vmCreateMemoryMB, "memory", "m", 0, "Memory in MB (default: global config)")
+	vmCreateCmd.Flags().IntVarP(&vmCreateDiskMB, "disk-size", "s", 0, "Disk size in MB (default: global config)")
+	vmCreateCmd.Flags().StringVarP(&vmCreateDistro, "distro", "d", "", "Linux distribution (default: global config)")
+	vmCreateCmd.Flags().BoolVar(&vmCreateNetwork, "network", true, "Enable networking for this VM")
+	vmCreateCmd.Flags().IntVar(&vmCreateSSHPort, "ssh-port", 0, "Host port for SSH forwarding (0 = disabled)")
+	vmCreateCmd.Flags().StringArrayVar(&vmCreateShares, "share", nil, "Host directory to share as <path>[:ro] (repeatable)")
+	vmCreateCmd.Flags().StringArrayVar(&vmCreateKernel, "kernel-arg", nil, "Append an argument to the kernel command line on every boot (repeatable)")
+	vmCreateCmd.Flags().StringVar(&vmCreateHostname, "hostname", "", "Guest hostname (default: the VM name)")
+
+	vmDeleteCmd.Flags().BoolVar(&vmDeleteData, "data", false, "Also delete VM data (disk, state, snapshots)")
+
+	vmImportCmd.Flags().StringVar(&vmImportDisk, "disk", "", "Path to the qcow2 image to import")
+	vmImportCmd.Flags().StringVar(&vmImportName, "name", "", "Name for the new VM (default: image file name)")
+	vmImportCmd.Flags().StringVarP(&vmImportDistro, "distro", "d", "", "Distribution whose kernel boots the image (default: global config)")
+	vmImportCmd.MarkFlagRequired("disk")
+
+	vmImportVagrantCmd.Flags().StringVar(&vmVagrantName, "name", "", "Name for the new VM (default: box file name)")
+
+	vmExportCmd.Flags().StringVar(&vmExportFormat, "format", vm.ExportQcow2, "Export format: qcow2, raw, or raw.gz")
+	vmExportCmd.Flags().StringVarP(&vmExportOutput, "output", "o", "", "Destination file (required)")
+	vmExportCmd.MarkFlagRequired("output")
+
+	vmCmd.AddCommand(vmCreateCmd)
+	vmCmd.AddCommand(vmListCmd)
+	vmCmd.AddCommand(vmUseCmd)
+	vmCmd.AddCommand(vmShowCmd)
+	vmCmd.AddCommand(vmDeleteCmd)
+	vmCmd.AddCommand(vmCloneCmd)
+	vmCmd.AddCommand(vmImportCmd)
+	vmCmd.AddCommand(vmImportVagrantCmd)
+	vmCmd.AddCommand(vmExportCmd)
+	vmCmd.AddCommand(vmSetKernelArgCmd)
+	vmCmd.AddCommand(vmClearKernelArgsCmd)
+	vmCmd.AddCommand(vmSetHostnameCmd)
+	rootCmd.AddCommand(vmCmd)
+}
+
+// getRegistry returns the VM registry rooted at ~/.vmterminal.
+func getRegistry() (*vm.Registry, error) {
+	baseDir, err := baseDirectory()
+	if err != nil {
+		return nil, err
+	}
+	return vm.NewRegistry(baseDir), nil
+}
+
+// activeVMEntry returns the active registry entry, or nil if no VM is active.
+// Unlike Registry.GetActiveOrDefault it never creates entries.
+func activeVMEntry(baseDir string) *vm.VMEntry {
+	reg := vm.NewRegistry(baseDir)
+	name, err := reg.GetActive()
+	if err != nil || name == "" {
+		return nil
+	}
+	entry, err := reg.GetVM(name)
+	if err != nil {
+		return nil
+	}
+	return entry
+}
+
+func runVMCreate(cmd *cobra.Command, args []string) error {
+	name := args[0]
+
+	reg, err := getRegistry()
+	if err != nil {
+		return err
+	}
+
+	entry, err := vmEntryFromFlags(cmd, name)
+	if err != nil {
+		return err
+	}
+	cfg, err := config.LoadState()
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+	// Each VM gets an SSH port of its own, so VMs can run side by side
+	port, err := reg.CreateVMWithSSHPort(entry, cfg.SSHHostPort)
+	if err != nil {
+		return err
+	}
+
+	if port > 0 {
+		progressf("Created VM '%s' (SSH on port %d).\n", name, port)
+	} else {
+		progressf("Created VM '%s'.\n", name)
+	}
+	progressf("Run 'vmterminal vm use %s' to make it active.\n", name)
+	return nil
+}
+
+// vmEntryFromFlags builds a registry entry named name from the vm create
+// flags, which 'template create' shares. Only overrides the user actually
+// asked for are recorded.
+func vmEntryFromFlags(cmd *cobra.Command, name string) (vm.VMEntry, error) {
+	if vmCreateDistro != "" {
+		if _, err := distro.ParseID(vmCreateDistro); err != nil {
+			return vm.VMEntry{}, err
+		}
+	}
+
+	entry := vm.VMEntry{
+		Name:       name,
+		Distro:     vmCreateDistro,
+		CPUs:       vmCreateCPUs,
+		MemoryMB:   vmCreateMemoryMB,
+		DiskSizeMB: vmCreateDiskMB,
+		Hostname:   vmCreateHostname,
+	}
+	if entry.Hostname != "" {
+		if err := vm.ValidateHostname(entry.Hostname); err != nil {
+			return vm.VMEntry{}, err
+		}
+	}
+	for _, arg := range vmCreateKernel {
+		if err := vm.ValidateKernelArg(arg); err != nil {
+			return vm.VMEntry{}, err
+		}
+		entry.KernelArgs = vm.SetKernelArg(entry.KernelArgs, arg)
+	}
+
+	vmCfg := &vm.VMConfig{}
+	if cmd.Flags().Changed("network") {
+		vmCfg.EnableNetwork = &vmCreateNetwork
+	}
+	if cmd.Flags().Changed("ssh-port") {
+		vmCfg.SSHHostPort = &vmCreateSSHPort
+	}
+	for _, arg := range vmCreateShares {
+		dir, err := config.ParseSharedDir(arg)
+		if err != nil {
+			return vm.VMEntry{}, err
+		}
+		dir.Path = expandHome(dir.Path)
+		vmCfg.SharedDirs = append(vmCfg.SharedDirs, dir)
+	}
+	if vmCfg.EnableNetwork != nil || vmCfg.SSHHostPort != nil || len(vmCfg.SharedDirs) > 0 {
+		entry.Config = vmCfg
+	}
+	return entry, nil
+}
+
+// vmListItem is a single row of vm list output.
+type vmListItem struct {
+	Name     string `json:"name"`
+	Distro   string `json:"distro"`
+	CPUs     int    `json:"cpus"`
+	MemoryMB int    `json:"memory_mb"`
+	Active   bool   `json:"active"`
+}
+
+// vmListResult is the structured output of vm list.
+type vmListResult struct {
+	VMs []vmListItem `json:"vms"`
+}
+
+// RenderHuman prints the VM list as text.
+func (r *vmListResult) RenderHuman(w io.Writer) {
+	if len(r.VMs) == 0 {
+		fmt.Fprintln(w, "No VMs. Create one with: vmterminal vm create <name>")
+		return
+	}
+	fmt.Fprintln(w, "VMs:")
+	for _, v := range r.VMs {
+		marker := " "
+		if v.Active {
+			marker = "*"
+		}
+		fmt.Fprintf(w, "  %s %s (%s, %d CPUs, %d MB)\n", marker, v.Name, v.Distro, v.CPUs, v.MemoryMB)
+	}
+}
+
+func runVMList(cmd *cobra.Command, args []string) error {
+	reg, err := getRegistry()
+	if err != nil {
+		return err
+	}
+
+	vms, err := reg.ListVMs()
+	if err != nil {
+		return err
+	}
+	active, _ := reg.GetActive()
+
+	global := loadGlobalState()
+	res := &vmListResult{VMs: []vmListItem{}}
+	for i := range vms {
+		eff := vms[i].EffectiveState(global)
+		res.VMs = append(res.VMs, vmListItem{
+			Name:     vms[i].Name,
+			Distro:   eff.Distro,
+			CPUs:     eff.CPUs,
+			MemoryMB: eff.MemoryMB,
+			Active:   vms[i].Name == active,
+		})
+	}
+
+	return printResult(res)
+}
+
+func runVMUse(cmd *cobra.Command, args []string) error {
+	reg, err := getRegistry()
+	if err != nil {
+		return err
+	}
+
+	if err := reg.SetActive(args[0]); err != nil {
+		return err
+	}
+
+	progressf("Active VM set to '%s'.\n", args[0])
+	return nil
+}
+
+// vmShowResult is the structured output of vm show.
+type vmShowResult struct {
+	Name       string        `json:"name"`
+	Active     bool          `json:"active"`
+	CreatedAt  time.Time     `json:"created_at"`
+	DataDir    string        `json:"data_dir"`
+	Hostname   string        `json:"hostname"`
+	Template   string        `json:"template,omitempty"`
+	KernelArgs []string      `json:"kernel_args,omitempty"`
+	Effective  *config.State `json:"effective"`
+}
+
+// RenderHuman prints VM details as text.
+func (r *vmShowResult) RenderHuman(w io.Writer) {
+	active := ""
+	if r.Active {
+		active = " (active)"
+	}
+	fmt.Fprintf(w, "VM: %s%s\n", r.Name, active)
+	fmt.Fprintf(w, "  Distro: %s\n", r.Effective.Distro)
+	fmt.Fprintf(w, "  Hostname: %s\n", r.Hostname)
+	if r.Template != "" {
+		fmt.Fprintf(w, "  Template: %s\n", r.Template)
+	}
+	fmt.Fprintf(w, "  CPUs: %d\n", r.Effective.CPUs)
+	fmt.Fprintf(w, "  Memory: %d MB\n", r.Effective.MemoryMB)
+	fmt.Fprintf(w, "  Disk Size: %d MB\n", r.Effective.DiskSizeMB)
+	fmt.Fprintf(w, "  Network: %s\n", formatEnabled(r.Effective.EnableNetwork))
+	fmt.Fprintf(w, "  SSH Port: %d\n", r.Effective.SSHHostPort)
+	fmt.Fprintf(w, "  Shared Dirs: %s\n", formatSharedDirs(r.Effective.SharedDirs))
+	if len(r.KernelArgs) > 0 {
+		fmt.Fprintf(w, "  Kernel Args: %s\n", strings.Join(r.KernelArgs, " "))
+	}
+	fmt.Fprintf(w, "  Created: %s\n", r.CreatedAt.Format("2006-01-02 15:04:05"))
+	fmt.Fprintf(w, "  Data Dir: %s\n", r.DataDir)
+}
+
+func runVMShow(cmd *cobra.Command, args []string) error {
+	reg, err := getRegistry()
+	if err != nil {
+		return err
+	}
+
+	active, _ := reg.GetActive()
+	name := active
+	if len(args) > 0 {
+		name = args[0]
+	}
+	if name == "" {
+		return fmt.Errorf("no active VM; specify a name or run 'vmterminal vm use <name>'")
+	}
+
+	entry, err := reg.GetVM(name)
+	if err != nil {
+		return err
+	}
+
+	return printResult(&vmShowResult{
+		Name:       entry.Name,
+		Active:     entry.Name == active,
+		CreatedAt:  entry.CreatedAt,
+		DataDir:    reg.VMDataDir(entry.Name),
+		Hostname:   entry.EffectiveHostname(),
+		Template:   entry.Template,
+		KernelArgs: entry.KernelArgs,
+		Effective:  entry.EffectiveState(loadGlobalState()),
+	})
+}
+
+func runVMSetKernelArg(cmd *cobra.Command, args []string) error {
+	name, newArgs := args[0], args[1:]
+	for _, arg := range newArgs {
+		if err := vm.ValidateKernelArg(arg); err != nil {
+			return err
+		}
+	}
+
+	reg, err := getRegistry()
+	if err != nil {
+		return err
+	}
+
+	var kernelArgs []string
+	err = reg.UpdateVM(name, func(e *vm.VMEntry) error {
+		for _, arg := range newArgs {
+			e.KernelArgs = vm.SetKernelArg(e.KernelArgs, arg)
+		}
+		kernelArgs = e.KernelArgs
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	progressf("Kernel arguments for '%s': %s\n", name, strings.Join(kernelArgs, " "))
+	progressf("They take effect on the next boot.\n")
+	return nil
+}
+
+func runVMClearKernelArgs(cmd *cobra.Command, args []string) error {
+	reg, err := getRegistry()
+	if err != nil {
+		return err
+	}
+
+	err = reg.UpdateVM(args[0], func(e *vm.VMEntry) error {
+		e.KernelArgs = nil
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	progressf("Cleared kernel arguments for '%s'.\n", args[0])
+	return nil
+}
+
+func runVMSetHostname(cmd *cobra.Command, args []string) error {
+	name, hostname := args[0], args[1]
+	if hostname != "" {
+		if err := vm.ValidateHostname(hostname); err != nil {
+			return err
+		}
+	}
+
+	reg, err := getRegistry()
+	if err != nil {
+		return err
+	}
+
+	var effective string
+	err = reg.UpdateVM(name, func(e *vm.VMEntry) error {
+		e.Hostname = hostname
+		effective = e.EffectiveHostname()
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	progressf("Hostname for '%s': %s\n", name, effective)
+	progressf("It takes effect on the next boot.\n")
+	return nil
+}
+
+func runVMDelete(cmd *cobra.Command, args []string) error {
+	name := args[0]
+
+	reg, err := getRegistry()
+	if err != nil {
+		return err
+	}
+
+	if err := reg.DeleteVM(name); err != nil {
+		return err
+	}
+
+	if vmDeleteData {
+		if err := reg.DeleteVMData(name); err != nil {
+			return err
+		}
+		progressf("Deleted VM '%s' and its data.\n", name)
+	} else {
+		progressf("Deleted VM '%s' (data kept in %s).\n", name, reg.VMDataDir(name))
+	}
+	return nil
+}
+
+func runVMClone(cmd *cobra.Command, args []string) error {
+	src, dst := args[0], args[1]
+
+	baseDir, err := baseDirectory()
+	if err != nil {
+		return err
+	}
+	if isVMRunningCheck(baseDir, src) {
+		return fmt.Errorf("VM '%s' is running; stop it before cloning", src)
+	}
+
+	reg, err := getRegistry()
+	if err != nil {
+		return err
+	}
+
+	cfg, err := config.LoadState()
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+
+	progressf("Cloning VM '%s' to '%s'...\n", src, dst)
+	// The clone runs alongside its source, so it needs its own SSH port
+	reflinked, err := reg.CloneVM(src, dst, cfg.SSHHostPort)
+	if err != nil {
+		return err
+	}
+
+	if reflinked {
+		progressf("Cloned VM '%s' (disk shared copy-on-write).\n", dst)
+	} else {
+		progressf("Cloned VM '%s'.\n", dst)
+	}
+	if clone, err := reg.GetVM(dst); err == nil && clone.Config != nil && clone.Config.SSHHostPort != nil {
+		progressf("SSH on port %d.\n", *clone.Config.SSHHostPort)
+	}
+	return nil
+}
+
+func runVMImport(cmd *cobra.Command, args []string) error {
+	src := expandHome(vmImportDisk)
+	if _, err := os.Stat(src); err != nil {
+		return fmt.Errorf("disk image: %w", err)
+	}
+
+	name := vmImportName
+	if name == "" {
+		name = strings.TrimSuffix(filepath.Base(src), filepath.Ext(src))
+	}
+
+	if vmImportDistro != "" {
+		if _, err := distro.ParseID(vmImportDistro); err != nil {
+			return err
+		}
+	}
+
+	// Validate the image before touching the registry
+	hdr, err := vm.ReadQcow2Header(src)
+	if err != nil {
+		return err
+	}
+
+	reg, err := getRegistry()
+	if err != nil {
+		return err
+	}
+
+	entry := vm.VMEntry{
+		Name:       name,
+		Distro:     vmImportDistro,
+		DiskSizeMB: int(hdr.Size / (1024 * 1024)),
+	}
+	if err := reg.CreateVM(entry); err != nil {
+		return err
+	}
+
+	dataDir := reg.VMDataDir(name)
+	progressf("Converting %s to raw...\n", filepath.Base(src))
+	if err := vm.ConvertQcow2ToRaw(src, filepath.Join(dataDir, "disk.raw")); err != nil {
+		reg.DeleteVM(name)
+		reg.DeleteVMData(name)
+		return err
+	}
+	if err := vm.MarkImported(dataDir); err != nil {
+		return err
+	}
+
+	progressf("Imported VM '%s'.\n", name)
+	progressf("Run 'vmterminal vm use %s' to make it active.\n", name)
+	return nil
+}
+
+func runVMImportVagrant(cmd *cobra.Command, args []string) error {
+	src := expandHome(args[0])
+	if _, err := os.Stat(src); err != nil {
+		return fmt.Errorf("box file: %w", err)
+	}
+
+	name := vmVagrantName
+	if name == "" {
+		name = strings.TrimSuffix(filepath.Base(src), ".box")
+	}
+
+	reg, err := getRegistry()
+	if err != nil {
+		return err
+	}
+	// Check before the slow conversion rather than after it
+	if _, err := reg.GetVM(name); err == nil {
+		return fmt.Errorf("VM '%s' already exists", name)
+	}
+
+	progressf("Unpacking and converting %s...\n", filepath.Base(src))
+	entry, err := vm.NewBoxImporter().Import(src, name, reg.VMDataDir(name))
+	if err != nil {
+		reg.DeleteVMData(name)
+		return err
+	}
+	if err := reg.CreateVM(*entry); err != nil {
+		reg.DeleteVMData(name)
+		return err
+	}
+
+	distroName := entry.Distro
+	if distroName == "" {
+		distroName = "global default"
+	}
+	progressf("Imported VM '%s' (distro: %s).\n", name, distroName)
+	if entry.CPUs > 0 || entry.MemoryMB > 0 {
+		progressf("Vagrantfile settings: %d CPUs, %d MB memory (0 = global default).\n", entry.CPUs, entry.MemoryMB)
+	}
+	progressf("Run 'vmterminal vm use %s' to make it active.\n", name)
+	return nil
+}
+
+func runVMExport(cmd *cobra.Command, args []string) error {
+	baseDir, err := baseDirectory()
+	if err != nil {
+		return err
+	}
+	// The legacy "default" VM has no registry entry but can still be exported
+	var name string
+	if len(args) > 0 {
+		name = args[0]
+	}
+	name, _, err = resolveVM(baseDir, name)
+	if err != nil {
+		return err
+	}
+	if isVMRunningCheck(baseDir, name) {
+		return fmt.Errorf("VM '%s' is running; stop it before exporting", name)
+	}
+
+	dest, err := validateExportPath(expandHome(vmExportOutput), filepath.Join(baseDir, "data"))
+	if err != nil {
+		return err
+	}
+
+	format := vmExportFormat
+	if format == vm.ExportQcow2 {
+		if err := vm.EnsureQemuImg(); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			fmt.Fprintln(os.Stderr, "Warning: falling back to raw.gz; it must be decompressed before use and is not sparse or snapshot-capable like qcow2")
+			format = vm.ExportRawGz
+			dest = strings.TrimSuffix(dest, ".qcow2") + ".raw.gz"
+		}
+	}
+
+	progressf("Exporting VM '%s' to %s (%s)...\n", name, dest, format)
+	if err := vm.NewImageManager(filepath.Join(baseDir, "data", name)).ExportDisk("disk", dest, format); err != nil {
+		return err
+	}
+	progressf("Exported VM '%s' to %s.\n", name, dest)
+	return nil
+}
+
+// validateExportPath makes dest absolute and rejects paths inside dataRoot,
+// where an export would be mistaken for VM data.
+func validateExportPath(dest, dataRoot string) (string, error) {
+	abs, err := filepath.Abs(dest)
+	if err != nil {
+		return "", fmt.Errorf("resolve output path: %w", err)
+	}
+	root, err := filepath.Abs(dataRoot)
+	if err != nil {
+		return "", fmt.Errorf("resolve data dir: %w", err)
+	}
+	if rel, err := filepath.Rel(root, abs); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("output %s is inside the VMTerminal data directory; choose a path outside %s", abs, root)
+	}
+	return abs, nil
+}
+
+// loadGlobalState loads the global config, falling back to defaults.
+func loadGlobalState() *config.State {
+	cfg, err := config.LoadState()
+	if err != nil {
+		return config.DefaultState()
+	}
+	return cfg
+}
+
+// expandHome expands a leading ~ to the user's home directory.
+func expandHome(path string) string {
+	if len(path) > 0 && path[0] == '~' {
+		if home, err := os.UserHomeDir(); err == nil {
+			return home + path[1:]
+		}
+	}
+	return path
+}
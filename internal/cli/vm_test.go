@@ -0,0 +1,34 @@
+package cli
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestValidateExportPath(t *testing.T) {
+	dataRoot := filepath.Join(t.TempDir(), ".vmterminal", "data")
+
+	tests := []struct {
+		name    string
+		dest    string
+		wantErr bool
+	}{
+		{"outside", filepath.Join(t.TempDir(), "vm.qcow2"), false},
+		{"sibling of data", filepath.Join(dataRoot, "..", "vm.qcow2"), false},
+		{"similar prefix", dataRoot + "-exports/vm.qcow2", false},
+		{"inside data", filepath.Join(dataRoot, "default", "vm.qcow2"), true},
+		{"data dir itself", dataRoot, true},
+		{"escapes back in", filepath.Join(dataRoot, "..", "data", "vm.qcow2"), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := validateExportPath(tt.dest, dataRoot)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("validateExportPath(%q) error = %v, wantErr %v", tt.dest, err, tt.wantErr)
+			}
+			if err == nil && !filepath.IsAbs(got) {
+				t.Errorf("validateExportPath(%q) = %q, want absolute path", tt.dest, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/javanstorm/vmterminal/internal/vm"
+)
+
+// loginPromptTimeout is how long the GUI window title says the VM is
+// booting before giving up on seeing a login prompt.
+const loginPromptTimeout = 5 * time.Minute
+
+// windowTitle returns the GUI window title for a VM, e.g.
+// "dev (Alpine Linux 3.21) — VMTerminal". A non-empty status, shown while
+// the VM boots, is appended to it.
+func windowTitle(vmName, distroName, version, status string) string {
+	title := fmt.Sprintf("%s (%s %s) — VMTerminal", vmName, distroName, version)
+	if status != "" {
+		title += " — " + status
+	}
+	return title
+}
+
+// bootTitle keeps a window title in step with the boot: "booting...",
+// then the kernel version once the kernel prints it, then no status once
+// the boot is over.
+type bootTitle struct {
+	mu       sync.Mutex
+	vmName   string
+	distro   string
+	version  string
+	finished bool
+	set      func(string)
+}
+
+// booting shows that the VM is booting kernel, if known.
+func (t *bootTitle) booting(kernel string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.finished {
+		return
+	}
+	status := "booting..."
+	if kernel != "" {
+		status = "booting Linux " + kernel + "..."
+	}
+	t.set(windowTitle(t.vmName, t.distro, t.version, status))
+}
+
+// done switches to the final title.
+func (t *bootTitle) done() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.finished = true
+	t.set(windowTitle(t.vmName, t.distro, t.version, ""))
+}
+
+// watchBootTitle returns a writer to tee console output into. It updates
+// t when the kernel version appears and finishes it at the login prompt,
+// or when no prompt comes. Like watchKernelVersion, writes never fail or
+// block for long.
+func watchBootTitle(ctx context.Context, t *bootTitle) io.Writer {
+	t.booting("")
+
+	kernelR, kernelW := io.Pipe()
+	go func() {
+		defer kernelR.Close()
+		if version, err := vm.WaitForKernelVersion(ctx, kernelR, kernelVersionTimeout); err == nil {
+			t.booting(version)
+		}
+	}()
+
+	loginR, loginW := io.Pipe()
+	go func() {
+		defer loginR.Close()
+		// Without a prompt, stop claiming the VM is still booting anyway
+		_ = vm.WaitForLoginPrompt(ctx, loginR, loginPromptTimeout)
+		if ctx.Err() == nil {
+			t.done()
+		}
+	}()
+	return io.MultiWriter(&consoleTap{w: kernelW}, &consoleTap{w: loginW})
+}
@@ -3,49 +3,254 @@ package config
 
 import (
 	"encoding/json"
+	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
 	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/javanstorm/vmterminal/internal/distro"
+	"gopkg.in/yaml.v3"
 )
 
 // State holds all VMTerminal configuration state.
-// This is stored in an internal JSON file, not user-editable.
-// Configuration is changed via 'vmterminal config' interactive editor.
+// Runtime state is stored in an internal JSON file changed via the
+// 'vmterminal config' interactive editor. Defaults for any field may also
+// be set in the user-editable config.yaml (see yaml.go for precedence).
 type State struct {
 	// Distro is the Linux distribution to use.
-	Distro string `json:"distro"`
+	Distro string `json:"distro" yaml:"distro"`
 
 	// CPUs is the number of virtual CPUs allocated to the VM.
-	CPUs int `json:"cpus"`
+	CPUs int `json:"cpus" yaml:"cpus"`
 
 	// MemoryMB is the amount of RAM in megabytes allocated to the VM.
-	MemoryMB int `json:"memory_mb"`
+	MemoryMB int `json:"memory_mb" yaml:"memory_mb"`
 
 	// DiskSizeMB is the disk image size in megabytes.
-	DiskSizeMB int `json:"disk_size_mb"`
+	DiskSizeMB int `json:"disk_size_mb" yaml:"disk_size_mb"`
 
 	// SharedDirs are host directories mounted inside the VM.
-	SharedDirs []string `json:"shared_dirs"`
+	SharedDirs []SharedDir `json:"shared_dirs" yaml:"shared_dirs"`
 
 	// EnableNetwork enables VM networking (NAT mode on macOS).
-	EnableNetwork bool `json:"enable_network"`
+	EnableNetwork bool `json:"enable_network" yaml:"enable_network"`
 
 	// MACAddress is an optional custom MAC address (empty = auto-generate).
-	MACAddress string `json:"mac_address,omitempty"`
+	MACAddress string `json:"mac_address,omitempty" yaml:"mac_address,omitempty"`
+
+	// Networks lists network interfaces as <mode>[:<iface>][,mac=<addr>],
+	// e.g. "nat" or "bridge:br0". When set, EnableNetwork and MACAddress
+	// are ignored.
+	Networks []string `json:"networks,omitempty" yaml:"networks,omitempty"`
 
 	// SSHHostPort is the host port for SSH port forwarding (0 = disabled).
-	SSHHostPort int `json:"ssh_host_port"`
+	SSHHostPort int `json:"ssh_host_port" yaml:"ssh_host_port"`
 
 	// IsDefaultTerminal indicates if VM is set as default terminal.
-	IsDefaultTerminal bool `json:"is_default_terminal"`
+	IsDefaultTerminal bool `json:"is_default_terminal" yaml:"is_default_terminal"`
+
+	// PortForwards are additional host-to-guest port forwards.
+	PortForwards []PortForwardRule `json:"port_forwards,omitempty" yaml:"port_forwards,omitempty"`
+
+	// MaxSnapshots is the number of snapshots kept per VM (0 = unlimited).
+	MaxSnapshots int `json:"max_snapshots,omitempty" yaml:"max_snapshots,omitempty"`
+
+	// SnapshotMaxAgeDays prunes snapshots older than this many days (0 = never).
+	SnapshotMaxAgeDays int `json:"snapshot_max_age_days,omitempty" yaml:"snapshot_max_age_days,omitempty"`
+
+	// SnapshotSchedule takes snapshots automatically while a VM runs.
+	SnapshotSchedule *SnapshotSchedule `json:"snapshot_schedule,omitempty" yaml:"snapshot_schedule,omitempty"`
+
+	// Mirrors are download mirrors for distro assets, each either <url>
+	// for every distro or <distro>=<url> for one. A distro with mirrors of
+	// its own ignores the ones for every distro.
+	Mirrors []string `json:"mirrors,omitempty" yaml:"mirrors,omitempty"`
+
+	// VersionOverride pins distros to a specific release, e.g. rocky: "9.2",
+	// instead of the version built into vmterminal.
+	VersionOverride map[distro.ID]string `json:"version_override,omitempty" yaml:"version_override,omitempty"`
+
+	// ExtraCmdlineFile is a file of kernel arguments appended to every VM's
+	// kernel command line (empty = ~/.vmterminal/extra_cmdline).
+	ExtraCmdlineFile string `json:"extra_cmdline_file,omitempty" yaml:"extra_cmdline_file,omitempty"`
+
+	// StopTimeoutSeconds is how long the guest gets to shut down before
+	// the VM is killed (0 = 30 seconds).
+	StopTimeoutSeconds int `json:"stop_timeout_seconds,omitempty" yaml:"stop_timeout_seconds,omitempty"`
+
+	// CacheMaxGB limits the asset cache in ~/.vmterminal/cache; the least
+	// recently used distro versions are evicted beyond it (0 = unlimited).
+	CacheMaxGB float64 `json:"cache_max_gb,omitempty" yaml:"cache_max_gb,omitempty"`
+
+	// DataDir moves the data directory, with VM disks, snapshots and the
+	// asset cache, out of ~/.vmterminal (empty = VMT_DATA_DIR, or else
+	// ~/.vmterminal). config.yaml and state.json stay in ~/.vmterminal.
+	DataDir string `json:"data_dir,omitempty" yaml:"data_dir,omitempty"`
+}
+
+// ParseMirror parses a mirror in [<distro>=]<url> notation. The distro is
+// empty for a mirror used by every distro.
+func ParseMirror(s string) (distro.ID, string, error) {
+	var id distro.ID
+	rawURL := s
+	if prefix, rest, ok := strings.Cut(s, "="); ok && !strings.Contains(prefix, "://") {
+		id, rawURL = distro.ID(prefix), rest
+		if !distro.IsRegistered(id) {
+			return "", "", fmt.Errorf("invalid mirror %q: unknown distribution %q", s, prefix)
+		}
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", "", fmt.Errorf("invalid mirror %q: expected [<distro>=]http(s)://<host>[/<path>]", s)
+	}
+	return id, rawURL, nil
+}
+
+// MirrorsFor returns the URLs of the mirrors that apply to distro id: its
+// own if it has any, otherwise those for every distro. Invalid entries
+// are skipped.
+func MirrorsFor(mirrors []string, id distro.ID) []string {
+	var own, all []string
+	for _, m := range mirrors {
+		mid, u, err := ParseMirror(m)
+		switch {
+		case err != nil:
+		case mid == id:
+			own = append(own, u)
+		case mid == "":
+			all = append(all, u)
+		}
+	}
+	if len(own) > 0 {
+		return own
+	}
+	return all
+}
+
+// DefaultSnapshotNameTemplate names scheduled snapshots when the schedule
+// sets no template.
+const DefaultSnapshotNameTemplate = "scheduled-{date}-{time}"
+
+// SnapshotSchedule describes automatic snapshots taken by 'vmterminal run'.
+type SnapshotSchedule struct {
+	Enabled bool `json:"enabled" yaml:"enabled"`
+
+	// Interval is a five-field cron expression, e.g. "0 9 * * *".
+	Interval string `json:"interval" yaml:"interval"`
+
+	// MaxRetain is the number of scheduled snapshots kept (0 = unlimited).
+	// Snapshots created by hand are never pruned by it.
+	MaxRetain int `json:"max_retain,omitempty" yaml:"max_retain,omitempty"`
+
+	// NameTemplate names each snapshot; {vm}, {date} (YYYYMMDD) and
+	// {time} (HHMMSS) are replaced. Empty uses DefaultSnapshotNameTemplate.
+	NameTemplate string `json:"name_template,omitempty" yaml:"name_template,omitempty"`
+}
+
+// SnapshotName returns the name of a snapshot of vmName taken at t.
+func (s *SnapshotSchedule) SnapshotName(vmName string, t time.Time) string {
+	tmpl := s.NameTemplate
+	if tmpl == "" {
+		tmpl = DefaultSnapshotNameTemplate
+	}
+	return strings.NewReplacer(
+		"{vm}", vmName,
+		"{date}", t.Format("20060102"),
+		"{time}", t.Format("150405"),
+	).Replace(tmpl)
+}
+
+// PortForwardRule forwards a host port to a guest port.
+type PortForwardRule struct {
+	Host  int    `json:"host" yaml:"host"`
+	Guest int    `json:"guest" yaml:"guest"`
+	Proto string `json:"proto" yaml:"proto"` // "tcp" or "udp"
+}
+
+// String formats the rule as host:guest/proto.
+func (r PortForwardRule) String() string {
+	return fmt.Sprintf("%d:%d/%s", r.Host, r.Guest, r.Proto)
+}
+
+// SharedDir is a host directory shared with the VM.
+// Older configs store shared directories as plain path strings; those
+// decode to read-write shares with a generated tag.
+type SharedDir struct {
+	Path     string `json:"path" yaml:"path"`
+	ReadOnly bool   `json:"read_only,omitempty" yaml:"read_only,omitempty"`
+	Tag      string `json:"tag,omitempty" yaml:"tag,omitempty"` // Empty = generated
+}
+
+// ParseSharedDir parses <path>[:ro] notation.
+func ParseSharedDir(s string) (SharedDir, error) {
+	dir := SharedDir{Path: s}
+	if path, ok := strings.CutSuffix(s, ":ro"); ok {
+		dir = SharedDir{Path: path, ReadOnly: true}
+	}
+	if strings.TrimSpace(dir.Path) == "" {
+		return SharedDir{}, fmt.Errorf("invalid shared directory %q: expected <path>[:ro]", s)
+	}
+	return dir, nil
+}
+
+// String formats the directory in <path>[:ro] notation.
+func (d SharedDir) String() string {
+	if d.ReadOnly {
+		return d.Path + ":ro"
+	}
+	return d.Path
+}
+
+// UnmarshalJSON accepts either an object or a legacy plain path string.
+func (d *SharedDir) UnmarshalJSON(data []byte) error {
+	var path string
+	if err := json.Unmarshal(data, &path); err == nil {
+		*d = SharedDir{Path: path}
+		return nil
+	}
+	type plain SharedDir
+	var v plain
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*d = SharedDir(v)
+	return nil
+}
+
+// UnmarshalYAML accepts either a mapping or a plain path string.
+func (d *SharedDir) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*d = SharedDir{Path: node.Value}
+		return nil
+	}
+	type plain SharedDir
+	var v plain
+	if err := node.Decode(&v); err != nil {
+		return err
+	}
+	*d = SharedDir(v)
+	return nil
+}
+
+// SharedDirPaths returns the host paths of dirs.
+func SharedDirPaths(dirs []SharedDir) []string {
+	paths := make([]string, len(dirs))
+	for i, d := range dirs {
+		paths[i] = d.Path
+	}
+	return paths
 }
 
 // DefaultState returns a State with sensible defaults.
 func DefaultState() *State {
 	home, _ := os.UserHomeDir()
-	sharedDirs := []string{}
+	sharedDirs := []SharedDir{}
 	if home != "" {
-		sharedDirs = append(sharedDirs, home)
+		sharedDirs = append(sharedDirs, SharedDir{Path: home})
 	}
 
 	return &State{
@@ -67,25 +272,50 @@ func stateFilePath() (string, error) {
 	if err != nil {
 		return "", err
 	}
-	return filepath.Join(paths.DataDir, "state.json"), nil
+	return filepath.Join(paths.StateDir, "state.json"), nil
 }
 
-// LoadState reads the state from the internal state file.
+// LoadState returns the effective state: DefaultState, overlaid with
+// config.yaml (if present), the internal state file, and finally any
+// VMT_* environment variables. An invalid environment variable is an
+// error, as an invalid config.yaml is.
 func LoadState() (*State, error) {
+	state, err := LoadSavedState()
+	if err != nil {
+		return nil, err
+	}
+	if err := ApplyEnvOverrides(state); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// LoadSavedState is like LoadState but ignores environment variables.
+// Use it when the state will be written back with SaveState, so that
+// temporary overrides are not persisted.
+func LoadSavedState() (*State, error) {
+	paths, err := GetPaths()
+	if err != nil {
+		return nil, err
+	}
 	statePath, err := stateFilePath()
 	if err != nil {
 		return nil, err
 	}
 
+	state := DefaultState()
+	if err := loadYAML(paths.ConfigFile, state); err != nil {
+		return nil, err
+	}
+
 	data, err := os.ReadFile(statePath)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return nil, err
+			return state, nil
 		}
 		return nil, err
 	}
 
-	state := &State{}
 	if err := json.Unmarshal(data, state); err != nil {
 		return nil, err
 	}
@@ -93,7 +323,74 @@ func LoadState() (*State, error) {
 	return state, nil
 }
 
+// ApplyEnvOverrides applies VMT_* environment variables over state.
+// Unset variables leave the field unchanged. A value that fails to parse,
+// or that leaves state invalid (see ValidateState), is an error naming the
+// variable.
+//
+//	VMT_CPUS, VMT_MEMORY_MB, VMT_DISK_SIZE_MB, VMT_SSH_PORT  integers
+//	VMT_DISTRO                                               distro ID
+//	VMT_NETWORK                                              boolean (true/false/1/0)
+//	VMT_SHARED_DIRS                                          colon-separated paths
+func ApplyEnvOverrides(s *State) error {
+	var problems []string
+	var applied bool
+	envInt := func(name string, dst *int) {
+		if v, ok := os.LookupEnv(name); ok {
+			n, err := strconv.Atoi(strings.TrimSpace(v))
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("%s: not an integer: %q", name, v))
+				return
+			}
+			*dst = n
+			applied = true
+		}
+	}
+
+	envInt("VMT_CPUS", &s.CPUs)
+	envInt("VMT_MEMORY_MB", &s.MemoryMB)
+	envInt("VMT_DISK_SIZE_MB", &s.DiskSizeMB)
+	envInt("VMT_SSH_PORT", &s.SSHHostPort)
+
+	if v, ok := os.LookupEnv("VMT_DISTRO"); ok && v != "" {
+		s.Distro = v
+		applied = true
+	}
+	if v, ok := os.LookupEnv("VMT_NETWORK"); ok {
+		b, err := strconv.ParseBool(strings.TrimSpace(v))
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("VMT_NETWORK: not a boolean: %q", v))
+		} else {
+			s.EnableNetwork = b
+			applied = true
+		}
+	}
+	if v, ok := os.LookupEnv("VMT_SHARED_DIRS"); ok {
+		// An empty value clears the list
+		dirs := []SharedDir{}
+		for _, dir := range strings.Split(v, ":") {
+			if dir != "" {
+				dirs = append(dirs, SharedDir{Path: dir})
+			}
+		}
+		s.SharedDirs = dirs
+		applied = true
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid environment: %s", strings.Join(problems, "; "))
+	}
+	// Range and distro checks are the same as for config.yaml
+	if applied {
+		if err := ValidateState(s); err != nil {
+			return fmt.Errorf("VMT_* environment variables: %w", err)
+		}
+	}
+	return nil
+}
+
 // SaveState writes the state to the internal state file.
+// The user-editable config.yaml is never modified.
 func SaveState(state *State) error {
 	statePath, err := stateFilePath()
 	if err != nil {
@@ -114,6 +411,91 @@ func SaveState(state *State) error {
 	return os.WriteFile(statePath, data, 0600)
 }
 
+// ResetFiles returns the files that ResetState(hard) deletes and that
+// exist: with hard, the VM registry (vms.json), the active VM marker, and
+// the default VM's persistent state. Disk images and snapshots are kept.
+// The state file itself is replaced rather than deleted, so it is not
+// included.
+func ResetFiles(hard bool) ([]string, error) {
+	if !hard {
+		return nil, nil
+	}
+	paths, err := GetPaths()
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, path := range []string{
+		filepath.Join(paths.DataDir, "vms.json"),
+		filepath.Join(paths.DataDir, "active"),
+		filepath.Join(paths.DataDir, "data", "default", "state.json"),
+	} {
+		if _, err := os.Stat(path); err == nil {
+			files = append(files, path)
+		}
+	}
+	return files, nil
+}
+
+// ResetState replaces the state file with DefaultState, for recovering
+// from a state file that no longer loads. data_dir is kept if it can be
+// read. With hard, it also deletes the
+// files listed by ResetFiles.
+//
+// Either every file is reset or none is: the files are moved aside first
+// and moved back if any step fails, and only removed once the new state
+// file is in place.
+func ResetState(hard bool) error {
+	statePath, err := stateFilePath()
+	if err != nil {
+		return err
+	}
+	remove, err := ResetFiles(hard)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(statePath), 0755); err != nil {
+		return err
+	}
+	// Keep the data directory, so that VMs are not left behind in it
+	state := DefaultState()
+	state.DataDir = stateFileDataDir(statePath)
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmpPath := statePath + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		return fmt.Errorf("write state: %w", err)
+	}
+
+	var moved []string
+	rollback := func() {
+		for _, path := range moved {
+			os.Rename(path+".reset", path)
+		}
+		os.Remove(tmpPath)
+	}
+	for _, path := range remove {
+		if err := os.Rename(path, path+".reset"); err != nil {
+			rollback()
+			return fmt.Errorf("remove %s: %w", path, err)
+		}
+		moved = append(moved, path)
+	}
+	if err := os.Rename(tmpPath, statePath); err != nil {
+		rollback()
+		return fmt.Errorf("replace state: %w", err)
+	}
+
+	for _, path := range moved {
+		os.Remove(path + ".reset")
+	}
+	return nil
+}
+
 // Config holds all VMTerminal configuration (legacy support).
 // Deprecated: Use State instead.
 type Config struct {
@@ -190,7 +572,7 @@ func Load() error {
 		MemoryMB:      state.MemoryMB,
 		DiskSizeMB:    state.DiskSizeMB,
 		DiskPath:      filepath.Join(paths.DataDir, "data", "default", "disk.img"),
-		SharedDirs:    state.SharedDirs,
+		SharedDirs:    SharedDirPaths(state.SharedDirs),
 		EnableNetwork: state.EnableNetwork,
 		MACAddress:    state.MACAddress,
 		SSHUser:       "root",
@@ -1,11 +1,18 @@
 package config
 
 import (
+	"bytes"
 	"encoding/json"
 	"os"
 	"path/filepath"
+	"reflect"
 	"runtime"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/javanstorm/vmterminal/internal/distro"
+	"github.com/javanstorm/vmterminal/pkg/hypervisor"
 )
 
 func TestDefaultState(t *testing.T) {
@@ -74,7 +81,7 @@ func TestStateJSONSerialization(t *testing.T) {
 				CPUs:              4,
 				MemoryMB:          4096,
 				DiskSizeMB:        20480,
-				SharedDirs:        []string{"/home/user", "/tmp"},
+				SharedDirs:        []SharedDir{{Path: "/home/user"}, {Path: "/tmp", ReadOnly: true, Tag: "tmp"}},
 				EnableNetwork:     true,
 				MACAddress:        "00:11:22:33:44:55",
 				SSHHostPort:       2222,
@@ -123,10 +130,72 @@ func TestStateJSONSerialization(t *testing.T) {
 			if loaded.IsDefaultTerminal != tt.state.IsDefaultTerminal {
 				t.Errorf("IsDefaultTerminal mismatch: got %v, want %v", loaded.IsDefaultTerminal, tt.state.IsDefaultTerminal)
 			}
+			if len(loaded.SharedDirs) != len(tt.state.SharedDirs) {
+				t.Fatalf("SharedDirs mismatch: got %v, want %v", loaded.SharedDirs, tt.state.SharedDirs)
+			}
+			for i := range loaded.SharedDirs {
+				if loaded.SharedDirs[i] != tt.state.SharedDirs[i] {
+					t.Errorf("SharedDirs[%d] mismatch: got %+v, want %+v", i, loaded.SharedDirs[i], tt.state.SharedDirs[i])
+				}
+			}
 		})
 	}
 }
 
+func TestSharedDirLegacyStrings(t *testing.T) {
+	want := []SharedDir{{Path: "/home/user"}, {Path: "/srv", ReadOnly: true, Tag: "srv"}}
+
+	var fromJSON State
+	data := `{"shared_dirs": ["/home/user", {"path": "/srv", "read_only": true, "tag": "srv"}]}`
+	if err := json.Unmarshal([]byte(data), &fromJSON); err != nil {
+		t.Fatalf("json.Unmarshal: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	yamlData := "shared_dirs:\n  - /home/user\n  - path: /srv\n    read_only: true\n    tag: srv\n"
+	if err := os.WriteFile(path, []byte(yamlData), 0644); err != nil {
+		t.Fatalf("failed to write config.yaml: %v", err)
+	}
+	fromYAML, err := LoadYAML(path)
+	if err != nil {
+		t.Fatalf("LoadYAML: %v", err)
+	}
+
+	for name, got := range map[string][]SharedDir{"json": fromJSON.SharedDirs, "yaml": fromYAML.SharedDirs} {
+		if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+			t.Errorf("%s: SharedDirs = %+v, want %+v", name, got, want)
+		}
+	}
+}
+
+func TestParseSharedDir(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    SharedDir
+		wantErr bool
+	}{
+		{"/home/user", SharedDir{Path: "/home/user"}, false},
+		{"/data:ro", SharedDir{Path: "/data", ReadOnly: true}, false},
+		{"/odd:name", SharedDir{Path: "/odd:name"}, false},
+		{"", SharedDir{}, true},
+		{":ro", SharedDir{}, true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseSharedDir(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseSharedDir(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseSharedDir(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+		if err == nil && got.String() != tt.in {
+			t.Errorf("String() = %q, want %q", got.String(), tt.in)
+		}
+	}
+}
+
 func TestStateFileRoundTrip(t *testing.T) {
 	// Create a temp directory for testing
 	tmpDir := t.TempDir()
@@ -137,7 +206,7 @@ func TestStateFileRoundTrip(t *testing.T) {
 		CPUs:              8,
 		MemoryMB:          8192,
 		DiskSizeMB:        51200,
-		SharedDirs:        []string{"/home/test"},
+		SharedDirs:        []SharedDir{{Path: "/home/test"}},
 		EnableNetwork:     true,
 		MACAddress:        "aa:bb:cc:dd:ee:ff",
 		SSHHostPort:       2223,
@@ -210,6 +279,146 @@ func TestGetPaths(t *testing.T) {
 	}
 }
 
+func TestGetPathsDataDir(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	stateDir := filepath.Join(home, ".vmterminal")
+	if err := os.MkdirAll(stateDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	dataDir := func() string {
+		t.Helper()
+		paths, err := GetPaths()
+		if err != nil {
+			t.Fatalf("GetPaths: %v", err)
+		}
+		if paths.StateDir != stateDir || paths.ConfigFile != filepath.Join(stateDir, "config.yaml") {
+			t.Errorf("StateDir = %s, ConfigFile = %s; want both in %s", paths.StateDir, paths.ConfigFile, stateDir)
+		}
+		return paths.DataDir
+	}
+
+	t.Setenv(DataDirEnv, "")
+	if got := dataDir(); got != stateDir {
+		t.Errorf("default DataDir = %s, want %s", got, stateDir)
+	}
+
+	t.Setenv(DataDirEnv, "/mnt/big/vmterminal")
+	if got := dataDir(); got != "/mnt/big/vmterminal" {
+		t.Errorf("DataDir with %s = %s, want /mnt/big/vmterminal", DataDirEnv, got)
+	}
+
+	// data_dir is checked before VMT_DATA_DIR, state.json before config.yaml
+	if err := os.WriteFile(filepath.Join(stateDir, "config.yaml"), []byte("data_dir: ~/vms\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := dataDir(), filepath.Join(home, "vms"); got != want {
+		t.Errorf("DataDir with config.yaml = %s, want %s", got, want)
+	}
+	if err := os.WriteFile(filepath.Join(stateDir, "state.json"), []byte(`{"data_dir": "/srv/vms"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := dataDir(); got != "/srv/vms" {
+		t.Errorf("DataDir with state.json = %s, want /srv/vms", got)
+	}
+
+	// A reset keeps the data directory
+	if err := ResetState(false); err != nil {
+		t.Fatalf("ResetState: %v", err)
+	}
+	if got := dataDir(); got != "/srv/vms" {
+		t.Errorf("DataDir after reset = %s, want /srv/vms", got)
+	}
+}
+
+func TestDataDirMigration(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	stateDir := filepath.Join(home, ".vmterminal")
+	newDir := filepath.Join(t.TempDir(), "vmterminal")
+	files := map[string]string{
+		"config.yaml":                    "cpus: 2\n",
+		"state.json":                     `{"data_dir": "` + newDir + `"}`,
+		"distros/custom.yaml":            "id: custom\n",
+		"vms.json":                       "{}",
+		"data/default/disk.raw":          "disk",
+		"cache/alpine/3.21/vmlinuz-virt": "kernel",
+	}
+	for name, data := range files {
+		path := filepath.Join(stateDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	m, err := PlanDataDirMigration()
+	if err != nil {
+		t.Fatalf("PlanDataDirMigration: %v", err)
+	}
+	if m.From != stateDir || m.To != newDir {
+		t.Errorf("migration %s -> %s, want %s -> %s", m.From, m.To, stateDir, newDir)
+	}
+	if want := []string{"cache", "data", "vms.json"}; !reflect.DeepEqual(m.Entries, want) {
+		t.Fatalf("Entries = %v, want %v", m.Entries, want)
+	}
+	if err := m.Run(); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	for name, data := range files {
+		dir := newDir
+		if stateDirFiles[strings.Split(name, "/")[0]] {
+			dir = stateDir
+		}
+		got, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil || string(got) != data {
+			t.Errorf("%s = %q, %v; want %q in %s", name, got, err, data, dir)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(stateDir, "data")); !os.IsNotExist(err) {
+		t.Errorf("data left behind in %s: %v", stateDir, err)
+	}
+
+	// Nothing is left to move
+	if m, err := PlanDataDirMigration(); err != nil || len(m.Entries) != 0 {
+		t.Errorf("second plan = %v, %v; want nothing to move", m.Entries, err)
+	}
+}
+
+func TestCopySparseFile(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "disk.raw")
+	data := make([]byte, 300*1024)
+	copy(data[100*1024:], "boot sector")
+	data[len(data)-1] = 1
+	if err := os.WriteFile(src, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dst := filepath.Join(dir, "copy.raw")
+	if err := copySparseFile(src, dst, info); err != nil {
+		t.Fatalf("copySparseFile: %v", err)
+	}
+	got, err := os.ReadFile(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Error("copy differs from the original")
+	}
+	if info, err := os.Stat(dst); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("copy mode = %v, %v; want 0600", info.Mode(), err)
+	}
+}
+
 func TestDefaultConfig(t *testing.T) {
 	// Test legacy DefaultConfig function
 	cfg := DefaultConfig()
@@ -234,3 +443,509 @@ func TestDefaultConfig(t *testing.T) {
 		t.Errorf("MemoryMB should be 2048, got %d", cfg.MemoryMB)
 	}
 }
+
+func TestLoadYAML(t *testing.T) {
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "config.yaml")
+
+	// Missing file yields defaults
+	state, err := LoadYAML(path)
+	if err != nil {
+		t.Fatalf("LoadYAML on missing file: %v", err)
+	}
+	if state.MemoryMB != DefaultState().MemoryMB {
+		t.Errorf("MemoryMB should default to %d, got %d", DefaultState().MemoryMB, state.MemoryMB)
+	}
+
+	yamlData := "# user config\ndistro: ubuntu\nmemory_mb: 4096\nenable_network: false\n"
+	if err := os.WriteFile(path, []byte(yamlData), 0644); err != nil {
+		t.Fatalf("failed to write config.yaml: %v", err)
+	}
+
+	state, err = LoadYAML(path)
+	if err != nil {
+		t.Fatalf("LoadYAML: %v", err)
+	}
+	if state.Distro != "ubuntu" {
+		t.Errorf("Distro should be 'ubuntu', got %q", state.Distro)
+	}
+	if state.MemoryMB != 4096 {
+		t.Errorf("MemoryMB should be 4096, got %d", state.MemoryMB)
+	}
+	if state.EnableNetwork {
+		t.Error("EnableNetwork should be false")
+	}
+	// Keys absent from the file keep their defaults
+	if state.SSHHostPort != 2222 {
+		t.Errorf("SSHHostPort should keep default 2222, got %d", state.SSHHostPort)
+	}
+
+	if err := os.WriteFile(path, []byte("version_override:\n  rocky: \"9.2\"\n"), 0644); err != nil {
+		t.Fatalf("failed to write config.yaml: %v", err)
+	}
+	state, err = LoadYAML(path)
+	if err != nil {
+		t.Fatalf("LoadYAML with version_override: %v", err)
+	}
+	if state.VersionOverride[distro.Rocky] != "9.2" {
+		t.Errorf("VersionOverride = %v, want rocky: 9.2", state.VersionOverride)
+	}
+}
+
+func TestLoadYAMLInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"bad syntax", "cpus: [\n"},
+		{"zero cpus", "cpus: 0\n"},
+		{"low memory", "memory_mb: 64\n"},
+		{"bad port", "ssh_host_port: 70000\n"},
+		{"bad mac", "mac_address: not-a-mac\n"},
+		{"unknown distro", "distro: plan9\n"},
+		{"negative max snapshots", "max_snapshots: -1\n"},
+		{"negative snapshot age", "snapshot_max_age_days: -3\n"},
+		{"bad network mode", "networks: [vlan]\n"},
+		{"duplicate share tag", "shared_dirs:\n  - {path: /a, tag: x}\n  - {path: /b, tag: x}\n"},
+		{"bad version override", "version_override:\n  rocky: 9.2; rm -rf\n"},
+		{"override unknown distro", "version_override:\n  plan9: \"4\"\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(path, []byte(tt.data), 0644); err != nil {
+				t.Fatalf("failed to write config.yaml: %v", err)
+			}
+			if _, err := LoadYAML(path); err == nil {
+				t.Error("expected error for invalid config.yaml")
+			}
+		})
+	}
+}
+
+func TestLoadStatePrecedence(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	paths, err := GetPaths()
+	if err != nil {
+		t.Fatalf("GetPaths: %v", err)
+	}
+	if err := os.MkdirAll(paths.DataDir, 0755); err != nil {
+		t.Fatalf("failed to create data dir: %v", err)
+	}
+
+	yamlData := "cpus: 3\nmemory_mb: 1024\n"
+	if err := os.WriteFile(paths.ConfigFile, []byte(yamlData), 0644); err != nil {
+		t.Fatalf("failed to write config.yaml: %v", err)
+	}
+
+	// No state.json yet: config.yaml wins over defaults
+	state, err := LoadState()
+	if err != nil {
+		t.Fatalf("LoadState: %v", err)
+	}
+	if state.CPUs != 3 || state.MemoryMB != 1024 {
+		t.Errorf("expected config.yaml values, got cpus=%d memory=%d", state.CPUs, state.MemoryMB)
+	}
+
+	// state.json wins over config.yaml
+	state.MemoryMB = 4096
+	if err := SaveState(state); err != nil {
+		t.Fatalf("SaveState: %v", err)
+	}
+	state, err = LoadState()
+	if err != nil {
+		t.Fatalf("LoadState: %v", err)
+	}
+	if state.MemoryMB != 4096 {
+		t.Errorf("MemoryMB should come from state.json (4096), got %d", state.MemoryMB)
+	}
+
+	// SaveState must not touch config.yaml
+	data, err := os.ReadFile(paths.ConfigFile)
+	if err != nil {
+		t.Fatalf("failed to read config.yaml: %v", err)
+	}
+	if string(data) != yamlData {
+		t.Errorf("config.yaml was modified: %q", data)
+	}
+}
+
+func TestResetState(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	paths, err := GetPaths()
+	if err != nil {
+		t.Fatalf("GetPaths: %v", err)
+	}
+	vmStatePath := filepath.Join(paths.DataDir, "data", "default", "state.json")
+	diskPath := filepath.Join(paths.DataDir, "data", "default", "disk.raw")
+	if err := os.MkdirAll(filepath.Dir(vmStatePath), 0755); err != nil {
+		t.Fatalf("failed to create VM dir: %v", err)
+	}
+	files := map[string]string{
+		filepath.Join(paths.DataDir, "state.json"): `{"cpus": "many"}`,
+		filepath.Join(paths.DataDir, "vms.json"):   `{}`,
+		filepath.Join(paths.DataDir, "active"):     "default",
+		vmStatePath:                                `{}`,
+		diskPath:                                   "disk",
+	}
+	for path, data := range files {
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", path, err)
+		}
+	}
+
+	if _, err := LoadSavedState(); err == nil {
+		t.Fatal("LoadSavedState should fail on the corrupt state file")
+	}
+
+	// A soft reset only replaces the state file
+	if remove, _ := ResetFiles(false); len(remove) != 0 {
+		t.Errorf("ResetFiles(false) = %v, want none", remove)
+	}
+	if err := ResetState(false); err != nil {
+		t.Fatalf("ResetState(false): %v", err)
+	}
+	state, err := LoadSavedState()
+	if err != nil {
+		t.Fatalf("LoadSavedState after reset: %v", err)
+	}
+	if state.CPUs != DefaultState().CPUs {
+		t.Errorf("CPUs = %d, want default %d", state.CPUs, DefaultState().CPUs)
+	}
+	for _, name := range []string{"vms.json", "active"} {
+		if _, err := os.Stat(filepath.Join(paths.DataDir, name)); err != nil {
+			t.Errorf("soft reset removed %s", name)
+		}
+	}
+
+	// A hard reset also removes the registry and VM state, but not disks
+	remove, err := ResetFiles(true)
+	if err != nil {
+		t.Fatalf("ResetFiles(true): %v", err)
+	}
+	if len(remove) != 3 {
+		t.Errorf("ResetFiles(true) = %v, want vms.json, active and the VM state", remove)
+	}
+	if err := ResetState(true); err != nil {
+		t.Fatalf("ResetState(true): %v", err)
+	}
+	for _, path := range remove {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("hard reset kept %s", path)
+		}
+		if _, err := os.Stat(path + ".reset"); !os.IsNotExist(err) {
+			t.Errorf("hard reset left %s.reset behind", path)
+		}
+	}
+	if _, err := os.Stat(diskPath); err != nil {
+		t.Errorf("hard reset removed the disk image: %v", err)
+	}
+	if _, err := LoadSavedState(); err != nil {
+		t.Errorf("LoadSavedState after hard reset: %v", err)
+	}
+}
+
+func TestApplyEnvOverrides(t *testing.T) {
+	tests := []struct {
+		name  string
+		env   string
+		value string
+		check func(s *State) bool
+	}{
+		{"cpus", "VMT_CPUS", "6", func(s *State) bool { return s.CPUs == 6 }},
+		{"memory", "VMT_MEMORY_MB", "3072", func(s *State) bool { return s.MemoryMB == 3072 }},
+		{"disk size", "VMT_DISK_SIZE_MB", "40960", func(s *State) bool { return s.DiskSizeMB == 40960 }},
+		{"distro", "VMT_DISTRO", "debian", func(s *State) bool { return s.Distro == "debian" }},
+		{"network", "VMT_NETWORK", "false", func(s *State) bool { return !s.EnableNetwork }},
+		{"ssh port", "VMT_SSH_PORT", "2200", func(s *State) bool { return s.SSHHostPort == 2200 }},
+		{"shared dirs", "VMT_SHARED_DIRS", "/a:/b/c", func(s *State) bool {
+			return len(s.SharedDirs) == 2 && s.SharedDirs[0].Path == "/a" && s.SharedDirs[1].Path == "/b/c"
+		}},
+		{"empty shared dirs", "VMT_SHARED_DIRS", "", func(s *State) bool { return len(s.SharedDirs) == 0 }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(tt.env, tt.value)
+
+			state := DefaultState()
+			state.SharedDirs = []SharedDir{{Path: "/home/test"}}
+			if err := ApplyEnvOverrides(state); err != nil {
+				t.Fatalf("ApplyEnvOverrides: %v", err)
+			}
+
+			if !tt.check(state) {
+				t.Errorf("%s=%q not applied: %+v", tt.env, tt.value, state)
+			}
+		})
+	}
+}
+
+func TestApplyEnvOverridesUnset(t *testing.T) {
+	for _, name := range []string{
+		"VMT_CPUS", "VMT_MEMORY_MB", "VMT_DISK_SIZE_MB", "VMT_DISTRO",
+		"VMT_NETWORK", "VMT_SSH_PORT", "VMT_SHARED_DIRS",
+	} {
+		t.Setenv(name, "")
+		os.Unsetenv(name)
+	}
+
+	original := &State{
+		Distro:        "arch",
+		CPUs:          8,
+		MemoryMB:      8192,
+		DiskSizeMB:    51200,
+		SharedDirs:    []SharedDir{{Path: "/home/test"}},
+		EnableNetwork: true,
+		SSHHostPort:   2223,
+	}
+	state := *original
+	if err := ApplyEnvOverrides(&state); err != nil {
+		t.Fatalf("ApplyEnvOverrides: %v", err)
+	}
+
+	if state.Distro != original.Distro || state.CPUs != original.CPUs ||
+		state.MemoryMB != original.MemoryMB || state.DiskSizeMB != original.DiskSizeMB ||
+		state.EnableNetwork != original.EnableNetwork || state.SSHHostPort != original.SSHHostPort ||
+		len(state.SharedDirs) != 1 || state.SharedDirs[0].Path != "/home/test" {
+		t.Errorf("state changed without overrides: got %+v, want %+v", state, *original)
+	}
+}
+
+func TestApplyEnvOverridesInvalid(t *testing.T) {
+	tests := []struct {
+		env, value, want string
+	}{
+		{"VMT_CPUS", "many", "VMT_CPUS: not an integer"},
+		{"VMT_NETWORK", "maybe", "VMT_NETWORK: not a boolean"},
+		{"VMT_CPUS", "0", "cpus: must be 1-64"},
+		{"VMT_SSH_PORT", "70000", "ssh_host_port: must be 0-65535"},
+		{"VMT_DISTRO", "plan9", "unknown distribution"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.env+"="+tt.value, func(t *testing.T) {
+			t.Setenv(tt.env, tt.value)
+			err := ApplyEnvOverrides(DefaultState())
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("ApplyEnvOverrides() = %v, want error containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadStateEnvNotPersisted(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("VMT_CPUS", "5")
+
+	state, err := LoadState()
+	if err != nil {
+		t.Fatalf("LoadState: %v", err)
+	}
+	if state.CPUs != 5 {
+		t.Errorf("LoadState should apply VMT_CPUS, got %d", state.CPUs)
+	}
+
+	saved, err := LoadSavedState()
+	if err != nil {
+		t.Fatalf("LoadSavedState: %v", err)
+	}
+	if saved.CPUs != runtime.NumCPU() {
+		t.Errorf("LoadSavedState should ignore VMT_CPUS, got %d", saved.CPUs)
+	}
+}
+
+func TestValidateStatePortForwards(t *testing.T) {
+	state := DefaultState()
+	state.PortForwards = []PortForwardRule{
+		{Host: 8080, Guest: 80, Proto: "tcp"},
+		{Host: 8080, Guest: 80, Proto: "udp"},
+	}
+	if err := ValidateState(state); err != nil {
+		t.Errorf("same port on different protocols should be valid: %v", err)
+	}
+
+	bad := [][]PortForwardRule{
+		{{Host: 8080, Guest: 80, Proto: "tcp"}, {Host: 8080, Guest: 81, Proto: "tcp"}},
+		{{Host: state.SSHHostPort, Guest: 80, Proto: "tcp"}},
+		{{Host: 8080, Guest: 0, Proto: "tcp"}},
+		{{Host: 8080, Guest: 80, Proto: "icmp"}},
+	}
+	for _, rules := range bad {
+		state.PortForwards = rules
+		if err := ValidateState(state); err == nil {
+			t.Errorf("expected error for %v", rules)
+		}
+	}
+}
+
+func TestValidateStateHostOnlyNetwork(t *testing.T) {
+	state := DefaultState()
+	state.Networks = []string{"nat", "host-only"}
+	if err := ValidateState(state); err == nil || !strings.Contains(err.Error(), "host-only") {
+		t.Errorf("ValidateState with host-only network: err = %v", err)
+	}
+}
+
+func TestValidateStateSnapshotSchedule(t *testing.T) {
+	state := DefaultState()
+	state.SnapshotSchedule = &SnapshotSchedule{Enabled: true, Interval: "0 9 * * 1-5", MaxRetain: 7}
+	if err := ValidateState(state); err != nil {
+		t.Errorf("valid schedule rejected: %v", err)
+	}
+
+	bad := []SnapshotSchedule{
+		{Interval: "daily"},
+		{Interval: "0 25 * * *"},
+		{Interval: "0 9 * * *", MaxRetain: -1},
+		{Interval: "0 9 * * *", NameTemplate: "../escape-{date}"},
+	}
+	for _, sched := range bad {
+		state.SnapshotSchedule = &sched
+		if err := ValidateState(state); err == nil {
+			t.Errorf("expected error for %+v", sched)
+		}
+	}
+}
+
+func TestSnapshotScheduleName(t *testing.T) {
+	at := time.Date(2024, 3, 5, 9, 0, 7, 0, time.UTC)
+
+	sched := &SnapshotSchedule{}
+	if got, want := sched.SnapshotName("dev", at), "scheduled-20240305-090007"; got != want {
+		t.Errorf("default name = %q, want %q", got, want)
+	}
+	sched.NameTemplate = "{vm}-daily-{date}"
+	if got, want := sched.SnapshotName("dev", at), "dev-daily-20240305"; got != want {
+		t.Errorf("templated name = %q, want %q", got, want)
+	}
+}
+
+func TestParseMirror(t *testing.T) {
+	tests := []struct {
+		in     string
+		id     distro.ID
+		url    string
+		hasErr bool
+	}{
+		{"https://mirror.example.com", "", "https://mirror.example.com", false},
+		{"alpine=https://mirror.example.com/alpine", distro.Alpine, "https://mirror.example.com/alpine", false},
+		{"https://mirror.example.com/?a=b", "", "https://mirror.example.com/?a=b", false},
+		{"nosuch=https://mirror.example.com", "", "", true},
+		{"ftp://mirror.example.com", "", "", true},
+		{"mirror.example.com", "", "", true},
+	}
+	for _, tt := range tests {
+		id, u, err := ParseMirror(tt.in)
+		if (err != nil) != tt.hasErr {
+			t.Errorf("ParseMirror(%q) error = %v, want error %v", tt.in, err, tt.hasErr)
+			continue
+		}
+		if id != tt.id || u != tt.url {
+			t.Errorf("ParseMirror(%q) = %q, %q, want %q, %q", tt.in, id, u, tt.id, tt.url)
+		}
+	}
+}
+
+func TestMirrorsFor(t *testing.T) {
+	mirrors := []string{
+		"https://all.example.com",
+		"alpine=https://alpine.example.com",
+		"alpine=https://alpine2.example.com",
+		"bogus",
+	}
+	if got := MirrorsFor(mirrors, distro.Alpine); len(got) != 2 || got[0] != "https://alpine.example.com" || got[1] != "https://alpine2.example.com" {
+		t.Errorf("alpine mirrors = %v", got)
+	}
+	if got := MirrorsFor(mirrors, distro.Ubuntu); len(got) != 1 || got[0] != "https://all.example.com" {
+		t.Errorf("ubuntu mirrors = %v", got)
+	}
+	if got := MirrorsFor(nil, distro.Ubuntu); got != nil {
+		t.Errorf("no mirrors = %v", got)
+	}
+
+	state := DefaultState()
+	state.Mirrors = mirrors
+	if err := ValidateState(state); err == nil || !strings.Contains(err.Error(), "bogus") {
+		t.Errorf("ValidateState error = %v, want invalid mirror", err)
+	}
+}
+
+func TestValidateConfigBridgeWithoutTap(t *testing.T) {
+	state := DefaultState()
+	state.Networks = []string{"nat", "bridge:br0"}
+
+	hasBridgeWarning := func(warnings []ValidationIssue) bool {
+		for _, w := range warnings {
+			if w.Field == "Networks" && strings.Contains(w.Message, "br0") {
+				return true
+			}
+		}
+		return false
+	}
+
+	if !hasBridgeWarning(ValidateConfig(state, hypervisor.Capabilities{})) {
+		t.Error("expected a bridge warning without networking support")
+	}
+	if hasBridgeWarning(ValidateConfig(state, hypervisor.Capabilities{Networking: true})) {
+		t.Error("unexpected bridge warning with networking support")
+	}
+}
+
+func TestValidateConfigHostResources(t *testing.T) {
+	origCPUs, origMem := hostCPUs, hostMemoryMB
+	t.Cleanup(func() { hostCPUs, hostMemoryMB = origCPUs, origMem })
+	hostCPUs = func() int { return 4 }
+	hostMemoryMB = func() int { return 8192 }
+
+	levelOf := func(issues []ValidationIssue, field string) (ValidationLevel, bool) {
+		for _, issue := range issues {
+			if issue.Field == field {
+				return issue.Level, true
+			}
+		}
+		return 0, false
+	}
+	caps := hypervisor.Capabilities{SharedDirs: true, Networking: true}
+
+	state := DefaultState()
+	state.CPUs = 4
+	state.MemoryMB = 7000
+	state.SharedDirs = []SharedDir{{Path: t.TempDir()}}
+	if issues := ValidateConfig(state, caps); len(issues) != 0 {
+		t.Fatalf("ValidateConfig = %v, want no issues", issues)
+	}
+
+	state.CPUs = 8
+	state.MemoryMB = 7500
+	state.MACAddress = "not-a-mac"
+	state.SharedDirs = append(state.SharedDirs, SharedDir{Path: filepath.Join(t.TempDir(), "missing")})
+	issues := ValidateConfig(state, caps)
+
+	for _, tt := range []struct {
+		field string
+		level ValidationLevel
+	}{
+		{"CPUs", LevelWarning},
+		{"MemoryMB", LevelWarning},
+		{"MACAddress", LevelError},
+		{"SharedDirs", LevelError},
+	} {
+		if level, ok := levelOf(issues, tt.field); !ok || level != tt.level {
+			t.Errorf("%s issue level = %v (found %v), want %v", tt.field, level, ok, tt.level)
+		}
+	}
+	if !HasValidationErrors(issues) {
+		t.Error("HasValidationErrors = false, want true")
+	}
+
+	// Unknown host memory skips the memory check
+	hostMemoryMB = func() int { return 0 }
+	if _, ok := levelOf(ValidateConfig(state, caps), "MemoryMB"); ok {
+		t.Error("unexpected memory warning when the host memory is unknown")
+	}
+}
@@ -0,0 +1,14 @@
+//go:build darwin
+
+package config
+
+import "golang.org/x/sys/unix"
+
+// totalMemoryMB returns the host's physical memory in MB, or 0 if unknown.
+func totalMemoryMB() int {
+	size, err := unix.SysctlUint64("hw.memsize")
+	if err != nil {
+		return 0
+	}
+	return int(size / (1024 * 1024))
+}
@@ -0,0 +1,14 @@
+//go:build linux
+
+package config
+
+import "golang.org/x/sys/unix"
+
+// totalMemoryMB returns the host's physical memory in MB, or 0 if unknown.
+func totalMemoryMB() int {
+	var info unix.Sysinfo_t
+	if err := unix.Sysinfo(&info); err != nil {
+		return 0
+	}
+	return int(uint64(info.Totalram) * uint64(info.Unit) / (1024 * 1024))
+}
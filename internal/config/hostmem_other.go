@@ -0,0 +1,8 @@
+//go:build !linux && !darwin
+
+package config
+
+// totalMemoryMB returns 0: the host's memory is not known here.
+func totalMemoryMB() int {
+	return 0
+}
@@ -0,0 +1,179 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+)
+
+// stateDirFiles are the entries of ~/.vmterminal that stay there when the
+// data directory moves.
+var stateDirFiles = map[string]bool{
+	"config.yaml":    true,
+	"state.json":     true,
+	"state.json.tmp": true,
+	"distros":        true,
+}
+
+// DataDirMigration moves the data left in ~/.vmterminal into a data
+// directory moved by data_dir or VMT_DATA_DIR.
+type DataDirMigration struct {
+	From string
+	To   string
+
+	// Entries are the names in From to move, sorted.
+	Entries []string
+}
+
+// PlanDataDirMigration returns the migration into the current data
+// directory. Entries is empty if the data directory has not moved or
+// nothing is left to move. config.yaml, state.json and distros stay in
+// ~/.vmterminal.
+func PlanDataDirMigration() (*DataDirMigration, error) {
+	paths, err := GetPaths()
+	if err != nil {
+		return nil, err
+	}
+	m := &DataDirMigration{From: paths.StateDir, To: paths.DataDir}
+	if filepath.Clean(m.From) == filepath.Clean(m.To) {
+		return m, nil
+	}
+
+	entries, err := os.ReadDir(m.From)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return m, nil
+		}
+		return nil, err
+	}
+	// A data directory inside ~/.vmterminal stays where it is
+	inside := ""
+	if rel, err := filepath.Rel(m.From, m.To); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		inside = strings.Split(rel, string(filepath.Separator))[0]
+	}
+	for _, e := range entries {
+		if stateDirFiles[e.Name()] || e.Name() == inside {
+			continue
+		}
+		m.Entries = append(m.Entries, e.Name())
+	}
+	sort.Strings(m.Entries)
+	return m, nil
+}
+
+// Run moves the entries into To. It moves nothing if any of them already
+// exists there. Entries are renamed when From and To share a filesystem
+// and otherwise copied, keeping disk images sparse, then deleted.
+func (m *DataDirMigration) Run() error {
+	for _, name := range m.Entries {
+		if _, err := os.Lstat(filepath.Join(m.To, name)); err == nil {
+			return fmt.Errorf("%s already exists", filepath.Join(m.To, name))
+		}
+	}
+	if err := os.MkdirAll(m.To, 0755); err != nil {
+		return err
+	}
+
+	for _, name := range m.Entries {
+		src, dst := filepath.Join(m.From, name), filepath.Join(m.To, name)
+		err := os.Rename(src, dst)
+		if errors.Is(err, syscall.EXDEV) {
+			if err = copyTree(src, dst); err == nil {
+				err = os.RemoveAll(src)
+			} else {
+				os.RemoveAll(dst)
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("move %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// copyTree copies the file, directory or symlink at src to dst, keeping
+// permissions.
+func copyTree(src, dst string) error {
+	info, err := os.Lstat(src)
+	if err != nil {
+		return err
+	}
+	switch {
+	case info.Mode()&os.ModeSymlink != 0:
+		target, err := os.Readlink(src)
+		if err != nil {
+			return err
+		}
+		return os.Symlink(target, dst)
+	case info.IsDir():
+		if err := os.Mkdir(dst, info.Mode().Perm()); err != nil {
+			return err
+		}
+		entries, err := os.ReadDir(src)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			if err := copyTree(filepath.Join(src, e.Name()), filepath.Join(dst, e.Name())); err != nil {
+				return err
+			}
+		}
+		return nil
+	case info.Mode().IsRegular():
+		return copySparseFile(src, dst, info)
+	default:
+		// Sockets and pipes belong to running VMs and are recreated
+		return nil
+	}
+}
+
+// copySparseFile copies a regular file, seeking over blocks of zeros
+// rather than writing them, so that sparse disk images stay sparse.
+func copySparseFile(src, dst string, info os.FileInfo) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+
+	buf := make([]byte, 64*1024)
+	zero := make([]byte, len(buf))
+	for {
+		n, err := io.ReadFull(in, buf)
+		if n > 0 {
+			if bytes.Equal(buf[:n], zero[:n]) {
+				_, werr := out.Seek(int64(n), io.SeekCurrent)
+				if werr != nil {
+					out.Close()
+					return werr
+				}
+			} else if _, werr := out.Write(buf[:n]); werr != nil {
+				out.Close()
+				return werr
+			}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			out.Close()
+			return err
+		}
+	}
+	// Seeking past the end leaves a trailing hole unallocated
+	if err := out.Truncate(info.Size()); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
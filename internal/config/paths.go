@@ -2,11 +2,19 @@
 package config
 
 import (
+	"encoding/json"
 	"os"
 	"path/filepath"
 	"runtime"
+	"strings"
+
+	"gopkg.in/yaml.v3"
 )
 
+// DataDirEnv is the environment variable that moves the data directory
+// when data_dir is not set.
+const DataDirEnv = "VMT_DATA_DIR"
+
 // Paths holds platform-specific directory paths for VMTerminal.
 type Paths struct {
 	// ConfigDir is the directory for configuration files.
@@ -15,11 +23,19 @@ type Paths struct {
 	ConfigDir string
 
 	// DataDir is the directory for VM disk images and state.
-	// All platforms: ~/.vmterminal
+	// All platforms: ~/.vmterminal, unless moved by data_dir or VMT_DATA_DIR
 	DataDir string
 
+	// StateDir holds config.yaml and state.json. It is always
+	// ~/.vmterminal, so that data_dir can be read before DataDir is known.
+	StateDir string
+
 	// ConfigFile is the path to the main config file.
 	ConfigFile string
+
+	// DistrosDir holds custom distro definitions.
+	// All platforms: ~/.vmterminal/distros
+	DistrosDir string
 }
 
 // GetPaths returns platform-aware paths for VMTerminal.
@@ -31,8 +47,13 @@ func GetPaths() (*Paths, error) {
 
 	p := &Paths{}
 
-	// Data directory is always ~/.vmterminal
-	p.DataDir = filepath.Join(home, ".vmterminal")
+	// Data directory is ~/.vmterminal unless data_dir or VMT_DATA_DIR
+	// moves it
+	p.StateDir = filepath.Join(home, ".vmterminal")
+	p.DataDir = p.StateDir
+	if dir := configuredDataDir(p.StateDir); dir != "" {
+		p.DataDir = ExpandHome(dir, home)
+	}
 
 	// Config directory is platform-specific
 	switch runtime.GOOS {
@@ -47,12 +68,52 @@ func GetPaths() (*Paths, error) {
 		}
 	}
 
-	// Config file lives in data directory for simplicity
-	p.ConfigFile = filepath.Join(p.DataDir, "config.yaml")
+	// Config file lives in ~/.vmterminal for simplicity, even when the
+	// data directory is moved
+	p.ConfigFile = filepath.Join(p.StateDir, "config.yaml")
+	p.DistrosDir = filepath.Join(p.StateDir, "distros")
 
 	return p, nil
 }
 
+// configuredDataDir returns data_dir from state.json or config.yaml in
+// stateDir, else VMT_DATA_DIR. Files that cannot be read are skipped here;
+// LoadState reports them.
+func configuredDataDir(stateDir string) string {
+	if dir := stateFileDataDir(filepath.Join(stateDir, "state.json")); dir != "" {
+		return dir
+	}
+	var saved struct {
+		DataDir string `yaml:"data_dir"`
+	}
+	if data, err := os.ReadFile(filepath.Join(stateDir, "config.yaml")); err == nil {
+		_ = yaml.Unmarshal(data, &saved)
+	}
+	if saved.DataDir != "" {
+		return saved.DataDir
+	}
+	return os.Getenv(DataDirEnv)
+}
+
+// stateFileDataDir returns data_dir from the state file at path, if any.
+func stateFileDataDir(path string) string {
+	var saved struct {
+		DataDir string `json:"data_dir"`
+	}
+	if data, err := os.ReadFile(path); err == nil {
+		_ = json.Unmarshal(data, &saved)
+	}
+	return saved.DataDir
+}
+
+// ExpandHome replaces a leading "~" in path with home and cleans it.
+func ExpandHome(path, home string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		path = filepath.Join(home, path[1:])
+	}
+	return filepath.Clean(path)
+}
+
 // EnsureDirectories creates the config and data directories if they don't exist.
 func (p *Paths) EnsureDirectories() error {
 	if err := os.MkdirAll(p.ConfigDir, 0755); err != nil {
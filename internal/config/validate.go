@@ -2,57 +2,287 @@ package config
 
 import (
 	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
 	"strings"
 
+	"github.com/javanstorm/vmterminal/internal/distro"
+	"github.com/javanstorm/vmterminal/internal/timing"
 	"github.com/javanstorm/vmterminal/pkg/hypervisor"
 )
 
-// ValidationError represents a configuration issue.
-type ValidationError struct {
-	Field   string
-	Message string
-	Fatal   bool // true = can't proceed, false = will be ignored
+// ValidationLevel says how serious a ValidationIssue is.
+type ValidationLevel int
+
+const (
+	// LevelWarning issues let the VM start with degraded functionality.
+	LevelWarning ValidationLevel = iota
+	// LevelError issues prevent the VM from starting.
+	LevelError
+)
+
+// String returns "warning" or "error".
+func (l ValidationLevel) String() string {
+	if l == LevelError {
+		return "error"
+	}
+	return "warning"
 }
 
-// ValidateConfig checks configuration against platform capabilities.
-// Returns a list of validation errors/warnings.
-func ValidateConfig(state *State, caps hypervisor.Capabilities) []ValidationError {
-	var errors []ValidationError
+// MarshalText encodes the level as its name in JSON output.
+func (l ValidationLevel) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+// ValidationIssue represents a configuration issue.
+type ValidationIssue struct {
+	Level   ValidationLevel `json:"level"`
+	Field   string          `json:"field"`
+	Message string          `json:"message"`
+}
+
+// Host resources, replaced in tests. hostMemoryMB returns 0 when the
+// host's memory cannot be determined.
+var (
+	hostCPUs     = runtime.NumCPU
+	hostMemoryMB = totalMemoryMB
+)
+
+// ValidateConfig checks configuration against platform capabilities and
+// host resources.
+func ValidateConfig(state *State, caps hypervisor.Capabilities) []ValidationIssue {
+	var issues []ValidationIssue
+
+	if n := hostCPUs(); state.CPUs > n {
+		issues = append(issues, ValidationIssue{
+			Level:   LevelWarning,
+			Field:   "CPUs",
+			Message: fmt.Sprintf("%d CPUs requested but the host has %d; the VM will be slower than with %d", state.CPUs, n, n),
+		})
+	}
+	if total := hostMemoryMB(); total > 0 && state.MemoryMB > total*9/10 {
+		issues = append(issues, ValidationIssue{
+			Level:   LevelWarning,
+			Field:   "MemoryMB",
+			Message: fmt.Sprintf("%d MB requested of the host's %d MB; the host may start swapping", state.MemoryMB, total),
+		})
+	}
+	if state.MACAddress != "" {
+		if _, err := net.ParseMAC(state.MACAddress); err != nil {
+			issues = append(issues, ValidationIssue{
+				Level:   LevelError,
+				Field:   "MACAddress",
+				Message: fmt.Sprintf("Invalid MAC address %q", state.MACAddress),
+			})
+		}
+	}
 
 	// Check shared directories
-	if len(state.SharedDirs) > 0 && !caps.SharedDirs {
-		errors = append(errors, ValidationError{
+	if len(state.SharedDirs) > 0 && !caps.SharedDirs && !caps.Sharing9P {
+		issues = append(issues, ValidationIssue{
+			Level:   LevelWarning, // Will be ignored, not fatal
 			Field:   "SharedDirs",
-			Message: "Shared directories not supported on this platform (Linux KVM lacks virtio-fs)",
-			Fatal:   false, // Will be ignored, not fatal
+			Message: "Shared directories not supported on this platform (no virtio-fs or 9p)",
 		})
+	} else {
+		for _, dir := range state.SharedDirs {
+			if info, err := os.Stat(dir.Path); err != nil || !info.IsDir() {
+				issues = append(issues, ValidationIssue{
+					Level:   LevelError,
+					Field:   "SharedDirs",
+					Message: fmt.Sprintf("Shared directory %s does not exist", dir.Path),
+				})
+			}
+		}
 	}
 
 	// Check networking
-	if state.EnableNetwork && !caps.Networking {
-		errors = append(errors, ValidationError{
+	if (state.EnableNetwork || len(state.Networks) > 0) && !caps.Networking {
+		issues = append(issues, ValidationIssue{
+			Level:   LevelWarning,
 			Field:   "EnableNetwork",
-			Message: "Networking not supported on this platform (Linux KVM lacks virtio-net)",
-			Fatal:   false,
+			Message: "Networking not available on this platform (Linux KVM needs --tap-device)",
 		})
 	}
+	nics, _ := NetworkInterfaces(state)
+	for _, nic := range nics {
+		if nic.Mode == hypervisor.NetworkBridge && !caps.Networking {
+			issues = append(issues, ValidationIssue{
+				Level:   LevelWarning,
+				Field:   "Networks",
+				Message: fmt.Sprintf("Bridge mode on Linux KVM needs --tap-device with the tap attached to %s", nic.Interface),
+			})
+			break
+		}
+	}
 
-	return errors
+	return issues
 }
 
-// FormatValidationErrors returns human-readable error summary.
-func FormatValidationErrors(errors []ValidationError) string {
-	if len(errors) == 0 {
+// HasValidationErrors reports whether any of issues is at LevelError.
+func HasValidationErrors(issues []ValidationIssue) bool {
+	for _, issue := range issues {
+		if issue.Level == LevelError {
+			return true
+		}
+	}
+	return false
+}
+
+// NetworkInterfaces parses state.Networks.
+func NetworkInterfaces(state *State) ([]hypervisor.NetworkInterface, error) {
+	var nics []hypervisor.NetworkInterface
+	for _, spec := range state.Networks {
+		nic, err := hypervisor.ParseNetworkInterface(spec)
+		if err != nil {
+			return nil, err
+		}
+		nics = append(nics, nic)
+	}
+	return nics, nil
+}
+
+// FormatValidationIssues returns human-readable issue summary.
+func FormatValidationIssues(issues []ValidationIssue) string {
+	if len(issues) == 0 {
 		return ""
 	}
 	var b strings.Builder
-	b.WriteString("Configuration warnings:\n")
-	for _, e := range errors {
+	b.WriteString("Configuration issues:\n")
+	for _, issue := range issues {
 		prefix := "Warning"
-		if e.Fatal {
+		if issue.Level == LevelError {
 			prefix = "Error"
 		}
-		fmt.Fprintf(&b, "  %s [%s]: %s\n", prefix, e.Field, e.Message)
+		fmt.Fprintf(&b, "  %s [%s]: %s\n", prefix, issue.Field, issue.Message)
 	}
 	return b.String()
 }
+
+// Validate checks the schedule's fields. Errors name the offending field.
+func (s *SnapshotSchedule) Validate() error {
+	if _, err := timing.ParseCronSchedule(s.Interval); err != nil {
+		return fmt.Errorf("interval: %w", err)
+	}
+	if s.MaxRetain < 0 {
+		return fmt.Errorf("max_retain: must not be negative, got %d", s.MaxRetain)
+	}
+	if strings.ContainsAny(s.NameTemplate, "/\\") {
+		return fmt.Errorf("name_template: must not contain path separators, got %q", s.NameTemplate)
+	}
+	return nil
+}
+
+// ValidateState checks that every field of state holds a usable value.
+// It is independent of platform capabilities; see ValidateConfig for those.
+func ValidateState(state *State) error {
+	var problems []string
+
+	if state.Distro != "" && !distro.IsRegistered(distro.ID(state.Distro)) {
+		problems = append(problems, fmt.Sprintf("distro: unknown distribution %q", state.Distro))
+	}
+	// Limits match the interactive 'vmterminal config' editor
+	if state.CPUs < 1 || state.CPUs > 64 {
+		problems = append(problems, fmt.Sprintf("cpus: must be 1-64, got %d", state.CPUs))
+	}
+	if state.MemoryMB < 256 || state.MemoryMB > 65536 {
+		problems = append(problems, fmt.Sprintf("memory_mb: must be 256-65536, got %d", state.MemoryMB))
+	}
+	if state.DiskSizeMB < 1024 || state.DiskSizeMB > 1024*1024 {
+		problems = append(problems, fmt.Sprintf("disk_size_mb: must be 1024-1048576, got %d", state.DiskSizeMB))
+	}
+	if state.SSHHostPort < 0 || state.SSHHostPort > 65535 {
+		problems = append(problems, fmt.Sprintf("ssh_host_port: must be 0-65535, got %d", state.SSHHostPort))
+	}
+	if state.MACAddress != "" {
+		if _, err := net.ParseMAC(state.MACAddress); err != nil {
+			problems = append(problems, fmt.Sprintf("mac_address: invalid MAC %q", state.MACAddress))
+		}
+	}
+	if state.DataDir != "" && !filepath.IsAbs(state.DataDir) && state.DataDir != "~" && !strings.HasPrefix(state.DataDir, "~/") {
+		problems = append(problems, fmt.Sprintf("data_dir: must be an absolute path, got %q", state.DataDir))
+	}
+	for _, id := range sortedIDs(state.VersionOverride) {
+		version := state.VersionOverride[id]
+		if !distro.IsRegistered(id) {
+			problems = append(problems, fmt.Sprintf("version_override: unknown distribution %q", id))
+		} else if err := distro.ValidatePinnedVersion(version); err != nil {
+			problems = append(problems, fmt.Sprintf("version_override: %s: %v", id, err))
+		}
+	}
+	seen := make(map[string]bool)
+	for _, r := range state.PortForwards {
+		if r.Host < 1 || r.Host > 65535 || r.Guest < 1 || r.Guest > 65535 {
+			problems = append(problems, fmt.Sprintf("port_forwards: ports must be 1-65535, got %s", r))
+			continue
+		}
+		if r.Proto != "tcp" && r.Proto != "udp" {
+			problems = append(problems, fmt.Sprintf("port_forwards: proto must be tcp or udp, got %q", r.Proto))
+			continue
+		}
+		key := fmt.Sprintf("%d/%s", r.Host, r.Proto)
+		if seen[key] || (r.Proto == "tcp" && r.Host == state.SSHHostPort) {
+			problems = append(problems, fmt.Sprintf("port_forwards: host port %s is already in use", key))
+		}
+		seen[key] = true
+	}
+	if state.MaxSnapshots < 0 {
+		problems = append(problems, fmt.Sprintf("max_snapshots: must not be negative, got %d", state.MaxSnapshots))
+	}
+	if state.SnapshotMaxAgeDays < 0 {
+		problems = append(problems, fmt.Sprintf("snapshot_max_age_days: must not be negative, got %d", state.SnapshotMaxAgeDays))
+	}
+	if state.CacheMaxGB < 0 {
+		problems = append(problems, fmt.Sprintf("cache_max_gb: must not be negative, got %g", state.CacheMaxGB))
+	}
+	if state.StopTimeoutSeconds < 0 {
+		problems = append(problems, fmt.Sprintf("stop_timeout_seconds: must not be negative, got %d", state.StopTimeoutSeconds))
+	}
+	for _, m := range state.Mirrors {
+		if _, _, err := ParseMirror(m); err != nil {
+			problems = append(problems, "mirrors: "+err.Error())
+		}
+	}
+	if sched := state.SnapshotSchedule; sched != nil {
+		if err := sched.Validate(); err != nil {
+			problems = append(problems, "snapshot_schedule."+err.Error())
+		}
+	}
+	for _, spec := range state.Networks {
+		if _, err := hypervisor.ParseNetworkInterface(spec); err != nil {
+			problems = append(problems, fmt.Sprintf("networks: %v", err))
+		}
+	}
+	tags := make(map[string]bool)
+	for _, dir := range state.SharedDirs {
+		if strings.TrimSpace(dir.Path) == "" {
+			problems = append(problems, "shared_dirs: entries must not be empty")
+			break
+		}
+		if dir.Tag == "" {
+			continue
+		}
+		if tags[dir.Tag] {
+			problems = append(problems, fmt.Sprintf("shared_dirs: tag %q is used more than once", dir.Tag))
+		}
+		tags[dir.Tag] = true
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid configuration: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// sortedIDs returns the keys of m in sorted order.
+func sortedIDs(m map[distro.ID]string) []distro.ID {
+	ids := make([]distro.ID, 0, len(m))
+	for id := range m {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
@@ -0,0 +1,56 @@
+package config
+
+import (
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Configuration precedence, highest first:
+//
+//	CLI flags > environment variables > state.json > config.yaml > defaults
+//
+// config.yaml is user-editable and never written by VMTerminal, so comments
+// and formatting survive upgrades. state.json holds runtime state written by
+// SaveState and the interactive 'vmterminal config' editor. Environment
+// variables are applied by ApplyEnvOverrides and never persisted.
+
+// loadYAML merges the user config file at path over state.
+// A missing file is not an error; the state is left untouched.
+func loadYAML(path string, state *State) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("read %s: %w", path, err)
+	}
+
+	// Unmarshal over the existing values so absent keys keep their defaults
+	if err := yaml.Unmarshal(data, state); err != nil {
+		return fmt.Errorf("parse %s: %w", path, err)
+	}
+
+	// Port forwards default to TCP when proto is omitted
+	for i := range state.PortForwards {
+		if state.PortForwards[i].Proto == "" {
+			state.PortForwards[i].Proto = "tcp"
+		}
+	}
+
+	if err := ValidateState(state); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	return nil
+}
+
+// LoadYAML returns DefaultState with the user config file at path merged over it.
+func LoadYAML(path string) (*State, error) {
+	state := DefaultState()
+	if err := loadYAML(path, state); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
@@ -1,12 +1,28 @@
 package distro
 
-import "fmt"
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
 
 const (
 	alpineVersion = "3.21"
-	alpineBaseURL = "https://dl-cdn.alpinelinux.org/alpine/v%s/releases/%s"
+	alpineBaseURL = "https://dl-cdn.alpinelinux.org/alpine/%s/releases/%s"
+
+	// alpineEdgeLatestURL lists the current snapshot of the edge branch.
+	alpineEdgeLatestURL = "https://dl-cdn.alpinelinux.org/alpine/edge/releases/%s/latest-releases.yaml"
 )
 
+// alpineEdgePattern matches a pinned snapshot of the edge branch, named
+// after the date it was built, e.g. "edge-20250108".
+var alpineEdgePattern = regexp.MustCompile(`^edge-([0-9]{8})$`)
+
 // AlpineProvider implements Provider for Alpine Linux.
 type AlpineProvider struct {
 	BaseProvider
@@ -31,16 +47,21 @@ func (p *AlpineProvider) AssetURLs(arch Arch) (*AssetURLs, error) {
 	}
 
 	alpineArch := p.toAlpineArch(arch)
-	baseURL := fmt.Sprintf(alpineBaseURL, p.version, alpineArch)
+	branch, release, netboot := alpineRelease(p.version)
+	baseURL := fmt.Sprintf(alpineBaseURL, branch, alpineArch)
 
 	// Note: For kernel and initramfs, we use netboot directory
 	// For rootfs, we use the minirootfs tarball
-	netbootURL := baseURL + "/netboot"
+	netbootURL := baseURL + "/" + netboot
 
+	// Each release tarball has an armored signature by the release
+	// key next to it; the key itself is installed by the user
+	rootfs := fmt.Sprintf("%s/alpine-minirootfs-%s-%s.tar.gz", baseURL, release, alpineArch)
 	return &AssetURLs{
-		Kernel:  netbootURL + "/vmlinuz-virt",
-		Initrd:  netbootURL + "/initramfs-virt",
-		Rootfs:  fmt.Sprintf("%s/alpine-minirootfs-%s.0-%s.tar.gz", baseURL, p.version, alpineArch),
+		Kernel:       netbootURL + "/vmlinuz-virt",
+		Initrd:       netbootURL + "/initramfs-virt",
+		Rootfs:       rootfs,
+		SignatureURL: rootfs + ".asc",
 	}, nil
 }
 
@@ -64,6 +85,83 @@ func (p *AlpineProvider) SetupRequirements() *SetupRequirements {
 	}
 }
 
+// setVersion accepts stable releases: a branch such as 3.18, which uses
+// its first release, or a point release such as 3.18.4. The edge branch
+// has no numbered releases, so it is pinned to a dated snapshot such as
+// edge-20250108 (see ResolveAlpineEdge).
+func (p *AlpineProvider) setVersion(version string) error {
+	if version == "edge" {
+		return fmt.Errorf("Alpine edge is pinned to a snapshot; run 'vmterminal distro pin alpine edge' to pick the current one")
+	}
+	if !alpineEdgePattern.MatchString(version) &&
+		(strings.Count(version, ".") < 1 || strings.Count(version, ".") > 2) {
+		return fmt.Errorf("Alpine versions are <major>.<minor>[.<patch>], e.g. %s, or edge-<yyyymmdd>", alpineVersion)
+	}
+	p.version = version
+	return nil
+}
+
+// alpineRelease splits a version into the branch directory on the CDN,
+// e.g. "v3.18", the release named in the rootfs tarball, e.g. "3.18.0",
+// and the netboot directory holding the matching kernel. An edge snapshot
+// lives under "edge" and is named after its date.
+func alpineRelease(version string) (branch, release, netboot string) {
+	if m := alpineEdgePattern.FindStringSubmatch(version); m != nil {
+		return "edge", m[1], "netboot-" + m[1]
+	}
+	parts := strings.SplitN(version, ".", 3)
+	if len(parts) < 3 {
+		return "v" + version, version + ".0", "netboot"
+	}
+	return "v" + parts[0] + "." + parts[1], version, "netboot"
+}
+
+// ResolveAlpineEdge returns the version pin, e.g. "edge-20250108", of the
+// current snapshot of Alpine's edge branch for arch.
+func ResolveAlpineEdge(ctx context.Context, arch Arch) (string, error) {
+	url := fmt.Sprintf(alpineEdgeLatestURL, NewAlpineProvider().toAlpineArch(arch))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", fmt.Errorf("edge release request: %w", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("fetch edge releases: %w", &ErrAssetDownloadFailed{URL: url, Err: err})
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("fetch edge releases: %w", &ErrAssetDownloadFailed{URL: url, StatusCode: resp.StatusCode})
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxManifestSize))
+	if err != nil {
+		return "", fmt.Errorf("read edge releases: %w", err)
+	}
+	return parseAlpineEdgeReleases(data)
+}
+
+// parseAlpineEdgeReleases picks the minirootfs snapshot out of an edge
+// latest-releases.yaml.
+func parseAlpineEdgeReleases(data []byte) (string, error) {
+	var releases []struct {
+		Flavor  string `yaml:"flavor"`
+		Version string `yaml:"version"`
+	}
+	if err := yaml.Unmarshal(data, &releases); err != nil {
+		return "", fmt.Errorf("parse edge releases: %w", err)
+	}
+	for _, r := range releases {
+		if r.Flavor != "alpine-minirootfs" {
+			continue
+		}
+		version := "edge-" + r.Version
+		if !alpineEdgePattern.MatchString(version) {
+			return "", fmt.Errorf("unexpected edge snapshot %q", r.Version)
+		}
+		return version, nil
+	}
+	return "", fmt.Errorf("no minirootfs in the edge releases")
+}
+
 // toAlpineArch converts our arch to Alpine's arch naming.
 func (p *AlpineProvider) toAlpineArch(arch Arch) string {
 	switch arch {
@@ -5,6 +5,8 @@ import "fmt"
 const (
 	archVersion = "latest"
 	archISOBase = "https://geo.mirror.pkgbuild.com/iso/latest"
+	// Arch Linux ARM publishes aarch64 separately from Arch Linux proper
+	archARMBase = "https://os.archlinuxarm.org/os"
 )
 
 // ArchProvider implements Provider for Arch Linux.
@@ -19,20 +21,39 @@ func NewArchProvider() *ArchProvider {
 			id:      ArchLinux,
 			name:    "Arch Linux",
 			version: archVersion,
-			// Arch Linux only officially supports x86_64
-			archs: []Arch{ArchAMD64},
+			// arm64 comes from the Arch Linux ARM project
+			archs: []Arch{ArchAMD64, ArchARM64},
 		},
 	}
 }
 
+// setVersion refuses changes: Arch is a rolling release and always uses
+// the latest ISO.
+func (p *ArchProvider) setVersion(version string) error {
+	return fmt.Errorf("rolling release, version is always %q", archVersion)
+}
+
 // AssetURLs returns download URLs for Arch Linux.
-// Kernel and initramfs are extracted from the ISO.
-// Rootfs uses the bootstrap tarball.
+// On amd64 the kernel and initramfs are extracted from the ISO and the
+// rootfs is the bootstrap tarball. On arm64 the Arch Linux ARM tarball is
+// the rootfs and also ships the kernel, which is extracted from it.
 func (p *ArchProvider) AssetURLs(arch Arch) (*AssetURLs, error) {
 	if !p.SupportsArch(arch) {
 		return nil, &ErrUnsupportedArch{Distro: p.id, Arch: arch}
 	}
 
+	if arch == ArchARM64 {
+		// The tarball is signed by the Arch Linux ARM build key, which the
+		// user installs
+		rootfsURL := fmt.Sprintf("%s/ArchLinuxARM-aarch64-latest.tar.gz", archARMBase)
+		return &AssetURLs{
+			Kernel:       fmt.Sprintf("tar:%s#boot/Image", rootfsURL),
+			Initrd:       fmt.Sprintf("tar:%s#boot/initramfs-linux.img", rootfsURL),
+			Rootfs:       rootfsURL,
+			SignatureURL: rootfsURL + ".sig",
+		}, nil
+	}
+
 	// Use ISO for kernel extraction (marked with iso: prefix)
 	// The asset manager will handle downloading the ISO and extracting
 	isoURL := fmt.Sprintf("%s/archlinux-x86_64.iso", archISOBase)
@@ -45,7 +66,19 @@ func (p *ArchProvider) AssetURLs(arch Arch) (*AssetURLs, error) {
 }
 
 // BootConfig returns the kernel boot configuration for Arch.
+// The Arch Linux ARM kernel probes virtio disks asynchronously, so on
+// arm64 the root filesystem is found by the label the disk was formatted
+// with and the kernel waits for it to appear.
 func (p *ArchProvider) BootConfig(arch Arch) *BootConfig {
+	if arch == ArchARM64 {
+		return &BootConfig{
+			Cmdline:       "console=hvc0 root=LABEL=vmterminal rootwait rw init=/usr/lib/systemd/systemd",
+			RootDevice:    "LABEL=vmterminal",
+			RootFSType:    "ext4",
+			ConsoleDevice: "hvc0",
+			ExtraModules:  "",
+		}
+	}
 	return &BootConfig{
 		Cmdline:       "console=hvc0 root=/dev/vda rw init=/usr/lib/systemd/systemd",
 		RootDevice:    "/dev/vda",
@@ -64,8 +97,9 @@ func (p *ArchProvider) SetupRequirements() *SetupRequirements {
 	}
 }
 
-// KernelLocator returns nil because Arch uses the iso: URL scheme for direct extraction.
-// The kernel and initrd paths are specified in AssetURLs using the iso: prefix.
+// KernelLocator returns nil because Arch uses the iso: and tar: URL schemes
+// for direct extraction. The kernel and initrd paths are specified in
+// AssetURLs.
 func (p *ArchProvider) KernelLocator() *KernelLocator {
 	return nil
 }
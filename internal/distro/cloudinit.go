@@ -0,0 +1,61 @@
+package distro
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// CloudInitConfig holds the files of a cloud-init NoCloud datasource.
+// They are served to the guest on a seed ISO labelled "cidata".
+type CloudInitConfig struct {
+	// UserData is the user-data file, usually a #cloud-config document.
+	UserData string
+
+	// MetaData is the meta-data file; it must set instance-id.
+	MetaData string
+
+	// NetworkConfig is the optional network-config file.
+	NetworkConfig string
+}
+
+// LoadCloudInitFile builds a CloudInitConfig whose user-data is read from
+// path. Files without a "#" header are treated as #cloud-config YAML.
+// The meta-data names the instance after hostname so that cloud-init runs
+// its first-boot modules once per VM.
+func LoadCloudInitFile(path, hostname string) (*CloudInitConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read cloud-init file: %w", err)
+	}
+
+	userData := string(data)
+	if !strings.HasPrefix(userData, "#") {
+		userData = "#cloud-config\n" + userData
+	}
+
+	return &CloudInitConfig{
+		UserData: userData,
+		MetaData: fmt.Sprintf("instance-id: %s\nlocal-hostname: %s\n", hostname, hostname),
+	}, nil
+}
+
+// LoadProvisionScript builds a CloudInitConfig whose user-data is the
+// shell script at path, which cloud-init runs once per instance. Scripts
+// without a "#!" line run with /bin/sh.
+func LoadProvisionScript(path, instanceID, hostname string) (*CloudInitConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read provisioning script: %w", err)
+	}
+
+	userData := string(data)
+	if !strings.HasPrefix(userData, "#!") {
+		userData = "#!/bin/sh\n" + userData
+	}
+
+	return &CloudInitConfig{
+		UserData: userData,
+		MetaData: fmt.Sprintf("instance-id: %s\nlocal-hostname: %s\n", instanceID, hostname),
+	}, nil
+}
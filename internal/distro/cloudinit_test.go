@@ -0,0 +1,77 @@
+package distro
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadCloudInitFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"cloud-config", "#cloud-config\npackages: [git]\n", "#cloud-config\npackages: [git]\n"},
+		{"bare yaml", "packages: [git]\n", "#cloud-config\npackages: [git]\n"},
+		{"script", "#!/bin/sh\necho hi\n", "#!/bin/sh\necho hi\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "user-data.yaml")
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			cfg, err := LoadCloudInitFile(path, "dev")
+			if err != nil {
+				t.Fatalf("LoadCloudInitFile failed: %v", err)
+			}
+			if cfg.UserData != tt.want {
+				t.Errorf("UserData = %q, want %q", cfg.UserData, tt.want)
+			}
+			if !strings.Contains(cfg.MetaData, "instance-id: dev") {
+				t.Errorf("MetaData = %q, want instance-id dev", cfg.MetaData)
+			}
+		})
+	}
+}
+
+func TestLoadCloudInitFileMissing(t *testing.T) {
+	if _, err := LoadCloudInitFile(filepath.Join(t.TempDir(), "missing.yaml"), "dev"); err == nil {
+		t.Error("expected error for missing file")
+	}
+}
+
+func TestLoadProvisionScript(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"bare", "apt-get install -y git\n", "#!/bin/sh\napt-get install -y git\n"},
+		{"shebang", "#!/bin/bash\nset -e\n", "#!/bin/bash\nset -e\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "provision.sh")
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			cfg, err := LoadProvisionScript(path, "web1", "web1.lab")
+			if err != nil {
+				t.Fatalf("LoadProvisionScript failed: %v", err)
+			}
+			if cfg.UserData != tt.want {
+				t.Errorf("UserData = %q, want %q", cfg.UserData, tt.want)
+			}
+			if cfg.MetaData != "instance-id: web1\nlocal-hostname: web1.lab\n" {
+				t.Errorf("MetaData = %q", cfg.MetaData)
+			}
+		})
+	}
+}
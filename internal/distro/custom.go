@@ -0,0 +1,330 @@
+package distro
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// CustomDistroSpec describes a distribution defined in a YAML or JSON file
+// rather than compiled in.
+type CustomDistroSpec struct {
+	ID      string `yaml:"id"`
+	Name    string `yaml:"name"`
+	Version string `yaml:"version"`
+	Archs   []Arch `yaml:"archs"`
+
+	// Assets holds the download URLs for each supported architecture.
+	Assets map[Arch]CustomAssetSpec `yaml:"assets"`
+
+	Boot          CustomBootSpec           `yaml:"boot"`
+	Setup         CustomSetupSpec          `yaml:"setup"`
+	KernelLocator *CustomKernelLocatorSpec `yaml:"kernel_locator,omitempty"`
+}
+
+// CustomAssetSpec holds asset URLs for one architecture. Kernel and Initrd
+// may use the iso:<url>#<path> scheme.
+type CustomAssetSpec struct {
+	Kernel       string `yaml:"kernel"`
+	Initrd       string `yaml:"initrd"`
+	Rootfs       string `yaml:"rootfs"`
+	SignatureURL string `yaml:"signature_url"`
+	GPGKey       string `yaml:"gpg_key"`
+}
+
+// CustomBootSpec is the YAML form of BootConfig.
+type CustomBootSpec struct {
+	Cmdline       string `yaml:"cmdline"`
+	RootDevice    string `yaml:"root_device"`
+	RootFSType    string `yaml:"root_fs_type"`
+	ConsoleDevice string `yaml:"console_device"`
+	ExtraModules  string `yaml:"extra_modules"`
+}
+
+// CustomSetupSpec is the YAML form of SetupRequirements.
+type CustomSetupSpec struct {
+	NeedsFormatting bool   `yaml:"needs_formatting"`
+	FSType          string `yaml:"fs_type"`
+	NeedsExtraction bool   `yaml:"needs_extraction"`
+}
+
+// CustomKernelLocatorSpec is the YAML form of KernelLocator.
+type CustomKernelLocatorSpec struct {
+	KernelPatterns []string `yaml:"kernel_patterns"`
+	InitrdPatterns []string `yaml:"initrd_patterns"`
+	ArchiveType    string   `yaml:"archive_type"`
+}
+
+var customIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]*$`)
+
+// ParseCustomDistroSpec decodes a YAML or JSON spec and validates it.
+// Unknown keys are rejected so typos are caught early.
+func ParseCustomDistroSpec(data []byte) (*CustomDistroSpec, error) {
+	var spec CustomDistroSpec
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&spec); err != nil {
+		return nil, fmt.Errorf("parse distro spec: %w", err)
+	}
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+	return &spec, nil
+}
+
+// Validate checks that required fields are present and URLs are well-formed.
+func (s *CustomDistroSpec) Validate() error {
+	if !customIDPattern.MatchString(s.ID) {
+		return fmt.Errorf("id: must be lowercase letters, digits, '.', '_' or '-', got %q", s.ID)
+	}
+	if s.Name == "" {
+		return fmt.Errorf("name: required")
+	}
+	if s.Version == "" {
+		return fmt.Errorf("version: required")
+	}
+	if len(s.Archs) == 0 {
+		return fmt.Errorf("archs: at least one architecture is required")
+	}
+
+	for _, arch := range s.Archs {
+		if arch != ArchAMD64 && arch != ArchARM64 {
+			return fmt.Errorf("archs: unsupported architecture %q (want %s or %s)", arch, ArchAMD64, ArchARM64)
+		}
+		assets, ok := s.Assets[arch]
+		if !ok {
+			return fmt.Errorf("assets.%s: missing", arch)
+		}
+		if assets.Rootfs == "" {
+			return fmt.Errorf("assets.%s.rootfs: required", arch)
+		}
+		if err := validateAssetURL(assets.Rootfs, false); err != nil {
+			return fmt.Errorf("assets.%s.rootfs: %w", arch, err)
+		}
+		if (assets.SignatureURL == "") != (assets.GPGKey == "") {
+			return fmt.Errorf("assets.%s: signature_url and gpg_key must be set together", arch)
+		}
+		if assets.SignatureURL != "" {
+			if err := validateAssetURL(assets.SignatureURL, false); err != nil {
+				return fmt.Errorf("assets.%s.signature_url: %w", arch, err)
+			}
+		}
+		if s.KernelLocator == nil && (assets.Kernel == "" || assets.Initrd == "") {
+			return fmt.Errorf("assets.%s: kernel and initrd are required without a kernel_locator", arch)
+		}
+		for field, u := range map[string]string{"kernel": assets.Kernel, "initrd": assets.Initrd} {
+			if u == "" {
+				continue
+			}
+			if err := validateAssetURL(u, true); err != nil {
+				return fmt.Errorf("assets.%s.%s: %w", arch, field, err)
+			}
+		}
+	}
+
+	if s.Boot.Cmdline == "" {
+		return fmt.Errorf("boot.cmdline: required")
+	}
+	if s.Setup.NeedsFormatting && s.Setup.FSType == "" {
+		return fmt.Errorf("setup.fs_type: required when needs_formatting is set")
+	}
+
+	if loc := s.KernelLocator; loc != nil {
+		if len(loc.KernelPatterns) == 0 || len(loc.InitrdPatterns) == 0 {
+			return fmt.Errorf("kernel_locator: kernel_patterns and initrd_patterns are required")
+		}
+		switch loc.ArchiveType {
+		case "tarball", "qcow2", "iso":
+		default:
+			return fmt.Errorf("kernel_locator.archive_type: must be tarball, qcow2, or iso, got %q", loc.ArchiveType)
+		}
+	}
+
+	return nil
+}
+
+// validateAssetURL checks that raw is an absolute http(s) URL. When allowISO
+// is set, the iso:<url>#<path> scheme is accepted as well.
+func validateAssetURL(raw string, allowISO bool) error {
+	if allowISO && strings.HasPrefix(raw, "iso:") {
+		isoURL, pathInISO, ok := strings.Cut(strings.TrimPrefix(raw, "iso:"), "#")
+		if !ok || pathInISO == "" {
+			return fmt.Errorf("invalid iso URL %q (expected iso:<url>#<path>)", raw)
+		}
+		raw = isoURL
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("invalid URL %q: %w", raw, err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid URL %q: must be an absolute http or https URL", raw)
+	}
+	return nil
+}
+
+// CustomProvider implements Provider from a CustomDistroSpec.
+type CustomProvider struct {
+	BaseProvider
+	spec CustomDistroSpec
+}
+
+// NewCustomProvider creates a provider from a validated spec.
+func NewCustomProvider(spec CustomDistroSpec) Provider {
+	return &CustomProvider{
+		BaseProvider: BaseProvider{
+			id:      ID(spec.ID),
+			name:    spec.Name,
+			version: spec.Version,
+			archs:   append([]Arch(nil), spec.Archs...),
+		},
+		spec: spec,
+	}
+}
+
+// setVersion refuses changes: the spec's asset URLs are fixed.
+func (p *CustomProvider) setVersion(version string) error {
+	return fmt.Errorf("custom distros are versioned by their definition file")
+}
+
+// AssetURLs returns the URLs from the spec for arch.
+func (p *CustomProvider) AssetURLs(arch Arch) (*AssetURLs, error) {
+	if !p.SupportsArch(arch) {
+		return nil, &ErrUnsupportedArch{Distro: p.id, Arch: arch}
+	}
+	a := p.spec.Assets[arch]
+	return &AssetURLs{
+		Kernel:       a.Kernel,
+		Initrd:       a.Initrd,
+		Rootfs:       a.Rootfs,
+		SignatureURL: a.SignatureURL,
+		GPGKey:       a.GPGKey,
+	}, nil
+}
+
+// BootConfig returns the boot configuration from the spec, filling in the
+// /dev/vda root and hvc0 console used by every built-in distro.
+func (p *CustomProvider) BootConfig(arch Arch) *BootConfig {
+	b := p.spec.Boot
+	bc := &BootConfig{
+		Cmdline:       b.Cmdline,
+		RootDevice:    b.RootDevice,
+		RootFSType:    b.RootFSType,
+		ConsoleDevice: b.ConsoleDevice,
+		ExtraModules:  b.ExtraModules,
+	}
+	if bc.RootDevice == "" {
+		bc.RootDevice = "/dev/vda"
+	}
+	if bc.RootFSType == "" {
+		bc.RootFSType = p.spec.Setup.FSType
+	}
+	if bc.ConsoleDevice == "" {
+		bc.ConsoleDevice = "hvc0"
+	}
+	return bc
+}
+
+// SetupRequirements returns the setup requirements from the spec.
+func (p *CustomProvider) SetupRequirements() *SetupRequirements {
+	s := p.spec.Setup
+	return &SetupRequirements{
+		NeedsFormatting: s.NeedsFormatting,
+		FSType:          s.FSType,
+		NeedsExtraction: s.NeedsExtraction,
+	}
+}
+
+// KernelLocator returns the spec's locator, or nil when the kernel is
+// downloaded directly.
+func (p *CustomProvider) KernelLocator() *KernelLocator {
+	loc := p.spec.KernelLocator
+	if loc == nil {
+		return nil
+	}
+	return &KernelLocator{
+		KernelPatterns: loc.KernelPatterns,
+		InitrdPatterns: loc.InitrdPatterns,
+		ArchiveType:    loc.ArchiveType,
+	}
+}
+
+var (
+	customIDs     = make(map[ID]bool)
+	customIDsLock sync.Mutex
+)
+
+// RegisterCustom registers a custom provider. It refuses to replace a
+// built-in distribution but may replace an earlier custom one.
+func RegisterCustom(p Provider) error {
+	load()
+	customIDsLock.Lock()
+	defer customIDsLock.Unlock()
+
+	if IsRegistered(p.ID()) && !customIDs[p.ID()] {
+		return fmt.Errorf("distro %q is built in and cannot be redefined", p.ID())
+	}
+	customIDs[p.ID()] = true
+	Register(p)
+	return nil
+}
+
+// IsCustom reports whether id was registered from a custom spec.
+func IsCustom(id ID) bool {
+	load()
+	customIDsLock.Lock()
+	defer customIDsLock.Unlock()
+	return customIDs[id]
+}
+
+// IsCustomDistroFile reports whether name has a spec file extension.
+func IsCustomDistroFile(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".yaml", ".yml", ".json":
+		return true
+	}
+	return false
+}
+
+// LoadCustomDistros registers every spec file in dir. A missing directory is
+// not an error. Invalid files are skipped and reported together.
+func LoadCustomDistros(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read custom distros: %w", err)
+	}
+
+	var errs []error
+	for _, e := range entries {
+		if e.IsDir() || !IsCustomDistroFile(e.Name()) {
+			continue
+		}
+		path := filepath.Join(dir, e.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		spec, err := ParseCustomDistroSpec(data)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", path, err))
+			continue
+		}
+		if err := RegisterCustom(NewCustomProvider(*spec)); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", path, err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,154 @@
+package distro
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const validCustomSpec = `
+id: testlinux
+name: Test Linux
+version: "1.0"
+archs: [amd64, arm64]
+assets:
+  amd64:
+    kernel: https://example.com/amd64/vmlinuz
+    initrd: iso:https://example.com/amd64/live.iso#/boot/initrd
+    rootfs: https://example.com/amd64/rootfs.tar.gz
+  arm64:
+    kernel: https://example.com/arm64/vmlinuz
+    initrd: https://example.com/arm64/initrd
+    rootfs: https://example.com/arm64/rootfs.tar.gz
+boot:
+  cmdline: console=hvc0 root=/dev/vda rw
+setup:
+  needs_formatting: true
+  fs_type: ext4
+  needs_extraction: true
+`
+
+func TestParseCustomDistroSpec(t *testing.T) {
+	spec, err := ParseCustomDistroSpec([]byte(validCustomSpec))
+	if err != nil {
+		t.Fatalf("ParseCustomDistroSpec: %v", err)
+	}
+
+	p := NewCustomProvider(*spec)
+	if p.ID() != "testlinux" || p.Name() != "Test Linux" || p.Version() != "1.0" {
+		t.Errorf("provider = %s %q %s", p.ID(), p.Name(), p.Version())
+	}
+	if !p.SupportsArch(ArchARM64) {
+		t.Error("arm64 should be supported")
+	}
+
+	urls, err := p.AssetURLs(ArchAMD64)
+	if err != nil {
+		t.Fatalf("AssetURLs: %v", err)
+	}
+	if urls.Rootfs != "https://example.com/amd64/rootfs.tar.gz" {
+		t.Errorf("Rootfs = %q", urls.Rootfs)
+	}
+
+	bc := p.BootConfig(ArchAMD64)
+	if bc.RootDevice != "/dev/vda" || bc.ConsoleDevice != "hvc0" || bc.RootFSType != "ext4" {
+		t.Errorf("BootConfig defaults not applied: %+v", bc)
+	}
+	if p.KernelLocator() != nil {
+		t.Error("KernelLocator should be nil without kernel_locator")
+	}
+	if p.CacheSubdir(ArchAMD64) != "testlinux/1.0/amd64" {
+		t.Errorf("CacheSubdir = %q", p.CacheSubdir(ArchAMD64))
+	}
+}
+
+func TestParseCustomDistroSpecJSON(t *testing.T) {
+	data := `{"id": "jsonlinux", "name": "JSON Linux", "version": "2", "archs": ["amd64"],
+		"assets": {"amd64": {"rootfs": "https://example.com/disk.qcow2"}},
+		"boot": {"cmdline": "console=hvc0 root=/dev/vda1 rw"},
+		"setup": {"needs_formatting": false, "needs_extraction": false},
+		"kernel_locator": {"kernel_patterns": ["boot/vmlinuz-*"], "initrd_patterns": ["boot/initrd*"], "archive_type": "qcow2"}}`
+
+	spec, err := ParseCustomDistroSpec([]byte(data))
+	if err != nil {
+		t.Fatalf("ParseCustomDistroSpec: %v", err)
+	}
+	loc := NewCustomProvider(*spec).KernelLocator()
+	if loc == nil || loc.ArchiveType != "qcow2" {
+		t.Errorf("KernelLocator = %+v", loc)
+	}
+}
+
+func TestCustomDistroSpecValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		replace [2]string
+		wantErr string
+	}{
+		{"bad id", [2]string{"id: testlinux", "id: Test Linux"}, "id:"},
+		{"missing name", [2]string{"name: Test Linux", "name: \"\""}, "name: required"},
+		{"bad arch", [2]string{"archs: [amd64, arm64]", "archs: [riscv64]"}, "unsupported architecture"},
+		{"unknown key", [2]string{"archs: [amd64, arm64]", "archs: [amd64, arm64]\nextra: 1"}, "extra"},
+		{"missing arch assets", [2]string{"  arm64:\n", "  other:\n"}, "assets.arm64: missing"},
+		{"bad rootfs url", [2]string{"https://example.com/amd64/rootfs.tar.gz", "ftp://example.com/rootfs"}, "assets.amd64.rootfs"},
+		{"relative url", [2]string{"https://example.com/arm64/initrd", "initrd"}, "assets.arm64.initrd"},
+		{"bad iso url", [2]string{"#/boot/initrd", ""}, "iso"},
+		{"missing cmdline", [2]string{"cmdline: console=hvc0 root=/dev/vda rw", "cmdline: \"\""}, "boot.cmdline"},
+		{"missing fs type", [2]string{"fs_type: ext4", "fs_type: \"\""}, "setup.fs_type"},
+		{"signature without key", [2]string{"    rootfs: https://example.com/amd64/rootfs.tar.gz", "    rootfs: https://example.com/amd64/rootfs.tar.gz\n    signature_url: https://example.com/amd64/rootfs.tar.gz.asc"}, "signature_url and gpg_key"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := strings.Replace(validCustomSpec, tt.replace[0], tt.replace[1], 1)
+			_, err := ParseCustomDistroSpec([]byte(data))
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want it to mention %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadCustomDistros(t *testing.T) {
+	dir := t.TempDir()
+	spec := strings.Replace(validCustomSpec, "id: testlinux", "id: loadedlinux", 1)
+	if err := os.WriteFile(filepath.Join(dir, "loaded.yaml"), []byte(spec), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "README"), []byte("ignored"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := LoadCustomDistros(dir); err != nil {
+		t.Fatalf("LoadCustomDistros: %v", err)
+	}
+	if !IsRegistered("loadedlinux") || !IsCustom("loadedlinux") {
+		t.Error("loadedlinux should be registered as a custom distro")
+	}
+
+	// Loading again replaces the custom provider without error
+	if err := LoadCustomDistros(dir); err != nil {
+		t.Errorf("reloading: %v", err)
+	}
+
+	if err := LoadCustomDistros(filepath.Join(dir, "missing")); err != nil {
+		t.Errorf("missing dir should not be an error: %v", err)
+	}
+}
+
+func TestRegisterCustomRejectsBuiltin(t *testing.T) {
+	spec, err := ParseCustomDistroSpec([]byte(strings.Replace(validCustomSpec, "id: testlinux", "id: alpine", 1)))
+	if err != nil {
+		t.Fatalf("ParseCustomDistroSpec: %v", err)
+	}
+	if err := RegisterCustom(NewCustomProvider(*spec)); err == nil {
+		t.Error("expected error redefining a built-in distro")
+	}
+	if p, _ := Get(Alpine); p.Name() != "Alpine Linux" {
+		t.Errorf("built-in alpine was replaced by %q", p.Name())
+	}
+}
@@ -1,13 +1,25 @@
 package distro
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 const (
-	debianVersion  = "12"
-	debianCodename = "bookworm"
-	debianBaseURL  = "https://cloud.debian.org/images/cloud"
+	debianVersion = "12"
+	debianBaseURL = "https://cloud.debian.org/images/cloud"
+	// debianRootfsTemplate takes the release codename, version and arch.
+	debianRootfsTemplate = debianBaseURL + "/%s/latest/debian-%s-generic-%s.qcow2"
 )
 
+// debianCodenames maps release versions to the codenames used in cloud
+// image URLs.
+var debianCodenames = map[string]string{
+	"11": "bullseye",
+	"12": "bookworm",
+	"13": "trixie",
+}
+
 // DebianProvider implements Provider for Debian.
 type DebianProvider struct {
 	BaseProvider
@@ -38,15 +50,17 @@ func (p *DebianProvider) AssetURLs(arch Arch) (*AssetURLs, error) {
 	return &AssetURLs{
 		Kernel: "", // Extracted from rootfs
 		Initrd: "", // Extracted from rootfs
-		Rootfs: fmt.Sprintf("%s/%s/latest/debian-%s-genericcloud-%s.qcow2", debianBaseURL, debianCodename, debianVersion, debianArch),
+		Rootfs: fmt.Sprintf(debianRootfsTemplate, debianCodenames[p.version], p.version, debianArch),
 	}, nil
 }
 
 // BootConfig returns the kernel boot configuration for Debian.
 func (p *DebianProvider) BootConfig(arch Arch) *BootConfig {
+	// The generic cloud image is partitioned: the root filesystem is
+	// partition 1, followed by the BIOS boot (14) and EFI (15) partitions
 	return &BootConfig{
-		Cmdline:       "console=hvc0 root=/dev/vda rw rootfstype=ext4",
-		RootDevice:    "/dev/vda",
+		Cmdline:       "console=hvc0 root=/dev/vda1 rw rootfstype=ext4",
+		RootDevice:    "/dev/vda1",
 		RootFSType:    "ext4",
 		ConsoleDevice: "hvc0",
 		ExtraModules:  "",
@@ -62,6 +76,22 @@ func (p *DebianProvider) SetupRequirements() *SetupRequirements {
 	}
 }
 
+// setVersion accepts only releases with a known codename. Debian publishes
+// cloud images per major release, so point releases such as 12.5 cannot be
+// selected.
+func (p *DebianProvider) setVersion(version string) error {
+	if _, ok := debianCodenames[version]; !ok {
+		if major, _, found := strings.Cut(version, "."); found {
+			if _, ok := debianCodenames[major]; ok {
+				return fmt.Errorf("Debian cloud images are published per major release, use %s", major)
+			}
+		}
+		return fmt.Errorf("unknown Debian release %s", version)
+	}
+	p.version = version
+	return nil
+}
+
 // toDebianArch converts our arch to Debian's arch naming.
 func (p *DebianProvider) toDebianArch(arch Arch) string {
 	switch arch {
@@ -0,0 +1,23 @@
+package distro
+
+import "fmt"
+
+// ErrAssetDownloadFailed is returned when a distro asset or version
+// manifest cannot be downloaded. StatusCode is the HTTP status of the
+// response, or 0 if none arrived, in which case Err says why.
+type ErrAssetDownloadFailed struct {
+	URL        string
+	StatusCode int
+	Err        error
+}
+
+func (e *ErrAssetDownloadFailed) Error() string {
+	if e.StatusCode != 0 {
+		return fmt.Sprintf("download failed: HTTP %d (URL: %s)", e.StatusCode, e.URL)
+	}
+	return fmt.Sprintf("download failed: %v", e.Err)
+}
+
+func (e *ErrAssetDownloadFailed) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,106 @@
+package distro
+
+import "fmt"
+
+const (
+	nixosVersion = "24.11"
+	nixosBaseURL = "https://channels.nixos.org/nixos-%s"
+)
+
+// NixOSProvider implements Provider for NixOS.
+//
+// NixOS runs live from the official minimal ISO: the ISO itself is attached
+// as the root disk and the kernel and initrd are extracted from its /boot
+// directory. Stage 1 finds the ISO by its volume label and mounts the Nix
+// store from it read-only, then hands over to the stage 2 init whose store
+// path is copied from the ISO's bootloader config. No disk formatting or
+// rootfs extraction is needed. Changes made in the guest live in a tmpfs
+// overlay and are lost on shutdown.
+type NixOSProvider struct {
+	BaseProvider
+}
+
+// NewNixOSProvider creates a new NixOS provider.
+func NewNixOSProvider() *NixOSProvider {
+	return &NixOSProvider{
+		BaseProvider: BaseProvider{
+			id:      NixOS,
+			name:    "NixOS",
+			version: nixosVersion,
+			archs:   []Arch{ArchAMD64},
+		},
+	}
+}
+
+// AssetURLs returns download URLs for NixOS.
+// The minimal ISO from the stable channel is the rootfs; kernel and initrd
+// are extracted from it via KernelLocator.
+func (p *NixOSProvider) AssetURLs(arch Arch) (*AssetURLs, error) {
+	if !p.SupportsArch(arch) {
+		return nil, &ErrUnsupportedArch{Distro: p.id, Arch: arch}
+	}
+
+	baseURL := fmt.Sprintf(nixosBaseURL, p.version)
+
+	return &AssetURLs{
+		Kernel: "", // Extracted from ISO
+		Initrd: "", // Extracted from ISO
+		Rootfs: fmt.Sprintf("%s/latest-nixos-minimal-%s-linux.iso", baseURL, p.toNixOSArch(arch)),
+	}, nil
+}
+
+// BootConfig returns the kernel boot configuration for NixOS.
+func (p *NixOSProvider) BootConfig(arch Arch) *BootConfig {
+	root := "LABEL=" + p.volumeLabel(arch)
+	return &BootConfig{
+		Cmdline:       fmt.Sprintf("console=hvc0 root=%s boot.shell_on_fail", root),
+		RootDevice:    root,
+		RootFSType:    "iso9660",
+		ConsoleDevice: "hvc0",
+		ExtraModules:  "",
+	}
+}
+
+// SetupRequirements returns setup requirements for NixOS.
+func (p *NixOSProvider) SetupRequirements() *SetupRequirements {
+	return &SetupRequirements{
+		NeedsFormatting: false, // live ISO, nothing to format
+		FSType:          "iso9660",
+		NeedsExtraction: false, // rootfs is the ISO itself
+	}
+}
+
+// KernelLocator returns patterns for finding the kernel in the NixOS ISO.
+func (p *NixOSProvider) KernelLocator() *KernelLocator {
+	return &KernelLocator{
+		KernelPatterns: []string{"boot/bzImage"},
+		InitrdPatterns: []string{"boot/initrd"},
+		ArchiveType:    "iso",
+		InitConfigPatterns: []string{
+			"isolinux/isolinux.cfg",
+			"EFI/boot/grub.cfg",
+			"boot/grub/grub.cfg",
+		},
+	}
+}
+
+// volumeLabel returns the ISO volume label that stage 1 mounts as root.
+func (p *NixOSProvider) volumeLabel(arch Arch) string {
+	return fmt.Sprintf("nixos-minimal-%s-%s", p.version, p.toNixOSArch(arch))
+}
+
+// toNixOSArch converts our arch to NixOS's arch naming.
+func (p *NixOSProvider) toNixOSArch(arch Arch) string {
+	switch arch {
+	case ArchAMD64:
+		return "x86_64"
+	case ArchARM64:
+		return "aarch64"
+	default:
+		return ""
+	}
+}
+
+func init() {
+	Register(NewNixOSProvider())
+}
@@ -35,15 +35,18 @@ func (p *OpenSUSEProvider) AssetURLs(arch Arch) (*AssetURLs, error) {
 
 	// OpenSUSE provides JeOS images (Just Enough OS)
 	return &AssetURLs{
-		Kernel:  "", // Extracted from rootfs
-		Initrd:  "", // Extracted from rootfs
-		Rootfs:  fmt.Sprintf("%s/%s/appliances/openSUSE-Leap-%s-Minimal-VM.%s-Cloud.qcow2", openSUSEBaseURL, p.version, p.version, suseArch),
+		Kernel: "", // Extracted from rootfs
+		Initrd: "", // Extracted from rootfs
+		Rootfs: fmt.Sprintf("%s/%s/appliances/openSUSE-Leap-%s-Minimal-VM.%s-Cloud.qcow2", openSUSEBaseURL, p.version, p.version, suseArch),
 	}, nil
 }
 
 // BootConfig returns the kernel boot configuration for OpenSUSE.
+// On arm64 the cloud image is booted through the hypervisor's UEFI
+// firmware, which reads its own kernel command line from the image's
+// bootloader.
 func (p *OpenSUSEProvider) BootConfig(arch Arch) *BootConfig {
-	return &BootConfig{
+	cfg := &BootConfig{
 		// OpenSUSE uses btrfs by default
 		Cmdline:       "console=hvc0 root=/dev/vda rw rootfstype=btrfs",
 		RootDevice:    "/dev/vda",
@@ -51,6 +54,10 @@ func (p *OpenSUSEProvider) BootConfig(arch Arch) *BootConfig {
 		ConsoleDevice: "hvc0",
 		ExtraModules:  "",
 	}
+	if arch == ArchARM64 {
+		cfg.UEFI = true
+	}
+	return cfg
 }
 
 // SetupRequirements returns setup requirements for OpenSUSE.
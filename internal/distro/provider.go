@@ -16,11 +16,13 @@ const (
 	Debian    ID = "debian"
 	Rocky     ID = "rocky"
 	OpenSUSE  ID = "opensuse"
+	Void      ID = "void"
+	NixOS     ID = "nixos"
 )
 
 // AllDistros returns all supported distribution IDs.
 func AllDistros() []ID {
-	return []ID{Alpine, Ubuntu, ArchLinux, Debian, Rocky, OpenSUSE}
+	return []ID{Alpine, Ubuntu, ArchLinux, Debian, Rocky, OpenSUSE, Void, NixOS}
 }
 
 // Arch represents a CPU architecture.
@@ -48,6 +50,17 @@ type AssetURLs struct {
 	Kernel  string // URL for kernel (vmlinuz)
 	Initrd  string // URL for initial ramdisk
 	Rootfs  string // URL for root filesystem tarball
+
+	// RootfsFallback, if set, is downloaded instead of Rootfs when Rootfs
+	// is not found, e.g. for a point release moved to an archive server.
+	RootfsFallback string
+
+	// SignatureURL is a detached OpenPGP signature of Rootfs, either
+	// ASCII-armored or binary. It is checked against GPGKey after download.
+	SignatureURL string
+
+	// GPGKey is the ASCII-armored release public key that signs Rootfs.
+	GPGKey string
 }
 
 // BootConfig contains kernel boot configuration.
@@ -57,6 +70,14 @@ type BootConfig struct {
 	RootFSType    string // Root filesystem type (e.g., ext4)
 	ConsoleDevice string // Console device (e.g., hvc0)
 	ExtraModules  string // Additional kernel modules to load
+
+	// UEFI boots the root disk through the hypervisor's UEFI firmware
+	// instead of loading the kernel directly.
+	UEFI bool
+
+	// Overlayroot is set when the distro's initramfs has the overlayroot
+	// hook, which an overlay boot (run --overlay) needs to mount the root.
+	Overlayroot bool
 }
 
 // SetupRequirements describes what's needed to set up the rootfs.
@@ -78,6 +99,11 @@ type KernelLocator struct {
 
 	// ArchiveType - "tarball", "qcow2", or "iso"
 	ArchiveType string
+
+	// InitConfigPatterns - glob patterns for bootloader configs in an ISO
+	// whose init= argument is added to the kernel command line. Needed
+	// where stage 2 lives at a path unique to each image, as on NixOS.
+	InitConfigPatterns []string
 }
 
 // Provider defines the interface for distribution-specific configuration.
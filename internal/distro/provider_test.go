@@ -117,7 +117,7 @@ func TestProviderCacheSubdir(t *testing.T) {
 
 func TestKernelLocatorPatterns(t *testing.T) {
 	// Distros that use KernelLocator for extraction
-	extractionDistros := []ID{Ubuntu, Debian, Rocky, OpenSUSE}
+	extractionDistros := []ID{Ubuntu, Debian, Rocky, OpenSUSE, NixOS}
 
 	for _, id := range extractionDistros {
 		t.Run(string(id), func(t *testing.T) {
@@ -147,8 +147,8 @@ func TestKernelLocatorPatterns(t *testing.T) {
 
 func TestDirectDownloadDistros(t *testing.T) {
 	// Alpine uses direct download, no KernelLocator
-	// Arch uses iso: URL scheme instead of KernelLocator
-	directDownloadDistros := []ID{Alpine, ArchLinux}
+	// Arch and Void use iso: URL scheme instead of KernelLocator
+	directDownloadDistros := []ID{Alpine, ArchLinux, Void}
 
 	for _, id := range directDownloadDistros {
 		t.Run(string(id), func(t *testing.T) {
@@ -214,13 +214,23 @@ func TestAssetURLsWellFormed(t *testing.T) {
 						}
 					}
 
-					// Arch uses iso: scheme
-					if id == ArchLinux {
-						if !strings.HasPrefix(urls.Kernel, "iso:") {
-							t.Errorf("Arch kernel URL should start with iso:, got %q", urls.Kernel)
+					// Arch and Void use iso: scheme, Arch Linux ARM uses tar:
+					if id == ArchLinux || id == Void {
+						scheme := "iso:"
+						if id == ArchLinux && arch == ArchARM64 {
+							scheme = "tar:" + urls.Rootfs + "#"
+							if !strings.HasPrefix(urls.Rootfs, "https://") {
+								t.Errorf("Arch Linux ARM rootfs should be fetched over https, got %q", urls.Rootfs)
+							}
+							if urls.SignatureURL != urls.Rootfs+".sig" {
+								t.Errorf("Arch Linux ARM signature URL = %q, want %q", urls.SignatureURL, urls.Rootfs+".sig")
+							}
 						}
-						if !strings.HasPrefix(urls.Initrd, "iso:") {
-							t.Errorf("Arch initrd URL should start with iso:, got %q", urls.Initrd)
+						if !strings.HasPrefix(urls.Kernel, scheme) {
+							t.Errorf("%s kernel URL should start with %s, got %q", id, scheme, urls.Kernel)
+						}
+						if !strings.HasPrefix(urls.Initrd, scheme) {
+							t.Errorf("%s initrd URL should start with %s, got %q", id, scheme, urls.Initrd)
 						}
 					}
 				} else {
@@ -383,11 +393,12 @@ func TestAllDistrosHaveConsistentConfig(t *testing.T) {
 					t.Errorf("ConsoleDevice = %q, want hvc0", bc.ConsoleDevice)
 				}
 
-				// Root device can be /dev/vda or LABEL-based (for cloud images with partitions)
-				validRootDevice := bc.RootDevice == "/dev/vda" ||
+				// Root device can be /dev/vda, a partition of it, or
+				// LABEL-based (for cloud images with partitions)
+				validRootDevice := strings.HasPrefix(bc.RootDevice, "/dev/vda") ||
 					strings.HasPrefix(bc.RootDevice, "LABEL=")
 				if !validRootDevice {
-					t.Errorf("RootDevice = %q, want /dev/vda or LABEL=*", bc.RootDevice)
+					t.Errorf("RootDevice = %q, want /dev/vda, /dev/vdaN or LABEL=*", bc.RootDevice)
 				}
 			})
 		}
@@ -401,10 +412,12 @@ func TestArchSupportPerDistro(t *testing.T) {
 	}{
 		{Alpine, []Arch{ArchAMD64, ArchARM64}},
 		{Ubuntu, []Arch{ArchAMD64, ArchARM64}},
-		{ArchLinux, []Arch{ArchAMD64}}, // Arch only supports x86_64
+		{ArchLinux, []Arch{ArchAMD64, ArchARM64}}, // arm64 via Arch Linux ARM
 		{Debian, []Arch{ArchAMD64, ArchARM64}},
 		{Rocky, []Arch{ArchAMD64, ArchARM64}},
 		{OpenSUSE, []Arch{ArchAMD64, ArchARM64}},
+		{Void, []Arch{ArchAMD64, ArchARM64}},
+		{NixOS, []Arch{ArchAMD64}}, // ISO-only for now
 	}
 
 	for _, tt := range tests {
@@ -427,3 +440,114 @@ func TestArchSupportPerDistro(t *testing.T) {
 		})
 	}
 }
+
+func TestVoidAssetURLs(t *testing.T) {
+	p := NewVoidProvider()
+
+	tests := []struct {
+		arch     Arch
+		voidArch string
+	}{
+		{ArchAMD64, "x86_64"},
+		{ArchARM64, "aarch64"},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.arch), func(t *testing.T) {
+			urls, err := p.AssetURLs(tt.arch)
+			if err != nil {
+				t.Fatalf("AssetURLs failed: %v", err)
+			}
+
+			wantRootfs := fmt.Sprintf("void-%s-musl-ROOTFS-%s.tar.xz", tt.voidArch, voidVersion)
+			if !strings.HasSuffix(urls.Rootfs, wantRootfs) {
+				t.Errorf("Rootfs = %q, want suffix %q", urls.Rootfs, wantRootfs)
+			}
+
+			iso := fmt.Sprintf("void-live-%s-musl-%s-base.iso", tt.voidArch, voidVersion)
+			if !strings.Contains(urls.Kernel, iso) || !strings.HasSuffix(urls.Kernel, "#/boot/vmlinuz") {
+				t.Errorf("Kernel = %q, want vmlinuz from %s", urls.Kernel, iso)
+			}
+			if !strings.Contains(urls.Initrd, iso) || !strings.HasSuffix(urls.Initrd, "#/boot/initrd") {
+				t.Errorf("Initrd = %q, want initrd from %s", urls.Initrd, iso)
+			}
+		})
+	}
+
+	sr := p.SetupRequirements()
+	if !sr.NeedsFormatting || !sr.NeedsExtraction || sr.FSType != "ext4" {
+		t.Errorf("SetupRequirements = %+v, want ext4 formatting and extraction", sr)
+	}
+}
+
+func TestDebianAssetURLs(t *testing.T) {
+	p := NewDebianProvider()
+
+	for _, arch := range []Arch{ArchAMD64, ArchARM64} {
+		t.Run(string(arch), func(t *testing.T) {
+			urls, err := p.AssetURLs(arch)
+			if err != nil {
+				t.Fatalf("AssetURLs failed: %v", err)
+			}
+
+			want := fmt.Sprintf("https://cloud.debian.org/images/cloud/bookworm/latest/debian-12-generic-%s.qcow2", arch)
+			if urls.Rootfs != want {
+				t.Errorf("Rootfs = %q, want %q", urls.Rootfs, want)
+			}
+			if urls.Kernel != "" || urls.Initrd != "" {
+				t.Errorf("Kernel = %q, Initrd = %q, want both extracted from the rootfs", urls.Kernel, urls.Initrd)
+			}
+		})
+	}
+
+	if loc := p.KernelLocator(); loc.ArchiveType != "qcow2" {
+		t.Errorf("KernelLocator().ArchiveType = %q, want qcow2", loc.ArchiveType)
+	}
+	if sr := p.SetupRequirements(); sr.NeedsExtraction {
+		t.Errorf("SetupRequirements().NeedsExtraction = true, want false")
+	}
+}
+
+// The generic image boots the disk itself, which is partitioned with the
+// root filesystem on partition 1, so the kernel must not mount the whole
+// disk.
+func TestDebianBootConfigRootPartition(t *testing.T) {
+	p := NewDebianProvider()
+	for _, arch := range []Arch{ArchAMD64, ArchARM64} {
+		bc := p.BootConfig(arch)
+		if bc.RootDevice != "/dev/vda1" {
+			t.Errorf("%s: RootDevice = %q, want /dev/vda1", arch, bc.RootDevice)
+		}
+		if !strings.Contains(bc.Cmdline, "root=/dev/vda1 ") {
+			t.Errorf("%s: Cmdline = %q, want root=/dev/vda1", arch, bc.Cmdline)
+		}
+	}
+}
+
+func TestArchBootConfigPerArch(t *testing.T) {
+	p := NewArchProvider()
+
+	amd64 := p.BootConfig(ArchAMD64)
+	if amd64.RootDevice != "/dev/vda" {
+		t.Errorf("amd64 RootDevice = %q, want /dev/vda", amd64.RootDevice)
+	}
+
+	arm64 := p.BootConfig(ArchARM64)
+	if arm64.RootDevice != "LABEL=vmterminal" {
+		t.Errorf("arm64 RootDevice = %q, want LABEL=vmterminal", arm64.RootDevice)
+	}
+	if !strings.Contains(arm64.Cmdline, "root=LABEL=vmterminal") || !strings.Contains(arm64.Cmdline, "rootwait") {
+		t.Errorf("arm64 Cmdline = %q", arm64.Cmdline)
+	}
+}
+
+func TestOpenSUSEUEFIBoot(t *testing.T) {
+	p := NewOpenSUSEProvider()
+
+	if !p.BootConfig(ArchARM64).UEFI {
+		t.Error("arm64 should boot through UEFI")
+	}
+	if p.BootConfig(ArchAMD64).UEFI {
+		t.Error("amd64 should boot the kernel directly")
+	}
+}
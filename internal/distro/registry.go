@@ -3,14 +3,40 @@ package distro
 import (
 	"fmt"
 	"sync"
+	"sync/atomic"
 )
 
 var (
 	registry     = make(map[ID]Provider)
 	registryLock sync.RWMutex
 	defaultID    ID = Alpine
+
+	loader     func()
+	loaderOnce sync.Once
+	loading    atomic.Bool
 )
 
+// SetLoader sets a function that registers distros defined outside the
+// binary, such as custom definitions and version overrides. It runs once,
+// on the first lookup, so commands that never look up a distro do not read
+// those files. Lookups the loader itself makes see the registry as it is so
+// far.
+func SetLoader(fn func()) {
+	loader = fn
+}
+
+// load runs the loader if it has not run yet.
+func load() {
+	if loader == nil || loading.Load() {
+		return
+	}
+	loaderOnce.Do(func() {
+		loading.Store(true)
+		defer loading.Store(false)
+		loader()
+	})
+}
+
 // Register adds a provider to the registry.
 // This should be called from init() functions in provider implementations.
 func Register(p Provider) {
@@ -21,6 +47,7 @@ func Register(p Provider) {
 
 // Get returns a provider by ID.
 func Get(id ID) (Provider, error) {
+	load()
 	registryLock.RLock()
 	defer registryLock.RUnlock()
 
@@ -43,6 +70,7 @@ func DefaultID() ID {
 
 // SetDefault changes the default distribution ID.
 func SetDefault(id ID) error {
+	load()
 	registryLock.Lock()
 	defer registryLock.Unlock()
 
@@ -55,6 +83,7 @@ func SetDefault(id ID) error {
 
 // List returns all registered provider IDs.
 func List() []ID {
+	load()
 	registryLock.RLock()
 	defer registryLock.RUnlock()
 
@@ -67,6 +96,7 @@ func List() []ID {
 
 // ListProviders returns all registered providers.
 func ListProviders() []Provider {
+	load()
 	registryLock.RLock()
 	defer registryLock.RUnlock()
 
@@ -79,6 +109,7 @@ func ListProviders() []Provider {
 
 // IsRegistered checks if a distribution ID is registered.
 func IsRegistered(id ID) bool {
+	load()
 	registryLock.RLock()
 	defer registryLock.RUnlock()
 	_, ok := registry[id]
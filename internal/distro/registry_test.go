@@ -2,6 +2,7 @@ package distro
 
 import (
 	"errors"
+	"sync"
 	"testing"
 )
 
@@ -17,6 +18,8 @@ func TestRegistryGet(t *testing.T) {
 		{"debian", Debian, false},
 		{"rocky", Rocky, false},
 		{"opensuse", OpenSUSE, false},
+		{"void", Void, false},
+		{"nixos", NixOS, false},
 		{"unknown", ID("unknown"), true},
 		{"empty", ID(""), true},
 	}
@@ -50,6 +53,8 @@ func TestIsRegistered(t *testing.T) {
 		{"debian registered", Debian, true},
 		{"rocky registered", Rocky, true},
 		{"opensuse registered", OpenSUSE, true},
+		{"void registered", Void, true},
+		{"nixos registered", NixOS, true},
 		{"unknown not registered", ID("unknown"), false},
 		{"empty not registered", ID(""), false},
 		{"random not registered", ID("random-distro"), false},
@@ -97,7 +102,7 @@ func TestList(t *testing.T) {
 	}
 
 	// Check all expected distros are present
-	expected := []ID{Alpine, Ubuntu, ArchLinux, Debian, Rocky, OpenSUSE}
+	expected := []ID{Alpine, Ubuntu, ArchLinux, Debian, Rocky, OpenSUSE, Void, NixOS}
 	for _, exp := range expected {
 		found := false
 		for _, id := range ids {
@@ -149,6 +154,8 @@ func TestParseID(t *testing.T) {
 		{"debian", "debian", Debian, false},
 		{"rocky", "rocky", Rocky, false},
 		{"opensuse", "opensuse", OpenSUSE, false},
+		{"void", "void", Void, false},
+		{"nixos", "nixos", NixOS, false},
 		{"unknown", "unknown", "", true},
 		{"empty", "", "", true},
 		{"invalid", "not-a-distro", "", true},
@@ -229,3 +236,28 @@ func TestProviderInterface(t *testing.T) {
 		})
 	}
 }
+
+func TestSetLoader(t *testing.T) {
+	t.Cleanup(func() {
+		loader = nil
+		loaderOnce = sync.Once{}
+	})
+
+	runs := 0
+	SetLoader(func() {
+		runs++
+		// Lookups from the loader must not wait for it
+		if !IsRegistered(Alpine) {
+			t.Error("built-in distro missing while loading")
+		}
+	})
+	if runs != 0 {
+		t.Fatalf("loader ran %d times before any lookup", runs)
+	}
+
+	List()
+	Get(Alpine)
+	if runs != 1 {
+		t.Errorf("loader ran %d times, want 1", runs)
+	}
+}
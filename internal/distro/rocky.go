@@ -1,10 +1,20 @@
 package distro
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 const (
 	rockyVersion = "9"
 	rockyBaseURL = "https://dl.rockylinux.org/pub/rocky"
+	// rockyVaultURL is where Rocky moves every point release once the
+	// next one is out; the vault.rockylinux.org tree is served here.
+	rockyVaultURL = "https://dl.rockylinux.org/vault/rocky"
+	// rockyRootfsPath takes the version directory, arch, major version
+	// and arch. Image file names only carry the major version, so a pinned
+	// minor such as 9.2 selects its directory.
+	rockyRootfsPath = "/%s/images/%s/Rocky-%s-GenericCloud.latest.%s.qcow2"
 )
 
 // RockyProvider implements Provider for Rocky Linux.
@@ -32,13 +42,25 @@ func (p *RockyProvider) AssetURLs(arch Arch) (*AssetURLs, error) {
 	}
 
 	rockyArch := p.toRockyArch(arch)
+	path := fmt.Sprintf(rockyRootfsPath, p.version, rockyArch, p.majorVersion(), rockyArch)
 
 	// Rocky provides GenericCloud images
-	return &AssetURLs{
-		Kernel:  "", // Extracted from rootfs
-		Initrd:  "", // Extracted from rootfs
-		Rootfs:  fmt.Sprintf("%s/%s/images/%s/Rocky-%s-GenericCloud.latest.%s.qcow2", rockyBaseURL, p.version, rockyArch, p.version, rockyArch),
-	}, nil
+	urls := &AssetURLs{
+		Kernel: "", // Extracted from rootfs
+		Initrd: "", // Extracted from rootfs
+		Rootfs: rockyBaseURL + path,
+	}
+	// Only the current point release stays on the main server
+	if strings.Contains(p.version, ".") {
+		urls.RootfsFallback = rockyVaultURL + path
+	}
+	return urls, nil
+}
+
+// majorVersion returns the major part of the version, e.g. "9" for "9.2".
+func (p *RockyProvider) majorVersion() string {
+	major, _, _ := strings.Cut(p.version, ".")
+	return major
 }
 
 // BootConfig returns the kernel boot configuration for Rocky.
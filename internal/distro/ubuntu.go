@@ -3,11 +3,20 @@ package distro
 import "fmt"
 
 const (
-	ubuntuVersion  = "24.04"
-	ubuntuCodename = "noble"
-	ubuntuBaseURL  = "https://cloud-images.ubuntu.com"
+	ubuntuVersion = "24.04"
+	ubuntuBaseURL = "https://cloud-images.ubuntu.com"
 )
 
+// ubuntuCodenames maps release versions to the codenames used in cloud
+// image URLs.
+var ubuntuCodenames = map[string]string{
+	"22.04": "jammy",
+	"24.04": "noble",
+	"24.10": "oracular",
+	"25.04": "plucky",
+	"25.10": "questing",
+}
+
 // UbuntuProvider implements Provider for Ubuntu.
 type UbuntuProvider struct {
 	BaseProvider
@@ -34,12 +43,13 @@ func (p *UbuntuProvider) AssetURLs(arch Arch) (*AssetURLs, error) {
 	}
 
 	ubuntuArch := p.toUbuntuArch(arch)
+	codename := ubuntuCodenames[p.version]
 
 	// Ubuntu cloud images: use .img (qcow2) which contains kernel/initrd in /boot
 	return &AssetURLs{
 		Kernel: "", // Extracted from rootfs
 		Initrd: "", // Extracted from rootfs
-		Rootfs: fmt.Sprintf("%s/%s/current/%s-server-cloudimg-%s.img", ubuntuBaseURL, ubuntuCodename, ubuntuCodename, ubuntuArch),
+		Rootfs: fmt.Sprintf("%s/%s/current/%s-server-cloudimg-%s.img", ubuntuBaseURL, codename, codename, ubuntuArch),
 	}, nil
 }
 
@@ -54,6 +64,9 @@ func (p *UbuntuProvider) BootConfig(arch Arch) *BootConfig {
 		RootFSType:    "ext4",
 		ConsoleDevice: "hvc0",
 		ExtraModules:  "",
+
+		// The cloud images ship the overlayroot package
+		Overlayroot: true,
 	}
 }
 
@@ -66,6 +79,15 @@ func (p *UbuntuProvider) SetupRequirements() *SetupRequirements {
 	}
 }
 
+// setVersion accepts only releases with a known codename.
+func (p *UbuntuProvider) setVersion(version string) error {
+	if _, ok := ubuntuCodenames[version]; !ok {
+		return fmt.Errorf("unknown Ubuntu release %s", version)
+	}
+	p.version = version
+	return nil
+}
+
 // toUbuntuArch converts our arch to Ubuntu's arch naming.
 func (p *UbuntuProvider) toUbuntuArch(arch Arch) string {
 	switch arch {
@@ -0,0 +1,269 @@
+package distro
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// VersionsFile is the name of the distro version registry in the data dir.
+const VersionsFile = "distro_versions.json"
+
+// DefaultManifestURL is where 'distro update' looks for newer versions:
+// the distro_versions.json at the repository root, attached to every
+// release.
+const DefaultManifestURL = "https://github.com/javanstorm/vmterminal/releases/latest/download/distro_versions.json"
+
+// maxManifestSize bounds the manifest download.
+const maxManifestSize = 1 << 20
+
+// versionPattern restricts versions to characters that are safe in URLs
+// and cache paths.
+var versionPattern = regexp.MustCompile(`^[0-9A-Za-z][0-9A-Za-z._-]*$`)
+
+// pinPattern is the stricter form accepted for user pins: a major version
+// with optional minor and patch, e.g. "9", "9.2" or "3.21.1", or a dated
+// snapshot of a rolling branch, e.g. "edge-20250108".
+var pinPattern = regexp.MustCompile(`^([0-9]+(\.[0-9]+){0,2}|edge-[0-9]{8})$`)
+
+// VersionRegistry maps distro IDs to versions. The same format is used for
+// the remote manifest and the local distro_versions.json.
+type VersionRegistry struct {
+	Versions  map[ID]string `json:"versions"`
+	UpdatedAt time.Time     `json:"updated_at,omitempty"`
+
+	// Pinned lists the distros whose version was chosen with 'distro pin';
+	// 'distro update' leaves them alone. Only the local file uses it.
+	Pinned []ID `json:"pinned,omitempty"`
+}
+
+// IsPinned reports whether id was pinned by the user.
+func (r *VersionRegistry) IsPinned(id ID) bool {
+	return slices.Contains(r.Pinned, id)
+}
+
+// Pin records version for id and marks it pinned.
+func (r *VersionRegistry) Pin(id ID, version string) {
+	r.Versions[id] = version
+	if !r.IsPinned(id) {
+		r.Pinned = append(r.Pinned, id)
+		slices.Sort(r.Pinned)
+	}
+}
+
+// Unpin drops the pin and the recorded version of id, so it goes back to
+// the compiled-in version and 'distro update' manages it again.
+func (r *VersionRegistry) Unpin(id ID) {
+	delete(r.Versions, id)
+	r.Pinned = slices.DeleteFunc(r.Pinned, func(p ID) bool { return p == id })
+}
+
+// versionSetter is implemented by providers whose version can be changed
+// at runtime.
+type versionSetter interface {
+	setVersion(version string) error
+}
+
+// setVersion replaces the provider's version. Providers whose asset URLs do
+// not follow the version override it.
+func (p *BaseProvider) setVersion(version string) error {
+	if strings.HasPrefix(version, "edge") {
+		return fmt.Errorf("%s has no edge branch", p.name)
+	}
+	p.version = version
+	return nil
+}
+
+// ValidateVersion checks that version is safe to use in URLs and paths.
+func ValidateVersion(version string) error {
+	if !versionPattern.MatchString(version) || strings.Contains(version, "..") {
+		return fmt.Errorf("invalid version %q", version)
+	}
+	return nil
+}
+
+// ValidatePinnedVersion checks that a user-supplied version pin is a
+// numeric semver-style version, so it cannot inject anything into asset
+// URLs or cache paths.
+func ValidatePinnedVersion(version string) error {
+	if !pinPattern.MatchString(version) {
+		return fmt.Errorf("invalid version %q: expected <major>[.<minor>[.<patch>]] or edge-<yyyymmdd>", version)
+	}
+	return nil
+}
+
+// PinVersion validates a user pin and applies it to a registered distro.
+func PinVersion(id ID, version string) error {
+	if err := ValidatePinnedVersion(version); err != nil {
+		return fmt.Errorf("%s: %w", id, err)
+	}
+	return SetVersion(id, version)
+}
+
+// SetVersion changes the version of a registered distro. It fails for
+// distros whose version is fixed, such as custom definitions. Call it
+// before the provider is in use; versions are not read under a lock.
+func SetVersion(id ID, version string) error {
+	if err := ValidateVersion(version); err != nil {
+		return err
+	}
+	if IsCustom(id) {
+		return fmt.Errorf("%s: custom distros are versioned by their definition file", id)
+	}
+	p, err := Get(id)
+	if err != nil {
+		return err
+	}
+	vs, ok := p.(versionSetter)
+	if !ok {
+		return fmt.Errorf("%s: version cannot be changed", id)
+	}
+	if err := vs.setVersion(version); err != nil {
+		return fmt.Errorf("%s: %w", id, err)
+	}
+	return nil
+}
+
+// LoadVersions reads the version registry at path. A missing file yields
+// an empty registry.
+func LoadVersions(path string) (*VersionRegistry, error) {
+	reg := &VersionRegistry{Versions: make(map[ID]string)}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return reg, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read distro versions: %w", err)
+	}
+	if err := json.Unmarshal(data, reg); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if reg.Versions == nil {
+		reg.Versions = make(map[ID]string)
+	}
+	return reg, nil
+}
+
+// Save writes the registry to path atomically.
+func (r *VersionRegistry) Save(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("create data dir: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("write distro versions: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("write distro versions: %w", err)
+	}
+	return nil
+}
+
+// ApplyVersions sets the version of every registered distro listed in the
+// registry at path, so providers use it instead of their compiled-in
+// default. Entries that cannot be applied are skipped and reported together.
+func ApplyVersions(path string) error {
+	reg, err := LoadVersions(path)
+	if err != nil {
+		return err
+	}
+
+	var problems []string
+	for _, id := range reg.IDs() {
+		if !IsRegistered(id) {
+			continue
+		}
+		if err := SetVersion(id, reg.Versions[id]); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%s: %s", path, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// IDs returns the registry's distro IDs in sorted order.
+func (r *VersionRegistry) IDs() []ID {
+	ids := make([]ID, 0, len(r.Versions))
+	for id := range r.Versions {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// FetchManifest downloads a version manifest from url.
+func FetchManifest(ctx context.Context, url string) (*VersionRegistry, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("manifest request: %w", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetch manifest: %w", &ErrAssetDownloadFailed{URL: url, Err: err})
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch manifest: %w", &ErrAssetDownloadFailed{URL: url, StatusCode: resp.StatusCode})
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxManifestSize))
+	if err != nil {
+		return nil, fmt.Errorf("read manifest: %w", err)
+	}
+
+	manifest := &VersionRegistry{}
+	if err := json.Unmarshal(data, manifest); err != nil {
+		return nil, fmt.Errorf("parse manifest: %w", err)
+	}
+	for id, version := range manifest.Versions {
+		if err := ValidateVersion(version); err != nil {
+			return nil, fmt.Errorf("manifest entry %s: %w", id, err)
+		}
+	}
+	return manifest, nil
+}
+
+// CompareVersions compares dotted versions such as "3.21" and "3.9",
+// returning -1, 0 or 1. Numeric components compare as numbers, others as
+// strings; a missing component sorts before a present one.
+func CompareVersions(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		if i >= len(as) {
+			return -1
+		}
+		if i >= len(bs) {
+			return 1
+		}
+		an, aErr := strconv.Atoi(as[i])
+		bn, bErr := strconv.Atoi(bs[i])
+		var c int
+		if aErr == nil && bErr == nil {
+			c = cmp.Compare(an, bn)
+		} else {
+			c = strings.Compare(as[i], bs[i])
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return 0
+}
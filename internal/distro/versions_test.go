@@ -0,0 +1,303 @@
+package distro
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"3.21", "3.21", 0},
+		{"3.22", "3.21", 1},
+		{"3.9", "3.21", -1},
+		{"24.10", "24.04", 1},
+		{"12", "13", -1},
+		{"15.6", "15", 1},
+		{"20250202", "20240314", 1},
+	}
+	for _, tt := range tests {
+		if got := CompareVersions(tt.a, tt.b); got != tt.want {
+			t.Errorf("CompareVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestValidateVersion(t *testing.T) {
+	for _, v := range []string{"3.21", "24.04", "20250202", "9"} {
+		if err := ValidateVersion(v); err != nil {
+			t.Errorf("ValidateVersion(%q) = %v, want nil", v, err)
+		}
+	}
+	for _, v := range []string{"", "../etc", "3.21/../../x", "a b", ".hidden", "1..2"} {
+		if err := ValidateVersion(v); err == nil {
+			t.Errorf("ValidateVersion(%q) = nil, want error", v)
+		}
+	}
+}
+
+// keepVersion restores id's version when the test ends.
+func keepVersion(t *testing.T, id ID) {
+	t.Helper()
+	p, err := Get(id)
+	if err != nil {
+		t.Fatalf("Get(%s): %v", id, err)
+	}
+	orig := p.Version()
+	t.Cleanup(func() { SetVersion(id, orig) })
+}
+
+func TestSetVersion(t *testing.T) {
+	keepVersion(t, Alpine)
+	if err := SetVersion(Alpine, "3.22"); err != nil {
+		t.Fatalf("SetVersion(alpine): %v", err)
+	}
+	p, _ := Get(Alpine)
+	if p.Version() != "3.22" || p.CacheSubdir(ArchAMD64) != "alpine/3.22/amd64" {
+		t.Errorf("version not applied: %s, %s", p.Version(), p.CacheSubdir(ArchAMD64))
+	}
+	urls, _ := p.AssetURLs(ArchAMD64)
+	if !strings.Contains(urls.Rootfs, "/v3.22/") {
+		t.Errorf("asset URL does not follow version: %s", urls.Rootfs)
+	}
+
+	keepVersion(t, Ubuntu)
+	if err := SetVersion(Ubuntu, "25.04"); err != nil {
+		t.Fatalf("SetVersion(ubuntu): %v", err)
+	}
+	p, _ = Get(Ubuntu)
+	urls, _ = p.AssetURLs(ArchAMD64)
+	if !strings.Contains(urls.Rootfs, "/plucky/") {
+		t.Errorf("Ubuntu URL does not use the release codename: %s", urls.Rootfs)
+	}
+	if err := SetVersion(Ubuntu, "99.04"); err == nil {
+		t.Error("expected error for Ubuntu release without a codename")
+	}
+
+	if err := SetVersion(ArchLinux, "2025.01.01"); err == nil {
+		t.Error("expected error for rolling-release Arch")
+	}
+	if err := SetVersion(Alpine, "../x"); err == nil {
+		t.Error("expected error for unsafe version")
+	}
+}
+
+func TestPinVersion(t *testing.T) {
+	keepVersion(t, Rocky)
+	keepVersion(t, Debian)
+
+	if err := PinVersion(Rocky, "9.2"); err != nil {
+		t.Fatalf("PinVersion(rocky, 9.2): %v", err)
+	}
+	p, _ := Get(Rocky)
+	if p.Version() != "9.2" {
+		t.Errorf("Version() = %q, want 9.2", p.Version())
+	}
+	urls, err := p.AssetURLs(ArchAMD64)
+	if err != nil {
+		t.Fatalf("AssetURLs: %v", err)
+	}
+	want := "https://dl.rockylinux.org/pub/rocky/9.2/images/x86_64/Rocky-9-GenericCloud.latest.x86_64.qcow2"
+	if urls.Rootfs != want {
+		t.Errorf("Rootfs = %q, want %q", urls.Rootfs, want)
+	}
+	want = "https://dl.rockylinux.org/vault/rocky/9.2/images/x86_64/Rocky-9-GenericCloud.latest.x86_64.qcow2"
+	if urls.RootfsFallback != want {
+		t.Errorf("RootfsFallback = %q, want %q", urls.RootfsFallback, want)
+	}
+
+	if err := PinVersion(Debian, "12.5"); err == nil || !strings.Contains(err.Error(), "use 12") {
+		t.Errorf("PinVersion(debian, 12.5) error = %v, want hint to use 12", err)
+	}
+	for _, bad := range []string{"9.2-beta", "latest", "9.2.1.4", "9/../8", ""} {
+		if err := PinVersion(Rocky, bad); err == nil {
+			t.Errorf("PinVersion(rocky, %q) should fail", bad)
+		}
+	}
+}
+
+func TestVersionRegistryApply(t *testing.T) {
+	keepVersion(t, Alpine)
+	path := filepath.Join(t.TempDir(), VersionsFile)
+
+	// A missing file changes nothing
+	if err := ApplyVersions(path); err != nil {
+		t.Fatalf("ApplyVersions(missing): %v", err)
+	}
+
+	reg, err := LoadVersions(path)
+	if err != nil {
+		t.Fatalf("LoadVersions: %v", err)
+	}
+	reg.Versions[Alpine] = "3.23"
+	reg.Versions["not-a-distro"] = "1.0"
+	if err := reg.Save(path); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	if err := ApplyVersions(path); err != nil {
+		t.Fatalf("ApplyVersions: %v", err)
+	}
+	if p, _ := Get(Alpine); p.Version() != "3.23" {
+		t.Errorf("alpine version = %s, want 3.23", p.Version())
+	}
+
+	os.WriteFile(path, []byte(`{"versions": {"arch": "2025.01.01"}}`), 0644)
+	if err := ApplyVersions(path); err == nil {
+		t.Error("expected error for an entry that cannot be applied")
+	}
+}
+
+func TestFetchManifest(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ok.json":
+			fmt.Fprint(w, `{"versions": {"alpine": "3.22", "ubuntu": "24.10"}}`)
+		case "/bad.json":
+			fmt.Fprint(w, `{"versions": {"alpine": "../../etc"}}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	m, err := FetchManifest(context.Background(), srv.URL+"/ok.json")
+	if err != nil {
+		t.Fatalf("FetchManifest: %v", err)
+	}
+	if m.Versions[Alpine] != "3.22" || m.Versions[Ubuntu] != "24.10" {
+		t.Errorf("manifest = %+v", m.Versions)
+	}
+	if ids := m.IDs(); len(ids) != 2 || ids[0] != Alpine {
+		t.Errorf("IDs() = %v, want sorted [alpine ubuntu]", ids)
+	}
+
+	if _, err := FetchManifest(context.Background(), srv.URL+"/bad.json"); err == nil {
+		t.Error("expected error for unsafe manifest version")
+	}
+	_, err = FetchManifest(context.Background(), srv.URL+"/missing.json")
+	var download *ErrAssetDownloadFailed
+	if !errors.As(err, &download) || download.StatusCode != http.StatusNotFound {
+		t.Errorf("FetchManifest for a missing manifest = %v, want ErrAssetDownloadFailed with HTTP 404", err)
+	}
+}
+
+// TestReleaseManifest checks the distro_versions.json attached to every
+// release, which 'distro update' downloads from DefaultManifestURL.
+func TestReleaseManifest(t *testing.T) {
+	manifest, err := LoadVersions(filepath.Join("..", "..", VersionsFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(manifest.Versions) == 0 {
+		t.Fatal("release manifest lists no distros")
+	}
+	for _, id := range manifest.IDs() {
+		version := manifest.Versions[id]
+		p, err := Get(id)
+		if err != nil {
+			t.Errorf("manifest entry %s: %v", id, err)
+			continue
+		}
+		if CompareVersions(version, p.Version()) < 0 {
+			t.Errorf("manifest has %s %s, older than the built-in %s", id, version, p.Version())
+		}
+		keepVersion(t, id)
+		if err := SetVersion(id, version); err != nil {
+			t.Errorf("manifest entry %s: %v", id, err)
+		}
+	}
+}
+
+func TestAlpineVersions(t *testing.T) {
+	keepVersion(t, Alpine)
+	tests := []struct {
+		version, branch, rootfs string
+	}{
+		{"3.18", "/v3.18/", "alpine-minirootfs-3.18.0-x86_64.tar.gz"},
+		{"3.19.1", "/v3.19/", "alpine-minirootfs-3.19.1-x86_64.tar.gz"},
+		{"edge-20250108", "/edge/", "alpine-minirootfs-20250108-x86_64.tar.gz"},
+	}
+	for _, tt := range tests {
+		if err := PinVersion(Alpine, tt.version); err != nil {
+			t.Fatalf("PinVersion(alpine, %s): %v", tt.version, err)
+		}
+		p, _ := Get(Alpine)
+		urls, err := p.AssetURLs(ArchAMD64)
+		if err != nil {
+			t.Fatalf("AssetURLs: %v", err)
+		}
+		if !strings.Contains(urls.Kernel, tt.branch) || !strings.HasSuffix(urls.Rootfs, tt.branch+"releases/x86_64/"+tt.rootfs) {
+			t.Errorf("alpine %s: kernel %s, rootfs %s", tt.version, urls.Kernel, urls.Rootfs)
+		}
+	}
+
+	for _, bad := range []string{"edge", "3", "edge-2025"} {
+		if err := SetVersion(Alpine, bad); err == nil {
+			t.Errorf("SetVersion(alpine, %q) should fail", bad)
+		}
+	}
+	p, _ := Get(Alpine)
+	if urls, _ := p.AssetURLs(ArchAMD64); !strings.HasSuffix(urls.Kernel, "/edge/releases/x86_64/netboot-20250108/vmlinuz-virt") {
+		t.Errorf("edge kernel = %s, want the snapshot's netboot directory", urls.Kernel)
+	}
+
+	// Only Alpine has an edge branch
+	keepVersion(t, Rocky)
+	if err := PinVersion(Rocky, "edge-20250108"); err == nil {
+		t.Error("PinVersion(rocky, edge-20250108) should fail")
+	}
+}
+
+func TestParseAlpineEdgeReleases(t *testing.T) {
+	data := []byte(`---
+-
+  title: "Netboot"
+  flavor: "alpine-netboot"
+  version: "20250108"
+-
+  title: "Mini root filesystem"
+  flavor: "alpine-minirootfs"
+  version: "20250108"
+  file: "alpine-minirootfs-20250108-x86_64.tar.gz"
+`)
+	got, err := parseAlpineEdgeReleases(data)
+	if err != nil || got != "edge-20250108" {
+		t.Errorf("parseAlpineEdgeReleases = %q, %v, want edge-20250108", got, err)
+	}
+	if _, err := parseAlpineEdgeReleases([]byte("- flavor: alpine-netboot\n  version: \"20250108\"\n")); err == nil {
+		t.Error("expected error without a minirootfs entry")
+	}
+}
+
+func TestVersionRegistryPins(t *testing.T) {
+	path := filepath.Join(t.TempDir(), VersionsFile)
+	reg, _ := LoadVersions(path)
+	reg.Versions[Rocky] = "9.5"
+	reg.Pin(Alpine, "3.18")
+	reg.Pin(Alpine, "3.19")
+	if err := reg.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	reg, err := LoadVersions(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reg.IsPinned(Alpine) || reg.IsPinned(Rocky) || len(reg.Pinned) != 1 || reg.Versions[Alpine] != "3.19" {
+		t.Errorf("after load: %+v", reg)
+	}
+	reg.Unpin(Alpine)
+	if reg.IsPinned(Alpine) || reg.Versions[Alpine] != "" || reg.Versions[Rocky] != "9.5" {
+		t.Errorf("after unpin: %+v", reg)
+	}
+}
@@ -0,0 +1,86 @@
+package distro
+
+import "fmt"
+
+const (
+	voidVersion = "20250202"
+	voidBaseURL = "https://repo-default.voidlinux.org/live"
+)
+
+// VoidProvider implements Provider for Void Linux (musl).
+type VoidProvider struct {
+	BaseProvider
+}
+
+// NewVoidProvider creates a new Void Linux provider.
+func NewVoidProvider() *VoidProvider {
+	return &VoidProvider{
+		BaseProvider: BaseProvider{
+			id:      Void,
+			name:    "Void Linux",
+			version: voidVersion,
+			archs:   []Arch{ArchAMD64, ArchARM64},
+		},
+	}
+}
+
+// AssetURLs returns download URLs for Void Linux.
+// The ROOTFS tarball ships no kernel, so kernel and initrd are
+// extracted from the live ISO using the iso: URL scheme.
+func (p *VoidProvider) AssetURLs(arch Arch) (*AssetURLs, error) {
+	if !p.SupportsArch(arch) {
+		return nil, &ErrUnsupportedArch{Distro: p.id, Arch: arch}
+	}
+
+	voidArch := p.toVoidArch(arch)
+	baseURL := fmt.Sprintf("%s/%s", voidBaseURL, p.version)
+	isoURL := fmt.Sprintf("%s/void-live-%s-musl-%s-base.iso", baseURL, voidArch, p.version)
+
+	return &AssetURLs{
+		Kernel: fmt.Sprintf("iso:%s#/boot/vmlinuz", isoURL),
+		Initrd: fmt.Sprintf("iso:%s#/boot/initrd", isoURL),
+		Rootfs: fmt.Sprintf("%s/void-%s-musl-ROOTFS-%s.tar.xz", baseURL, voidArch, p.version),
+	}, nil
+}
+
+// BootConfig returns the kernel boot configuration for Void.
+func (p *VoidProvider) BootConfig(arch Arch) *BootConfig {
+	return &BootConfig{
+		Cmdline:       "console=hvc0 root=/dev/vda rw rootfstype=ext4",
+		RootDevice:    "/dev/vda",
+		RootFSType:    "ext4",
+		ConsoleDevice: "hvc0",
+		ExtraModules:  "",
+	}
+}
+
+// SetupRequirements returns setup requirements for Void.
+func (p *VoidProvider) SetupRequirements() *SetupRequirements {
+	return &SetupRequirements{
+		NeedsFormatting: true,
+		FSType:          "ext4",
+		NeedsExtraction: true,
+	}
+}
+
+// toVoidArch converts our arch to Void's arch naming.
+func (p *VoidProvider) toVoidArch(arch Arch) string {
+	switch arch {
+	case ArchAMD64:
+		return "x86_64"
+	case ArchARM64:
+		return "aarch64"
+	default:
+		return ""
+	}
+}
+
+// KernelLocator returns nil because Void's rootfs has no kernel.
+// The kernel and initrd paths are specified in AssetURLs using the iso: prefix.
+func (p *VoidProvider) KernelLocator() *KernelLocator {
+	return nil
+}
+
+func init() {
+	Register(NewVoidProvider())
+}
@@ -0,0 +1,63 @@
+// Package filelock provides exclusive advisory locks on files, shared by
+// vmterminal processes.
+package filelock
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// errLockHeld is returned by tryLock when another holder has the lock.
+var errLockHeld = errors.New("lock held")
+
+// Lock is an exclusive advisory lock on a file.
+type Lock struct {
+	f *os.File
+}
+
+// Acquire takes the lock on the file name in dir, creating both if needed
+// and retrying until timeout; what names the lock in errors. Callers must
+// Release it, typically with defer.
+func Acquire(dir, name, what string, timeout time.Duration) (*Lock, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("create directory: %w", err)
+	}
+	path := filepath.Join(dir, name)
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("open %s lock: %w", what, err)
+	}
+
+	deadline := time.Now().Add(timeout)
+	wait := time.Millisecond
+	for {
+		err := tryLock(f)
+		if err == nil {
+			return &Lock{f: f}, nil
+		}
+		if !errors.Is(err, errLockHeld) {
+			f.Close()
+			return nil, fmt.Errorf("lock %s: %w", what, err)
+		}
+		if time.Now().After(deadline) {
+			f.Close()
+			return nil, fmt.Errorf("%s is locked by another vmterminal process (waited %s)", what, timeout)
+		}
+		time.Sleep(wait)
+		if wait < 50*time.Millisecond {
+			wait *= 2
+		}
+	}
+}
+
+// Release drops the lock. Closing the file releases it even if unlock fails.
+func (l *Lock) Release() error {
+	err := unlock(l.f)
+	if cerr := l.f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
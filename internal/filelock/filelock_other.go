@@ -0,0 +1,16 @@
+//go:build !linux && !darwin
+
+package filelock
+
+import "os"
+
+// tryLock is a no-op on platforms without flock; vmterminal only runs VMs
+// on Linux and macOS.
+func tryLock(f *os.File) error {
+	return nil
+}
+
+// unlock is a no-op on platforms without flock.
+func unlock(f *os.File) error {
+	return nil
+}
@@ -0,0 +1,25 @@
+package gui
+
+import (
+	"io"
+	"strings"
+
+	"fyne.io/fyne/v2"
+)
+
+// pasteNewlines turns pasted line endings into carriage returns, the Enter
+// key a serial console expects, as other terminal emulators do on paste.
+var pasteNewlines = strings.NewReplacer("\r\n", "\r", "\n", "\r")
+
+// pasteHandler returns a shortcut handler that writes the clipboard's text
+// to vmIn. The write blocks until the guest reads it, so pasting a lot of
+// text stalls the window while the console pipe drains.
+func pasteHandler(clip fyne.Clipboard, vmIn io.Writer) func(fyne.Shortcut) {
+	return func(fyne.Shortcut) {
+		text := clip.Content()
+		if text == "" {
+			return
+		}
+		io.WriteString(vmIn, pasteNewlines.Replace(text))
+	}
+}
@@ -0,0 +1,23 @@
+package gui
+
+import (
+	"io"
+
+	"fyne.io/fyne/v2"
+	fyneterm "github.com/fyne-io/terminal"
+)
+
+// setupClipboardPaste makes Cmd+V paste the host clipboard (the general
+// NSPasteboard) into the VM, or do nothing if allow is false.
+func setupClipboardPaste(t *fyneterm.Terminal, clip fyne.Clipboard, vmIn io.Writer, allow bool) {
+	// The terminal registers its own shortcuts when its renderer is created,
+	// which Refresh forces, so ours replaces them rather than the reverse
+	t.Refresh()
+
+	paste := &fyne.ShortcutPaste{}
+	if !allow {
+		t.RemoveShortcut(paste)
+		return
+	}
+	t.AddShortcut(paste, pasteHandler(clip, vmIn))
+}
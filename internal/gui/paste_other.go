@@ -0,0 +1,26 @@
+//go:build !darwin
+
+package gui
+
+import (
+	"io"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/driver/desktop"
+	fyneterm "github.com/fyne-io/terminal"
+)
+
+// setupClipboardPaste makes Ctrl+Shift+V paste the host clipboard into the
+// VM, or do nothing if allow is false. Ctrl+V itself is sent to the guest.
+func setupClipboardPaste(t *fyneterm.Terminal, clip fyne.Clipboard, vmIn io.Writer, allow bool) {
+	// The terminal registers its own shortcuts when its renderer is created,
+	// which Refresh forces, so ours replaces them rather than the reverse
+	t.Refresh()
+
+	paste := &desktop.CustomShortcut{KeyName: fyne.KeyV, Modifier: fyne.KeyModifierShift | fyne.KeyModifierShortcutDefault}
+	if !allow {
+		t.RemoveShortcut(paste)
+		return
+	}
+	t.AddShortcut(paste, pasteHandler(clip, vmIn))
+}
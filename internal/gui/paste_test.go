@@ -0,0 +1,35 @@
+package gui
+
+import (
+	"bytes"
+	"testing"
+
+	"fyne.io/fyne/v2"
+)
+
+// fakeClipboard is an in-memory fyne.Clipboard.
+type fakeClipboard struct {
+	content string
+}
+
+func (c *fakeClipboard) Content() string           { return c.content }
+func (c *fakeClipboard) SetContent(content string) { c.content = content }
+
+func TestPasteHandler(t *testing.T) {
+	clip := &fakeClipboard{content: "echo héllo\nls -l\r\n"}
+	var vmIn bytes.Buffer
+	paste := pasteHandler(clip, &vmIn)
+
+	paste(&fyne.ShortcutPaste{})
+	if got, want := vmIn.String(), "echo héllo\rls -l\r"; got != want {
+		t.Errorf("pasted %q, want %q", got, want)
+	}
+
+	// An empty clipboard writes nothing
+	vmIn.Reset()
+	clip.SetContent("")
+	paste(&fyne.ShortcutPaste{})
+	if vmIn.Len() != 0 {
+		t.Errorf("empty clipboard pasted %q", vmIn.String())
+	}
+}
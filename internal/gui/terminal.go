@@ -0,0 +1,76 @@
+package gui
+
+import (
+	"time"
+
+	fyneterm "github.com/fyne-io/terminal"
+)
+
+// resizeDebounce coalesces bursts of resize events while a window is dragged.
+const resizeDebounce = 250 * time.Millisecond
+
+// winSize is a terminal grid size in character cells.
+type winSize struct {
+	Rows, Cols uint
+}
+
+// resizeFunc tells the guest the console grid size. The size travels in
+// the console device's config space, so nothing is typed into the guest.
+type resizeFunc func(rows, cols uint16) error
+
+// forwardResizes calls resize each time the grid size settles on a new
+// value, starting with the first size the terminal reports. It returns
+// when sizes is closed.
+func forwardResizes(sizes <-chan winSize, resize resizeFunc, debounce time.Duration) {
+	var (
+		last, pending winSize
+		timer         <-chan time.Time
+	)
+
+	for {
+		select {
+		case s, ok := <-sizes:
+			if !ok {
+				return
+			}
+			if s.Rows == 0 || s.Cols == 0 {
+				continue
+			}
+			pending = s
+			timer = time.After(debounce)
+		case <-timer:
+			timer = nil
+			if pending != last {
+				last = pending
+				resize(uint16(last.Rows), uint16(last.Cols))
+			}
+		}
+	}
+}
+
+// watchTerminalSize forwards grid size changes of t to resize until stop is
+// closed. A nil resize leaves the guest's console size alone.
+func watchTerminalSize(t *fyneterm.Terminal, resize resizeFunc, stop <-chan struct{}) {
+	if resize == nil {
+		return
+	}
+
+	configs := make(chan fyneterm.Config, 8)
+	t.AddListener(configs)
+
+	sizes := make(chan winSize)
+	go forwardResizes(sizes, resize, resizeDebounce)
+
+	go func() {
+		defer close(sizes)
+		for {
+			select {
+			case cfg := <-configs:
+				sizes <- winSize{Rows: cfg.Rows, Cols: cfg.Columns}
+			case <-stop:
+				t.RemoveListener(configs)
+				return
+			}
+		}
+	}()
+}
@@ -0,0 +1,68 @@
+package gui
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// resizeRecorder records the sizes passed to a resizeFunc.
+type resizeRecorder struct {
+	mu    sync.Mutex
+	sizes []winSize
+}
+
+func (r *resizeRecorder) resize(rows, cols uint16) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sizes = append(r.sizes, winSize{Rows: uint(rows), Cols: uint(cols)})
+	return nil
+}
+
+func (r *resizeRecorder) got() []winSize {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]winSize(nil), r.sizes...)
+}
+
+func TestForwardResizes(t *testing.T) {
+	sizes := make(chan winSize)
+	var rec resizeRecorder
+	done := make(chan struct{})
+	go func() {
+		forwardResizes(sizes, rec.resize, 10*time.Millisecond)
+		close(done)
+	}()
+
+	// The initial size is forwarded too, since it never touches console input
+	sizes <- winSize{Rows: 24, Cols: 80}
+	time.Sleep(30 * time.Millisecond)
+	if got, want := rec.got(), []winSize{{24, 80}}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("forwarded %v, want %v", got, want)
+	}
+
+	// A burst of resizes is coalesced into the final size
+	sizes <- winSize{Rows: 30, Cols: 100}
+	sizes <- winSize{Rows: 40, Cols: 120}
+	time.Sleep(50 * time.Millisecond)
+	want := []winSize{{24, 80}, {40, 120}}
+	if got := rec.got(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("forwarded %v, want %v", got, want)
+	}
+
+	// Repeating the current size sends nothing, and neither does an empty one
+	sizes <- winSize{Rows: 40, Cols: 120}
+	sizes <- winSize{}
+	time.Sleep(30 * time.Millisecond)
+	if got := rec.got(); !reflect.DeepEqual(got, want) {
+		t.Errorf("duplicate size was forwarded: %v", got)
+	}
+
+	close(sizes)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("forwardResizes did not return after sizes was closed")
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"io"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 
 	"fyne.io/fyne/v2"
@@ -19,20 +20,59 @@ type nopWriteCloser struct {
 
 func (nopWriteCloser) Close() error { return nil }
 
+var (
+	titleMu sync.Mutex
+	window  fyne.Window // The window RunTerminal opened, if open
+	title   string      // The title to give window when it opens
+)
+
+// UpdateWindowTitle sets the title of the terminal window. Before
+// RunTerminal opens the window it replaces the title passed to it. It may
+// be called from any goroutine.
+func UpdateWindowTitle(t string) {
+	titleMu.Lock()
+	defer titleMu.Unlock()
+	title = t
+	if window != nil {
+		w := window
+		fyne.Do(func() { w.SetTitle(t) })
+	}
+}
+
 // RunTerminal opens a GUI window with a terminal emulator connected to the VM.
 // vmIn is the writer to send input to the VM (keyboard -> VM).
 // vmOut is the reader to receive output from the VM (VM -> display).
-// title is the window title.
+// initialTitle is the window title until UpdateWindowTitle changes it.
 // onClose is called when the user closes the window or the VM connection ends.
+// allowPaste enables pasting the host clipboard (Cmd+V on macOS).
+// resize, if not nil, is called with the grid size as the window is resized;
+// with a nil resize the guest keeps its default console size.
 // This function blocks until the window is closed.
-func RunTerminal(vmIn io.Writer, vmOut io.Reader, title string, onClose func()) {
+func RunTerminal(vmIn io.Writer, vmOut io.Reader, initialTitle string, onClose func(), allowPaste bool, resize func(rows, cols uint16) error) {
 	a := app.New()
+	titleMu.Lock()
+	if title == "" {
+		title = initialTitle
+	}
 	w := a.NewWindow(title)
+	window = w
+	titleMu.Unlock()
+	defer func() {
+		titleMu.Lock()
+		window = nil
+		titleMu.Unlock()
+	}()
 	w.SetPadded(false)
 	w.Resize(fyne.NewSize(800, 600))
 
 	t := fyneterm.New()
 	w.SetContent(t)
+	setupClipboardPaste(t, a.Clipboard(), vmIn, allowPaste)
+
+	// Tell the guest about grid size changes as the window is resized
+	stopResize := make(chan struct{})
+	defer close(stopResize)
+	watchTerminalSize(t, resize, stopResize)
 
 	w.SetCloseIntercept(func() {
 		if onClose != nil {
@@ -0,0 +1,103 @@
+package integration
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/javanstorm/vmterminal/internal/vm"
+	"github.com/javanstorm/vmterminal/pkg/hypervisor"
+)
+
+// serveGuestShell plays the guest on driver's console once it boots: a
+// login prompt, then a shell that echoes what is typed, as a tty does, and
+// answers each command from replies. Commands without a reply fail with
+// status 127 except echo and true.
+func serveGuestShell(driver *hypervisor.MockDriver, replies map[string]string) {
+	go func() {
+		<-driver.Started()
+		in, out := driver.Guest()
+		io.WriteString(out, "\r\nWelcome to Alpine Linux\r\nlocalhost login: ")
+		lines := bufio.NewScanner(in)
+		loggedIn := false
+		for lines.Scan() {
+			line := lines.Text()
+			io.WriteString(out, line+"\r\n")
+			if !loggedIn {
+				loggedIn = true
+				io.WriteString(out, "localhost:~# ")
+				continue
+			}
+			status := "0"
+			for _, cmd := range strings.Split(line, "; ") {
+				switch {
+				case strings.HasPrefix(cmd, "echo "):
+					arg := strings.ReplaceAll(strings.TrimPrefix(cmd, "echo "), `""`, "")
+					io.WriteString(out, strings.ReplaceAll(arg, "$?", status)+"\r\n")
+				case cmd == "true":
+					status = "0"
+				default:
+					reply, ok := replies[cmd]
+					if !ok {
+						status = "127"
+						continue
+					}
+					status = "0"
+					io.WriteString(out, strings.ReplaceAll(reply, "\n", "\r\n"))
+				}
+			}
+			io.WriteString(out, "localhost:~# ")
+		}
+	}()
+}
+
+func TestBench(t *testing.T) {
+	dataDir := setupHome(t)
+	driver := useMockDriver(t)
+	serveGuestShell(driver, map[string]string{
+		vm.BenchMemoryCommand: "1024+0 records in\n1024+0 records out\n" +
+			"1073741824 bytes (1.0GB) copied, 0.250000 seconds, 4.0GB/s\n",
+		"command -v sysbench >/dev/null":    "",
+		vm.BenchCPUCommand(2 * time.Second): "CPU speed:\n    events per second:  1500.00\n",
+	})
+
+	done := make(chan error, 1)
+	go func() { done <- execute(t, "bench", "--duration", "2s", "--json") }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("bench: %v", err)
+		}
+	case <-time.After(30 * time.Second):
+		t.Fatal("bench did not finish")
+	}
+
+	if driver.Running() {
+		t.Error("VM still running after bench")
+	}
+	if _, err := os.Stat(filepath.Join(dataDir, "vm.pid")); !os.IsNotExist(err) {
+		t.Error("PID file left behind after bench")
+	}
+
+	history, err := vm.LoadBenchHistory(filepath.Join(filepath.Dir(filepath.Dir(dataDir)), vm.BenchHistoryFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 1 {
+		t.Fatalf("bench history holds %d results, want 1", len(history))
+	}
+	r := history[0]
+	if r.VM != "default" || r.Hypervisor != "mock" {
+		t.Errorf("recorded VM %q on %q, want default on mock", r.VM, r.Hypervisor)
+	}
+	if int(r.MemoryMBps) != 4294 {
+		t.Errorf("memory bandwidth = %f MB/s, want 4294.97", r.MemoryMBps)
+	}
+	if r.CPUEventsPerSec != 1500 {
+		t.Errorf("CPU score = %f, want 1500", r.CPUEventsPerSec)
+	}
+}
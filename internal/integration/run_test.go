@@ -0,0 +1,127 @@
+// Package integration runs vmterminal commands end to end against
+// hypervisor.MockDriver, so they need neither a hypervisor nor root.
+package integration
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/javanstorm/vmterminal/internal/cli"
+	"github.com/javanstorm/vmterminal/internal/distro"
+	"github.com/javanstorm/vmterminal/internal/vm"
+	"github.com/javanstorm/vmterminal/pkg/hypervisor"
+)
+
+// setupHome points HOME at a temporary directory holding an installed VM
+// named "default": cached boot assets for the default distro and a disk
+// marked as imported, so that run boots it without downloading or setting
+// anything up. It returns the VM's data directory.
+func setupHome(t *testing.T) string {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	baseDir := filepath.Join(home, ".vmterminal")
+
+	provider, err := distro.GetDefault()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cacheSubdir := filepath.Join(baseDir, "cache", provider.CacheSubdir(distro.CurrentArch()))
+	if err := os.MkdirAll(cacheSubdir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"vmlinuz", "initramfs", "rootfs.tar.gz"} {
+		if err := os.WriteFile(filepath.Join(cacheSubdir, name), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dataDir := filepath.Join(baseDir, "data", "default")
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dataDir, "disk.raw"), make([]byte, 4096), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dataDir, ".imported"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return dataDir
+}
+
+// useMockDriver makes every driver the commands create the returned one.
+func useMockDriver(t *testing.T) *hypervisor.MockDriver {
+	t.Helper()
+	driver := hypervisor.NewMockDriver()
+	hypervisor.SetDriverFactory(func() (hypervisor.Driver, error) { return driver, nil })
+	t.Cleanup(func() { hypervisor.SetDriverFactory(nil) })
+	return driver
+}
+
+// execute runs the vmterminal command line args.
+func execute(t *testing.T, args ...string) error {
+	t.Helper()
+	orig := os.Args
+	os.Args = append([]string{"vmterminal"}, args...)
+	defer func() { os.Args = orig }()
+	return cli.Execute()
+}
+
+func TestRunHeadless(t *testing.T) {
+	dataDir := setupHome(t)
+	driver := useMockDriver(t)
+
+	// There is no SSH server to wait for, so power the guest off once it
+	// has booted, as 'poweroff' inside it would
+	go func() {
+		select {
+		case <-driver.Started():
+			time.Sleep(100 * time.Millisecond)
+			driver.Exit(nil)
+		case <-time.After(30 * time.Second):
+		}
+	}()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- execute(t, "run", "--headless", "--distro", string(distro.DefaultID()))
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("run: %v", err)
+		}
+	case <-time.After(30 * time.Second):
+		t.Fatal("run did not return after the guest powered off")
+	}
+
+	if driver.Boots() != 1 {
+		t.Errorf("guest booted %d times, want 1", driver.Boots())
+	}
+	cfg := driver.Config()
+	if cfg == nil {
+		t.Fatal("VM was never created")
+	}
+	if filepath.Base(cfg.Kernel) != "vmlinuz" {
+		t.Errorf("booted kernel %s, want the cached vmlinuz", cfg.Kernel)
+	}
+	if cfg.DiskPath != filepath.Join(dataDir, "disk.raw") {
+		t.Errorf("booted disk %s, want %s", cfg.DiskPath, filepath.Join(dataDir, "disk.raw"))
+	}
+
+	state, err := vm.NewStateFile(dataDir).Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.BootCount == 0 {
+		t.Error("boot was not recorded in the state file")
+	}
+	if state.LastBoot.IsZero() || state.LastShutdown.IsZero() {
+		t.Errorf("boot and shutdown times not recorded: %+v", state)
+	}
+	if _, err := os.Stat(filepath.Join(dataDir, "vm.pid")); !os.IsNotExist(err) {
+		t.Error("PID file left behind after the VM stopped")
+	}
+}
@@ -0,0 +1,138 @@
+// Package metrics exposes VM resource usage in the Prometheus text format.
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Source supplies the current values for a scrape.
+type Source interface {
+	// State returns the current VM state name.
+	State() string
+
+	// BootCount returns the number of times the VM has booted.
+	BootCount() (int, error)
+
+	// SnapshotCount returns the number of stored snapshots.
+	SnapshotCount() (int, error)
+
+	// DiskPath returns the path of the VM's disk image.
+	DiskPath() string
+
+	// Uptime returns how long the VM has been running.
+	Uptime() time.Duration
+}
+
+// Collector renders metrics from a Source.
+type Collector struct {
+	src    Source
+	states []string
+}
+
+// NewCollector creates a collector. states lists every possible state name;
+// each is exported as a vmterminal_vm_state series so the current one is 1.
+func NewCollector(src Source, states []string) *Collector {
+	return &Collector{src: src, states: states}
+}
+
+// Render writes all metrics to w in the Prometheus text exposition format.
+// Values whose source fails are omitted rather than failing the scrape.
+func (c *Collector) Render(w io.Writer) error {
+	ew := &errWriter{w: w}
+
+	if n, err := c.src.BootCount(); err == nil {
+		ew.metric("vmterminal_boot_count_total", "counter", "Number of times the VM has booted.")
+		ew.printf("vmterminal_boot_count_total %d\n", n)
+	}
+
+	ew.metric("vmterminal_vm_state", "gauge", "Current VM state (1 for the active state).")
+	current := c.src.State()
+	for _, state := range c.states {
+		v := 0
+		if state == current {
+			v = 1
+		}
+		ew.printf("vmterminal_vm_state{state=%q} %d\n", state, v)
+	}
+
+	if n, err := c.src.SnapshotCount(); err == nil {
+		ew.metric("vmterminal_snapshot_count", "gauge", "Number of stored snapshots.")
+		ew.printf("vmterminal_snapshot_count %d\n", n)
+	}
+
+	if used, err := diskBytesUsed(c.src.DiskPath()); err == nil {
+		ew.metric("vmterminal_disk_bytes_used", "gauge", "Bytes allocated on the host for the VM disk image.")
+		ew.printf("vmterminal_disk_bytes_used %d\n", used)
+	}
+
+	ew.metric("vmterminal_uptime_seconds", "gauge", "Seconds since the VM started.")
+	ew.printf("vmterminal_uptime_seconds %g\n", c.src.Uptime().Seconds())
+
+	return ew.err
+}
+
+// ServeHTTP serves the metrics page.
+func (c *Collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	c.Render(w)
+}
+
+// Server serves /metrics until stopped.
+type Server struct {
+	srv *http.Server
+}
+
+// NewServer creates a metrics server listening on addr (e.g. ":9090").
+func NewServer(addr string, c *Collector) *Server {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", c)
+	return &Server{srv: &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 5 * time.Second}}
+}
+
+// Start binds the server's address and begins serving in a goroutine. An
+// address that cannot be bound is returned; errors after that are sent to
+// errFn, if non-nil.
+func (s *Server) Start(errFn func(error)) error {
+	ln, err := net.Listen("tcp", s.srv.Addr)
+	if err != nil {
+		return fmt.Errorf("listen on %s: %w", s.srv.Addr, err)
+	}
+	go func() {
+		if err := s.srv.Serve(ln); err != nil && err != http.ErrServerClosed && errFn != nil {
+			errFn(err)
+		}
+	}()
+	return nil
+}
+
+// Shutdown stops the server, waiting up to timeout for in-flight scrapes.
+func (s *Server) Shutdown(timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return s.srv.Shutdown(ctx)
+}
+
+// errWriter remembers the first write error so Render can stay linear.
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (e *errWriter) printf(format string, args ...interface{}) {
+	if e.err == nil {
+		_, e.err = fmt.Fprintf(e.w, format, args...)
+	}
+}
+
+func (e *errWriter) metric(name, typ, help string) {
+	e.printf("# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
@@ -0,0 +1,109 @@
+package metrics
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeSource struct {
+	state     string
+	boots     int
+	snapshots int
+	snapErr   error
+	disk      string
+	uptime    time.Duration
+}
+
+func (f *fakeSource) State() string               { return f.state }
+func (f *fakeSource) BootCount() (int, error)     { return f.boots, nil }
+func (f *fakeSource) SnapshotCount() (int, error) { return f.snapshots, f.snapErr }
+func (f *fakeSource) DiskPath() string            { return f.disk }
+func (f *fakeSource) Uptime() time.Duration       { return f.uptime }
+
+func TestCollectorRender(t *testing.T) {
+	disk := filepath.Join(t.TempDir(), "disk.raw")
+	if err := os.WriteFile(disk, make([]byte, 8192), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	src := &fakeSource{state: "running", boots: 3, snapshots: 2, disk: disk, uptime: 90 * time.Second}
+	c := NewCollector(src, []string{"running", "stopped"})
+
+	var sb strings.Builder
+	if err := c.Render(&sb); err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	out := sb.String()
+
+	for _, want := range []string{
+		"# TYPE vmterminal_boot_count_total counter",
+		"vmterminal_boot_count_total 3\n",
+		`vmterminal_vm_state{state="running"} 1`,
+		`vmterminal_vm_state{state="stopped"} 0`,
+		"vmterminal_snapshot_count 2\n",
+		"vmterminal_disk_bytes_used ",
+		"vmterminal_uptime_seconds 90\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestCollectorSkipsFailingValues(t *testing.T) {
+	src := &fakeSource{state: "running", snapErr: errors.New("boom"), disk: "/nonexistent/disk.raw"}
+	c := NewCollector(src, []string{"running"})
+
+	var sb strings.Builder
+	if err := c.Render(&sb); err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	out := sb.String()
+
+	if strings.Contains(out, "vmterminal_snapshot_count") {
+		t.Error("snapshot count should be omitted when it cannot be read")
+	}
+	if strings.Contains(out, "vmterminal_disk_bytes_used") {
+		t.Error("disk usage should be omitted when the disk is missing")
+	}
+}
+
+func TestCollectorServeHTTP(t *testing.T) {
+	c := NewCollector(&fakeSource{state: "running"}, []string{"running"})
+
+	rec := httptest.NewRecorder()
+	c.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+
+	rec = httptest.NewRecorder()
+	c.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/metrics", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST status = %d, want 405", rec.Code)
+	}
+}
+
+func TestServerStartAddressInUse(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	srv := NewServer(ln.Addr().String(), NewCollector(&fakeSource{}, nil))
+	if err := srv.Start(nil); err == nil {
+		srv.Shutdown(time.Second)
+		t.Fatal("Start on a bound address succeeded")
+	}
+}
@@ -0,0 +1,14 @@
+//go:build !darwin && !linux
+
+package metrics
+
+import "os"
+
+// diskBytesUsed returns the size of path.
+func diskBytesUsed(path string) (int64, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
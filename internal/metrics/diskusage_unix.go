@@ -0,0 +1,21 @@
+//go:build darwin || linux
+
+package metrics
+
+import (
+	"os"
+	"syscall"
+)
+
+// diskBytesUsed returns the bytes actually allocated for path, which is
+// smaller than its size for sparse disk images.
+func diskBytesUsed(path string) (int64, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return st.Blocks * 512, nil
+	}
+	return info.Size(), nil
+}
@@ -0,0 +1,158 @@
+// Package progress reports the progress of long-running operations such as
+// downloads, snapshots, and rootfs extraction.
+package progress
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// UpdateInterval is the least time between two update events of the JSON
+// reporter. Updates arrive on every read of a download, far more often
+// than anyone needs to see them.
+var UpdateInterval = 250 * time.Millisecond
+
+// Progress receives events for one operation at a time.
+// Start begins a new operation; total is its size in bytes, or 0 if unknown.
+// Message reports a status line that belongs to no single operation.
+type Progress interface {
+	Start(name string, total int64)
+	Update(done int64)
+	Complete()
+	Error(err error)
+	Message(msg string)
+}
+
+// TextProgress prints a line for each operation it starts.
+type TextProgress struct {
+	w io.Writer
+}
+
+// NewTextProgress creates a text reporter writing to w.
+func NewTextProgress(w io.Writer) *TextProgress {
+	return &TextProgress{w: w}
+}
+
+// Start prints the operation name.
+func (p *TextProgress) Start(name string, total int64) {
+	fmt.Fprintf(p.w, "%s...\n", name)
+}
+
+// Update is a no-op; text output only reports operation starts.
+func (p *TextProgress) Update(done int64) {}
+
+// Complete is a no-op.
+func (p *TextProgress) Complete() {}
+
+// Error is a no-op; the error is returned to and reported by the caller.
+func (p *TextProgress) Error(err error) {}
+
+// Message prints msg on a line of its own.
+func (p *TextProgress) Message(msg string) {
+	fmt.Fprintln(p.w, msg)
+}
+
+// Event is a single JSON progress record.
+type Event struct {
+	Event string `json:"event"`
+	Name  string `json:"name"`
+	Done  int64  `json:"done"`
+	Total int64  `json:"total"`
+	Error string `json:"error,omitempty"`
+}
+
+// JSONProgress writes one JSON event per line. Update events are written
+// at most once per UpdateInterval, and always when the total is reached.
+type JSONProgress struct {
+	mu         sync.Mutex
+	enc        *json.Encoder
+	name       string
+	done       int64
+	total      int64
+	lastUpdate time.Time
+}
+
+// NewJSONProgress creates a JSON reporter writing to w.
+func NewJSONProgress(w io.Writer) *JSONProgress {
+	return &JSONProgress{enc: json.NewEncoder(w)}
+}
+
+// Start emits a start event.
+func (p *JSONProgress) Start(name string, total int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.name, p.done, p.total = name, 0, total
+	p.lastUpdate = time.Time{}
+	p.emit("start", "")
+}
+
+// Update emits an update event with the bytes done so far, unless one was
+// emitted less than UpdateInterval ago.
+func (p *JSONProgress) Update(done int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.done = done
+	if now := time.Now(); now.Sub(p.lastUpdate) >= UpdateInterval || (p.total > 0 && done >= p.total) {
+		p.lastUpdate = now
+		p.emit("update", "")
+	}
+}
+
+// Complete emits a complete event.
+func (p *JSONProgress) Complete() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.emit("complete", "")
+}
+
+// Error emits an error event.
+func (p *JSONProgress) Error(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.emit("error", err.Error())
+}
+
+// Message emits a message event named msg.
+func (p *JSONProgress) Message(msg string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.enc.Encode(Event{Event: "message", Name: msg})
+}
+
+func (p *JSONProgress) emit(event, errMsg string) {
+	p.enc.Encode(Event{Event: event, Name: p.name, Done: p.done, Total: p.total, Error: errMsg})
+}
+
+// SilentProgress discards all events.
+type SilentProgress struct{}
+
+func (SilentProgress) Start(name string, total int64) {}
+func (SilentProgress) Update(done int64)              {}
+func (SilentProgress) Complete()                      {}
+func (SilentProgress) Error(err error)                {}
+func (SilentProgress) Message(msg string)             {}
+
+// Reader wraps r and reports the running byte count to p on every read.
+type Reader struct {
+	r    io.Reader
+	p    Progress
+	done int64
+}
+
+// NewReader returns a Reader reporting reads from r to p.
+func NewReader(r io.Reader, p Progress) *Reader {
+	return &Reader{r: r, p: p}
+}
+
+// Read reads from the underlying reader and reports progress.
+func (r *Reader) Read(b []byte) (int, error) {
+	n, err := r.r.Read(b)
+	if n > 0 {
+		r.done += int64(n)
+		r.p.Update(r.done)
+	}
+	return n, err
+}
@@ -0,0 +1,116 @@
+package progress
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestJSONProgress(t *testing.T) {
+	var buf bytes.Buffer
+	p := NewJSONProgress(&buf)
+
+	p.Start("download", 100)
+	p.Update(40)
+	p.Complete()
+	p.Error(errors.New("boom"))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("got %d lines, want 4:\n%s", len(lines), buf.String())
+	}
+
+	want := []Event{
+		{Event: "start", Name: "download", Total: 100},
+		{Event: "update", Name: "download", Done: 40, Total: 100},
+		{Event: "complete", Name: "download", Done: 40, Total: 100},
+		{Event: "error", Name: "download", Done: 40, Total: 100, Error: "boom"},
+	}
+	for i, line := range lines {
+		var got Event
+		if err := json.Unmarshal([]byte(line), &got); err != nil {
+			t.Fatalf("line %d: %v", i, err)
+		}
+		if got != want[i] {
+			t.Errorf("line %d = %+v, want %+v", i, got, want[i])
+		}
+	}
+}
+
+func TestTextProgress(t *testing.T) {
+	var buf bytes.Buffer
+	p := NewTextProgress(&buf)
+
+	p.Start("Extracting rootfs", 0)
+	p.Update(10)
+	p.Complete()
+
+	if got := buf.String(); got != "Extracting rootfs...\n" {
+		t.Errorf("output = %q", got)
+	}
+}
+
+func TestReader(t *testing.T) {
+	var buf bytes.Buffer
+	p := NewJSONProgress(&buf)
+	p.Start("copy", 11)
+
+	if _, err := io.Copy(io.Discard, NewReader(strings.NewReader("hello world"), p)); err != nil {
+		t.Fatalf("Copy: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	var last Event
+	if err := json.Unmarshal([]byte(lines[len(lines)-1]), &last); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if last.Done != 11 {
+		t.Errorf("last done = %d, want 11", last.Done)
+	}
+}
+
+func TestJSONProgressThrottle(t *testing.T) {
+	var buf bytes.Buffer
+	p := NewJSONProgress(&buf)
+
+	p.Start("download", 1000)
+	for done := int64(1); done <= 1000; done++ {
+		p.Update(done)
+	}
+
+	var updates []Event
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var ev Event
+		if err := json.Unmarshal([]byte(line), &ev); err != nil {
+			t.Fatalf("Unmarshal: %v", err)
+		}
+		if ev.Event == "update" {
+			updates = append(updates, ev)
+		}
+	}
+	// The first update and the one reaching the total
+	if len(updates) != 2 || updates[0].Done != 1 || updates[1].Done != 1000 {
+		t.Errorf("updates = %+v, want done 1 and 1000", updates)
+	}
+}
+
+func TestProgressMessage(t *testing.T) {
+	var text bytes.Buffer
+	NewTextProgress(&text).Message("Disk setup complete.")
+	if got := text.String(); got != "Disk setup complete.\n" {
+		t.Errorf("text output = %q", got)
+	}
+
+	var buf bytes.Buffer
+	NewJSONProgress(&buf).Message("Disk setup complete.")
+	var got Event
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if want := (Event{Event: "message", Name: "Disk setup complete."}); got != want {
+		t.Errorf("event = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,88 @@
+package terminal
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// DetachKey is the byte the host terminal sends for Ctrl+], which
+// detaches from the console as in telnet. It is not passed to the VM.
+const DetachKey = 0x1d
+
+// ErrDetached is returned by AttachConsole when DetachKey was pressed.
+var ErrDetached = errors.New("detached from console")
+
+// Overridden in tests.
+var (
+	setRawMode = SetRawMode
+	isTerminal = IsTerminal
+)
+
+// AttachConsole connects the host terminal to a VM console until either
+// direction ends, returning the first copy error (nil on EOF) or
+// ErrDetached if DetachKey was pressed. When in is a terminal it is
+// switched to raw mode and restored before returning, including when
+// SIGINT or SIGTERM arrives.
+func AttachConsole(in *os.File, out io.Writer, vmIn io.Writer, vmOut io.Reader) error {
+	fd := int(in.Fd())
+	if isTerminal(fd) {
+		restore, err := setRawMode(fd)
+		if err != nil {
+			return err
+		}
+		defer restore()
+	}
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigCh)
+
+	errCh := make(chan error, 2)
+	go func() {
+		errCh <- copyInput(vmIn, in)
+	}()
+	go func() {
+		_, err := io.Copy(out, vmOut)
+		errCh <- err
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-sigCh:
+		return nil
+	}
+}
+
+// copyInput copies keystrokes from src to the VM until EOF or DetachKey.
+func copyInput(vmIn io.Writer, src io.Reader) error {
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := src.Read(buf)
+		if n > 0 {
+			chunk := buf[:n]
+			detach := bytes.IndexByte(chunk, DetachKey)
+			if detach >= 0 {
+				chunk = chunk[:detach]
+			}
+			if len(chunk) > 0 {
+				if _, err := vmIn.Write(chunk); err != nil {
+					return err
+				}
+			}
+			if detach >= 0 {
+				return ErrDetached
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package terminal
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"testing"
+)
+
+// failingReader returns err on the first read.
+type failingReader struct{ err error }
+
+func (r failingReader) Read(p []byte) (int, error) { return 0, r.err }
+
+// fakeRawMode makes AttachConsole treat any fd as a terminal and records
+// whether the mode was restored.
+func fakeRawMode(t *testing.T) *bool {
+	t.Helper()
+	restored := new(bool)
+	origSet, origIs := setRawMode, isTerminal
+	t.Cleanup(func() { setRawMode, isTerminal = origSet, origIs })
+	isTerminal = func(int) bool { return true }
+	setRawMode = func(int) (func(), error) {
+		return func() { *restored = true }, nil
+	}
+	return restored
+}
+
+func TestAttachConsoleRestoresOnCopyError(t *testing.T) {
+	restored := fakeRawMode(t)
+
+	in, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Pipe: %v", err)
+	}
+	defer in.Close()
+	defer w.Close()
+
+	copyErr := errors.New("console closed")
+	err = AttachConsole(in, io.Discard, io.Discard, failingReader{copyErr})
+	if !errors.Is(err, copyErr) {
+		t.Errorf("AttachConsole error = %v, want %v", err, copyErr)
+	}
+	if !*restored {
+		t.Error("terminal mode was not restored after a copy error")
+	}
+}
+
+func TestAttachConsoleCopiesOutput(t *testing.T) {
+	restored := fakeRawMode(t)
+
+	in, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Pipe: %v", err)
+	}
+	defer in.Close()
+	defer w.Close()
+
+	var out bytes.Buffer
+	if err := AttachConsole(in, &out, io.Discard, bytes.NewReader([]byte("login: "))); err != nil {
+		t.Fatalf("AttachConsole: %v", err)
+	}
+	if out.String() != "login: " {
+		t.Errorf("output = %q, want %q", out.String(), "login: ")
+	}
+	if !*restored {
+		t.Error("terminal mode was not restored")
+	}
+}
+
+func TestAttachConsoleDetachKey(t *testing.T) {
+	restored := fakeRawMode(t)
+
+	in, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Pipe: %v", err)
+	}
+	defer in.Close()
+	defer w.Close()
+
+	// The console stays open, so only the detach key ends the attach
+	vmOut, vmOutW := io.Pipe()
+	defer vmOutW.Close()
+
+	w.Write([]byte("ls\x1dpwd\n"))
+	var vmIn bytes.Buffer
+	if err := AttachConsole(in, io.Discard, &vmIn, vmOut); !errors.Is(err, ErrDetached) {
+		t.Errorf("AttachConsole error = %v, want ErrDetached", err)
+	}
+	if vmIn.String() != "ls" {
+		t.Errorf("VM input = %q, want %q", vmIn.String(), "ls")
+	}
+	if !*restored {
+		t.Error("terminal mode was not restored after detaching")
+	}
+}
+
+func TestSetRawModeNotATerminal(t *testing.T) {
+	f, err := os.CreateTemp(t.TempDir(), "notatty")
+	if err != nil {
+		t.Fatalf("CreateTemp: %v", err)
+	}
+	defer f.Close()
+
+	if IsTerminal(int(f.Fd())) {
+		t.Error("a regular file should not be a terminal")
+	}
+	if _, err := SetRawMode(int(f.Fd())); err == nil {
+		t.Error("SetRawMode on a regular file should fail")
+	}
+}
@@ -0,0 +1,144 @@
+// Package terminal provides helpers for the VM console stream.
+package terminal
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// RotatingLogWriter appends to a log file and rotates it once it grows past
+// a size limit. The previous file is kept as <path>.1; older data is dropped.
+type RotatingLogWriter struct {
+	mu      sync.Mutex
+	path    string
+	maxSize int64
+	f       *os.File
+	size    int64
+}
+
+// NewRotatingLogWriter opens path for append. A maxSize of 0 or less
+// disables rotation.
+func NewRotatingLogWriter(path string, maxSize int64) (*RotatingLogWriter, error) {
+	w := &RotatingLogWriter{path: path, maxSize: maxSize}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *RotatingLogWriter) open() error {
+	f, err := os.OpenFile(w.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("open console log: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("stat console log: %w", err)
+	}
+	w.f = f
+	w.size = info.Size()
+	return nil
+}
+
+// rotate moves the current file to <path>.1 and starts a new one.
+func (w *RotatingLogWriter) rotate() error {
+	if err := w.f.Close(); err != nil {
+		return fmt.Errorf("close console log: %w", err)
+	}
+	if err := os.Rename(w.path, w.path+".1"); err != nil {
+		return fmt.Errorf("rotate console log: %w", err)
+	}
+	return w.open()
+}
+
+// Write appends p, rotating first if p would push the file past maxSize.
+func (w *RotatingLogWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.maxSize > 0 && w.size > 0 && w.size+int64(len(p)) > w.maxSize {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := w.f.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// Close closes the log file.
+func (w *RotatingLogWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.f.Close()
+}
+
+// LogTimeFormat is the timestamp prefixed to each console log line.
+const LogTimeFormat = "2006-01-02T15:04:05.000Z07:00"
+
+// TimestampWriter prefixes every line written through it with the time the
+// line started, so console logs can be filtered by time later.
+type TimestampWriter struct {
+	mu        sync.Mutex
+	w         io.Writer
+	now       func() time.Time
+	lineStart bool
+}
+
+// NewTimestampWriter wraps w, stamping each line with the current UTC time.
+func NewTimestampWriter(w io.Writer) *TimestampWriter {
+	return &TimestampWriter{w: w, now: time.Now, lineStart: true}
+}
+
+// Write copies p to the underlying writer, inserting a timestamp at the
+// start of each line. It reports len(p) on success.
+func (t *TimestampWriter) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	n := len(p)
+	var buf []byte
+	for len(p) > 0 {
+		if t.lineStart {
+			buf = t.now().UTC().AppendFormat(buf, LogTimeFormat)
+			buf = append(buf, ' ')
+			t.lineStart = false
+		}
+		i := bytes.IndexByte(p, '\n')
+		if i < 0 {
+			buf = append(buf, p...)
+			break
+		}
+		buf = append(buf, p[:i+1]...)
+		p = p[i+1:]
+		t.lineStart = true
+	}
+	if _, err := t.w.Write(buf); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// ParseLineTime returns the timestamp a TimestampWriter put at the start of
+// line. ok is false if the line has no timestamp.
+func ParseLineTime(line []byte) (ts time.Time, ok bool) {
+	n := len(LogTimeFormat) + 1 // longest stamp plus the separating space
+	if len(line) < n {
+		n = len(line)
+	}
+	i := bytes.IndexByte(line[:n], ' ')
+	if i < 0 {
+		return time.Time{}, false
+	}
+	ts, err := time.Parse(LogTimeFormat, string(line[:i]))
+	if err != nil {
+		return time.Time{}, false
+	}
+	return ts, true
+}
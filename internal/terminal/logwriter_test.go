@@ -0,0 +1,115 @@
+package terminal
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile(%s): %v", path, err)
+	}
+	return string(data)
+}
+
+func TestRotatingLogWriterRotatesAtBoundary(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "console.log")
+
+	w, err := NewRotatingLogWriter(path, 10)
+	if err != nil {
+		t.Fatalf("NewRotatingLogWriter: %v", err)
+	}
+	defer w.Close()
+
+	// Exactly filling the limit must not rotate
+	if _, err := w.Write([]byte("0123456789")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if _, err := os.Stat(path + ".1"); !os.IsNotExist(err) {
+		t.Fatalf("rotated before exceeding limit")
+	}
+
+	// One more byte crosses the limit
+	if _, err := w.Write([]byte("a")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if got := readFile(t, path+".1"); got != "0123456789" {
+		t.Errorf("rotated file = %q, want %q", got, "0123456789")
+	}
+	if got := readFile(t, path); got != "a" {
+		t.Errorf("current file = %q, want %q", got, "a")
+	}
+}
+
+func TestRotatingLogWriterAppends(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "console.log")
+	if err := os.WriteFile(path, []byte("old\n"), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	w, err := NewRotatingLogWriter(path, 8)
+	if err != nil {
+		t.Fatalf("NewRotatingLogWriter: %v", err)
+	}
+	if _, err := w.Write([]byte("new\n")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	// The existing size counts toward the limit
+	if _, err := w.Write([]byte("x")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	w.Close()
+
+	if got := readFile(t, path+".1"); got != "old\nnew\n" {
+		t.Errorf("rotated file = %q", got)
+	}
+	if got := readFile(t, path); got != "x" {
+		t.Errorf("current file = %q", got)
+	}
+}
+
+func TestRotatingLogWriterNoLimit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "console.log")
+
+	w, err := NewRotatingLogWriter(path, 0)
+	if err != nil {
+		t.Fatalf("NewRotatingLogWriter: %v", err)
+	}
+	for i := 0; i < 100; i++ {
+		w.Write([]byte("line\n"))
+	}
+	w.Close()
+
+	if _, err := os.Stat(path + ".1"); !os.IsNotExist(err) {
+		t.Error("should never rotate without a limit")
+	}
+}
+
+func TestTimestampWriterStampsEachLine(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewTimestampWriter(&buf)
+	now := time.Date(2026, 1, 2, 3, 4, 5, 6e6, time.UTC)
+	w.now = func() time.Time { return now }
+
+	// A line split across writes is stamped once
+	w.Write([]byte("boot"))
+	w.Write([]byte("ing\nlogin: "))
+
+	want := "2026-01-02T03:04:05.006Z booting\n2026-01-02T03:04:05.006Z login: "
+	if buf.String() != want {
+		t.Errorf("output = %q, want %q", buf.String(), want)
+	}
+
+	ts, ok := ParseLineTime([]byte("2026-01-02T03:04:05.006Z booting\n"))
+	if !ok || !ts.Equal(now) {
+		t.Errorf("ParseLineTime = %v, %v, want %v", ts, ok, now)
+	}
+	if _, ok := ParseLineTime([]byte("[    0.000000] Linux version 6.6\n")); ok {
+		t.Error("ParseLineTime accepted an unstamped line")
+	}
+}
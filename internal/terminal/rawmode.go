@@ -0,0 +1,29 @@
+package terminal
+
+import (
+	"fmt"
+	"sync"
+
+	"golang.org/x/term"
+)
+
+// SetRawMode puts the terminal on fd into raw mode, so keystrokes such as
+// Ctrl+C and arrow keys reach the VM unprocessed and full-screen programs
+// like vim work. The returned restore puts the previous mode back; it is
+// safe to call more than once.
+func SetRawMode(fd int) (restore func(), err error) {
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return nil, fmt.Errorf("set raw mode: %w", err)
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { term.Restore(fd, state) })
+	}, nil
+}
+
+// IsTerminal reports whether fd refers to a terminal.
+func IsTerminal(fd int) bool {
+	return term.IsTerminal(fd)
+}
@@ -0,0 +1,58 @@
+package terminal
+
+import (
+	"bytes"
+	"sync"
+)
+
+// kernelPanicMarker is printed by the Linux kernel when it panics.
+var kernelPanicMarker = []byte("Kernel panic")
+
+// TailBuffer is a writer that keeps only the last N bytes written to it,
+// so recent console output can be inspected after the VM exits.
+type TailBuffer struct {
+	mu   sync.Mutex
+	buf  []byte
+	size int
+}
+
+// NewTailBuffer returns a TailBuffer holding at most size bytes.
+func NewTailBuffer(size int) *TailBuffer {
+	return &TailBuffer{size: size}
+}
+
+// Write appends p, discarding the oldest bytes beyond the buffer size.
+func (t *TailBuffer) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	n := len(p)
+	if len(p) > t.size {
+		p = p[len(p)-t.size:]
+	}
+	if over := len(t.buf) + len(p) - t.size; over > 0 {
+		t.buf = append(t.buf[:0], t.buf[over:]...)
+	}
+	t.buf = append(t.buf, p...)
+	return n, nil
+}
+
+// Bytes returns a copy of the buffered tail.
+func (t *TailBuffer) Bytes() []byte {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]byte(nil), t.buf...)
+}
+
+// Reset discards the buffered output.
+func (t *TailBuffer) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.buf = t.buf[:0]
+}
+
+// ContainsKernelPanic reports whether console output shows a kernel panic,
+// as opposed to a clean poweroff or reboot.
+func ContainsKernelPanic(output []byte) bool {
+	return bytes.Contains(output, kernelPanicMarker)
+}
@@ -0,0 +1,48 @@
+package terminal
+
+import "testing"
+
+func TestTailBufferKeepsLastBytes(t *testing.T) {
+	tb := NewTailBuffer(8)
+
+	tb.Write([]byte("hello "))
+	tb.Write([]byte("world"))
+	if got := string(tb.Bytes()); got != "lo world" {
+		t.Errorf("Bytes() = %q, want %q", got, "lo world")
+	}
+
+	// A single write larger than the buffer keeps its own tail
+	n, err := tb.Write([]byte("0123456789abcdef"))
+	if err != nil || n != 16 {
+		t.Fatalf("Write = %d, %v; want 16, nil", n, err)
+	}
+	if got := string(tb.Bytes()); got != "89abcdef" {
+		t.Errorf("Bytes() = %q, want %q", got, "89abcdef")
+	}
+
+	tb.Reset()
+	if got := tb.Bytes(); len(got) != 0 {
+		t.Errorf("Bytes() after Reset = %q, want empty", got)
+	}
+}
+
+func TestContainsKernelPanic(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   bool
+	}{
+		{"panic", "[    3.1] Kernel panic - not syncing: VFS: Unable to mount root fs\n", true},
+		{"poweroff", "[   12.0] reboot: Power down\n", false},
+		{"reboot", "[   12.0] reboot: Restarting system\n", false},
+		{"empty", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ContainsKernelPanic([]byte(tt.output)); got != tt.want {
+				t.Errorf("ContainsKernelPanic(%q) = %v, want %v", tt.output, got, tt.want)
+			}
+		})
+	}
+}
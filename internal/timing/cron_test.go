@@ -0,0 +1,82 @@
+package timing
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCronScheduleNext(t *testing.T) {
+	// Monday 2024-01-15 10:30
+	from := time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2024, 1, 15, 10, 31, 0, 0, time.UTC)},
+		{"0 9 * * *", time.Date(2024, 1, 16, 9, 0, 0, 0, time.UTC)},
+		{"30 10 * * *", time.Date(2024, 1, 16, 10, 30, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2024, 1, 15, 10, 45, 0, 0, time.UTC)},
+		{"0 9-17/4 * * *", time.Date(2024, 1, 15, 13, 0, 0, 0, time.UTC)},
+		{"0 9 * * 1-5", time.Date(2024, 1, 16, 9, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2024, 1, 21, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 12 1,15 3 *", time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)},
+		// Both day fields restricted: either one matches
+		{"0 0 20 * 3", time.Date(2024, 1, 17, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		sched, err := ParseCronSchedule(tt.expr)
+		if err != nil {
+			t.Fatalf("ParseCronSchedule(%q): %v", tt.expr, err)
+		}
+		if got := sched.Next(from); !got.Equal(tt.want) {
+			t.Errorf("Next(%q) = %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestCronScheduleNeverFires(t *testing.T) {
+	sched, err := ParseCronSchedule("0 0 30 2 *")
+	if err != nil {
+		t.Fatalf("ParseCronSchedule: %v", err)
+	}
+	if got := sched.Next(time.Now()); !got.IsZero() {
+		t.Errorf("Next = %v, want zero time", got)
+	}
+	if _, err := ParseCron("0 0 30 2 *"); err == nil {
+		t.Error("ParseCron should reject an expression that never fires")
+	}
+}
+
+func TestParseCronInvalid(t *testing.T) {
+	tests := map[string]string{
+		"0 9 * *":     "want 5 fields",
+		"60 * * * *":  "out of range",
+		"* 24 * * *":  "out of range",
+		"* * 0 * *":   "out of range",
+		"* * * 13 *":  "out of range",
+		"* * * * 8":   "out of range",
+		"*/0 * * * *": "invalid step",
+		"5-1 * * * *": "backwards",
+		"a * * * *":   "invalid value",
+	}
+	for expr, want := range tests {
+		_, err := ParseCron(expr)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("ParseCron(%q) error = %v, want %q", expr, err, want)
+		}
+	}
+}
+
+func TestParseCron(t *testing.T) {
+	d, err := ParseCron("* * * * *")
+	if err != nil {
+		t.Fatalf("ParseCron: %v", err)
+	}
+	if d <= 0 || d > time.Minute {
+		t.Errorf("ParseCron = %v, want within the next minute", d)
+	}
+}
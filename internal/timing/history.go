@@ -0,0 +1,261 @@
+package timing
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// HistoryFile is the per-VM file that boot timings are appended to.
+const HistoryFile = "boot_times.jsonl"
+
+// MaxHistory is the number of boots the history file keeps; older ones are
+// dropped once it grows past that. A variable so tests can lower it.
+var MaxHistory = 500
+
+// RegressionWindow is the number of boots in each window compared by
+// Regressions: the latest boots against the ones just before them.
+const RegressionWindow = 10
+
+// regressionThreshold is the relative slowdown of a phase's median that
+// counts as a regression.
+const regressionThreshold = 0.2
+
+// regressionFloor ignores slowdowns too small to matter, so phases that
+// take microseconds don't flap.
+const regressionFloor = 5 * time.Millisecond
+
+// TimingSnapshot is one boot's recorded phase timings.
+type TimingSnapshot struct {
+	Time   time.Time     `json:"time"`
+	Phases []Phase       `json:"phases"`
+	Total  time.Duration `json:"total_ns"`
+}
+
+// Snapshot returns the phases recorded so far, stamped with the current time.
+func (t *Timer) Snapshot() TimingSnapshot {
+	return TimingSnapshot{
+		Time:   time.Now(),
+		Phases: append([]Phase(nil), t.phases...),
+		Total:  t.Total(),
+	}
+}
+
+// Append adds s as a JSON line to the history file at path, creating it
+// and its directory if needed, and drops the oldest boots beyond MaxHistory.
+func Append(path string, s TimingSnapshot) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("create timing dir: %w", err)
+	}
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("open timing history: %w", err)
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return fmt.Errorf("write timing history: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("write timing history: %w", err)
+	}
+	return trimHistory(path)
+}
+
+// trimHistory rewrites the history file at path with only its last
+// MaxHistory snapshots, if it holds more.
+func trimHistory(path string) error {
+	snapshots, err := Load(path)
+	if err != nil || len(snapshots) <= MaxHistory {
+		return err
+	}
+	snapshots = snapshots[len(snapshots)-MaxHistory:]
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), HistoryFile+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("trim timing history: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	w := bufio.NewWriter(tmp)
+	enc := json.NewEncoder(w)
+	for _, s := range snapshots {
+		if err := enc.Encode(s); err != nil {
+			tmp.Close()
+			return fmt.Errorf("trim timing history: %w", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("trim timing history: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("trim timing history: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("trim timing history: %w", err)
+	}
+	return nil
+}
+
+// Load reads every snapshot from the history file at path, oldest first.
+// A missing file yields no snapshots. Lines that fail to parse, such as one
+// cut short by a crash, are skipped.
+func Load(path string) ([]TimingSnapshot, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("open timing history: %w", err)
+	}
+	defer f.Close()
+
+	var snapshots []TimingSnapshot
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var s TimingSnapshot
+		if err := json.Unmarshal(line, &s); err != nil {
+			continue
+		}
+		snapshots = append(snapshots, s)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read timing history: %w", err)
+	}
+	return snapshots, nil
+}
+
+// TimingHistory aggregates phase timings over many boots.
+type TimingHistory struct {
+	Snapshots []TimingSnapshot
+}
+
+// PhaseStats summarises one phase across boots.
+type PhaseStats struct {
+	Name  string        `json:"name"`
+	Count int           `json:"count"`
+	Min   time.Duration `json:"min_ns"`
+	Max   time.Duration `json:"max_ns"`
+	P50   time.Duration `json:"p50_ns"`
+	P95   time.Duration `json:"p95_ns"`
+	P99   time.Duration `json:"p99_ns"`
+}
+
+// Regression is a phase whose median got slower in the latest boots.
+type Regression struct {
+	Name     string        `json:"name"`
+	Previous time.Duration `json:"previous_p50_ns"`
+	Recent   time.Duration `json:"recent_p50_ns"`
+	Change   float64       `json:"change"` // Relative slowdown, 0.25 = 25% slower
+}
+
+// Stats returns per-phase statistics in the order phases were first seen.
+// The boot total is reported as a final "total" phase.
+func (h *TimingHistory) Stats() []PhaseStats {
+	names, samples := h.samples(h.Snapshots)
+
+	stats := make([]PhaseStats, 0, len(names))
+	for _, name := range names {
+		d := samples[name]
+		stats = append(stats, PhaseStats{
+			Name:  name,
+			Count: len(d),
+			Min:   d[0],
+			Max:   d[len(d)-1],
+			P50:   percentile(d, 50),
+			P95:   percentile(d, 95),
+			P99:   percentile(d, 99),
+		})
+	}
+	return stats
+}
+
+// Regressions compares each phase's median over the last RegressionWindow
+// boots with the RegressionWindow boots before them. It needs at least one
+// full window on each side; otherwise it returns nil.
+func (h *TimingHistory) Regressions() []Regression {
+	n := len(h.Snapshots)
+	if n < 2*RegressionWindow {
+		return nil
+	}
+	_, previous := h.samples(h.Snapshots[n-2*RegressionWindow : n-RegressionWindow])
+	names, recent := h.samples(h.Snapshots[n-RegressionWindow:])
+
+	var regressions []Regression
+	for _, name := range names {
+		prev, ok := previous[name]
+		if !ok {
+			continue
+		}
+		before, after := percentile(prev, 50), percentile(recent[name], 50)
+		if after-before < regressionFloor || before <= 0 {
+			continue
+		}
+		change := float64(after-before) / float64(before)
+		if change >= regressionThreshold {
+			regressions = append(regressions, Regression{
+				Name:     name,
+				Previous: before,
+				Recent:   after,
+				Change:   change,
+			})
+		}
+	}
+	return regressions
+}
+
+// samples collects sorted durations per phase from snapshots, returning
+// phase names in first-seen order with "total" last.
+func (h *TimingHistory) samples(snapshots []TimingSnapshot) ([]string, map[string][]time.Duration) {
+	var names []string
+	samples := make(map[string][]time.Duration)
+	add := func(name string, d time.Duration) {
+		if _, ok := samples[name]; !ok {
+			names = append(names, name)
+		}
+		samples[name] = append(samples[name], d)
+	}
+
+	for _, s := range snapshots {
+		for _, p := range s.Phases {
+			add(p.Name, p.Duration)
+		}
+	}
+	for _, s := range snapshots {
+		add("total", s.Total)
+	}
+
+	for _, d := range samples {
+		sort.Slice(d, func(i, j int) bool { return d[i] < d[j] })
+	}
+	return names, samples
+}
+
+// percentile returns the nearest-rank percentile p of sorted durations.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// FormatDuration formats a duration the way Report does.
+func FormatDuration(d time.Duration) string {
+	return formatDuration(d)
+}
@@ -0,0 +1,102 @@
+package timing
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// OTLPEndpointEnv names the environment variable holding the OTLP/gRPC
+// collector to send boot traces to, e.g. "localhost:4317". Traces are not
+// sent when it is unset.
+const OTLPEndpointEnv = "VMT_OTLP_ENDPOINT"
+
+// RootSpanName is the name of the span covering a whole boot; each phase
+// is a child span of it.
+const RootSpanName = "vmterminal.run"
+
+// tracerName is the instrumentation scope of vmterminal's spans.
+const tracerName = "github.com/javanstorm/vmterminal/internal/timing"
+
+// InitTracing installs an OTLP/gRPC trace exporter for the collector at
+// endpoint as the global tracer provider. An endpoint with an https://
+// scheme is dialled over TLS; a bare host:port or http:// URL is not. The
+// returned function flushes any pending spans and must be called before
+// exit. With an empty endpoint, tracing stays a no-op.
+func InitTracing(ctx context.Context, endpoint string) (shutdown func(context.Context) error, err error) {
+	if endpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	var opts []otlptracegrpc.Option
+	if strings.Contains(endpoint, "://") {
+		opts = append(opts, otlptracegrpc.WithEndpointURL(endpoint))
+	} else {
+		opts = append(opts, otlptracegrpc.WithEndpoint(endpoint), otlptracegrpc.WithInsecure())
+	}
+	exporter, err := otlptracegrpc.New(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("create trace exporter: %w", err)
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", "vmterminal"))),
+	)
+	otel.SetTracerProvider(tp)
+	return tp.Shutdown, nil
+}
+
+// StartSpan starts a span named name as a child of the span in ctx, for
+// work inside a phase that is worth seeing on its own in a trace.
+func StartSpan(ctx context.Context, name string) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name)
+}
+
+// startTrace starts the root span of the timer's trace.
+func (t *Timer) startTrace() {
+	t.ctx, t.root = otel.Tracer(tracerName).Start(context.Background(), RootSpanName, trace.WithTimestamp(t.start))
+}
+
+// Context returns ctx carrying the span of the phase in progress, so spans
+// started with it nest under the phase its Mark will end.
+func (t *Timer) Context(ctx context.Context) context.Context {
+	if t.root == nil {
+		return ctx
+	}
+	if t.phase == nil {
+		_, t.phase = otel.Tracer(tracerName).Start(t.ctx, "", trace.WithTimestamp(t.phaseStart()))
+	}
+	return trace.ContextWithSpan(ctx, t.phase)
+}
+
+// endPhaseSpan ends the span of the phase name, which finished at end.
+func (t *Timer) endPhaseSpan(name string, start, end time.Time) {
+	span := t.phase
+	if span == nil {
+		_, span = otel.Tracer(tracerName).Start(t.ctx, name, trace.WithTimestamp(start))
+	}
+	span.SetName(name)
+	span.End(trace.WithTimestamp(end))
+	t.phase = nil
+}
+
+// phaseStart returns when the phase in progress started.
+func (t *Timer) phaseStart() time.Time {
+	return t.start.Add(t.totalDuration())
+}
+
+// End ends the root span at the last mark.
+func (t *Timer) End() {
+	if t.root != nil {
+		t.root.End(trace.WithTimestamp(t.phaseStart()))
+	}
+}
@@ -2,26 +2,36 @@
 package timing
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"time"
+
+	"go.opentelemetry.io/otel/trace"
 )
 
-// Timer tracks durations of named phases.
+// Timer tracks durations of named phases. Each phase is also a span of a
+// trace, exported when tracing has been set up with InitTracing.
 type Timer struct {
 	start  time.Time
 	phases []Phase
+
+	ctx   context.Context // carries root
+	root  trace.Span
+	phase trace.Span // span of the phase in progress, if Context started one
 }
 
 // Phase represents a timed phase with name and duration.
 type Phase struct {
-	Name     string
-	Duration time.Duration
+	Name     string        `json:"name"`
+	Duration time.Duration `json:"duration_ns"`
 }
 
 // New creates a new Timer starting from now.
 func New() *Timer {
-	return &Timer{start: time.Now()}
+	t := &Timer{start: time.Now()}
+	t.startTrace()
+	return t
 }
 
 // Mark records a named phase ending now.
@@ -34,6 +44,9 @@ func (t *Timer) Mark(name string) {
 	} else {
 		duration = now.Sub(t.start) - t.totalDuration()
 	}
+	if t.root != nil {
+		t.endPhaseSpan(name, t.phaseStart(), now)
+	}
 	t.phases = append(t.phases, Phase{Name: name, Duration: duration})
 }
 
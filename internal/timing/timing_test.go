@@ -2,9 +2,16 @@ package timing
 
 import (
 	"bytes"
+	"context"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 )
 
 func TestTimerMark(t *testing.T) {
@@ -122,3 +129,168 @@ func TestFormatDuration(t *testing.T) {
 		}
 	}
 }
+
+func bootSnapshot(phases map[string]time.Duration, total time.Duration) TimingSnapshot {
+	s := TimingSnapshot{Time: time.Now(), Total: total}
+	for _, name := range []string{"config_load", "vm_start"} {
+		if d, ok := phases[name]; ok {
+			s.Phases = append(s.Phases, Phase{Name: name, Duration: d})
+		}
+	}
+	return s
+}
+
+func TestHistoryAppendLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vm", HistoryFile)
+
+	// Missing file is empty history
+	if got, err := Load(path); err != nil || len(got) != 0 {
+		t.Fatalf("Load(missing) = %v, %v; want empty", got, err)
+	}
+
+	timer := New()
+	timer.Mark("config_load")
+	timer.Mark("vm_start")
+	for i := 0; i < 2; i++ {
+		if err := Append(path, timer.Snapshot()); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+	}
+
+	// A torn final line is skipped
+	f, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	f.WriteString(`{"time":"2026-`)
+	f.Close()
+
+	got, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("Load returned %d snapshots, want 2", len(got))
+	}
+	if len(got[0].Phases) != 2 || got[0].Phases[1].Name != "vm_start" {
+		t.Errorf("phases not round-tripped: %+v", got[0].Phases)
+	}
+}
+
+func TestHistoryAppendTrims(t *testing.T) {
+	old := MaxHistory
+	MaxHistory = 3
+	defer func() { MaxHistory = old }()
+
+	path := filepath.Join(t.TempDir(), HistoryFile)
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		s := TimingSnapshot{Time: start.Add(time.Duration(i) * time.Hour), Total: time.Second}
+		if err := Append(path, s); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+	}
+
+	got, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(got) != 3 {
+		t.Fatalf("history holds %d boots, want 3", len(got))
+	}
+	if !got[0].Time.Equal(start.Add(2 * time.Hour)) {
+		t.Errorf("oldest kept boot = %v, want the third", got[0].Time)
+	}
+}
+
+func TestHistoryStats(t *testing.T) {
+	var h TimingHistory
+	for i := 1; i <= 100; i++ {
+		d := time.Duration(i) * time.Millisecond
+		h.Snapshots = append(h.Snapshots, bootSnapshot(map[string]time.Duration{"config_load": d}, 2*d))
+	}
+
+	stats := h.Stats()
+	if len(stats) != 2 || stats[0].Name != "config_load" || stats[1].Name != "total" {
+		t.Fatalf("Stats() phases = %+v, want config_load then total", stats)
+	}
+	s := stats[0]
+	if s.Count != 100 || s.Min != time.Millisecond || s.Max != 100*time.Millisecond {
+		t.Errorf("count/min/max = %d/%v/%v", s.Count, s.Min, s.Max)
+	}
+	if s.P50 != 50*time.Millisecond || s.P95 != 95*time.Millisecond || s.P99 != 99*time.Millisecond {
+		t.Errorf("p50/p95/p99 = %v/%v/%v", s.P50, s.P95, s.P99)
+	}
+}
+
+func TestHistoryRegressions(t *testing.T) {
+	var h TimingHistory
+	add := func(n int, load, start time.Duration) {
+		for i := 0; i < n; i++ {
+			h.Snapshots = append(h.Snapshots, bootSnapshot(map[string]time.Duration{
+				"config_load": load, "vm_start": start,
+			}, load+start))
+		}
+	}
+
+	add(RegressionWindow, 20*time.Millisecond, time.Second)
+	add(RegressionWindow-1, 20*time.Millisecond, 2*time.Second)
+	if got := h.Regressions(); got != nil {
+		t.Errorf("Regressions() with too few boots = %+v, want nil", got)
+	}
+
+	add(1, 20*time.Millisecond, 2*time.Second)
+	got := h.Regressions()
+	if len(got) != 2 || got[0].Name != "vm_start" || got[1].Name != "total" {
+		t.Fatalf("Regressions() = %+v, want vm_start and total", got)
+	}
+	if got[0].Previous != time.Second || got[0].Recent != 2*time.Second || got[0].Change != 1 {
+		t.Errorf("vm_start regression = %+v", got[0])
+	}
+}
+
+func TestTimerSpans(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(tp)
+	defer otel.SetTracerProvider(prev)
+
+	timer := New()
+	timer.Mark("config_load")
+	_, span := StartSpan(timer.Context(context.Background()), "asset_ensure")
+	span.End()
+	timer.Mark("vm_prepare")
+	timer.Mark("vm_start")
+	timer.End()
+
+	spans := map[string]tracetest.SpanStub{}
+	for _, s := range exporter.GetSpans() {
+		spans[s.Name] = s
+	}
+	if len(spans) != 5 {
+		t.Fatalf("got spans %v, want 5", spans)
+	}
+	root := spans[RootSpanName]
+	if root.Parent.IsValid() {
+		t.Errorf("root span has parent %v", root.Parent.SpanID())
+	}
+	for _, name := range []string{"config_load", "vm_prepare", "vm_start"} {
+		if got := spans[name].Parent.SpanID(); got != root.SpanContext.SpanID() {
+			t.Errorf("%s is not a child of the root span", name)
+		}
+	}
+	if got := spans["asset_ensure"].Parent.SpanID(); got != spans["vm_prepare"].SpanContext.SpanID() {
+		t.Error("asset_ensure is not a child of vm_prepare")
+	}
+	if !spans["vm_prepare"].StartTime.Equal(spans["config_load"].EndTime) || !root.EndTime.Equal(spans["vm_start"].EndTime) {
+		t.Error("phase spans do not follow each other")
+	}
+}
+
+func TestInitTracingWithoutEndpoint(t *testing.T) {
+	shutdown, err := InitTracing(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := shutdown(context.Background()); err != nil {
+		t.Errorf("shutdown: %v", err)
+	}
+}
@@ -0,0 +1,313 @@
+package version
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/javanstorm/vmterminal/internal/filelock"
+)
+
+// Update channels accepted by FetchRelease.
+const (
+	ChannelStable  = "stable"  // The latest full release
+	ChannelNightly = "nightly" // The newest release, prereleases included
+)
+
+// ReleasesURL is the GitHub API endpoint listing releases, newest first,
+// used for the nightly channel.
+var ReleasesURL = "https://api.github.com/repos/javanstorm/vmterminal/releases"
+
+const (
+	// checksumsAsset lists the SHA-256 of every release archive in
+	// sha256sum format.
+	checksumsAsset = "checksums.txt"
+
+	// binaryName is the executable inside each release archive.
+	binaryName = "vmterminal"
+
+	// downloadTimeout bounds downloading a release archive.
+	downloadTimeout = 10 * time.Minute
+
+	// UpdateLockFile is the lock file in the base directory held while
+	// 'vmterminal update' replaces the executable.
+	UpdateLockFile = "update.lock"
+)
+
+// AcquireUpdateLock takes the update lock in baseDir, retrying until
+// timeout, so only one process replaces the executable at a time.
+// Callers must Release it, typically with defer.
+func AcquireUpdateLock(baseDir string, timeout time.Duration) (*filelock.Lock, error) {
+	return filelock.Acquire(baseDir, UpdateLockFile, "update", timeout)
+}
+
+// Release is a published GitHub release.
+type Release struct {
+	TagName    string         `json:"tag_name"`
+	Name       string         `json:"name"`
+	Prerelease bool           `json:"prerelease"`
+	Draft      bool           `json:"draft"`
+	Body       string         `json:"body"` // Release notes
+	Assets     []ReleaseAsset `json:"assets"`
+}
+
+// ReleaseAsset is a file attached to a release.
+type ReleaseAsset struct {
+	Name string `json:"name"`
+	URL  string `json:"browser_download_url"`
+	Size int64  `json:"size"`
+
+	// Digest is "sha256:<hex>" on releases where GitHub records it.
+	Digest string `json:"digest"`
+}
+
+// Asset returns the release's asset called name.
+func (r *Release) Asset(name string) (*ReleaseAsset, bool) {
+	for i := range r.Assets {
+		if r.Assets[i].Name == name {
+			return &r.Assets[i], true
+		}
+	}
+	return nil, false
+}
+
+// ArchiveAssetName returns the name of the release archive for this
+// platform, as GoReleaser names it: vmterminal_<version>_<os>_<arch>.tar.gz,
+// where the version is the tag without its leading "v".
+func ArchiveAssetName(tag string) string {
+	return fmt.Sprintf("vmterminal_%s_%s_%s.tar.gz", strings.TrimPrefix(tag, "v"), runtime.GOOS, runtime.GOARCH)
+}
+
+// FetchRelease returns the release channel updates to.
+func FetchRelease(ctx context.Context, channel string) (*Release, error) {
+	switch channel {
+	case ChannelStable:
+		var release Release
+		if err := getJSON(ctx, LatestReleaseURL, &release); err != nil {
+			return nil, fmt.Errorf("fetch latest release: %w", err)
+		}
+		if release.TagName == "" {
+			return nil, fmt.Errorf("latest release has no tag")
+		}
+		return &release, nil
+	case ChannelNightly:
+		var releases []Release
+		if err := getJSON(ctx, ReleasesURL, &releases); err != nil {
+			return nil, fmt.Errorf("fetch releases: %w", err)
+		}
+		for i := range releases {
+			if !releases[i].Draft && releases[i].TagName != "" {
+				return &releases[i], nil
+			}
+		}
+		return nil, fmt.Errorf("no releases published")
+	default:
+		return nil, fmt.Errorf("unknown update channel %q (want %s or %s)", channel, ChannelStable, ChannelNightly)
+	}
+}
+
+// IsNewer reports whether release should replace the running version on
+// channel. Stable releases must have a higher version number; nightly
+// tags carry no comparable number, so any other tag is newer.
+func IsNewer(current string, release *Release, channel string) bool {
+	if channel == ChannelNightly {
+		return release.TagName != current && strings.TrimPrefix(release.TagName, "v") != strings.TrimPrefix(current, "v")
+	}
+	return IsOutdated(current, release.TagName)
+}
+
+// getJSON decodes the GitHub API response at url into v.
+func getJSON(ctx context.Context, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s", resp.Status)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 4<<20)).Decode(v)
+}
+
+// download opens the asset at url.
+func download(ctx context.Context, url string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("download %s: %s", url, resp.Status)
+	}
+	return resp.Body, nil
+}
+
+// ExpectedChecksum returns the SHA-256 the release publishes for the asset
+// called name: GitHub's recorded digest, or its line in checksums.txt.
+func ExpectedChecksum(ctx context.Context, release *Release, name string) (string, error) {
+	asset, ok := release.Asset(name)
+	if !ok {
+		return "", fmt.Errorf("release %s has no %s", release.TagName, name)
+	}
+	if sum, ok := strings.CutPrefix(asset.Digest, "sha256:"); ok {
+		return sum, nil
+	}
+
+	sums, ok := release.Asset(checksumsAsset)
+	if !ok {
+		return "", fmt.Errorf("release %s publishes no checksum for %s", release.TagName, name)
+	}
+	body, err := download(ctx, sums.URL)
+	if err != nil {
+		return "", fmt.Errorf("fetch checksums: %w", err)
+	}
+	defer body.Close()
+
+	scanner := bufio.NewScanner(body)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == name {
+			return fields[0], nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("read checksums: %w", err)
+	}
+	return "", fmt.Errorf("%s of release %s has no entry for %s", checksumsAsset, release.TagName, name)
+}
+
+// Install downloads the platform's archive from release, checks it against
+// the published SHA-256, and replaces the executable at exePath with the
+// binary inside it. Both are written next to exePath first, so a failed
+// download leaves the old executable in place.
+func Install(ctx context.Context, release *Release, exePath string) error {
+	ctx, cancel := context.WithTimeout(ctx, downloadTimeout)
+	defer cancel()
+
+	name := ArchiveAssetName(release.TagName)
+	asset, ok := release.Asset(name)
+	if !ok {
+		return fmt.Errorf("release %s has no archive for %s", release.TagName, Platform())
+	}
+	want, err := ExpectedChecksum(ctx, release, name)
+	if err != nil {
+		return err
+	}
+
+	body, err := download(ctx, asset.URL)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	dir := filepath.Dir(exePath)
+	archive, err := os.CreateTemp(dir, ".vmterminal-update-*.tar.gz")
+	if err != nil {
+		return fmt.Errorf("create temp file: %w", err)
+	}
+	defer os.Remove(archive.Name())
+	defer archive.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(archive, h), body); err != nil {
+		return fmt.Errorf("download %s: %w", name, err)
+	}
+	if got := hex.EncodeToString(h.Sum(nil)); !strings.EqualFold(got, want) {
+		return fmt.Errorf("downloaded %s is corrupted: SHA-256 %s, release says %s", name, got, want)
+	}
+	if _, err := archive.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("read %s: %w", name, err)
+	}
+
+	tmp, err := os.CreateTemp(dir, ".vmterminal-update-*")
+	if err != nil {
+		return fmt.Errorf("create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name()) // No-op once renamed
+	defer tmp.Close()
+
+	if err := extractBinary(archive, tmp); err != nil {
+		return fmt.Errorf("extract %s: %w", name, err)
+	}
+	if err := tmp.Chmod(0755); err != nil {
+		return fmt.Errorf("make executable: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		return fmt.Errorf("write %s: %w", binaryName, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("write %s: %w", binaryName, err)
+	}
+	return replaceExecutable(exePath, tmp.Name())
+}
+
+// extractBinary copies the vmterminal executable out of the gzipped tar
+// archive r into w. GoReleaser puts it at the top of the archive, next to
+// the README and licence, but a wrapping directory is accepted too.
+func extractBinary(r io.Reader, w io.Writer) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return fmt.Errorf("archive has no %s executable", binaryName)
+		}
+		if err != nil {
+			return err
+		}
+		if hdr.Typeflag == tar.TypeReg && path.Base(hdr.Name) == binaryName {
+			_, err := io.Copy(w, tr)
+			return err
+		}
+	}
+}
+
+// replaceExecutable moves newPath over exePath. Windows cannot replace a
+// running executable, but it can rename it, so the old one is moved aside.
+func replaceExecutable(exePath, newPath string) error {
+	if runtime.GOOS == "windows" {
+		old := exePath + ".old"
+		os.Remove(old)
+		if err := os.Rename(exePath, old); err != nil {
+			return fmt.Errorf("move old executable aside: %w", err)
+		}
+		if err := os.Rename(newPath, exePath); err != nil {
+			os.Rename(old, exePath)
+			return fmt.Errorf("replace executable: %w", err)
+		}
+		return nil
+	}
+	if err := os.Rename(newPath, exePath); err != nil {
+		return fmt.Errorf("replace executable: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package version
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// tarGz returns a gzipped tar archive holding files, name to content.
+func tarGz(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, name := range []string{"README.md", "LICENSE", "vmterminal"} {
+		data, ok := files[name]
+		if !ok {
+			continue
+		}
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0755, Size: int64(len(data)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		tw.Write([]byte(data))
+	}
+	tw.Close()
+	gz.Close()
+	return buf.Bytes()
+}
+
+// releaseServer serves a release whose archive holds binary, with the
+// checksum published in checksums.txt as sum.
+func releaseServer(t *testing.T, archive []byte, sum string) (*httptest.Server, *Release) {
+	t.Helper()
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	name := ArchiveAssetName("v1.3.0")
+	release := &Release{
+		TagName: "v1.3.0",
+		Assets: []ReleaseAsset{
+			{Name: name, URL: srv.URL + "/archive"},
+			{Name: checksumsAsset, URL: srv.URL + "/sums"},
+		},
+	}
+	mux.HandleFunc("/archive", func(w http.ResponseWriter, r *http.Request) { w.Write(archive) })
+	mux.HandleFunc("/sums", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s  vmterminal_1.3.0_other_arch.tar.gz\n%s  %s\n", strings.Repeat("0", 64), sum, name)
+	})
+	return srv, release
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestInstall(t *testing.T) {
+	exePath := filepath.Join(t.TempDir(), "vmterminal")
+	if err := os.WriteFile(exePath, []byte("old binary"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	archive := tarGz(t, map[string]string{"README.md": "readme", "vmterminal": "new binary"})
+	_, release := releaseServer(t, archive, sha256Hex(archive))
+	if err := Install(context.Background(), release, exePath); err != nil {
+		t.Fatalf("Install: %v", err)
+	}
+	data, err := os.ReadFile(exePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "new binary" {
+		t.Errorf("executable = %q, want the new binary", data)
+	}
+	if info, _ := os.Stat(exePath); info.Mode().Perm() != 0755 {
+		t.Errorf("mode = %v, want 0755", info.Mode().Perm())
+	}
+
+	// An archive that does not match its checksum is not installed
+	tampered := tarGz(t, map[string]string{"vmterminal": "tampered binary"})
+	_, release = releaseServer(t, tampered, sha256Hex(archive))
+	if err := Install(context.Background(), release, exePath); err == nil {
+		t.Fatal("expected checksum error")
+	}
+	if data, _ := os.ReadFile(exePath); string(data) != "new binary" {
+		t.Errorf("executable was replaced by a corrupted download: %q", data)
+	}
+
+	// Nor is an archive without the executable
+	empty := tarGz(t, map[string]string{"README.md": "readme"})
+	_, release = releaseServer(t, empty, sha256Hex(empty))
+	if err := Install(context.Background(), release, exePath); err == nil || !strings.Contains(err.Error(), "no vmterminal executable") {
+		t.Fatalf("Install of an archive without the binary: %v", err)
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(exePath)); len(entries) != 1 {
+		t.Errorf("temporary files left behind: %v", entries)
+	}
+}
+
+func TestArchiveAssetName(t *testing.T) {
+	want := fmt.Sprintf("vmterminal_1.3.0_%s_%s.tar.gz", runtime.GOOS, runtime.GOARCH)
+	if got := ArchiveAssetName("v1.3.0"); got != want {
+		t.Errorf("ArchiveAssetName = %q, want %q", got, want)
+	}
+}
+
+func TestExpectedChecksumDigest(t *testing.T) {
+	release := &Release{TagName: "v1.3.0", Assets: []ReleaseAsset{{Name: "vmterminal_1.3.0_linux_amd64.tar.gz", Digest: "sha256:abc123"}}}
+	sum, err := ExpectedChecksum(context.Background(), release, "vmterminal_1.3.0_linux_amd64.tar.gz")
+	if err != nil || sum != "abc123" {
+		t.Errorf("ExpectedChecksum = %q, %v, want abc123", sum, err)
+	}
+	if _, err := ExpectedChecksum(context.Background(), release, "vmterminal_1.3.0_darwin_arm64.tar.gz"); err == nil {
+		t.Error("expected error for a missing asset")
+	}
+}
+
+func TestFetchRelease(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/latest":
+			fmt.Fprint(w, `{"tag_name": "v1.2.0"}`)
+		case "/releases":
+			fmt.Fprint(w, `[{"tag_name": "draft", "draft": true}, {"tag_name": "nightly-20260301", "prerelease": true}, {"tag_name": "v1.2.0"}]`)
+		}
+	}))
+	defer srv.Close()
+
+	origLatest, origReleases := LatestReleaseURL, ReleasesURL
+	defer func() { LatestReleaseURL, ReleasesURL = origLatest, origReleases }()
+	LatestReleaseURL, ReleasesURL = srv.URL+"/latest", srv.URL+"/releases"
+
+	ctx := context.Background()
+	if r, err := FetchRelease(ctx, ChannelStable); err != nil || r.TagName != "v1.2.0" {
+		t.Errorf("stable = %+v, %v", r, err)
+	}
+	r, err := FetchRelease(ctx, ChannelNightly)
+	if err != nil || r.TagName != "nightly-20260301" {
+		t.Errorf("nightly = %+v, %v", r, err)
+	}
+	if !IsNewer("1.2.0", r, ChannelNightly) || IsNewer("nightly-20260301", r, ChannelNightly) {
+		t.Error("IsNewer should compare nightly tags by name")
+	}
+	if _, err := FetchRelease(ctx, "beta"); err == nil {
+		t.Error("expected error for an unknown channel")
+	}
+}
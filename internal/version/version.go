@@ -1,6 +1,14 @@
 // Package version provides build-time version information.
 package version
 
+import (
+	"context"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
 // These variables are set at build time using ldflags:
 //
 //	go build -ldflags "-X github.com/javanstorm/vmterminal/internal/version.Version=1.0.0 \
@@ -16,3 +24,79 @@ var (
 	// BuildDate is the date when the binary was built.
 	BuildDate = "unknown"
 )
+
+// LatestReleaseURL is the GitHub API endpoint CheckForUpdates queries.
+var LatestReleaseURL = "https://api.github.com/repos/javanstorm/vmterminal/releases/latest"
+
+// updateCheckTimeout bounds the release lookup in CheckForUpdates.
+const updateCheckTimeout = 10 * time.Second
+
+// GoVersion returns the Go release the binary was built with.
+func GoVersion() string {
+	return runtime.Version()
+}
+
+// Platform returns the target OS and architecture as "GOOS/GOARCH".
+func Platform() string {
+	return runtime.GOOS + "/" + runtime.GOARCH
+}
+
+// CheckForUpdates returns the tag of the latest published release.
+func CheckForUpdates() (latest string, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), updateCheckTimeout)
+	defer cancel()
+
+	release, err := FetchRelease(ctx, ChannelStable)
+	if err != nil {
+		return "", err
+	}
+	return release.TagName, nil
+}
+
+// IsOutdated reports whether the release tag latest is newer than current.
+// Development builds and versions that are not dotted numbers, such as
+// "dev", are never reported as outdated.
+func IsOutdated(current, latest string) bool {
+	cur, ok := parseVersion(current)
+	if !ok {
+		return false
+	}
+	lat, ok := parseVersion(latest)
+	if !ok {
+		return false
+	}
+	for i := 0; i < len(cur) || i < len(lat); i++ {
+		var c, l int
+		if i < len(cur) {
+			c = cur[i]
+		}
+		if i < len(lat) {
+			l = lat[i]
+		}
+		if c != l {
+			return l > c
+		}
+	}
+	return false
+}
+
+// parseVersion splits a tag such as "v1.2.3" or "1.2.3-4-gabc1234-dirty"
+// into its numeric components, ignoring any suffix after '-'.
+func parseVersion(v string) ([]int, bool) {
+	v = strings.TrimPrefix(v, "v")
+	if i := strings.IndexByte(v, '-'); i >= 0 {
+		v = v[:i]
+	}
+	if v == "" {
+		return nil, false
+	}
+	var parts []int
+	for _, s := range strings.Split(v, ".") {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, false
+		}
+		parts = append(parts, n)
+	}
+	return parts, true
+}
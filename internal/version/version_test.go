@@ -0,0 +1,64 @@
+package version
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestIsOutdated(t *testing.T) {
+	tests := []struct {
+		current, latest string
+		want            bool
+	}{
+		{"1.2.3", "v1.2.4", true},
+		{"v1.2.3", "v1.3", true},
+		{"1.9.0", "1.10.0", true},
+		{"1.2.3", "v1.2.3", false},
+		{"1.2", "1.2.0", false},
+		{"2.0.0", "v1.9.9", false},
+		{"v1.2.3-4-gabc1234-dirty", "v1.2.4", true},
+		{"v1.2.3-4-gabc1234", "v1.2.3", false},
+		{"dev", "v1.0.0", false},
+		{"abc1234", "v1.0.0", false},
+		{"1.0.0", "nightly", false},
+	}
+	for _, tt := range tests {
+		if got := IsOutdated(tt.current, tt.latest); got != tt.want {
+			t.Errorf("IsOutdated(%q, %q) = %v, want %v", tt.current, tt.latest, got, tt.want)
+		}
+	}
+}
+
+func TestCheckForUpdates(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/latest":
+			w.Write([]byte(`{"tag_name": "v1.4.0", "name": "VMTerminal 1.4.0"}`))
+		case "/untagged":
+			w.Write([]byte(`{}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	orig := LatestReleaseURL
+	defer func() { LatestReleaseURL = orig }()
+
+	LatestReleaseURL = srv.URL + "/latest"
+	latest, err := CheckForUpdates()
+	if err != nil {
+		t.Fatalf("CheckForUpdates: %v", err)
+	}
+	if latest != "v1.4.0" {
+		t.Errorf("latest = %q, want v1.4.0", latest)
+	}
+
+	for _, path := range []string{"/untagged", "/missing"} {
+		LatestReleaseURL = srv.URL + path
+		if _, err := CheckForUpdates(); err == nil {
+			t.Errorf("%s: expected an error", path)
+		}
+	}
+}
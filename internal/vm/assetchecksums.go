@@ -0,0 +1,169 @@
+package vm
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/javanstorm/vmterminal/internal/distro"
+)
+
+// AssetChecksumsFile records the SHA-256, size and modification time of
+// the cached assets in a directory, next to the assets themselves.
+const AssetChecksumsFile = "checksums.json"
+
+// assetRecord is what AssetChecksumsFile keeps about one asset. The
+// SHA-256 is taken once, when the asset is downloaded or extracted; the
+// size and modification time let every boot check the cache without
+// reading the files.
+type assetRecord struct {
+	SHA256  string    `json:"sha256"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+// checksumsPath returns the checksums file of the distro's cache directory.
+func (m *AssetManager) checksumsPath() string {
+	return filepath.Join(m.cacheDir, m.provider.CacheSubdir(distro.CurrentArch()), AssetChecksumsFile)
+}
+
+// bootAssets returns the cached files VerifyAssets hashes: the kernel,
+// initramfs. The rootfs is left out because hashing it takes
+// too long; like every asset it is still checked by size and time.
+func bootAssets(paths *AssetPaths) []string {
+	var out []string
+	for _, p := range []string{paths.Kernel, paths.Initramfs} {
+		if p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// loadChecksums reads the records of the checksums file at path, keyed by
+// file name. A missing file gives an empty map, and files written before
+// sizes were recorded give records with only the SHA-256.
+func loadChecksums(path string) (map[string]assetRecord, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return map[string]assetRecord{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", AssetChecksumsFile, err)
+	}
+	records := map[string]assetRecord{}
+	if err := json.Unmarshal(data, &records); err == nil {
+		return records, nil
+	}
+	sums := map[string]string{}
+	if err := json.Unmarshal(data, &sums); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", AssetChecksumsFile, err)
+	}
+	for name, sum := range sums {
+		records[name] = assetRecord{SHA256: sum}
+	}
+	return records, nil
+}
+
+// recordAsset notes the SHA-256, size and modification time of the asset
+// at path in the checksums file of its directory. sum is the SHA-256 if
+// the caller already has it, or "" to hash the file now.
+func recordAsset(path, sum string) error {
+	if sum == "" {
+		var err error
+		if sum, err = fileSHA256(path); err != nil {
+			return fmt.Errorf("checksum %s: %w", filepath.Base(path), err)
+		}
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	checksums := filepath.Join(filepath.Dir(path), AssetChecksumsFile)
+	records, err := loadChecksums(checksums)
+	if err != nil {
+		return err
+	}
+	records[filepath.Base(path)] = assetRecord{SHA256: sum, Size: info.Size(), ModTime: info.ModTime()}
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := checksums + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("write %s: %w", AssetChecksumsFile, err)
+	}
+	if err := os.Rename(tmp, checksums); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("write %s: %w", AssetChecksumsFile, err)
+	}
+	return nil
+}
+
+// checkAsset checks that the asset at path still has the size and
+// modification time recorded for it, without reading it. Assets cached
+// before records were kept only need to exist.
+func checkAsset(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	records, err := loadChecksums(filepath.Join(filepath.Dir(path), AssetChecksumsFile))
+	if err != nil {
+		return err
+	}
+	rec, ok := records[filepath.Base(path)]
+	if !ok || rec.ModTime.IsZero() {
+		return nil
+	}
+	if info.Size() != rec.Size || !info.ModTime().Equal(rec.ModTime) {
+		return fmt.Errorf("%s changed since it was cached", filepath.Base(path))
+	}
+	return nil
+}
+
+// VerifyAssets hashes the cached kernel, initramfs and firmware and checks
+// them against the SHA-256 recorded in checksums.json when they were
+// downloaded or extracted. It reads every file, so it is only run after an
+// unclean shutdown. Assets without a recorded checksum, cached by older versions,
+// are checked against the content store if they live in it. corrupt holds
+// the paths of the assets that fail; ok is true when there are none.
+func (m *AssetManager) VerifyAssets() (ok bool, corrupt []string, err error) {
+	paths, err := m.GetAssetPaths()
+	if err != nil {
+		return false, nil, err
+	}
+	records, err := loadChecksums(m.checksumsPath())
+	if err != nil {
+		return false, nil, err
+	}
+
+	for _, p := range bootAssets(paths) {
+		rec, recorded := records[filepath.Base(p)]
+		if !recorded {
+			if m.cas.Verify(p) != nil {
+				corrupt = append(corrupt, p)
+			}
+			continue
+		}
+		got, err := fileSHA256(p)
+		if err != nil || got != rec.SHA256 {
+			corrupt = append(corrupt, p)
+		}
+	}
+	return len(corrupt) == 0, corrupt, nil
+}
+
+// DiscardAssets removes the cached assets at paths, as returned by
+// VerifyAssets, so that EnsureAssets downloads or extracts them again.
+func (m *AssetManager) DiscardAssets(paths []string) error {
+	for _, p := range paths {
+		if err := m.cas.Discard(p); err != nil {
+			return fmt.Errorf("remove corrupt %s: %w", filepath.Base(p), err)
+		}
+	}
+	return nil
+}
@@ -1,32 +1,92 @@
 package vm
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/javanstorm/vmterminal/internal/distro"
+	"github.com/javanstorm/vmterminal/internal/progress"
 )
 
 // AssetManager handles kernel, initramfs, and rootfs downloads.
 type AssetManager struct {
-	cacheDir string
-	provider distro.Provider
+	cacheDir   string
+	provider   distro.Provider
+	prog       progress.Progress
+	skipVerify bool
+	limit      int64    // Download bytes per second, 0 = unlimited
+	mirrors    []string // Base URLs tried before the official server
+	maxCache   int64    // Cache size EnsureAssets evicts down to, 0 = unlimited
+	cas        *ContentStore
+	client     *http.Client
 }
 
 // NewAssetManager creates an asset manager with the given cache directory and distro provider.
-func NewAssetManager(cacheDir string, provider distro.Provider) *AssetManager {
+// Downloads are kept in the content store next to cacheDir (see ContentStoreDir).
+// Download and extraction progress is reported to prog; nil discards it.
+func NewAssetManager(cacheDir string, provider distro.Provider, prog progress.Progress) *AssetManager {
+	if prog == nil {
+		prog = progress.SilentProgress{}
+	}
 	return &AssetManager{
 		cacheDir: cacheDir,
 		provider: provider,
+		prog:     prog,
+		cas:      NewContentStore(ContentStoreDir(cacheDir)),
+		client:   NewHTTPClient(""),
 	}
 }
 
+// SetSkipVerify disables OpenPGP signature checks on downloaded assets.
+func (m *AssetManager) SetSkipVerify(skip bool) {
+	m.skipVerify = skip
+}
+
+// WithBandwidthLimit caps download speed at bytesPerSec, allowing bursts of
+// up to five seconds' worth. Zero removes the limit. It returns m.
+func (m *AssetManager) WithBandwidthLimit(bytesPerSec int64) *AssetManager {
+	m.limit = bytesPerSec
+	return m
+}
+
+// WithMirrors makes downloads try each mirror, in round-robin order, before
+// the URL the distro publishes. A mirror replaces the scheme and host of
+// the official URL and must serve the same paths, optionally below a path
+// of its own. It returns m.
+func (m *AssetManager) WithMirrors(mirrors []string) *AssetManager {
+	m.mirrors = mirrors
+	return m
+}
+
+// WithCacheLimit makes EnsureAssets evict the least recently used distro
+// versions from the cache, other than the provider's and those registered
+// VMs use, after downloading while the cache holds more than maxBytes (see
+// EvictOldCacheEntries).
+// Zero removes the limit. It returns m.
+func (m *AssetManager) WithCacheLimit(maxBytes int64) *AssetManager {
+	m.maxCache = maxBytes
+	return m
+}
+
+// WithProxy sends downloads through proxy instead of the proxy named by
+// the environment (see NewHTTPClient). An empty proxy keeps the
+// environment's. It returns m.
+func (m *AssetManager) WithProxy(proxy string) *AssetManager {
+	m.client = NewHTTPClient(proxy)
+	return m
+}
+
 // AssetPaths contains paths to downloaded assets.
 type AssetPaths struct {
 	Kernel    string
@@ -59,6 +119,17 @@ func (m *AssetManager) EnsureAssets() (*AssetPaths, error) {
 		return nil, fmt.Errorf("create cache dir: %w", err)
 	}
 
+	// Another process may be fetching the same assets; wait for it and
+	// use what it left behind
+	lock, err := AcquireCacheLock(cacheSubdir, cacheLockTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer lock.Release()
+	if exist, _ := m.AssetsExist(); exist {
+		return m.GetAssetPaths()
+	}
+
 	// Get asset URLs from provider
 	urls, err := m.provider.AssetURLs(arch)
 	if err != nil {
@@ -75,7 +146,7 @@ func (m *AssetManager) EnsureAssets() (*AssetPaths, error) {
 		if urls.Rootfs != "" {
 			ext := filepath.Ext(urls.Rootfs)
 			paths.Rootfs = filepath.Join(cacheSubdir, "rootfs"+ext)
-			if err := m.ensureFile(paths.Rootfs, urls.Rootfs); err != nil {
+			if err := m.ensureRootfs(paths.Rootfs, urls); err != nil {
 				return nil, fmt.Errorf("download rootfs: %w", err)
 			}
 		}
@@ -86,15 +157,26 @@ func (m *AssetManager) EnsureAssets() (*AssetPaths, error) {
 
 		_, kernelErr := os.Stat(kernelPath)
 		_, initrdErr := os.Stat(initrdPath)
+		var initErr error
+		if len(locator.InitConfigPatterns) > 0 {
+			_, initErr = os.Stat(filepath.Join(cacheSubdir, initArgFile))
+		}
 
-		if os.IsNotExist(kernelErr) || os.IsNotExist(initrdErr) {
+		if os.IsNotExist(kernelErr) || os.IsNotExist(initrdErr) || os.IsNotExist(initErr) {
 			// Extract kernel/initrd from rootfs
-			fmt.Printf("Extracting kernel and initrd from %s...\n", filepath.Base(paths.Rootfs))
+			m.prog.Start(fmt.Sprintf("Extracting kernel and initrd from %s", filepath.Base(paths.Rootfs)), 0)
 			extractor := NewKernelExtractor(cacheSubdir)
 			kernel, initrd, err := extractor.ExtractKernel(paths.Rootfs, locator)
 			if err != nil {
+				m.prog.Error(err)
 				return nil, fmt.Errorf("extract kernel: %w", err)
 			}
+			m.prog.Complete()
+			for _, p := range []string{kernel, initrd} {
+				if err := recordAsset(p, ""); err != nil {
+					return nil, err
+				}
+			}
 			paths.Kernel = kernel
 			paths.Initramfs = initrd
 		} else {
@@ -102,26 +184,51 @@ func (m *AssetManager) EnsureAssets() (*AssetPaths, error) {
 			paths.Initramfs = initrdPath
 		}
 
-		// For qcow2 images that don't need extraction (like Ubuntu cloud images),
-		// convert to raw format so it can be used as the root disk
+		// For qcow2 images that don't need extraction (like Ubuntu cloud images)
+		// or that boot through UEFI, convert to raw format so it can be used
+		// as the root disk
 		setupReqs := m.provider.SetupRequirements()
-		if setupReqs != nil && !setupReqs.NeedsExtraction && locator.ArchiveType == "qcow2" {
+		bootsImage := (setupReqs != nil && !setupReqs.NeedsExtraction) || m.provider.BootConfig(arch).UEFI
+		if bootsImage && locator.ArchiveType == "qcow2" {
 			rawPath := filepath.Join(cacheSubdir, "rootfs.raw")
 			if _, err := os.Stat(rawPath); os.IsNotExist(err) {
-				fmt.Printf("Converting %s to raw format...\n", filepath.Base(paths.Rootfs))
+				m.prog.Start(fmt.Sprintf("Converting %s to raw format", filepath.Base(paths.Rootfs)), 0)
 				if err := m.convertQcow2ToRaw(paths.Rootfs, rawPath); err != nil {
+					m.prog.Error(err)
 					return nil, fmt.Errorf("convert qcow2 to raw: %w", err)
 				}
+				m.prog.Complete()
+				if err := recordAsset(rawPath, ""); err != nil {
+					return nil, err
+				}
 			}
 			// Update rootfs path to point to raw image
 			paths.Rootfs = rawPath
 		}
 	} else {
-		// Direct download (Alpine-style or iso: URL scheme)
+		// Direct download (Alpine-style or iso:/tar: URL scheme)
+		// Download rootfs first: tar: kernel URLs may point into it
+		if urls.Rootfs != "" {
+			ext := filepath.Ext(urls.Rootfs)
+			paths.Rootfs = filepath.Join(cacheSubdir, "rootfs"+ext)
+			if err := m.ensureRootfs(paths.Rootfs, urls); err != nil {
+				return nil, fmt.Errorf("download rootfs: %w", err)
+			}
+		}
+
+		// fetch extracts straight from the downloaded rootfs when a tar:
+		// URL names it, instead of downloading the same tarball again
+		fetch := func(path, url string) error {
+			if archiveURL, member, ok := parseTarURL(url); ok && archiveURL == urls.Rootfs {
+				return m.extractFromTarball(paths.Rootfs, member, path)
+			}
+			return m.ensureFile(path, url, nil)
+		}
+
 		// Download kernel if URL is provided
 		if urls.Kernel != "" {
 			paths.Kernel = filepath.Join(cacheSubdir, "vmlinuz")
-			if err := m.ensureFile(paths.Kernel, urls.Kernel); err != nil {
+			if err := fetch(paths.Kernel, urls.Kernel); err != nil {
 				return nil, fmt.Errorf("download kernel: %w", err)
 			}
 		}
@@ -129,19 +236,15 @@ func (m *AssetManager) EnsureAssets() (*AssetPaths, error) {
 		// Download initramfs if URL is provided
 		if urls.Initrd != "" {
 			paths.Initramfs = filepath.Join(cacheSubdir, "initramfs")
-			if err := m.ensureFile(paths.Initramfs, urls.Initrd); err != nil {
+			if err := fetch(paths.Initramfs, urls.Initrd); err != nil {
 				return nil, fmt.Errorf("download initramfs: %w", err)
 			}
 		}
+	}
 
-		// Download rootfs if URL is provided
-		if urls.Rootfs != "" {
-			ext := filepath.Ext(urls.Rootfs)
-			paths.Rootfs = filepath.Join(cacheSubdir, "rootfs"+ext)
-			if err := m.ensureFile(paths.Rootfs, urls.Rootfs); err != nil {
-				return nil, fmt.Errorf("download rootfs: %w", err)
-			}
-		}
+	inUse := filepath.Join(m.cacheDir, string(m.provider.ID()))
+	if err := EvictOldCacheEntries(m.cacheDir, m.maxCache, inUse); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: evict old cache entries: %v\n", err)
 	}
 
 	return paths, nil
@@ -162,9 +265,20 @@ func (m *AssetManager) DistroID() distro.ID {
 	return m.provider.ID()
 }
 
-// BootConfig returns the boot configuration for the current architecture.
+// BootConfig returns the boot configuration for the current architecture,
+// with the init= argument extracted from the image, if any, added to
+// Cmdline.
 func (m *AssetManager) BootConfig() *distro.BootConfig {
-	return m.provider.BootConfig(distro.CurrentArch())
+	arch := distro.CurrentArch()
+	cfg := m.provider.BootConfig(arch)
+	if loc := m.provider.KernelLocator(); loc != nil && len(loc.InitConfigPatterns) > 0 {
+		if init, err := os.ReadFile(filepath.Join(m.cacheDir, m.provider.CacheSubdir(arch), initArgFile)); err == nil {
+			resolved := *cfg
+			resolved.Cmdline = strings.TrimSpace(cfg.Cmdline + " init=" + strings.TrimSpace(string(init)))
+			cfg = &resolved
+		}
+	}
+	return cfg
 }
 
 // SetupRequirements returns the setup requirements for the distro.
@@ -173,7 +287,8 @@ func (m *AssetManager) SetupRequirements() *distro.SetupRequirements {
 }
 
 // GetAssetPaths returns paths for cached assets without downloading.
-// Returns nil paths for assets that don't exist.
+// Returns nil paths for assets that don't exist. The paths point into the
+// cache shared by every VM of the distro, and VMs boot from them in place.
 // Uses parallel file checks for better warm path performance.
 func (m *AssetManager) GetAssetPaths() (*AssetPaths, error) {
 	arch := distro.CurrentArch()
@@ -225,7 +340,7 @@ func (m *AssetManager) GetAssetPaths() (*AssetPaths, error) {
 			return
 		}
 		// Fall back to other formats
-		for _, ext := range []string{".tar.gz", ".tar.xz", ".tar.zst", ".qcow2", ".img"} {
+		for _, ext := range []string{".tar.gz", ".tar.xz", ".tar.zst", ".qcow2", ".img", ".iso"} {
 			rootfsPath := filepath.Join(cacheSubdir, "rootfs"+ext)
 			if _, err := os.Stat(rootfsPath); err == nil {
 				mu.Lock()
@@ -263,6 +378,12 @@ func (m *AssetManager) AssetsExist() (bool, error) {
 		if urls.Rootfs != "" && paths.Rootfs == "" {
 			return false, nil
 		}
+		if len(locator.InitConfigPatterns) > 0 {
+			initArg := filepath.Join(m.cacheDir, m.provider.CacheSubdir(distro.CurrentArch()), initArgFile)
+			if _, err := os.Stat(initArg); err != nil {
+				return false, nil
+			}
+		}
 	} else {
 		// For direct download distros, check URLs vs paths
 		if urls.Kernel != "" && paths.Kernel == "" {
@@ -276,12 +397,63 @@ func (m *AssetManager) AssetsExist() (bool, error) {
 		}
 	}
 
+	// UEFI boots the converted disk image
+	if m.provider.BootConfig(distro.CurrentArch()).UEFI && filepath.Ext(paths.Rootfs) != ".raw" {
+		return false, nil
+	}
+
+	// An asset that changed since it was cached is fetched again
+	for _, p := range []string{paths.Kernel, paths.Initramfs, paths.Rootfs} {
+		if p != "" && checkAsset(p) != nil {
+			return false, nil
+		}
+	}
+
 	return true, nil
 }
 
-func (m *AssetManager) ensureFile(path, url string) error {
+// KeysDir returns the directory of release keys installed by the user for
+// cacheDir: "keys" next to it, i.e. ~/.vmterminal/keys. The key of a
+// distro is <id>.asc there.
+func KeysDir(cacheDir string) string {
+	return filepath.Join(filepath.Dir(cacheDir), "keys")
+}
+
+// rootfsSignature returns the signature check for the rootfs, or nil if the
+// provider publishes none or verification is disabled. A provider that
+// publishes signatures without a key of its own is checked against the key
+// installed in KeysDir; if there is none, a warning says the download is
+// not verified.
+func (m *AssetManager) rootfsSignature(urls *distro.AssetURLs) *signatureCheck {
+	if m.skipVerify || urls.SignatureURL == "" {
+		return nil
+	}
+	key := urls.GPGKey
+	if key == "" && m.provider != nil {
+		path := filepath.Join(KeysDir(m.cacheDir), string(m.provider.ID())+".asc")
+		data, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: not verifying the %s download: no release key at %s\n", m.provider.Name(), path)
+			return nil
+		}
+		key = string(data)
+	}
+	if key == "" {
+		return nil
+	}
+	return &signatureCheck{URL: urls.SignatureURL, Key: key}
+}
+
+// ensureFile downloads url to path unless it is already cached. If sig is
+// non-nil the download is checked against it and removed if it does not match.
+func (m *AssetManager) ensureFile(path, url string, sig *signatureCheck) error {
 	if _, err := os.Stat(path); err == nil {
-		return nil // Already exists
+		if checkAsset(path) == nil {
+			return nil // Already exists
+		}
+		if err := m.cas.Discard(path); err != nil {
+			return fmt.Errorf("remove corrupt %s: %w", filepath.Base(path), err)
+		}
 	}
 
 	// Handle iso: URL scheme for extracting files from ISOs
@@ -289,32 +461,66 @@ func (m *AssetManager) ensureFile(path, url string) error {
 	if strings.HasPrefix(url, "iso:") {
 		return m.ensureFileFromISO(path, url)
 	}
+	if strings.HasPrefix(url, "tar:") {
+		return m.ensureFileFromTar(path, url)
+	}
 
-	resp, err := http.Get(url)
+	if sig == nil {
+		if err := m.downloadFile(path, url); err != nil {
+			return err
+		}
+		return m.store(path)
+	}
+
+	// Verify under a temporary name, so an unverified download is never
+	// mistaken for a cached one, even if vmterminal is killed meanwhile
+	unverified, err := createTemp(path)
 	if err != nil {
 		return err
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("download failed: %s (URL: %s)", resp.Status, url)
+	unverified.Close()
+	defer os.Remove(unverified.Name()) // No-op once renamed
+	if err := m.downloadFile(unverified.Name(), url); err != nil {
+		return err
 	}
 
-	// Write to temp file first, then rename for atomicity
-	tmpPath := path + ".tmp"
-	f, err := os.Create(tmpPath)
+	sigFile, err := createTemp(path + ".sig")
 	if err != nil {
 		return err
 	}
+	sigFile.Close()
+	defer os.Remove(sigFile.Name())
+	if err := m.downloadFile(sigFile.Name(), sig.URL); err != nil {
+		return fmt.Errorf("download signature: %w", err)
+	}
+	if err := VerifyDetachedSignature(unverified.Name(), sigFile.Name(), sig.Key); err != nil {
+		return fmt.Errorf("verify %s: %w (the download was removed; use --skip-verify to bypass)", filepath.Base(url), err)
+	}
+	if err := os.Rename(unverified.Name(), path); err != nil {
+		return err
+	}
+	return m.store(path)
+}
 
-	_, err = io.Copy(f, resp.Body)
-	f.Close()
-	if err != nil {
-		os.Remove(tmpPath)
+// ensureRootfs downloads the rootfs of urls to path, from RootfsFallback if
+// Rootfs is not found.
+func (m *AssetManager) ensureRootfs(path string, urls *distro.AssetURLs) error {
+	err := m.ensureFile(path, urls.Rootfs, m.rootfsSignature(urls))
+	var download *distro.ErrAssetDownloadFailed
+	if urls.RootfsFallback == "" || !errors.As(err, &download) || download.StatusCode != http.StatusNotFound {
 		return err
 	}
+	return m.ensureFile(path, urls.RootfsFallback, m.rootfsSignature(urls))
+}
 
-	return os.Rename(tmpPath, path)
+// store moves a finished download into the content store, leaving a
+// symlink to it at path, and records the checksum Add computed.
+func (m *AssetManager) store(path string) error {
+	sum, err := m.cas.Add(path)
+	if err != nil {
+		return fmt.Errorf("store %s: %w", filepath.Base(path), err)
+	}
+	return recordAsset(path, sum)
 }
 
 // ensureFileFromISO extracts a file from an ISO image.
@@ -337,15 +543,75 @@ func (m *AssetManager) ensureFileFromISO(destPath, isoURL string) error {
 	}
 
 	if _, err := os.Stat(isoPath); os.IsNotExist(err) {
-		fmt.Printf("Downloading ISO: %s\n", filepath.Base(isoDownloadURL))
 		if err := m.downloadFile(isoPath, isoDownloadURL); err != nil {
 			return fmt.Errorf("download ISO: %w", err)
 		}
+		if err := m.store(isoPath); err != nil {
+			return err
+		}
 	}
 
 	// Extract the file from ISO using bsdtar (preferred) or 7z
-	fmt.Printf("Extracting %s from ISO...\n", filepath.Base(pathInISO))
+	m.prog.Start(fmt.Sprintf("Extracting %s from ISO", filepath.Base(pathInISO)), 0)
+	err := m.extractFromISO(isoPath, pathInISO, destPath)
+	if err != nil {
+		m.prog.Error(err)
+		return err
+	}
+	m.prog.Complete()
+	return recordAsset(destPath, "")
+}
 
+// parseTarURL splits a tar:<tarball-url>#<path-in-tarball> URL.
+func parseTarURL(url string) (archiveURL, member string, ok bool) {
+	if !strings.HasPrefix(url, "tar:") {
+		return "", "", false
+	}
+	archiveURL, member, ok = strings.Cut(strings.TrimPrefix(url, "tar:"), "#")
+	return archiveURL, member, ok && archiveURL != "" && member != ""
+}
+
+// ensureFileFromTar extracts a file from a tarball, downloading the
+// tarball to the cache first if needed.
+// URL format: tar:<tarball-url>#<path-in-tarball>
+func (m *AssetManager) ensureFileFromTar(destPath, tarURL string) error {
+	archiveURL, member, ok := parseTarURL(tarURL)
+	if !ok {
+		return fmt.Errorf("invalid tar URL format: %s (expected tar:<url>#<path>)", tarURL)
+	}
+
+	archivePath := filepath.Join(m.cacheDir, "tar", filepath.Base(archiveURL))
+	if err := os.MkdirAll(filepath.Dir(archivePath), 0755); err != nil {
+		return fmt.Errorf("create tar cache dir: %w", err)
+	}
+	if _, err := os.Stat(archivePath); os.IsNotExist(err) {
+		if err := m.downloadFile(archivePath, archiveURL); err != nil {
+			return fmt.Errorf("download tarball: %w", err)
+		}
+		if err := m.store(archivePath); err != nil {
+			return err
+		}
+	}
+
+	return m.extractFromTarball(archivePath, member, destPath)
+}
+
+// extractFromTarball extracts member from the tarball at archivePath.
+func (m *AssetManager) extractFromTarball(archivePath, member, destPath string) error {
+	if _, err := os.Stat(destPath); err == nil {
+		return nil // Already extracted
+	}
+	m.prog.Start(fmt.Sprintf("Extracting %s from %s", filepath.Base(member), filepath.Base(archivePath)), 0)
+	if err := ExtractFile(archivePath, member, destPath); err != nil {
+		m.prog.Error(err)
+		return err
+	}
+	m.prog.Complete()
+	return recordAsset(destPath, "")
+}
+
+// extractFromISO extracts pathInISO with whichever extraction tool is installed.
+func (m *AssetManager) extractFromISO(isoPath, pathInISO, destPath string) error {
 	// Try bsdtar first (available on most Linux systems)
 	if _, err := exec.LookPath("bsdtar"); err == nil {
 		return m.extractWithBsdtar(isoPath, pathInISO, destPath)
@@ -366,12 +632,12 @@ func (m *AssetManager) extractWithBsdtar(isoPath, pathInISO, destPath string) er
 	// Path in ISO typically starts with / which bsdtar expects without leading /
 	isoInternalPath := strings.TrimPrefix(pathInISO, "/")
 
-	tmpPath := destPath + ".tmp"
-	outFile, err := os.Create(tmpPath)
+	outFile, err := createTemp(destPath)
 	if err != nil {
 		return fmt.Errorf("create temp file: %w", err)
 	}
 	defer outFile.Close()
+	tmpPath := outFile.Name()
 
 	cmd := exec.Command("bsdtar", "-xOf", isoPath, isoInternalPath)
 	cmd.Stdout = outFile
@@ -410,32 +676,159 @@ func (m *AssetManager) extractWith7z(isoPath, pathInISO, destPath string) error
 	return os.Rename(extractedPath, destPath)
 }
 
-// downloadFile downloads a URL to a local path.
+// mirrorCounter rotates mirroredURL through its mirrors.
+var mirrorCounter atomic.Uint64
+
+// mirroredURL returns originalURL as served by the next of mirrors, taken
+// in round-robin order. It returns originalURL when there are no mirrors
+// or the URLs cannot be combined.
+func mirroredURL(originalURL string, mirrors []string) string {
+	if len(mirrors) == 0 {
+		return originalURL
+	}
+	mirror := mirrors[(mirrorCounter.Add(1)-1)%uint64(len(mirrors))]
+	return MirrorURL(originalURL, mirror)
+}
+
+// MirrorURL returns originalURL with its scheme and host replaced by those
+// of mirror, and mirror's path, if any, prefixed to its path. It returns
+// originalURL if either cannot be parsed.
+func MirrorURL(originalURL, mirror string) string {
+	orig, err := url.Parse(originalURL)
+	if err != nil || orig.Host == "" {
+		return originalURL
+	}
+	m, err := url.Parse(mirror)
+	if err != nil || m.Host == "" {
+		return originalURL
+	}
+	orig.Scheme = m.Scheme
+	orig.Host = m.Host
+	orig.Path = strings.TrimSuffix(m.Path, "/") + orig.Path
+	orig.RawPath = ""
+	return orig.String()
+}
+
+// AssetDownloadURL returns the URL actually fetched for a provider asset
+// URL, stripping the iso: and tar: extraction wrappers.
+func AssetDownloadURL(assetURL string) string {
+	if archiveURL, _, ok := parseTarURL(assetURL); ok {
+		return archiveURL
+	}
+	if rest, ok := strings.CutPrefix(assetURL, "iso:"); ok {
+		isoURL, _, _ := strings.Cut(rest, "#")
+		return isoURL
+	}
+	return assetURL
+}
+
+// MirrorProbe is the outcome of a HEAD request for an asset on a mirror.
+type MirrorProbe struct {
+	URL     string
+	Status  int // HTTP status code, 0 if the request failed
+	Latency time.Duration
+	Err     error
+}
+
+// OK reports whether the mirror has the asset.
+func (p MirrorProbe) OK() bool {
+	return p.Err == nil && p.Status == http.StatusOK
+}
+
+// ProbeMirror sends a HEAD request for assetURL as served by mirror, or by
+// the official server if mirror is empty, and measures how long the
+// response took.
+func ProbeMirror(ctx context.Context, client *http.Client, assetURL, mirror string) MirrorProbe {
+	target := AssetDownloadURL(assetURL)
+	if mirror != "" {
+		target = MirrorURL(target, mirror)
+	}
+	probe := MirrorProbe{URL: target}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, target, nil)
+	if err != nil {
+		probe.Err = err
+		return probe
+	}
+	start := time.Now()
+	resp, err := client.Do(req)
+	probe.Latency = time.Since(start)
+	if err != nil {
+		probe.Err = err
+		return probe
+	}
+	resp.Body.Close()
+	probe.Status = resp.StatusCode
+	return probe
+}
+
+// downloadFile downloads a URL to a local path, trying each configured
+// mirror before the URL itself.
 func (m *AssetManager) downloadFile(path, url string) error {
-	resp, err := http.Get(url)
+	for range m.mirrors {
+		mirrored := mirroredURL(url, m.mirrors)
+		if mirrored == url {
+			break
+		}
+		if err := m.fetchFile(path, mirrored); err == nil {
+			return nil
+		}
+	}
+	return m.fetchFile(path, url)
+}
+
+// fetchFile downloads a URL to a local path, reporting bytes received.
+func (m *AssetManager) fetchFile(path, url string) error {
+	resp, err := m.client.Get(url)
 	if err != nil {
-		return err
+		return &distro.ErrAssetDownloadFailed{URL: url, Err: err}
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("download failed: %s (URL: %s)", resp.Status, url)
+		return &distro.ErrAssetDownloadFailed{URL: url, StatusCode: resp.StatusCode}
 	}
 
-	tmpPath := path + ".tmp"
-	f, err := os.Create(tmpPath)
+	m.prog.Start("Downloading "+filepath.Base(url), max(resp.ContentLength, 0))
+
+	// Write to temp file first, then rename for atomicity
+	f, err := createTemp(path)
 	if err != nil {
+		m.prog.Error(err)
 		return err
 	}
+	tmpPath := f.Name()
 
-	_, err = io.Copy(f, resp.Body)
+	body := newRateLimitedReader(resp.Body, m.limit)
+	_, err = io.Copy(f, progress.NewReader(body, m.prog))
 	f.Close()
 	if err != nil {
 		os.Remove(tmpPath)
+		m.prog.Error(err)
 		return err
 	}
 
-	return os.Rename(tmpPath, path)
+	if err := os.Rename(tmpPath, path); err != nil {
+		m.prog.Error(err)
+		return err
+	}
+	m.prog.Complete()
+	return nil
+}
+
+// createTemp creates a uniquely named temporary file next to path, for a
+// download or conversion that is renamed over path once complete.
+func createTemp(path string) (*os.File, error) {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return nil, err
+	}
+	if err := f.Chmod(0644); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
+	}
+	return f, nil
 }
 
 // convertQcow2ToRaw converts a qcow2 image to raw format using qemu-img.
@@ -446,7 +839,12 @@ func (m *AssetManager) convertQcow2ToRaw(qcow2Path, rawPath string) error {
 	}
 
 	// Convert to raw format
-	tmpPath := rawPath + ".tmp"
+	f, err := createTemp(rawPath)
+	if err != nil {
+		return err
+	}
+	f.Close()
+	tmpPath := f.Name()
 	cmd := exec.Command("qemu-img", "convert", "-f", "qcow2", "-O", "raw", qcow2Path, tmpPath)
 	cmd.Stderr = os.Stderr
 
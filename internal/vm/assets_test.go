@@ -0,0 +1,331 @@
+package vm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/javanstorm/vmterminal/internal/distro"
+)
+
+func TestMirrorURL(t *testing.T) {
+	const orig = "https://dl-cdn.alpinelinux.org/alpine/v3.19/releases/x86_64/rootfs.tar.gz"
+	tests := []struct {
+		mirror string
+		want   string
+	}{
+		{"https://mirror.example.com", "https://mirror.example.com/alpine/v3.19/releases/x86_64/rootfs.tar.gz"},
+		{"http://mirror.example.com:8080/", "http://mirror.example.com:8080/alpine/v3.19/releases/x86_64/rootfs.tar.gz"},
+		{"https://mirror.example.com/cdn", "https://mirror.example.com/cdn/alpine/v3.19/releases/x86_64/rootfs.tar.gz"},
+		{"not a url", orig},
+	}
+	for _, tt := range tests {
+		if got := MirrorURL(orig, tt.mirror); got != tt.want {
+			t.Errorf("MirrorURL(%q) = %q, want %q", tt.mirror, got, tt.want)
+		}
+	}
+}
+
+func TestMirroredURLRoundRobin(t *testing.T) {
+	mirrors := []string{"https://a.example.com", "https://b.example.com"}
+	first := mirroredURL("https://cdn.example.org/file", mirrors)
+	second := mirroredURL("https://cdn.example.org/file", mirrors)
+	third := mirroredURL("https://cdn.example.org/file", mirrors)
+
+	if first == second {
+		t.Errorf("consecutive calls used the same mirror: %s", first)
+	}
+	if first != third {
+		t.Errorf("third call = %s, want %s after wrapping around", third, first)
+	}
+	if got := mirroredURL("https://cdn.example.org/file", nil); got != "https://cdn.example.org/file" {
+		t.Errorf("no mirrors = %q, want original", got)
+	}
+}
+
+func TestAssetDownloadURL(t *testing.T) {
+	tests := map[string]string{
+		"https://example.com/rootfs.tar.gz":            "https://example.com/rootfs.tar.gz",
+		"tar:https://example.com/boot.tar.gz#vmlinuz":  "https://example.com/boot.tar.gz",
+		"iso:https://example.com/netboot.iso#/vmlinuz": "https://example.com/netboot.iso",
+	}
+	for in, want := range tests {
+		if got := AssetDownloadURL(in); got != want {
+			t.Errorf("AssetDownloadURL(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestDownloadFileMirrorFallback(t *testing.T) {
+	var mu sync.Mutex
+	var hits []string
+	record := func(name string, status int) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			hits = append(hits, name+r.URL.Path)
+			mu.Unlock()
+			w.WriteHeader(status)
+			w.Write([]byte(name))
+		})
+	}
+	broken := httptest.NewServer(record("broken", http.StatusNotFound))
+	defer broken.Close()
+	official := httptest.NewServer(record("official", http.StatusOK))
+	defer official.Close()
+
+	m := NewAssetManager(t.TempDir(), nil, nil).WithMirrors([]string{broken.URL})
+	dst := filepath.Join(t.TempDir(), "rootfs")
+	if err := m.downloadFile(dst, official.URL+"/alpine/rootfs.tar.gz"); err != nil {
+		t.Fatalf("downloadFile: %v", err)
+	}
+
+	data, err := os.ReadFile(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "official" {
+		t.Errorf("downloaded %q, want the official copy", data)
+	}
+	want := []string{"broken/alpine/rootfs.tar.gz", "official/alpine/rootfs.tar.gz"}
+	if len(hits) != 2 || hits[0] != want[0] || hits[1] != want[1] {
+		t.Errorf("requests = %v, want %v", hits, want)
+	}
+}
+
+func TestFetchFileHTTPError(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+
+	url := srv.URL + "/alpine/rootfs.tar.gz"
+	err := NewAssetManager(t.TempDir(), nil, nil).fetchFile(filepath.Join(t.TempDir(), "rootfs"), url)
+	var download *distro.ErrAssetDownloadFailed
+	if !errors.As(err, &download) {
+		t.Fatalf("fetchFile = %v, want ErrAssetDownloadFailed", err)
+	}
+	if download.URL != url || download.StatusCode != http.StatusNotFound {
+		t.Errorf("ErrAssetDownloadFailed = %+v, want HTTP 404 for %s", download, url)
+	}
+}
+
+func TestEnsureRootfsFallback(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/vault/rocky.qcow2" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("archived image"))
+	}))
+	defer srv.Close()
+
+	cacheDir := t.TempDir()
+	path := filepath.Join(cacheDir, "rocky", "rootfs.qcow2")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	urls := &distro.AssetURLs{Rootfs: srv.URL + "/pub/rocky.qcow2", RootfsFallback: srv.URL + "/vault/rocky.qcow2"}
+	if err := NewAssetManager(cacheDir, nil, nil).ensureRootfs(path, urls); err != nil {
+		t.Fatalf("ensureRootfs: %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "archived image" {
+		t.Errorf("rootfs = %q, want the archived image", data)
+	}
+
+	// Without a fallback the 404 is reported
+	urls.RootfsFallback = ""
+	err := NewAssetManager(t.TempDir(), nil, nil).ensureRootfs(filepath.Join(t.TempDir(), "rootfs.qcow2"), urls)
+	var download *distro.ErrAssetDownloadFailed
+	if !errors.As(err, &download) || download.StatusCode != http.StatusNotFound {
+		t.Errorf("ensureRootfs without fallback = %v, want HTTP 404", err)
+	}
+}
+
+func TestProbeMirror(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodHead {
+			t.Errorf("method = %s, want HEAD", r.Method)
+		}
+		if r.URL.Path != "/alpine/rootfs.tar.gz" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	probe := ProbeMirror(context.Background(), srv.Client(), "https://cdn.example.org/alpine/rootfs.tar.gz", srv.URL)
+	if !probe.OK() {
+		t.Errorf("probe = %+v, want OK", probe)
+	}
+	if probe.URL != srv.URL+"/alpine/rootfs.tar.gz" {
+		t.Errorf("probed %s", probe.URL)
+	}
+
+	probe = ProbeMirror(context.Background(), srv.Client(), "https://cdn.example.org/missing", srv.URL)
+	if probe.OK() || probe.Status != http.StatusNotFound {
+		t.Errorf("missing asset probe = %+v, want 404", probe)
+	}
+}
+
+func TestEnsureFileContentStore(t *testing.T) {
+	downloads := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		downloads++
+		w.Write([]byte("rootfs"))
+	}))
+	defer srv.Close()
+
+	cacheDir := filepath.Join(t.TempDir(), "cache")
+	dir := filepath.Join(cacheDir, "alpine")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	m := NewAssetManager(cacheDir, nil, nil)
+	path := filepath.Join(dir, "rootfs.tar.gz")
+
+	if err := m.ensureFile(path, srv.URL+"/rootfs.tar.gz", nil); err != nil {
+		t.Fatalf("ensureFile: %v", err)
+	}
+	blob, ok, err := linkTarget(path)
+	if err != nil || !ok {
+		t.Fatalf("rootfs is not linked into the content store: %v", err)
+	}
+	if filepath.Dir(filepath.Dir(blob)) != ContentStoreDir(cacheDir) {
+		t.Errorf("blob %s is outside %s", blob, ContentStoreDir(cacheDir))
+	}
+
+	// Cached and intact: no second download
+	if err := m.ensureFile(path, srv.URL+"/rootfs.tar.gz", nil); err != nil || downloads != 1 {
+		t.Fatalf("ensureFile again: %v, %d downloads", err, downloads)
+	}
+
+	// Corrupt: downloaded again
+	if err := os.WriteFile(blob, []byte("bit rot"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.ensureFile(path, srv.URL+"/rootfs.tar.gz", nil); err != nil {
+		t.Fatalf("ensureFile after corruption: %v", err)
+	}
+	if downloads != 2 {
+		t.Errorf("downloads = %d, want the corrupt file fetched again", downloads)
+	}
+	if err := m.cas.Verify(path); err != nil {
+		t.Errorf("Verify after re-download: %v", err)
+	}
+}
+
+func TestVerifyAssets(t *testing.T) {
+	provider, err := distro.GetDefault()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cacheDir := t.TempDir()
+	m := NewAssetManager(cacheDir, provider, nil)
+	dir := filepath.Join(cacheDir, provider.CacheSubdir(distro.CurrentArch()))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	// Extracted files are regular files, outside the content store
+	paths := &AssetPaths{Kernel: filepath.Join(dir, "vmlinuz"), Initramfs: filepath.Join(dir, "initramfs")}
+	os.WriteFile(paths.Kernel, []byte("kernel"), 0644)
+	os.WriteFile(paths.Initramfs, []byte("initramfs"), 0644)
+
+	// Nothing recorded yet: regular files are trusted
+	if ok, corrupt, err := m.VerifyAssets(); err != nil || !ok {
+		t.Fatalf("VerifyAssets without checksums = %v, %v, %v", ok, corrupt, err)
+	}
+
+	for _, p := range []string{paths.Kernel, paths.Initramfs} {
+		if err := recordAsset(p, ""); err != nil {
+			t.Fatalf("recordAsset: %v", err)
+		}
+	}
+	if ok, corrupt, err := m.VerifyAssets(); err != nil || !ok {
+		t.Fatalf("VerifyAssets = %v, %v, %v", ok, corrupt, err)
+	}
+
+	os.WriteFile(paths.Kernel, []byte("kernel, half written"), 0644)
+	ok, corrupt, err := m.VerifyAssets()
+	if err != nil {
+		t.Fatalf("VerifyAssets: %v", err)
+	}
+	if ok || len(corrupt) != 1 || corrupt[0] != paths.Kernel {
+		t.Errorf("VerifyAssets = %v, %v, want the kernel corrupt", ok, corrupt)
+	}
+}
+
+func TestCheckAsset(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "vmlinuz")
+	if err := os.WriteFile(path, []byte("kernel"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Assets cached before records were kept only need to exist
+	if err := checkAsset(path); err != nil {
+		t.Fatalf("checkAsset without a record = %v", err)
+	}
+	if err := checkAsset(filepath.Join(dir, "initramfs")); err == nil {
+		t.Error("checkAsset on a missing file should fail")
+	}
+
+	if err := recordAsset(path, ""); err != nil {
+		t.Fatalf("recordAsset: %v", err)
+	}
+	if err := checkAsset(path); err != nil {
+		t.Fatalf("checkAsset after recordAsset = %v", err)
+	}
+
+	// A file rewritten after it was recorded no longer matches
+	if err := os.WriteFile(path, []byte("kernel, half written"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkAsset(path); err == nil {
+		t.Error("checkAsset should fail once the size changes")
+	}
+
+	// Records written before sizes were kept still load
+	legacy := filepath.Join(dir, AssetChecksumsFile)
+	if err := os.WriteFile(legacy, []byte(`{"vmlinuz": "abc"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	records, err := loadChecksums(legacy)
+	if err != nil || records["vmlinuz"].SHA256 != "abc" {
+		t.Fatalf("loadChecksums(legacy) = %v, %v", records, err)
+	}
+	if err := checkAsset(path); err != nil {
+		t.Errorf("checkAsset with a legacy record = %v", err)
+	}
+}
+
+func TestBootConfigInitArg(t *testing.T) {
+	provider := distro.NewNixOSProvider()
+	cacheDir := t.TempDir()
+	m := NewAssetManager(cacheDir, provider, nil)
+
+	before := m.BootConfig().Cmdline
+	if strings.Contains(before, "init=") {
+		t.Fatalf("Cmdline has init= before extraction: %q", before)
+	}
+
+	cfg := []byte("LABEL boot\n  LINUX /boot/bzImage\n  APPEND init=/nix/store/abc-nixos-system-nixos-24.11/init root=LABEL=nixos-minimal boot.shell_on_fail\n")
+	init := bootInitPattern.FindSubmatch(cfg)
+	if init == nil {
+		t.Fatal("init= not found in bootloader config")
+	}
+	dir := filepath.Join(cacheDir, provider.CacheSubdir(distro.CurrentArch()))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, initArgFile), init[1], 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	want := before + " init=/nix/store/abc-nixos-system-nixos-24.11/init"
+	if got := m.BootConfig().Cmdline; got != want {
+		t.Errorf("Cmdline = %q, want %q", got, want)
+	}
+}
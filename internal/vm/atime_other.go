@@ -0,0 +1,14 @@
+//go:build !linux && !darwin
+
+package vm
+
+import (
+	"os"
+	"time"
+)
+
+// accessTime returns the modification time: access times are not read on
+// this platform.
+func accessTime(info os.FileInfo) time.Time {
+	return info.ModTime()
+}
@@ -0,0 +1,179 @@
+package vm
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// BenchHistoryFile is the file in the base directory that every
+// 'vmterminal bench' result is appended to.
+const BenchHistoryFile = "bench_history.jsonl"
+
+// BenchMemoryCommand copies 1 GiB through memory in the guest. Its output
+// is parsed by ParseDDThroughput.
+const BenchMemoryCommand = "dd if=/dev/zero of=/dev/null bs=1M count=1024 2>&1"
+
+// BenchCPUCommand returns the sysbench CPU benchmark command, run for d.
+// The options are the ones sysbench 0.4 understands, which later versions
+// still accept. Its output is parsed by ParseSysbenchCPU.
+func BenchCPUCommand(d time.Duration) string {
+	return fmt.Sprintf("sysbench --test=cpu --max-requests=0 --max-time=%d run 2>&1", int(d.Seconds()))
+}
+
+// BenchResult is one run of 'vmterminal bench'.
+type BenchResult struct {
+	Time       time.Time     `json:"time"`
+	VM         string        `json:"vm"`
+	Distro     string        `json:"distro"`
+	Hypervisor string        `json:"hypervisor"`
+	Arch       string        `json:"arch"`
+	CPUs       int           `json:"cpus"`
+	MemoryMB   int           `json:"memory_mb"`
+	BootTime   time.Duration `json:"boot_time_ns"`
+
+	// MemoryMBps is the dd copy rate in MB/s.
+	MemoryMBps float64 `json:"memory_mbps"`
+
+	// CPUEventsPerSec is sysbench's CPU score, or 0 when sysbench is not
+	// installed in the guest.
+	CPUEventsPerSec float64 `json:"cpu_events_per_sec,omitempty"`
+}
+
+// BenchBaseline holds the best scores recorded for a hypervisor and
+// architecture, to tell a misconfigured VM from a healthy one on the same
+// host: memory bandwidth in MB/s for BenchMemoryCommand, and sysbench CPU
+// events per second, 0 if no run had sysbench.
+type BenchBaseline struct {
+	MemoryMBps      float64 `json:"memory_mbps"`
+	CPUEventsPerSec float64 `json:"cpu_events_per_sec"`
+}
+
+// BenchBaselineFrom returns the best scores in history for a hypervisor
+// driver name and architecture, as reported by hypervisor.Info. It reports
+// false if history has no result for them.
+func BenchBaselineFrom(history []BenchResult, hypervisor, arch string) (BenchBaseline, bool) {
+	var b BenchBaseline
+	found := false
+	for _, r := range history {
+		if r.Hypervisor != hypervisor || r.Arch != arch {
+			continue
+		}
+		found = true
+		b.MemoryMBps = max(b.MemoryMBps, r.MemoryMBps)
+		b.CPUEventsPerSec = max(b.CPUEventsPerSec, r.CPUEventsPerSec)
+	}
+	return b, found
+}
+
+var (
+	ddBytesRe        = regexp.MustCompile(`(\d+) bytes`)
+	ddSecondsRe      = regexp.MustCompile(`copied,\s*([\d.]+)\s*s`)
+	sysbenchEPSRe    = regexp.MustCompile(`events per second:\s*([\d.]+)`)
+	sysbenchEventsRe = regexp.MustCompile(`total number of events:\s*(\d+)`)
+	sysbenchTimeRe   = regexp.MustCompile(`total time:\s*([\d.]+)s`)
+)
+
+// ParseDDThroughput returns the copy rate in MB/s from the output of dd,
+// in either the GNU or the BusyBox format. The rate is computed from the
+// bytes and seconds rather than taken from dd's rounded figure.
+func ParseDDThroughput(output string) (float64, error) {
+	b := ddBytesRe.FindStringSubmatch(output)
+	s := ddSecondsRe.FindStringSubmatch(output)
+	if b == nil || s == nil {
+		return 0, fmt.Errorf("no dd summary in output")
+	}
+	bytes, err := strconv.ParseFloat(b[1], 64)
+	if err != nil {
+		return 0, fmt.Errorf("parse dd bytes: %w", err)
+	}
+	seconds, err := strconv.ParseFloat(s[1], 64)
+	if err != nil {
+		return 0, fmt.Errorf("parse dd time: %w", err)
+	}
+	if seconds <= 0 {
+		return 0, fmt.Errorf("dd finished too quickly to measure")
+	}
+	return bytes / seconds / 1e6, nil
+}
+
+// ParseSysbenchCPU returns the events per second from the output of the
+// sysbench CPU benchmark. sysbench 0.4 does not print the rate, so it is
+// computed from the total events and time.
+func ParseSysbenchCPU(output string) (float64, error) {
+	if m := sysbenchEPSRe.FindStringSubmatch(output); m != nil {
+		eps, err := strconv.ParseFloat(m[1], 64)
+		if err != nil {
+			return 0, fmt.Errorf("parse sysbench score: %w", err)
+		}
+		return eps, nil
+	}
+
+	e := sysbenchEventsRe.FindStringSubmatch(output)
+	t := sysbenchTimeRe.FindStringSubmatch(output)
+	if e == nil || t == nil {
+		return 0, fmt.Errorf("no events per second in sysbench output")
+	}
+	events, err := strconv.ParseFloat(e[1], 64)
+	if err != nil {
+		return 0, fmt.Errorf("parse sysbench events: %w", err)
+	}
+	seconds, err := strconv.ParseFloat(t[1], 64)
+	if err != nil || seconds <= 0 {
+		return 0, fmt.Errorf("parse sysbench time %q", t[1])
+	}
+	return events / seconds, nil
+}
+
+// AppendBenchResult adds r as a JSON line to the history file at path,
+// creating it and its directory if needed.
+func AppendBenchResult(path string, r BenchResult) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("create bench history dir: %w", err)
+	}
+	data, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("marshal bench result: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("open bench history: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("write bench history: %w", err)
+	}
+	return nil
+}
+
+// LoadBenchHistory reads the results in the history file at path, oldest
+// first. A missing file gives no results; malformed lines are skipped.
+func LoadBenchHistory(path string) ([]BenchResult, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("open bench history: %w", err)
+	}
+	defer f.Close()
+
+	var results []BenchResult
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var r BenchResult
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			continue
+		}
+		results = append(results, r)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read bench history: %w", err)
+	}
+	return results, nil
+}
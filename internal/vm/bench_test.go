@@ -0,0 +1,109 @@
+package vm
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseDDThroughput(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   float64
+	}{
+		{
+			name: "GNU",
+			output: "1024+0 records in\n1024+0 records out\n" +
+				"1073741824 bytes (1.1 GB, 1.0 GiB) copied, 0.25 s, 4.3 GB/s\n",
+			want: 1073741824 / 0.25 / 1e6,
+		},
+		{
+			name: "BusyBox",
+			output: "1024+0 records in\n1024+0 records out\n" +
+				"1073741824 bytes (1.0GB) copied, 0.500000 seconds, 2.0GB/s\n",
+			want: 1073741824 / 0.5 / 1e6,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseDDThroughput(tt.output)
+			if err != nil {
+				t.Fatalf("ParseDDThroughput: %v", err)
+			}
+			if math.Abs(got-tt.want) > 0.01 {
+				t.Errorf("ParseDDThroughput = %f, want %f", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := ParseDDThroughput("dd: can't open '/dev/zero'\n"); err == nil {
+		t.Error("expected error for output without a summary")
+	}
+}
+
+func TestParseSysbenchCPU(t *testing.T) {
+	v1 := `CPU speed:
+    events per second:  1234.56
+
+General statistics:
+    total time:                          30.0003s
+    total number of events:              37037
+`
+	if got, err := ParseSysbenchCPU(v1); err != nil || got != 1234.56 {
+		t.Errorf("ParseSysbenchCPU(1.x) = %f, %v, want 1234.56", got, err)
+	}
+
+	v04 := `Test execution summary:
+    total time:                          10.0000s
+    total number of events:              15000
+`
+	if got, err := ParseSysbenchCPU(v04); err != nil || got != 1500 {
+		t.Errorf("ParseSysbenchCPU(0.4) = %f, %v, want 1500", got, err)
+	}
+
+	if _, err := ParseSysbenchCPU("sysbench: not found\n"); err == nil {
+		t.Error("expected error for output without a score")
+	}
+}
+
+func TestBenchHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", BenchHistoryFile)
+
+	results, err := LoadBenchHistory(path)
+	if err != nil || len(results) != 0 {
+		t.Fatalf("LoadBenchHistory(missing) = %v, %v, want empty", results, err)
+	}
+
+	first := BenchResult{Time: time.Unix(100, 0).UTC(), VM: "dev", Hypervisor: "kvm", MemoryMBps: 5000}
+	second := BenchResult{Time: time.Unix(200, 0).UTC(), VM: "dev", Hypervisor: "kvm", MemoryMBps: 5500, CPUEventsPerSec: 1100}
+	for _, r := range []BenchResult{first, second} {
+		if err := AppendBenchResult(path, r); err != nil {
+			t.Fatalf("AppendBenchResult: %v", err)
+		}
+	}
+
+	results, err = LoadBenchHistory(path)
+	if err != nil {
+		t.Fatalf("LoadBenchHistory: %v", err)
+	}
+	if len(results) != 2 || results[0] != first || results[1] != second {
+		t.Errorf("LoadBenchHistory = %+v, want %+v and %+v", results, first, second)
+	}
+}
+
+func TestBenchBaselineFrom(t *testing.T) {
+	history := []BenchResult{
+		{Hypervisor: "kvm", Arch: "amd64", MemoryMBps: 5000, CPUEventsPerSec: 900},
+		{Hypervisor: "kvm", Arch: "amd64", MemoryMBps: 6000},
+		{Hypervisor: "kvm", Arch: "arm64", MemoryMBps: 9000, CPUEventsPerSec: 3000},
+	}
+	b, ok := BenchBaselineFrom(history, "kvm", "amd64")
+	if want := (BenchBaseline{MemoryMBps: 6000, CPUEventsPerSec: 900}); !ok || b != want {
+		t.Errorf("BenchBaselineFrom(kvm, amd64) = %+v, %v; want %+v, true", b, ok, want)
+	}
+	if _, ok := BenchBaselineFrom(history, "vz", "arm64"); ok {
+		t.Error("expected no baseline without results for the hypervisor")
+	}
+}
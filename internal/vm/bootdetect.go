@@ -0,0 +1,167 @@
+package vm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sync/atomic"
+	"time"
+)
+
+// kernelVersionPattern matches the banner the kernel prints early in
+// boot, e.g. "Linux version 6.6.58-0-virt (buildozer@...) ...". The
+// trailing space ensures the version was read in full.
+var kernelVersionPattern = regexp.MustCompile(`Linux version (\S+)\s`)
+
+// kernelBannerWindow is how much console output is kept between reads so
+// a banner or prompt split across two reads still matches.
+const kernelBannerWindow = 256
+
+// loginPromptPattern matches a getty login prompt, e.g. "alpine login: ".
+var loginPromptPattern = regexp.MustCompile(`\slogin: ?$`)
+
+// WaitForKernelVersion reads console output from reader until the kernel
+// prints its version banner, and returns the version. It gives up when
+// timeout passes, ctx is done or reader ends. On timeout or cancellation
+// the read in progress is abandoned; close reader to release it.
+func WaitForKernelVersion(ctx context.Context, reader io.Reader, timeout time.Duration) (string, error) {
+	m, err := waitForConsole(ctx, reader, timeout, kernelVersionPattern, "kernel version")
+	if err != nil {
+		return "", err
+	}
+	return string(m[1]), nil
+}
+
+// WaitForLoginPrompt reads console output from reader until a login prompt
+// ends it, which marks the end of the boot. It gives up like
+// WaitForKernelVersion.
+func WaitForLoginPrompt(ctx context.Context, reader io.Reader, timeout time.Duration) error {
+	_, err := waitForConsole(ctx, reader, timeout, loginPromptPattern, "login prompt")
+	return err
+}
+
+// waitForConsole reads reader until pattern matches and returns the
+// submatches. what names the match in errors.
+func waitForConsole(ctx context.Context, reader io.Reader, timeout time.Duration, pattern *regexp.Regexp, what string) ([][]byte, error) {
+	type result struct {
+		match [][]byte
+		err   error
+	}
+	found := make(chan result, 1)
+	go func() {
+		m, err := scanConsole(reader, pattern, what)
+		found <- result{m, err}
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case r := <-found:
+		return r.match, r.err
+	case <-timer.C:
+		return nil, fmt.Errorf("no %s on the console after %s", what, timeout)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// scanConsole reads r until pattern matches the output read so far.
+func scanConsole(r io.Reader, pattern *regexp.Regexp, what string) ([][]byte, error) {
+	var window []byte
+	buf := make([]byte, 4096)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			window = append(window, buf[:n]...)
+			if m := pattern.FindSubmatch(window); m != nil {
+				return m, nil
+			}
+			if len(window) > kernelBannerWindow {
+				window = append(window[:0], window[len(window)-kernelBannerWindow:]...)
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("console closed before the %s was printed", what)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// dhcpLeasePattern matches the line a guest DHCP client prints when it
+// gets an address and captures the address, e.g. udhcpc's "lease of
+// 10.0.2.15 obtained", dhclient's "bound to 10.0.2.15", dhcpcd's "leased
+// 10.0.2.15 for 86400 seconds", systemd-networkd's "DHCPv4 address
+// 10.0.2.15/24" or NetworkManager's "dhcp4 (eth0): address 10.0.2.15". The
+// trailing character ensures the address was read in full.
+var dhcpLeasePattern = regexp.MustCompile(`(?:DHCP lease acquired\D*|lease of|bound to|leased|DHCPv4 address|dhcp4 \([^)]*\): address)\s+(\d{1,3}(?:\.\d{1,3}){3})[^\d.]`)
+
+// linkReadyPattern matches the kernel's message that a network link is up,
+// e.g. "IPv6: ADDRCONF(NETDEV_CHANGE): eth0: link becomes ready".
+var linkReadyPattern = regexp.MustCompile(`\S+: link becomes ready`)
+
+// WaitForNetworkReady reads console output from reader until a DHCP client
+// reports its lease, and returns the leased IPv4 address. If only the
+// kernel's "link becomes ready" message was seen when timeout passes or
+// reader ends, the network is taken to be up and the address is empty.
+// Otherwise it gives up like WaitForKernelVersion.
+func WaitForNetworkReady(ctx context.Context, reader io.Reader, timeout time.Duration) (ip string, err error) {
+	var linkUp atomic.Bool
+	type result struct {
+		ip  string
+		err error
+	}
+	found := make(chan result, 1)
+	go func() {
+		ip, err := scanNetwork(reader, &linkUp)
+		found <- result{ip, err}
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case r := <-found:
+		if r.err != nil && linkUp.Load() {
+			return "", nil
+		}
+		return r.ip, r.err
+	case <-timer.C:
+		if linkUp.Load() {
+			return "", nil
+		}
+		return "", fmt.Errorf("no network link on the console after %s", timeout)
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// scanNetwork reads r until a DHCP lease is printed, setting linkUp once
+// a link comes up.
+func scanNetwork(r io.Reader, linkUp *atomic.Bool) (string, error) {
+	var window []byte
+	buf := make([]byte, 4096)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			window = append(window, buf[:n]...)
+			if linkReadyPattern.Match(window) {
+				linkUp.Store(true)
+			}
+			if m := dhcpLeasePattern.FindSubmatch(window); m != nil {
+				return string(m[1]), nil
+			}
+			if len(window) > kernelBannerWindow {
+				window = append(window[:0], window[len(window)-kernelBannerWindow:]...)
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return "", fmt.Errorf("console closed before the network came up")
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+}
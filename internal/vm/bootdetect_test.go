@@ -0,0 +1,116 @@
+package vm
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+const alpineBootLog = "[    0.000000] Booting Linux on physical CPU 0x0000000000 [0x610f0000]\r\n" +
+	"[    0.000000] Linux version 6.6.58-0-virt (buildozer@build-3-21-aarch64) (gcc (Alpine 14.2.0) 14.2.0) #1-Alpine SMP\r\n" +
+	"[    0.000000] KASLR enabled\r\n"
+
+func TestWaitForKernelVersion(t *testing.T) {
+	tests := []struct {
+		name string
+		r    io.Reader
+	}{
+		{"whole", strings.NewReader(alpineBootLog)},
+		{"byte by byte", iotest.OneByteReader(strings.NewReader(alpineBootLog))},
+		{"after lots of output", io.MultiReader(strings.NewReader(strings.Repeat("x", 10000)), strings.NewReader(alpineBootLog))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := WaitForKernelVersion(context.Background(), tt.r, time.Second)
+			if err != nil {
+				t.Fatalf("WaitForKernelVersion: %v", err)
+			}
+			if got != "6.6.58-0-virt" {
+				t.Errorf("version = %q, want 6.6.58-0-virt", got)
+			}
+		})
+	}
+}
+
+func TestWaitForKernelVersionNoBanner(t *testing.T) {
+	// The version must be followed by a space to count as complete
+	if _, err := WaitForKernelVersion(context.Background(), strings.NewReader("login: \nLinux version 6.6"), time.Second); err == nil {
+		t.Error("expected error when the console ends without a banner")
+	}
+}
+
+func TestWaitForKernelVersionTimeout(t *testing.T) {
+	pr, pw := io.Pipe()
+	defer pr.Close()
+	defer pw.Close()
+
+	start := time.Now()
+	if _, err := WaitForKernelVersion(context.Background(), pr, 50*time.Millisecond); err == nil {
+		t.Fatal("expected timeout error")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("timeout took %s", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := WaitForKernelVersion(ctx, pr, time.Minute); err != context.Canceled {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+}
+
+func TestWaitForLoginPrompt(t *testing.T) {
+	log := alpineBootLog + "\r\nWelcome to Alpine Linux 3.21\r\nKernel 6.6.58-0-virt on an aarch64 (/dev/ttyAMA0)\r\n\r\nalpine login: "
+	if err := WaitForLoginPrompt(context.Background(), iotest.OneByteReader(strings.NewReader(log)), time.Second); err != nil {
+		t.Errorf("WaitForLoginPrompt: %v", err)
+	}
+	if err := WaitForLoginPrompt(context.Background(), strings.NewReader(alpineBootLog), time.Second); err == nil {
+		t.Error("expected error when the console ends without a login prompt")
+	}
+}
+
+func TestWaitForNetworkReady(t *testing.T) {
+	tests := map[string]string{
+		"udhcpc":           "udhcpc: sending select for 10.0.2.15\r\nudhcpc: lease of 10.0.2.15 obtained from 10.0.2.2, lease time 86400\r\n",
+		"dhclient":         "DHCPACK of 10.0.2.15 from 10.0.2.2\r\nbound to 10.0.2.15 -- renewal in 41562 seconds.\r\n",
+		"dhcpcd":           "eth0: leased 10.0.2.15 for 86400 seconds\r\n",
+		"systemd-networkd": "systemd-networkd[312]: eth0: DHCPv4 address 10.0.2.15/24, gateway 10.0.2.2 acquired from 10.0.2.2\r\n",
+		"NetworkManager":   "NetworkManager[701]: <info>  [1700000000.1234] dhcp4 (eth0): address 10.0.2.15\r\n",
+		"generic":          "DHCP lease acquired: 10.0.2.15\r\n",
+	}
+	for name, log := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := iotest.OneByteReader(strings.NewReader(alpineBootLog + log))
+			ip, err := WaitForNetworkReady(context.Background(), r, time.Second)
+			if err != nil {
+				t.Fatalf("WaitForNetworkReady: %v", err)
+			}
+			if ip != "10.0.2.15" {
+				t.Errorf("ip = %q, want 10.0.2.15", ip)
+			}
+		})
+	}
+}
+
+func TestWaitForNetworkReadyLinkOnly(t *testing.T) {
+	linkUp := alpineBootLog + "[    2.104331] IPv6: ADDRCONF(NETDEV_CHANGE): eth0: link becomes ready\r\n"
+	ip, err := WaitForNetworkReady(context.Background(), strings.NewReader(linkUp), time.Second)
+	if err != nil || ip != "" {
+		t.Errorf("WaitForNetworkReady with only a link = %q, %v; want no address and no error", ip, err)
+	}
+
+	pr, pw := io.Pipe()
+	defer pr.Close()
+	go pw.Write([]byte(linkUp))
+	ip, err = WaitForNetworkReady(context.Background(), pr, 100*time.Millisecond)
+	if err != nil || ip != "" {
+		t.Errorf("WaitForNetworkReady timing out after a link = %q, %v; want no address and no error", ip, err)
+	}
+
+	if _, err := WaitForNetworkReady(context.Background(), strings.NewReader(alpineBootLog), time.Second); err == nil {
+		t.Error("expected error when the console ends without a network")
+	}
+}
@@ -0,0 +1,82 @@
+package vm
+
+import (
+	"bytes"
+	"io"
+	"time"
+)
+
+// Boot milestones reported by BootMonitor, in the order they happen.
+const (
+	MilestoneKernelStarted = "kernel started"
+	MilestoneInitRunning   = "init running"
+	MilestoneLoginPrompt   = "login prompt"
+)
+
+// bootMilestones maps the console output that marks each milestone to it.
+// The login prompt is matched with loginPromptPattern instead.
+var bootMilestones = []struct {
+	marker []byte
+	name   string
+}{
+	{[]byte("Starting kernel"), MilestoneKernelStarted},
+	{[]byte("Run /init"), MilestoneInitRunning},
+}
+
+// BootMilestone is a point in the boot seen on the console.
+type BootMilestone struct {
+	Name    string
+	At      time.Time
+	Elapsed time.Duration // Since the boot started
+}
+
+// BootMonitor passes console output through while watching it for boot
+// milestones. Each milestone is reported once, from Read, so the callback
+// must not block for long.
+type BootMonitor struct {
+	r           io.Reader
+	start       time.Time
+	onMilestone func(BootMilestone)
+	seen        map[string]bool
+	window      []byte
+}
+
+// NewBootMonitor returns a reader of r that calls onMilestone as the boot
+// that began at start reaches each milestone.
+func NewBootMonitor(r io.Reader, start time.Time, onMilestone func(BootMilestone)) *BootMonitor {
+	return &BootMonitor{r: r, start: start, onMilestone: onMilestone, seen: make(map[string]bool)}
+}
+
+// Read reads from the console and checks the output for milestones.
+func (b *BootMonitor) Read(p []byte) (int, error) {
+	n, err := b.r.Read(p)
+	if n > 0 && !b.seen[MilestoneLoginPrompt] {
+		b.scan(p[:n])
+	}
+	return n, err
+}
+
+// scan looks for milestones in the output read so far.
+func (b *BootMonitor) scan(p []byte) {
+	b.window = append(b.window, p...)
+	for _, m := range bootMilestones {
+		if !b.seen[m.name] && bytes.Contains(b.window, m.marker) {
+			b.report(m.name)
+		}
+	}
+	if loginPromptPattern.Match(b.window) {
+		b.report(MilestoneLoginPrompt)
+		b.window = nil
+		return
+	}
+	if len(b.window) > kernelBannerWindow {
+		b.window = append(b.window[:0], b.window[len(b.window)-kernelBannerWindow:]...)
+	}
+}
+
+// report calls onMilestone for name.
+func (b *BootMonitor) report(name string) {
+	b.seen[name] = true
+	now := time.Now()
+	b.onMilestone(BootMilestone{Name: name, At: now, Elapsed: now.Sub(b.start)})
+}
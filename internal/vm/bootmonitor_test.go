@@ -0,0 +1,86 @@
+package vm
+
+import (
+	"io"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+const milestoneBootLog = "EFI stub: Booting Linux Kernel...\r\nStarting kernel ...\r\n" +
+	alpineBootLog +
+	"[    1.204113] Run /init as init process\r\n" +
+	" * Starting networking ... [ ok ]\r\n" +
+	"\r\nWelcome to Alpine Linux 3.21\r\n\r\nalpine login: "
+
+func TestBootMonitor(t *testing.T) {
+	tests := []struct {
+		name string
+		r    io.Reader
+	}{
+		{"whole", strings.NewReader(milestoneBootLog)},
+		{"byte by byte", iotest.OneByteReader(strings.NewReader(milestoneBootLog))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := time.Now()
+			var got []string
+			mon := NewBootMonitor(tt.r, start, func(m BootMilestone) {
+				if m.Elapsed < 0 || m.At.Before(start) {
+					t.Errorf("%s: Elapsed = %s, At = %s", m.Name, m.Elapsed, m.At)
+				}
+				got = append(got, m.Name)
+			})
+
+			// Output passes through untouched
+			out, err := io.ReadAll(mon)
+			if err != nil {
+				t.Fatalf("ReadAll: %v", err)
+			}
+			if string(out) != milestoneBootLog {
+				t.Error("console output was altered")
+			}
+			want := []string{MilestoneKernelStarted, MilestoneInitRunning, MilestoneLoginPrompt}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("milestones = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestBootMonitorReportsOnce(t *testing.T) {
+	log := "Run /init as init process\r\nlocalhost login: \r\nRun /init as init process\r\nlocalhost login: "
+	var got []string
+	io.Copy(io.Discard, NewBootMonitor(strings.NewReader(log), time.Now(), func(m BootMilestone) {
+		got = append(got, m.Name)
+	}))
+	want := []string{MilestoneInitRunning, MilestoneLoginPrompt}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("milestones = %v, want %v", got, want)
+	}
+}
+
+func TestRecordBootMilestone(t *testing.T) {
+	sf := NewStateFile(filepath.Join(t.TempDir(), "dev"))
+	if err := sf.RecordBootMilestone(MilestoneLoginPrompt, 8700*time.Millisecond); err != nil {
+		t.Fatalf("RecordBootMilestone: %v", err)
+	}
+	state, err := sf.Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got := state.BootMilestones[MilestoneLoginPrompt]; got != 8700*time.Millisecond {
+		t.Errorf("BootMilestones[%q] = %s, want 8.7s", MilestoneLoginPrompt, got)
+	}
+
+	// A new boot starts with no milestones
+	if err := sf.RecordBoot(); err != nil {
+		t.Fatalf("RecordBoot: %v", err)
+	}
+	if state, _ = sf.Load(); len(state.BootMilestones) != 0 {
+		t.Errorf("BootMilestones after RecordBoot = %v, want none", state.BootMilestones)
+	}
+}
@@ -0,0 +1,164 @@
+package vm
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/javanstorm/vmterminal/internal/config"
+)
+
+// cacheEntry is one distro version in the asset cache:
+// <cacheDir>/<distro>/<version>.
+type cacheEntry struct {
+	path     string
+	size     int64
+	lastUsed time.Time
+}
+
+// EvictOldCacheEntries removes the least recently used distro versions
+// from the asset cache at cacheDir until the assets it holds take at most
+// maxBytes, then deletes the content store files nothing links to any
+// more. An entry was last used when any of its files was last read. The
+// directories in keep, such as <cacheDir>/<distro> of the distro in use,
+// are never removed, and neither are those of the distros of the VMs
+// registered next to cacheDir: a cloud-image VM's disk may be its distro's
+// cached image. maxBytes of 0 or less means no limit.
+func EvictOldCacheEntries(cacheDir string, maxBytes int64, keep ...string) error {
+	if maxBytes <= 0 {
+		return nil
+	}
+	entries, err := cacheEntries(cacheDir)
+	if err != nil {
+		return err
+	}
+	used, err := registeredDistroDirs(cacheDir)
+	if err != nil {
+		return err
+	}
+	keep = append(keep, used...)
+
+	var total int64
+	for _, e := range entries {
+		total += e.size
+	}
+	if total <= maxBytes {
+		return nil
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].lastUsed.Before(entries[j].lastUsed)
+	})
+	evicted := false
+	for _, e := range entries {
+		if total <= maxBytes {
+			break
+		}
+		if isKept(e.path, keep) {
+			continue
+		}
+		if err := os.RemoveAll(e.path); err != nil {
+			return fmt.Errorf("evict %s: %w", e.path, err)
+		}
+		total -= e.size
+		evicted = true
+
+		// Leave no empty distro directory behind
+		os.Remove(filepath.Dir(e.path))
+	}
+
+	if evicted {
+		if _, err := NewContentStore(ContentStoreDir(cacheDir)).GC(cacheDir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// registeredDistroDirs returns <cacheDir>/<distro> for the distro of every
+// VM in the registry next to cacheDir. VMs without a distro of their own
+// use the configured one.
+func registeredDistroDirs(cacheDir string) ([]string, error) {
+	vms, err := NewRegistry(filepath.Dir(cacheDir)).ListVMs()
+	if err != nil {
+		return nil, fmt.Errorf("find the cache entries VMs use: %w", err)
+	}
+	var dirs []string
+	for _, e := range vms {
+		id := e.Distro
+		if id == "" {
+			state, err := config.LoadSavedState()
+			if err != nil {
+				return nil, fmt.Errorf("find the cache entries VMs use: %w", err)
+			}
+			id = state.Distro
+		}
+		if id != "" {
+			dirs = append(dirs, filepath.Join(cacheDir, id))
+		}
+	}
+	return dirs, nil
+}
+
+// isKept reports whether path is one of keep or inside one.
+func isKept(path string, keep []string) bool {
+	for _, k := range keep {
+		rel, err := filepath.Rel(k, path)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// cacheEntries lists the distro versions in cacheDir with the size of the
+// files they hold, following links into the content store, and when they
+// were last used.
+func cacheEntries(cacheDir string) ([]cacheEntry, error) {
+	distros, err := os.ReadDir(cacheDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read cache: %w", err)
+	}
+
+	var entries []cacheEntry
+	for _, d := range distros {
+		if !d.IsDir() {
+			continue
+		}
+		versions, err := os.ReadDir(filepath.Join(cacheDir, d.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("read cache: %w", err)
+		}
+		for _, v := range versions {
+			if !v.IsDir() {
+				continue
+			}
+			e := cacheEntry{path: filepath.Join(cacheDir, d.Name(), v.Name())}
+			err := filepath.Walk(e.path, func(path string, info os.FileInfo, err error) error {
+				if err != nil || info.IsDir() {
+					return err
+				}
+				// Stat through links to the stored blob
+				if target, err := os.Stat(path); err == nil {
+					info = target
+				}
+				e.size += info.Size()
+				if t := accessTime(info); t.After(e.lastUsed) {
+					e.lastUsed = t
+				}
+				return nil
+			})
+			if err != nil {
+				return nil, fmt.Errorf("scan %s: %w", e.path, err)
+			}
+			entries = append(entries, e)
+		}
+	}
+	return entries, nil
+}
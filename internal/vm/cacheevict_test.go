@@ -0,0 +1,117 @@
+package vm
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeCacheEntry fills <cacheDir>/<entry>/x86_64 with a size-byte rootfs
+// last read at used.
+func writeCacheEntry(t *testing.T, cacheDir, entry string, size int, used time.Time) string {
+	t.Helper()
+	dir := filepath.Join(cacheDir, entry, "x86_64")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "rootfs.tar.gz")
+	if err := os.WriteFile(path, bytes.Repeat([]byte(entry[:1]), size), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, used, used); err != nil {
+		t.Fatal(err)
+	}
+	return filepath.Join(cacheDir, entry)
+}
+
+func TestEvictOldCacheEntries(t *testing.T) {
+	cacheDir := filepath.Join(t.TempDir(), "cache")
+	now := time.Now()
+	oldest := writeCacheEntry(t, cacheDir, "debian/11", 400, now.Add(-72*time.Hour))
+	older := writeCacheEntry(t, cacheDir, "debian/12", 400, now.Add(-48*time.Hour))
+	inUse := writeCacheEntry(t, cacheDir, "alpine/3.19", 400, now.Add(-96*time.Hour))
+	recent := writeCacheEntry(t, cacheDir, "rocky/9", 400, now.Add(-time.Hour))
+
+	// 1600 bytes cached: two entries must go, and alpine, the oldest, is in use
+	if err := EvictOldCacheEntries(cacheDir, 900, filepath.Join(cacheDir, "alpine")); err != nil {
+		t.Fatalf("EvictOldCacheEntries: %v", err)
+	}
+	for _, dir := range []string{oldest, older} {
+		if _, err := os.Stat(dir); !os.IsNotExist(err) {
+			t.Errorf("%s should have been evicted", dir)
+		}
+	}
+	for _, dir := range []string{inUse, recent} {
+		if _, err := os.Stat(dir); err != nil {
+			t.Errorf("%s should have been kept: %v", dir, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(cacheDir, "debian")); !os.IsNotExist(err) {
+		t.Error("empty distro directory should have been removed")
+	}
+}
+
+func TestEvictOldCacheEntriesUnderLimit(t *testing.T) {
+	cacheDir := filepath.Join(t.TempDir(), "cache")
+	entry := writeCacheEntry(t, cacheDir, "debian/12", 400, time.Now().Add(-time.Hour))
+
+	for _, limit := range []int64{0, 400} {
+		if err := EvictOldCacheEntries(cacheDir, limit); err != nil {
+			t.Fatalf("EvictOldCacheEntries(%d): %v", limit, err)
+		}
+		if _, err := os.Stat(entry); err != nil {
+			t.Errorf("limit %d evicted %s", limit, entry)
+		}
+	}
+}
+
+func TestEvictOldCacheEntriesContentStore(t *testing.T) {
+	cacheDir := filepath.Join(t.TempDir(), "cache")
+	old := writeCacheEntry(t, cacheDir, "debian/11", 400, time.Now().Add(-48*time.Hour))
+	writeCacheEntry(t, cacheDir, "debian/12", 400, time.Now())
+
+	// Move the old rootfs into the content store, as downloads are
+	store := NewContentStore(ContentStoreDir(cacheDir))
+	link := filepath.Join(old, "x86_64", "rootfs.tar.gz")
+	sum, err := store.Add(link)
+	if err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	os.Chtimes(store.blobPath(sum), time.Now().Add(-48*time.Hour), time.Now().Add(-48*time.Hour))
+
+	// The link is tiny, but the stored file it points to counts
+	if err := EvictOldCacheEntries(cacheDir, 500); err != nil {
+		t.Fatalf("EvictOldCacheEntries: %v", err)
+	}
+	if _, err := os.Stat(old); !os.IsNotExist(err) {
+		t.Error("old entry should have been evicted")
+	}
+	if _, err := os.Stat(store.blobPath(sum)); !os.IsNotExist(err) {
+		t.Error("the evicted entry's stored file should have been collected")
+	}
+}
+
+func TestEvictOldCacheEntriesKeepsRegisteredVMs(t *testing.T) {
+	baseDir := t.TempDir()
+	cacheDir := filepath.Join(baseDir, "cache")
+	now := time.Now()
+	ubuntu := writeCacheEntry(t, cacheDir, "ubuntu/24.04", 400, now.Add(-72*time.Hour))
+	debian := writeCacheEntry(t, cacheDir, "debian/12", 400, now.Add(-48*time.Hour))
+	writeCacheEntry(t, cacheDir, "alpine/3.21", 400, now)
+
+	// The ubuntu VM boots the cached image: evicting it would erase the VM
+	if err := NewRegistry(baseDir).CreateVM(VMEntry{Name: "web", Distro: "ubuntu"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := EvictOldCacheEntries(cacheDir, 500, filepath.Join(cacheDir, "alpine")); err != nil {
+		t.Fatalf("EvictOldCacheEntries: %v", err)
+	}
+	if _, err := os.Stat(ubuntu); err != nil {
+		t.Errorf("the registered VM's distro was evicted: %v", err)
+	}
+	if _, err := os.Stat(debian); !os.IsNotExist(err) {
+		t.Error("unused debian entry should have been evicted")
+	}
+}
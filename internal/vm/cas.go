@@ -0,0 +1,214 @@
+package vm
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// ContentStore keeps downloaded assets under the SHA-256 of their
+// content, as <dir>/<sha256[:2]>/<sha256>. The version-named cache paths
+// are symlinks into the store, so a new distro version never overwrites
+// the files of an old one and identical files are stored once.
+type ContentStore struct {
+	dir string
+}
+
+// NewContentStore returns the content store rooted at dir.
+func NewContentStore(dir string) *ContentStore {
+	return &ContentStore{dir: dir}
+}
+
+// ContentStoreDir returns the directory of the content store that backs
+// cacheDir: "cas" next to it, i.e. ~/.vmterminal/cas.
+func ContentStoreDir(cacheDir string) string {
+	return filepath.Join(filepath.Dir(cacheDir), "cas")
+}
+
+// Dir returns the store's root directory.
+func (s *ContentStore) Dir() string {
+	return s.dir
+}
+
+// blobPath returns where the blob with the given SHA-256 is kept.
+func (s *ContentStore) blobPath(sum string) string {
+	return filepath.Join(s.dir, sum[:2], sum)
+}
+
+// Add moves the file at path into the store and replaces it with a
+// symlink to the stored blob. If the store already holds the same
+// content, the file is dropped in favour of the existing blob. It
+// returns the file's SHA-256.
+func (s *ContentStore) Add(path string) (string, error) {
+	sum, err := fileSHA256(path)
+	if err != nil {
+		return "", err
+	}
+
+	blob := s.blobPath(sum)
+	if err := os.MkdirAll(filepath.Dir(blob), 0755); err != nil {
+		return "", fmt.Errorf("create content store: %w", err)
+	}
+	if _, err := os.Stat(blob); err == nil {
+		if err := os.Remove(path); err != nil {
+			return "", err
+		}
+	} else if err := os.Rename(path, blob); err != nil {
+		return "", fmt.Errorf("move %s into content store: %w", filepath.Base(path), err)
+	}
+
+	// Link relatively so the base directory can be moved as a whole
+	target, err := filepath.Rel(filepath.Dir(path), blob)
+	if err != nil {
+		target = blob
+	}
+	tmpLink := path + ".link"
+	os.Remove(tmpLink)
+	if err := os.Symlink(target, tmpLink); err != nil {
+		return "", fmt.Errorf("link %s: %w", filepath.Base(path), err)
+	}
+	if err := os.Rename(tmpLink, path); err != nil {
+		os.Remove(tmpLink)
+		return "", fmt.Errorf("link %s: %w", filepath.Base(path), err)
+	}
+	return sum, nil
+}
+
+// Verify checks that the blob the symlink at path points to still has the
+// content its name promises. Regular files, cached before the store
+// existed, are not checked.
+func (s *ContentStore) Verify(path string) error {
+	blob, ok, err := linkTarget(path)
+	if err != nil || !ok {
+		return err
+	}
+	sum, err := fileSHA256(blob)
+	if err != nil {
+		return err
+	}
+	if sum != filepath.Base(blob) {
+		return fmt.Errorf("%s is corrupt: content hashes to %s, stored as %s", filepath.Base(path), sum, filepath.Base(blob))
+	}
+	return nil
+}
+
+// Discard removes the cache entry at path and, if it is a symlink into
+// the store, the blob behind it, so a corrupt asset is downloaded again.
+func (s *ContentStore) Discard(path string) error {
+	if blob, ok, _ := linkTarget(path); ok {
+		if err := os.Remove(blob); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// GCResult reports what ContentStore.GC removed.
+type GCResult struct {
+	Removed    int   `json:"removed"`
+	FreedBytes int64 `json:"freed_bytes"`
+	Kept       int   `json:"kept"`
+}
+
+// GC removes blobs that no symlink under any of roots points to.
+func (s *ContentStore) GC(roots ...string) (*GCResult, error) {
+	referenced := make(map[string]bool)
+	for _, root := range roots {
+		err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) {
+					return nil
+				}
+				return err
+			}
+			if info.Mode()&os.ModeSymlink == 0 {
+				return nil
+			}
+			if blob, ok, err := linkTarget(path); err == nil && ok {
+				referenced[blob] = true
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("scan %s: %w", root, err)
+		}
+	}
+
+	res := &GCResult{}
+	err := filepath.Walk(s.dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		if abs, err := filepath.Abs(path); err == nil && referenced[abs] {
+			res.Kept++
+			return nil
+		}
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		res.Removed++
+		res.FreedBytes += info.Size()
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("collect content store: %w", err)
+	}
+
+	// Drop the fan-out directories that are now empty
+	dirs, _ := os.ReadDir(s.dir)
+	for _, d := range dirs {
+		if d.IsDir() {
+			os.Remove(filepath.Join(s.dir, d.Name()))
+		}
+	}
+	return res, nil
+}
+
+// linkTarget returns the cleaned absolute path the symlink at path points
+// to. ok is false if path is not a symlink.
+func linkTarget(path string) (target string, ok bool, err error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return "", false, err
+	}
+	if info.Mode()&os.ModeSymlink == 0 {
+		return "", false, nil
+	}
+	target, err = os.Readlink(path)
+	if err != nil {
+		return "", false, err
+	}
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(filepath.Dir(path), target)
+	}
+	if abs, err := filepath.Abs(target); err == nil {
+		target = abs
+	}
+	return filepath.Clean(target), true, nil
+}
+
+// fileSHA256 returns the hex SHA-256 of the file at path.
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
@@ -0,0 +1,138 @@
+package vm
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestContentStoreAdd(t *testing.T) {
+	base := t.TempDir()
+	store := NewContentStore(filepath.Join(base, "cas"))
+	cache := filepath.Join(base, "cache")
+
+	// The same file cached for two versions is stored once
+	var sums []string
+	for _, version := range []string{"3.19", "3.20"} {
+		dir := filepath.Join(cache, "alpine", version)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(dir, "vmlinuz")
+		if err := os.WriteFile(path, []byte("kernel"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		sum, err := store.Add(path)
+		if err != nil {
+			t.Fatalf("Add: %v", err)
+		}
+		sums = append(sums, sum)
+
+		info, err := os.Lstat(path)
+		if err != nil || info.Mode()&os.ModeSymlink == 0 {
+			t.Fatalf("%s is not a symlink after Add", path)
+		}
+		if data, err := os.ReadFile(path); err != nil || string(data) != "kernel" {
+			t.Errorf("read through link = %q, %v", data, err)
+		}
+		if err := store.Verify(path); err != nil {
+			t.Errorf("Verify: %v", err)
+		}
+	}
+
+	if sums[0] != sums[1] {
+		t.Errorf("sums differ: %v", sums)
+	}
+	blob := filepath.Join(store.Dir(), sums[0][:2], sums[0])
+	if _, err := os.Stat(blob); err != nil {
+		t.Errorf("blob missing: %v", err)
+	}
+	blobs, _ := filepath.Glob(filepath.Join(store.Dir(), "*", "*"))
+	if len(blobs) != 1 {
+		t.Errorf("store holds %v, want one blob", blobs)
+	}
+}
+
+func TestContentStoreVerifyCorrupt(t *testing.T) {
+	base := t.TempDir()
+	store := NewContentStore(filepath.Join(base, "cas"))
+	path := filepath.Join(base, "rootfs.tar.gz")
+	if err := os.WriteFile(path, []byte("rootfs"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	sum, err := store.Add(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blob := filepath.Join(store.Dir(), sum[:2], sum)
+	if err := os.WriteFile(blob, []byte("bit rot"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Verify(path); err == nil || !strings.Contains(err.Error(), "corrupt") {
+		t.Errorf("Verify = %v, want corruption error", err)
+	}
+
+	if err := store.Discard(path); err != nil {
+		t.Fatalf("Discard: %v", err)
+	}
+	for _, p := range []string{path, blob} {
+		if _, err := os.Lstat(p); !os.IsNotExist(err) {
+			t.Errorf("%s still exists after Discard", p)
+		}
+	}
+
+	// Files cached before the store existed are not checked
+	legacy := filepath.Join(base, "vmlinuz")
+	if err := os.WriteFile(legacy, []byte("kernel"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Verify(legacy); err != nil {
+		t.Errorf("Verify(regular file) = %v", err)
+	}
+}
+
+func TestContentStoreGC(t *testing.T) {
+	base := t.TempDir()
+	store := NewContentStore(filepath.Join(base, "cas"))
+	cache := filepath.Join(base, "cache")
+
+	add := func(rel, content string) string {
+		path := filepath.Join(cache, rel)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := store.Add(path); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	add("alpine/3.19/vmlinuz", "old kernel")
+	add("alpine/3.20/vmlinuz", "new kernel")
+	add("ubuntu/24.04/vmlinuz", "new kernel")
+
+	// Clearing a distro only removes its links
+	if err := os.RemoveAll(filepath.Join(cache, "alpine")); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := store.GC(cache)
+	if err != nil {
+		t.Fatalf("GC: %v", err)
+	}
+	if res.Removed != 1 || res.Kept != 1 || res.FreedBytes != int64(len("old kernel")) {
+		t.Errorf("GC = %+v, want 1 removed, 1 kept", res)
+	}
+	if data, err := os.ReadFile(filepath.Join(cache, "ubuntu/24.04/vmlinuz")); err != nil || string(data) != "new kernel" {
+		t.Errorf("shared blob lost: %q, %v", data, err)
+	}
+
+	// A missing store or cache is not an error
+	if _, err := NewContentStore(filepath.Join(base, "none")).GC(filepath.Join(base, "nocache")); err != nil {
+		t.Errorf("GC on empty dirs: %v", err)
+	}
+}
@@ -0,0 +1,71 @@
+package vm
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/javanstorm/vmterminal/internal/distro"
+)
+
+// seedVolumeID is the volume label cloud-init's NoCloud datasource looks for.
+const seedVolumeID = "cidata"
+
+// seedISOCommand returns the command that packs dir into a cloud-init seed
+// ISO at path, using genisoimage or mkisofs, or hdiutil on macOS.
+func seedISOCommand(dir, path string) (*exec.Cmd, error) {
+	for _, tool := range []string{"genisoimage", "mkisofs"} {
+		if _, err := exec.LookPath(tool); err == nil {
+			return exec.Command(tool, "-output", path, "-volid", seedVolumeID, "-joliet", "-rock", "-quiet", dir), nil
+		}
+	}
+	if runtime.GOOS == "darwin" {
+		return exec.Command("hdiutil", "makehybrid", "-o", path, "-iso", "-joliet",
+			"-default-volume-name", seedVolumeID, dir), nil
+	}
+	return nil, fmt.Errorf("genisoimage or mkisofs is required for cloud-init (install genisoimage)")
+}
+
+// BuildSeedISO writes a cloud-init NoCloud seed ISO for cfg to path.
+func BuildSeedISO(cfg *distro.CloudInitConfig, path string) error {
+	dir, err := os.MkdirTemp("", "vmterminal-seed-")
+	if err != nil {
+		return fmt.Errorf("create seed dir: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"user-data": cfg.UserData,
+		"meta-data": cfg.MetaData,
+	}
+	if cfg.NetworkConfig != "" {
+		files["network-config"] = cfg.NetworkConfig
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			return fmt.Errorf("write %s: %w", name, err)
+		}
+	}
+
+	// Build next to the final path so a failed run leaves the old seed intact.
+	// hdiutil insists on an .iso extension.
+	tmpPath := strings.TrimSuffix(path, ".iso") + ".tmp.iso"
+	os.Remove(tmpPath)
+	cmd, err := seedISOCommand(dir, tmpPath)
+	if err != nil {
+		return err
+	}
+	if output, err := cmd.CombinedOutput(); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("build seed ISO: %w: %s", err, output)
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("finalize seed ISO: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package vm
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/javanstorm/vmterminal/internal/distro"
+)
+
+const isoSectorSize = 2048
+
+// isoFile is a file found in an ISO 9660 directory.
+type isoFile struct {
+	Name string
+	Data []byte
+}
+
+// readJolietRoot returns the primary volume ID and the files in the root
+// directory of the Joliet tree of the ISO image at path.
+func readJolietRoot(t *testing.T, path string) (string, map[string]isoFile) {
+	t.Helper()
+
+	img, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var volumeID string
+	var root []byte
+	for sector := 16; (sector+1)*isoSectorSize <= len(img); sector++ {
+		vd := img[sector*isoSectorSize : (sector+1)*isoSectorSize]
+		if string(vd[1:6]) != "CD001" {
+			t.Fatalf("sector %d is not a volume descriptor", sector)
+		}
+		switch vd[0] {
+		case 1: // Primary volume descriptor
+			volumeID = strings.TrimRight(string(vd[40:72]), " ")
+		case 2: // Supplementary; Joliet uses UCS-2 escape sequences
+			if vd[88] == '%' && vd[89] == '/' {
+				root = vd[156 : 156+34]
+			}
+		}
+		if vd[0] == 255 {
+			break
+		}
+	}
+	if root == nil {
+		t.Fatal("no Joliet volume descriptor found")
+	}
+
+	extent := binary.LittleEndian.Uint32(root[2:])
+	size := binary.LittleEndian.Uint32(root[10:])
+	dir := img[int(extent)*isoSectorSize : int(extent)*isoSectorSize+int(size)]
+
+	files := make(map[string]isoFile)
+	for off := 0; off < len(dir); {
+		recLen := int(dir[off])
+		if recLen == 0 {
+			// Records never span sectors; skip the padding
+			off = (off/isoSectorSize + 1) * isoSectorSize
+			continue
+		}
+		rec := dir[off : off+recLen]
+		off += recLen
+
+		nameLen := int(rec[32])
+		name := rec[33 : 33+nameLen]
+		if nameLen == 1 && (name[0] == 0 || name[0] == 1) {
+			continue // "." and ".."
+		}
+
+		u := make([]uint16, nameLen/2)
+		for i := range u {
+			u[i] = binary.BigEndian.Uint16(name[2*i:])
+		}
+		decoded := strings.TrimSuffix(string(utf16.Decode(u)), ";1")
+
+		fileExtent := int(binary.LittleEndian.Uint32(rec[2:]))
+		fileSize := int(binary.LittleEndian.Uint32(rec[10:]))
+		files[decoded] = isoFile{
+			Name: decoded,
+			Data: img[fileExtent*isoSectorSize : fileExtent*isoSectorSize+fileSize],
+		}
+	}
+
+	return volumeID, files
+}
+
+func TestBuildSeedISO(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := seedISOCommand(dir, filepath.Join(dir, "probe.iso")); err != nil {
+		t.Skip(err)
+	}
+
+	cfg := &distro.CloudInitConfig{
+		UserData:      "#cloud-config\npackages: [htop]\n",
+		MetaData:      "instance-id: vmterminal\nlocal-hostname: vmterminal\n",
+		NetworkConfig: "version: 2\n",
+	}
+	path := filepath.Join(dir, "seed.iso")
+	if err := BuildSeedISO(cfg, path); err != nil {
+		t.Fatalf("BuildSeedISO failed: %v", err)
+	}
+
+	volumeID, files := readJolietRoot(t, path)
+	if !strings.EqualFold(volumeID, "cidata") {
+		t.Errorf("volume ID = %q, want cidata", volumeID)
+	}
+
+	want := map[string]string{
+		"user-data":      cfg.UserData,
+		"meta-data":      cfg.MetaData,
+		"network-config": cfg.NetworkConfig,
+	}
+	if len(files) != len(want) {
+		t.Errorf("seed ISO has %d files, want %d", len(files), len(want))
+	}
+	for name, content := range want {
+		f, ok := files[name]
+		if !ok {
+			t.Errorf("seed ISO is missing %s", name)
+			continue
+		}
+		if string(f.Data) != content {
+			t.Errorf("%s = %q, want %q", name, f.Data, content)
+		}
+	}
+
+	if _, err := os.Stat(strings.TrimSuffix(path, ".iso") + ".tmp.iso"); !os.IsNotExist(err) {
+		t.Error("temporary seed ISO should be renamed away")
+	}
+}
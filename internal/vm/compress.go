@@ -0,0 +1,159 @@
+package vm
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+)
+
+// Snapshot compression codecs.
+const (
+	CodecGzip = "gzip"
+	CodecZstd = "zstd"
+)
+
+// codecExt returns the snapshot file extension for a codec.
+func codecExt(codec string) string {
+	if codec == CodecZstd {
+		return ".raw.zst"
+	}
+	return ".raw.gz"
+}
+
+// ValidateCompression checks a codec and level pair. An empty codec means
+// gzip and a zero level means the codec's default. gzip accepts levels 1-9,
+// zstd accepts 1-19.
+func ValidateCompression(codec string, level int) error {
+	maxLevel := 9
+	switch codec {
+	case "", CodecGzip:
+	case CodecZstd:
+		maxLevel = 19
+	default:
+		return fmt.Errorf("unknown compression codec %q (use gzip or zstd)", codec)
+	}
+	if level < 0 || level > maxLevel {
+		return fmt.Errorf("compression level %d out of range for %s (1-%d)", level, codecName(codec), maxLevel)
+	}
+	return nil
+}
+
+// codecName returns codec, defaulting to gzip for metadata written before
+// codecs were recorded.
+func codecName(codec string) string {
+	if codec == "" {
+		return CodecGzip
+	}
+	return codec
+}
+
+// newCompressor returns a writer that compresses into w. Closing it flushes
+// the stream but does not close w.
+func newCompressor(w io.Writer, codec string, level int) (io.WriteCloser, error) {
+	if err := ValidateCompression(codec, level); err != nil {
+		return nil, err
+	}
+
+	if codec != CodecZstd {
+		if level == 0 {
+			level = gzip.DefaultCompression
+		}
+		return gzip.NewWriterLevel(w, level)
+	}
+
+	if err := EnsureZstd(); err != nil {
+		return nil, err
+	}
+	args := []string{"-q", "-c"}
+	if level > 0 {
+		args = append(args, "-"+strconv.Itoa(level))
+	}
+	cmd := exec.Command("zstd", args...)
+	cmd.Stdout = w
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("start zstd: %w", err)
+	}
+	return &zstdWriter{cmd: cmd, stdin: stdin}, nil
+}
+
+// newDecompressor returns a reader that decompresses r.
+func newDecompressor(r io.Reader, codec string) (io.ReadCloser, error) {
+	if codec != CodecZstd {
+		return gzip.NewReader(r)
+	}
+
+	if err := EnsureZstd(); err != nil {
+		return nil, err
+	}
+	cmd := exec.Command("zstd", "-d", "-q", "-c")
+	cmd.Stdin = r
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("start zstd: %w", err)
+	}
+	return &zstdReader{cmd: cmd, stdout: stdout}, nil
+}
+
+// zstdWriter feeds data to a zstd process writing to the destination.
+type zstdWriter struct {
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+}
+
+func (z *zstdWriter) Write(p []byte) (int, error) {
+	return z.stdin.Write(p)
+}
+
+// Close ends the input and waits for zstd to finish writing.
+func (z *zstdWriter) Close() error {
+	if z.cmd == nil {
+		return nil
+	}
+	z.stdin.Close()
+	err := z.cmd.Wait()
+	z.cmd = nil
+	if err != nil {
+		return fmt.Errorf("zstd: %w", err)
+	}
+	return nil
+}
+
+// zstdReader reads the output of a zstd decompression process.
+type zstdReader struct {
+	cmd    *exec.Cmd
+	stdout io.ReadCloser
+	eof    bool
+}
+
+func (z *zstdReader) Read(p []byte) (int, error) {
+	n, err := z.stdout.Read(p)
+	if err == io.EOF {
+		z.eof = true
+	}
+	return n, err
+}
+
+// Close stops zstd if the stream was not fully read and reaps the process.
+func (z *zstdReader) Close() error {
+	if z.cmd == nil {
+		return nil
+	}
+	if !z.eof {
+		z.cmd.Process.Kill()
+	}
+	err := z.cmd.Wait()
+	z.cmd = nil
+	if err != nil && z.eof {
+		return fmt.Errorf("zstd: %w", err)
+	}
+	return nil
+}
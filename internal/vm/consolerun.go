@@ -0,0 +1,147 @@
+package vm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// consoleLoginTimeout bounds the wait for a shell after logging in.
+const consoleLoginTimeout = 30 * time.Second
+
+// ConsoleRunner runs shell commands in a guest through its serial console,
+// for guests that cannot be reached over SSH. It types each command at the
+// console's shell and reads the output back between marker lines.
+type ConsoleRunner struct {
+	in      io.Writer
+	chunks  chan []byte
+	readErr error
+	buf     []byte // Output read but not consumed yet
+	seq     int
+}
+
+// NewConsoleRunner returns a runner that types into in and reads from out,
+// the two ends of a VM console as returned by Manager.Console. It reads out
+// from a background goroutine until out ends.
+func NewConsoleRunner(in io.Writer, out io.Reader) *ConsoleRunner {
+	r := &ConsoleRunner{in: in, chunks: make(chan []byte, 64)}
+	go func() {
+		buf := make([]byte, 4096)
+		for {
+			n, err := out.Read(buf)
+			if n > 0 {
+				r.chunks <- append([]byte(nil), buf[:n]...)
+			}
+			if err != nil {
+				r.readErr = err
+				close(r.chunks)
+				return
+			}
+		}
+	}()
+	return r
+}
+
+// WaitFor reads the console until s appears and returns everything up to
+// and including it. Output after s is kept for the next read.
+func (r *ConsoleRunner) WaitFor(ctx context.Context, s string) (string, error) {
+	for {
+		if i := strings.Index(string(r.buf), s); i >= 0 {
+			out := string(r.buf[:i+len(s)])
+			r.buf = r.buf[i+len(s):]
+			return out, nil
+		}
+		select {
+		case chunk, ok := <-r.chunks:
+			if !ok {
+				if r.readErr == io.EOF {
+					return "", fmt.Errorf("console closed before %q appeared", s)
+				}
+				return "", fmt.Errorf("read console: %w", r.readErr)
+			}
+			r.buf = append(r.buf, chunk...)
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+}
+
+// Login waits for the console's login prompt, logs in as user, and waits
+// until a shell answers. The account must not need a password.
+func (r *ConsoleRunner) Login(ctx context.Context, user string) error {
+	if _, err := r.WaitFor(ctx, "login:"); err != nil {
+		return fmt.Errorf("wait for login prompt: %w", err)
+	}
+	if _, err := io.WriteString(r.in, user+"\n"); err != nil {
+		return fmt.Errorf("write to console: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, consoleLoginTimeout)
+	defer cancel()
+	if _, _, err := r.Run(ctx, "true"); err != nil {
+		return fmt.Errorf("no shell after logging in as %s (does it need a password?): %w", user, err)
+	}
+	return nil
+}
+
+// Run runs cmd in the console's shell and returns what it printed, with
+// carriage returns removed, and its exit status.
+func (r *ConsoleRunner) Run(ctx context.Context, cmd string) (output string, status int, err error) {
+	r.seq++
+	begin := fmt.Sprintf("__VMT_BEGIN_%d__", r.seq)
+	end := fmt.Sprintf("__VMT_END_%d__", r.seq)
+
+	// Quote the markers in the typed line so that the console's echo of
+	// it does not match them; only the output of echo does
+	line := fmt.Sprintf("echo %s; %s; echo %s $?\n", splitMarker(begin), cmd, splitMarker(end))
+	if _, err := io.WriteString(r.in, line); err != nil {
+		return "", 0, fmt.Errorf("write to console: %w", err)
+	}
+
+	if _, err := r.WaitFor(ctx, begin); err != nil {
+		return "", 0, err
+	}
+	if _, err := r.WaitFor(ctx, "\n"); err != nil {
+		return "", 0, err
+	}
+	out, err := r.WaitFor(ctx, end+" ")
+	if err != nil {
+		return "", 0, err
+	}
+	statusLine, err := r.WaitFor(ctx, "\n")
+	if err != nil {
+		return "", 0, err
+	}
+	status, err = strconv.Atoi(strings.TrimSpace(statusLine))
+	if err != nil {
+		return "", 0, fmt.Errorf("parse exit status %q: %w", statusLine, err)
+	}
+
+	output = strings.TrimSuffix(out, end+" ")
+	output = strings.ReplaceAll(output, "\r", "")
+	return output, status, nil
+}
+
+// RunInConsole runs command in the shell on a VM console, such as one from
+// ReconnectConsole, and returns its output and exit status. The console
+// must be logged in and at a shell prompt. It gives up after timeout.
+func RunInConsole(ctx context.Context, vmIn io.Writer, vmOut io.Reader, command string, timeout time.Duration) (output string, exitCode int, err error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	output, exitCode, err = NewConsoleRunner(vmIn, vmOut).Run(ctx, command)
+	if errors.Is(err, context.DeadlineExceeded) {
+		return "", 0, fmt.Errorf("command did not finish within %s; is the console logged in at a shell prompt?", timeout)
+	}
+	return output, exitCode, err
+}
+
+// splitMarker returns marker with an empty quoted string in the middle,
+// which the shell removes.
+func splitMarker(marker string) string {
+	mid := len(marker) / 2
+	return marker[:mid] + `""` + marker[mid:]
+}
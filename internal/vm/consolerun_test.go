@@ -0,0 +1,139 @@
+package vm
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeConsole plays a guest that prints a login prompt and then runs a
+// tiny shell: it echoes every line typed, like a tty, and answers the
+// commands in replies. echo prints its arguments with quotes removed and
+// $? as 0.
+func fakeConsole(t *testing.T, replies map[string]string) (in io.Writer, out io.Reader) {
+	t.Helper()
+	guestIn, hostIn := io.Pipe()
+	hostOut, guestOut := io.Pipe()
+	t.Cleanup(func() {
+		hostIn.Close()
+		guestOut.Close()
+	})
+
+	go func() {
+		defer guestOut.Close()
+		io.WriteString(guestOut, "Welcome\r\n\r\nlocalhost login: ")
+		lines := bufio.NewScanner(guestIn)
+		loggedIn := false
+		for lines.Scan() {
+			line := lines.Text()
+			io.WriteString(guestOut, line+"\r\n")
+			if !loggedIn {
+				loggedIn = true
+				io.WriteString(guestOut, "localhost:~# ")
+				continue
+			}
+			for _, cmd := range strings.Split(line, "; ") {
+				switch {
+				case strings.HasPrefix(cmd, "echo "):
+					arg := strings.ReplaceAll(strings.TrimPrefix(cmd, "echo "), `""`, "")
+					io.WriteString(guestOut, strings.ReplaceAll(arg, "$?", "0")+"\r\n")
+				case cmd == "true":
+				default:
+					io.WriteString(guestOut, strings.ReplaceAll(replies[cmd], "\n", "\r\n"))
+				}
+			}
+			io.WriteString(guestOut, "localhost:~# ")
+		}
+	}()
+	return hostIn, hostOut
+}
+
+func TestConsoleRunnerRun(t *testing.T) {
+	in, out := fakeConsole(t, map[string]string{
+		"uname -r":          "6.6.8-virt\n",
+		"cat /etc/hostname": "dev\n",
+	})
+	r := NewConsoleRunner(in, out)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := r.Login(ctx, "root"); err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+
+	output, status, err := r.Run(ctx, "uname -r")
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if output != "6.6.8-virt\n" || status != 0 {
+		t.Errorf("Run(uname -r) = %q, %d, want %q, 0", output, status, "6.6.8-virt\n")
+	}
+
+	// Output of one command does not leak into the next
+	output, _, err = r.Run(ctx, "cat /etc/hostname")
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if output != "dev\n" {
+		t.Errorf("Run(cat /etc/hostname) = %q, want %q", output, "dev\n")
+	}
+}
+
+func TestConsoleRunnerConsoleClosed(t *testing.T) {
+	guestIn, hostIn := io.Pipe()
+	go io.Copy(io.Discard, guestIn)
+	r := NewConsoleRunner(hostIn, strings.NewReader("booting...\r\n"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := r.Login(ctx, "root"); err == nil {
+		t.Error("Login succeeded on a console that closed without a prompt")
+	}
+}
+
+func TestConsoleRunnerTimeout(t *testing.T) {
+	guestIn, hostIn := io.Pipe()
+	go io.Copy(io.Discard, guestIn)
+	hostOut, guestOut := io.Pipe()
+	defer guestOut.Close()
+	r := NewConsoleRunner(hostIn, hostOut)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := r.WaitFor(ctx, "login:"); err != context.DeadlineExceeded {
+		t.Errorf("WaitFor on a silent console = %v, want DeadlineExceeded", err)
+	}
+}
+
+func TestRunInConsole(t *testing.T) {
+	in, out := fakeConsole(t, map[string]string{"uname -a": "Linux localhost 6.6.8-virt\n"})
+	// Log the fake console in, as a user would have
+	prompt := make([]byte, len("Welcome\r\n\r\nlocalhost login: "))
+	if _, err := io.ReadFull(out, prompt); err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(in, "root\n")
+
+	output, code, err := RunInConsole(context.Background(), in, out, "uname -a", 5*time.Second)
+	if err != nil {
+		t.Fatalf("RunInConsole: %v", err)
+	}
+	if output != "Linux localhost 6.6.8-virt\n" || code != 0 {
+		t.Errorf("RunInConsole = %q, %d", output, code)
+	}
+}
+
+func TestRunInConsoleTimeout(t *testing.T) {
+	guestIn, hostIn := io.Pipe()
+	go io.Copy(io.Discard, guestIn)
+	hostOut, guestOut := io.Pipe()
+	defer guestOut.Close()
+
+	_, _, err := RunInConsole(context.Background(), hostIn, hostOut, "true", 50*time.Millisecond)
+	if err == nil || !strings.Contains(err.Error(), "logged in") {
+		t.Errorf("RunInConsole on a silent console = %v, want a timeout asking about the login", err)
+	}
+}
@@ -14,6 +14,7 @@ type Dependency struct {
 	Command     string            // Command to check (e.g., "guestfish")
 	Packages    map[string]string // OS -> package name mapping
 	Description string            // Human-readable description
+	Required    bool              // Needed on every host, not only for some distros or features
 }
 
 // DependencyManager handles checking and installing dependencies.
@@ -80,6 +81,87 @@ var isoDeps = []Dependency{
 	},
 }
 
+// qemuImgDep converts disk images between formats.
+var qemuImgDep = Dependency{
+	Name:        "qemu-img",
+	Command:     "qemu-img",
+	Description: "Convert qcow2 disk images to raw",
+	Packages: map[string]string{
+		"arch":        "qemu-img",
+		"manjaro":     "qemu-img",
+		"endeavouros": "qemu-img",
+		"ubuntu":      "qemu-utils",
+		"debian":      "qemu-utils",
+		"linuxmint":   "qemu-utils",
+		"pop":         "qemu-utils",
+		"fedora":      "qemu-img",
+		"rhel":        "qemu-img",
+		"centos":      "qemu-img",
+		"rocky":       "qemu-img",
+		"almalinux":   "qemu-img",
+		"opensuse":    "qemu-tools",
+		"suse":        "qemu-tools",
+		"macos":       "qemu", // brew install qemu
+	},
+}
+
+// zstdDep compresses snapshots with the zstd codec.
+var zstdDep = Dependency{
+	Name:        "zstd",
+	Command:     "zstd",
+	Description: "Compress snapshots with zstd",
+	Packages: map[string]string{
+		"arch":        "zstd",
+		"manjaro":     "zstd",
+		"endeavouros": "zstd",
+		"ubuntu":      "zstd",
+		"debian":      "zstd",
+		"linuxmint":   "zstd",
+		"pop":         "zstd",
+		"fedora":      "zstd",
+		"rhel":        "zstd",
+		"centos":      "zstd",
+		"rocky":       "zstd",
+		"almalinux":   "zstd",
+		"opensuse":    "zstd",
+		"suse":        "zstd",
+		"macos":       "zstd", // brew install zstd
+	},
+}
+
+// mkfsExt4Dep formats VM disks during first-time setup on Linux.
+var mkfsExt4Dep = Dependency{
+	Name:        "mkfs.ext4",
+	Command:     "mkfs.ext4",
+	Description: "Format VM disks during setup",
+	Required:    runtime.GOOS == "linux",
+	Packages: map[string]string{
+		"arch":        "e2fsprogs",
+		"manjaro":     "e2fsprogs",
+		"endeavouros": "e2fsprogs",
+		"ubuntu":      "e2fsprogs",
+		"debian":      "e2fsprogs",
+		"linuxmint":   "e2fsprogs",
+		"pop":         "e2fsprogs",
+		"fedora":      "e2fsprogs",
+		"rhel":        "e2fsprogs",
+		"centos":      "e2fsprogs",
+		"rocky":       "e2fsprogs",
+		"almalinux":   "e2fsprogs",
+		"opensuse":    "e2fsprogs",
+		"suse":        "e2fsprogs",
+		"macos":       "", // Disks are set up inside the VM on macOS
+	},
+}
+
+// AllDependencies returns every external tool vmterminal may call.
+func AllDependencies() []Dependency {
+	deps := []Dependency{mkfsExt4Dep}
+	deps = append(deps, qcow2Deps...)
+	deps = append(deps, isoDeps...)
+	return append(deps, qemuImgDep, zstdDep)
+}
+
 // detectHostOS returns the host OS family.
 func detectHostOS() string {
 	if runtime.GOOS == "darwin" {
@@ -130,6 +212,41 @@ func (m *DependencyManager) CheckDependency(dep Dependency) bool {
 	return err == nil
 }
 
+// HostOS returns the detected host OS family, e.g. "ubuntu" or "macos".
+func (m *DependencyManager) HostOS() string {
+	return m.hostOS
+}
+
+// Applicable reports whether dep is used on this host. Dependencies mapped
+// to an empty package name for the host OS are not.
+func (m *DependencyManager) Applicable(dep Dependency) bool {
+	pkg, ok := dep.Packages[m.hostOS]
+	return !ok || pkg != ""
+}
+
+// installArgs returns the package manager command line that installs pkg.
+func (m *DependencyManager) installArgs(pkg string) ([]string, error) {
+	pm, err := HostPackageManagerFor(m.hostOS)
+	if err != nil {
+		return nil, fmt.Errorf("%w (install %s manually)", err, pkg)
+	}
+	return pm.Install(pkg), nil
+}
+
+// InstallCommand returns the shell command that installs dep on this host,
+// or "" if there is no known package for it.
+func (m *DependencyManager) InstallCommand(dep Dependency) string {
+	pkg := dep.Packages[m.hostOS]
+	if pkg == "" {
+		return ""
+	}
+	args, err := m.installArgs(pkg)
+	if err != nil {
+		return ""
+	}
+	return strings.Join(args, " ")
+}
+
 // InstallDependency installs a dependency using the appropriate package manager.
 func (m *DependencyManager) InstallDependency(dep Dependency) error {
 	pkg, ok := dep.Packages[m.hostOS]
@@ -137,25 +254,11 @@ func (m *DependencyManager) InstallDependency(dep Dependency) error {
 		return fmt.Errorf("%s is not available on %s", dep.Name, m.hostOS)
 	}
 
-	var cmd *exec.Cmd
-
-	switch m.hostOS {
-	case "arch", "manjaro", "endeavouros":
-		cmd = exec.Command("sudo", "pacman", "-S", "--noconfirm", pkg)
-	case "ubuntu", "debian", "linuxmint", "pop":
-		cmd = exec.Command("sudo", "apt-get", "install", "-y", pkg)
-	case "fedora":
-		cmd = exec.Command("sudo", "dnf", "install", "-y", pkg)
-	case "rhel", "centos", "rocky", "almalinux":
-		cmd = exec.Command("sudo", "yum", "install", "-y", pkg)
-	case "opensuse", "suse":
-		cmd = exec.Command("sudo", "zypper", "install", "-y", pkg)
-	case "macos":
-		cmd = exec.Command("brew", "install", pkg)
-	default:
-		return fmt.Errorf("unsupported host OS: %s (install %s manually)", m.hostOS, pkg)
+	args, err := m.installArgs(pkg)
+	if err != nil {
+		return err
 	}
-
+	cmd := exec.Command(args[0], args[1:]...)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 
@@ -199,3 +302,29 @@ func EnsureISODeps() error {
 	dm := NewDependencyManager()
 	return dm.EnsureDependencies(isoDeps)
 }
+
+// EnsureQemuImg checks that qemu-img is installed. Unlike the other helpers
+// it never installs anything; the error names the package to install.
+func EnsureQemuImg() error {
+	dm := NewDependencyManager()
+	if dm.CheckDependency(qemuImgDep) {
+		return nil
+	}
+	if pkg := qemuImgDep.Packages[dm.hostOS]; pkg != "" {
+		return fmt.Errorf("qemu-img not found (install the %s package)", pkg)
+	}
+	return fmt.Errorf("qemu-img not found (install QEMU's qemu-img tool)")
+}
+
+// EnsureZstd checks that the zstd tool is installed. Like EnsureQemuImg it
+// never installs anything; the error names the package to install.
+func EnsureZstd() error {
+	dm := NewDependencyManager()
+	if dm.CheckDependency(zstdDep) {
+		return nil
+	}
+	if pkg := zstdDep.Packages[dm.hostOS]; pkg != "" {
+		return fmt.Errorf("zstd not found (install the %s package)", pkg)
+	}
+	return fmt.Errorf("zstd not found")
+}
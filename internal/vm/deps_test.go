@@ -0,0 +1,47 @@
+package vm
+
+import "testing"
+
+func TestInstallCommand(t *testing.T) {
+	tests := []struct {
+		hostOS string
+		dep    Dependency
+		want   string
+	}{
+		{"ubuntu", qemuImgDep, "sudo apt-get install -y qemu-utils"},
+		{"arch", zstdDep, "sudo pacman -S --noconfirm zstd"},
+		{"macos", isoDeps[0], "brew install libarchive"},
+		{"macos", qcow2Deps[0], ""},
+		{"plan9", zstdDep, ""},
+	}
+	for _, tt := range tests {
+		m := &DependencyManager{hostOS: tt.hostOS}
+		if got := m.InstallCommand(tt.dep); got != tt.want {
+			t.Errorf("InstallCommand(%s on %s) = %q, want %q", tt.dep.Name, tt.hostOS, got, tt.want)
+		}
+	}
+}
+
+func TestApplicable(t *testing.T) {
+	macos := &DependencyManager{hostOS: "macos"}
+	if macos.Applicable(qcow2Deps[0]) {
+		t.Error("guestfish should not apply on macOS")
+	}
+	if !macos.Applicable(zstdDep) {
+		t.Error("zstd should apply on macOS")
+	}
+	unknown := &DependencyManager{hostOS: "plan9"}
+	if !unknown.Applicable(mkfsExt4Dep) {
+		t.Error("dependencies should apply on unknown hosts")
+	}
+}
+
+func TestAllDependenciesUnique(t *testing.T) {
+	seen := make(map[string]bool)
+	for _, dep := range AllDependencies() {
+		if seen[dep.Name] {
+			t.Errorf("duplicate dependency %s", dep.Name)
+		}
+		seen[dep.Name] = true
+	}
+}
@@ -0,0 +1,98 @@
+package vm
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// NATLeasesFile is where macOS's DHCP server records the leases it hands to
+// VMs on Virtualization.framework NAT networks.
+const NATLeasesFile = "/var/db/dhcpd_leases"
+
+// LookupDHCPLease returns the IP address leased to the hardware address mac
+// in a bootpd lease file such as NATLeasesFile. Entries look like:
+//
+//	{
+//		name=alpine
+//		ip_address=192.168.64.2
+//		hw_address=1,52:54:0:12:34:56
+//		...
+//	}
+//
+// bootpd drops leading zeros from the MAC octets, so addresses are
+// compared octet by octet.
+func LookupDHCPLease(path, mac string) (string, error) {
+	want, ok := normalizeMAC(mac)
+	if !ok {
+		return "", fmt.Errorf("invalid MAC address %q", mac)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	var ip, hw string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "{":
+			ip, hw = "", ""
+		case strings.HasPrefix(line, "ip_address="):
+			ip = strings.TrimPrefix(line, "ip_address=")
+		case strings.HasPrefix(line, "hw_address="):
+			// The hardware type comes first, e.g. "1," for Ethernet
+			hw = strings.TrimPrefix(line, "hw_address=")
+			if i := strings.IndexByte(hw, ','); i >= 0 {
+				hw = hw[i+1:]
+			}
+		case line == "}":
+			if got, ok := normalizeMAC(hw); ok && got == want && ip != "" {
+				return ip, nil
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("no DHCP lease for %s in %s", mac, path)
+}
+
+// WaitForDHCPLease polls the lease file at path every interval until it
+// has a lease for mac, returning its IP address, or until ctx is done.
+func WaitForDHCPLease(ctx context.Context, path, mac string, interval time.Duration) (string, error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if ip, err := LookupDHCPLease(path, mac); err == nil {
+			return ip, nil
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+}
+
+// normalizeMAC returns mac with every octet as two lowercase hex digits.
+func normalizeMAC(mac string) (string, bool) {
+	parts := strings.Split(mac, ":")
+	if len(parts) != 6 {
+		return "", false
+	}
+	for i, p := range parts {
+		n, err := strconv.ParseUint(p, 16, 8)
+		if err != nil {
+			return "", false
+		}
+		parts[i] = fmt.Sprintf("%02x", n)
+	}
+	return strings.Join(parts, ":"), true
+}
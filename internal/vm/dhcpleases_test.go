@@ -0,0 +1,65 @@
+package vm
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLookupDHCPLease(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dhcpd_leases")
+	leases := `{
+	name=ubuntu
+	ip_address=192.168.64.3
+	hw_address=1,52:54:0:ab:cd:1
+	identifier=1,52:54:0:ab:cd:1
+	lease=0x6553f2a1
+}
+{
+	name=alpine
+	ip_address=192.168.64.2
+	hw_address=1,52:54:0:12:34:56
+	identifier=1,52:54:0:12:34:56
+	lease=0x6553f1b0
+}
+`
+	if err := os.WriteFile(path, []byte(leases), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ip, err := LookupDHCPLease(path, "52:54:00:12:34:56")
+	if err != nil || ip != "192.168.64.2" {
+		t.Errorf("LookupDHCPLease = %q, %v; want 192.168.64.2", ip, err)
+	}
+	if ip, _ := LookupDHCPLease(path, "52:54:00:AB:CD:01"); ip != "192.168.64.3" {
+		t.Errorf("LookupDHCPLease with upper case = %q, want 192.168.64.3", ip)
+	}
+	if _, err := LookupDHCPLease(path, "52:54:00:00:00:01"); err == nil {
+		t.Error("expected error for a MAC without a lease")
+	}
+	if _, err := LookupDHCPLease(path, "not-a-mac"); err == nil {
+		t.Error("expected error for an invalid MAC")
+	}
+}
+
+func TestWaitForDHCPLease(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dhcpd_leases")
+
+	// The lease appears after the first poll
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		os.WriteFile(path, []byte("{\n\tip_address=192.168.64.5\n\thw_address=1,52:54:0:12:34:56\n}\n"), 0644)
+	}()
+	ip, err := WaitForDHCPLease(context.Background(), path, "52:54:00:12:34:56", 10*time.Millisecond)
+	if err != nil || ip != "192.168.64.5" {
+		t.Errorf("WaitForDHCPLease = %q, %v; want 192.168.64.5", ip, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := WaitForDHCPLease(ctx, path, "52:54:00:00:00:01", 10*time.Millisecond); err == nil {
+		t.Error("expected error when no lease appears")
+	}
+}
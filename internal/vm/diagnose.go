@@ -0,0 +1,126 @@
+package vm
+
+import (
+	"os"
+	"strings"
+
+	"github.com/javanstorm/vmterminal/pkg/hypervisor"
+)
+
+// minKernelSize is the smallest plausible kernel image. Smaller cached
+// kernels are truncated downloads or extractions.
+const minKernelSize = 1 << 20
+
+// DiagFile is a boot file looked at by diagnose.
+type DiagFile struct {
+	Path   string `json:"path"`
+	Exists bool   `json:"exists"`
+	Size   int64  `json:"size"`
+}
+
+// StatDiagFile returns the DiagFile for path.
+func StatDiagFile(path string) DiagFile {
+	f := DiagFile{Path: path}
+	if info, err := os.Stat(path); err == nil {
+		f.Exists = true
+		f.Size = info.Size()
+	}
+	return f
+}
+
+// DiagInfo is what diagnose collects about a VM and its host.
+type DiagInfo struct {
+	VM     string   `json:"vm"`
+	Distro string   `json:"distro"`
+	Kernel DiagFile `json:"kernel"`
+	Initrd DiagFile `json:"initrd"`
+	Disk   DiagFile `json:"disk"`
+
+	// UEFI is set when the distro boots its disk through UEFI firmware,
+	// so there is no cached kernel or initramfs to look at.
+	UEFI bool `json:"uefi,omitempty"`
+
+	// HasInitrd is set when the distro boots with an initramfs.
+	HasInitrd bool `json:"has_initrd"`
+
+	// BootLog is the end of the VM's console log, if one was recorded.
+	BootLog string `json:"boot_log,omitempty"`
+
+	// Dmesg is the end of the host kernel log, if it is readable.
+	Dmesg string `json:"dmesg,omitempty"`
+
+	Host []hypervisor.HostCheck `json:"host"`
+}
+
+// hostCheckFailed reports whether the host check called name ran and failed.
+func (d *DiagInfo) hostCheckFailed(name string) bool {
+	for _, c := range d.Host {
+		if c.Name == name {
+			return !c.OK
+		}
+	}
+	return false
+}
+
+// DiagRule is a known cause of boot failures: Matcher recognizes it in the
+// collected information and Advice tells the user how to fix it.
+type DiagRule struct {
+	Name    string
+	Matcher func(*DiagInfo) bool
+	Advice  string
+}
+
+// DiagRules are the rules Diagnose applies, in the order they are reported.
+var DiagRules = []DiagRule{
+	{
+		Name:    "kernel-missing",
+		Matcher: func(d *DiagInfo) bool { return !d.UEFI && !d.Kernel.Exists },
+		Advice:  "The kernel is not in the cache: run 'vmterminal setup'",
+	},
+	{
+		Name:    "kernel-too-small",
+		Matcher: func(d *DiagInfo) bool { return !d.UEFI && d.Kernel.Exists && d.Kernel.Size < minKernelSize },
+		Advice:  "The cached kernel is too small to be valid, the cache may be corrupt: run 'vmterminal cache clear'",
+	},
+	{
+		Name:    "initrd-missing",
+		Matcher: func(d *DiagInfo) bool { return !d.UEFI && d.HasInitrd && d.Kernel.Exists && !d.Initrd.Exists },
+		Advice:  "The initramfs is not in the cache: run 'vmterminal setup'",
+	},
+	{
+		Name:    "disk-missing",
+		Matcher: func(d *DiagInfo) bool { return !d.Disk.Exists },
+		Advice:  "The VM disk does not exist: run 'vmterminal setup'",
+	},
+	{
+		Name:    "kvm-missing",
+		Matcher: func(d *DiagInfo) bool { return d.hostCheckFailed("/dev/kvm present") },
+		Advice:  "/dev/kvm is missing: load the kvm kernel module (sudo modprobe kvm_intel or kvm_amd) and enable virtualization in the firmware",
+	},
+	{
+		Name:    "kvm-permission",
+		Matcher: func(d *DiagInfo) bool { return d.hostCheckFailed("/dev/kvm accessible") },
+		Advice:  "/dev/kvm is not accessible: sudo usermod -aG kvm $USER, then log in again",
+	},
+	{
+		Name:    "root-mount-failed",
+		Matcher: func(d *DiagInfo) bool { return strings.Contains(d.BootLog, "Unable to mount root fs") },
+		Advice:  "The kernel could not mount the root filesystem: run 'vmterminal check' on the disk, or 'vmterminal setup' if it was never set up",
+	},
+	{
+		Name:    "kernel-panic",
+		Matcher: func(d *DiagInfo) bool { return strings.Contains(d.BootLog, "Kernel panic") },
+		Advice:  "The guest kernel panicked: see the boot log above, and try 'vmterminal cache clear' if the kernel was updated",
+	},
+}
+
+// Diagnose returns the rules that match info.
+func Diagnose(info *DiagInfo) []DiagRule {
+	var matched []DiagRule
+	for _, r := range DiagRules {
+		if r.Matcher(info) {
+			matched = append(matched, r)
+		}
+	}
+	return matched
+}
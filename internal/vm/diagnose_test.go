@@ -0,0 +1,97 @@
+package vm
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/javanstorm/vmterminal/pkg/hypervisor"
+)
+
+// healthyDiagInfo returns information that no rule matches.
+func healthyDiagInfo() *DiagInfo {
+	return &DiagInfo{
+		VM:     "default",
+		Kernel: DiagFile{Path: "vmlinuz", Exists: true, Size: 8 << 20},
+		Initrd: DiagFile{Path: "initramfs", Exists: true, Size: 4 << 20},
+		Disk:   DiagFile{Path: "disk.raw", Exists: true, Size: 10 << 30},
+
+		HasInitrd: true,
+		Host: []hypervisor.HostCheck{
+			{Name: "/dev/kvm present", OK: true, Required: true},
+			{Name: "/dev/kvm accessible", OK: true, Required: true},
+		},
+		BootLog: "Welcome to Alpine Linux 3.21\nlocalhost login: ",
+	}
+}
+
+func TestDiagnoseHealthy(t *testing.T) {
+	if matched := Diagnose(healthyDiagInfo()); len(matched) != 0 {
+		t.Errorf("Diagnose on a healthy VM matched %v", matched)
+	}
+}
+
+func TestDiagRules(t *testing.T) {
+	tests := map[string]func(*DiagInfo){
+		"kernel-missing":    func(d *DiagInfo) { d.Kernel = DiagFile{Path: "vmlinuz"} },
+		"kernel-too-small":  func(d *DiagInfo) { d.Kernel.Size = 512 },
+		"initrd-missing":    func(d *DiagInfo) { d.Initrd = DiagFile{Path: "initramfs"} },
+		"disk-missing":      func(d *DiagInfo) { d.Disk = DiagFile{Path: "disk.raw"} },
+		"kvm-missing":       func(d *DiagInfo) { d.Host = []hypervisor.HostCheck{{Name: "/dev/kvm present"}} },
+		"kvm-permission":    func(d *DiagInfo) { d.Host[1].OK = false },
+		"root-mount-failed": func(d *DiagInfo) { d.BootLog = "VFS: Unable to mount root fs on unknown-block(0,0)" },
+		"kernel-panic":      func(d *DiagInfo) { d.BootLog = "Kernel panic - not syncing: Attempted to kill init!" },
+	}
+	if len(tests) != len(DiagRules) {
+		t.Errorf("%d rules tested, want all %d", len(tests), len(DiagRules))
+	}
+	for _, rule := range DiagRules {
+		breakIt, ok := tests[rule.Name]
+		if !ok {
+			t.Errorf("no test for rule %s", rule.Name)
+			continue
+		}
+		info := healthyDiagInfo()
+		breakIt(info)
+		matched := Diagnose(info)
+		if len(matched) != 1 || matched[0].Name != rule.Name {
+			var names []string
+			for _, m := range matched {
+				names = append(names, m.Name)
+			}
+			t.Errorf("rule %s: Diagnose matched %v, want only it", rule.Name, names)
+		}
+	}
+}
+
+func TestDiagRulesBootFiles(t *testing.T) {
+	// A distro without an initramfs does not need one in the cache
+	info := healthyDiagInfo()
+	info.HasInitrd = false
+	info.Initrd = DiagFile{Path: "initramfs"}
+	if matched := Diagnose(info); len(matched) != 0 {
+		t.Errorf("Diagnose without an initramfs matched %v", matched)
+	}
+
+	// UEFI boots the disk, so neither kernel nor initramfs is cached
+	info = healthyDiagInfo()
+	info.UEFI = true
+	info.Kernel = DiagFile{Path: "vmlinuz"}
+	info.Initrd = DiagFile{Path: "initramfs"}
+	if matched := Diagnose(info); len(matched) != 0 {
+		t.Errorf("Diagnose on a UEFI VM matched %v", matched)
+	}
+}
+
+func TestStatDiagFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vmlinuz")
+	if f := StatDiagFile(path); f.Exists {
+		t.Errorf("StatDiagFile on a missing file = %+v", f)
+	}
+	if err := os.WriteFile(path, make([]byte, 100), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if f := StatDiagFile(path); !f.Exists || f.Size != 100 || f.Path != path {
+		t.Errorf("StatDiagFile = %+v, want an existing 100-byte file", f)
+	}
+}
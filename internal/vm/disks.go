@@ -0,0 +1,59 @@
+package vm
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/javanstorm/vmterminal/pkg/hypervisor"
+)
+
+// RootDiskName is the name of the root disk image of distros whose rootfs
+// is extracted into a disk of their own.
+const RootDiskName = "disk"
+
+// DiskConfig is a data disk attached to a VM after its root disk. The
+// image is <Name>.raw in the VM's data directory; it is created sparse on
+// the first boot and kept when the VM switches distro.
+type DiskConfig struct {
+	Name     string `json:"name"`
+	SizeMB   int64  `json:"size_mb"`
+	ReadOnly bool   `json:"read_only,omitempty"`
+}
+
+// diskNamePattern matches names that are safe as file names.
+var diskNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// Validate checks the disk's name and size.
+func (d DiskConfig) Validate() error {
+	if !diskNamePattern.MatchString(d.Name) {
+		return fmt.Errorf("invalid disk name %q: use lowercase letters, digits, '-' and '_'", d.Name)
+	}
+	if d.Name == RootDiskName || d.Name == OverlayDiskName {
+		return fmt.Errorf("disk name %q is reserved", d.Name)
+	}
+	if d.SizeMB < 1 {
+		return fmt.Errorf("disk %s: size must be at least 1 MB, got %d", d.Name, d.SizeMB)
+	}
+	return nil
+}
+
+// additionalDisks returns the storage devices of the configured data
+// disks, in order.
+func (m *Manager) additionalDisks() []hypervisor.StorageDevice {
+	var devices []hypervisor.StorageDevice
+	for _, d := range m.cfg.AdditionalDisks {
+		devices = append(devices, hypervisor.StorageDevice{Path: m.images.DiskPath(d.Name), ReadOnly: d.ReadOnly})
+	}
+	return devices
+}
+
+// ensureAdditionalDisks creates the images of data disks that do not
+// exist yet.
+func (m *Manager) ensureAdditionalDisks() error {
+	for _, d := range m.cfg.AdditionalDisks {
+		if _, err := m.images.EnsureDisk(d.Name, d.SizeMB); err != nil {
+			return fmt.Errorf("disk %s: %w", d.Name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,26 @@
+package vm
+
+import "testing"
+
+func TestDiskConfigValidate(t *testing.T) {
+	tests := []struct {
+		disk    DiskConfig
+		wantErr bool
+	}{
+		{DiskConfig{Name: "data", SizeMB: 20480}, false},
+		{DiskConfig{Name: "pg_data-2", SizeMB: 1, ReadOnly: true}, false},
+		{DiskConfig{Name: "", SizeMB: 1024}, true},
+		{DiskConfig{Name: "Data", SizeMB: 1024}, true},
+		{DiskConfig{Name: "../data", SizeMB: 1024}, true},
+		{DiskConfig{Name: "-data", SizeMB: 1024}, true},
+		{DiskConfig{Name: RootDiskName, SizeMB: 1024}, true},
+		{DiskConfig{Name: OverlayDiskName, SizeMB: 1024}, true},
+		{DiskConfig{Name: "data", SizeMB: 0}, true},
+	}
+	for _, tt := range tests {
+		err := tt.disk.Validate()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Validate(%+v) error = %v, wantErr %v", tt.disk, err, tt.wantErr)
+		}
+	}
+}
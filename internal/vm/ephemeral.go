@@ -0,0 +1,31 @@
+package vm
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// CreateEphemeralDisk copies the disk image at src to a new directory under
+// os.TempDir, so a VM can boot from it without touching src. The copy is a
+// reflink where the filesystem allows, making it near-instant. cleanup
+// removes the copy and is safe to call more than once.
+func CreateEphemeralDisk(src string) (tmpPath string, cleanup func(), err error) {
+	if _, err := os.Stat(src); err != nil {
+		return "", nil, fmt.Errorf("base disk: %w", err)
+	}
+
+	dir, err := os.MkdirTemp("", "vmterminal-ephemeral-")
+	if err != nil {
+		return "", nil, fmt.Errorf("create ephemeral dir: %w", err)
+	}
+	cleanup = func() { os.RemoveAll(dir) }
+
+	tmpPath = filepath.Join(dir, filepath.Base(src))
+	if _, err := CopyDisk(src, tmpPath); err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("copy base disk: %w", err)
+	}
+
+	return tmpPath, cleanup, nil
+}
@@ -0,0 +1,45 @@
+package vm
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCreateEphemeralDisk(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "disk.raw")
+	if err := os.WriteFile(src, []byte("base disk"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tmpPath, cleanup, err := CreateEphemeralDisk(src)
+	if err != nil {
+		t.Fatalf("CreateEphemeralDisk failed: %v", err)
+	}
+	defer cleanup()
+
+	if !strings.HasPrefix(tmpPath, os.TempDir()) {
+		t.Errorf("ephemeral disk %s is not under %s", tmpPath, os.TempDir())
+	}
+
+	// Writes to the copy must not reach the base disk
+	if err := os.WriteFile(tmpPath, []byte("dirty"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(src); string(data) != "base disk" {
+		t.Errorf("base disk changed to %q", data)
+	}
+
+	cleanup()
+	if _, err := os.Stat(filepath.Dir(tmpPath)); !os.IsNotExist(err) {
+		t.Error("cleanup should remove the ephemeral directory")
+	}
+	cleanup()
+}
+
+func TestCreateEphemeralDiskMissing(t *testing.T) {
+	if _, _, err := CreateEphemeralDisk(filepath.Join(t.TempDir(), "missing.raw")); err == nil {
+		t.Error("expected error for missing base disk")
+	}
+}
@@ -0,0 +1,47 @@
+package vm
+
+import "fmt"
+
+// ErrVMNotRunning is returned by operations that need a running VM. Name
+// is the VM's name when the caller knows it.
+type ErrVMNotRunning struct {
+	Name string
+}
+
+func (e *ErrVMNotRunning) Error() string {
+	if e.Name == "" {
+		return "VM not running"
+	}
+	return fmt.Sprintf("VM '%s' not running", e.Name)
+}
+
+// ErrVMNotFound is returned when no VM called Name is registered.
+type ErrVMNotFound struct {
+	Name string
+}
+
+func (e *ErrVMNotFound) Error() string {
+	return fmt.Sprintf("VM '%s' not found", e.Name)
+}
+
+// ErrSnapshotCorrupted is returned when a snapshot's data does not match
+// the SHA-256 checksum recorded for it.
+type ErrSnapshotCorrupted struct {
+	Name     string
+	Expected string
+	Got      string
+}
+
+func (e *ErrSnapshotCorrupted) Error() string {
+	return fmt.Sprintf("snapshot '%s' corrupted: checksum mismatch: expected %s, got %s", e.Name, e.Expected, e.Got)
+}
+
+// ErrSnapshotNoChecksum is returned when verifying a snapshot taken before
+// checksums were recorded, which cannot be checked.
+type ErrSnapshotNoChecksum struct {
+	Name string
+}
+
+func (e *ErrSnapshotNoChecksum) Error() string {
+	return fmt.Sprintf("snapshot '%s' has no checksum (created before checksum support)", e.Name)
+}
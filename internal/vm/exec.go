@@ -0,0 +1,133 @@
+package vm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// execDialTimeout bounds how long ExecOverSSH waits to connect.
+const execDialTimeout = 10 * time.Second
+
+// SSHConfig describes how to reach a VM's SSH server and where a remote
+// command's I/O goes.
+type SSHConfig struct {
+	Host    string // Defaults to localhost
+	Port    int
+	User    string // Defaults to root
+	KeyPath string // Private key file
+
+	// Socket is a Unix socket leading to the guest's SSH server, such as
+	// VsockSocketPath. It is tried before Host:Port, which is used as a
+	// fallback when Port is set.
+	Socket string
+
+	Stdin  io.Reader // nil = no input
+	Stdout io.Writer // nil = discarded
+	Stderr io.Writer // nil = discarded
+}
+
+// ExecOverSSH runs command in the VM and streams its output to cfg.Stdout
+// and cfg.Stderr. It returns the remote exit code once the command
+// finishes; err is set only when the command could not be run or its exit
+// status is unknown. Cancelling ctx closes the connection.
+func ExecOverSSH(ctx context.Context, cfg SSHConfig, command string) (int, error) {
+	if cfg.Host == "" {
+		cfg.Host = "localhost"
+	}
+	if cfg.User == "" {
+		cfg.User = "root"
+	}
+
+	keyData, err := os.ReadFile(cfg.KeyPath)
+	if err != nil {
+		return -1, fmt.Errorf("read SSH key: %w", err)
+	}
+	signer, err := ssh.ParsePrivateKey(keyData)
+	if err != nil {
+		return -1, fmt.Errorf("parse SSH key: %w", err)
+	}
+
+	clientCfg := &ssh.ClientConfig{
+		User: cfg.User,
+		Auth: []ssh.AuthMethod{ssh.PublicKeys(signer)},
+		// The VM is only reachable through a local port forward or vsock,
+		// and its host key changes whenever the disk is recreated
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		Timeout:         execDialTimeout,
+	}
+
+	client, err := dialSSH(ctx, cfg, clientCfg)
+	if err != nil {
+		return -1, err
+	}
+	defer client.Close()
+
+	session, err := client.NewSession()
+	if err != nil {
+		return -1, fmt.Errorf("open SSH session: %w", err)
+	}
+	defer session.Close()
+
+	session.Stdin = cfg.Stdin
+	session.Stdout = cfg.Stdout
+	session.Stderr = cfg.Stderr
+
+	// Tear down the connection if the caller gives up
+	stop := context.AfterFunc(ctx, func() { client.Close() })
+	defer stop()
+
+	err = session.Run(command)
+	if ctx.Err() != nil {
+		return -1, ctx.Err()
+	}
+
+	var exitErr *ssh.ExitError
+	switch {
+	case err == nil:
+		return 0, nil
+	case errors.As(err, &exitErr):
+		return exitErr.ExitStatus(), nil
+	default:
+		return -1, fmt.Errorf("run command: %w", err)
+	}
+}
+
+// dialSSH connects to the VM through cfg.Socket if set, falling back to
+// TCP on cfg.Host:cfg.Port.
+func dialSSH(ctx context.Context, cfg SSHConfig, clientCfg *ssh.ClientConfig) (*ssh.Client, error) {
+	if cfg.Socket != "" {
+		client, err := sshClient(ctx, "unix", cfg.Socket, clientCfg)
+		if err == nil || cfg.Port == 0 {
+			return client, err
+		}
+	}
+	return sshClient(ctx, "tcp", net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port)), clientCfg)
+}
+
+// sshClient dials addr and performs the SSH handshake.
+func sshClient(ctx context.Context, network, addr string, clientCfg *ssh.ClientConfig) (*ssh.Client, error) {
+	dialer := net.Dialer{Timeout: execDialTimeout}
+	conn, err := dialer.DialContext(ctx, network, addr)
+	if err != nil {
+		return nil, fmt.Errorf("connect to %s: %w", addr, err)
+	}
+
+	// A proxy socket accepts even when nothing listens in the guest, so
+	// bound the handshake as well as the dial
+	conn.SetDeadline(time.Now().Add(execDialTimeout))
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, addr, clientCfg)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("SSH handshake with %s: %w", addr, err)
+	}
+	conn.SetDeadline(time.Time{})
+	return ssh.NewClient(sshConn, chans, reqs), nil
+}
@@ -0,0 +1,174 @@
+package vm
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"net"
+	"os"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// startExecServer runs an SSH server on localhost that accepts clientKey and
+// answers exec requests with handle's output and exit status.
+func startExecServer(t *testing.T, clientKey ssh.PublicKey, handle func(cmd string) (stdout, stderr string, status uint32)) int {
+	t.Helper()
+
+	_, hostPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	hostSigner, err := ssh.NewSignerFromKey(hostPriv)
+	if err != nil {
+		t.Fatalf("NewSignerFromKey: %v", err)
+	}
+
+	cfg := &ssh.ServerConfig{
+		PublicKeyCallback: func(_ ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if !bytes.Equal(key.Marshal(), clientKey.Marshal()) {
+				return nil, ssh.ErrNoAuth
+			}
+			return nil, nil
+		},
+	}
+	cfg.AddHostKey(hostSigner)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serveExecConn(conn, cfg, handle)
+		}
+	}()
+
+	return ln.Addr().(*net.TCPAddr).Port
+}
+
+func serveExecConn(conn net.Conn, cfg *ssh.ServerConfig, handle func(string) (string, string, uint32)) {
+	_, chans, reqs, err := ssh.NewServerConn(conn, cfg)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+
+	for newCh := range chans {
+		ch, chReqs, err := newCh.Accept()
+		if err != nil {
+			continue
+		}
+		go func() {
+			defer ch.Close()
+			for req := range chReqs {
+				if req.Type != "exec" {
+					req.Reply(false, nil)
+					continue
+				}
+				var payload struct{ Command string }
+				ssh.Unmarshal(req.Payload, &payload)
+				req.Reply(true, nil)
+
+				stdout, stderr, status := handle(payload.Command)
+				ch.Write([]byte(stdout))
+				ch.Stderr().Write([]byte(stderr))
+				var st [4]byte
+				binary.BigEndian.PutUint32(st[:], status)
+				ch.SendRequest("exit-status", false, st[:])
+				return
+			}
+		}()
+	}
+}
+
+func TestExecOverSSH(t *testing.T) {
+	keys := NewSSHKeyManager(t.TempDir())
+	privPath, _, err := keys.EnsureKeyPair()
+	if err != nil {
+		t.Fatalf("EnsureKeyPair: %v", err)
+	}
+	keyData, err := os.ReadFile(privPath)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	signer, err := ssh.ParsePrivateKey(keyData)
+	if err != nil {
+		t.Fatalf("ParsePrivateKey: %v", err)
+	}
+	clientKey := signer.PublicKey()
+
+	port := startExecServer(t, clientKey, func(cmd string) (string, string, uint32) {
+		if cmd == "false" {
+			return "", "failed\n", 3
+		}
+		return "ran: " + cmd + "\n", "", 0
+	})
+
+	tests := []struct {
+		name       string
+		command    string
+		wantCode   int
+		wantStdout string
+		wantStderr string
+	}{
+		{"success", "make test", 0, "ran: make test\n", ""},
+		{"failure", "false", 3, "", "failed\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			code, err := ExecOverSSH(context.Background(), SSHConfig{
+				Host:    "127.0.0.1",
+				Port:    port,
+				KeyPath: privPath,
+				Stdout:  &stdout,
+				Stderr:  &stderr,
+			}, tt.command)
+			if err != nil {
+				t.Fatalf("ExecOverSSH: %v", err)
+			}
+			if code != tt.wantCode {
+				t.Errorf("exit code = %d, want %d", code, tt.wantCode)
+			}
+			if stdout.String() != tt.wantStdout {
+				t.Errorf("stdout = %q, want %q", stdout.String(), tt.wantStdout)
+			}
+			if stderr.String() != tt.wantStderr {
+				t.Errorf("stderr = %q, want %q", stderr.String(), tt.wantStderr)
+			}
+		})
+	}
+}
+
+func TestExecOverSSHConnectionRefused(t *testing.T) {
+	keys := NewSSHKeyManager(t.TempDir())
+	privPath, _, err := keys.EnsureKeyPair()
+	if err != nil {
+		t.Fatalf("EnsureKeyPair: %v", err)
+	}
+
+	// Grab a free port and close it so nothing is listening
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+
+	_, err = ExecOverSSH(context.Background(), SSHConfig{Host: "127.0.0.1", Port: port, KeyPath: privPath}, "true")
+	if err == nil || !strings.Contains(err.Error(), "connect to") {
+		t.Errorf("expected connection error, got %v", err)
+	}
+}
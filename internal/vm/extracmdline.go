@@ -0,0 +1,69 @@
+package vm
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// DefaultExtraCmdlineFile is the name, in ~/.vmterminal, of the file of
+// extra kernel arguments read when config.State.ExtraCmdlineFile is unset.
+const DefaultExtraCmdlineFile = "extra_cmdline"
+
+// maxExtraCmdlineLen limits the extra kernel arguments, keeping the full
+// command line well inside the kernel's limit.
+const maxExtraCmdlineLen = 512
+
+// riskyKernelArgs are kernel arguments that weaken the guest's security.
+// They are allowed, with a warning.
+var riskyKernelArgs = map[string]bool{
+	"mitigations=off":               true,
+	"nokaslr":                       true,
+	"nopti":                         true,
+	"pti=off":                       true,
+	"nosmap":                        true,
+	"nosmep":                        true,
+	"spectre_v2=off":                true,
+	"spec_store_bypass_disable=off": true,
+	"selinux=0":                     true,
+	"enforcing=0":                   true,
+	"apparmor=0":                    true,
+	"module.sig_enforce=0":          true,
+	"lockdown=none":                 true,
+}
+
+// ReadExtraCmdline reads kernel arguments to append to every VM's command
+// line from path. Lines starting with '#' are comments and line breaks
+// separate arguments like spaces. A missing file means no arguments.
+// Arguments longer than 512 characters in total, and init= or rdinit=,
+// which would replace the guest's init, are rejected. The returned
+// warnings name arguments that weaken the guest's security.
+func ReadExtraCmdline(path string) (args, warnings []string, err error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("read extra kernel arguments: %w", err)
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+		args = append(args, strings.Fields(line)...)
+	}
+
+	if n := len(strings.Join(args, " ")); n > maxExtraCmdlineLen {
+		return nil, nil, fmt.Errorf("%s: extra kernel arguments are %d characters, at most %d are allowed", path, n, maxExtraCmdlineLen)
+	}
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "init=") || strings.HasPrefix(arg, "rdinit=") {
+			return nil, nil, fmt.Errorf("%s: %q is not allowed, it would replace the guest's init", path, arg)
+		}
+		if riskyKernelArgs[arg] {
+			warnings = append(warnings, fmt.Sprintf("%s: %q weakens the guest's security", path, arg))
+		}
+	}
+	return args, warnings, nil
+}
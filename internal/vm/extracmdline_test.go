@@ -0,0 +1,76 @@
+package vm
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadExtraCmdline(t *testing.T) {
+	dir := t.TempDir()
+	write := func(content string) string {
+		path := filepath.Join(dir, "extra_cmdline")
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	args, warnings, err := ReadExtraCmdline(filepath.Join(dir, "missing"))
+	if err != nil || args != nil || warnings != nil {
+		t.Errorf("missing file = %v, %v, %v", args, warnings, err)
+	}
+
+	args, warnings, err = ReadExtraCmdline(write("# Isolate CPUs for benchmarks\nnohz_full=1-3  isolcpus=1-3\r\n\nmitigations=off\n"))
+	if err != nil {
+		t.Fatalf("ReadExtraCmdline: %v", err)
+	}
+	if want := []string{"nohz_full=1-3", "isolcpus=1-3", "mitigations=off"}; !reflect.DeepEqual(args, want) {
+		t.Errorf("args = %q, want %q", args, want)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "mitigations=off") {
+		t.Errorf("warnings = %q, want one about mitigations=off", warnings)
+	}
+
+	for _, content := range []string{
+		"quiet init=/bin/sh",
+		"rdinit=/evil",
+		strings.Repeat("a", 513),
+	} {
+		if _, _, err := ReadExtraCmdline(write(content)); err == nil {
+			t.Errorf("expected error for %.40q", content)
+		}
+	}
+}
+
+func TestDryRunConfigExtraCmdline(t *testing.T) {
+	cfg := createTestConfig(t)
+	cfg.ExtraCmdlineFile = filepath.Join(t.TempDir(), "extra_cmdline")
+	cfg.KernelArgs = []string{"debug"}
+	if err := os.WriteFile(cfg.ExtraCmdlineFile, []byte("nohz_full=1-3\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	m := &Manager{
+		cfg:    cfg,
+		assets: NewAssetManager(cfg.CacheDir, cfg.Provider, nil),
+		images: NewImageManager(cfg.DataDir),
+	}
+
+	vmCfg, err := m.DryRunConfig()
+	if err != nil {
+		t.Fatalf("DryRunConfig: %v", err)
+	}
+	// The VM's own arguments come last so they win over the file's
+	if !strings.HasSuffix(vmCfg.Cmdline, " nohz_full=1-3 debug") {
+		t.Errorf("Cmdline = %q, want the file's arguments before the VM's", vmCfg.Cmdline)
+	}
+
+	if err := os.WriteFile(cfg.ExtraCmdlineFile, []byte("init=/bin/sh\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.DryRunConfig(); err == nil {
+		t.Error("expected error for init= in the extra cmdline file")
+	}
+}
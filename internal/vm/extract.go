@@ -2,10 +2,12 @@ package vm
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
 	"sort"
 	"strings"
 
@@ -15,6 +17,11 @@ import (
 // KernelExtractor handles extracting kernel and initrd from various archive formats.
 type KernelExtractor struct {
 	cacheDir string
+
+	// UseNativeTar reads tarballs with the system tar instead of the
+	// built-in reader. The system tar is also used when the built-in reader
+	// does not support an archive's compression.
+	UseNativeTar bool
 }
 
 // NewKernelExtractor creates a new kernel extractor with the given cache directory.
@@ -74,6 +81,13 @@ func (e *KernelExtractor) extractFromTarball(archivePath string, locator *distro
 
 // listTarball lists all files in a tarball archive.
 func (e *KernelExtractor) listTarball(archivePath string) ([]string, error) {
+	if !e.UseNativeTar {
+		files, err := ListTarFiles(archivePath)
+		if !errors.Is(err, errUnsupportedTar) {
+			return files, err
+		}
+	}
+
 	var cmd *exec.Cmd
 
 	switch {
@@ -107,6 +121,13 @@ func (e *KernelExtractor) listTarball(archivePath string) ([]string, error) {
 
 // extractTarFile extracts a single file from a tarball to destination.
 func (e *KernelExtractor) extractTarFile(archive, file, dest string) error {
+	if !e.UseNativeTar {
+		err := ExtractFile(archive, file, dest)
+		if !errors.Is(err, errUnsupportedTar) {
+			return err
+		}
+	}
+
 	var cmd *exec.Cmd
 
 	switch {
@@ -274,9 +295,47 @@ func (e *KernelExtractor) extractFromISO(archivePath string, locator *distro.Ker
 		return "", "", fmt.Errorf("extract initrd: %w", err)
 	}
 
+	if len(locator.InitConfigPatterns) > 0 {
+		if err := e.extractISOInit(archivePath, files, locator.InitConfigPatterns); err != nil {
+			return "", "", err
+		}
+	}
+
 	return kernelDest, initrdDest, nil
 }
 
+// initArgFile holds the init= argument copied from an image's bootloader
+// config, next to the extracted kernel.
+const initArgFile = "init-arg"
+
+// bootInitPattern matches the init= argument of a kernel command line.
+var bootInitPattern = regexp.MustCompile(`(?:^|\s)init=(\S+)`)
+
+// extractISOInit copies the init= argument from the first bootloader config
+// matching patterns into initArgFile.
+func (e *KernelExtractor) extractISOInit(isoPath string, files, patterns []string) error {
+	configFile := e.findMatchingFile(files, patterns)
+	if configFile == "" {
+		return fmt.Errorf("bootloader config not found in ISO (tried: %v)", patterns)
+	}
+
+	tmp := filepath.Join(e.cacheDir, initArgFile+".cfg")
+	defer os.Remove(tmp)
+	if err := e.extractISOFile(isoPath, configFile, tmp); err != nil {
+		return fmt.Errorf("extract bootloader config: %w", err)
+	}
+	data, err := os.ReadFile(tmp)
+	if err != nil {
+		return err
+	}
+
+	m := bootInitPattern.FindSubmatch(data)
+	if m == nil {
+		return fmt.Errorf("no init= argument in %s", configFile)
+	}
+	return os.WriteFile(filepath.Join(e.cacheDir, initArgFile), m[1], 0644)
+}
+
 // listISO lists files in an ISO image.
 func (e *KernelExtractor) listISO(isoPath string) ([]string, error) {
 	// Try bsdtar first
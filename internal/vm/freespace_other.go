@@ -0,0 +1,10 @@
+//go:build !darwin && !linux
+
+package vm
+
+import "errors"
+
+// freeBytes is not supported on this platform.
+func freeBytes(path string) (int64, error) {
+	return 0, errors.New("free space check not supported on this platform")
+}
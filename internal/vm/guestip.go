@@ -0,0 +1,58 @@
+package vm
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+)
+
+// NeighborTableFile is the Linux kernel's IPv4 neighbour (ARP) table.
+const NeighborTableFile = "/proc/net/arp"
+
+// LookupGuestIP returns the IPv4 address of the guest whose network
+// interface has the hardware address mac: from the NAT's DHCP leases on
+// macOS, and from the host's neighbour table on Linux, where the guest sits
+// behind a tap device on a bridge the host talks to.
+func LookupGuestIP(mac string) (string, error) {
+	if runtime.GOOS == "darwin" {
+		return LookupDHCPLease(NATLeasesFile, mac)
+	}
+	return LookupNeighbor(NeighborTableFile, mac)
+}
+
+// LookupNeighbor returns the IP address with the hardware address mac in a
+// neighbour table in the format of /proc/net/arp:
+//
+//	IP address       HW type     Flags       HW address            Mask     Device
+//	192.168.122.57   0x1         0x2         52:54:00:12:34:56     *        virbr0
+//
+// Incomplete entries (flags without 0x2) are skipped.
+func LookupNeighbor(path, mac string) (string, error) {
+	want, ok := normalizeMAC(mac)
+	if !ok {
+		return "", fmt.Errorf("invalid MAC address %q", mac)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Scan() // header
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 || fields[2] == "0x0" {
+			continue
+		}
+		if got, ok := normalizeMAC(fields[3]); ok && got == want {
+			return fields[0], nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("no neighbour entry for %s in %s", mac, path)
+}
@@ -0,0 +1,29 @@
+package vm
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLookupNeighbor(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "arp")
+	table := `IP address       HW type     Flags       HW address            Mask     Device
+192.168.122.1    0x1         0x2         52:54:00:aa:bb:cc     *        eth0
+192.168.122.57   0x1         0x2         52:54:00:12:34:56     *        virbr0
+192.168.122.60   0x1         0x0         52:54:00:00:00:01     *        virbr0
+`
+	if err := os.WriteFile(path, []byte(table), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if ip, err := LookupNeighbor(path, "52:54:0:12:34:56"); err != nil || ip != "192.168.122.57" {
+		t.Errorf("LookupNeighbor = %q, %v; want 192.168.122.57", ip, err)
+	}
+	if _, err := LookupNeighbor(path, "52:54:00:00:00:01"); err == nil {
+		t.Error("expected error for an incomplete entry")
+	}
+	if _, err := LookupNeighbor(path, "not-a-mac"); err == nil {
+		t.Error("expected error for an invalid MAC")
+	}
+}
@@ -0,0 +1,79 @@
+package vm
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Backoff bounds for WaitForSSH retries.
+const (
+	sshWaitInitialBackoff = 500 * time.Millisecond
+	sshWaitMaxBackoff     = 8 * time.Second
+)
+
+// sshProbe runs a trivial command over SSH and reports whether it succeeded.
+// It is a variable so tests can replace it.
+var sshProbe = func(ctx context.Context, cfg SSHConfig) error {
+	var out bytes.Buffer
+	cfg.Stdout = &out
+	code, err := ExecOverSSH(ctx, cfg, "echo ok")
+	if err != nil {
+		return err
+	}
+	if code != 0 {
+		return fmt.Errorf("probe exited with status %d", code)
+	}
+	if strings.TrimSpace(out.String()) != "ok" {
+		return fmt.Errorf("unexpected output %q", strings.TrimSpace(out.String()))
+	}
+	return nil
+}
+
+// sshTargetName describes where cfg connects, for error messages.
+func sshTargetName(cfg SSHConfig) string {
+	host := cfg.Host
+	if host == "" {
+		host = "localhost"
+	}
+	addr := net.JoinHostPort(host, strconv.Itoa(cfg.Port))
+	switch {
+	case cfg.Socket != "" && cfg.Port != 0:
+		return cfg.Socket + " or " + addr
+	case cfg.Socket != "":
+		return cfg.Socket
+	default:
+		return addr
+	}
+}
+
+// WaitForSSH polls the VM's SSH server until a command succeeds or timeout
+// expires. When cfg.Socket is set the vsock proxy is tried first on every
+// attempt. Retries back off exponentially starting at 500ms.
+func WaitForSSH(ctx context.Context, cfg SSHConfig, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	backoff := sshWaitInitialBackoff
+	for {
+		err := sshProbe(ctx, cfg)
+		if err == nil {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("SSH on %s not ready after %s: %w", sshTargetName(cfg), timeout, err)
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > sshWaitMaxBackoff {
+			backoff = sshWaitMaxBackoff
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package vm
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func stubSSHProbe(t *testing.T, probe func(ctx context.Context, cfg SSHConfig) error) {
+	t.Helper()
+	orig := sshProbe
+	sshProbe = probe
+	t.Cleanup(func() { sshProbe = orig })
+}
+
+func TestWaitForSSHRetriesUntilReady(t *testing.T) {
+	attempts := 0
+	stubSSHProbe(t, func(ctx context.Context, cfg SSHConfig) error {
+		attempts++
+		if attempts < 2 {
+			return errors.New("connection refused")
+		}
+		if cfg.Host != "localhost" || cfg.Port != 2222 || cfg.KeyPath != "/key" {
+			t.Errorf("probe config = %+v", cfg)
+		}
+		return nil
+	})
+
+	cfg := SSHConfig{Host: "localhost", Port: 2222, KeyPath: "/key"}
+	if err := WaitForSSH(context.Background(), cfg, 5*time.Second); err != nil {
+		t.Fatalf("WaitForSSH: %v", err)
+	}
+	if attempts != 2 {
+		t.Errorf("attempts = %d, want 2", attempts)
+	}
+}
+
+func TestWaitForSSHTimeout(t *testing.T) {
+	stubSSHProbe(t, func(ctx context.Context, cfg SSHConfig) error {
+		return errors.New("connection refused")
+	})
+
+	start := time.Now()
+	cfg := SSHConfig{Socket: "/data/vsock-ssh.sock", Port: 2222, KeyPath: "/key"}
+	err := WaitForSSH(context.Background(), cfg, 100*time.Millisecond)
+	if err == nil {
+		t.Fatal("expected timeout error")
+	}
+	if !strings.Contains(err.Error(), "/data/vsock-ssh.sock or localhost:2222") {
+		t.Errorf("error = %v, want both targets named", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("WaitForSSH took %s, should stop at the timeout", elapsed)
+	}
+}
@@ -0,0 +1,71 @@
+package vm
+
+import "fmt"
+
+// HostPackageManager builds the commands for the host's package manager.
+// It has the same methods as PackageManager, but each returns the argv to
+// run on the host, with sudo in front of the commands that change the
+// system on Linux.
+type HostPackageManager interface {
+	PackageManager
+}
+
+// DetectHostPackageManager returns the package manager of this host:
+// Homebrew on macOS, or the distribution's own on Linux.
+func DetectHostPackageManager() (HostPackageManager, error) {
+	return HostPackageManagerFor(detectHostOS())
+}
+
+// HostPackageManagerFor returns the package manager of a host OS family
+// as reported by DependencyManager.HostOS, e.g. "macos" or "ubuntu".
+func HostPackageManagerFor(hostOS string) (HostPackageManager, error) {
+	switch hostOS {
+	case "macos":
+		return BrewManager{}, nil
+	case "arch", "manjaro", "endeavouros":
+		return sudoManager{PacmanManager{}}, nil
+	case "ubuntu", "debian", "linuxmint", "pop":
+		return sudoManager{AptManager{}}, nil
+	case "fedora":
+		return sudoManager{DnfManager{}}, nil
+	case "rhel", "centos", "rocky", "almalinux":
+		return sudoManager{YumManager{}}, nil
+	case "opensuse", "suse":
+		return sudoManager{ZypperManager{}}, nil
+	case "alpine":
+		return sudoManager{ApkManager{}}, nil
+	case "void":
+		return sudoManager{XbpsManager{}}, nil
+	default:
+		return nil, fmt.Errorf("unsupported host OS: %s", hostOS)
+	}
+}
+
+// sudoManager runs a Linux package manager on the host, through sudo for
+// everything but queries.
+type sudoManager struct {
+	PackageManager
+}
+
+func sudo(cmd []string) []string { return append([]string{"sudo"}, cmd...) }
+
+func (m sudoManager) Install(pkgs ...string) []string { return sudo(m.PackageManager.Install(pkgs...)) }
+func (m sudoManager) Remove(pkgs ...string) []string  { return sudo(m.PackageManager.Remove(pkgs...)) }
+func (m sudoManager) Update() []string                { return sudo(m.PackageManager.Update()) }
+func (m sudoManager) Upgrade() []string               { return sudo(m.PackageManager.Upgrade()) }
+
+// BrewManager drives Homebrew on macOS. Homebrew refuses to run as root,
+// so none of its commands use sudo.
+type BrewManager struct{}
+
+func (BrewManager) Name() string { return "brew" }
+func (BrewManager) Install(pkgs ...string) []string {
+	return withArgs([]string{"brew", "install"}, pkgs...)
+}
+func (BrewManager) Remove(pkgs ...string) []string {
+	return withArgs([]string{"brew", "uninstall"}, pkgs...)
+}
+func (BrewManager) Search(query string) []string { return []string{"brew", "search", query} }
+func (BrewManager) Update() []string             { return []string{"brew", "update"} }
+func (BrewManager) Upgrade() []string            { return []string{"brew", "upgrade"} }
+func (BrewManager) List() []string               { return []string{"brew", "list"} }
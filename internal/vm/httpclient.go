@@ -0,0 +1,44 @@
+package vm
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// NewHTTPClient returns the client asset downloads use. Requests go through
+// proxy when it is set, and otherwise through the proxy named by the
+// HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables. HTTPS
+// requests tunnel through the proxy with CONNECT.
+//
+// A proxy without a scheme is taken to be http://. If proxy is not a valid
+// URL, every request fails with the parse error.
+func NewHTTPClient(proxy string) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyFromEnvironment
+	if proxy != "" {
+		proxyURL, err := ParseProxyURL(proxy)
+		if err != nil {
+			transport.Proxy = func(*http.Request) (*url.URL, error) { return nil, err }
+		} else {
+			transport.Proxy = http.ProxyURL(proxyURL)
+		}
+	}
+	return &http.Client{Transport: transport}
+}
+
+// ParseProxyURL parses a proxy given as a URL or as host:port.
+func ParseProxyURL(proxy string) (*url.URL, error) {
+	if !strings.Contains(proxy, "://") {
+		proxy = "http://" + proxy
+	}
+	u, err := url.Parse(proxy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy: %w", err)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid proxy %q: no host", proxy)
+	}
+	return u, nil
+}
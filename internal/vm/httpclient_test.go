@@ -0,0 +1,101 @@
+package vm
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// connectProxy is a forward proxy that only tunnels CONNECT requests,
+// recording the target of each.
+type connectProxy struct {
+	mu      sync.Mutex
+	targets []string
+}
+
+func (p *connectProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodConnect {
+		http.Error(w, "only CONNECT", http.StatusMethodNotAllowed)
+		return
+	}
+	p.mu.Lock()
+	p.targets = append(p.targets, r.Host)
+	p.mu.Unlock()
+
+	upstream, err := net.Dial("tcp", r.Host)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	conn, _, err := w.(http.Hijacker).Hijack()
+	if err != nil {
+		upstream.Close()
+		return
+	}
+	conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n"))
+	go func() {
+		io.Copy(upstream, conn)
+		upstream.Close()
+	}()
+	io.Copy(conn, upstream)
+	conn.Close()
+}
+
+func TestFetchFileThroughProxy(t *testing.T) {
+	origin := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("rootfs"))
+	}))
+	defer origin.Close()
+	proxy := &connectProxy{}
+	proxySrv := httptest.NewServer(proxy)
+	defer proxySrv.Close()
+
+	m := NewAssetManager(t.TempDir(), nil, nil).WithProxy(proxySrv.URL)
+	// Trust the origin's self-signed certificate
+	m.client.Transport.(*http.Transport).TLSClientConfig = origin.Client().Transport.(*http.Transport).TLSClientConfig
+
+	dst := filepath.Join(t.TempDir(), "rootfs")
+	if err := m.fetchFile(dst, origin.URL+"/rootfs.tar.gz"); err != nil {
+		t.Fatalf("fetchFile: %v", err)
+	}
+	if data, err := os.ReadFile(dst); err != nil || string(data) != "rootfs" {
+		t.Errorf("downloaded %q, %v; want %q", data, err, "rootfs")
+	}
+	want := origin.Listener.Addr().String()
+	if len(proxy.targets) != 1 || proxy.targets[0] != want {
+		t.Errorf("CONNECT targets = %v, want [%s]", proxy.targets, want)
+	}
+}
+
+func TestNewHTTPClientInvalidProxy(t *testing.T) {
+	client := NewHTTPClient("http://")
+	if _, err := client.Get("http://example.invalid/"); err == nil {
+		t.Error("Get through an invalid proxy succeeded")
+	}
+}
+
+func TestParseProxyURL(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"http://proxy:3128", "http://proxy:3128"},
+		{"proxy.example.com:8080", "http://proxy.example.com:8080"},
+		{"socks5://user:pw@proxy:1080", "socks5://user:pw@proxy:1080"},
+	}
+	for _, tt := range tests {
+		u, err := ParseProxyURL(tt.in)
+		if err != nil {
+			t.Errorf("ParseProxyURL(%q): %v", tt.in, err)
+			continue
+		}
+		if u.String() != tt.want {
+			t.Errorf("ParseProxyURL(%q) = %s, want %s", tt.in, u, tt.want)
+		}
+	}
+}
@@ -1,8 +1,11 @@
 package vm
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"os/exec"
 	"path/filepath"
 )
 
@@ -11,6 +14,13 @@ const (
 	DefaultDiskSizeMB = 10 * 1024
 )
 
+// Disk export formats accepted by ExportDisk.
+const (
+	ExportQcow2 = "qcow2"  // Compressed qcow2, needs qemu-img
+	ExportRaw   = "raw"    // Sparse raw copy
+	ExportRawGz = "raw.gz" // gzip-compressed raw image
+)
+
 // ImageManager handles disk image creation and management.
 type ImageManager struct {
 	dataDir string
@@ -41,6 +51,53 @@ func (m *ImageManager) EnsureDisk(name string, sizeMB int64) (string, error) {
 	return path, nil
 }
 
+// EnsureImageDisk returns the disk image name, creating it the first time
+// as a copy of image (a reflink where the filesystem supports one). VMs that
+// boot a distro's own disk image each get a copy this way, so no two of them
+// write to the shared image in the cache. A live ISO is never written, so
+// it is returned as is.
+func (m *ImageManager) EnsureImageDisk(name, image string) (string, error) {
+	if filepath.Ext(image) == ".iso" {
+		return image, nil
+	}
+	path := m.DiskPath(name)
+	if _, err := os.Stat(path); err == nil {
+		return path, nil
+	}
+	if err := os.MkdirAll(m.dataDir, 0755); err != nil {
+		return "", fmt.Errorf("create data dir: %w", err)
+	}
+
+	// Copy under a temporary name so an interrupted copy is never booted
+	tmp := path + ".tmp"
+	os.Remove(tmp)
+	if _, err := CopyDisk(image, tmp); err != nil {
+		return "", fmt.Errorf("copy disk image: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return "", fmt.Errorf("copy disk image: %w", err)
+	}
+	forgetHostname(path)
+	return path, nil
+}
+
+// CreateOverlayDisk creates an empty sparse disk image of sizeMB for an
+// overlay root, replacing any left by an earlier boot so each boot starts
+// from the lower disk's state.
+func (m *ImageManager) CreateOverlayDisk(diskName string, sizeMB int64) error {
+	if err := os.MkdirAll(m.dataDir, 0755); err != nil {
+		return fmt.Errorf("create data dir: %w", err)
+	}
+	if err := m.DeleteDisk(diskName); err != nil {
+		return err
+	}
+	if err := m.createSparseImage(m.DiskPath(diskName), sizeMB); err != nil {
+		return fmt.Errorf("create overlay disk: %w", err)
+	}
+	return nil
+}
+
 // DiskPath returns the path to a named disk image.
 func (m *ImageManager) DiskPath(name string) string {
 	return filepath.Join(m.dataDir, name+".raw")
@@ -58,9 +115,88 @@ func (m *ImageManager) DeleteDisk(name string) error {
 	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
 		return fmt.Errorf("delete disk: %w", err)
 	}
+	forgetHostname(path)
 	return nil
 }
 
+// ExportDisk writes the named disk image to destPath in format (ExportQcow2,
+// ExportRaw or ExportRawGz). A disk that is a symlink is exported from its
+// target. destPath must not exist; the export is written to a temp file and
+// renamed into place so a failed run leaves nothing.
+func (m *ImageManager) ExportDisk(diskName, destPath, format string) error {
+	// Resolve first: a reflink copy of a symlink would copy the link
+	src, err := filepath.EvalSymlinks(m.DiskPath(diskName))
+	if err != nil {
+		return fmt.Errorf("disk image: %w", err)
+	}
+	if _, err := os.Stat(destPath); err == nil {
+		return fmt.Errorf("%s already exists", destPath)
+	}
+
+	tmpPath := destPath + ".tmp"
+	os.Remove(tmpPath)
+
+	switch format {
+	case ExportQcow2:
+		err = exportQcow2(src, tmpPath)
+	case ExportRaw:
+		_, err = CopyDisk(src, tmpPath)
+	case ExportRawGz:
+		err = exportGzip(src, tmpPath)
+	default:
+		return fmt.Errorf("unknown export format %q (use qcow2, raw, or raw.gz)", format)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	if err := os.Rename(tmpPath, destPath); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("finalize export: %w", err)
+	}
+	return nil
+}
+
+// exportQcow2 converts a raw image to a compressed qcow2 image.
+func exportQcow2(src, dst string) error {
+	if err := EnsureQemuImg(); err != nil {
+		return err
+	}
+	cmd := exec.Command("qemu-img", "convert", "-f", "raw", "-O", "qcow2", "-c", src, dst)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("qemu-img convert: %w: %s", err, output)
+	}
+	return nil
+}
+
+// exportGzip writes a gzip-compressed copy of src to dst.
+func exportGzip(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("open disk: %w", err)
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return fmt.Errorf("create export: %w", err)
+	}
+	defer out.Close()
+
+	zw, err := newCompressor(out, CodecGzip, 0)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(zw, in); err != nil {
+		return fmt.Errorf("compress disk: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("compress disk: %w", err)
+	}
+	return out.Close()
+}
+
 func (m *ImageManager) createSparseImage(path string, sizeMB int64) error {
 	f, err := os.Create(path)
 	if err != nil {
@@ -69,5 +205,132 @@ func (m *ImageManager) createSparseImage(path string, sizeMB int64) error {
 	defer f.Close()
 
 	// Truncate creates a sparse file on Linux/macOS
-	return f.Truncate(sizeMB * 1024 * 1024)
+	size := sizeMB * 1024 * 1024
+	if err := f.Truncate(size); err != nil {
+		return err
+	}
+	return deallocate(f, size)
+}
+
+// DiskActualSize returns the bytes allocated on the host for a disk image
+// and its logical size. A sparse image allocates far less than its size.
+func DiskActualSize(diskPath string) (allocated int64, total int64, err error) {
+	info, err := os.Stat(diskPath)
+	if err != nil {
+		return 0, 0, err
+	}
+	return allocatedBytes(info), info.Size(), nil
+}
+
+// errReflinkUnsupported is returned when the platform cannot clone files.
+var errReflinkUnsupported = errors.New("reflinks are not supported on this platform")
+
+// ReferenceLink creates dst as a copy-on-write clone of src. The clone shares
+// data blocks with src until either file is modified. It fails if the
+// filesystem does not support reflinks or src and dst are on different
+// filesystems; use CopyDisk for a copy that falls back automatically.
+func ReferenceLink(src, dst string) error {
+	if err := cloneFile(src, dst); err != nil {
+		return fmt.Errorf("reflink %s: %w", filepath.Base(src), err)
+	}
+	return nil
+}
+
+// ReflinkSupported reports whether the filesystem holding dir supports
+// reflinks, by attempting a clone between two temporary files.
+func ReflinkSupported(dir string) bool {
+	probe, err := os.MkdirTemp(dir, ".reflink-probe-")
+	if err != nil {
+		return false
+	}
+	defer os.RemoveAll(probe)
+
+	src := filepath.Join(probe, "src")
+	if err := os.WriteFile(src, []byte("reflink"), 0644); err != nil {
+		return false
+	}
+	return cloneFile(src, filepath.Join(probe, "dst")) == nil
+}
+
+// CopyDisk copies the disk image at src to dst. It uses a reflink when the
+// filesystem supports one and otherwise falls back to a sparse-preserving
+// byte copy. The returned bool reports whether a reflink was used.
+func CopyDisk(src, dst string) (bool, error) {
+	if err := cloneFile(src, dst); err == nil {
+		return true, nil
+	}
+
+	if err := copySparse(src, dst); err != nil {
+		return false, fmt.Errorf("copy %s: %w", filepath.Base(src), err)
+	}
+	return false, nil
+}
+
+// copySparse copies src to dst, seeking over all-zero blocks so that sparse
+// disk images stay sparse. A partially written dst is removed on failure.
+func copySparse(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+
+	if err := writeSparse(out, in, info.Size()); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(dst)
+		return err
+	}
+	return nil
+}
+
+// writeSparse copies r to out, leaving holes for all-zero blocks, and sets
+// out's final size to size.
+func writeSparse(out *os.File, r io.Reader, size int64) error {
+	buf := make([]byte, 1024*1024)
+	for {
+		n, rerr := io.ReadFull(r, buf)
+		if n > 0 {
+			var err error
+			if isZero(buf[:n]) {
+				_, err = out.Seek(int64(n), io.SeekCurrent)
+			} else {
+				_, err = out.Write(buf[:n])
+			}
+			if err != nil {
+				return err
+			}
+		}
+		if rerr == io.EOF || rerr == io.ErrUnexpectedEOF {
+			break
+		}
+		if rerr != nil {
+			return rerr
+		}
+	}
+
+	// Trailing holes are only materialised by setting the final size
+	return out.Truncate(size)
+}
+
+func isZero(b []byte) bool {
+	for _, c := range b {
+		if c != 0 {
+			return false
+		}
+	}
+	return true
 }
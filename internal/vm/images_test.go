@@ -1,6 +1,8 @@
 package vm
 
 import (
+	"bytes"
+	"io"
 	"os"
 	"path/filepath"
 	"testing"
@@ -44,6 +46,30 @@ func TestEnsureDisk(t *testing.T) {
 	}
 }
 
+func TestDiskActualSize(t *testing.T) {
+	im := NewImageManager(t.TempDir())
+	path, err := im.EnsureDisk("sparse", 64)
+	if err != nil {
+		t.Fatalf("EnsureDisk failed: %v", err)
+	}
+
+	allocated, total, err := DiskActualSize(path)
+	if err != nil {
+		t.Fatalf("DiskActualSize failed: %v", err)
+	}
+	if total != 64*1024*1024 {
+		t.Errorf("total = %d, want %d", total, 64*1024*1024)
+	}
+	// A fresh image holds no data, so it should allocate next to nothing
+	if allocated >= total {
+		t.Errorf("allocated = %d, want less than total %d (sparse)", allocated, total)
+	}
+
+	if _, _, err := DiskActualSize(filepath.Join(t.TempDir(), "missing.raw")); err == nil {
+		t.Error("expected error for missing disk")
+	}
+}
+
 func TestEnsureDiskDifferentSizes(t *testing.T) {
 	dir := t.TempDir()
 	im := NewImageManager(dir)
@@ -177,3 +203,256 @@ func TestImageManagerCreatesDirIfNeeded(t *testing.T) {
 		t.Error("path should be a directory")
 	}
 }
+
+func TestCopyDisk(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src.raw")
+
+	// 4MB image with data only in the second megabyte
+	f, err := os.Create(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Truncate(4 * 1024 * 1024); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteAt([]byte("vmterminal"), 1024*1024+42); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	dst := filepath.Join(dir, "dst.raw")
+	if _, err := CopyDisk(src, dst); err != nil {
+		t.Fatalf("CopyDisk failed: %v", err)
+	}
+
+	want, _ := os.ReadFile(src)
+	got, err := os.ReadFile(dst)
+	if err != nil {
+		t.Fatalf("read copy: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Error("copied disk content differs from source")
+	}
+
+	// Refuses to overwrite an existing destination
+	if _, err := CopyDisk(src, dst); err == nil {
+		t.Error("expected error copying onto existing file")
+	}
+}
+
+func TestEnsureImageDisk(t *testing.T) {
+	cache := t.TempDir()
+	image := filepath.Join(cache, "rootfs.raw")
+	if err := os.WriteFile(image, []byte("cloud image"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Two VMs of the same distro each get their own copy
+	a := NewImageManager(filepath.Join(t.TempDir(), "a"))
+	b := NewImageManager(filepath.Join(t.TempDir(), "b"))
+	pathA, err := a.EnsureImageDisk("disk", image)
+	if err != nil {
+		t.Fatalf("EnsureImageDisk: %v", err)
+	}
+	pathB, err := b.EnsureImageDisk("disk", image)
+	if err != nil {
+		t.Fatalf("EnsureImageDisk: %v", err)
+	}
+	if pathA == image || pathA != a.DiskPath("disk") || pathB != b.DiskPath("disk") {
+		t.Fatalf("disks = %q, %q; want each VM's own disk.raw", pathA, pathB)
+	}
+
+	// The copy is made once: a VM keeps what it wrote
+	os.WriteFile(pathA, []byte("changed by A"), 0644)
+	if _, err := a.EnsureImageDisk("disk", image); err != nil {
+		t.Fatal(err)
+	}
+	for path, want := range map[string]string{pathA: "changed by A", pathB: "cloud image", image: "cloud image"} {
+		if got, _ := os.ReadFile(path); string(got) != want {
+			t.Errorf("%s = %q, want %q", path, got, want)
+		}
+	}
+
+	// Live ISOs are booted from the cache
+	iso := filepath.Join(cache, "rootfs.iso")
+	if path, err := a.EnsureImageDisk("disk", iso); err != nil || path != iso {
+		t.Errorf("EnsureImageDisk for an ISO = %q, %v; want %q", path, err, iso)
+	}
+}
+
+func TestCopySparseKeepsTrailingHole(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src.raw")
+	if err := os.WriteFile(src, []byte("head"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Truncate(src, 3*1024*1024); err != nil {
+		t.Fatal(err)
+	}
+
+	dst := filepath.Join(dir, "dst.raw")
+	if err := copySparse(src, dst); err != nil {
+		t.Fatalf("copySparse failed: %v", err)
+	}
+
+	info, err := os.Stat(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() != 3*1024*1024 {
+		t.Errorf("size = %d, want %d", info.Size(), 3*1024*1024)
+	}
+}
+
+func TestReferenceLink(t *testing.T) {
+	dir := t.TempDir()
+	if !ReflinkSupported(dir) {
+		t.Skip("filesystem does not support reflinks")
+	}
+
+	src := filepath.Join(dir, "src.raw")
+	data := bytes.Repeat([]byte("vmterminal"), 64*1024)
+	if err := os.WriteFile(src, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	dst := filepath.Join(dir, "dst.raw")
+	if err := ReferenceLink(src, dst); err != nil {
+		t.Fatalf("ReferenceLink failed: %v", err)
+	}
+
+	got, err := os.ReadFile(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Error("reflinked content differs from source")
+	}
+
+	assertSharesBlocks(t, dst)
+
+	// Writing to the clone must not affect the source
+	if err := os.WriteFile(dst, []byte("changed"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if orig, _ := os.ReadFile(src); !bytes.Equal(orig, data) {
+		t.Error("writing to clone modified source")
+	}
+}
+
+func TestExportDisk(t *testing.T) {
+	dir := t.TempDir()
+	im := NewImageManager(dir)
+	content := bytes.Repeat([]byte("disk block "), 1000)
+	if err := os.WriteFile(im.DiskPath("disk"), content, 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	t.Run("raw", func(t *testing.T) {
+		dst := filepath.Join(t.TempDir(), "vm.raw")
+		if err := im.ExportDisk("disk", dst, ExportRaw); err != nil {
+			t.Fatalf("ExportDisk: %v", err)
+		}
+		got, _ := os.ReadFile(dst)
+		if !bytes.Equal(got, content) {
+			t.Error("raw export differs from the disk")
+		}
+	})
+
+	t.Run("raw.gz", func(t *testing.T) {
+		dst := filepath.Join(t.TempDir(), "vm.raw.gz")
+		if err := im.ExportDisk("disk", dst, ExportRawGz); err != nil {
+			t.Fatalf("ExportDisk: %v", err)
+		}
+		f, err := os.Open(dst)
+		if err != nil {
+			t.Fatalf("Open: %v", err)
+		}
+		defer f.Close()
+		zr, err := newDecompressor(f, CodecGzip)
+		if err != nil {
+			t.Fatalf("gzip reader: %v", err)
+		}
+		got, err := io.ReadAll(zr)
+		if err != nil || !bytes.Equal(got, content) {
+			t.Errorf("raw.gz export does not decompress to the disk (err %v)", err)
+		}
+	})
+
+	t.Run("qcow2", func(t *testing.T) {
+		if err := EnsureQemuImg(); err != nil {
+			t.Skip(err)
+		}
+		dst := filepath.Join(t.TempDir(), "vm.qcow2")
+		if err := im.ExportDisk("disk", dst, ExportQcow2); err != nil {
+			t.Fatalf("ExportDisk: %v", err)
+		}
+		if _, err := ReadQcow2Header(dst); err != nil {
+			t.Errorf("export is not a qcow2 image: %v", err)
+		}
+	})
+
+	t.Run("symlinked disk", func(t *testing.T) {
+		linkDir := t.TempDir()
+		if err := os.Symlink(im.DiskPath("disk"), filepath.Join(linkDir, "disk.raw")); err != nil {
+			t.Fatalf("Symlink: %v", err)
+		}
+		dst := filepath.Join(t.TempDir(), "vm.raw")
+		if err := NewImageManager(linkDir).ExportDisk("disk", dst, ExportRaw); err != nil {
+			t.Fatalf("ExportDisk: %v", err)
+		}
+		info, err := os.Lstat(dst)
+		if err != nil || !info.Mode().IsRegular() {
+			t.Fatalf("export is not a regular file: %v", err)
+		}
+		if got, _ := os.ReadFile(dst); !bytes.Equal(got, content) {
+			t.Error("export of a symlinked disk differs from its target")
+		}
+	})
+
+	t.Run("existing destination", func(t *testing.T) {
+		dst := filepath.Join(t.TempDir(), "exists.raw")
+		os.WriteFile(dst, []byte("keep"), 0644)
+		if err := im.ExportDisk("disk", dst, ExportRaw); err == nil {
+			t.Error("expected error for existing destination")
+		}
+		if got, _ := os.ReadFile(dst); string(got) != "keep" {
+			t.Error("existing destination was modified")
+		}
+	})
+
+	t.Run("unknown format", func(t *testing.T) {
+		dst := filepath.Join(t.TempDir(), "vm.vmdk")
+		if err := im.ExportDisk("disk", dst, "vmdk"); err == nil {
+			t.Error("expected error for unknown format")
+		}
+		if _, err := os.Stat(dst + ".tmp"); !os.IsNotExist(err) {
+			t.Error("temp file left behind")
+		}
+	})
+}
+
+func TestCreateOverlayDisk(t *testing.T) {
+	im := NewImageManager(t.TempDir())
+
+	if err := im.CreateOverlayDisk(OverlayDiskName, 16); err != nil {
+		t.Fatalf("CreateOverlayDisk: %v", err)
+	}
+	path := im.DiskPath(OverlayDiskName)
+	if err := os.WriteFile(path, []byte("changes from the last boot"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// A second boot gets an empty overlay again
+	if err := im.CreateOverlayDisk(OverlayDiskName, 16); err != nil {
+		t.Fatalf("CreateOverlayDisk: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if int64(len(data)) != 16*1024*1024 || !isZero(data) {
+		t.Error("overlay disk was not recreated empty")
+	}
+}
@@ -0,0 +1,249 @@
+package vm
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// hostnameMarkerSuffix names the file next to a disk image that records
+// which hostname has already been written inside it.
+const hostnameMarkerSuffix = ".hostname"
+
+// hostnameSkippedSuffix names the file next to a disk image that records
+// a hostname that could not be written, so the failure is reported once.
+const hostnameSkippedSuffix = ".hostname-skipped"
+
+// hostsLoopbackAddr is the address Debian-style /etc/hosts files map the
+// machine's own hostname to.
+const hostsLoopbackAddr = "127.0.1.1"
+
+// InjectHostname sets the guest hostname in a rootfs mounted at
+// mountPoint: /etc/hostname is replaced and /etc/hosts gains (or has
+// updated) a 127.0.1.1 entry so the name resolves without DNS.
+func InjectHostname(mountPoint, hostname string) error {
+	if err := ValidateHostname(hostname); err != nil {
+		return err
+	}
+
+	etcDir := filepath.Join(mountPoint, "etc")
+	cmd := exec.Command("sudo", "mkdir", "-p", etcDir)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("create /etc: %w", err)
+	}
+
+	// A missing /etc/hosts is created with just the loopback entries
+	hosts, err := os.ReadFile(filepath.Join(etcDir, "hosts"))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("read /etc/hosts: %w", err)
+	}
+
+	files := []struct{ name, content string }{
+		{"hostname", hostname + "\n"},
+		{"hosts", HostsWithHostname(string(hosts), hostname)},
+	}
+	for _, f := range files {
+		// Use tee with sudo since the mount point is owned by root
+		cmd := exec.Command("sudo", "tee", filepath.Join(etcDir, f.name))
+		cmd.Stdin = strings.NewReader(f.content)
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("write /etc/%s: %w", f.name, err)
+		}
+	}
+	return nil
+}
+
+// InjectHostnameImage sets the guest hostname inside an unmounted disk
+// image using guestfish, for disks that are never mounted on the host.
+// The image is left untouched if it already carries hostname.
+func InjectHostnameImage(diskPath, hostname string) error {
+	if err := ValidateHostname(hostname); err != nil {
+		return err
+	}
+	if HostnameInjected(diskPath, hostname) {
+		return nil
+	}
+
+	if _, err := exec.LookPath("guestfish"); err != nil {
+		return fmt.Errorf("guestfish not found; install libguestfs-tools to set the hostname of %s", filepath.Base(diskPath))
+	}
+
+	// Read the current /etc/hosts first; the leading '-' keeps a missing
+	// file from failing the script
+	hosts, err := runGuestfish(diskPath, true, "-cat /etc/hosts\n")
+	if err != nil {
+		return err
+	}
+
+	tmpDir, err := os.MkdirTemp("", "vmterminal-hostname-")
+	if err != nil {
+		return fmt.Errorf("create temp dir: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	hostnameFile := filepath.Join(tmpDir, "hostname")
+	hostsFile := filepath.Join(tmpDir, "hosts")
+	if err := os.WriteFile(hostnameFile, []byte(hostname+"\n"), 0644); err != nil {
+		return err
+	}
+	if err := os.WriteFile(hostsFile, []byte(HostsWithHostname(hosts, hostname)), 0644); err != nil {
+		return err
+	}
+
+	if _, err := runGuestfish(diskPath, false, guestfishHostnameScript(hostnameFile, hostsFile)); err != nil {
+		return err
+	}
+	os.Remove(diskPath + hostnameSkippedSuffix)
+	return RecordHostname(diskPath, hostname)
+}
+
+// HostnameInjected reports whether hostname was the last hostname written
+// into the disk image at diskPath.
+func HostnameInjected(diskPath, hostname string) bool {
+	data, err := os.ReadFile(diskPath + hostnameMarkerSuffix)
+	return err == nil && strings.TrimSpace(string(data)) == hostname
+}
+
+// RecordHostname notes that hostname has been written into the disk image
+// at diskPath, so the next boot does not write it again.
+func RecordHostname(diskPath, hostname string) error {
+	return os.WriteFile(diskPath+hostnameMarkerSuffix, []byte(hostname+"\n"), 0644)
+}
+
+// HostnameSkipped reports whether writing hostname into the disk image at
+// diskPath already failed and was reported.
+func HostnameSkipped(diskPath, hostname string) bool {
+	data, err := os.ReadFile(diskPath + hostnameSkippedSuffix)
+	return err == nil && strings.TrimSpace(string(data)) == hostname
+}
+
+// RecordHostnameSkipped notes that hostname could not be written into the
+// disk image at diskPath. Choosing a different hostname tries again.
+func RecordHostnameSkipped(diskPath, hostname string) error {
+	return os.WriteFile(diskPath+hostnameSkippedSuffix, []byte(hostname+"\n"), 0644)
+}
+
+// forgetHostname drops the hostname markers of the disk image at diskPath,
+// for a disk that was deleted or replaced by a fresh one.
+func forgetHostname(diskPath string) {
+	os.Remove(diskPath + hostnameMarkerSuffix)
+	os.Remove(diskPath + hostnameSkippedSuffix)
+}
+
+// runGuestfish runs script against the disk image with guestfish and
+// returns its output.
+func runGuestfish(diskPath string, readOnly bool, script string) (string, error) {
+	args := []string{"-a", diskPath, "-i"}
+	if readOnly {
+		args = append([]string{"--ro"}, args...)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("guestfish", args...)
+	cmd.Stdin = strings.NewReader(script)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("guestfish: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
+
+// guestfishHostnameScript returns the guestfish commands that install
+// the prepared hostname and hosts files in the guest's /etc.
+func guestfishHostnameScript(hostnamePath, hostsPath string) string {
+	return fmt.Sprintf(`mkdir-p /etc
+upload "%s" /etc/hostname
+upload "%s" /etc/hosts
+chmod 0644 /etc/hostname
+chmod 0644 /etc/hosts
+`, hostnamePath, hostsPath)
+}
+
+// HostsWithHostname returns the /etc/hosts content hosts with hostname
+// mapped to 127.0.1.1. An existing 127.0.1.1 line is replaced and every
+// other line is kept; if there is none, one is added after the localhost
+// entries.
+func HostsWithHostname(hosts, hostname string) string {
+	entry := hostsLoopbackAddr + "\t" + hostname
+	if strings.TrimSpace(hosts) == "" {
+		return "127.0.0.1\tlocalhost\n::1\tlocalhost ip6-localhost ip6-loopback\n" + entry + "\n"
+	}
+
+	lines := strings.Split(strings.TrimRight(hosts, "\n"), "\n")
+	out := make([]string, 0, len(lines)+1)
+	replaced := false
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) > 0 && fields[0] == hostsLoopbackAddr {
+			if !replaced {
+				out = append(out, entry)
+				replaced = true
+			}
+			continue
+		}
+		out = append(out, line)
+	}
+
+	if !replaced {
+		// Keep the entry next to the other loopback addresses
+		at := 0
+		for i, line := range out {
+			if f := strings.Fields(line); len(f) > 0 && f[0] == "127.0.0.1" {
+				at = i + 1
+			}
+		}
+		out = append(out[:at], append([]string{entry}, out[at:]...)...)
+	}
+	return strings.Join(out, "\n") + "\n"
+}
+
+// ValidateHostname checks that name is a valid RFC 1123 hostname: dot
+// separated labels of letters, digits and hyphens, each 1-63 characters
+// that neither start nor end with a hyphen, 253 characters in total.
+func ValidateHostname(name string) error {
+	if name == "" {
+		return fmt.Errorf("hostname must not be empty")
+	}
+	if len(name) > 253 {
+		return fmt.Errorf("hostname %q is longer than 253 characters", name)
+	}
+	for _, label := range strings.Split(name, ".") {
+		if label == "" || len(label) > 63 {
+			return fmt.Errorf("hostname %q: each dot-separated part must be 1-63 characters", name)
+		}
+		if label[0] == '-' || label[len(label)-1] == '-' {
+			return fmt.Errorf("hostname %q: parts must not start or end with '-'", name)
+		}
+		for _, c := range label {
+			if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-') {
+				return fmt.Errorf("hostname %q: only letters, digits, '-' and '.' are allowed", name)
+			}
+		}
+	}
+	return nil
+}
+
+// hostnameFromName turns a VM name into a valid hostname by replacing
+// characters hostnames cannot contain with '-'.
+func hostnameFromName(name string) string {
+	var b strings.Builder
+	for _, c := range strings.ToLower(name) {
+		if c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-' {
+			b.WriteRune(c)
+		} else {
+			b.WriteByte('-')
+		}
+	}
+	h := strings.Trim(b.String(), "-")
+	if len(h) > 63 {
+		h = strings.TrimRight(h[:63], "-")
+	}
+	if h == "" {
+		return "vmterminal"
+	}
+	return h
+}
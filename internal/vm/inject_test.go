@@ -0,0 +1,170 @@
+package vm
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHostsWithHostname(t *testing.T) {
+	tests := []struct {
+		name  string
+		hosts string
+		want  string
+	}{
+		{
+			name:  "empty",
+			hosts: "",
+			want:  "127.0.0.1\tlocalhost\n::1\tlocalhost ip6-localhost ip6-loopback\n127.0.1.1\tdev\n",
+		},
+		{
+			name:  "adds after localhost",
+			hosts: "# comment\n127.0.0.1 localhost\n::1 localhost\n",
+			want:  "# comment\n127.0.0.1 localhost\n127.0.1.1\tdev\n::1 localhost\n",
+		},
+		{
+			name:  "replaces existing entry",
+			hosts: "127.0.0.1 localhost\n127.0.1.1 ubuntu\n10.0.0.5 db\n127.0.1.1 old\n",
+			want:  "127.0.0.1 localhost\n127.0.1.1\tdev\n10.0.0.5 db\n",
+		},
+		{
+			name:  "no localhost line",
+			hosts: "10.0.0.5 db",
+			want:  "127.0.1.1\tdev\n10.0.0.5 db\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := HostsWithHostname(tt.hosts, "dev"); got != tt.want {
+				t.Errorf("HostsWithHostname() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateHostname(t *testing.T) {
+	valid := []string{"dev", "web-2", "Build01", "db.lab.internal"}
+	for _, name := range valid {
+		if err := ValidateHostname(name); err != nil {
+			t.Errorf("ValidateHostname(%q) = %v, want nil", name, err)
+		}
+	}
+
+	invalid := []string{"", "-dev", "dev-", "my_vm", "a..b", "dev.", strings.Repeat("a", 64), "dev box"}
+	for _, name := range invalid {
+		if err := ValidateHostname(name); err == nil {
+			t.Errorf("ValidateHostname(%q) = nil, want error", name)
+		}
+	}
+}
+
+func TestEffectiveHostname(t *testing.T) {
+	tests := map[string]string{
+		"dev":      "dev",
+		"My_VM":    "my-vm",
+		"_build_":  "build",
+		"web.prod": "web-prod",
+		"___":      "vmterminal",
+	}
+	for name, want := range tests {
+		entry := &VMEntry{Name: name}
+		got := entry.EffectiveHostname()
+		if got != want {
+			t.Errorf("EffectiveHostname(%q) = %q, want %q", name, got, want)
+		}
+		if err := ValidateHostname(got); err != nil {
+			t.Errorf("derived hostname %q is invalid: %v", got, err)
+		}
+	}
+
+	entry := &VMEntry{Name: "dev", Hostname: "devbox.lab"}
+	if got := entry.EffectiveHostname(); got != "devbox.lab" {
+		t.Errorf("EffectiveHostname() = %q, want devbox.lab", got)
+	}
+}
+
+func TestInjectHostnameImageMarker(t *testing.T) {
+	disk := filepath.Join(t.TempDir(), "disk.raw")
+	if err := RecordHostname(disk, "dev"); err != nil {
+		t.Fatal(err)
+	}
+	if !HostnameInjected(disk, "dev") {
+		t.Error("HostnameInjected() = false after RecordHostname")
+	}
+	if HostnameInjected(disk, "other") {
+		t.Error("HostnameInjected() = true for a different hostname")
+	}
+
+	// A matching marker means the image already has the hostname, so
+	// guestfish is never needed.
+	if err := InjectHostnameImage(disk, "dev"); err != nil {
+		t.Errorf("InjectHostnameImage() with current marker error = %v", err)
+	}
+	if err := InjectHostnameImage(disk, "bad_name"); err == nil {
+		t.Error("InjectHostnameImage() should reject an invalid hostname")
+	}
+}
+
+func TestGuestfishHostnameScript(t *testing.T) {
+	script := guestfishHostnameScript("/tmp/x/hostname", "/tmp/x/hosts")
+	for _, want := range []string{
+		`upload "/tmp/x/hostname" /etc/hostname` + "\n",
+		`upload "/tmp/x/hosts" /etc/hosts` + "\n",
+	} {
+		if !strings.Contains(script, want) {
+			t.Errorf("script missing %q:\n%s", want, script)
+		}
+	}
+}
+
+func TestInjectHostnameInvalid(t *testing.T) {
+	mountPoint := t.TempDir()
+	if err := InjectHostname(mountPoint, "no spaces"); err == nil {
+		t.Error("InjectHostname() should reject an invalid hostname")
+	}
+	if _, err := os.Stat(filepath.Join(mountPoint, "etc")); !os.IsNotExist(err) {
+		t.Error("InjectHostname() touched the rootfs for an invalid hostname")
+	}
+}
+
+func TestSetHostnameOwnDiskOnly(t *testing.T) {
+	dataDir := t.TempDir()
+	m := &Manager{
+		cfg:    ManagerConfig{DataDir: dataDir, DiskName: "disk", Hostname: "dev"},
+		images: NewImageManager(dataDir),
+	}
+
+	// A disk that is not the VM's own is never touched, not even to
+	// record a failure
+	shared := filepath.Join(t.TempDir(), "rootfs.iso")
+	m.setHostname(shared)
+	if HostnameSkipped(shared, "dev") || HostnameInjected(shared, "dev") {
+		t.Error("setHostname() wrote a marker next to a shared disk")
+	}
+
+	// The VM's own disk is skipped once its marker matches
+	own := m.images.DiskPath("disk")
+	if err := RecordHostname(own, "dev"); err != nil {
+		t.Fatal(err)
+	}
+	m.setHostname(own)
+	if HostnameSkipped(own, "dev") {
+		t.Error("setHostname() retried a hostname the disk already has")
+	}
+
+	// A hostname that cannot be written is reported once, not every boot
+	m.cfg.Hostname = "renamed"
+	m.setHostname(own)
+	if !HostnameSkipped(own, "renamed") {
+		t.Error("failed hostname change was not recorded as skipped")
+	}
+
+	// Deleting the disk forgets the hostname, so a new disk gets it again
+	if err := m.images.DeleteDisk("disk"); err != nil {
+		t.Fatal(err)
+	}
+	if HostnameInjected(own, "dev") || HostnameSkipped(own, "renamed") {
+		t.Error("hostname markers survived DeleteDisk")
+	}
+}
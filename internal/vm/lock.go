@@ -0,0 +1,52 @@
+package vm
+
+import (
+	"time"
+
+	"github.com/javanstorm/vmterminal/internal/filelock"
+)
+
+// RegistryLockFile is the lock file in the base directory that serialises
+// registry updates across vmterminal processes.
+const RegistryLockFile = "registry.lock"
+
+// CacheLockFile is the lock file in each distro's cache directory held
+// while its assets are downloaded or unpacked.
+const CacheLockFile = ".lock"
+
+// StateLockFile is the lock file in each VM's data directory held while
+// its state.json is updated.
+const StateLockFile = "state.lock"
+
+// stateLockTimeout bounds how long a state update waits for another one.
+const stateLockTimeout = 10 * time.Second
+
+// cacheLockTimeout bounds how long EnsureAssets waits for another process
+// to finish downloading the same distro.
+const cacheLockTimeout = 30 * time.Minute
+
+// registryLockTimeout bounds how long a registry update waits for another
+// process to finish.
+const registryLockTimeout = 10 * time.Second
+
+// FileLock is an exclusive advisory lock on a file, shared by vmterminal
+// processes.
+type FileLock = filelock.Lock
+
+// RegistryLock is an exclusive advisory lock on the VM registry. It is held
+// across a whole read-modify-write so concurrent processes cannot lose
+// each other's updates to vms.json.
+type RegistryLock = FileLock
+
+// AcquireRegistryLock takes the registry lock in baseDir, retrying until
+// timeout. Callers must Release it, typically with defer.
+func AcquireRegistryLock(baseDir string, timeout time.Duration) (*RegistryLock, error) {
+	return filelock.Acquire(baseDir, RegistryLockFile, "registry", timeout)
+}
+
+// AcquireCacheLock takes the lock on a distro's cache directory, retrying
+// until timeout, so concurrent runs do not download or unpack the same
+// assets over each other. Callers must Release it, typically with defer.
+func AcquireCacheLock(cacheSubdir string, timeout time.Duration) (*FileLock, error) {
+	return filelock.Acquire(cacheSubdir, CacheLockFile, "asset cache", timeout)
+}
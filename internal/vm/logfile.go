@@ -0,0 +1,263 @@
+package vm
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/javanstorm/vmterminal/internal/terminal"
+)
+
+// tailChunkSize is how much TailFile reads at a time when scanning
+// backwards for line breaks.
+const tailChunkSize = 8192
+
+// TailFile writes the last lines of the file at path to w, or the whole
+// file if lines is 0 or less. With follow set it keeps writing data as it
+// is appended, reopening the file when it is rotated or replaced, and only
+// returns on error.
+func TailFile(path string, lines int, follow bool, w io.Writer) error {
+	return TailFileContext(context.Background(), path, lines, time.Time{}, follow, w)
+}
+
+// TailFileContext is TailFile with a context that ends --follow mode and
+// a cutoff: unless since is zero, only lines stamped at or after since are
+// written (see SinceFilter), and the last lines are counted among those.
+func TailFileContext(ctx context.Context, path string, lines int, since time.Time, follow bool, w io.Writer) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("open log: %w", err)
+	}
+	defer func() { f.Close() }()
+
+	// Watch the directory rather than the file so a rotation (rename and
+	// recreate) is seen as a Create of path. Start before the first read
+	// so nothing appended in between is missed.
+	var watcher *fsnotify.Watcher
+	if follow {
+		if watcher, err = fsnotify.NewWatcher(); err != nil {
+			return fmt.Errorf("watch log: %w", err)
+		}
+		defer watcher.Close()
+		if err := watcher.Add(filepath.Dir(path)); err != nil {
+			return fmt.Errorf("watch log: %w", err)
+		}
+	}
+
+	if since.IsZero() {
+		err = copyTail(f, lines, w)
+	} else {
+		err = copyTailSince(f, lines, since, w)
+	}
+	if err != nil {
+		return err
+	}
+	if !follow {
+		return nil
+	}
+
+	name := filepath.Clean(path)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-watcher.Errors:
+			return fmt.Errorf("watch log: %w", err)
+		case ev := <-watcher.Events:
+			if filepath.Clean(ev.Name) != name {
+				continue
+			}
+			switch {
+			case ev.Has(fsnotify.Write):
+				if err := copyAppended(f, w); err != nil {
+					return err
+				}
+			case ev.Has(fsnotify.Create):
+				// Drain whatever reached the old file before it was
+				// replaced, then start over on the new one.
+				if err := copyAppended(f, w); err != nil {
+					return err
+				}
+				nf, err := os.Open(path)
+				if err != nil {
+					return fmt.Errorf("reopen log: %w", err)
+				}
+				f.Close()
+				f = nf
+				if _, err := io.Copy(w, f); err != nil {
+					return fmt.Errorf("read log: %w", err)
+				}
+			}
+		}
+	}
+}
+
+// copyTail writes the last lines of f to w, or all of it if lines is 0 or
+// less, leaving f at its end.
+func copyTail(f *os.File, lines int, w io.Writer) error {
+	start := int64(0)
+	if lines > 0 {
+		info, err := f.Stat()
+		if err != nil {
+			return fmt.Errorf("stat log: %w", err)
+		}
+		if start, err = tailOffset(f, info.Size(), lines); err != nil {
+			return fmt.Errorf("read log: %w", err)
+		}
+	}
+	if _, err := f.Seek(start, io.SeekStart); err != nil {
+		return fmt.Errorf("seek log: %w", err)
+	}
+	if _, err := io.Copy(w, f); err != nil {
+		return fmt.Errorf("read log: %w", err)
+	}
+	return nil
+}
+
+// copyTailSince is copyTail for the lines stamped at or after since: the
+// whole file is filtered first, so lines counts only lines that pass.
+func copyTailSince(f *os.File, lines int, since time.Time, w io.Writer) error {
+	var kept bytes.Buffer
+	filter := NewSinceFilter(&kept, since)
+	if _, err := io.Copy(filter, f); err != nil {
+		return fmt.Errorf("read log: %w", err)
+	}
+	filter.Flush()
+
+	out := kept.Bytes()
+	if lines > 0 {
+		start, err := tailOffset(bytes.NewReader(out), int64(len(out)), lines)
+		if err != nil {
+			return err
+		}
+		out = out[start:]
+	}
+	_, err := w.Write(out)
+	return err
+}
+
+// copyAppended writes data added to f since the last read, starting over
+// from the beginning if f was truncated.
+func copyAppended(f *os.File, w io.Writer) error {
+	pos, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return fmt.Errorf("seek log: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("stat log: %w", err)
+	}
+	if info.Size() < pos {
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return fmt.Errorf("seek log: %w", err)
+		}
+	}
+	if _, err := io.Copy(w, f); err != nil {
+		return fmt.Errorf("read log: %w", err)
+	}
+	return nil
+}
+
+// tailOffset returns the offset of the first of the last n lines of the
+// first end bytes of r, reading backwards from end. A final line without a
+// trailing newline counts as a line.
+func tailOffset(r io.ReaderAt, end int64, n int) (int64, error) {
+	if end == 0 {
+		return 0, nil
+	}
+
+	buf := make([]byte, tailChunkSize)
+	pos := end
+	skipTrailing := true // the newline ending the last line starts no line
+	for pos > 0 {
+		size := int64(len(buf))
+		if pos < size {
+			size = pos
+		}
+		pos -= size
+		if _, err := r.ReadAt(buf[:size], pos); err != nil && !errors.Is(err, io.EOF) {
+			return 0, err
+		}
+		chunk := buf[:size]
+		for i := len(chunk) - 1; i >= 0; i-- {
+			if chunk[i] != '\n' {
+				continue
+			}
+			if skipTrailing && pos+int64(i) == end-1 {
+				continue
+			}
+			n--
+			if n == 0 {
+				return pos + int64(i) + 1, nil
+			}
+		}
+		skipTrailing = false
+	}
+	return 0, nil
+}
+
+// SinceFilter is a writer that drops console log lines stamped before a
+// cutoff. Lines without a timestamp follow the line before them, so a
+// line split across writes or a continuation is kept with its start.
+type SinceFilter struct {
+	mu      sync.Mutex
+	w       io.Writer
+	since   time.Time
+	keep    bool
+	partial []byte
+}
+
+// NewSinceFilter returns a SinceFilter writing lines at or after since to w.
+func NewSinceFilter(w io.Writer, since time.Time) *SinceFilter {
+	return &SinceFilter{w: w, since: since}
+}
+
+// Write buffers p and writes every complete line that passes the filter.
+func (s *SinceFilter) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.partial = append(s.partial, p...)
+	for {
+		i := bytes.IndexByte(s.partial, '\n')
+		if i < 0 {
+			break
+		}
+		if err := s.writeLine(s.partial[:i+1]); err != nil {
+			return 0, err
+		}
+		s.partial = s.partial[i+1:]
+	}
+	return len(p), nil
+}
+
+// Flush writes a buffered final line that has no trailing newline.
+func (s *SinceFilter) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.partial) == 0 {
+		return nil
+	}
+	err := s.writeLine(s.partial)
+	s.partial = nil
+	return err
+}
+
+func (s *SinceFilter) writeLine(line []byte) error {
+	if ts, ok := terminal.ParseLineTime(line); ok {
+		s.keep = !ts.Before(s.since)
+	}
+	if !s.keep {
+		return nil
+	}
+	_, err := s.w.Write(line)
+	return err
+}
@@ -0,0 +1,167 @@
+package vm
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTailFileLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "console.log")
+	var content strings.Builder
+	for i := 1; i <= 5000; i++ {
+		content.WriteString("line ")
+		content.WriteString(strings.Repeat("x", i%7))
+		content.WriteString("\n")
+	}
+	os.WriteFile(path, []byte(content.String()), 0644)
+	all := strings.SplitAfter(content.String(), "\n")
+	all = all[:len(all)-1]
+
+	tests := []struct {
+		lines int
+		want  string
+	}{
+		{0, content.String()},
+		{1, all[len(all)-1]},
+		{3, strings.Join(all[len(all)-3:], "")},
+		{4000, strings.Join(all[len(all)-4000:], "")},
+		{10000, content.String()},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := TailFile(path, tt.lines, false, &buf); err != nil {
+			t.Fatalf("TailFile(%d): %v", tt.lines, err)
+		}
+		if buf.String() != tt.want {
+			t.Errorf("TailFile(%d) returned %d bytes, want %d", tt.lines, buf.Len(), len(tt.want))
+		}
+	}
+}
+
+func TestTailFileNoTrailingNewline(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "console.log")
+	os.WriteFile(path, []byte("a\nb\nlogin: "), 0644)
+
+	var buf bytes.Buffer
+	if err := TailFile(path, 2, false, &buf); err != nil {
+		t.Fatalf("TailFile: %v", err)
+	}
+	if buf.String() != "b\nlogin: " {
+		t.Errorf("TailFile = %q, want %q", buf.String(), "b\nlogin: ")
+	}
+}
+
+// syncBuffer is a bytes.Buffer safe for a writer and a poller.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func waitForOutput(t *testing.T, b *syncBuffer, want string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if b.String() == want {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("output = %q, want %q", b.String(), want)
+}
+
+func TestTailFileFollowsRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "console.log")
+	os.WriteFile(path, []byte("first\n"), 0644)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var out syncBuffer
+	done := make(chan error, 1)
+	go func() { done <- TailFileContext(ctx, path, 0, time.Time{}, true, &out) }()
+	waitForOutput(t, &out, "first\n")
+
+	f, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	f.WriteString("second\n")
+	f.Close()
+	waitForOutput(t, &out, "first\nsecond\n")
+
+	// Rotate the way RotatingLogWriter does: rename, then recreate
+	os.Rename(path, path+".1")
+	os.WriteFile(path, []byte("third\n"), 0644)
+	waitForOutput(t, &out, "first\nsecond\nthird\n")
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("TailFileContext: %v", err)
+	}
+}
+
+func TestSinceFilter(t *testing.T) {
+	log := "2026-01-02T03:00:00.000Z old\n" +
+		"continuation of old\n" +
+		"2026-01-02T03:10:00.000Z new\n" +
+		"continuation of new\n" +
+		"2026-01-02T03:11:00.000Z login: "
+
+	var buf bytes.Buffer
+	f := NewSinceFilter(&buf, time.Date(2026, 1, 2, 3, 5, 0, 0, time.UTC))
+	// Split mid-line to check partial lines are held back
+	f.Write([]byte(log[:40]))
+	f.Write([]byte(log[40:]))
+	if err := f.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	want := "2026-01-02T03:10:00.000Z new\ncontinuation of new\n2026-01-02T03:11:00.000Z login: "
+	if buf.String() != want {
+		t.Errorf("filtered = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestTailFileSinceThenLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "console.log")
+	log := "2026-01-02T03:00:00.000Z a\n" +
+		"2026-01-02T03:01:00.000Z b\n" +
+		"2026-01-02T03:10:00.000Z c\n" +
+		"2026-01-02T03:11:00.000Z d\n"
+	if err := os.WriteFile(path, []byte(log), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// A cutoff after every line must print nothing, not the last lines
+	var out bytes.Buffer
+	late := time.Date(2026, 1, 2, 4, 0, 0, 0, time.UTC)
+	if err := TailFileContext(context.Background(), path, 2, late, false, &out); err != nil {
+		t.Fatalf("TailFileContext: %v", err)
+	}
+	if out.Len() != 0 {
+		t.Errorf("late cutoff printed %q", out.String())
+	}
+
+	out.Reset()
+	since := time.Date(2026, 1, 2, 3, 1, 0, 0, time.UTC)
+	if err := TailFileContext(context.Background(), path, 2, since, false, &out); err != nil {
+		t.Fatalf("TailFileContext: %v", err)
+	}
+	want := "2026-01-02T03:10:00.000Z c\n2026-01-02T03:11:00.000Z d\n"
+	if out.String() != want {
+		t.Errorf("since then lines = %q, want %q", out.String(), want)
+	}
+}
@@ -2,25 +2,37 @@ package vm
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/javanstorm/vmterminal/internal/distro"
+	"github.com/javanstorm/vmterminal/internal/progress"
+	"github.com/javanstorm/vmterminal/internal/timing"
 	"github.com/javanstorm/vmterminal/pkg/hypervisor"
 )
 
+// DefaultStopTimeout is how long Stop gives the guest to shut down before
+// the VM is killed.
+const DefaultStopTimeout = 30 * time.Second
+
 // State represents the VM lifecycle state.
 type State int
 
 const (
-	StateNew State = iota
-	StateReady     // Assets downloaded, disk created
-	StateRunning   // VM is running
-	StateStopping  // Shutdown in progress
-	StateStopped   // Clean shutdown complete
-	StateError     // Error state
+	StateNew       State = iota
+	StateReady           // Assets downloaded, disk created
+	StateRunning         // VM is running
+	StateStopping        // Shutdown in progress
+	StateStopped         // Clean shutdown complete
+	StateError           // Error state
+	StateSuspended       // VM is paused, memory resident
 )
 
 func (s State) String() string {
@@ -31,6 +43,8 @@ func (s State) String() string {
 		return "ready"
 	case StateRunning:
 		return "running"
+	case StateSuspended:
+		return "suspended"
 	case StateStopping:
 		return "stopping"
 	case StateStopped:
@@ -62,20 +76,157 @@ type ManagerConfig struct {
 	// DiskName is the name for the disk image (without extension).
 	DiskName string
 
+	// DiskPath, if set, is booted instead of the distro's usual disk image,
+	// e.g. an ephemeral copy from CreateEphemeralDisk.
+	DiskPath string
+
 	// SharedDirs maps mount tags to host paths for filesystem sharing.
 	SharedDirs map[string]string
 
+	// SharedDirsReadOnly marks mount tags whose shares are read-only.
+	SharedDirsReadOnly map[string]bool
+
 	// EnableNetwork enables VM networking.
 	EnableNetwork bool
 
 	// MACAddress is optional custom MAC (empty = auto-generate).
 	MACAddress string
 
+	// Networks lists the VM's network interfaces. When set, EnableNetwork
+	// and MACAddress are ignored.
+	Networks []hypervisor.NetworkInterface
+
 	// SSHHostPort is the host port for SSH port forwarding (0 = disabled).
 	SSHHostPort int
 
+	// PortForwards are additional host-to-guest port forwarding rules.
+	PortForwards []hypervisor.PortForward
+
+	// TapFile is an open tap device used for networking on Linux KVM.
+	// The caller owns it and must keep it open while the VM runs.
+	TapFile *os.File
+
+	// TapDevice is the name of TapFile's interface, where StartCapture
+	// finds the VM's traffic.
+	TapDevice string
+
+	// EnableVsock adds a virtio-vsock device; see Manager.Vsock.
+	EnableVsock bool
+
+	// AdditionalDisks are data disks attached after the root disk, seen
+	// by the guest as /dev/vdb, /dev/vdc and so on (after the overlay disk
+	// with Overlay).
+	AdditionalDisks []DiskConfig
+
+	// KernelArgs are appended to the distro's kernel command line.
+	// They have no effect on UEFI boots, where the bootloader on the
+	// disk chooses the command line.
+	KernelArgs []string
+
+	// ExtraCmdlineFile, if it exists, holds kernel arguments appended to
+	// the distro's command line ahead of KernelArgs (see ReadExtraCmdline).
+	ExtraCmdlineFile string
+
+	// Overlay boots the root disk read-only under an overlay whose writes
+	// go to a new overlay disk of OverlaySizeMB (0 = DefaultOverlaySizeMB),
+	// recreated on every boot. It needs an initramfs with the overlayroot
+	// hook and a distro booted with its own kernel.
+	Overlay       bool
+	OverlaySizeMB int64
+
+	// StopTimeout is how long Stop gives the guest to shut down before
+	// the VM is killed (0 = DefaultStopTimeout).
+	StopTimeout time.Duration
+
+	// Hostname, if set, is written into the guest's /etc/hostname and
+	// /etc/hosts before the disk is handed to the hypervisor.
+	Hostname string
+
 	// Provider is the distribution provider.
 	Provider distro.Provider
+
+	// CloudInit, if set, is served to the guest on a NoCloud seed ISO
+	// attached as a second disk.
+	CloudInit *distro.CloudInitConfig
+
+	// SkipVerify disables OpenPGP signature checks on downloaded assets.
+	SkipVerify bool
+
+	// DownloadLimit caps asset download speed in bytes per second (0 = unlimited).
+	DownloadLimit int64
+
+	// Mirrors are base URLs tried before the distro's own servers, in
+	// round-robin order (see AssetManager.WithMirrors).
+	Mirrors []string
+
+	// Proxy is the HTTP proxy for asset downloads, overriding HTTP_PROXY
+	// and HTTPS_PROXY (empty = use the environment).
+	Proxy string
+
+	// CacheMaxBytes limits the asset cache; older distro versions are
+	// evicted after a download beyond it (0 = unlimited).
+	CacheMaxBytes int64
+
+	// Progress receives asset download progress (nil = silent).
+	Progress progress.Progress
+}
+
+// managerConfigJSON is the JSON form of ManagerConfig. Shared directories,
+// networks and port forwards are left to the VMConfig they end up in.
+type managerConfigJSON struct {
+	CacheDir      string     `json:"cache_dir"`
+	DataDir       string     `json:"data_dir"`
+	CPUs          int        `json:"cpus"`
+	MemoryMB      int        `json:"memory_mb"`
+	DiskSizeMB    int64      `json:"disk_size_mb"`
+	DiskName      string     `json:"disk_name"`
+	DiskPath      string     `json:"disk_path,omitempty"`
+	Distro        distroJSON `json:"distro"`
+	CloudInit     bool       `json:"cloud_init"`
+	SSHHostPort   int        `json:"ssh_host_port,omitempty"`
+	KernelArgs    []string   `json:"kernel_args,omitempty"`
+	Overlay       bool       `json:"overlay,omitempty"`
+	Hostname      string     `json:"hostname,omitempty"`
+	SkipVerify    bool       `json:"skip_verify,omitempty"`
+	DownloadLimit int64      `json:"download_limit,omitempty"`
+	Mirrors       []string   `json:"mirrors,omitempty"`
+	CacheMaxBytes int64      `json:"cache_max_bytes,omitempty"`
+}
+
+type distroJSON struct {
+	ID      distro.ID `json:"id"`
+	Name    string    `json:"name"`
+	Version string    `json:"version"`
+}
+
+// MarshalJSON renders the configuration for dry runs and bug reports.
+// Cloud-init user-data may hold secrets, so only its presence is shown,
+// and DiskPath is reduced to its base name.
+func (c ManagerConfig) MarshalJSON() ([]byte, error) {
+	out := managerConfigJSON{
+		CacheDir:      c.CacheDir,
+		DataDir:       c.DataDir,
+		CPUs:          c.CPUs,
+		MemoryMB:      c.MemoryMB,
+		DiskSizeMB:    c.DiskSizeMB,
+		DiskName:      c.DiskName,
+		CloudInit:     c.CloudInit != nil,
+		SSHHostPort:   c.SSHHostPort,
+		KernelArgs:    c.KernelArgs,
+		Overlay:       c.Overlay,
+		Hostname:      c.Hostname,
+		SkipVerify:    c.SkipVerify,
+		DownloadLimit: c.DownloadLimit,
+		Mirrors:       c.Mirrors,
+		CacheMaxBytes: c.CacheMaxBytes,
+	}
+	if c.DiskPath != "" {
+		out.DiskPath = filepath.Base(c.DiskPath)
+	}
+	if c.Provider != nil {
+		out.Distro = distroJSON{ID: c.Provider.ID(), Name: c.Provider.Name(), Version: c.Provider.Version()}
+	}
+	return json.Marshal(out)
 }
 
 // Manager orchestrates VM lifecycle with asset and disk management.
@@ -88,7 +239,11 @@ type Manager struct {
 	mu        sync.RWMutex
 	state     State
 	errCh     chan error
+	done      chan struct{} // closed by monitorVM once the VM exits
+	exitErr   error
 	lastErr   error
+	diskPath  string
+	forwarder *portForwarder // relays the port forwards while the VM runs
 }
 
 // NewManager creates a new VM manager.
@@ -111,6 +266,9 @@ func NewManager(cfg ManagerConfig) (*Manager, error) {
 	if cfg.DiskName == "" {
 		cfg.DiskName = "root"
 	}
+	if cfg.StopTimeout == 0 {
+		cfg.StopTimeout = DefaultStopTimeout
+	}
 
 	// Use default provider if not specified
 	if cfg.Provider == nil {
@@ -121,9 +279,13 @@ func NewManager(cfg ManagerConfig) (*Manager, error) {
 		}
 	}
 
+	assets := NewAssetManager(cfg.CacheDir, cfg.Provider, cfg.Progress)
+	assets.SetSkipVerify(cfg.SkipVerify)
+	assets.WithBandwidthLimit(cfg.DownloadLimit).WithMirrors(cfg.Mirrors).WithProxy(cfg.Proxy).WithCacheLimit(cfg.CacheMaxBytes)
+
 	return &Manager{
 		cfg:       cfg,
-		assets:    NewAssetManager(cfg.CacheDir, cfg.Provider),
+		assets:    assets,
 		images:    NewImageManager(cfg.DataDir),
 		driver:    driver,
 		stateFile: NewStateFile(cfg.DataDir),
@@ -141,8 +303,10 @@ func (m *Manager) Prepare(ctx context.Context) error {
 		return fmt.Errorf("cannot prepare: invalid state %s", m.state)
 	}
 
-	// Check for warm path - assets and disk already exist
-	if m.isWarmPath() {
+	// Check for warm path - assets and disk already exist.
+	// The cloud-init seed and overlay disk are rebuilt on the cold path
+	// every time.
+	if m.cfg.CloudInit == nil && !m.cfg.Overlay && m.isWarmPath() {
 		return m.warmPrepare(ctx)
 	}
 
@@ -157,9 +321,15 @@ func (m *Manager) isWarmPath() bool {
 		return false
 	}
 
+	// Data disks are created on the cold path
+	for _, d := range m.cfg.AdditionalDisks {
+		if !m.images.DiskExists(d.Name) {
+			return false
+		}
+	}
+
 	// Check if disk exists - depends on distro setup requirements
-	setupReqs := m.assets.SetupRequirements()
-	if setupReqs != nil && !setupReqs.NeedsExtraction {
+	if m.bootsImage() {
 		// For qcow2-based distros, check if rootfs.raw exists
 		paths, err := m.assets.GetAssetPaths()
 		if err != nil {
@@ -172,16 +342,35 @@ func (m *Manager) isWarmPath() bool {
 		if _, err := os.Stat(paths.Rootfs); err != nil {
 			return false
 		}
-		return true
+		// The VM's copy of the image is made on the cold path
+		if filepath.Ext(paths.Rootfs) == ".iso" {
+			return true
+		}
 	}
 
-	// For extraction-based distros, check if disk exists
 	return m.images.DiskExists(m.cfg.DiskName)
 }
 
 // warmPrepare is the optimized path when assets and disk already exist.
 // Skips EnsureAssets/EnsureDisk overhead and goes directly to VM creation.
 func (m *Manager) warmPrepare(ctx context.Context) error {
+	// A crash may have left the cached kernel or initramfs corrupt
+	if m.uncleanShutdown() {
+		ok, corrupt, err := m.assets.VerifyAssets()
+		if err != nil {
+			return m.coldPrepare(ctx)
+		}
+		if !ok {
+			fmt.Fprintf(os.Stderr, "Warning: cached boot assets are corrupt after an unclean shutdown, fetching them again: %s\n", strings.Join(corrupt, ", "))
+			if err := m.assets.DiscardAssets(corrupt); err != nil {
+				m.state = StateError
+				m.lastErr = err
+				return err
+			}
+			return m.coldPrepare(ctx)
+		}
+	}
+
 	// Get cached paths directly - we know they exist
 	assetPaths, err := m.assets.GetAssetPaths()
 	if err != nil {
@@ -190,39 +379,28 @@ func (m *Manager) warmPrepare(ctx context.Context) error {
 	}
 
 	// Determine disk path based on distro setup requirements
-	var diskPath string
-	setupReqs := m.assets.SetupRequirements()
-	if setupReqs != nil && !setupReqs.NeedsExtraction && assetPaths.Rootfs != "" {
-		// For qcow2-based distros, use the converted raw image
+	diskPath := m.images.DiskPath(m.cfg.DiskName)
+	if m.bootsImage() && filepath.Ext(assetPaths.Rootfs) == ".iso" {
 		diskPath = assetPaths.Rootfs
-	} else {
-		diskPath = m.images.DiskPath(m.cfg.DiskName)
+	}
+	if m.cfg.DiskPath != "" {
+		diskPath = m.cfg.DiskPath
 	}
 
-	bootConfig := m.assets.BootConfig()
+	extraArgs, err := m.extraKernelArgs()
+	if err != nil {
+		m.state = StateError
+		m.lastErr = err
+		return err
+	}
 
 	// Configure and create VM
-	vmCfg := &hypervisor.VMConfig{
-		CPUs:          m.cfg.CPUs,
-		MemoryMB:      m.cfg.MemoryMB,
-		Kernel:        assetPaths.Kernel,
-		Initrd:        assetPaths.Initramfs,
-		Cmdline:       bootConfig.Cmdline,
-		DiskPath:      diskPath,
-		SharedDirs:    m.cfg.SharedDirs,
-		EnableNetwork: m.cfg.EnableNetwork,
-		MACAddress:    m.cfg.MACAddress,
-	}
-
-	// Add SSH port forwarding if configured
-	if m.cfg.SSHHostPort > 0 {
-		vmCfg.PortForwards = map[int]int{
-			m.cfg.SSHHostPort: 22,
-		}
-	}
+	vmCfg := m.vmConfig(assetPaths, diskPath, extraArgs)
+	m.diskPath = diskPath
+	m.setHostname(diskPath)
 
 	// Skip Validate on warm path - config hasn't changed since last successful run
-	if err := m.driver.Create(ctx, vmCfg); err != nil {
+	if err := m.createVM(ctx, vmCfg); err != nil {
 		m.state = StateError
 		m.lastErr = err
 		return fmt.Errorf("create VM: %w", err)
@@ -232,55 +410,207 @@ func (m *Manager) warmPrepare(ctx context.Context) error {
 	return nil
 }
 
+// uncleanShutdown reports whether the VM has booted before and its last
+// run did not end in a clean shutdown.
+func (m *Manager) uncleanShutdown() bool {
+	if m.stateFile == nil {
+		return false
+	}
+	st, err := m.stateFile.Load()
+	return err == nil && st.BootCount > 0 && !st.CleanShutdown
+}
+
+// setHostname writes the configured hostname into diskPath before the
+// driver opens it, when the disk is the VM's own and does not carry the
+// hostname yet. A live ISO or ephemeral disk is left alone. A failure only leaves the guest with its previous
+// hostname, so it is reported once per hostname and the boot goes on.
+func (m *Manager) setHostname(diskPath string) {
+	if m.cfg.Hostname == "" || m.cfg.DiskPath != "" || diskPath != m.images.DiskPath(m.cfg.DiskName) {
+		return
+	}
+	if HostnameInjected(diskPath, m.cfg.Hostname) || HostnameSkipped(diskPath, m.cfg.Hostname) {
+		return
+	}
+	if err := InjectHostnameImage(diskPath, m.cfg.Hostname); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not set hostname %q: %v\n", m.cfg.Hostname, err)
+		RecordHostnameSkipped(diskPath, m.cfg.Hostname)
+	}
+}
+
+// bootsImage reports whether the root disk is a copy of the distro's own
+// disk image, as for cloud images and anything booted through UEFI, rather
+// than a disk the rootfs is extracted into.
+func (m *Manager) bootsImage() bool {
+	if reqs := m.assets.SetupRequirements(); reqs != nil && !reqs.NeedsExtraction {
+		return true
+	}
+	return m.assets.BootConfig().UEFI
+}
+
+// extraKernelArgs returns the arguments of the ExtraCmdlineFile, warning
+// about any that weaken the guest's security.
+func (m *Manager) extraKernelArgs() ([]string, error) {
+	if m.cfg.ExtraCmdlineFile == "" {
+		return nil, nil
+	}
+	args, warnings, err := ReadExtraCmdline(m.cfg.ExtraCmdlineFile)
+	if err != nil {
+		return nil, err
+	}
+	for _, w := range warnings {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", w)
+	}
+	return args, nil
+}
+
+// vmConfig builds the driver configuration for the given assets and disk.
+// extraArgs go on the kernel command line ahead of the VM's KernelArgs.
+// UEFI boots load the kernel from the disk, so no kernel is passed.
+func (m *Manager) vmConfig(assetPaths *AssetPaths, diskPath string, extraArgs []string) *hypervisor.VMConfig {
+	bootConfig := m.assets.BootConfig()
+	vmCfg := &hypervisor.VMConfig{
+		CPUs:               m.cfg.CPUs,
+		MemoryMB:           m.cfg.MemoryMB,
+		Kernel:             assetPaths.Kernel,
+		Initrd:             assetPaths.Initramfs,
+		Cmdline:            kernelCmdline(bootConfig.Cmdline, append(extraArgs, m.cfg.KernelArgs...)),
+		DiskPath:           diskPath,
+		SharedDirs:         m.cfg.SharedDirs,
+		SharedDirsReadOnly: m.cfg.SharedDirsReadOnly,
+		EnableNetwork:      m.cfg.EnableNetwork,
+		MACAddress:         m.cfg.MACAddress,
+		Networks:           m.cfg.Networks,
+		PortForwards:       m.portForwards(),
+		TapFile:            m.cfg.TapFile,
+		EnableVsock:        m.cfg.EnableVsock,
+		ExtraDisks:         m.additionalDisks(),
+	}
+	if len(vmCfg.SharedDirs) > 0 {
+		if p, ok := MountProtocolFor(m.driver.Capabilities()); ok && p == Mount9P {
+			vmCfg.Cmdline = withPlan9Modules(vmCfg.Cmdline)
+		}
+	}
+	if bootConfig.UEFI {
+		vmCfg.UEFI = true
+		vmCfg.Kernel, vmCfg.Initrd, vmCfg.Cmdline = "", "", ""
+	}
+	return vmCfg
+}
+
+// kernelCmdline appends args to the distro's kernel command line.
+func kernelCmdline(base string, args []string) string {
+	if len(args) == 0 {
+		return base
+	}
+	return strings.TrimSpace(base + " " + strings.Join(args, " "))
+}
+
+// seedPath is where the cloud-init seed ISO is built.
+func (m *Manager) seedPath() string {
+	return filepath.Join(m.cfg.DataDir, "seed.iso")
+}
+
+// DryRunConfig returns the configuration Prepare would pass to the driver,
+// without downloading assets, creating disks or building the cloud-init
+// seed. Kernel, Initrd and the disk of image-based distros are empty if
+// they have not been downloaded yet.
+func (m *Manager) DryRunConfig() (*hypervisor.VMConfig, error) {
+	assetPaths, err := m.assets.GetAssetPaths()
+	if err != nil {
+		return nil, err
+	}
+
+	diskPath := m.images.DiskPath(m.cfg.DiskName)
+	if m.bootsImage() && filepath.Ext(assetPaths.Rootfs) == ".iso" {
+		diskPath = assetPaths.Rootfs
+	}
+	if m.cfg.DiskPath != "" {
+		diskPath = m.cfg.DiskPath
+	}
+
+	extraArgs, err := m.extraKernelArgs()
+	if err != nil {
+		return nil, err
+	}
+
+	vmCfg := m.vmConfig(assetPaths, diskPath, extraArgs)
+	if m.cfg.Overlay {
+		if err := m.applyOverlay(vmCfg); err != nil {
+			return nil, err
+		}
+	}
+	if m.cfg.CloudInit != nil {
+		vmCfg.ExtraDisks = append(vmCfg.ExtraDisks, hypervisor.StorageDevice{Path: m.seedPath(), ReadOnly: true})
+	}
+	return vmCfg, nil
+}
+
+// portForwards returns the SSH forward (if configured) followed by user rules.
+func (m *Manager) portForwards() []hypervisor.PortForward {
+	var forwards []hypervisor.PortForward
+	if m.cfg.SSHHostPort > 0 {
+		forwards = append(forwards, hypervisor.PortForward{Host: m.cfg.SSHHostPort, Guest: 22, Proto: "tcp"})
+	}
+	return append(forwards, m.cfg.PortForwards...)
+}
+
 // coldPrepare is the full path that ensures assets and disk exist.
 func (m *Manager) coldPrepare(ctx context.Context) error {
 	// Download kernel/initramfs if needed
+	_, span := timing.StartSpan(ctx, "asset_ensure")
 	assetPaths, err := m.assets.EnsureAssets()
+	span.End()
 	if err != nil {
 		m.state = StateError
 		m.lastErr = err
 		return fmt.Errorf("ensure assets: %w", err)
 	}
 
-	// Determine disk path based on distro setup requirements
-	var diskPath string
-	setupReqs := m.assets.SetupRequirements()
+	diskPath, err := m.ensureDisks(ctx, assetPaths)
+	if err != nil {
+		m.state = StateError
+		m.lastErr = err
+		return err
+	}
 
-	if setupReqs != nil && !setupReqs.NeedsExtraction && assetPaths.Rootfs != "" {
-		// For distros like Ubuntu where rootfs is the complete disk image,
-		// use the converted raw image directly
-		diskPath = assetPaths.Rootfs
-	} else {
-		// Create disk image if needed (for distros like Alpine that need extraction)
-		diskPath, err = m.images.EnsureDisk(m.cfg.DiskName, m.cfg.DiskSizeMB)
-		if err != nil {
+	extraArgs, err := m.extraKernelArgs()
+	if err != nil {
+		m.state = StateError
+		m.lastErr = err
+		return err
+	}
+
+	// Get boot config from provider
+	// Configure and create VM
+	vmCfg := m.vmConfig(assetPaths, diskPath, extraArgs)
+	m.diskPath = diskPath
+	m.setHostname(diskPath)
+
+	if m.cfg.Overlay {
+		if err := m.applyOverlay(vmCfg); err != nil {
 			m.state = StateError
 			m.lastErr = err
-			return fmt.Errorf("ensure disk: %w", err)
+			return err
+		}
+		sizeMB := m.cfg.OverlaySizeMB
+		if sizeMB == 0 {
+			sizeMB = DefaultOverlaySizeMB
+		}
+		if err := m.images.CreateOverlayDisk(OverlayDiskName, sizeMB); err != nil {
+			m.state = StateError
+			m.lastErr = err
+			return err
 		}
 	}
 
-	// Get boot config from provider
-	bootConfig := m.assets.BootConfig()
-
-	// Configure and create VM
-	vmCfg := &hypervisor.VMConfig{
-		CPUs:          m.cfg.CPUs,
-		MemoryMB:      m.cfg.MemoryMB,
-		Kernel:        assetPaths.Kernel,
-		Initrd:        assetPaths.Initramfs,
-		Cmdline:       bootConfig.Cmdline,
-		DiskPath:      diskPath,
-		SharedDirs:    m.cfg.SharedDirs,
-		EnableNetwork: m.cfg.EnableNetwork,
-		MACAddress:    m.cfg.MACAddress,
-	}
-
-	// Add SSH port forwarding if configured
-	if m.cfg.SSHHostPort > 0 {
-		vmCfg.PortForwards = map[int]int{
-			m.cfg.SSHHostPort: 22,
+	if m.cfg.CloudInit != nil {
+		seedPath := m.seedPath()
+		if err := BuildSeedISO(m.cfg.CloudInit, seedPath); err != nil {
+			m.state = StateError
+			m.lastErr = err
+			return fmt.Errorf("cloud-init: %w", err)
 		}
+		vmCfg.ExtraDisks = append(vmCfg.ExtraDisks, hypervisor.StorageDevice{Path: seedPath, ReadOnly: true})
 	}
 
 	if err := m.driver.Validate(ctx, vmCfg); err != nil {
@@ -289,7 +619,7 @@ func (m *Manager) coldPrepare(ctx context.Context) error {
 		return fmt.Errorf("validate config: %w", err)
 	}
 
-	if err := m.driver.Create(ctx, vmCfg); err != nil {
+	if err := m.createVM(ctx, vmCfg); err != nil {
 		m.state = StateError
 		m.lastErr = err
 		return fmt.Errorf("create VM: %w", err)
@@ -299,6 +629,42 @@ func (m *Manager) coldPrepare(ctx context.Context) error {
 	return nil
 }
 
+// ensureDisks creates the VM's root disk and data disks if they do not
+// exist yet, and returns the path of the disk to boot.
+func (m *Manager) ensureDisks(ctx context.Context, assetPaths *AssetPaths) (string, error) {
+	_, span := timing.StartSpan(ctx, "disk_ensure")
+	defer span.End()
+
+	// Determine disk path based on distro setup requirements
+	var diskPath string
+	var err error
+	if m.bootsImage() && assetPaths.Rootfs != "" {
+		// For distros like Ubuntu where rootfs is the complete disk image,
+		// boot the VM's own copy of the converted raw image
+		diskPath, err = m.images.EnsureImageDisk(m.cfg.DiskName, assetPaths.Rootfs)
+	} else {
+		// Create disk image if needed (for distros like Alpine that need extraction)
+		diskPath, err = m.images.EnsureDisk(m.cfg.DiskName, m.cfg.DiskSizeMB)
+	}
+	if err != nil {
+		return "", fmt.Errorf("ensure disk: %w", err)
+	}
+	if m.cfg.DiskPath != "" {
+		diskPath = m.cfg.DiskPath
+	}
+	if err := m.ensureAdditionalDisks(); err != nil {
+		return "", fmt.Errorf("ensure disk: %w", err)
+	}
+	return diskPath, nil
+}
+
+// createVM has the driver create the VM.
+func (m *Manager) createVM(ctx context.Context, vmCfg *hypervisor.VMConfig) error {
+	ctx, span := timing.StartSpan(ctx, "vm_create")
+	defer span.End()
+	return m.driver.Create(ctx, vmCfg)
+}
+
 // Start boots the VM.
 func (m *Manager) Start(ctx context.Context) error {
 	m.mu.Lock()
@@ -308,14 +674,25 @@ func (m *Manager) Start(ctx context.Context) error {
 		return fmt.Errorf("cannot start: invalid state %s", m.state)
 	}
 
+	forwarder, err := m.startPortForwards()
+	if err != nil {
+		return err
+	}
+
 	errCh, err := m.driver.Start(ctx)
 	if err != nil {
+		if forwarder != nil {
+			forwarder.Close()
+		}
 		m.state = StateError
 		m.lastErr = err
 		return fmt.Errorf("start VM: %w", err)
 	}
 
+	m.forwarder = forwarder
 	m.errCh = errCh
+	m.done = make(chan struct{})
+	m.exitErr = nil
 	m.state = StateRunning
 
 	// Record boot in persistent state
@@ -330,6 +707,37 @@ func (m *Manager) Start(ctx context.Context) error {
 	return nil
 }
 
+// startPortForwards binds the host ports of the VM's port forwards, or
+// returns nil if there are none. The guest is found by the MAC of its first
+// network interface. Without one the SSH forward is skipped, since SSH can
+// still go over vsock, but port_forwards rules are an error.
+func (m *Manager) startPortForwards() (*portForwarder, error) {
+	forwards := m.portForwards()
+	if len(forwards) == 0 {
+		return nil, nil
+	}
+	mac := m.MACAddress()
+	if mac == "" {
+		if len(m.cfg.PortForwards) > 0 {
+			return nil, fmt.Errorf("port forwarding needs a network interface (on Linux, pass --tap-device)")
+		}
+		return nil, nil
+	}
+	return startPortForwards(forwards, func() (string, error) {
+		return LookupGuestIP(mac)
+	})
+}
+
+// MACAddress returns the MAC the driver gave the VM's first network
+// interface, which it generates when none is configured. It returns "" if
+// the driver cannot report it or the VM has no network interface.
+func (m *Manager) MACAddress() string {
+	if r, ok := m.driver.(hypervisor.MACReporter); ok {
+		return r.MACAddress()
+	}
+	return ""
+}
+
 // Stop gracefully shuts down the VM.
 func (m *Manager) Stop(ctx context.Context) error {
 	m.mu.Lock()
@@ -348,13 +756,88 @@ func (m *Manager) Stop(ctx context.Context) error {
 		return fmt.Errorf("stop VM: %w", err)
 	}
 
+	// A guest that ignores the request would otherwise never exit
+	m.mu.RLock()
+	done := m.done
+	m.mu.RUnlock()
+	if done != nil {
+		go m.killAfterTimeout(ctx, done)
+	}
+
+	return nil
+}
+
+// killAfterTimeout kills the VM unless it exits, closing done, within the
+// stop timeout or before ctx is cancelled.
+func (m *Manager) killAfterTimeout(ctx context.Context, done <-chan struct{}) {
+	timeout := m.cfg.StopTimeout
+	if timeout == 0 {
+		timeout = DefaultStopTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	select {
+	case <-done:
+		return
+	case <-ctx.Done():
+	}
+	fmt.Fprintf(os.Stderr, "Warning: VM did not shut down within %s, killing it\n", timeout)
+	if err := m.driver.Kill(context.Background()); err != nil && !errors.Is(err, hypervisor.ErrNotRunning) {
+		m.mu.Lock()
+		m.state = StateError
+		m.lastErr = err
+		m.mu.Unlock()
+		fmt.Fprintf(os.Stderr, "Warning: kill VM: %v\n", err)
+	}
+}
+
+// Suspend pauses the running VM.
+func (m *Manager) Suspend(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.state != StateRunning {
+		return fmt.Errorf("cannot suspend: invalid state %s", m.state)
+	}
+
+	if err := m.driver.Suspend(ctx); err != nil {
+		return fmt.Errorf("suspend VM: %w", err)
+	}
+	m.state = StateSuspended
+
+	if err := m.stateFile.RecordSuspend(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to record suspend: %v\n", err)
+	}
+
+	return nil
+}
+
+// Resume continues a suspended VM.
+func (m *Manager) Resume(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.state != StateSuspended {
+		return fmt.Errorf("cannot resume: invalid state %s", m.state)
+	}
+
+	if err := m.driver.Resume(ctx); err != nil {
+		return fmt.Errorf("resume VM: %w", err)
+	}
+	m.state = StateRunning
+
+	if err := m.stateFile.RecordResume(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to record resume: %v\n", err)
+	}
+
 	return nil
 }
 
 // Kill forcefully terminates the VM.
 func (m *Manager) Kill(ctx context.Context) error {
 	m.mu.Lock()
-	if m.state != StateRunning && m.state != StateStopping {
+	if m.state != StateRunning && m.state != StateSuspended && m.state != StateStopping {
 		m.mu.Unlock()
 		return fmt.Errorf("cannot kill: invalid state %s", m.state)
 	}
@@ -385,17 +868,40 @@ func (m *Manager) LastError() error {
 	return m.lastErr
 }
 
-// Wait blocks until the VM stops.
+// Wait blocks until the VM stops and returns its exit error.
+// The driver's error channel is consumed by monitorVM, so Wait waits for
+// monitorVM to finish instead of reading the channel itself.
 func (m *Manager) Wait() error {
 	m.mu.RLock()
-	errCh := m.errCh
+	done := m.done
 	m.mu.RUnlock()
 
-	if errCh == nil {
+	if done == nil {
 		return fmt.Errorf("VM not started")
 	}
 
-	return <-errCh
+	<-done
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.exitErr
+}
+
+// Vsock returns the driver's vsock dialer, or nil if the VM was not
+// configured with EnableVsock or the driver cannot open vsock connections.
+func (m *Manager) Vsock() hypervisor.VsockDialer {
+	if !m.cfg.EnableVsock {
+		return nil
+	}
+	dialer, _ := m.driver.(hypervisor.VsockDialer)
+	return dialer
+}
+
+// ConsoleResizer returns the driver's console resizer, or nil if the
+// driver cannot tell the guest its console size.
+func (m *Manager) ConsoleResizer() hypervisor.ConsoleResizer {
+	resizer, _ := m.driver.(hypervisor.ConsoleResizer)
+	return resizer
 }
 
 // DriverInfo returns hypervisor driver information.
@@ -415,12 +921,18 @@ func (m *Manager) monitorVM() {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	if m.forwarder != nil {
+		m.forwarder.Close()
+		m.forwarder = nil
+	}
 	if err != nil {
 		m.state = StateError
 		m.lastErr = err
 	} else {
 		m.state = StateStopped
 	}
+	m.exitErr = err
+	close(m.done)
 }
 
 // PersistentState returns the current persistent state.
@@ -433,7 +945,7 @@ func (m *Manager) Console() (io.Writer, io.Reader, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 	if m.state != StateRunning {
-		return nil, nil, fmt.Errorf("VM not running")
+		return nil, nil, &ErrVMNotRunning{}
 	}
 	return m.driver.Console()
 }
@@ -444,6 +956,18 @@ func (m *Manager) CloseConsole() error {
 	return m.driver.CloseConsole()
 }
 
+// Config returns the manager configuration with defaults applied.
+func (m *Manager) Config() ManagerConfig {
+	return m.cfg
+}
+
+// DiskPath returns the disk image the VM boots from, once prepared.
+func (m *Manager) DiskPath() string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.diskPath
+}
+
 // Provider returns the distro provider.
 func (m *Manager) Provider() distro.Provider {
 	return m.cfg.Provider
@@ -1,9 +1,21 @@
 package vm
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/javanstorm/vmterminal/internal/distro"
+	"github.com/javanstorm/vmterminal/pkg/hypervisor"
 )
 
 // createTestConfig creates a ManagerConfig suitable for testing.
@@ -96,6 +108,15 @@ func TestManagerConfigDefaults(t *testing.T) {
 
 // TestNewManagerRequiresHypervisor documents that NewManager requires hypervisor access.
 // This test is skipped when /dev/kvm is not available (typical in CI/containers).
+func TestConsoleRequiresRunningVM(t *testing.T) {
+	m := &Manager{state: StateStopped}
+	_, _, err := m.Console()
+	var notRunning *ErrVMNotRunning
+	if !errors.As(err, &notRunning) {
+		t.Errorf("Console on a stopped VM = %v, want ErrVMNotRunning", err)
+	}
+}
+
 func TestNewManagerRequiresHypervisor(t *testing.T) {
 	cfg := createTestConfig(t)
 
@@ -127,3 +148,395 @@ func TestDefaultDiskSizeMB(t *testing.T) {
 		t.Errorf("DefaultDiskSizeMB = %d, want at least 1024", DefaultDiskSizeMB)
 	}
 }
+
+func TestDryRunConfig(t *testing.T) {
+	cfg := createTestConfig(t)
+	cfg.SSHHostPort = 2222
+	cfg.CloudInit = &distro.CloudInitConfig{UserData: "#cloud-config\npassword: hunter2\n"}
+	m := &Manager{
+		cfg:    cfg,
+		assets: NewAssetManager(cfg.CacheDir, cfg.Provider, nil),
+		images: NewImageManager(cfg.DataDir),
+	}
+
+	vmCfg, err := m.DryRunConfig()
+	if err != nil {
+		t.Fatalf("DryRunConfig: %v", err)
+	}
+	if want := filepath.Join(cfg.DataDir, "test-disk.raw"); vmCfg.DiskPath != want {
+		t.Errorf("DiskPath = %q, want %q", vmCfg.DiskPath, want)
+	}
+	if len(vmCfg.ExtraDisks) != 1 || vmCfg.ExtraDisks[0].Path != filepath.Join(cfg.DataDir, "seed.iso") {
+		t.Errorf("ExtraDisks = %+v, want the cloud-init seed", vmCfg.ExtraDisks)
+	}
+	if len(vmCfg.PortForwards) != 1 || vmCfg.PortForwards[0].Host != 2222 {
+		t.Errorf("PortForwards = %+v, want the SSH forward", vmCfg.PortForwards)
+	}
+	if vmCfg.Cmdline == "" {
+		t.Error("Cmdline should come from the distro boot config")
+	}
+
+	// Nothing may be created on disk
+	for _, p := range []string{vmCfg.DiskPath, vmCfg.ExtraDisks[0].Path} {
+		if _, err := os.Stat(p); !os.IsNotExist(err) {
+			t.Errorf("%s was created by a dry run", p)
+		}
+	}
+
+	data, err := json.Marshal(m.Config())
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	if strings.Contains(string(data), "hunter2") {
+		t.Errorf("cloud-init user-data leaked into JSON: %s", data)
+	}
+	if !strings.Contains(string(data), `"cloud_init":true`) || !strings.Contains(string(data), `"id":"`+string(cfg.Provider.ID())+`"`) {
+		t.Errorf("unexpected JSON: %s", data)
+	}
+}
+
+func TestDryRunConfigKernelArgs(t *testing.T) {
+	cfg := createTestConfig(t)
+	m := &Manager{
+		cfg:    cfg,
+		assets: NewAssetManager(cfg.CacheDir, cfg.Provider, nil),
+		images: NewImageManager(cfg.DataDir),
+	}
+	base, err := m.DryRunConfig()
+	if err != nil {
+		t.Fatalf("DryRunConfig: %v", err)
+	}
+
+	m.cfg.KernelArgs = []string{"debug", "net.ifnames=0"}
+	vmCfg, err := m.DryRunConfig()
+	if err != nil {
+		t.Fatalf("DryRunConfig: %v", err)
+	}
+	if want := base.Cmdline + " debug net.ifnames=0"; vmCfg.Cmdline != want {
+		t.Errorf("Cmdline = %q, want %q", vmCfg.Cmdline, want)
+	}
+
+	data, err := json.Marshal(m.Config())
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	if !strings.Contains(string(data), `"kernel_args":["debug","net.ifnames=0"]`) {
+		t.Errorf("JSON missing kernel_args: %s", data)
+	}
+}
+
+// uefiProvider boots the wrapped provider through UEFI on every arch.
+type uefiProvider struct {
+	distro.Provider
+}
+
+func (p uefiProvider) BootConfig(arch distro.Arch) *distro.BootConfig {
+	cfg := p.Provider.BootConfig(arch)
+	cfg.UEFI = true
+	return cfg
+}
+
+func TestAssetManagerUEFI(t *testing.T) {
+	arch := distro.CurrentArch()
+	inner, err := distro.Get(distro.OpenSUSE)
+	if err != nil || !inner.SupportsArch(arch) {
+		t.Skip("openSUSE not available on this arch")
+	}
+	cacheDir := t.TempDir()
+	m := NewAssetManager(cacheDir, uefiProvider{inner}, nil)
+	subdir := filepath.Join(cacheDir, inner.CacheSubdir(arch))
+
+	// Kernel and initrd are cached; the converted disk is not
+	os.MkdirAll(subdir, 0755)
+	for _, name := range []string{"vmlinuz", "initramfs", "rootfs.qcow2"} {
+		os.WriteFile(filepath.Join(subdir, name), []byte("x"), 0644)
+	}
+	if exist, _ := m.AssetsExist(); exist {
+		t.Error("AssetsExist = true without the converted disk")
+	}
+
+	os.WriteFile(filepath.Join(subdir, "rootfs.raw"), []byte("x"), 0644)
+	if exist, _ := m.AssetsExist(); !exist {
+		t.Error("AssetsExist = false with all assets cached")
+	}
+
+	// The manager boots the converted image through UEFI
+	cfg := createTestConfig(t)
+	mgr := &Manager{cfg: cfg, assets: m, images: NewImageManager(cfg.DataDir)}
+	vmCfg, err := mgr.DryRunConfig()
+	if err != nil {
+		t.Fatalf("DryRunConfig: %v", err)
+	}
+	if !vmCfg.UEFI || vmCfg.Kernel != "" {
+		t.Errorf("UEFI = %v, Kernel = %q; want UEFI and no kernel", vmCfg.UEFI, vmCfg.Kernel)
+	}
+	if vmCfg.DiskPath != filepath.Join(cfg.DataDir, cfg.DiskName+".raw") {
+		t.Errorf("DiskPath = %q, want the VM's copy of the converted cloud image", vmCfg.DiskPath)
+	}
+}
+
+func TestOverlayCmdline(t *testing.T) {
+	got := overlayCmdline("console=hvc0 root=/dev/vda rw rootfstype=ext4")
+	want := "console=hvc0 root=/dev/vda rootfstype=ext4 ro overlayroot=device:dev=/dev/vdb,mkfs=1,recurse=0"
+	if got != want {
+		t.Errorf("overlayCmdline = %q, want %q", got, want)
+	}
+}
+
+func TestDryRunConfigOverlay(t *testing.T) {
+	cfg := createTestConfig(t)
+	cfg.Provider = distro.NewUbuntuProvider()
+	cfg.Overlay = true
+	cfg.CloudInit = &distro.CloudInitConfig{UserData: "#cloud-config\n"}
+	m := &Manager{
+		cfg:    cfg,
+		assets: NewAssetManager(cfg.CacheDir, cfg.Provider, nil),
+		images: NewImageManager(cfg.DataDir),
+	}
+
+	vmCfg, err := m.DryRunConfig()
+	if err != nil {
+		t.Fatalf("DryRunConfig: %v", err)
+	}
+	if !vmCfg.DiskReadOnly {
+		t.Error("root disk should be read-only")
+	}
+	// The overlay must come first to be /dev/vdb
+	if len(vmCfg.ExtraDisks) != 2 || vmCfg.ExtraDisks[0].Path != filepath.Join(cfg.DataDir, "overlay.raw") || vmCfg.ExtraDisks[0].ReadOnly {
+		t.Errorf("ExtraDisks = %+v, want the overlay disk then the seed", vmCfg.ExtraDisks)
+	}
+	if !strings.Contains(vmCfg.Cmdline, "overlayroot=device:dev=/dev/vdb") {
+		t.Errorf("Cmdline = %q, want an overlayroot argument", vmCfg.Cmdline)
+	}
+
+	m.cfg.Provider = uefiProvider{cfg.Provider}
+	m.assets = NewAssetManager(cfg.CacheDir, m.cfg.Provider, nil)
+	if _, err := m.DryRunConfig(); err == nil {
+		t.Error("expected error for an overlay boot through UEFI")
+	}
+
+	// Distros without the overlayroot hook would boot a read-only root
+	m.cfg.Provider = distro.NewAlpineProvider()
+	m.assets = NewAssetManager(cfg.CacheDir, m.cfg.Provider, nil)
+	if _, err := m.DryRunConfig(); err == nil || !strings.Contains(err.Error(), "overlayroot") {
+		t.Errorf("DryRunConfig for Alpine = %v, want an overlayroot error", err)
+	}
+}
+
+func TestDryRunConfigAdditionalDisks(t *testing.T) {
+	cfg := createTestConfig(t)
+	cfg.Provider = distro.NewUbuntuProvider()
+	cfg.Overlay = true
+	cfg.CloudInit = &distro.CloudInitConfig{UserData: "#cloud-config\n"}
+	cfg.AdditionalDisks = []DiskConfig{{Name: "data", SizeMB: 1024}, {Name: "media", SizeMB: 2048, ReadOnly: true}}
+	m := &Manager{
+		cfg:    cfg,
+		assets: NewAssetManager(cfg.CacheDir, cfg.Provider, nil),
+		images: NewImageManager(cfg.DataDir),
+	}
+
+	vmCfg, err := m.DryRunConfig()
+	if err != nil {
+		t.Fatalf("DryRunConfig: %v", err)
+	}
+	// The overlay keeps /dev/vdb and the seed stays last
+	want := []hypervisor.StorageDevice{
+		{Path: filepath.Join(cfg.DataDir, "overlay.raw")},
+		{Path: filepath.Join(cfg.DataDir, "data.raw")},
+		{Path: filepath.Join(cfg.DataDir, "media.raw"), ReadOnly: true},
+		{Path: filepath.Join(cfg.DataDir, "seed.iso"), ReadOnly: true},
+	}
+	if !reflect.DeepEqual(vmCfg.ExtraDisks, want) {
+		t.Errorf("ExtraDisks = %+v, want %+v", vmCfg.ExtraDisks, want)
+	}
+}
+
+// recordingDriver records the configuration the manager creates a VM with.
+type recordingDriver struct {
+	hypervisor.Driver
+	created *hypervisor.VMConfig
+}
+
+func (d *recordingDriver) Validate(ctx context.Context, cfg *hypervisor.VMConfig) error { return nil }
+
+func (d *recordingDriver) Create(ctx context.Context, cfg *hypervisor.VMConfig) error {
+	d.created = cfg
+	return nil
+}
+
+func TestColdPrepareSharesCachedAssets(t *testing.T) {
+	cacheDir := t.TempDir()
+	provider, err := distro.GetDefault()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Populate the shared cache as a previous download would have
+	cacheSubdir := filepath.Join(cacheDir, provider.CacheSubdir(distro.CurrentArch()))
+	if err := os.MkdirAll(cacheSubdir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"vmlinuz", "initramfs", "rootfs.tar.gz"} {
+		if err := os.WriteFile(filepath.Join(cacheSubdir, name), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Two VMs, such as a VM and its clone, each with their own data dir
+	var kernels []string
+	for i := 0; i < 2; i++ {
+		cfg := createTestConfig(t)
+		cfg.CacheDir = cacheDir
+		driver := &recordingDriver{}
+		m := &Manager{
+			cfg:    cfg,
+			assets: NewAssetManager(cfg.CacheDir, cfg.Provider, nil),
+			images: NewImageManager(cfg.DataDir),
+			driver: driver,
+		}
+		if err := m.coldPrepare(context.Background()); err != nil {
+			t.Fatalf("coldPrepare: %v", err)
+		}
+
+		kernel := driver.created.Kernel
+		if filepath.Dir(kernel) != cacheSubdir {
+			t.Errorf("kernel %s is not in the shared cache %s", kernel, cacheSubdir)
+		}
+		if filepath.Dir(driver.created.Initrd) != cacheSubdir {
+			t.Errorf("initrd %s is not in the shared cache %s", driver.created.Initrd, cacheSubdir)
+		}
+		kernels = append(kernels, kernel)
+
+		// Only the VM's own disk may be written to its data dir
+		entries, err := os.ReadDir(cfg.DataDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range entries {
+			if e.Name() != cfg.DiskName+".raw" {
+				t.Errorf("coldPrepare wrote %s to the data dir", e.Name())
+			}
+		}
+	}
+	if kernels[0] != kernels[1] {
+		t.Errorf("VMs boot different kernel files: %s and %s", kernels[0], kernels[1])
+	}
+}
+
+// servedProvider downloads the wrapped provider's kernel, initramfs and
+// rootfs from a test server.
+type servedProvider struct {
+	distro.Provider
+	url string
+}
+
+func (p servedProvider) AssetURLs(arch distro.Arch) (*distro.AssetURLs, error) {
+	return &distro.AssetURLs{
+		Kernel: p.url + "/vmlinuz",
+		Initrd: p.url + "/initramfs",
+		Rootfs: p.url + "/rootfs.tar.gz",
+	}, nil
+}
+
+func (p servedProvider) KernelLocator() *distro.KernelLocator { return nil }
+
+func TestWarmPrepareRefetchesCorruptAssetsAfterCrash(t *testing.T) {
+	var mu sync.Mutex
+	downloads := map[string]int{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		downloads[r.URL.Path]++
+		mu.Unlock()
+		w.Write([]byte("contents of " + r.URL.Path))
+	}))
+	defer srv.Close()
+
+	cfg := createTestConfig(t)
+	cfg.Provider = servedProvider{Provider: cfg.Provider, url: srv.URL}
+	driver := &recordingDriver{}
+	m := &Manager{
+		cfg:       cfg,
+		assets:    NewAssetManager(cfg.CacheDir, cfg.Provider, nil),
+		images:    NewImageManager(cfg.DataDir),
+		driver:    driver,
+		stateFile: NewStateFile(cfg.DataDir),
+	}
+	if err := m.coldPrepare(context.Background()); err != nil {
+		t.Fatalf("coldPrepare: %v", err)
+	}
+	kernel := driver.created.Kernel
+
+	// The VM boots and crashes, and the kernel is damaged with it
+	if err := m.stateFile.RecordBoot(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(kernel, []byte("garbage"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.warmPrepare(context.Background()); err != nil {
+		t.Fatalf("warmPrepare: %v", err)
+	}
+	if downloads["/vmlinuz"] != 2 {
+		t.Errorf("kernel downloaded %d times, want it fetched again", downloads["/vmlinuz"])
+	}
+	if downloads["/initramfs"] != 1 {
+		t.Errorf("intact initramfs downloaded %d times", downloads["/initramfs"])
+	}
+	data, err := os.ReadFile(driver.created.Kernel)
+	if err != nil || string(data) != "contents of /vmlinuz" {
+		t.Errorf("kernel after warmPrepare = %q, %v", data, err)
+	}
+}
+
+// stubbornDriver accepts stop requests but its guest never shuts down.
+type stubbornDriver struct {
+	hypervisor.Driver
+	killed chan struct{}
+}
+
+func (d *stubbornDriver) Stop(ctx context.Context) error { return nil }
+
+func (d *stubbornDriver) Kill(ctx context.Context) error {
+	close(d.killed)
+	return nil
+}
+
+func TestStopKillsAfterTimeout(t *testing.T) {
+	driver := &stubbornDriver{killed: make(chan struct{})}
+	cfg := createTestConfig(t)
+	cfg.StopTimeout = 50 * time.Millisecond
+	m := &Manager{cfg: cfg, driver: driver, state: StateRunning, done: make(chan struct{})}
+
+	start := time.Now()
+	if err := m.Stop(context.Background()); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	select {
+	case <-driver.killed:
+		if elapsed := time.Since(start); elapsed < cfg.StopTimeout {
+			t.Errorf("killed after %s, before the %s timeout", elapsed, cfg.StopTimeout)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("VM was not killed after the stop timeout")
+	}
+}
+
+func TestStopDoesNotKillExitedVM(t *testing.T) {
+	driver := &stubbornDriver{killed: make(chan struct{})}
+	cfg := createTestConfig(t)
+	cfg.StopTimeout = 50 * time.Millisecond
+	done := make(chan struct{})
+	m := &Manager{cfg: cfg, driver: driver, state: StateRunning, done: done}
+
+	if err := m.Stop(context.Background()); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	close(done) // The guest shuts down in time
+
+	select {
+	case <-driver.killed:
+		t.Error("VM that shut down in time was killed")
+	case <-time.After(4 * cfg.StopTimeout):
+	}
+}
@@ -5,16 +5,87 @@ import (
 	"path/filepath"
 	"sort"
 	"strings"
+
+	"github.com/javanstorm/vmterminal/pkg/hypervisor"
+)
+
+// MountProtocol is the filesystem a guest mounts shared directories with.
+type MountProtocol string
+
+const (
+	// MountVirtioFS mounts shares with virtio-fs.
+	MountVirtioFS MountProtocol = "virtiofs"
+	// Mount9P mounts shares with the kernel's 9p filesystem over virtio.
+	Mount9P MountProtocol = "9p"
 )
 
-// MountHelper generates shell commands for mounting virtio-fs shares inside the guest.
+// plan9Msize is the msize 9P mounts ask for, the most the host accepts.
+const plan9Msize = 512 * 1024
+
+// plan9Modules are the guest kernel modules 9P mounts need.
+var plan9Modules = []string{"9pnet_virtio", "9p"}
+
+// ParseMountProtocol parses a protocol name as given to 'vmterminal mount
+// --type'.
+func ParseMountProtocol(s string) (MountProtocol, error) {
+	switch p := MountProtocol(s); p {
+	case MountVirtioFS, Mount9P:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown mount type %q (want %s or %s)", s, MountVirtioFS, Mount9P)
+}
+
+// MountProtocolFor returns the protocol a driver with caps shares
+// directories over: virtio-fs where it is available, otherwise 9P. ok is
+// false if the driver cannot share directories.
+func MountProtocolFor(caps hypervisor.Capabilities) (p MountProtocol, ok bool) {
+	switch {
+	case caps.SharedDirs:
+		return MountVirtioFS, true
+	case caps.Sharing9P:
+		return Mount9P, true
+	}
+	return "", false
+}
+
+// withPlan9Modules adds the 9P modules to the modules loaded at boot:
+// Alpine's initramfs loads those in modules=, other distros those in
+// systemd's modules-load=.
+func withPlan9Modules(cmdline string) string {
+	mods := strings.Join(plan9Modules, ",")
+	fields := strings.Fields(cmdline)
+	for i, f := range fields {
+		if strings.HasPrefix(f, "modules=") {
+			fields[i] = f + "," + mods
+			return strings.Join(fields, " ")
+		}
+	}
+	return kernelCmdline(cmdline, []string{"modules-load=" + mods})
+}
+
+// MountHelper generates shell commands for mounting shares inside the guest.
 type MountHelper struct {
-	shares map[string]string // tag → host path
+	shares   map[string]string // tag → host path
+	protocol MountProtocol
 }
 
-// NewMountHelper creates a MountHelper with the given shares.
+// NewMountHelper creates a MountHelper with the given shares, mounted with
+// virtio-fs.
 func NewMountHelper(shares map[string]string) *MountHelper {
-	return &MountHelper{shares: shares}
+	return &MountHelper{shares: shares, protocol: MountVirtioFS}
+}
+
+// SetProtocol makes the generated commands mount with p.
+func (h *MountHelper) SetProtocol(p MountProtocol) {
+	h.protocol = p
+}
+
+// MountArgs returns the mount type and options for the helper's protocol.
+func (h *MountHelper) MountArgs() string {
+	if h.protocol == Mount9P {
+		return fmt.Sprintf("-t 9p -o trans=virtio,version=9p2000.L,msize=%d", plan9Msize)
+	}
+	return "-t virtiofs"
 }
 
 // GenerateMountScript returns shell commands to mount all shares.
@@ -25,21 +96,7 @@ func (h *MountHelper) GenerateMountScript(mountBase string) string {
 		return ""
 	}
 
-	var lines []string
-
-	// Header with virtiofs availability check
-	lines = append(lines,
-		"#!/bin/sh",
-		"# Mount virtio-fs shared directories",
-		"# Generated by vmterminal",
-		"",
-		"# Check if virtiofs is available",
-		"if ! grep -q virtiofs /proc/filesystems 2>/dev/null; then",
-		"    echo \"Error: virtiofs not available in this kernel\" >&2",
-		"    exit 1",
-		"fi",
-		"",
-	)
+	lines := h.mountScriptHeader()
 
 	// Sort tags for deterministic output
 	tags := make([]string, 0, len(h.shares))
@@ -58,12 +115,46 @@ func (h *MountHelper) GenerateMountScript(mountBase string) string {
 	return strings.Join(lines, "\n")
 }
 
+// mountScriptHeader returns the opening lines of a mount script, which
+// check that the kernel supports the helper's protocol.
+func (h *MountHelper) mountScriptHeader() []string {
+	if h.protocol == Mount9P {
+		return []string{
+			"#!/bin/sh",
+			"# Mount 9p shared directories",
+			"# Generated by vmterminal",
+			"",
+			"# Check if 9p is available, loading it if needed",
+			"if ! grep -qw 9p /proc/filesystems 2>/dev/null; then",
+			"    modprobe -a " + strings.Join(plan9Modules, " ") + " 2>/dev/null",
+			"fi",
+			"if ! grep -qw 9p /proc/filesystems 2>/dev/null; then",
+			"    echo \"Error: 9p not available in this kernel\" >&2",
+			"    exit 1",
+			"fi",
+			"",
+		}
+	}
+	return []string{
+		"#!/bin/sh",
+		"# Mount virtio-fs shared directories",
+		"# Generated by vmterminal",
+		"",
+		"# Check if virtiofs is available",
+		"if ! grep -q virtiofs /proc/filesystems 2>/dev/null; then",
+		"    echo \"Error: virtiofs not available in this kernel\" >&2",
+		"    exit 1",
+		"fi",
+		"",
+	}
+}
+
 // GenerateMountCommand returns the command for mounting a single share.
-// tag is the virtio-fs mount tag, mountpoint is where to mount in the guest.
+// tag is the mount tag, mountpoint is where to mount in the guest.
 func (h *MountHelper) GenerateMountCommand(tag, mountpoint string) string {
 	// Quote paths that contain spaces or special characters
 	quotedMountpoint := quotePath(mountpoint)
-	return fmt.Sprintf("mkdir -p %s && mount -t virtiofs %s %s", quotedMountpoint, tag, quotedMountpoint)
+	return fmt.Sprintf("mkdir -p %s && mount %s %s %s", quotedMountpoint, h.MountArgs(), tag, quotedMountpoint)
 }
 
 // generateMountLines returns the shell lines for mounting a single share.
@@ -75,7 +166,7 @@ func (h *MountHelper) generateMountLines(tag, mountpoint string) []string {
 		fmt.Sprintf("    echo \"%s already mounted\"", tag),
 		"else",
 		fmt.Sprintf("    mkdir -p %s", quotedMountpoint),
-		fmt.Sprintf("    if mount -t virtiofs %s %s; then", tag, quotedMountpoint),
+		fmt.Sprintf("    if mount %s %s %s; then", h.MountArgs(), tag, quotedMountpoint),
 		fmt.Sprintf("        echo \"Mounted %s at %s\"", tag, mountpoint),
 		"    else",
 		fmt.Sprintf("        echo \"Failed to mount %s\" >&2", tag),
@@ -3,6 +3,8 @@ package vm
 import (
 	"strings"
 	"testing"
+
+	"github.com/javanstorm/vmterminal/pkg/hypervisor"
 )
 
 func TestMountHelperSingleShare(t *testing.T) {
@@ -201,3 +203,119 @@ func TestQuotePath(t *testing.T) {
 		})
 	}
 }
+
+func TestUnionMountScript(t *testing.T) {
+	helper := NewMountHelper(map[string]string{
+		"src":     "/Users/test/src",
+		"src/web": "/Users/test/web",
+		"data":    "/Users/test/data",
+	})
+
+	script := helper.GenerateUnionMountScript("/mnt/host")
+
+	if !strings.Contains(script, "mkdir -p /mnt/host/data /mnt/host/src\n") {
+		t.Errorf("Script missing mount point hierarchy:\n%s", script)
+	}
+	for _, want := range []string{
+		"mount -t virtiofs data /mnt/host/data",
+		"mount -t virtiofs src /mnt/host/src",
+		"mount -t virtiofs src/web /mnt/host/src/web",
+		"# Warning: /mnt/host/src/web is inside the share mounted at /mnt/host/src;",
+	} {
+		if !strings.Contains(script, want) {
+			t.Errorf("Script missing %q:\n%s", want, script)
+		}
+	}
+	if strings.Index(script, "virtiofs src /mnt/host/src") > strings.Index(script, "virtiofs src/web") {
+		t.Error("Nested share mounted before its parent")
+	}
+	if strings.Contains(script, "overlay") {
+		t.Error("Script uses an overlay without conflicting shares")
+	}
+}
+
+func TestUnionMountScriptConflict(t *testing.T) {
+	helper := NewMountHelper(map[string]string{
+		"src":   "/Users/test/src",
+		"./src": "/Users/test/other",
+	})
+
+	script := helper.GenerateUnionMountScript("/mnt/host")
+
+	for _, want := range []string{
+		"# Warning: shares ./src, src all map to /mnt/host/src;",
+		"mount -t virtiofs ./src /mnt/host/.shares/._src",
+		"mount -t virtiofs src /mnt/host/.shares/src",
+		"mount -t overlay overlay -o lowerdir=/mnt/host/.shares/._src:/mnt/host/.shares/src /mnt/host/src",
+	} {
+		if !strings.Contains(script, want) {
+			t.Errorf("Script missing %q:\n%s", want, script)
+		}
+	}
+}
+
+func TestUnionMountScriptEmpty(t *testing.T) {
+	if script := NewMountHelper(nil).GenerateUnionMountScript("/mnt/host"); script != "" {
+		t.Errorf("GenerateUnionMountScript() with no shares = %q, want empty string", script)
+	}
+}
+
+func TestMountHelper9P(t *testing.T) {
+	helper := NewMountHelper(map[string]string{"home": "/home/test"})
+	helper.SetProtocol(Mount9P)
+
+	cmd := helper.GenerateMountCommand("home", "/mnt/host/home")
+	expected := "mkdir -p /mnt/host/home && mount -t 9p -o trans=virtio,version=9p2000.L,msize=524288 home /mnt/host/home"
+	if cmd != expected {
+		t.Errorf("GenerateMountCommand() = %q, want %q", cmd, expected)
+	}
+
+	script := helper.GenerateMountScript("/mnt/host")
+	if strings.Contains(script, "virtiofs") {
+		t.Error("9p script should not mention virtiofs")
+	}
+	if !strings.Contains(script, "modprobe -a 9pnet_virtio 9p") {
+		t.Error("9p script should load the 9p modules")
+	}
+}
+
+func TestMountProtocolFor(t *testing.T) {
+	tests := []struct {
+		caps hypervisor.Capabilities
+		want MountProtocol
+		ok   bool
+	}{
+		{hypervisor.Capabilities{SharedDirs: true, Sharing9P: true}, MountVirtioFS, true},
+		{hypervisor.Capabilities{Sharing9P: true}, Mount9P, true},
+		{hypervisor.Capabilities{}, "", false},
+	}
+	for _, tt := range tests {
+		if got, ok := MountProtocolFor(tt.caps); got != tt.want || ok != tt.ok {
+			t.Errorf("MountProtocolFor(%+v) = %q, %v; want %q, %v", tt.caps, got, ok, tt.want, tt.ok)
+		}
+	}
+
+	if _, err := ParseMountProtocol("nfs"); err == nil {
+		t.Error("ParseMountProtocol(nfs) should fail")
+	}
+}
+
+func TestWithPlan9Modules(t *testing.T) {
+	tests := []struct {
+		cmdline, want string
+	}{
+		{
+			"console=hvc0 root=/dev/vda modules=virtio_blk",
+			"console=hvc0 root=/dev/vda modules=virtio_blk,9pnet_virtio,9p",
+		},
+		{
+			"console=hvc0 root=/dev/vda",
+			"console=hvc0 root=/dev/vda modules-load=9pnet_virtio,9p",
+		},
+	}
+	for _, tt := range tests {
+		if got := withPlan9Modules(tt.cmdline); got != tt.want {
+			t.Errorf("withPlan9Modules(%q) = %q, want %q", tt.cmdline, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package vm
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// unionStagingDir is the directory below the base mount where
+// GenerateUnionMountScript mounts shares that are combined by an overlay.
+const unionStagingDir = ".shares"
+
+// unionMountPoint returns where tag is mounted below baseMount: the tag
+// read as a relative path, so "src/web" nests inside "src".
+func unionMountPoint(baseMount, tag string) string {
+	name := strings.TrimPrefix(path.Clean("/"+tag), "/")
+	if name == "" {
+		name = "_"
+	}
+	return path.Join(baseMount, name)
+}
+
+// stagingName returns a directory name for tag that is safe in an overlay
+// lowerdir list, which separates paths with ':' and options with ','.
+func stagingName(tag string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.' {
+			return r
+		}
+		return '_'
+	}, tag)
+}
+
+// GenerateUnionMountScript returns a shell script that mounts every share
+// in a single tree under baseMount (e.g. "/mnt/host"), each at the path
+// its tag names, creating the directories first. Shares whose tags name
+// the same path are mounted under baseMount/.shares and combined there by
+// a read-only overlay, in tag order. The script carries a warning comment
+// for every such conflict, and for shares mounted inside another share.
+func (h *MountHelper) GenerateUnionMountScript(baseMount string) string {
+	if len(h.shares) == 0 {
+		return ""
+	}
+
+	// Group the tags by the mount point they map to
+	groups := make(map[string][]string)
+	for _, tag := range h.Tags() {
+		mp := unionMountPoint(baseMount, tag)
+		groups[mp] = append(groups[mp], tag)
+	}
+	mountpoints := make([]string, 0, len(groups))
+	for mp := range groups {
+		mountpoints = append(mountpoints, mp)
+	}
+	sort.Strings(mountpoints) // Parents before the mounts nested in them
+
+	lines := h.mountScriptHeader()
+
+	// Warn about conflicts before anything is mounted
+	var warnings []string
+	for _, mp := range mountpoints {
+		if tags := groups[mp]; len(tags) > 1 {
+			warnings = append(warnings,
+				fmt.Sprintf("# Warning: shares %s all map to %s; they are combined in a", strings.Join(tags, ", "), mp),
+				fmt.Sprintf("# read-only overlay where files in %s hide those in the others.", tags[0]))
+		}
+		if parent := enclosingMount(mp, mountpoints); parent != "" {
+			warnings = append(warnings,
+				fmt.Sprintf("# Warning: %s is inside the share mounted at %s;", mp, parent),
+				"# its mount point is created in that share's host directory.")
+		}
+	}
+	if len(warnings) > 0 {
+		lines = append(lines, warnings...)
+		lines = append(lines, "")
+	}
+
+	// Create the hierarchy outside of any share up front
+	var dirs []string
+	for _, mp := range mountpoints {
+		if enclosingMount(mp, mountpoints) == "" {
+			dirs = append(dirs, quotePath(mp))
+		}
+	}
+	lines = append(lines,
+		"# Create the mount point hierarchy",
+		"mkdir -p "+strings.Join(dirs, " "),
+		"",
+	)
+
+	used := make(map[string]bool)
+	for _, mp := range mountpoints {
+		tags := groups[mp]
+		if len(tags) == 1 {
+			lines = append(lines, h.generateMountLines(tags[0], mp)...)
+			lines = append(lines, "")
+			continue
+		}
+
+		var lower []string
+		for _, tag := range tags {
+			name := stagingName(tag)
+			for i := 2; used[name]; i++ {
+				name = fmt.Sprintf("%s-%d", stagingName(tag), i)
+			}
+			used[name] = true
+			staging := path.Join(baseMount, unionStagingDir, name)
+			lower = append(lower, staging)
+			lines = append(lines, h.generateMountLines(tag, staging)...)
+			lines = append(lines, "")
+		}
+		lines = append(lines, unionMountLines(tags, lower, mp)...)
+		lines = append(lines, "")
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// enclosingMount returns the mount point in mountpoints that mp is nested
+// inside, if any.
+func enclosingMount(mp string, mountpoints []string) string {
+	parent := ""
+	for _, other := range mountpoints {
+		if other != mp && strings.HasPrefix(mp, other+"/") && len(other) > len(parent) {
+			parent = other
+		}
+	}
+	return parent
+}
+
+// unionMountLines returns the shell lines that combine the shares mounted
+// at lower into a read-only overlay at mountpoint.
+func unionMountLines(tags, lower []string, mountpoint string) []string {
+	quotedMountpoint := quotePath(mountpoint)
+	names := strings.Join(tags, ", ")
+	return []string{
+		fmt.Sprintf("# Union of %s", names),
+		fmt.Sprintf("if mountpoint -q %s 2>/dev/null; then", quotedMountpoint),
+		fmt.Sprintf("    echo \"%s already mounted\"", mountpoint),
+		"else",
+		"    modprobe overlay 2>/dev/null",
+		fmt.Sprintf("    mkdir -p %s", quotedMountpoint),
+		fmt.Sprintf("    if mount -t overlay overlay -o %s %s; then", quotePath("lowerdir="+strings.Join(lower, ":")), quotedMountpoint),
+		fmt.Sprintf("        echo \"Mounted %s at %s\"", names, mountpoint),
+		"    else",
+		fmt.Sprintf("        echo \"Failed to mount the union of %s\" >&2", names),
+		"    fi",
+		"fi",
+	}
+}
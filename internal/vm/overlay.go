@@ -0,0 +1,55 @@
+package vm
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/javanstorm/vmterminal/pkg/hypervisor"
+)
+
+const (
+	// OverlayDiskName is the disk, in the VM's data directory, that takes
+	// the writes of an overlay boot.
+	OverlayDiskName = "overlay"
+
+	// DefaultOverlaySizeMB is the default size of the overlay disk (sparse).
+	DefaultOverlaySizeMB = 2 * 1024
+
+	// overlayDevice is the overlay disk in the guest: the first disk after
+	// the root disk.
+	overlayDevice = "/dev/vdb"
+)
+
+// overlayCmdline turns a kernel command line that mounts the root disk
+// read-write into one for an overlay boot: the root disk (the lower layer)
+// is mounted read-only and the initramfs's overlayroot hook mounts an
+// overlay over it with a fresh filesystem on the overlay disk as the upper
+// layer.
+func overlayCmdline(cmdline string) string {
+	fields := strings.Fields(cmdline)
+	out := make([]string, 0, len(fields)+2)
+	for _, f := range fields {
+		if f == "rw" || f == "ro" || strings.HasPrefix(f, "overlayroot=") {
+			continue
+		}
+		out = append(out, f)
+	}
+	return strings.Join(append(out, "ro", "overlayroot=device:dev="+overlayDevice+",mkfs=1,recurse=0"), " ")
+}
+
+// applyOverlay changes vmCfg for an overlay boot: the root disk is attached
+// read-only and the overlay disk ahead of any other extra disk, so it is
+// the guest's overlayDevice.
+func (m *Manager) applyOverlay(vmCfg *hypervisor.VMConfig) error {
+	if vmCfg.UEFI {
+		return fmt.Errorf("overlay boot needs a distro booted with its own kernel; %s boots through UEFI", m.cfg.Provider.Name())
+	}
+	if !m.assets.BootConfig().Overlayroot {
+		return fmt.Errorf("overlay boot needs an initramfs with the overlayroot hook, which %s does not ship", m.cfg.Provider.Name())
+	}
+	vmCfg.DiskReadOnly = true
+	overlay := hypervisor.StorageDevice{Path: m.images.DiskPath(OverlayDiskName)}
+	vmCfg.ExtraDisks = append([]hypervisor.StorageDevice{overlay}, vmCfg.ExtraDisks...)
+	vmCfg.Cmdline = overlayCmdline(vmCfg.Cmdline)
+	return nil
+}
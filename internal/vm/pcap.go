@@ -0,0 +1,270 @@
+package vm
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/javanstorm/vmterminal/pkg/hypervisor"
+	"golang.org/x/net/bpf"
+)
+
+// pcapSnapLen is the largest frame a capture records in full. Longer
+// frames are cut short; the record keeps their original length.
+const pcapSnapLen = 65535
+
+// pcap file format constants (microsecond timestamps, Ethernet frames).
+const (
+	pcapMagic        = 0xa1b2c3d4
+	pcapVersionMajor = 2
+	pcapVersionMinor = 4
+	pcapLinkEthernet = 1
+)
+
+// packetSource reads Ethernet frames from a host network interface.
+type packetSource interface {
+	// ReadPackets blocks until frames arrive and passes each to fn, with
+	// the frame's length on the wire and when it was received.
+	ReadPackets(fn func(frame []byte, origLen int, ts time.Time) error) error
+	Close() error
+}
+
+// pcapWriter writes frames in the classic libpcap file format read by
+// tcpdump and Wireshark.
+type pcapWriter struct {
+	w   io.Writer
+	hdr [16]byte
+}
+
+// newPcapWriter writes the pcap file header to w.
+func newPcapWriter(w io.Writer) (*pcapWriter, error) {
+	var hdr [24]byte
+	binary.LittleEndian.PutUint32(hdr[0:], pcapMagic)
+	binary.LittleEndian.PutUint16(hdr[4:], pcapVersionMajor)
+	binary.LittleEndian.PutUint16(hdr[6:], pcapVersionMinor)
+	// hdr[8:16] is the zero time zone offset and timestamp accuracy
+	binary.LittleEndian.PutUint32(hdr[16:], pcapSnapLen)
+	binary.LittleEndian.PutUint32(hdr[20:], pcapLinkEthernet)
+	if _, err := w.Write(hdr[:]); err != nil {
+		return nil, err
+	}
+	return &pcapWriter{w: w}, nil
+}
+
+// WritePacket writes one frame captured at ts. origLen is the frame's
+// length on the wire, which may exceed len(frame).
+func (p *pcapWriter) WritePacket(ts time.Time, frame []byte, origLen int) error {
+	if len(frame) > pcapSnapLen {
+		frame = frame[:pcapSnapLen]
+	}
+	binary.LittleEndian.PutUint32(p.hdr[0:], uint32(ts.Unix()))
+	binary.LittleEndian.PutUint32(p.hdr[4:], uint32(ts.Nanosecond()/1000))
+	binary.LittleEndian.PutUint32(p.hdr[8:], uint32(len(frame)))
+	binary.LittleEndian.PutUint32(p.hdr[12:], uint32(max(origLen, len(frame))))
+	if _, err := p.w.Write(p.hdr[:]); err != nil {
+		return err
+	}
+	_, err := p.w.Write(frame)
+	return err
+}
+
+// Capture is a packet capture running in the background.
+type Capture struct {
+	src     packetSource
+	file    *os.File
+	buf     *bufio.Writer
+	done    chan struct{}
+	packets atomic.Int64
+	err     error // First read or write error, set before done is closed
+	once    sync.Once
+}
+
+// CaptureInterface starts writing the frames seen on the host network
+// interface iface to a pcap file at outputPath: every frame, or if mac is
+// not empty only those sent to or from mac. Capturing needs root (or
+// CAP_NET_RAW on Linux, access to /dev/bpf* on macOS).
+func CaptureInterface(iface, mac, outputPath string) (*Capture, error) {
+	var filter []bpf.RawInstruction
+	if mac != "" {
+		hw, err := net.ParseMAC(mac)
+		if err != nil || len(hw) != 6 {
+			return nil, fmt.Errorf("invalid MAC address %q", mac)
+		}
+		if filter, err = macFilter(hw); err != nil {
+			return nil, err
+		}
+	}
+	src, err := openPacketSource(iface, filter)
+	if err != nil {
+		return nil, err
+	}
+	return startCapture(src, outputPath)
+}
+
+// macFilter returns a BPF program that keeps the Ethernet frames whose
+// destination or source is mac, which must be 6 bytes long.
+func macFilter(mac net.HardwareAddr) ([]bpf.RawInstruction, error) {
+	hi := binary.BigEndian.Uint32(mac[0:4])
+	lo := uint32(binary.BigEndian.Uint16(mac[4:6]))
+	return bpf.Assemble([]bpf.Instruction{
+		// Destination address
+		bpf.LoadAbsolute{Off: 0, Size: 4},
+		bpf.JumpIf{Cond: bpf.JumpEqual, Val: hi, SkipFalse: 2},
+		bpf.LoadAbsolute{Off: 4, Size: 2},
+		bpf.JumpIf{Cond: bpf.JumpEqual, Val: lo, SkipTrue: 4},
+		// Source address
+		bpf.LoadAbsolute{Off: 6, Size: 4},
+		bpf.JumpIf{Cond: bpf.JumpEqual, Val: hi, SkipFalse: 3},
+		bpf.LoadAbsolute{Off: 10, Size: 2},
+		bpf.JumpIf{Cond: bpf.JumpEqual, Val: lo, SkipFalse: 1},
+		bpf.RetConstant{Val: pcapSnapLen},
+		bpf.RetConstant{Val: 0},
+	})
+}
+
+// startCapture writes the frames read from src to a new pcap file at
+// outputPath in the background.
+func startCapture(src packetSource, outputPath string) (*Capture, error) {
+	f, err := os.Create(outputPath)
+	if err != nil {
+		src.Close()
+		return nil, fmt.Errorf("create capture file: %w", err)
+	}
+	buf := bufio.NewWriter(f)
+	pw, err := newPcapWriter(buf)
+	if err != nil {
+		src.Close()
+		f.Close()
+		return nil, fmt.Errorf("write capture file: %w", err)
+	}
+
+	c := &Capture{src: src, file: f, buf: buf, done: make(chan struct{})}
+	go func() {
+		defer close(c.done)
+		for {
+			err := src.ReadPackets(func(frame []byte, origLen int, ts time.Time) error {
+				c.packets.Add(1)
+				return pw.WritePacket(ts, frame, origLen)
+			})
+			if err != nil {
+				if !errors.Is(err, os.ErrClosed) {
+					c.err = err
+				}
+				return
+			}
+		}
+	}()
+	return c, nil
+}
+
+// Packets returns the number of frames captured so far.
+func (c *Capture) Packets() int64 {
+	return c.packets.Load()
+}
+
+// Stop ends the capture and closes the pcap file. It returns the number
+// of frames written and the error that ended the capture early, if any.
+// Calling Stop again returns the same result.
+func (c *Capture) Stop() (int64, error) {
+	c.once.Do(func() {
+		c.src.Close()
+		<-c.done
+		if err := c.buf.Flush(); err != nil && c.err == nil {
+			c.err = err
+		}
+		if err := c.file.Close(); err != nil && c.err == nil {
+			c.err = err
+		}
+	})
+	return c.Packets(), c.err
+}
+
+// HostInterface returns the host network interface that carries the
+// traffic of the VM's NIC number nicIndex. tapDevice is the tap interface
+// the VM was started with, if any. When the interface also carries other
+// traffic, filterMAC is the NIC's MAC, which the capture should be limited
+// to; it is empty if the interface is the VM's alone or the MAC is unknown.
+func HostInterface(nics []hypervisor.NetworkInterface, nicIndex int, tapDevice string) (iface, filterMAC string, err error) {
+	if nicIndex < 0 || nicIndex >= len(nics) {
+		if len(nics) == 0 {
+			return "", "", fmt.Errorf("the VM has no network interfaces")
+		}
+		return "", "", fmt.Errorf("NIC %d does not exist; the VM has %d network interfaces", nicIndex, len(nics))
+	}
+	nic := nics[nicIndex]
+	iface, shared, err := hostInterface(nic, nicIndex, tapDevice)
+	if err != nil || !shared {
+		return iface, "", err
+	}
+	return iface, nic.MAC, nil
+}
+
+// natBridgeAddrs is an interface and its IPv4 networks, as seen by
+// pickNATBridge.
+type natBridgeAddrs struct {
+	Name string
+	Nets []*net.IPNet
+}
+
+// pickNATBridge returns the bridge vmnet created for its shared (NAT)
+// network: the bridge whose network holds leased, the address the NAT's
+// DHCP server gave the VM, or else the only bridge with an IPv4 address.
+// vmnet numbers its bridges from bridge100 in the order networks come up.
+func pickNATBridge(ifaces []natBridgeAddrs, leased net.IP) (string, error) {
+	var bridges []string
+	for _, ifi := range ifaces {
+		if !strings.HasPrefix(ifi.Name, "bridge") || len(ifi.Nets) == 0 {
+			continue
+		}
+		for _, n := range ifi.Nets {
+			if leased != nil && n.Contains(leased) {
+				return ifi.Name, nil
+			}
+		}
+		bridges = append(bridges, ifi.Name)
+	}
+	switch len(bridges) {
+	case 0:
+		return "", fmt.Errorf("no vmnet NAT bridge found; is the VM running with NAT networking?")
+	case 1:
+		return bridges[0], nil
+	default:
+		return "", fmt.Errorf("several vmnet bridges (%s) and no DHCP lease to tell them apart; use --interface", strings.Join(bridges, ", "))
+	}
+}
+
+// StartCapture writes the traffic of the VM's NIC number nicIndex to a
+// pcap file at outputPath until the returned stop function is called.
+func (m *Manager) StartCapture(nicIndex int, outputPath string) (stop func(), err error) {
+	vmCfg := &hypervisor.VMConfig{
+		EnableNetwork: m.cfg.EnableNetwork,
+		MACAddress:    m.cfg.MACAddress,
+		Networks:      m.cfg.Networks,
+	}
+	// The first NIC's MAC may have been generated by the driver
+	nics := append([]hypervisor.NetworkInterface(nil), vmCfg.NetworkInterfaces()...)
+	if len(nics) > 0 && nics[0].MAC == "" {
+		nics[0].MAC = m.MACAddress()
+	}
+	iface, mac, err := HostInterface(nics, nicIndex, m.cfg.TapDevice)
+	if err != nil {
+		return nil, err
+	}
+	c, err := CaptureInterface(iface, mac, outputPath)
+	if err != nil {
+		return nil, err
+	}
+	return func() {
+		if _, err := c.Stop(); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: packet capture: %v\n", err)
+		}
+	}, nil
+}
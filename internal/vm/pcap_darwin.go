@@ -0,0 +1,188 @@
+package vm
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"github.com/javanstorm/vmterminal/pkg/hypervisor"
+	"golang.org/x/net/bpf"
+	"golang.org/x/sys/unix"
+)
+
+// bpfBufferSize is the read buffer requested from /dev/bpf.
+const bpfBufferSize = 1 << 20
+
+// hostInterface returns the interface the VM's NIC is attached to: the
+// vmnet bridge for NAT, or the bridged host interface. Both also carry
+// the traffic of other VMs or of the host.
+func hostInterface(nic hypervisor.NetworkInterface, nicIndex int, tapDevice string) (iface string, shared bool, err error) {
+	switch nic.Mode {
+	case hypervisor.NetworkNAT, "":
+		iface, err = natBridge(nic.MAC)
+		return iface, true, err
+	case hypervisor.NetworkBridge:
+		if nic.Interface == "" {
+			return "", false, fmt.Errorf("NIC %d is bridged without a host interface", nicIndex)
+		}
+		return nic.Interface, true, nil
+	default:
+		return "", false, fmt.Errorf("NIC %d: cannot capture %s networking", nicIndex, nic.Mode)
+	}
+}
+
+// natBridge returns the vmnet bridge of the NAT network the NIC with the
+// hardware address mac is on.
+func natBridge(mac string) (string, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return "", fmt.Errorf("list interfaces: %w", err)
+	}
+	var candidates []natBridgeAddrs
+	for _, ifi := range ifaces {
+		if ifi.Flags&net.FlagUp == 0 {
+			continue
+		}
+		addrs, err := ifi.Addrs()
+		if err != nil {
+			continue
+		}
+		c := natBridgeAddrs{Name: ifi.Name}
+		for _, a := range addrs {
+			if n, ok := a.(*net.IPNet); ok && n.IP.To4() != nil {
+				c.Nets = append(c.Nets, n)
+			}
+		}
+		candidates = append(candidates, c)
+	}
+
+	var leased net.IP
+	if mac != "" {
+		if ip, err := LookupDHCPLease(NATLeasesFile, mac); err == nil {
+			leased = net.ParseIP(ip)
+		}
+	}
+	return pickNATBridge(candidates, leased)
+}
+
+// bpfSource reads frames from a /dev/bpf device attached to one interface.
+type bpfSource struct {
+	f   *os.File
+	buf []byte
+}
+
+// bpfIfreq is struct ifreq as BIOCSETIF expects it: the interface name
+// padded to the full size of the union.
+type bpfIfreq struct {
+	Name [unix.IFNAMSIZ]byte
+	_    [16]byte
+}
+
+// openPacketSource attaches the first free /dev/bpf device to iface,
+// passing only the frames filter accepts if it is not nil.
+func openPacketSource(iface string, filter []bpf.RawInstruction) (packetSource, error) {
+	if len(iface) >= unix.IFNAMSIZ {
+		return nil, fmt.Errorf("interface name %q is too long", iface)
+	}
+
+	fd := -1
+	var err error
+	for i := 0; i < 256; i++ {
+		fd, err = unix.Open(fmt.Sprintf("/dev/bpf%d", i), unix.O_RDWR|unix.O_CLOEXEC, 0)
+		if !errors.Is(err, unix.EBUSY) {
+			break
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("open /dev/bpf: %w (requires root or read access to /dev/bpf*)", err)
+	}
+
+	// The buffer size must be set before attaching the interface
+	if err := unix.IoctlSetPointerInt(fd, unix.BIOCSBLEN, bpfBufferSize); err != nil {
+		unix.Close(fd)
+		return nil, fmt.Errorf("BIOCSBLEN: %w", err)
+	}
+	var ifr bpfIfreq
+	copy(ifr.Name[:], iface)
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), uintptr(unix.BIOCSETIF), uintptr(unsafe.Pointer(&ifr))); errno != 0 {
+		unix.Close(fd)
+		return nil, fmt.Errorf("capture on %s: %w", iface, errno)
+	}
+	if len(filter) > 0 {
+		insns := make([]unix.BpfInsn, len(filter))
+		for i, ins := range filter {
+			insns[i] = unix.BpfInsn{Code: ins.Op, Jt: ins.Jt, Jf: ins.Jf, K: ins.K}
+		}
+		prog := unix.BpfProgram{Len: uint32(len(insns)), Insns: &insns[0]}
+		if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), uintptr(unix.BIOCSETF), uintptr(unsafe.Pointer(&prog))); errno != 0 {
+			unix.Close(fd)
+			return nil, fmt.Errorf("BIOCSETF: %w", errno)
+		}
+	}
+	// Deliver frames as they arrive instead of when the buffer fills
+	if err := unix.IoctlSetPointerInt(fd, unix.BIOCIMMEDIATE, 1); err != nil {
+		unix.Close(fd)
+		return nil, fmt.Errorf("BIOCIMMEDIATE: %w", err)
+	}
+	bufLen, err := unix.IoctlGetInt(fd, unix.BIOCGBLEN)
+	if err != nil {
+		unix.Close(fd)
+		return nil, fmt.Errorf("BIOCGBLEN: %w", err)
+	}
+	if err := unix.SetNonblock(fd, true); err != nil {
+		unix.Close(fd)
+		return nil, err
+	}
+
+	// A non-blocking descriptor goes through the runtime poller, so
+	// closing the file wakes a pending read
+	return &bpfSource{
+		f:   os.NewFile(uintptr(fd), "bpf:"+iface),
+		buf: make([]byte, int(uint32(bufLen))),
+	}, nil
+}
+
+// ReadPackets reads a buffer of frames, each preceded by a bpf_hdr.
+func (s *bpfSource) ReadPackets(fn func(frame []byte, origLen int, ts time.Time) error) error {
+	n, err := s.f.Read(s.buf)
+	if err != nil {
+		return err
+	}
+
+	const hdrSize = int(unsafe.Sizeof(unix.BpfHdr{}))
+	for off := 0; off+hdrSize <= n; {
+		hdr := s.buf[off:]
+		sec := int32(binary.NativeEndian.Uint32(hdr[0:]))
+		usec := int32(binary.NativeEndian.Uint32(hdr[4:]))
+		capLen := int(binary.NativeEndian.Uint32(hdr[8:]))
+		dataLen := int(binary.NativeEndian.Uint32(hdr[12:]))
+		hdrLen := int(binary.NativeEndian.Uint16(hdr[16:]))
+
+		start := off + hdrLen
+		if start+capLen > n {
+			break
+		}
+		ts := time.Unix(int64(sec), int64(usec)*1000)
+		if err := fn(s.buf[start:start+capLen], dataLen, ts); err != nil {
+			return err
+		}
+		off += bpfWordAlign(hdrLen + capLen)
+	}
+	return nil
+}
+
+// Close detaches and closes the bpf device.
+func (s *bpfSource) Close() error {
+	return s.f.Close()
+}
+
+// bpfWordAlign rounds x up to the alignment of records in a bpf buffer.
+func bpfWordAlign(x int) int {
+	const align = 4 // BPF_ALIGNMENT is sizeof(int32_t) on Darwin
+	return (x + align - 1) &^ (align - 1)
+}
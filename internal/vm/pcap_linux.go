@@ -0,0 +1,89 @@
+package vm
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"github.com/javanstorm/vmterminal/pkg/hypervisor"
+	"golang.org/x/net/bpf"
+	"golang.org/x/sys/unix"
+)
+
+// hostInterface returns the tap interface behind the VM's only NIC. KVM
+// networking always goes through the tap given to 'run --tap-device',
+// which carries no other traffic.
+func hostInterface(nic hypervisor.NetworkInterface, nicIndex int, tapDevice string) (iface string, shared bool, err error) {
+	if tapDevice == "" {
+		return "", false, fmt.Errorf("the VM was started without --tap-device, so it has no network traffic to capture")
+	}
+	if nicIndex != 0 {
+		return "", false, fmt.Errorf("NIC %d does not exist; KVM VMs have a single tap-backed NIC", nicIndex)
+	}
+	return tapDevice, false, nil
+}
+
+// afPacketSource reads frames from an AF_PACKET socket bound to one
+// interface.
+type afPacketSource struct {
+	f   *os.File
+	buf []byte
+}
+
+// openPacketSource opens a raw AF_PACKET socket on iface, passing only the
+// frames filter accepts if it is not nil.
+func openPacketSource(iface string, filter []bpf.RawInstruction) (packetSource, error) {
+	ifi, err := net.InterfaceByName(iface)
+	if err != nil {
+		return nil, fmt.Errorf("capture on %s: %w", iface, err)
+	}
+
+	proto := htons(unix.ETH_P_ALL)
+	fd, err := unix.Socket(unix.AF_PACKET, unix.SOCK_RAW|unix.SOCK_CLOEXEC|unix.SOCK_NONBLOCK, int(proto))
+	if err != nil {
+		return nil, fmt.Errorf("open packet socket: %w (requires root or CAP_NET_RAW)", err)
+	}
+	// Attach the filter before binding so no unfiltered frame is queued
+	if len(filter) > 0 {
+		insns := make([]unix.SockFilter, len(filter))
+		for i, ins := range filter {
+			insns[i] = unix.SockFilter{Code: ins.Op, Jt: ins.Jt, Jf: ins.Jf, K: ins.K}
+		}
+		prog := unix.SockFprog{Len: uint16(len(insns)), Filter: &insns[0]}
+		if err := unix.SetsockoptSockFprog(fd, unix.SOL_SOCKET, unix.SO_ATTACH_FILTER, &prog); err != nil {
+			unix.Close(fd)
+			return nil, fmt.Errorf("attach capture filter: %w", err)
+		}
+	}
+	if err := unix.Bind(fd, &unix.SockaddrLinklayer{Protocol: proto, Ifindex: ifi.Index}); err != nil {
+		unix.Close(fd)
+		return nil, fmt.Errorf("bind packet socket to %s: %w", iface, err)
+	}
+
+	// A non-blocking descriptor goes through the runtime poller, so
+	// closing the file wakes a pending read
+	return &afPacketSource{
+		f:   os.NewFile(uintptr(fd), "packet:"+iface),
+		buf: make([]byte, pcapSnapLen),
+	}, nil
+}
+
+// ReadPackets reads one frame.
+func (s *afPacketSource) ReadPackets(fn func(frame []byte, origLen int, ts time.Time) error) error {
+	n, err := s.f.Read(s.buf)
+	if err != nil {
+		return err
+	}
+	return fn(s.buf[:n], n, time.Now())
+}
+
+// Close closes the socket.
+func (s *afPacketSource) Close() error {
+	return s.f.Close()
+}
+
+// htons converts a 16-bit value to network byte order.
+func htons(v uint16) uint16 {
+	return v<<8 | v>>8
+}
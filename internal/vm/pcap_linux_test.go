@@ -0,0 +1,18 @@
+package vm
+
+import (
+	"testing"
+
+	"github.com/javanstorm/vmterminal/pkg/hypervisor"
+)
+
+func TestHostInterfaceTap(t *testing.T) {
+	// The tap carries only the VM's traffic, so nothing is filtered
+	nics := []hypervisor.NetworkInterface{{Mode: hypervisor.NetworkNAT, MAC: "52:54:00:12:34:56"}}
+	if got, mac, err := HostInterface(nics, 0, "tap0"); err != nil || got != "tap0" || mac != "" {
+		t.Errorf("HostInterface = %q, %q, %v; want tap0 and no filter", got, mac, err)
+	}
+	if _, _, err := HostInterface(nics, 0, ""); err == nil {
+		t.Error("expected error for a VM without a tap device")
+	}
+}
@@ -0,0 +1,24 @@
+//go:build !linux && !darwin
+
+package vm
+
+import (
+	"errors"
+
+	"github.com/javanstorm/vmterminal/pkg/hypervisor"
+	"golang.org/x/net/bpf"
+)
+
+// errCaptureUnsupported is returned by packet capture on platforms
+// without AF_PACKET or /dev/bpf support here.
+var errCaptureUnsupported = errors.New("packet capture is only supported on Linux and macOS")
+
+// hostInterface is not supported on this platform.
+func hostInterface(nic hypervisor.NetworkInterface, nicIndex int, tapDevice string) (iface string, shared bool, err error) {
+	return "", false, errCaptureUnsupported
+}
+
+// openPacketSource is not supported on this platform.
+func openPacketSource(iface string, filter []bpf.RawInstruction) (packetSource, error) {
+	return nil, errCaptureUnsupported
+}
@@ -0,0 +1,172 @@
+package vm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/javanstorm/vmterminal/pkg/hypervisor"
+	"golang.org/x/net/bpf"
+)
+
+// fakePacketSource hands out queued frames, then blocks until closed.
+type fakePacketSource struct {
+	frames chan []byte
+	closed chan struct{}
+}
+
+func (s *fakePacketSource) ReadPackets(fn func(frame []byte, origLen int, ts time.Time) error) error {
+	select {
+	case frame := <-s.frames:
+		return fn(frame, len(frame)+4, time.Unix(1700000000, 250000000))
+	case <-s.closed:
+		return os.ErrClosed
+	}
+}
+
+func (s *fakePacketSource) Close() error {
+	close(s.closed)
+	return nil
+}
+
+func TestPcapWriter(t *testing.T) {
+	var buf bytes.Buffer
+	pw, err := newPcapWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := pw.WritePacket(time.Unix(10, 5000), []byte{1, 2, 3}, 60); err != nil {
+		t.Fatal(err)
+	}
+
+	data := buf.Bytes()
+	if len(data) != 24+16+3 {
+		t.Fatalf("wrote %d bytes, want %d", len(data), 24+16+3)
+	}
+	le := binary.LittleEndian
+	if le.Uint32(data[0:]) != pcapMagic || le.Uint32(data[20:]) != pcapLinkEthernet {
+		t.Errorf("bad file header % x", data[:24])
+	}
+	rec := data[24:]
+	if le.Uint32(rec[0:]) != 10 || le.Uint32(rec[4:]) != 5 {
+		t.Errorf("timestamp = %d.%06d, want 10.000005", le.Uint32(rec[0:]), le.Uint32(rec[4:]))
+	}
+	if le.Uint32(rec[8:]) != 3 || le.Uint32(rec[12:]) != 60 {
+		t.Errorf("lengths = %d/%d, want 3/60", le.Uint32(rec[8:]), le.Uint32(rec[12:]))
+	}
+	if !bytes.Equal(rec[16:], []byte{1, 2, 3}) {
+		t.Errorf("frame = % x", rec[16:])
+	}
+}
+
+func TestCaptureWritesFrames(t *testing.T) {
+	src := &fakePacketSource{frames: make(chan []byte, 2), closed: make(chan struct{})}
+	src.frames <- []byte("first frame")
+	src.frames <- []byte("second")
+
+	out := filepath.Join(t.TempDir(), "vm.pcap")
+	c, err := startCapture(src, out)
+	if err != nil {
+		t.Fatalf("startCapture: %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for c.Packets() < 2 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	packets, err := c.Stop()
+	if err != nil || packets != 2 {
+		t.Fatalf("Stop = %d, %v; want 2 packets", packets, err)
+	}
+	if again, _ := c.Stop(); again != 2 {
+		t.Errorf("second Stop = %d", again)
+	}
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := 24 + 16 + len("first frame") + 16 + len("second"); len(data) != want {
+		t.Errorf("capture file is %d bytes, want %d", len(data), want)
+	}
+}
+
+func TestHostInterfaceNICIndex(t *testing.T) {
+	if _, _, err := HostInterface(nil, 0, "tap0"); err == nil {
+		t.Error("expected error for a VM without NICs")
+	}
+	nics := []hypervisor.NetworkInterface{{Mode: hypervisor.NetworkNAT}}
+	if _, _, err := HostInterface(nics, 1, "tap0"); err == nil {
+		t.Error("expected error for a NIC index out of range")
+	}
+}
+
+func TestMACFilter(t *testing.T) {
+	mac, _ := net.ParseMAC("52:54:00:12:34:56")
+	prog, err := macFilter(mac)
+	if err != nil {
+		t.Fatalf("macFilter: %v", err)
+	}
+	insns := make([]bpf.Instruction, len(prog))
+	for i, raw := range prog {
+		insns[i] = raw.Disassemble()
+	}
+	filter, err := bpf.NewVM(insns)
+	if err != nil {
+		t.Fatalf("NewVM: %v", err)
+	}
+
+	other, _ := net.ParseMAC("52:54:00:12:34:57")
+	frame := func(dst, src net.HardwareAddr) []byte {
+		return append(append(append([]byte{}, dst...), src...), 0x08, 0x00, 0x45)
+	}
+	tests := []struct {
+		name     string
+		frame    []byte
+		wantKeep bool
+	}{
+		{"to the VM", frame(mac, other), true},
+		{"from the VM", frame(other, mac), true},
+		{"between others", frame(other, other), false},
+		{"same prefix", frame(net.HardwareAddr{0x52, 0x54, 0x00, 0x12, 0x00, 0x00}, other), false},
+	}
+	for _, tt := range tests {
+		n, err := filter.Run(tt.frame)
+		if err != nil {
+			t.Fatalf("%s: Run: %v", tt.name, err)
+		}
+		if keep := n > 0; keep != tt.wantKeep {
+			t.Errorf("%s: kept = %v, want %v", tt.name, keep, tt.wantKeep)
+		}
+	}
+}
+
+func TestPickNATBridge(t *testing.T) {
+	ipNet := func(s string) *net.IPNet {
+		_, n, _ := net.ParseCIDR(s)
+		return n
+	}
+	ifaces := []natBridgeAddrs{
+		{Name: "en0", Nets: []*net.IPNet{ipNet("192.168.1.10/24")}},
+		{Name: "bridge0"}, // Thunderbolt bridge, no address
+		{Name: "bridge100", Nets: []*net.IPNet{ipNet("192.168.64.1/24")}},
+		{Name: "bridge101", Nets: []*net.IPNet{ipNet("192.168.65.1/24")}},
+	}
+
+	if got, err := pickNATBridge(ifaces, net.ParseIP("192.168.65.3")); err != nil || got != "bridge101" {
+		t.Errorf("pickNATBridge(leased) = %q, %v; want bridge101", got, err)
+	}
+	if _, err := pickNATBridge(ifaces, nil); err == nil {
+		t.Error("expected error for several bridges without a lease")
+	}
+	if got, err := pickNATBridge(ifaces[:3], nil); err != nil || got != "bridge100" {
+		t.Errorf("pickNATBridge(one bridge) = %q, %v; want bridge100", got, err)
+	}
+	if _, err := pickNATBridge(ifaces[:2], nil); err == nil {
+		t.Error("expected error without a vmnet bridge")
+	}
+}
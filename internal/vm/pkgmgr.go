@@ -0,0 +1,207 @@
+package vm
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/javanstorm/vmterminal/internal/distro"
+)
+
+// PackageManager builds the guest commands for a distribution's package
+// manager. Each method returns the argv to run inside the VM.
+type PackageManager interface {
+	// Name returns the package manager binary name.
+	Name() string
+
+	// Install returns the command that installs pkgs.
+	Install(pkgs ...string) []string
+
+	// Remove returns the command that removes pkgs.
+	Remove(pkgs ...string) []string
+
+	// Search returns the command that searches for query.
+	Search(query string) []string
+
+	// Update returns the command that refreshes the package index.
+	Update() []string
+
+	// Upgrade returns the command that upgrades all installed packages.
+	Upgrade() []string
+
+	// List returns the command that lists installed packages.
+	List() []string
+}
+
+// DetectPackageManager returns the package manager for a distribution.
+// Unknown distributions fall back to apk, matching the default distro.
+func DetectPackageManager(distroID distro.ID) PackageManager {
+	switch distroID {
+	case distro.Ubuntu, distro.Debian:
+		return AptManager{}
+	case distro.Rocky:
+		return DnfManager{}
+	case distro.ArchLinux:
+		return PacmanManager{}
+	case distro.OpenSUSE:
+		return ZypperManager{}
+	case distro.Void:
+		return XbpsManager{}
+	default:
+		return ApkManager{}
+	}
+}
+
+// detectPackageManagerCommand prints the path of the first package manager
+// found in the guest.
+const detectPackageManagerCommand = "command -v apk dnf apt-get pacman zypper xbps-install 2>/dev/null | head -1"
+
+// packageManagers maps package manager names, both as returned by Name and
+// as binaries in the guest, to their PackageManager.
+var packageManagers = map[string]PackageManager{
+	"apk":          ApkManager{},
+	"apt":          AptManager{},
+	"apt-get":      AptManager{},
+	"dnf":          DnfManager{},
+	"yum":          YumManager{},
+	"pacman":       PacmanManager{},
+	"zypper":       ZypperManager{},
+	"xbps":         XbpsManager{},
+	"xbps-install": XbpsManager{},
+}
+
+// PackageManagerNamed returns the package manager with the given name, as
+// returned by Name or as its binary is called, e.g. "apt" or "apt-get".
+func PackageManagerNamed(name string) (PackageManager, bool) {
+	pm, ok := packageManagers[name]
+	return pm, ok
+}
+
+// DetectPackageManagerInVM asks the running VM reached through sshCfg which
+// package manager it has, so that one installed or removed by hand after
+// the VM was created is picked up. The I/O fields of sshCfg are ignored.
+func DetectPackageManagerInVM(ctx context.Context, sshCfg SSHConfig) (PackageManager, error) {
+	var stdout bytes.Buffer
+	sshCfg.Stdin, sshCfg.Stdout, sshCfg.Stderr = nil, &stdout, nil
+	if _, err := ExecOverSSH(ctx, sshCfg, detectPackageManagerCommand); err != nil {
+		return nil, fmt.Errorf("detect package manager: %w", err)
+	}
+	found := strings.TrimSpace(stdout.String())
+	if found == "" {
+		return nil, fmt.Errorf("no known package manager found in the VM")
+	}
+	pm, ok := PackageManagerNamed(path.Base(found))
+	if !ok {
+		return nil, fmt.Errorf("unknown package manager %q in the VM", found)
+	}
+	return pm, nil
+}
+
+// withArgs returns cmd followed by args.
+func withArgs(cmd []string, args ...string) []string {
+	return append(cmd, args...)
+}
+
+// ApkManager drives Alpine's apk.
+type ApkManager struct{}
+
+func (ApkManager) Name() string                    { return "apk" }
+func (ApkManager) Install(pkgs ...string) []string { return withArgs([]string{"apk", "add"}, pkgs...) }
+func (ApkManager) Remove(pkgs ...string) []string  { return withArgs([]string{"apk", "del"}, pkgs...) }
+func (ApkManager) Search(query string) []string    { return []string{"apk", "search", query} }
+func (ApkManager) Update() []string                { return []string{"apk", "update"} }
+func (ApkManager) Upgrade() []string               { return []string{"apk", "upgrade"} }
+func (ApkManager) List() []string                  { return []string{"apk", "info"} }
+
+// AptManager drives apt-get on Debian and Ubuntu.
+type AptManager struct{}
+
+func (AptManager) Name() string { return "apt" }
+func (AptManager) Install(pkgs ...string) []string {
+	return withArgs([]string{"apt-get", "install", "-y"}, pkgs...)
+}
+func (AptManager) Remove(pkgs ...string) []string {
+	return withArgs([]string{"apt-get", "remove", "-y"}, pkgs...)
+}
+func (AptManager) Search(query string) []string { return []string{"apt-cache", "search", query} }
+func (AptManager) Update() []string             { return []string{"apt-get", "update"} }
+func (AptManager) Upgrade() []string            { return []string{"apt-get", "upgrade", "-y"} }
+func (AptManager) List() []string               { return []string{"dpkg-query", "-W"} }
+
+// DnfManager drives dnf on Rocky Linux and Fedora.
+type DnfManager struct{}
+
+func (DnfManager) Name() string { return "dnf" }
+func (DnfManager) Install(pkgs ...string) []string {
+	return withArgs([]string{"dnf", "install", "-y"}, pkgs...)
+}
+func (DnfManager) Remove(pkgs ...string) []string {
+	return withArgs([]string{"dnf", "remove", "-y"}, pkgs...)
+}
+func (DnfManager) Search(query string) []string { return []string{"dnf", "search", query} }
+func (DnfManager) Update() []string             { return []string{"dnf", "makecache"} }
+func (DnfManager) Upgrade() []string            { return []string{"dnf", "upgrade", "-y"} }
+func (DnfManager) List() []string               { return []string{"dnf", "list", "--installed"} }
+
+// YumManager drives yum on RHEL-family hosts. EL7 has no dnf, and on
+// later releases yum is an alias for it.
+type YumManager struct{}
+
+func (YumManager) Name() string { return "yum" }
+func (YumManager) Install(pkgs ...string) []string {
+	return withArgs([]string{"yum", "install", "-y"}, pkgs...)
+}
+func (YumManager) Remove(pkgs ...string) []string {
+	return withArgs([]string{"yum", "remove", "-y"}, pkgs...)
+}
+func (YumManager) Search(query string) []string { return []string{"yum", "search", query} }
+func (YumManager) Update() []string             { return []string{"yum", "makecache"} }
+func (YumManager) Upgrade() []string            { return []string{"yum", "upgrade", "-y"} }
+func (YumManager) List() []string               { return []string{"yum", "list", "installed"} }
+
+// PacmanManager drives Arch Linux's pacman.
+type PacmanManager struct{}
+
+func (PacmanManager) Name() string { return "pacman" }
+func (PacmanManager) Install(pkgs ...string) []string {
+	return withArgs([]string{"pacman", "-S", "--noconfirm"}, pkgs...)
+}
+func (PacmanManager) Remove(pkgs ...string) []string {
+	return withArgs([]string{"pacman", "-R", "--noconfirm"}, pkgs...)
+}
+func (PacmanManager) Search(query string) []string { return []string{"pacman", "-Ss", query} }
+func (PacmanManager) Update() []string             { return []string{"pacman", "-Sy"} }
+func (PacmanManager) Upgrade() []string            { return []string{"pacman", "-Syu", "--noconfirm"} }
+func (PacmanManager) List() []string               { return []string{"pacman", "-Q"} }
+
+// ZypperManager drives openSUSE's zypper.
+type ZypperManager struct{}
+
+func (ZypperManager) Name() string { return "zypper" }
+func (ZypperManager) Install(pkgs ...string) []string {
+	return withArgs([]string{"zypper", "--non-interactive", "install"}, pkgs...)
+}
+func (ZypperManager) Remove(pkgs ...string) []string {
+	return withArgs([]string{"zypper", "--non-interactive", "remove"}, pkgs...)
+}
+func (ZypperManager) Search(query string) []string { return []string{"zypper", "search", query} }
+func (ZypperManager) Update() []string             { return []string{"zypper", "--non-interactive", "refresh"} }
+func (ZypperManager) Upgrade() []string            { return []string{"zypper", "--non-interactive", "update"} }
+func (ZypperManager) List() []string               { return []string{"zypper", "search", "--installed-only"} }
+
+// XbpsManager drives Void Linux's xbps tools.
+type XbpsManager struct{}
+
+func (XbpsManager) Name() string { return "xbps" }
+func (XbpsManager) Install(pkgs ...string) []string {
+	return withArgs([]string{"xbps-install", "-y"}, pkgs...)
+}
+func (XbpsManager) Remove(pkgs ...string) []string {
+	return withArgs([]string{"xbps-remove", "-y"}, pkgs...)
+}
+func (XbpsManager) Search(query string) []string { return []string{"xbps-query", "-Rs", query} }
+func (XbpsManager) Update() []string             { return []string{"xbps-install", "-S"} }
+func (XbpsManager) Upgrade() []string            { return []string{"xbps-install", "-Syu"} }
+func (XbpsManager) List() []string               { return []string{"xbps-query", "-l"} }
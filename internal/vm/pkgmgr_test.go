@@ -0,0 +1,139 @@
+package vm
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/javanstorm/vmterminal/internal/distro"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestDetectPackageManager(t *testing.T) {
+	tests := []struct {
+		id   distro.ID
+		want string
+	}{
+		{distro.Alpine, "apk"},
+		{distro.Ubuntu, "apt"},
+		{distro.Debian, "apt"},
+		{distro.Rocky, "dnf"},
+		{distro.ArchLinux, "pacman"},
+		{distro.OpenSUSE, "zypper"},
+		{distro.Void, "xbps"},
+		{distro.ID("unknown"), "apk"},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.id), func(t *testing.T) {
+			if got := DetectPackageManager(tt.id).Name(); got != tt.want {
+				t.Errorf("DetectPackageManager(%q).Name() = %q, want %q", tt.id, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPackageManagerCommands(t *testing.T) {
+	tests := []struct {
+		name string
+		got  []string
+		want []string
+	}{
+		{"apk install", ApkManager{}.Install("git", "vim"), []string{"apk", "add", "git", "vim"}},
+		{"apt install", AptManager{}.Install("git"), []string{"apt-get", "install", "-y", "git"}},
+		{"dnf remove", DnfManager{}.Remove("git"), []string{"dnf", "remove", "-y", "git"}},
+		{"pacman search", PacmanManager{}.Search("vim"), []string{"pacman", "-Ss", "vim"}},
+		{"zypper upgrade", ZypperManager{}.Upgrade(), []string{"zypper", "--non-interactive", "update"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !reflect.DeepEqual(tt.got, tt.want) {
+				t.Errorf("got %v, want %v", tt.got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHostPackageManagerFor(t *testing.T) {
+	tests := []struct {
+		hostOS string
+		name   string
+		got    func(HostPackageManager) []string
+		want   []string
+	}{
+		{"macos", "brew", func(pm HostPackageManager) []string { return pm.Install("qemu") }, []string{"brew", "install", "qemu"}},
+		{"ubuntu", "apt", func(pm HostPackageManager) []string { return pm.Install("qemu-utils") }, []string{"sudo", "apt-get", "install", "-y", "qemu-utils"}},
+		{"fedora", "dnf", func(pm HostPackageManager) []string { return pm.Upgrade() }, []string{"sudo", "dnf", "upgrade", "-y"}},
+		{"centos", "yum", func(pm HostPackageManager) []string { return pm.Install("qemu-img") }, []string{"sudo", "yum", "install", "-y", "qemu-img"}},
+		{"manjaro", "pacman", func(pm HostPackageManager) []string { return pm.Search("zstd") }, []string{"pacman", "-Ss", "zstd"}},
+		{"suse", "zypper", func(pm HostPackageManager) []string { return pm.List() }, []string{"zypper", "search", "--installed-only"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.hostOS, func(t *testing.T) {
+			pm, err := HostPackageManagerFor(tt.hostOS)
+			if err != nil {
+				t.Fatalf("HostPackageManagerFor(%q): %v", tt.hostOS, err)
+			}
+			if pm.Name() != tt.name {
+				t.Errorf("Name() = %q, want %q", pm.Name(), tt.name)
+			}
+			if got := tt.got(pm); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := HostPackageManagerFor("linux"); err == nil {
+		t.Error("expected an error for an unknown host OS")
+	}
+}
+
+func TestDetectPackageManagerInVM(t *testing.T) {
+	keyPath, _, err := NewSSHKeyManager(t.TempDir()).EnsureKeyPair()
+	if err != nil {
+		t.Fatalf("EnsureKeyPair: %v", err)
+	}
+	keyData, err := os.ReadFile(keyPath)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	signer, err := ssh.ParsePrivateKey(keyData)
+	if err != nil {
+		t.Fatalf("ParsePrivateKey: %v", err)
+	}
+
+	tests := []struct {
+		found   string
+		want    string
+		wantErr bool
+	}{
+		{"/sbin/apk\n", "apk", false},
+		{"/usr/bin/apt-get\n", "apt", false},
+		{"/usr/bin/xbps-install\n", "xbps", false},
+		{"", "", true},
+		{"/usr/bin/nix-env\n", "", true},
+	}
+	for _, tt := range tests {
+		port := startExecServer(t, signer.PublicKey(), func(cmd string) (string, string, uint32) {
+			if cmd != detectPackageManagerCommand {
+				return "", "unexpected command\n", 127
+			}
+			return tt.found, "", 0
+		})
+		pm, err := DetectPackageManagerInVM(context.Background(), SSHConfig{Host: "127.0.0.1", Port: port, KeyPath: keyPath})
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("found %q: expected error, got %s", tt.found, pm.Name())
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("found %q: %v", tt.found, err)
+		} else if pm.Name() != tt.want {
+			t.Errorf("found %q: got %s, want %s", tt.found, pm.Name(), tt.want)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package vm
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/javanstorm/vmterminal/pkg/hypervisor"
+)
+
+// portForwardDialTimeout bounds how long a forwarded connection waits for
+// the guest to accept it.
+const portForwardDialTimeout = 5 * time.Second
+
+// udpSessionIdle is how long a forwarded UDP client may stay silent before
+// its session with the guest is dropped.
+const udpSessionIdle = 2 * time.Minute
+
+// portForwarder relays host ports on 127.0.0.1 to the same VM's guest
+// ports. Neither hypervisor's NAT forwards ports itself, so the relay runs
+// in the VM process and dials the guest's address, which guestIP looks up
+// when the first connection arrives.
+type portForwarder struct {
+	guestIP func() (string, error)
+
+	mu        sync.Mutex
+	ip        string // cached guest address, "" until known
+	listeners []io.Closer
+}
+
+// startPortForwards binds every host port in forwards and starts relaying
+// to the guest. Binding happens up front so a port that is in use fails
+// the boot instead of going unnoticed.
+func startPortForwards(forwards []hypervisor.PortForward, guestIP func() (string, error)) (*portForwarder, error) {
+	f := &portForwarder{guestIP: guestIP}
+	for _, pf := range forwards {
+		addr := net.JoinHostPort("127.0.0.1", strconv.Itoa(pf.Host))
+		switch pf.Proto {
+		case "udp":
+			conn, err := net.ListenPacket("udp", addr)
+			if err != nil {
+				f.Close()
+				return nil, fmt.Errorf("forward port %d/udp: %w", pf.Host, err)
+			}
+			f.listeners = append(f.listeners, conn)
+			go f.serveUDP(conn, pf.Guest)
+		default:
+			ln, err := net.Listen("tcp", addr)
+			if err != nil {
+				f.Close()
+				return nil, fmt.Errorf("forward port %d/tcp: %w", pf.Host, err)
+			}
+			f.listeners = append(f.listeners, ln)
+			go f.serveTCP(ln, pf.Guest)
+		}
+	}
+	return f, nil
+}
+
+// Close stops accepting connections on the forwarded ports.
+func (f *portForwarder) Close() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, l := range f.listeners {
+		l.Close()
+	}
+}
+
+// guestAddr returns the guest's address and port, looking the address up
+// if it is not known yet.
+func (f *portForwarder) guestAddr(port int) (string, error) {
+	f.mu.Lock()
+	ip := f.ip
+	f.mu.Unlock()
+	if ip == "" {
+		var err error
+		if ip, err = f.guestIP(); err != nil {
+			return "", err
+		}
+		f.mu.Lock()
+		f.ip = ip
+		f.mu.Unlock()
+	}
+	return net.JoinHostPort(ip, strconv.Itoa(port)), nil
+}
+
+// forget drops the cached guest address after a failed dial, since the
+// guest may have been given a new one.
+func (f *portForwarder) forget() {
+	f.mu.Lock()
+	f.ip = ""
+	f.mu.Unlock()
+}
+
+func (f *portForwarder) serveTCP(ln net.Listener, guestPort int) {
+	for {
+		client, err := ln.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			continue
+		}
+		go f.relayTCP(client, guestPort)
+	}
+}
+
+// relayTCP copies between client and a new connection to the guest until
+// both directions are done.
+func (f *portForwarder) relayTCP(client net.Conn, guestPort int) {
+	defer client.Close()
+	addr, err := f.guestAddr(guestPort)
+	if err != nil {
+		return
+	}
+	guest, err := net.DialTimeout("tcp", addr, portForwardDialTimeout)
+	if err != nil {
+		f.forget()
+		return
+	}
+	defer guest.Close()
+
+	done := make(chan struct{})
+	go func() {
+		io.Copy(guest, client)
+		if c, ok := guest.(*net.TCPConn); ok {
+			c.CloseWrite()
+		}
+		close(done)
+	}()
+	io.Copy(client, guest)
+	if c, ok := client.(*net.TCPConn); ok {
+		c.CloseWrite()
+	}
+	<-done
+}
+
+// serveUDP relays datagrams between host clients and the guest, keeping
+// one guest socket per client so replies reach the right sender.
+func (f *portForwarder) serveUDP(conn net.PacketConn, guestPort int) {
+	var mu sync.Mutex
+	sessions := make(map[string]net.Conn)
+	defer func() {
+		mu.Lock()
+		for _, s := range sessions {
+			s.Close()
+		}
+		mu.Unlock()
+	}()
+
+	buf := make([]byte, 65535)
+	for {
+		n, client, err := conn.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			continue
+		}
+
+		mu.Lock()
+		guest, ok := sessions[client.String()]
+		mu.Unlock()
+		if !ok {
+			addr, err := f.guestAddr(guestPort)
+			if err != nil {
+				continue
+			}
+			if guest, err = net.Dial("udp", addr); err != nil {
+				f.forget()
+				continue
+			}
+			mu.Lock()
+			sessions[client.String()] = guest
+			mu.Unlock()
+
+			// Copy replies back until the client goes quiet
+			go func() {
+				reply := make([]byte, 65535)
+				for {
+					guest.SetReadDeadline(time.Now().Add(udpSessionIdle))
+					n, err := guest.Read(reply)
+					if err != nil {
+						break
+					}
+					conn.WriteTo(reply[:n], client)
+				}
+				mu.Lock()
+				delete(sessions, client.String())
+				mu.Unlock()
+				guest.Close()
+			}()
+		}
+		guest.Write(buf[:n])
+	}
+}
@@ -0,0 +1,66 @@
+package vm
+
+import (
+	"bufio"
+	"net"
+	"strconv"
+	"testing"
+
+	"github.com/javanstorm/vmterminal/pkg/hypervisor"
+)
+
+// freePort returns a TCP port on 127.0.0.1 that nothing listens on.
+func freePort(t *testing.T) int {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	return ln.Addr().(*net.TCPAddr).Port
+}
+
+func TestPortForwarderTCP(t *testing.T) {
+	// The "guest" echoes one line back
+	guest, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer guest.Close()
+	go func() {
+		for {
+			conn, err := guest.Accept()
+			if err != nil {
+				return
+			}
+			line, _ := bufio.NewReader(conn).ReadString('\n')
+			conn.Write([]byte(line))
+			conn.Close()
+		}
+	}()
+
+	host := freePort(t)
+	guestPort := guest.Addr().(*net.TCPAddr).Port
+	f, err := startPortForwards([]hypervisor.PortForward{{Host: host, Guest: guestPort, Proto: "tcp"}},
+		func() (string, error) { return "127.0.0.1", nil })
+	if err != nil {
+		t.Fatalf("startPortForwards: %v", err)
+	}
+	defer f.Close()
+
+	conn, err := net.Dial("tcp", "127.0.0.1:"+strconv.Itoa(host))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.Write([]byte("hello\n"))
+	if got, _ := bufio.NewReader(conn).ReadString('\n'); got != "hello\n" {
+		t.Errorf("relayed reply = %q, want %q", got, "hello\n")
+	}
+
+	// A second forwarder cannot take the same port
+	if _, err := startPortForwards([]hypervisor.PortForward{{Host: host, Guest: guestPort, Proto: "tcp"}},
+		func() (string, error) { return "127.0.0.1", nil }); err == nil {
+		t.Error("expected an error for a host port in use")
+	}
+}
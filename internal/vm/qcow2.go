@@ -0,0 +1,97 @@
+package vm
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// qcow2Magic is the "QFI\xfb" signature at the start of every qcow2 image.
+const qcow2Magic = 0x514649fb
+
+// Qcow2Header holds the fields of a qcow2 header VMTerminal cares about.
+type Qcow2Header struct {
+	Version     uint32
+	ClusterBits uint32
+	Size        uint64 // Virtual disk size in bytes
+	Encrypted   bool
+}
+
+// ReadQcow2Header parses the header of the qcow2 image at path.
+func ReadQcow2Header(path string) (*Qcow2Header, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var raw struct {
+		Magic             uint32
+		Version           uint32
+		BackingFileOffset uint64
+		BackingFileSize   uint32
+		ClusterBits       uint32
+		Size              uint64
+		CryptMethod       uint32
+	}
+	if err := binary.Read(f, binary.BigEndian, &raw); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, fmt.Errorf("%s: not a qcow2 image", path)
+		}
+		return nil, fmt.Errorf("read qcow2 header: %w", err)
+	}
+	if raw.Magic != qcow2Magic {
+		return nil, fmt.Errorf("%s: not a qcow2 image", path)
+	}
+	if raw.Version != 2 && raw.Version != 3 {
+		return nil, fmt.Errorf("%s: unsupported qcow2 version %d", path, raw.Version)
+	}
+
+	return &Qcow2Header{
+		Version:     raw.Version,
+		ClusterBits: raw.ClusterBits,
+		Size:        raw.Size,
+		Encrypted:   raw.CryptMethod != 0,
+	}, nil
+}
+
+// ConvertQcow2ToRaw converts the qcow2 image at src into a raw image at dst
+// using qemu-img. Without qemu-img the header is still validated so the
+// error can say what the image is and how to proceed.
+func ConvertQcow2ToRaw(src, dst string) error {
+	hdr, err := ReadQcow2Header(src)
+	if err != nil {
+		return err
+	}
+	if hdr.Encrypted {
+		return fmt.Errorf("%s: encrypted qcow2 images are not supported", filepath.Base(src))
+	}
+
+	if err := EnsureQemuImg(); err != nil {
+		return fmt.Errorf("%s is a qcow2 v%d image (%d MB) and converting it needs qemu-img: %w",
+			filepath.Base(src), hdr.Version, hdr.Size/(1024*1024), err)
+	}
+
+	return qemuImgToRaw(src, dst, "qcow2")
+}
+
+// qemuImgToRaw converts src, an image in the given qemu-img format, into a
+// raw image at dst.
+func qemuImgToRaw(src, dst, format string) error {
+	// Convert to a temp file so an interrupted run leaves no partial disk
+	tmpPath := dst + ".tmp"
+	cmd := exec.Command("qemu-img", "convert", "-f", format, "-O", "raw", src, tmpPath)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("qemu-img convert: %w: %s", err, output)
+	}
+
+	if err := os.Rename(tmpPath, dst); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("finalize disk: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package vm
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeQcow2Header writes a minimal qcow2 header to a temp file.
+func writeQcow2Header(t *testing.T, magic, version uint32, size uint64, crypt uint32) string {
+	t.Helper()
+
+	buf := make([]byte, 104)
+	binary.BigEndian.PutUint32(buf[0:], magic)
+	binary.BigEndian.PutUint32(buf[4:], version)
+	binary.BigEndian.PutUint32(buf[20:], 16)
+	binary.BigEndian.PutUint64(buf[24:], size)
+	binary.BigEndian.PutUint32(buf[32:], crypt)
+
+	path := filepath.Join(t.TempDir(), "image.qcow2")
+	if err := os.WriteFile(path, buf, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReadQcow2Header(t *testing.T) {
+	path := writeQcow2Header(t, qcow2Magic, 3, 20*1024*1024*1024, 0)
+
+	hdr, err := ReadQcow2Header(path)
+	if err != nil {
+		t.Fatalf("ReadQcow2Header failed: %v", err)
+	}
+	if hdr.Version != 3 || hdr.ClusterBits != 16 || hdr.Size != 20*1024*1024*1024 || hdr.Encrypted {
+		t.Errorf("unexpected header: %+v", hdr)
+	}
+}
+
+func TestReadQcow2HeaderInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		path func(t *testing.T) string
+		want string
+	}{
+		{"raw image", func(t *testing.T) string {
+			return writeQcow2Header(t, 0, 0, 0, 0)
+		}, "not a qcow2 image"},
+		{"short file", func(t *testing.T) string {
+			path := filepath.Join(t.TempDir(), "short.qcow2")
+			os.WriteFile(path, []byte("QFI"), 0644)
+			return path
+		}, "not a qcow2 image"},
+		{"unknown version", func(t *testing.T) string {
+			return writeQcow2Header(t, qcow2Magic, 7, 1024, 0)
+		}, "unsupported qcow2 version"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ReadQcow2Header(tt.path(t))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestConvertQcow2ToRawEncrypted(t *testing.T) {
+	path := writeQcow2Header(t, qcow2Magic, 2, 1024*1024, 1)
+
+	err := ConvertQcow2ToRaw(path, filepath.Join(t.TempDir(), "disk.raw"))
+	if err == nil || !strings.Contains(err.Error(), "encrypted") {
+		t.Errorf("error = %v, want encrypted image error", err)
+	}
+}
@@ -0,0 +1,67 @@
+package vm
+
+import (
+	"io"
+	"time"
+)
+
+// burstSeconds is how many seconds of bandwidth a limited download may
+// consume at once before throttling starts.
+const burstSeconds = 5
+
+// rateLimitedReader throttles reads with a token bucket holding up to
+// burstSeconds worth of bytes. It starts full, so short downloads are not
+// slowed at all.
+type rateLimitedReader struct {
+	r      io.Reader
+	rate   float64 // bytes per second
+	burst  float64
+	tokens float64
+	last   time.Time
+
+	now   func() time.Time
+	sleep func(time.Duration)
+}
+
+// newRateLimitedReader limits r to bytesPerSec. A limit of zero or less
+// returns r unchanged.
+func newRateLimitedReader(r io.Reader, bytesPerSec int64) io.Reader {
+	if bytesPerSec <= 0 {
+		return r
+	}
+	burst := float64(bytesPerSec) * burstSeconds
+	return &rateLimitedReader{
+		r:      r,
+		rate:   float64(bytesPerSec),
+		burst:  burst,
+		tokens: burst,
+		last:   time.Now(),
+		now:    time.Now,
+		sleep:  time.Sleep,
+	}
+}
+
+func (l *rateLimitedReader) Read(p []byte) (int, error) {
+	if len(p) > int(l.burst) {
+		p = p[:int(l.burst)]
+	}
+
+	n, err := l.r.Read(p)
+
+	now := l.now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+
+	// Go into debt for what was read and wait until it is paid back
+	l.tokens -= float64(n)
+	if l.tokens < 0 {
+		wait := time.Duration(-l.tokens / l.rate * float64(time.Second))
+		l.sleep(wait)
+		l.tokens = 0
+		l.last = l.now()
+	}
+	return n, err
+}
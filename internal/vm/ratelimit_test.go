@@ -0,0 +1,70 @@
+package vm
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRateLimitedReaderZeroLimit(t *testing.T) {
+	r := bytes.NewReader(nil)
+	if got := newRateLimitedReader(r, 0); got != io.Reader(r) {
+		t.Error("a zero limit should return the reader unchanged")
+	}
+}
+
+func TestRateLimitedReaderFakeClock(t *testing.T) {
+	const rate = 1000 // bytes per second
+	clock := time.Unix(0, 0)
+	var slept time.Duration
+
+	l := newRateLimitedReader(bytes.NewReader(make([]byte, 8000)), rate).(*rateLimitedReader)
+	l.last = clock
+	l.now = func() time.Time { return clock }
+	l.sleep = func(d time.Duration) {
+		slept += d
+		clock = clock.Add(d)
+	}
+
+	n, err := io.Copy(io.Discard, l)
+	if err != nil || n != 8000 {
+		t.Fatalf("copied %d bytes, err %v", n, err)
+	}
+
+	// The 5000-byte burst is free; the other 3000 bytes take 3 seconds
+	if want := 3 * time.Second; slept < want-10*time.Millisecond || slept > want+10*time.Millisecond {
+		t.Errorf("slept %v, want %v", slept, want)
+	}
+}
+
+func TestDownloadBandwidthLimit(t *testing.T) {
+	const (
+		limit = 1 << 20         // 1 MiB/s, so a 5 MiB burst
+		size  = 6 << 20         // 1 MiB beyond the burst
+		want  = 1 * time.Second // time to pay back that 1 MiB
+	)
+	payload := make([]byte, size)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(payload)
+	}))
+	defer srv.Close()
+
+	m := NewAssetManager(t.TempDir(), nil, nil).WithBandwidthLimit(limit)
+	start := time.Now()
+	if err := m.downloadFile(filepath.Join(t.TempDir(), "rootfs"), srv.URL); err != nil {
+		t.Fatalf("downloadFile failed: %v", err)
+	}
+	elapsed := time.Since(start)
+
+	if elapsed < want-100*time.Millisecond {
+		t.Errorf("download took %v, want at least %v", elapsed, want)
+	}
+	if elapsed > want+2*time.Second {
+		t.Errorf("download took %v, far beyond the expected %v", elapsed, want)
+	}
+}
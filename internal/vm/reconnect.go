@@ -0,0 +1,124 @@
+package vm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// consoleClientWriteTimeout bounds how long console output waits for a
+// reconnected client before the client is dropped, so that a stalled
+// terminal never holds up the VM's own console.
+const consoleClientWriteTimeout = time.Second
+
+// ConsoleSocketPath returns the Unix socket through which other vmterminal
+// processes reconnect to the console of the VM while it runs.
+func ConsoleSocketPath(dataDir string) string {
+	return filepath.Join(dataDir, "console.sock")
+}
+
+// ConsoleServer shares the console of a running VM with the processes that
+// reconnect to it. Console output written to it goes to every connected
+// client, and input from any client goes to the VM.
+type ConsoleServer struct {
+	vmIn io.Writer
+
+	mu      sync.Mutex
+	clients map[net.Conn]struct{}
+}
+
+// NewConsoleServer returns a server that types client input into vmIn, the
+// console input returned by Manager.Console.
+func NewConsoleServer(vmIn io.Writer) *ConsoleServer {
+	return &ConsoleServer{vmIn: vmIn, clients: make(map[net.Conn]struct{})}
+}
+
+// Write sends console output to the connected clients. It never fails:
+// clients that cannot keep up are disconnected.
+func (s *ConsoleServer) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for conn := range s.clients {
+		conn.SetWriteDeadline(time.Now().Add(consoleClientWriteTimeout))
+		if _, err := conn.Write(p); err != nil {
+			conn.Close()
+			delete(s.clients, conn)
+		}
+	}
+	return len(p), nil
+}
+
+// Serve listens on the Unix socket at socketPath until ctx is done, then
+// disconnects all clients. The socket is removed on return.
+func (s *ConsoleServer) Serve(ctx context.Context, socketPath string) error {
+	// A socket left by a VM process that crashed would block Listen
+	if err := os.Remove(socketPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("remove stale console socket: %w", err)
+	}
+	ln, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return fmt.Errorf("listen on console socket: %w", err)
+	}
+	defer os.Remove(socketPath)
+	// Anyone who can connect can type into the guest's console
+	if err := os.Chmod(socketPath, 0600); err != nil {
+		ln.Close()
+		return fmt.Errorf("restrict console socket: %w", err)
+	}
+	defer s.closeClients()
+
+	stop := context.AfterFunc(ctx, func() { ln.Close() })
+	defer stop()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return fmt.Errorf("accept console client: %w", err)
+		}
+		s.mu.Lock()
+		s.clients[conn] = struct{}{}
+		s.mu.Unlock()
+		go s.readClient(conn)
+	}
+}
+
+// readClient types a client's input into the VM until the client leaves.
+func (s *ConsoleServer) readClient(conn net.Conn) {
+	_, _ = io.Copy(s.vmIn, conn)
+	s.mu.Lock()
+	delete(s.clients, conn)
+	s.mu.Unlock()
+	conn.Close()
+}
+
+// closeClients disconnects every client.
+func (s *ConsoleServer) closeClients() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for conn := range s.clients {
+		conn.Close()
+		delete(s.clients, conn)
+	}
+}
+
+// ReconnectConsole connects to the console of the running VM vmName, served
+// by the process that runs it, and returns its input and output like
+// Manager.Console. The output ends when the VM stops. Both are the same
+// connection, which the caller may close through io.Closer.
+func ReconnectConsole(vmName, baseDir string) (io.Writer, io.Reader, error) {
+	socketPath := ConsoleSocketPath(filepath.Join(baseDir, "data", vmName))
+	conn, err := net.Dial("unix", socketPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("reconnect to console of VM '%s': %w", vmName, err)
+	}
+	return conn, conn, nil
+}
@@ -0,0 +1,81 @@
+package vm
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReconnectConsole(t *testing.T) {
+	baseDir := t.TempDir()
+	dataDir := filepath.Join(baseDir, "data", "dev")
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	socketPath := ConsoleSocketPath(dataDir)
+
+	vmInR, vmInW := io.Pipe()
+	srv := NewConsoleServer(vmInW)
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() { errCh <- srv.Serve(ctx, socketPath) }()
+	waitForSocket(t, socketPath)
+
+	in, out, err := ReconnectConsole("dev", baseDir)
+	if err != nil {
+		t.Fatalf("ReconnectConsole: %v", err)
+	}
+
+	// Input typed by the client reaches the VM
+	if _, err := in.Write([]byte("uname\n")); err != nil {
+		t.Fatalf("write input: %v", err)
+	}
+	line, err := bufio.NewReader(vmInR).ReadString('\n')
+	if err != nil || line != "uname\n" {
+		t.Fatalf("VM read %q, %v; want %q", line, err, "uname\n")
+	}
+	if fi, err := os.Stat(socketPath); err != nil {
+		t.Errorf("stat console socket: %v", err)
+	} else if fi.Mode().Perm() != 0600 {
+		t.Errorf("console socket mode = %v, want 0600", fi.Mode().Perm())
+	}
+
+	// Console output reaches the client once it is registered
+	outR := bufio.NewReader(out)
+	got := make(chan string, 1)
+	go func() {
+		line, _ := outR.ReadString('\n')
+		got <- line
+	}()
+	srv.Write([]byte("Linux\n"))
+	select {
+	case line := <-got:
+		if line != "Linux\n" {
+			t.Errorf("client read %q, want %q", line, "Linux\n")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("console output never reached the client")
+	}
+
+	// Stopping the server ends the client's output
+	cancel()
+	if err := <-errCh; err != nil {
+		t.Errorf("Serve: %v", err)
+	}
+	if _, err := outR.ReadString('\n'); err != io.EOF {
+		t.Errorf("read after stop = %v, want EOF", err)
+	}
+	if _, err := os.Stat(socketPath); !os.IsNotExist(err) {
+		t.Errorf("socket not removed after stop: %v", err)
+	}
+}
+
+func TestReconnectConsoleNotServed(t *testing.T) {
+	if _, _, err := ReconnectConsole("dev", t.TempDir()); err == nil {
+		t.Error("ReconnectConsole succeeded without a console server")
+	}
+}
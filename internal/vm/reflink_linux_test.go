@@ -0,0 +1,64 @@
+//go:build linux
+
+package vm
+
+import (
+	"os"
+	"testing"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	fsIocFiemap        = 0xC020660B // FS_IOC_FIEMAP
+	fiemapExtentShared = 0x2000     // FIEMAP_EXTENT_SHARED
+	fiemapMaxExtents   = 32
+)
+
+// fiemapExtent mirrors struct fiemap_extent.
+type fiemapExtent struct {
+	Logical    uint64
+	Physical   uint64
+	Length     uint64
+	reserved64 [2]uint64
+	Flags      uint32
+	reserved   [3]uint32
+}
+
+// fiemap mirrors struct fiemap with room for a fixed number of extents.
+type fiemap struct {
+	Start         uint64
+	Length        uint64
+	Flags         uint32
+	MappedExtents uint32
+	ExtentCount   uint32
+	reserved      uint32
+	Extents       [fiemapMaxExtents]fiemapExtent
+}
+
+// assertSharesBlocks fails the test unless every extent of path is marked
+// shared, which is how the kernel reports reflinked data.
+func assertSharesBlocks(t *testing.T, path string) {
+	t.Helper()
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	fm := fiemap{Length: ^uint64(0), ExtentCount: fiemapMaxExtents}
+	_, _, errno := unix.Syscall(unix.SYS_IOCTL, f.Fd(), fsIocFiemap, uintptr(unsafe.Pointer(&fm)))
+	if errno != 0 {
+		t.Skipf("FIEMAP not supported: %v", errno)
+	}
+	if fm.MappedExtents == 0 {
+		t.Fatal("clone has no mapped extents")
+	}
+	for _, e := range fm.Extents[:fm.MappedExtents] {
+		if e.Flags&fiemapExtentShared == 0 {
+			t.Errorf("extent at %d is not shared with source", e.Logical)
+		}
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !linux && !darwin
+
+package vm
+
+// cloneFile is not supported on this platform.
+func cloneFile(src, dst string) error {
+	return errReflinkUnsupported
+}
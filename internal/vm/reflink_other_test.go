@@ -0,0 +1,8 @@
+//go:build !linux
+
+package vm
+
+import "testing"
+
+// assertSharesBlocks has no portable implementation outside Linux.
+func assertSharesBlocks(t *testing.T, path string) {}
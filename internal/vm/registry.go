@@ -3,10 +3,13 @@ package vm
 import (
 	"encoding/json"
 	"fmt"
+	"net"
 	"os"
 	"path/filepath"
 	"strings"
 	"time"
+
+	"github.com/javanstorm/vmterminal/internal/config"
 )
 
 // VMEntry represents a single VM configuration in the registry.
@@ -17,6 +20,112 @@ type VMEntry struct {
 	MemoryMB   int       `json:"memory_mb"`
 	DiskSizeMB int       `json:"disk_size_mb"`
 	CreatedAt  time.Time `json:"created_at"`
+
+	// KernelArgs are appended to the distro's kernel command line.
+	KernelArgs []string `json:"kernel_args,omitempty"`
+
+	// Hostname is written into the guest before each boot. Empty means
+	// a hostname derived from Name.
+	Hostname string `json:"hostname,omitempty"`
+
+	// Disks are data disks attached after the root disk.
+	Disks []DiskConfig `json:"disks,omitempty"`
+
+	// Template is the template the VM was created from, if any.
+	Template string `json:"template,omitempty"`
+
+	// Config holds per-VM overrides of the global configuration.
+	Config *VMConfig `json:"config,omitempty"`
+}
+
+// EffectiveHostname returns the hostname the guest boots with: Hostname
+// if set, otherwise the VM name made into a valid hostname.
+func (e *VMEntry) EffectiveHostname() string {
+	if e.Hostname != "" {
+		return e.Hostname
+	}
+	return hostnameFromName(e.Name)
+}
+
+// ValidateKernelArg checks that arg can be added to a kernel command line.
+func ValidateKernelArg(arg string) error {
+	if strings.TrimSpace(arg) == "" {
+		return fmt.Errorf("kernel argument must not be empty")
+	}
+	if strings.ContainsAny(arg, "\n\r\x00") {
+		return fmt.Errorf("kernel argument %q must be a single line", arg)
+	}
+	return nil
+}
+
+// SetKernelArg returns args with arg added. An existing argument with the
+// same key (the part before '=') is replaced, so setting
+// "systemd.log_level=info" after "systemd.log_level=debug" keeps only the
+// later value.
+func SetKernelArg(args []string, arg string) []string {
+	key, _, _ := strings.Cut(arg, "=")
+	out := make([]string, 0, len(args)+1)
+	for _, a := range args {
+		if k, _, _ := strings.Cut(a, "="); k != key {
+			out = append(out, a)
+		}
+	}
+	return append(out, arg)
+}
+
+// VMConfig holds per-VM overrides for settings otherwise taken from
+// the global config.State. Nil or empty fields fall back to the global value.
+type VMConfig struct {
+	// EnableNetwork overrides VM networking.
+	EnableNetwork *bool `json:"enable_network,omitempty"`
+
+	// SSHHostPort overrides the host port for SSH forwarding.
+	SSHHostPort *int `json:"ssh_host_port,omitempty"`
+
+	// SharedDirs replaces the global shared directory list.
+	SharedDirs []config.SharedDir `json:"shared_dirs,omitempty"`
+
+	// MACAddress overrides the VM MAC address.
+	MACAddress string `json:"mac_address,omitempty"`
+}
+
+// EffectiveState returns a copy of global with this entry's settings applied.
+// Per-VM values win over global ones; zero values are treated as unset.
+func (e *VMEntry) EffectiveState(global *config.State) *config.State {
+	merged := *global
+	merged.SharedDirs = append([]config.SharedDir(nil), global.SharedDirs...)
+	merged.PortForwards = append([]config.PortForwardRule(nil), global.PortForwards...)
+	merged.Networks = append([]string(nil), global.Networks...)
+
+	if e.Distro != "" {
+		merged.Distro = e.Distro
+	}
+	if e.CPUs > 0 {
+		merged.CPUs = e.CPUs
+	}
+	if e.MemoryMB > 0 {
+		merged.MemoryMB = e.MemoryMB
+	}
+	if e.DiskSizeMB > 0 {
+		merged.DiskSizeMB = e.DiskSizeMB
+	}
+
+	if c := e.Config; c != nil {
+		if c.EnableNetwork != nil {
+			merged.EnableNetwork = *c.EnableNetwork
+		}
+		if c.SSHHostPort != nil {
+			merged.SSHHostPort = *c.SSHHostPort
+		}
+		if len(c.SharedDirs) > 0 {
+			merged.SharedDirs = append([]config.SharedDir(nil), c.SharedDirs...)
+		}
+		if c.MACAddress != "" {
+			merged.MACAddress = c.MACAddress
+		}
+	}
+
+	return &merged
 }
 
 // RegistryData holds the registry file contents.
@@ -58,8 +167,25 @@ func (r *Registry) Load() (*RegistryData, error) {
 	return &reg, nil
 }
 
+// withLock runs fn while holding the registry lock, so a Load, change and
+// save in fn cannot interleave with another process doing the same.
+func (r *Registry) withLock(fn func() error) error {
+	lock, err := AcquireRegistryLock(r.baseDir, registryLockTimeout)
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+	return fn()
+}
+
 // Save writes the registry to disk.
 func (r *Registry) Save(reg *RegistryData) error {
+	return r.withLock(func() error { return r.save(reg) })
+}
+
+// save writes the registry with the lock already held. The file is
+// replaced atomically so readers never see a partial write.
+func (r *Registry) save(reg *RegistryData) error {
 	// Ensure base directory exists
 	if err := os.MkdirAll(r.baseDir, 0755); err != nil {
 		return fmt.Errorf("create directory: %w", err)
@@ -70,7 +196,12 @@ func (r *Registry) Save(reg *RegistryData) error {
 		return fmt.Errorf("marshal registry: %w", err)
 	}
 
-	if err := os.WriteFile(r.registryPath, data, 0644); err != nil {
+	tmp := r.registryPath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("write registry: %w", err)
+	}
+	if err := os.Rename(tmp, r.registryPath); err != nil {
+		os.Remove(tmp)
 		return fmt.Errorf("write registry: %w", err)
 	}
 
@@ -79,6 +210,11 @@ func (r *Registry) Save(reg *RegistryData) error {
 
 // CreateVM adds a new VM to the registry.
 func (r *Registry) CreateVM(entry VMEntry) error {
+	return r.withLock(func() error { return r.createVM(entry) })
+}
+
+// createVM adds a new VM with the lock already held.
+func (r *Registry) createVM(entry VMEntry) error {
 	reg, err := r.Load()
 	if err != nil {
 		return err
@@ -96,7 +232,7 @@ func (r *Registry) CreateVM(entry VMEntry) error {
 
 	reg.VMs = append(reg.VMs, entry)
 
-	if err := r.Save(reg); err != nil {
+	if err := r.save(reg); err != nil {
 		return err
 	}
 
@@ -109,6 +245,58 @@ func (r *Registry) CreateVM(entry VMEntry) error {
 	return nil
 }
 
+// CreateVMWithSSHPort adds a new VM like CreateVM, giving it an SSH host
+// port of its own (see freeSSHPort) unless it already has one. base is the
+// configured ssh_host_port; 0 leaves SSH forwarding off. It returns the
+// port the VM gets.
+func (r *Registry) CreateVMWithSSHPort(entry VMEntry, base int) (int, error) {
+	var port int
+	err := r.withLock(func() error {
+		if entry.Config != nil && entry.Config.SSHHostPort != nil {
+			port = *entry.Config.SSHHostPort
+			return r.createVM(entry)
+		}
+		if base <= 0 {
+			return r.createVM(entry)
+		}
+		reg, err := r.Load()
+		if err != nil {
+			return err
+		}
+		if port, err = freeSSHPort(reg.VMs, base); err != nil {
+			return err
+		}
+		if entry.Config == nil {
+			entry.Config = &VMConfig{}
+		}
+		entry.Config.SSHHostPort = &port
+		return r.createVM(entry)
+	})
+	return port, err
+}
+
+// freeSSHPort returns the first port above base that none of vms forwards
+// SSH from and nothing on the host listens on. base itself is left to the
+// VMs without a port of their own, which all use it.
+func freeSSHPort(vms []VMEntry, base int) (int, error) {
+	used := map[int]bool{base: true}
+	for _, e := range vms {
+		if e.Config != nil && e.Config.SSHHostPort != nil {
+			used[*e.Config.SSHHostPort] = true
+		}
+	}
+	for port := base + 1; port <= 65535; port++ {
+		if used[port] {
+			continue
+		}
+		if l, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port)); err == nil {
+			l.Close()
+			return port, nil
+		}
+	}
+	return 0, fmt.Errorf("no free SSH port above %d", base)
+}
+
 // GetVM returns a VM entry by name.
 func (r *Registry) GetVM(name string) (*VMEntry, error) {
 	reg, err := r.Load()
@@ -122,7 +310,27 @@ func (r *Registry) GetVM(name string) (*VMEntry, error) {
 		}
 	}
 
-	return nil, fmt.Errorf("VM '%s' not found", name)
+	return nil, &ErrVMNotFound{Name: name}
+}
+
+// UpdateVM applies fn to the named VM's entry and saves the registry.
+// Nothing is saved if fn returns an error.
+func (r *Registry) UpdateVM(name string, fn func(*VMEntry) error) error {
+	return r.withLock(func() error {
+		reg, err := r.Load()
+		if err != nil {
+			return err
+		}
+		for i := range reg.VMs {
+			if reg.VMs[i].Name == name {
+				if err := fn(&reg.VMs[i]); err != nil {
+					return err
+				}
+				return r.save(reg)
+			}
+		}
+		return &ErrVMNotFound{Name: name}
+	})
 }
 
 // ListVMs returns all VM entries.
@@ -137,6 +345,11 @@ func (r *Registry) ListVMs() ([]VMEntry, error) {
 
 // DeleteVM removes a VM from the registry.
 func (r *Registry) DeleteVM(name string) error {
+	return r.withLock(func() error { return r.deleteVM(name) })
+}
+
+// deleteVM removes a VM with the lock already held.
+func (r *Registry) deleteVM(name string) error {
 	reg, err := r.Load()
 	if err != nil {
 		return err
@@ -153,7 +366,7 @@ func (r *Registry) DeleteVM(name string) error {
 	}
 
 	if !found {
-		return fmt.Errorf("VM '%s' not found", name)
+		return &ErrVMNotFound{Name: name}
 	}
 
 	reg.VMs = newVMs
@@ -161,14 +374,19 @@ func (r *Registry) DeleteVM(name string) error {
 	// Clear active if this was the active VM
 	active, _ := r.GetActive()
 	if active == name {
-		r.ClearActive()
+		r.clearActive()
 	}
 
-	return r.Save(reg)
+	return r.save(reg)
 }
 
 // SetActive sets the active VM.
 func (r *Registry) SetActive(name string) error {
+	return r.withLock(func() error { return r.setActive(name) })
+}
+
+// setActive sets the active VM with the lock already held.
+func (r *Registry) setActive(name string) error {
 	// Verify VM exists
 	_, err := r.GetVM(name)
 	if err != nil {
@@ -202,6 +420,11 @@ func (r *Registry) GetActive() (string, error) {
 
 // ClearActive removes the active VM setting.
 func (r *Registry) ClearActive() error {
+	return r.withLock(r.clearActive)
+}
+
+// clearActive removes the active VM setting with the lock already held.
+func (r *Registry) clearActive() error {
 	if err := os.Remove(r.activePath); err != nil && !os.IsNotExist(err) {
 		return fmt.Errorf("remove active file: %w", err)
 	}
@@ -213,8 +436,16 @@ func (r *Registry) VMDataDir(name string) string {
 	return filepath.Join(r.baseDir, "data", name)
 }
 
-// EnsureDefault creates a default VM if the registry is empty.
+// EnsureDefault creates a default VM if the registry is empty. The check
+// and creation happen under one lock so concurrent first runs create it once.
 func (r *Registry) EnsureDefault(defaultDistro string, defaultCPUs, defaultMemoryMB, defaultDiskSizeMB int) error {
+	return r.withLock(func() error {
+		return r.ensureDefault(defaultDistro, defaultCPUs, defaultMemoryMB, defaultDiskSizeMB)
+	})
+}
+
+// ensureDefault creates the default VM with the lock already held.
+func (r *Registry) ensureDefault(defaultDistro string, defaultCPUs, defaultMemoryMB, defaultDiskSizeMB int) error {
 	reg, err := r.Load()
 	if err != nil {
 		return err
@@ -234,12 +465,12 @@ func (r *Registry) EnsureDefault(defaultDistro string, defaultCPUs, defaultMemor
 		DiskSizeMB: defaultDiskSizeMB,
 	}
 
-	if err := r.CreateVM(entry); err != nil {
+	if err := r.createVM(entry); err != nil {
 		return fmt.Errorf("create default VM: %w", err)
 	}
 
 	// Set as active
-	if err := r.SetActive("default"); err != nil {
+	if err := r.setActive("default"); err != nil {
 		return fmt.Errorf("set default active: %w", err)
 	}
 
@@ -283,3 +514,80 @@ func (r *Registry) DeleteVMData(name string) error {
 	}
 	return nil
 }
+
+// CloneVM creates a new VM named dst with src's settings and a copy of its
+// data directory. Disk images are cloned with reflinks where the filesystem
+// allows, so cloning is cheap on btrfs, XFS and APFS. The clone gets a fresh
+// MAC address and an SSH port of its own above sshPortBase, as with
+// CreateVMWithSSHPort. It reports whether every disk image was reflinked.
+func (r *Registry) CloneVM(src, dst string, sshPortBase int) (bool, error) {
+	entry, err := r.GetVM(src)
+	if err != nil {
+		return false, err
+	}
+
+	clone := *entry
+	clone.Name = dst
+	if entry.Config != nil {
+		cfg := *entry.Config
+		cfg.SharedDirs = append([]config.SharedDir(nil), entry.Config.SharedDirs...)
+		cfg.MACAddress = ""
+		cfg.SSHHostPort = nil
+		clone.Config = &cfg
+	}
+	// The clone answers to its own name unless given a hostname
+	clone.Hostname = ""
+
+	if _, err := r.CreateVMWithSSHPort(clone, sshPortBase); err != nil {
+		return false, err
+	}
+
+	reflinked, err := r.cloneVMData(src, dst)
+	if err != nil {
+		// Leave no half-populated clone behind
+		r.DeleteVM(dst)
+		r.DeleteVMData(dst)
+		return false, err
+	}
+	return reflinked, nil
+}
+
+// cloneVMData copies the top-level files of src's data directory into dst's.
+// Runtime files such as the PID file are skipped.
+func (r *Registry) cloneVMData(src, dst string) (bool, error) {
+	srcDir := r.VMDataDir(src)
+	dstDir := r.VMDataDir(dst)
+
+	entries, err := os.ReadDir(srcDir)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("read VM data: %w", err)
+	}
+
+	reflinked := true
+	disks := 0
+	for _, e := range entries {
+		if !e.Type().IsRegular() || e.Name() == "vm.pid" {
+			continue
+		}
+		from := filepath.Join(srcDir, e.Name())
+		to := filepath.Join(dstDir, e.Name())
+
+		if filepath.Ext(e.Name()) == ".raw" {
+			disks++
+			ok, err := CopyDisk(from, to)
+			if err != nil {
+				return false, err
+			}
+			reflinked = reflinked && ok
+			continue
+		}
+		if err := copySparse(from, to); err != nil {
+			return false, fmt.Errorf("copy %s: %w", e.Name(), err)
+		}
+	}
+
+	return reflinked && disks > 0, nil
+}
@@ -0,0 +1,318 @@
+package vm
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/javanstorm/vmterminal/internal/config"
+)
+
+func TestVMEntryEffectiveState(t *testing.T) {
+	global := &config.State{
+		Distro:        "alpine",
+		CPUs:          2,
+		MemoryMB:      2048,
+		DiskSizeMB:    10240,
+		SharedDirs:    []config.SharedDir{{Path: "/home/user"}},
+		EnableNetwork: true,
+		SSHHostPort:   2222,
+	}
+
+	t.Run("no overrides", func(t *testing.T) {
+		entry := &VMEntry{Name: "dev"}
+		got := entry.EffectiveState(global)
+		if got.Distro != "alpine" || got.CPUs != 2 || got.SSHHostPort != 2222 || !got.EnableNetwork {
+			t.Errorf("expected global values, got %+v", got)
+		}
+	})
+
+	t.Run("per-VM wins", func(t *testing.T) {
+		network := false
+		port := 2223
+		entry := &VMEntry{
+			Name:     "dev",
+			Distro:   "ubuntu",
+			CPUs:     8,
+			MemoryMB: 8192,
+			Config: &VMConfig{
+				EnableNetwork: &network,
+				SSHHostPort:   &port,
+				SharedDirs:    []config.SharedDir{{Path: "/src", ReadOnly: true}},
+			},
+		}
+		got := entry.EffectiveState(global)
+		if got.Distro != "ubuntu" {
+			t.Errorf("Distro = %q, want ubuntu", got.Distro)
+		}
+		if got.CPUs != 8 || got.MemoryMB != 8192 {
+			t.Errorf("CPUs/MemoryMB = %d/%d, want 8/8192", got.CPUs, got.MemoryMB)
+		}
+		if got.DiskSizeMB != 10240 {
+			t.Errorf("DiskSizeMB = %d, want global 10240", got.DiskSizeMB)
+		}
+		if got.EnableNetwork {
+			t.Error("EnableNetwork should be overridden to false")
+		}
+		if got.SSHHostPort != 2223 {
+			t.Errorf("SSHHostPort = %d, want 2223", got.SSHHostPort)
+		}
+		if len(got.SharedDirs) != 1 || got.SharedDirs[0].Path != "/src" || !got.SharedDirs[0].ReadOnly {
+			t.Errorf("SharedDirs = %v, want [/src:ro]", got.SharedDirs)
+		}
+	})
+
+	t.Run("global not mutated", func(t *testing.T) {
+		entry := &VMEntry{Name: "dev", CPUs: 16, Config: &VMConfig{SharedDirs: []config.SharedDir{{Path: "/x"}}}}
+		entry.EffectiveState(global)
+		if global.CPUs != 2 || global.SharedDirs[0].Path != "/home/user" {
+			t.Errorf("global state was mutated: %+v", global)
+		}
+	})
+}
+
+func TestRegistryPersistsVMConfig(t *testing.T) {
+	reg := NewRegistry(t.TempDir())
+	port := 2300
+	if err := reg.CreateVM(VMEntry{Name: "web", Config: &VMConfig{SSHHostPort: &port}}); err != nil {
+		t.Fatalf("CreateVM: %v", err)
+	}
+
+	entry, err := reg.GetVM("web")
+	if err != nil {
+		t.Fatalf("GetVM: %v", err)
+	}
+	if entry.Config == nil || entry.Config.SSHHostPort == nil || *entry.Config.SSHHostPort != 2300 {
+		t.Errorf("per-VM config not persisted: %+v", entry.Config)
+	}
+}
+
+func TestRegistryCreateVMWithSSHPort(t *testing.T) {
+	reg := NewRegistry(t.TempDir())
+	taken := 2301
+	if err := reg.CreateVM(VMEntry{Name: "a", Config: &VMConfig{SSHHostPort: &taken}}); err != nil {
+		t.Fatalf("CreateVM: %v", err)
+	}
+
+	port, err := reg.CreateVMWithSSHPort(VMEntry{Name: "b"}, 2300)
+	if err != nil {
+		t.Fatalf("CreateVMWithSSHPort: %v", err)
+	}
+	if port <= 2301 {
+		t.Errorf("got port %d, want one above the base and the taken port", port)
+	}
+	entry, err := reg.GetVM("b")
+	if err != nil {
+		t.Fatalf("GetVM: %v", err)
+	}
+	if entry.Config == nil || entry.Config.SSHHostPort == nil || *entry.Config.SSHHostPort != port {
+		t.Errorf("allocated port not persisted: %+v", entry.Config)
+	}
+
+	own := 2400
+	port, err = reg.CreateVMWithSSHPort(VMEntry{Name: "c", Config: &VMConfig{SSHHostPort: &own}}, 2300)
+	if err != nil || port != own {
+		t.Errorf("explicit port: got %d, %v; want %d", port, err, own)
+	}
+}
+
+func TestRegistryCloneVM(t *testing.T) {
+	dir := t.TempDir()
+	reg := NewRegistry(dir)
+
+	port := 2223
+	if err := reg.CreateVM(VMEntry{
+		Name:     "dev",
+		Distro:   "alpine",
+		CPUs:     4,
+		Hostname: "devbox",
+		Config:   &VMConfig{SSHHostPort: &port, MACAddress: "52:54:00:12:34:56"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	srcDir := reg.VMDataDir("dev")
+	if err := os.WriteFile(filepath.Join(srcDir, "disk.raw"), []byte("disk"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(srcDir, "vm.pid"), []byte("123"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := reg.CloneVM("dev", "dev2", 2222); err != nil {
+		t.Fatalf("CloneVM failed: %v", err)
+	}
+
+	clone, err := reg.GetVM("dev2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if clone.Distro != "alpine" || clone.CPUs != 4 {
+		t.Errorf("clone settings = %+v", clone)
+	}
+	if p := clone.Config.SSHHostPort; p == nil || *p <= 2223 {
+		t.Errorf("clone SSH port = %v, want a free port above the source's 2223", p)
+	}
+	if clone.Config.MACAddress != "" {
+		t.Errorf("clone kept MAC address %q", clone.Config.MACAddress)
+	}
+	if got := clone.EffectiveHostname(); got != "dev2" {
+		t.Errorf("clone hostname = %q, want dev2", got)
+	}
+
+	dstDir := reg.VMDataDir("dev2")
+	if data, err := os.ReadFile(filepath.Join(dstDir, "disk.raw")); err != nil || string(data) != "disk" {
+		t.Errorf("cloned disk = %q, %v", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(dstDir, "vm.pid")); !os.IsNotExist(err) {
+		t.Error("PID file should not be cloned")
+	}
+
+	if _, err := reg.CloneVM("dev", "dev2", 2222); err == nil {
+		t.Error("expected error cloning onto existing VM")
+	}
+	if _, err := reg.CloneVM("missing", "dev3", 2222); err == nil {
+		t.Error("expected error cloning unknown VM")
+	}
+}
+
+func TestRegistryConcurrentCreateVM(t *testing.T) {
+	dir := t.TempDir()
+
+	// Each goroutine uses its own Registry, like separate processes would,
+	// and every name is created by two goroutines at once
+	const workers = 20
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	errs := make([]error, workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			errs[i] = NewRegistry(dir).CreateVM(VMEntry{Name: fmt.Sprintf("vm-%d", i%(workers/2))})
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	created := 0
+	for _, err := range errs {
+		if err == nil {
+			created++
+		} else if !strings.Contains(err.Error(), "already exists") {
+			t.Errorf("CreateVM: %v", err)
+		}
+	}
+	if created != workers/2 {
+		t.Errorf("%d creates succeeded, want %d", created, workers/2)
+	}
+
+	vms, err := NewRegistry(dir).ListVMs()
+	if err != nil {
+		t.Fatalf("ListVMs: %v", err)
+	}
+	seen := make(map[string]bool)
+	for _, vm := range vms {
+		if seen[vm.Name] {
+			t.Errorf("duplicate VM %s", vm.Name)
+		}
+		seen[vm.Name] = true
+	}
+	if len(seen) != workers/2 {
+		t.Errorf("registry has %d VMs, want %d", len(seen), workers/2)
+	}
+}
+
+func TestRegistryLockTimeout(t *testing.T) {
+	dir := t.TempDir()
+	held, err := AcquireRegistryLock(dir, time.Second)
+	if err != nil {
+		t.Fatalf("AcquireRegistryLock: %v", err)
+	}
+
+	if _, err := AcquireRegistryLock(dir, 50*time.Millisecond); err == nil {
+		t.Fatal("second lock should time out while the first is held")
+	}
+
+	held.Release()
+	again, err := AcquireRegistryLock(dir, time.Second)
+	if err != nil {
+		t.Fatalf("lock after release: %v", err)
+	}
+	again.Release()
+}
+
+func TestSetKernelArg(t *testing.T) {
+	args := SetKernelArg(nil, "debug")
+	args = SetKernelArg(args, "systemd.log_level=debug")
+	args = SetKernelArg(args, "net.ifnames=0")
+	args = SetKernelArg(args, "systemd.log_level=info")
+
+	want := []string{"debug", "net.ifnames=0", "systemd.log_level=info"}
+	if !reflect.DeepEqual(args, want) {
+		t.Errorf("SetKernelArg() = %q, want %q", args, want)
+	}
+}
+
+func TestValidateKernelArg(t *testing.T) {
+	for _, arg := range []string{"debug", "console=ttyS0,115200", `dyndbg="module virtio_net +p"`} {
+		if err := ValidateKernelArg(arg); err != nil {
+			t.Errorf("ValidateKernelArg(%q) = %v, want nil", arg, err)
+		}
+	}
+	for _, arg := range []string{"", "  ", "a\nb"} {
+		if err := ValidateKernelArg(arg); err == nil {
+			t.Errorf("ValidateKernelArg(%q) = nil, want error", arg)
+		}
+	}
+}
+
+func TestRegistryUpdateVM(t *testing.T) {
+	reg := NewRegistry(t.TempDir())
+	if err := reg.CreateVM(VMEntry{Name: "dev"}); err != nil {
+		t.Fatalf("CreateVM: %v", err)
+	}
+
+	err := reg.UpdateVM("dev", func(e *VMEntry) error {
+		e.KernelArgs = SetKernelArg(e.KernelArgs, "debug")
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("UpdateVM: %v", err)
+	}
+	entry, err := reg.GetVM("dev")
+	if err != nil {
+		t.Fatalf("GetVM: %v", err)
+	}
+	if !reflect.DeepEqual(entry.KernelArgs, []string{"debug"}) {
+		t.Errorf("KernelArgs = %q, want [debug]", entry.KernelArgs)
+	}
+
+	// A failing update leaves the entry untouched
+	err = reg.UpdateVM("dev", func(e *VMEntry) error {
+		e.KernelArgs = nil
+		return errors.New("boom")
+	})
+	if err == nil {
+		t.Fatal("UpdateVM should return fn's error")
+	}
+	if entry, _ := reg.GetVM("dev"); len(entry.KernelArgs) != 1 {
+		t.Errorf("KernelArgs after failed update = %q", entry.KernelArgs)
+	}
+
+	err = reg.UpdateVM("missing", func(*VMEntry) error { return nil })
+	var notFound *ErrVMNotFound
+	if !errors.As(err, &notFound) || notFound.Name != "missing" {
+		t.Errorf("UpdateVM for an unknown VM = %v, want ErrVMNotFound", err)
+	}
+	if _, err := reg.GetVM("missing"); !errors.As(err, &notFound) {
+		t.Errorf("GetVM for an unknown VM = %v, want ErrVMNotFound", err)
+	}
+}
@@ -1,21 +1,46 @@
 package vm
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
+
+	"github.com/javanstorm/vmterminal/internal/progress"
 )
 
 // RootfsManager handles disk formatting and rootfs extraction.
 type RootfsManager struct {
-	dataDir string
+	dataDir  string
+	prog     progress.Progress
+	sshKeys  *SSHKeyManager
+	hostname string
 }
 
 // NewRootfsManager creates a new rootfs manager.
-func NewRootfsManager(dataDir string) *RootfsManager {
-	return &RootfsManager{dataDir: dataDir}
+// Setup progress is reported to prog; nil discards it.
+func NewRootfsManager(dataDir string, prog progress.Progress) *RootfsManager {
+	if prog == nil {
+		prog = progress.SilentProgress{}
+	}
+	return &RootfsManager{dataDir: dataDir, prog: prog}
+}
+
+// WithSSHKey makes ExtractRootfs authorize the public key from keys for
+// root while the freshly extracted rootfs is still mounted.
+func (m *RootfsManager) WithSSHKey(keys *SSHKeyManager) *RootfsManager {
+	m.sshKeys = keys
+	return m
+}
+
+// WithHostname makes ExtractRootfs set the guest hostname while the
+// freshly extracted rootfs is still mounted.
+func (m *RootfsManager) WithHostname(hostname string) *RootfsManager {
+	m.hostname = hostname
+	return m
 }
 
 // SetupState represents the state of rootfs setup.
@@ -26,6 +51,18 @@ type SetupState struct {
 	FSType          string
 }
 
+// importedMarker marks a data directory whose disk was imported ready-made.
+const importedMarker = ".imported"
+
+// MarkImported records that the disk in dataDir was imported, so that
+// CheckSetupState reports it as set up and it is never formatted.
+func MarkImported(dataDir string) error {
+	if err := os.WriteFile(filepath.Join(dataDir, importedMarker), nil, 0644); err != nil {
+		return fmt.Errorf("mark disk imported: %w", err)
+	}
+	return nil
+}
+
 // CheckSetupState checks the current state of rootfs setup.
 func (m *RootfsManager) CheckSetupState(diskName string) (*SetupState, error) {
 	state := &SetupState{}
@@ -41,6 +78,13 @@ func (m *RootfsManager) CheckSetupState(diskName string) (*SetupState, error) {
 	}
 	state.DiskExists = true
 
+	// Imported disks are complete images that the setup flow must not touch
+	if _, err := os.Stat(filepath.Join(m.dataDir, importedMarker)); err == nil {
+		state.DiskFormatted = true
+		state.RootfsExtracted = true
+		return state, nil
+	}
+
 	// Check if disk has a filesystem by looking at its actual size
 	// A sparse file that's been formatted will have allocated blocks
 	if info.Size() > 0 {
@@ -134,7 +178,10 @@ func (m *RootfsManager) ExtractRootfs(diskName, rootfsPath string) error {
 		extractCmd = exec.Command("sudo", "tar", "-xf", rootfsPath, "-C", mountPoint)
 	} else if strings.HasSuffix(rootfsPath, ".qcow2") {
 		// For qcow2 images, we need to use qemu-img and then copy
-		return m.extractQcow2(rootfsPath, mountPoint)
+		if err := m.extractQcow2(rootfsPath, mountPoint); err != nil {
+			return err
+		}
+		return m.customize(diskPath, mountPoint)
 	} else {
 		return fmt.Errorf("unsupported archive format: %s", rootfsPath)
 	}
@@ -146,6 +193,36 @@ func (m *RootfsManager) ExtractRootfs(diskName, rootfsPath string) error {
 		return fmt.Errorf("extract rootfs: %w", err)
 	}
 
+	return m.customize(diskPath, mountPoint)
+}
+
+// customize applies the configured SSH key and hostname to the rootfs
+// of diskPath, mounted at mountPoint.
+func (m *RootfsManager) customize(diskPath, mountPoint string) error {
+	if err := m.injectSSHKey(mountPoint); err != nil {
+		return err
+	}
+	if m.hostname == "" {
+		return nil
+	}
+	if err := InjectHostname(mountPoint, m.hostname); err != nil {
+		return fmt.Errorf("set hostname: %w", err)
+	}
+	return RecordHostname(diskPath, m.hostname)
+}
+
+// injectSSHKey authorizes the configured SSH key in the mounted rootfs and
+// serves SSH on vsock.
+func (m *RootfsManager) injectSSHKey(mountPoint string) error {
+	if m.sshKeys == nil {
+		return nil
+	}
+	if err := m.sshKeys.InjectSSHKey(mountPoint); err != nil {
+		return fmt.Errorf("inject SSH key: %w", err)
+	}
+	if err := InstallVsockSSH(mountPoint); err != nil {
+		return fmt.Errorf("set up SSH over vsock: %w", err)
+	}
 	return nil
 }
 
@@ -238,17 +315,21 @@ func (m *RootfsManager) extractWithNBD(qcow2Path, mountPoint string) error {
 
 // SetupDisk performs full disk setup: format and extract rootfs.
 func (m *RootfsManager) SetupDisk(diskName, fsType, rootfsPath string) error {
-	fmt.Printf("Formatting disk with %s filesystem...\n", fsType)
+	m.prog.Start(fmt.Sprintf("Formatting disk with %s filesystem", fsType), 0)
 	if err := m.FormatDisk(diskName, fsType); err != nil {
+		m.prog.Error(err)
 		return err
 	}
+	m.prog.Complete()
 
-	fmt.Printf("Extracting rootfs from %s...\n", filepath.Base(rootfsPath))
+	m.prog.Start(fmt.Sprintf("Extracting rootfs from %s", filepath.Base(rootfsPath)), 0)
 	if err := m.ExtractRootfs(diskName, rootfsPath); err != nil {
+		m.prog.Error(err)
 		return err
 	}
+	m.prog.Complete()
 
-	fmt.Println("Disk setup complete.")
+	m.prog.Message("Disk setup complete.")
 	return nil
 }
 
@@ -256,3 +337,96 @@ func (m *RootfsManager) SetupDisk(diskName, fsType, rootfsPath string) error {
 func (m *RootfsManager) DiskPath(diskName string) string {
 	return filepath.Join(m.dataDir, diskName+".raw")
 }
+
+// ErrDiskCheckUnsupported is returned by CheckDisk and RepairDisk for
+// filesystems without a known checker, including partitioned cloud images.
+var ErrDiskCheckUnsupported = errors.New("no filesystem checker for this disk")
+
+// DiskCheck is the outcome of running a filesystem checker on a disk image.
+type DiskCheck struct {
+	FSType   string
+	ExitCode int
+	Output   string
+}
+
+// OK reports whether the checker found no problems.
+func (c *DiskCheck) OK() bool {
+	return c.ExitCode == 0
+}
+
+// fsckCommand returns the checker for fsType. Without repair the checker
+// only reports problems and never writes to the disk.
+func fsckCommand(fsType string, repair bool) ([]string, error) {
+	switch fsType {
+	case "ext2", "ext3", "ext4":
+		if repair {
+			return []string{"e2fsck", "-f", "-y"}, nil
+		}
+		return []string{"e2fsck", "-f", "-n"}, nil
+	case "xfs":
+		if repair {
+			return []string{"xfs_repair"}, nil
+		}
+		return []string{"xfs_repair", "-n"}, nil
+	case "btrfs":
+		if repair {
+			return []string{"btrfs", "check", "--repair"}, nil
+		}
+		return []string{"btrfs", "check", "--readonly"}, nil
+	default:
+		return nil, ErrDiskCheckUnsupported
+	}
+}
+
+// RunDiskCheck runs the checker for fsType against the unmounted disk
+// image at diskPath. An empty fsType is detected with blkid. A non-zero
+// ExitCode means the checker found problems; err is only set when the
+// checker could not be run.
+func RunDiskCheck(diskPath, fsType string, repair bool) (*DiskCheck, error) {
+	if _, err := os.Stat(diskPath); err != nil {
+		return nil, fmt.Errorf("disk image: %w", err)
+	}
+	if fsType == "" {
+		fsType, _ = (&RootfsManager{}).detectFSType(diskPath)
+	}
+
+	args, err := fsckCommand(fsType, repair)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := exec.LookPath(args[0]); err != nil {
+		return nil, fmt.Errorf("%s not found; install it to check %s filesystems", args[0], fsType)
+	}
+
+	var out bytes.Buffer
+	cmd := exec.Command(args[0], append(args[1:], diskPath)...)
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+
+	check := &DiskCheck{FSType: fsType}
+	err = cmd.Run()
+	check.Output = strings.TrimSpace(out.String())
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		check.ExitCode = exitErr.ExitCode()
+	} else if err != nil {
+		return nil, fmt.Errorf("run %s: %w", args[0], err)
+	}
+	return check, nil
+}
+
+// CheckDisk checks the filesystem on the disk image at diskPath without
+// mounting or modifying it.
+func CheckDisk(diskPath, fsType string) (ok bool, output string, err error) {
+	check, err := RunDiskCheck(diskPath, fsType, false)
+	if err != nil {
+		return false, "", err
+	}
+	return check.OK(), check.Output, nil
+}
+
+// RepairDisk runs the filesystem checker on diskPath with repairs enabled.
+// The VM must not be running.
+func RepairDisk(diskPath, fsType string) (*DiskCheck, error) {
+	return RunDiskCheck(diskPath, fsType, true)
+}
@@ -1,14 +1,17 @@
 package vm
 
 import (
+	"errors"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
 func TestCheckSetupStateNewDisk(t *testing.T) {
 	dir := t.TempDir()
-	rm := NewRootfsManager(dir)
+	rm := NewRootfsManager(dir, nil)
 
 	// Check state for non-existent disk
 	state, err := rm.CheckSetupState("nonexistent")
@@ -29,7 +32,7 @@ func TestCheckSetupStateNewDisk(t *testing.T) {
 
 func TestCheckSetupStateWithDiskFile(t *testing.T) {
 	dir := t.TempDir()
-	rm := NewRootfsManager(dir)
+	rm := NewRootfsManager(dir, nil)
 
 	// Create an empty disk file
 	diskPath := filepath.Join(dir, "test.raw")
@@ -55,7 +58,7 @@ func TestCheckSetupStateWithDiskFile(t *testing.T) {
 
 func TestRootfsManagerDiskPath(t *testing.T) {
 	dir := t.TempDir()
-	rm := NewRootfsManager(dir)
+	rm := NewRootfsManager(dir, nil)
 
 	tests := []struct {
 		name string
@@ -82,7 +85,7 @@ func TestFormatDisk(t *testing.T) {
 	}
 
 	dir := t.TempDir()
-	rm := NewRootfsManager(dir)
+	rm := NewRootfsManager(dir, nil)
 
 	// Create a disk file first
 	diskPath := filepath.Join(dir, "test.raw")
@@ -118,7 +121,7 @@ func TestFormatDisk(t *testing.T) {
 
 func TestFormatDiskNonExistent(t *testing.T) {
 	dir := t.TempDir()
-	rm := NewRootfsManager(dir)
+	rm := NewRootfsManager(dir, nil)
 
 	// Format non-existent disk should fail
 	err := rm.FormatDisk("nonexistent", "ext4")
@@ -133,7 +136,7 @@ func TestFormatDiskUnsupportedFS(t *testing.T) {
 	}
 
 	dir := t.TempDir()
-	rm := NewRootfsManager(dir)
+	rm := NewRootfsManager(dir, nil)
 
 	// Create a disk file first
 	diskPath := filepath.Join(dir, "test.raw")
@@ -174,3 +177,114 @@ func TestSetupStateFields(t *testing.T) {
 		t.Error("zero SetupState.FSType should be empty")
 	}
 }
+
+func TestCheckSetupStateImported(t *testing.T) {
+	dir := t.TempDir()
+	rm := NewRootfsManager(dir, nil)
+
+	// An imported disk may carry a partition table blkid cannot type
+	if err := os.WriteFile(filepath.Join(dir, "disk.raw"), make([]byte, 4096), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := MarkImported(dir); err != nil {
+		t.Fatalf("MarkImported failed: %v", err)
+	}
+
+	state, err := rm.CheckSetupState("disk")
+	if err != nil {
+		t.Fatalf("CheckSetupState failed: %v", err)
+	}
+	if !state.DiskExists || !state.DiskFormatted || !state.RootfsExtracted {
+		t.Errorf("imported disk should be reported as set up, got %+v", state)
+	}
+}
+
+func TestFsckCommand(t *testing.T) {
+	tests := []struct {
+		fsType string
+		repair bool
+		want   string
+	}{
+		{"ext4", false, "e2fsck -f -n"},
+		{"ext3", true, "e2fsck -f -y"},
+		{"xfs", false, "xfs_repair -n"},
+		{"xfs", true, "xfs_repair"},
+		{"btrfs", false, "btrfs check --readonly"},
+		{"btrfs", true, "btrfs check --repair"},
+	}
+	for _, tt := range tests {
+		args, err := fsckCommand(tt.fsType, tt.repair)
+		if err != nil {
+			t.Fatalf("fsckCommand(%q, %v) error = %v", tt.fsType, tt.repair, err)
+		}
+		if got := strings.Join(args, " "); got != tt.want {
+			t.Errorf("fsckCommand(%q, %v) = %q, want %q", tt.fsType, tt.repair, got, tt.want)
+		}
+	}
+
+	if _, err := fsckCommand("", false); !errors.Is(err, ErrDiskCheckUnsupported) {
+		t.Errorf("fsckCommand(\"\") error = %v, want ErrDiskCheckUnsupported", err)
+	}
+}
+
+func TestCheckDiskMissing(t *testing.T) {
+	_, _, err := CheckDisk(filepath.Join(t.TempDir(), "disk.raw"), "ext4")
+	if err == nil {
+		t.Error("CheckDisk should fail for a missing disk image")
+	}
+}
+
+func TestCheckDiskUnsupported(t *testing.T) {
+	diskPath := filepath.Join(t.TempDir(), "disk.raw")
+	if err := os.WriteFile(diskPath, make([]byte, 1024), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, err := CheckDisk(diskPath, "ntfs")
+	if !errors.Is(err, ErrDiskCheckUnsupported) {
+		t.Errorf("CheckDisk() error = %v, want ErrDiskCheckUnsupported", err)
+	}
+}
+
+func TestCheckDiskExt4(t *testing.T) {
+	if _, err := exec.LookPath("mkfs.ext4"); err != nil {
+		t.Skip("mkfs.ext4 not available")
+	}
+	if _, err := exec.LookPath("e2fsck"); err != nil {
+		t.Skip("e2fsck not available")
+	}
+
+	diskPath := filepath.Join(t.TempDir(), "disk.raw")
+	if err := os.WriteFile(diskPath, make([]byte, 8<<20), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := exec.Command("mkfs.ext4", "-q", "-F", diskPath).CombinedOutput(); err != nil {
+		t.Fatalf("mkfs.ext4: %v: %s", err, out)
+	}
+
+	ok, output, err := CheckDisk(diskPath, "ext4")
+	if err != nil {
+		t.Fatalf("CheckDisk() error = %v", err)
+	}
+	if !ok {
+		t.Errorf("CheckDisk() reported problems on a fresh filesystem:\n%s", output)
+	}
+
+	// Clobber the superblock so e2fsck has something to report
+	f, err := os.OpenFile(diskPath, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteAt(make([]byte, 1024), 1024); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	check, err := RunDiskCheck(diskPath, "ext4", false)
+	if err != nil {
+		t.Fatalf("RunDiskCheck() error = %v", err)
+	}
+	if check.OK() {
+		t.Error("RunDiskCheck() should report a corrupted superblock")
+	}
+}
@@ -0,0 +1,217 @@
+package vm
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// s3DefaultRegion is used when neither the environment nor ~/.aws/config
+// names a region.
+const s3DefaultRegion = "us-east-1"
+
+// s3MaxPutSize is the largest object a single PUT may upload; larger
+// objects are sent in s3PartSize parts. Variables so tests can lower them.
+var (
+	s3MaxPutSize int64 = 5 << 30
+	s3PartSize   int64 = 512 << 20
+)
+
+// s3Location is a bucket and object key parsed from an s3:// URI.
+type s3Location struct {
+	Bucket string
+	Key    string
+}
+
+// String returns the location as an s3:// URI.
+func (l s3Location) String() string {
+	return "s3://" + l.Bucket + "/" + l.Key
+}
+
+// parseS3URI splits an "s3://bucket/key" URI. The key may be empty.
+func parseS3URI(uri string) (s3Location, error) {
+	rest, ok := strings.CutPrefix(uri, "s3://")
+	if !ok {
+		return s3Location{}, fmt.Errorf("invalid S3 URI %q: expected s3://<bucket>/<key>", uri)
+	}
+	bucket, key, _ := strings.Cut(rest, "/")
+	if bucket == "" {
+		return s3Location{}, fmt.Errorf("invalid S3 URI %q: missing bucket", uri)
+	}
+	return s3Location{Bucket: bucket, Key: key}, nil
+}
+
+// s3Client talks to AWS S3 or an S3-compatible store.
+type s3Client struct {
+	api *s3.Client
+}
+
+// newS3Client configures a client from the standard AWS sources, as the
+// AWS CLI does: credentials from the environment, AWS_PROFILE in
+// ~/.aws/credentials, SSO or an instance role; the region from AWS_REGION
+// or ~/.aws/config; and an S3-compatible endpoint from AWS_ENDPOINT_URL_S3
+// or AWS_ENDPOINT_URL, which is addressed path-style.
+func newS3Client(ctx context.Context) (*s3Client, error) {
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("load AWS config: %w", err)
+	}
+	if cfg.Region == "" {
+		cfg.Region = s3DefaultRegion
+	}
+	if _, err := cfg.Credentials.Retrieve(ctx); err != nil {
+		return nil, fmt.Errorf("no AWS credentials: set AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY or configure a profile in ~/.aws: %w", err)
+	}
+
+	endpoint := os.Getenv("AWS_ENDPOINT_URL_S3")
+	if endpoint == "" && cfg.BaseEndpoint != nil {
+		endpoint = *cfg.BaseEndpoint
+	}
+	return newS3ClientFromConfig(cfg, endpoint), nil
+}
+
+// newS3ClientFromConfig returns a client for cfg, sending requests to
+// endpoint path-style if it is not empty.
+func newS3ClientFromConfig(cfg aws.Config, endpoint string) *s3Client {
+	// Snapshots carry their own SHA-256, so skip the SDK's extra checksums,
+	// which would need the upload to be buffered or chunk-encoded
+	cfg.RequestChecksumCalculation = aws.RequestChecksumCalculationWhenRequired
+	cfg.ResponseChecksumValidation = aws.ResponseChecksumValidationWhenRequired
+
+	return &s3Client{api: s3.NewFromConfig(cfg, func(o *s3.Options) {
+		if endpoint != "" {
+			o.BaseEndpoint = aws.String(endpoint)
+			o.UsePathStyle = true
+		}
+	})}
+}
+
+// putObject uploads size bytes from body to loc with user metadata and
+// object tags. Objects over s3MaxPutSize are uploaded in parts. The body is
+// streamed rather than hashed first, so uploads are sent UNSIGNED-PAYLOAD.
+func (c *s3Client) putObject(ctx context.Context, loc s3Location, body io.Reader, size int64, meta map[string]string, tags url.Values) error {
+	if size > s3MaxPutSize {
+		return c.putMultipart(ctx, loc, body, size, meta, tags)
+	}
+
+	_, err := c.api.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:        aws.String(loc.Bucket),
+		Key:           aws.String(loc.Key),
+		Body:          body,
+		ContentLength: aws.Int64(size),
+		Metadata:      meta,
+		Tagging:       aws.String(tags.Encode()),
+	}, s3.WithAPIOptions(v4.SwapComputePayloadSHA256ForUnsignedPayloadMiddleware))
+	if err != nil {
+		return fmt.Errorf("upload %s: %w", loc, err)
+	}
+	return nil
+}
+
+// putMultipart uploads body to loc with a multipart upload, aborting the
+// upload if any part fails.
+func (c *s3Client) putMultipart(ctx context.Context, loc s3Location, body io.Reader, size int64, meta map[string]string, tags url.Values) error {
+	created, err := c.api.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+		Bucket:   aws.String(loc.Bucket),
+		Key:      aws.String(loc.Key),
+		Metadata: meta,
+		Tagging:  aws.String(tags.Encode()),
+	})
+	if err != nil {
+		return fmt.Errorf("start upload of %s: %w", loc, err)
+	}
+	uploadID := created.UploadId
+
+	var parts []types.CompletedPart
+	for offset, number := int64(0), int32(1); offset < size; offset, number = offset+s3PartSize, number+1 {
+		partSize := s3PartSize
+		if rest := size - offset; rest < partSize {
+			partSize = rest
+		}
+		part, err := c.api.UploadPart(ctx, &s3.UploadPartInput{
+			Bucket:        aws.String(loc.Bucket),
+			Key:           aws.String(loc.Key),
+			UploadId:      uploadID,
+			PartNumber:    aws.Int32(number),
+			Body:          io.LimitReader(body, partSize),
+			ContentLength: aws.Int64(partSize),
+		}, s3.WithAPIOptions(v4.SwapComputePayloadSHA256ForUnsignedPayloadMiddleware))
+		if err != nil {
+			c.abortMultipart(loc, uploadID)
+			return fmt.Errorf("upload part %d of %s: %w", number, loc, err)
+		}
+		parts = append(parts, types.CompletedPart{PartNumber: aws.Int32(number), ETag: part.ETag})
+	}
+
+	_, err = c.api.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(loc.Bucket),
+		Key:             aws.String(loc.Key),
+		UploadId:        uploadID,
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: parts},
+	})
+	if err != nil {
+		c.abortMultipart(loc, uploadID)
+		return fmt.Errorf("complete upload of %s: %w", loc, err)
+	}
+	return nil
+}
+
+// abortMultipart discards the parts of a failed multipart upload.
+func (c *s3Client) abortMultipart(loc s3Location, uploadID *string) {
+	c.api.AbortMultipartUpload(context.Background(), &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(loc.Bucket),
+		Key:      aws.String(loc.Key),
+		UploadId: uploadID,
+	})
+}
+
+// getObject starts downloading loc. The caller closes the output's Body.
+func (c *s3Client) getObject(ctx context.Context, loc s3Location) (*s3.GetObjectOutput, error) {
+	out, err := c.api.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(loc.Bucket),
+		Key:    aws.String(loc.Key),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("download %s: %w", loc, err)
+	}
+	return out, nil
+}
+
+// getObjectTags returns the tags of loc.
+func (c *s3Client) getObjectTags(ctx context.Context, loc s3Location) (map[string]string, error) {
+	out, err := c.api.GetObjectTagging(ctx, &s3.GetObjectTaggingInput{
+		Bucket: aws.String(loc.Bucket),
+		Key:    aws.String(loc.Key),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("read tags of %s: %w", loc, err)
+	}
+	tags := make(map[string]string, len(out.TagSet))
+	for _, t := range out.TagSet {
+		tags[aws.ToString(t.Key)] = aws.ToString(t.Value)
+	}
+	return tags, nil
+}
+
+// objectMeta returns the user metadata value for key, which the SDK may
+// hand back in any case.
+func objectMeta(meta map[string]string, key string) string {
+	if v, ok := meta[key]; ok {
+		return v
+	}
+	for k, v := range meta {
+		if strings.EqualFold(k, key) {
+			return v
+		}
+	}
+	return ""
+}
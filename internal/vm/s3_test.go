@@ -0,0 +1,192 @@
+package vm
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+)
+
+func TestParseS3URI(t *testing.T) {
+	loc, err := parseS3URI("s3://backups/vms/dev")
+	if err != nil || loc.Bucket != "backups" || loc.Key != "vms/dev" {
+		t.Errorf("parseS3URI = %+v, %v", loc, err)
+	}
+	for _, uri := range []string{"backups/vms", "s3://", "s3:///key", "https://backups/vms"} {
+		if _, err := parseS3URI(uri); err == nil {
+			t.Errorf("expected error for %q", uri)
+		}
+	}
+}
+
+func TestNewS3ClientFromProfile(t *testing.T) {
+	dir := t.TempDir()
+	creds := filepath.Join(dir, "credentials")
+	cfg := filepath.Join(dir, "config")
+	os.WriteFile(creds, []byte("[work]\naws_access_key_id = AKIAWORK\naws_secret_access_key = secret\n"), 0600)
+	os.WriteFile(cfg, []byte("[profile work]\nregion = eu-central-1\n"), 0600)
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "")
+	t.Setenv("AWS_REGION", "")
+	t.Setenv("AWS_DEFAULT_REGION", "")
+	t.Setenv("AWS_ENDPOINT_URL", "")
+	t.Setenv("AWS_ENDPOINT_URL_S3", "")
+	t.Setenv("AWS_PROFILE", "work")
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", creds)
+	t.Setenv("AWS_CONFIG_FILE", cfg)
+	t.Setenv("AWS_EC2_METADATA_DISABLED", "true")
+
+	c, err := newS3Client(t.Context())
+	if err != nil {
+		t.Fatalf("newS3Client: %v", err)
+	}
+	opts := c.api.Options()
+	keys, err := opts.Credentials.Retrieve(t.Context())
+	if err != nil || keys.AccessKeyID != "AKIAWORK" || opts.Region != "eu-central-1" || opts.BaseEndpoint != nil {
+		t.Errorf("client region = %q, endpoint = %v, credentials = %+v, %v", opts.Region, opts.BaseEndpoint, keys, err)
+	}
+
+	t.Setenv("AWS_PROFILE", "missing")
+	if _, err := newS3Client(t.Context()); err == nil {
+		t.Error("expected error without credentials")
+	}
+}
+
+// fakeS3 is an in-memory S3 server supporting the requests s3Client makes.
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	meta    map[string]http.Header
+	tags    map[string]url.Values
+	parts   map[string]map[int][]byte // By upload ID
+	uploads int
+}
+
+func newFakeS3(t *testing.T) (*fakeS3, *s3Client) {
+	f := &fakeS3{
+		objects: map[string][]byte{},
+		meta:    map[string]http.Header{},
+		tags:    map[string]url.Values{},
+		parts:   map[string]map[int][]byte{},
+	}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+
+	return f, newS3ClientFromConfig(aws.Config{
+		Region:      "us-east-1",
+		Credentials: credentials.NewStaticCredentialsProvider("AKIATEST", "secret", ""),
+		HTTPClient:  srv.Client(),
+	}, srv.URL)
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKIATEST/") {
+		http.Error(w, "unsigned request", http.StatusForbidden)
+		return
+	}
+	key := r.URL.Path
+	q := r.URL.Query()
+	body, _ := io.ReadAll(r.Body)
+
+	switch {
+	case r.Method == http.MethodPost && q.Has("uploads"):
+		f.uploads++
+		id := strconv.Itoa(f.uploads)
+		f.parts[id] = map[int][]byte{}
+		f.meta[key] = r.Header.Clone()
+		f.tags[key], _ = url.ParseQuery(r.Header.Get("X-Amz-Tagging"))
+		fmt.Fprintf(w, "<InitiateMultipartUploadResult><UploadId>%s</UploadId></InitiateMultipartUploadResult>", id)
+	case r.Method == http.MethodPut && q.Has("partNumber"):
+		n, _ := strconv.Atoi(q.Get("partNumber"))
+		f.parts[q.Get("uploadId")][n] = body
+		w.Header().Set("ETag", fmt.Sprintf(`"etag-%d"`, n))
+	case r.Method == http.MethodPost && q.Has("uploadId"):
+		var done struct {
+			Parts []struct {
+				PartNumber int
+				ETag       string
+			} `xml:"Part"`
+		}
+		xml.Unmarshal(body, &done)
+		var data []byte
+		for _, p := range done.Parts {
+			data = append(data, f.parts[q.Get("uploadId")][p.PartNumber]...)
+		}
+		f.objects[key] = data
+		fmt.Fprint(w, "<CompleteMultipartUploadResult><ETag>\"etag\"</ETag></CompleteMultipartUploadResult>")
+	case r.Method == http.MethodPut:
+		f.objects[key] = body
+		f.meta[key] = r.Header.Clone()
+		f.tags[key], _ = url.ParseQuery(r.Header.Get("X-Amz-Tagging"))
+	case r.Method == http.MethodGet && q.Has("tagging"):
+		if _, ok := f.objects[key]; !ok {
+			http.Error(w, "<Error><Code>NoSuchKey</Code><Message>The specified key does not exist.</Message></Error>", http.StatusNotFound)
+			return
+		}
+		keys := make([]string, 0, len(f.tags[key]))
+		for k := range f.tags[key] {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		fmt.Fprint(w, "<Tagging><TagSet>")
+		for _, k := range keys {
+			fmt.Fprintf(w, "<Tag><Key>%s</Key><Value>%s</Value></Tag>", k, f.tags[key].Get(k))
+		}
+		fmt.Fprint(w, "</TagSet></Tagging>")
+	case r.Method == http.MethodGet:
+		data, ok := f.objects[key]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		for k, v := range f.meta[key] {
+			if strings.HasPrefix(k, "X-Amz-Meta-") {
+				w.Header()[k] = v
+			}
+		}
+		w.Write(data)
+	default:
+		http.Error(w, "unsupported", http.StatusBadRequest)
+	}
+}
+
+func TestS3PutObjectMultipart(t *testing.T) {
+	origMax, origPart := s3MaxPutSize, s3PartSize
+	s3MaxPutSize, s3PartSize = 10, 4
+	defer func() { s3MaxPutSize, s3PartSize = origMax, origPart }()
+
+	fake, c := newFakeS3(t)
+	loc := s3Location{Bucket: "backups", Key: "big.raw.gz"}
+	data := []byte("0123456789abcdefghij")
+	err := c.putObject(t.Context(), loc, bytes.NewReader(data), int64(len(data)), map[string]string{"Codec": "gzip"}, url.Values{"sha256": {"abc"}})
+	if err != nil {
+		t.Fatalf("putObject: %v", err)
+	}
+
+	if got := fake.objects["/backups/big.raw.gz"]; !bytes.Equal(got, data) {
+		t.Errorf("object = %q, want %q", got, data)
+	}
+	if got := len(fake.parts["1"]); got != 5 {
+		t.Errorf("uploaded %d parts, want 5", got)
+	}
+	if got := fake.tags["/backups/big.raw.gz"].Get("sha256"); got != "abc" {
+		t.Errorf("sha256 tag = %q", got)
+	}
+}
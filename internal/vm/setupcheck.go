@@ -0,0 +1,223 @@
+package vm
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/javanstorm/vmterminal/internal/distro"
+)
+
+// SetupConfig describes what setting up a VM disk needs, for DryRunSetup.
+type SetupConfig struct {
+	// URLs are the downloads setup makes. iso: and tar: URLs are checked
+	// by the archive they point into.
+	URLs []string
+
+	// Tools are the host commands setup runs, e.g. mkfs.ext4.
+	Tools []string
+
+	// Dir is where the disk image is created. It need not exist yet.
+	Dir string
+
+	// DiskBytes is the size of the disk image. The sizes of the downloads
+	// are added to it when the server reports them.
+	DiskBytes int64
+
+	// NeedsSudo is set when setup formats and mounts the disk with sudo.
+	NeedsSudo bool
+
+	// Proxy is the download proxy, as for NewHTTPClient.
+	Proxy string
+}
+
+// CheckResult is the outcome of one DryRunSetup check.
+type CheckResult struct {
+	Name   string `json:"name"`
+	OK     bool   `json:"ok"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// urlCheckTimeout bounds each HEAD request of DryRunSetup.
+const urlCheckTimeout = 15 * time.Second
+
+// sudoCheck runs sudo without prompting for a password. Tests replace it.
+var sudoCheck = func() error {
+	return exec.Command("sudo", "-n", "true").Run()
+}
+
+// DryRunSetup checks that setup as described by cfg can succeed, without
+// downloading or writing anything: that the downloads are reachable, the
+// tools are installed, Dir has room for the disk image and the downloads,
+// and sudo works without a password prompt.
+func DryRunSetup(cfg SetupConfig) []CheckResult {
+	var results []CheckResult
+	client := NewHTTPClient(cfg.Proxy)
+	need := cfg.DiskBytes
+	for _, u := range cfg.URLs {
+		size, err := headURL(client, archiveURL(u))
+		res := CheckResult{Name: "download " + archiveURL(u), OK: err == nil}
+		if err != nil {
+			res.Detail = err.Error()
+		}
+		if size > 0 {
+			need += size
+		}
+		results = append(results, res)
+	}
+
+	for _, tool := range cfg.Tools {
+		res := CheckResult{Name: "tool " + tool, OK: true}
+		if path, err := exec.LookPath(tool); err != nil {
+			res.OK = false
+			res.Detail = "not found in PATH"
+		} else {
+			res.Detail = path
+		}
+		results = append(results, res)
+	}
+
+	if cfg.Dir != "" {
+		results = append(results, checkFreeSpace(cfg.Dir, need))
+	}
+
+	if cfg.NeedsSudo {
+		res := CheckResult{Name: "sudo", OK: true}
+		if err := sudoCheck(); err != nil {
+			res.OK = false
+			res.Detail = "sudo -n true failed; run 'sudo -v' first or set up passwordless sudo"
+		}
+		results = append(results, res)
+	}
+	return results
+}
+
+// SetupChecksPassed reports whether every check in results passed.
+func SetupChecksPassed(results []CheckResult) bool {
+	for _, r := range results {
+		if !r.OK {
+			return false
+		}
+	}
+	return true
+}
+
+// SetupConfigFor returns the SetupConfig for setting up a disk of
+// diskSizeMB in dataDir with the distro of assets. Downloads and the tools
+// that unpack them are left out if the assets are already cached.
+func SetupConfigFor(assets *AssetManager, dataDir string, diskSizeMB int64) (SetupConfig, error) {
+	cfg := SetupConfig{Dir: dataDir}
+	provider := assets.Provider()
+
+	urls, err := provider.AssetURLs(distro.CurrentArch())
+	if err != nil {
+		return cfg, fmt.Errorf("get asset URLs: %w", err)
+	}
+	reqs := provider.SetupRequirements()
+	locator := provider.KernelLocator()
+	bootsImage := (reqs != nil && !reqs.NeedsExtraction) || provider.BootConfig(distro.CurrentArch()).UEFI
+
+	if cached, _ := assets.AssetsExist(); !cached {
+		seen := make(map[string]bool)
+		for _, u := range []string{urls.Rootfs, urls.SignatureURL, urls.Kernel, urls.Initrd} {
+			if u == "" || seen[archiveURL(u)] {
+				continue
+			}
+			seen[archiveURL(u)] = true
+			cfg.URLs = append(cfg.URLs, u)
+			if strings.HasPrefix(u, "iso:") {
+				cfg.Tools = appendTool(cfg.Tools, "bsdtar")
+			}
+		}
+		if locator != nil && locator.ArchiveType == "qcow2" {
+			cfg.Tools = appendTool(cfg.Tools, "guestfish")
+			if bootsImage {
+				cfg.Tools = appendTool(cfg.Tools, "qemu-img")
+			}
+		}
+	}
+
+	// Cloud images are the disk themselves and are never formatted
+	if bootsImage {
+		return cfg, nil
+	}
+	fsType := "ext4"
+	if reqs != nil && reqs.FSType != "" {
+		fsType = reqs.FSType
+	}
+	cfg.Tools = appendTool(cfg.Tools, "mkfs."+fsType)
+	cfg.DiskBytes = diskSizeMB * 1024 * 1024
+	cfg.NeedsSudo = true
+	return cfg, nil
+}
+
+// appendTool appends tool to tools unless it is already there.
+func appendTool(tools []string, tool string) []string {
+	for _, t := range tools {
+		if t == tool {
+			return tools
+		}
+	}
+	return append(tools, tool)
+}
+
+// archiveURL returns the URL actually downloaded for an asset URL: the
+// archive for iso: and tar: URLs, otherwise u itself.
+func archiveURL(u string) string {
+	for _, scheme := range []string{"iso:", "tar:"} {
+		if rest, ok := strings.CutPrefix(u, scheme); ok {
+			archive, _, _ := strings.Cut(rest, "#")
+			return archive
+		}
+	}
+	return u
+}
+
+// headURL sends a HEAD request for u and returns the reported size, or -1
+// if the server does not report one.
+func headURL(client *http.Client, u string) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), urlCheckTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, u, nil)
+	if err != nil {
+		return -1, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return -1, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return -1, fmt.Errorf("HTTP %s", resp.Status)
+	}
+	return resp.ContentLength, nil
+}
+
+// checkFreeSpace checks that the filesystem holding dir, or its nearest
+// existing parent, has need bytes free.
+func checkFreeSpace(dir string, need int64) CheckResult {
+	res := CheckResult{Name: "free space in " + dir}
+	for {
+		if _, err := os.Stat(dir); err == nil {
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+	free, err := freeBytes(dir)
+	if err != nil {
+		res.Detail = err.Error()
+		return res
+	}
+	res.OK = free >= need
+	res.Detail = fmt.Sprintf("%d MB free, %d MB needed", free>>20, need>>20)
+	return res
+}
@@ -0,0 +1,74 @@
+package vm
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDryRunSetup(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodHead {
+			t.Errorf("dry run sent %s %s, want only HEAD requests", r.Method, r.URL.Path)
+		}
+		if r.URL.Path == "/missing.tar.gz" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Length", "1048576")
+	}))
+	defer srv.Close()
+
+	orig := sudoCheck
+	t.Cleanup(func() { sudoCheck = orig })
+	sudoCheck = func() error { return errors.New("a password is required") }
+
+	results := DryRunSetup(SetupConfig{
+		URLs:      []string{srv.URL + "/rootfs.tar.gz", "iso:" + srv.URL + "/missing.tar.gz#/boot/vmlinuz"},
+		Tools:     []string{"sh", "vmterminal-no-such-tool"},
+		Dir:       t.TempDir() + "/data/default",
+		DiskBytes: 1 << 20,
+		NeedsSudo: true,
+	})
+
+	want := []struct {
+		name string
+		ok   bool
+	}{
+		{"download " + srv.URL + "/rootfs.tar.gz", true},
+		{"download " + srv.URL + "/missing.tar.gz", false},
+		{"tool sh", true},
+		{"tool vmterminal-no-such-tool", false},
+		{"free space in ", true},
+		{"sudo", false},
+	}
+	if len(results) != len(want) {
+		t.Fatalf("DryRunSetup returned %d results, want %d: %+v", len(results), len(want), results)
+	}
+	for i, w := range want {
+		if !strings.HasPrefix(results[i].Name, w.name) || results[i].OK != w.ok {
+			t.Errorf("result %d = %+v, want %s OK=%v", i, results[i], w.name, w.ok)
+		}
+	}
+	if !strings.Contains(results[4].Detail, "2 MB needed") {
+		t.Errorf("free space detail = %q, want the disk plus the download", results[4].Detail)
+	}
+	if SetupChecksPassed(results) {
+		t.Error("SetupChecksPassed with failed checks = true")
+	}
+}
+
+func TestArchiveURL(t *testing.T) {
+	tests := map[string]string{
+		"https://example.com/rootfs.tar.gz":              "https://example.com/rootfs.tar.gz",
+		"iso:https://example.com/x.iso#/boot/vmlinuz":    "https://example.com/x.iso",
+		"tar:https://example.com/rootfs.tar#boot/initrd": "https://example.com/rootfs.tar",
+	}
+	for in, want := range tests {
+		if got := archiveURL(in); got != want {
+			t.Errorf("archiveURL(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
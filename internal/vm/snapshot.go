@@ -1,14 +1,19 @@
 package vm
 
 import (
-	"compress/gzip"
 	"crypto/sha256"
 	"encoding/json"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
 	"time"
+
+	"github.com/javanstorm/vmterminal/internal/progress"
 )
 
 // SnapshotEntry represents a single VM snapshot.
@@ -19,6 +24,77 @@ type SnapshotEntry struct {
 	CreatedAt   time.Time `json:"created_at"`
 	DiskSize    int64     `json:"disk_size"` // Original uncompressed size in bytes
 	Checksum    string    `json:"checksum"`  // SHA256 of compressed file
+
+	// DiskAllocated is the bytes the disk occupied on the host when the
+	// snapshot was taken; 0 for snapshots that predate recording it.
+	DiskAllocated int64 `json:"disk_allocated,omitempty"`
+
+	// Codec is the compression codec; empty means gzip, which is what
+	// snapshots created before codecs were recorded use.
+	Codec string `json:"codec,omitempty"`
+
+	// CompressionLevel is the level the snapshot was written at, 0 for the
+	// codec's default.
+	CompressionLevel int `json:"compression_level,omitempty"`
+
+	// Scheduled marks snapshots taken by a snapshot schedule rather than
+	// by hand; only these count towards the schedule's retention limit.
+	Scheduled bool `json:"scheduled,omitempty"`
+
+	// DiskChecksum is the SHA256 of the uncompressed disk. It is empty for
+	// snapshots that predate recording it and for pulled snapshots.
+	DiskChecksum string `json:"disk_checksum,omitempty"`
+
+	// IsDeltaRestore marks snapshots that can take part in DeltaRestore:
+	// their DiskChecksum lets the current disk be checked against them.
+	IsDeltaRestore bool `json:"delta_restore,omitempty"`
+}
+
+// CompressionCodec returns the snapshot's codec, defaulting to gzip.
+func (e *SnapshotEntry) CompressionCodec() string {
+	return codecName(e.Codec)
+}
+
+// snapshotOptions holds the settings applied by SnapshotOption.
+type snapshotOptions struct {
+	codec     string
+	level     int
+	scheduled bool
+	diskPath  string
+}
+
+// SnapshotOption configures CreateSnapshot.
+type SnapshotOption func(*snapshotOptions)
+
+// WithCompressionLevel sets the compression level: 1 (fastest, largest)
+// through 9 (slowest, smallest) for gzip, up to 19 for zstd.
+func WithCompressionLevel(level int) SnapshotOption {
+	return func(o *snapshotOptions) {
+		o.level = level
+	}
+}
+
+// WithCompressionCodec selects the codec, CodecGzip (default) or CodecZstd.
+// zstd needs the zstd tool on the host.
+func WithCompressionCodec(codec string) SnapshotOption {
+	return func(o *snapshotOptions) {
+		o.codec = codec
+	}
+}
+
+// WithDiskPath snapshots the disk at path instead of the VM's default
+// disk.raw, for VMs that boot from another image.
+func WithDiskPath(path string) SnapshotOption {
+	return func(o *snapshotOptions) {
+		o.diskPath = path
+	}
+}
+
+// WithScheduled marks the snapshot as taken by a snapshot schedule.
+func WithScheduled() SnapshotOption {
+	return func(o *snapshotOptions) {
+		o.scheduled = true
+	}
 }
 
 // SnapshotData holds all snapshots for a VM.
@@ -26,14 +102,37 @@ type SnapshotData struct {
 	Snapshots []SnapshotEntry `json:"snapshots"`
 }
 
+// RetentionPolicy limits how many snapshots are kept for a VM.
+// Zero fields mean no limit.
+type RetentionPolicy struct {
+	MaxCount   int `json:"max_count,omitempty"`
+	MaxAgeDays int `json:"max_age_days,omitempty"`
+}
+
+// IsZero reports whether the policy sets no limits.
+func (p RetentionPolicy) IsZero() bool {
+	return p.MaxCount <= 0 && p.MaxAgeDays <= 0
+}
+
 // SnapshotManager handles VM disk snapshots.
 type SnapshotManager struct {
-	baseDir string // ~/.vmterminal
+	baseDir   string // ~/.vmterminal
+	prog      progress.Progress
+	retention RetentionPolicy
 }
 
 // NewSnapshotManager creates a new snapshot manager.
-func NewSnapshotManager(baseDir string) *SnapshotManager {
-	return &SnapshotManager{baseDir: baseDir}
+// Compression progress is reported to prog; nil discards it.
+func NewSnapshotManager(baseDir string, prog progress.Progress) *SnapshotManager {
+	if prog == nil {
+		prog = progress.SilentProgress{}
+	}
+	return &SnapshotManager{baseDir: baseDir, prog: prog}
+}
+
+// SetRetention sets the policy applied after every CreateSnapshot.
+func (m *SnapshotManager) SetRetention(policy RetentionPolicy) {
+	m.retention = policy
 }
 
 // snapshotsDir returns the snapshots directory for a VM.
@@ -52,8 +151,9 @@ func (m *SnapshotManager) diskPath(vmName string) string {
 }
 
 // snapshotPath returns the path to a specific snapshot file.
-func (m *SnapshotManager) snapshotPath(vmName, snapshotName string) string {
-	return filepath.Join(m.snapshotsDir(vmName), snapshotName+".raw.gz")
+// The extension depends on the codec: .raw.gz for gzip, .raw.zst for zstd.
+func (m *SnapshotManager) snapshotPath(vmName, snapshotName, codec string) string {
+	return filepath.Join(m.snapshotsDir(vmName), snapshotName+codecExt(codec))
 }
 
 // Load reads the snapshot metadata from disk.
@@ -106,12 +206,26 @@ func (m *SnapshotManager) Save(vmName string, data *SnapshotData) error {
 
 // CreateSnapshot creates a new snapshot by compressing the VM disk.
 // Uses atomic temp file + rename to prevent corruption on interrupted writes.
-func (m *SnapshotManager) CreateSnapshot(vmName, snapshotName, description string) error {
+func (m *SnapshotManager) CreateSnapshot(vmName, snapshotName, description string, opts ...SnapshotOption) error {
+	var o snapshotOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if err := ValidateCompression(o.codec, o.level); err != nil {
+		return err
+	}
+	if o.codec == CodecGzip {
+		o.codec = "" // recorded as the default
+	}
+
 	// Clean up any previous partial operations
 	m.CleanupPartial(vmName)
 
 	// Check if disk exists
 	diskPath := m.diskPath(vmName)
+	if o.diskPath != "" {
+		diskPath = o.diskPath
+	}
 	diskInfo, err := os.Stat(diskPath)
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -147,7 +261,7 @@ func (m *SnapshotManager) CreateSnapshot(vmName, snapshotName, description strin
 	defer srcFile.Close()
 
 	// Create compressed snapshot file using temp file for atomic operation
-	snapPath := m.snapshotPath(vmName, snapshotName)
+	snapPath := m.snapshotPath(vmName, snapshotName, o.codec)
 	tmpPath := snapPath + ".tmp"
 	dstFile, err := os.Create(tmpPath)
 	if err != nil {
@@ -155,18 +269,27 @@ func (m *SnapshotManager) CreateSnapshot(vmName, snapshotName, description strin
 	}
 	defer dstFile.Close()
 
-	// Create gzip writer
-	gzWriter := gzip.NewWriter(dstFile)
-	defer gzWriter.Close()
+	// Create compressing writer
+	zw, err := newCompressor(dstFile, o.codec, o.level)
+	if err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("start compression: %w", err)
+	}
+	defer zw.Close()
 
-	// Copy disk to gzip
-	if _, err := io.Copy(gzWriter, srcFile); err != nil {
+	// Copy disk through the compressor, hashing the uncompressed data
+	diskHash := sha256.New()
+	m.prog.Start(fmt.Sprintf("Creating snapshot %s", snapshotName), diskInfo.Size())
+	if _, err := io.Copy(io.MultiWriter(zw, diskHash), progress.NewReader(srcFile, m.prog)); err != nil {
+		zw.Close()
 		os.Remove(tmpPath) // Clean up temp file on failure
+		m.prog.Error(err)
 		return fmt.Errorf("compress disk: %w", err)
 	}
+	m.prog.Complete()
 
-	// Ensure gzip is flushed
-	if err := gzWriter.Close(); err != nil {
+	// Ensure the compressed stream is flushed
+	if err := zw.Close(); err != nil {
 		os.Remove(tmpPath)
 		return fmt.Errorf("finalize compression: %w", err)
 	}
@@ -198,6 +321,15 @@ func (m *SnapshotManager) CreateSnapshot(vmName, snapshotName, description strin
 		CreatedAt:   time.Now(),
 		DiskSize:    diskInfo.Size(),
 		Checksum:    checksum,
+
+		DiskAllocated: allocatedBytes(diskInfo),
+
+		Codec:            o.codec,
+		CompressionLevel: o.level,
+		Scheduled:        o.scheduled,
+
+		DiskChecksum:   fmt.Sprintf("%x", diskHash.Sum(nil)),
+		IsDeltaRestore: true,
 	}
 
 	data.Snapshots = append(data.Snapshots, entry)
@@ -208,9 +340,93 @@ func (m *SnapshotManager) CreateSnapshot(vmName, snapshotName, description strin
 		return err
 	}
 
+	if !m.retention.IsZero() {
+		if _, err := m.ApplyRetention(vmName, m.retention); err != nil {
+			return fmt.Errorf("apply retention: %w", err)
+		}
+	}
+
 	return nil
 }
 
+// ApplyRetention deletes the oldest snapshots of a VM until it satisfies
+// policy: snapshots older than MaxAgeDays are removed, then the oldest are
+// removed until at most MaxCount remain. It returns the deleted names,
+// oldest first.
+func (m *SnapshotManager) ApplyRetention(vmName string, policy RetentionPolicy) ([]string, error) {
+	if policy.IsZero() {
+		return nil, nil
+	}
+
+	data, err := m.Load(vmName)
+	if err != nil {
+		return nil, err
+	}
+
+	snaps := append([]SnapshotEntry(nil), data.Snapshots...)
+	sort.SliceStable(snaps, func(i, j int) bool {
+		return snaps[i].CreatedAt.Before(snaps[j].CreatedAt)
+	})
+
+	var prune []string
+	if policy.MaxAgeDays > 0 {
+		cutoff := time.Now().AddDate(0, 0, -policy.MaxAgeDays)
+		for len(snaps) > 0 && snaps[0].CreatedAt.Before(cutoff) {
+			prune = append(prune, snaps[0].Name)
+			snaps = snaps[1:]
+		}
+	}
+	if policy.MaxCount > 0 {
+		for len(snaps) > policy.MaxCount {
+			prune = append(prune, snaps[0].Name)
+			snaps = snaps[1:]
+		}
+	}
+
+	var deleted []string
+	for _, name := range prune {
+		if err := m.DeleteSnapshot(vmName, name); err != nil {
+			return deleted, err
+		}
+		deleted = append(deleted, name)
+	}
+	return deleted, nil
+}
+
+// PruneScheduled deletes the oldest scheduled snapshots of a VM until at
+// most keep remain, leaving snapshots created by hand alone. It returns the
+// deleted names, oldest first. A keep of 0 or less keeps everything.
+func (m *SnapshotManager) PruneScheduled(vmName string, keep int) ([]string, error) {
+	if keep <= 0 {
+		return nil, nil
+	}
+
+	data, err := m.Load(vmName)
+	if err != nil {
+		return nil, err
+	}
+
+	var scheduled []SnapshotEntry
+	for _, snap := range data.Snapshots {
+		if snap.Scheduled {
+			scheduled = append(scheduled, snap)
+		}
+	}
+	sort.SliceStable(scheduled, func(i, j int) bool {
+		return scheduled[i].CreatedAt.Before(scheduled[j].CreatedAt)
+	})
+
+	var deleted []string
+	for len(scheduled) > keep {
+		if err := m.DeleteSnapshot(vmName, scheduled[0].Name); err != nil {
+			return deleted, err
+		}
+		deleted = append(deleted, scheduled[0].Name)
+		scheduled = scheduled[1:]
+	}
+	return deleted, nil
+}
+
 // ListSnapshots returns all snapshots for a VM.
 func (m *SnapshotManager) ListSnapshots(vmName string) ([]SnapshotEntry, error) {
 	data, err := m.Load(vmName)
@@ -220,6 +436,78 @@ func (m *SnapshotManager) ListSnapshots(vmName string) ([]SnapshotEntry, error)
 	return data.Snapshots, nil
 }
 
+// Snapshot sort orders for SnapshotFilter.SortBy.
+const (
+	SnapshotSortCreated = "created" // Newest first (default)
+	SnapshotSortSize    = "size"    // Largest disk first
+)
+
+// SnapshotFilter selects snapshots in ListSnapshotsFiltered. Nil and empty
+// fields match everything.
+type SnapshotFilter struct {
+	// Before and After bound CreatedAt: Before is exclusive, After inclusive.
+	Before, After *time.Time
+
+	// MinSize and MaxSize bound the original disk size in bytes, inclusive.
+	MinSize, MaxSize *int64
+
+	// Description matches snapshots whose description contains it,
+	// ignoring case.
+	Description string
+
+	// SortBy is SnapshotSortCreated (default) or SnapshotSortSize.
+	SortBy string
+}
+
+// Match reports whether snap passes the filter.
+func (f SnapshotFilter) Match(snap *SnapshotEntry) bool {
+	if f.Before != nil && !snap.CreatedAt.Before(*f.Before) {
+		return false
+	}
+	if f.After != nil && snap.CreatedAt.Before(*f.After) {
+		return false
+	}
+	if f.MinSize != nil && snap.DiskSize < *f.MinSize {
+		return false
+	}
+	if f.MaxSize != nil && snap.DiskSize > *f.MaxSize {
+		return false
+	}
+	if f.Description != "" && !strings.Contains(strings.ToLower(snap.Description), strings.ToLower(f.Description)) {
+		return false
+	}
+	return true
+}
+
+// ListSnapshotsFiltered returns the VM's snapshots that match opts, newest
+// first or largest first depending on opts.SortBy. Snapshot counts are
+// small, so this is a plain pass over the metadata file.
+func (m *SnapshotManager) ListSnapshotsFiltered(vmName string, opts SnapshotFilter) ([]SnapshotEntry, error) {
+	var less func(a, b *SnapshotEntry) bool
+	switch opts.SortBy {
+	case "", SnapshotSortCreated:
+		less = func(a, b *SnapshotEntry) bool { return a.CreatedAt.After(b.CreatedAt) }
+	case SnapshotSortSize:
+		less = func(a, b *SnapshotEntry) bool { return a.DiskSize > b.DiskSize }
+	default:
+		return nil, fmt.Errorf("unknown sort order %q (use %s or %s)", opts.SortBy, SnapshotSortCreated, SnapshotSortSize)
+	}
+
+	snaps, err := m.ListSnapshots(vmName)
+	if err != nil {
+		return nil, err
+	}
+
+	matched := []SnapshotEntry{}
+	for i := range snaps {
+		if opts.Match(&snaps[i]) {
+			matched = append(matched, snaps[i])
+		}
+	}
+	sort.SliceStable(matched, func(i, j int) bool { return less(&matched[i], &matched[j]) })
+	return matched, nil
+}
+
 // GetSnapshot returns a specific snapshot.
 func (m *SnapshotManager) GetSnapshot(vmName, snapshotName string) (*SnapshotEntry, error) {
 	data, err := m.Load(vmName)
@@ -243,14 +531,18 @@ func (m *SnapshotManager) RestoreSnapshot(vmName, snapshotName string) error {
 	// Clean up any previous partial operations
 	m.CleanupPartial(vmName)
 
-	// Verify snapshot exists
+	return m.RestoreSnapshotTo(vmName, snapshotName, m.diskPath(vmName))
+}
+
+// RestoreSnapshotTo writes the disk saved in a snapshot of vmName to
+// diskPath, replacing any file there. Verifies the checksum first.
+func (m *SnapshotManager) RestoreSnapshotTo(vmName, snapshotName, diskPath string) error {
 	snap, err := m.GetSnapshot(vmName, snapshotName)
 	if err != nil {
 		return err
 	}
 
-	snapPath := m.snapshotPath(vmName, snapshotName)
-	diskPath := m.diskPath(vmName)
+	snapPath := m.snapshotPath(vmName, snapshotName, snap.Codec)
 
 	// Verify checksum before restore (if checksum exists)
 	if snap.Checksum != "" {
@@ -259,7 +551,7 @@ func (m *SnapshotManager) RestoreSnapshot(vmName, snapshotName string) error {
 			return fmt.Errorf("verify checksum: %w", err)
 		}
 		if checksum != snap.Checksum {
-			return fmt.Errorf("snapshot corrupted: checksum mismatch")
+			return &ErrSnapshotCorrupted{Name: snapshotName, Expected: snap.Checksum, Got: checksum}
 		}
 	}
 
@@ -270,12 +562,12 @@ func (m *SnapshotManager) RestoreSnapshot(vmName, snapshotName string) error {
 	}
 	defer srcFile.Close()
 
-	// Create gzip reader
-	gzReader, err := gzip.NewReader(srcFile)
+	// Create decompressing reader
+	zr, err := newDecompressor(srcFile, snap.Codec)
 	if err != nil {
-		return fmt.Errorf("open gzip: %w", err)
+		return fmt.Errorf("open %s: %w", snap.CompressionCodec(), err)
 	}
-	defer gzReader.Close()
+	defer zr.Close()
 
 	// Create temporary file for restored disk
 	tmpPath := diskPath + ".restoring"
@@ -286,13 +578,29 @@ func (m *SnapshotManager) RestoreSnapshot(vmName, snapshotName string) error {
 	defer dstFile.Close()
 
 	// Decompress to disk
-	if _, err := io.Copy(dstFile, gzReader); err != nil {
+	m.prog.Start(fmt.Sprintf("Restoring snapshot %s", snapshotName), snap.DiskSize)
+	if _, err := io.Copy(dstFile, progress.NewReader(zr, m.prog)); err != nil {
 		os.Remove(tmpPath)
+		m.prog.Error(err)
 		return fmt.Errorf("decompress snapshot: %w", err)
 	}
+	if err := zr.Close(); err != nil {
+		os.Remove(tmpPath)
+		m.prog.Error(err)
+		return fmt.Errorf("decompress snapshot: %w", err)
+	}
+	m.prog.Complete()
 
-	// Close files before rename
-	dstFile.Close()
+	// Get the data onto disk before it replaces the old disk, so a crash
+	// cannot leave a truncated disk in its place
+	if err := dstFile.Sync(); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("sync restored disk: %w", err)
+	}
+	if err := dstFile.Close(); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("close restored disk: %w", err)
+	}
 
 	// Atomic rename
 	if err := os.Rename(tmpPath, diskPath); err != nil {
@@ -311,22 +619,22 @@ func (m *SnapshotManager) DeleteSnapshot(vmName, snapshotName string) error {
 	}
 
 	// Find and remove snapshot entry
-	found := false
+	var found *SnapshotEntry
 	newSnapshots := make([]SnapshotEntry, 0, len(data.Snapshots))
-	for _, snap := range data.Snapshots {
+	for i, snap := range data.Snapshots {
 		if snap.Name == snapshotName {
-			found = true
+			found = &data.Snapshots[i]
 		} else {
 			newSnapshots = append(newSnapshots, snap)
 		}
 	}
 
-	if !found {
+	if found == nil {
 		return fmt.Errorf("snapshot '%s' not found", snapshotName)
 	}
 
 	// Delete snapshot file
-	snapPath := m.snapshotPath(vmName, snapshotName)
+	snapPath := m.snapshotPath(vmName, snapshotName, found.Codec)
 	if err := os.Remove(snapPath); err != nil && !os.IsNotExist(err) {
 		return fmt.Errorf("delete snapshot file: %w", err)
 	}
@@ -338,7 +646,11 @@ func (m *SnapshotManager) DeleteSnapshot(vmName, snapshotName string) error {
 
 // SnapshotFileSize returns the compressed size of a snapshot file.
 func (m *SnapshotManager) SnapshotFileSize(vmName, snapshotName string) (int64, error) {
-	snapPath := m.snapshotPath(vmName, snapshotName)
+	snap, err := m.GetSnapshot(vmName, snapshotName)
+	if err != nil {
+		return 0, err
+	}
+	snapPath := m.snapshotPath(vmName, snapshotName, snap.Codec)
 	info, err := os.Stat(snapPath)
 	if err != nil {
 		return 0, fmt.Errorf("stat snapshot: %w", err)
@@ -353,19 +665,62 @@ func (m *SnapshotManager) VerifySnapshot(vmName, snapshotName string) error {
 	if err != nil {
 		return err
 	}
+	return m.verifyEntry(vmName, snap)
+}
+
+// VerifyAll verifies every snapshot of vmName, several at a time, and
+// returns each snapshot's VerifySnapshot result by name. A snapshot whose
+// file is gone fails with an error matching os.ErrNotExist, and one without
+// a checksum with *ErrSnapshotNoChecksum. err is set
+// only if the snapshots could not be listed.
+func (m *SnapshotManager) VerifyAll(vmName string) (results map[string]error, err error) {
+	snaps, err := m.ListSnapshots(vmName)
+	if err != nil {
+		return nil, err
+	}
+
+	jobs := make(chan *SnapshotEntry)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	results = make(map[string]error, len(snaps))
+	workers := runtime.NumCPU()
+	if workers > len(snaps) {
+		workers = len(snaps)
+	}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for snap := range jobs {
+				err := m.verifyEntry(vmName, snap)
+				mu.Lock()
+				results[snap.Name] = err
+				mu.Unlock()
+			}
+		}()
+	}
+	for i := range snaps {
+		jobs <- &snaps[i]
+	}
+	close(jobs)
+	wg.Wait()
+	return results, nil
+}
 
+// verifyEntry checks the snapshot file of snap against its checksum.
+func (m *SnapshotManager) verifyEntry(vmName string, snap *SnapshotEntry) error {
 	if snap.Checksum == "" {
-		return fmt.Errorf("snapshot has no checksum (created before checksum support)")
+		return &ErrSnapshotNoChecksum{Name: snap.Name}
 	}
 
-	snapPath := m.snapshotPath(vmName, snapshotName)
+	snapPath := m.snapshotPath(vmName, snap.Name, snap.Codec)
 	checksum, err := m.computeChecksum(snapPath)
 	if err != nil {
 		return fmt.Errorf("compute checksum: %w", err)
 	}
 
 	if checksum != snap.Checksum {
-		return fmt.Errorf("checksum mismatch: expected %s, got %s", snap.Checksum, checksum)
+		return &ErrSnapshotCorrupted{Name: snap.Name, Expected: snap.Checksum, Got: checksum}
 	}
 
 	return nil
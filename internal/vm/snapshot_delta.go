@@ -0,0 +1,172 @@
+package vm
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// deltaBlockSize is the unit DeltaRestore compares and rewrites.
+const deltaBlockSize = 1 << 20
+
+// DeltaRestore restores vmName's disk to toSnap, assuming it currently
+// holds fromSnap. Both snapshots are decompressed side by side and only
+// the blocks that differ between them are written to the disk in place,
+// which saves most of the writes of RestoreSnapshot when the snapshots
+// are close.
+//
+// It falls back to a full RestoreSnapshot of toSnap when either snapshot
+// lacks the IsDeltaRestore capability, or when the current disk does not
+// match fromSnap's DiskChecksum, such as after the VM has run since it was
+// restored. A delta that fails part way is also finished with a full
+// restore. The VM must be stopped.
+func (m *SnapshotManager) DeltaRestore(vmName, fromSnap, toSnap string) error {
+	m.CleanupPartial(vmName)
+
+	from, err := m.GetSnapshot(vmName, fromSnap)
+	if err != nil {
+		return err
+	}
+	to, err := m.GetSnapshot(vmName, toSnap)
+	if err != nil {
+		return err
+	}
+	if !from.IsDeltaRestore || !to.IsDeltaRestore || from.DiskChecksum == "" || to.DiskChecksum == "" {
+		return m.RestoreSnapshot(vmName, toSnap)
+	}
+
+	diskPath := m.diskPath(vmName)
+	match, err := m.diskMatches(diskPath, from)
+	if err != nil {
+		return err
+	}
+	if !match {
+		return m.RestoreSnapshot(vmName, toSnap)
+	}
+
+	if err := m.applyDelta(vmName, from, to, diskPath); err != nil {
+		if ferr := m.RestoreSnapshot(vmName, toSnap); ferr != nil {
+			return fmt.Errorf("delta restore: %w; full restore: %v", err, ferr)
+		}
+	}
+	return nil
+}
+
+// diskMatches reports whether the disk at diskPath is the one snap saved.
+func (m *SnapshotManager) diskMatches(diskPath string, snap *SnapshotEntry) (bool, error) {
+	info, err := os.Stat(diskPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("stat disk: %w", err)
+	}
+	if info.Size() != snap.DiskSize {
+		return false, nil
+	}
+	checksum, err := m.computeChecksum(diskPath)
+	if err != nil {
+		return false, fmt.Errorf("checksum disk: %w", err)
+	}
+	return checksum == snap.DiskChecksum, nil
+}
+
+// openSnapshotDisk verifies a snapshot file against its checksum and
+// returns a reader of the disk it holds.
+func (m *SnapshotManager) openSnapshotDisk(vmName string, snap *SnapshotEntry) (io.ReadCloser, func(), error) {
+	snapPath := m.snapshotPath(vmName, snap.Name, snap.Codec)
+	if snap.Checksum != "" {
+		checksum, err := m.computeChecksum(snapPath)
+		if err != nil {
+			return nil, nil, fmt.Errorf("verify checksum: %w", err)
+		}
+		if checksum != snap.Checksum {
+			return nil, nil, &ErrSnapshotCorrupted{Name: snap.Name, Expected: snap.Checksum, Got: checksum}
+		}
+	}
+	f, err := os.Open(snapPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("open snapshot: %w", err)
+	}
+	zr, err := newDecompressor(f, snap.Codec)
+	if err != nil {
+		f.Close()
+		return nil, nil, fmt.Errorf("open %s: %w", snap.CompressionCodec(), err)
+	}
+	return zr, func() { zr.Close(); f.Close() }, nil
+}
+
+// applyDelta rewrites the blocks of the disk at diskPath, which holds
+// from, that differ in to, then checks the result against to.
+func (m *SnapshotManager) applyDelta(vmName string, from, to *SnapshotEntry, diskPath string) error {
+	fromDisk, closeFrom, err := m.openSnapshotDisk(vmName, from)
+	if err != nil {
+		return err
+	}
+	defer closeFrom()
+	toDisk, closeTo, err := m.openSnapshotDisk(vmName, to)
+	if err != nil {
+		return err
+	}
+	defer closeTo()
+
+	disk, err := os.OpenFile(diskPath, os.O_RDWR, 0)
+	if err != nil {
+		return fmt.Errorf("open disk: %w", err)
+	}
+	defer disk.Close()
+
+	fromBuf := make([]byte, deltaBlockSize)
+	toBuf := make([]byte, deltaBlockSize)
+	h := sha256.New()
+	var off int64
+
+	m.prog.Start(fmt.Sprintf("Restoring snapshot %s (delta from %s)", to.Name, from.Name), to.DiskSize)
+	for {
+		nt, err := io.ReadFull(toDisk, toBuf)
+		if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+			m.prog.Error(err)
+			return fmt.Errorf("decompress snapshot '%s': %w", to.Name, err)
+		}
+		if nt == 0 {
+			break
+		}
+		nf, err := io.ReadFull(fromDisk, fromBuf)
+		if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+			m.prog.Error(err)
+			return fmt.Errorf("decompress snapshot '%s': %w", from.Name, err)
+		}
+
+		h.Write(toBuf[:nt])
+		if nf != nt || !bytes.Equal(fromBuf[:nf], toBuf[:nt]) {
+			if _, err := disk.WriteAt(toBuf[:nt], off); err != nil {
+				m.prog.Error(err)
+				return fmt.Errorf("write disk: %w", err)
+			}
+		}
+		off += int64(nt)
+		m.prog.Update(off)
+		if nt < deltaBlockSize {
+			break
+		}
+	}
+
+	if err := disk.Truncate(off); err != nil {
+		m.prog.Error(err)
+		return fmt.Errorf("resize disk: %w", err)
+	}
+	if err := disk.Sync(); err != nil {
+		m.prog.Error(err)
+		return fmt.Errorf("sync disk: %w", err)
+	}
+	if fmt.Sprintf("%x", h.Sum(nil)) != to.DiskChecksum {
+		err := fmt.Errorf("snapshot '%s' does not match its disk checksum", to.Name)
+		m.prog.Error(err)
+		return err
+	}
+	m.prog.Complete()
+	return nil
+}
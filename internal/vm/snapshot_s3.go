@@ -0,0 +1,243 @@
+package vm
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/javanstorm/vmterminal/internal/progress"
+)
+
+// Object metadata and tag keys of pushed snapshots.
+const (
+	s3TagSHA256       = "sha256"
+	s3MetaSnapshot    = "Snapshot"
+	s3MetaDescription = "Description"
+	s3MetaCreatedAt   = "Created-At"
+	s3MetaDiskSize    = "Disk-Size"
+	s3MetaCodec       = "Codec"
+)
+
+// SnapshotObjectURI returns where PushSnapshot stores a snapshot under the
+// s3://bucket/prefix URI: <prefix>/<vm>/<snapshot>.raw.gz (or .raw.zst).
+func (m *SnapshotManager) SnapshotObjectURI(vmName, snapshotName, s3URI string) (string, error) {
+	loc, err := m.snapshotObject(vmName, snapshotName, s3URI)
+	if err != nil {
+		return "", err
+	}
+	return loc.String(), nil
+}
+
+// snapshotObject returns the object a snapshot is pushed to.
+func (m *SnapshotManager) snapshotObject(vmName, snapshotName, s3URI string) (s3Location, error) {
+	prefix, err := parseS3URI(s3URI)
+	if err != nil {
+		return s3Location{}, err
+	}
+	snap, err := m.GetSnapshot(vmName, snapshotName)
+	if err != nil {
+		return s3Location{}, err
+	}
+	prefix.Key = path.Join(strings.Trim(prefix.Key, "/"), vmName, snapshotName+codecExt(snap.Codec))
+	return prefix, nil
+}
+
+// PushSnapshot uploads a snapshot's compressed disk to the S3 bucket and
+// prefix in s3URI (s3://bucket/prefix), using the credentials, region and
+// endpoint of the standard AWS environment variables or ~/.aws files. The
+// object is tagged with the file's SHA-256 and carries the snapshot's
+// metadata, so PullSnapshot can verify and restore it.
+func (m *SnapshotManager) PushSnapshot(vmName, snapshotName, s3URI string) error {
+	loc, err := m.snapshotObject(vmName, snapshotName, s3URI)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	client, err := newS3Client(ctx)
+	if err != nil {
+		return err
+	}
+	return m.pushSnapshot(ctx, client, vmName, snapshotName, loc)
+}
+
+// pushSnapshot uploads a snapshot to loc with client.
+func (m *SnapshotManager) pushSnapshot(ctx context.Context, client *s3Client, vmName, snapshotName string, loc s3Location) error {
+	snap, err := m.GetSnapshot(vmName, snapshotName)
+	if err != nil {
+		return err
+	}
+	snapPath := m.snapshotPath(vmName, snapshotName, snap.Codec)
+
+	// The recorded checksum is of this file; older snapshots may lack one
+	checksum := snap.Checksum
+	if checksum == "" {
+		if checksum, err = m.computeChecksum(snapPath); err != nil {
+			return fmt.Errorf("compute checksum: %w", err)
+		}
+	}
+
+	f, err := os.Open(snapPath)
+	if err != nil {
+		return fmt.Errorf("open snapshot: %w", err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("stat snapshot: %w", err)
+	}
+
+	meta := map[string]string{
+		s3MetaSnapshot:    snapshotName,
+		s3MetaDescription: url.PathEscape(snap.Description),
+		s3MetaCreatedAt:   snap.CreatedAt.UTC().Format(time.RFC3339),
+		s3MetaDiskSize:    strconv.FormatInt(snap.DiskSize, 10),
+		s3MetaCodec:       snap.CompressionCodec(),
+	}
+	tags := url.Values{s3TagSHA256: {checksum}}
+
+	m.prog.Start(fmt.Sprintf("Uploading snapshot %s", snapshotName), info.Size())
+	if err := client.putObject(ctx, loc, progress.NewReader(f, m.prog), info.Size(), meta, tags); err != nil {
+		m.prog.Error(err)
+		return err
+	}
+	m.prog.Complete()
+	return nil
+}
+
+// PullSnapshot downloads a snapshot pushed with PushSnapshot from the
+// object at s3URI and adds it to vmName's snapshots as snapshotName. If
+// snapshotName is empty, the pushed snapshot's name is used. The download
+// is rejected unless its SHA-256 matches the object's tag.
+func (m *SnapshotManager) PullSnapshot(vmName, s3URI, snapshotName string) (string, error) {
+	loc, err := parseS3URI(s3URI)
+	if err != nil {
+		return "", err
+	}
+	if loc.Key == "" || strings.HasSuffix(loc.Key, "/") {
+		return "", fmt.Errorf("invalid S3 URI %q: expected the URI of a snapshot object", s3URI)
+	}
+	ctx := context.Background()
+	client, err := newS3Client(ctx)
+	if err != nil {
+		return "", err
+	}
+	return m.pullSnapshot(ctx, client, vmName, loc, snapshotName)
+}
+
+// pullSnapshot downloads the snapshot at loc with client.
+func (m *SnapshotManager) pullSnapshot(ctx context.Context, client *s3Client, vmName string, loc s3Location, snapshotName string) (string, error) {
+	tags, err := client.getObjectTags(ctx, loc)
+	if err != nil {
+		return "", err
+	}
+	want := tags[s3TagSHA256]
+	if want == "" {
+		return "", fmt.Errorf("%s has no %s tag; only snapshots uploaded with 'snapshot push' can be pulled", loc, s3TagSHA256)
+	}
+
+	obj, err := client.getObject(ctx, loc)
+	if err != nil {
+		return "", err
+	}
+	defer obj.Body.Close()
+
+	if snapshotName == "" {
+		snapshotName = objectMeta(obj.Metadata, s3MetaSnapshot)
+	}
+	if snapshotName == "" {
+		base := path.Base(loc.Key)
+		snapshotName = strings.TrimSuffix(strings.TrimSuffix(base, codecExt(CodecZstd)), codecExt(CodecGzip))
+	}
+	if snapshotName == "" || strings.ContainsAny(snapshotName, `/\`) || snapshotName == "." || snapshotName == ".." {
+		return "", fmt.Errorf("invalid snapshot name %q", snapshotName)
+	}
+
+	codec := objectMeta(obj.Metadata, s3MetaCodec)
+	if codec == "" && strings.HasSuffix(loc.Key, codecExt(CodecZstd)) {
+		codec = CodecZstd
+	}
+	if err := ValidateCompression(codec, 0); err != nil {
+		return "", fmt.Errorf("%s: %w", loc, err)
+	}
+	if codec == CodecGzip {
+		codec = "" // recorded as the default
+	}
+
+	m.CleanupPartial(vmName)
+	data, err := m.Load(vmName)
+	if err != nil {
+		return "", err
+	}
+	for _, snap := range data.Snapshots {
+		if snap.Name == snapshotName {
+			return "", fmt.Errorf("snapshot '%s' already exists", snapshotName)
+		}
+	}
+	if err := os.MkdirAll(m.snapshotsDir(vmName), 0755); err != nil {
+		return "", fmt.Errorf("create snapshots dir: %w", err)
+	}
+
+	snapPath := m.snapshotPath(vmName, snapshotName, codec)
+	tmpPath := snapPath + ".tmp"
+	f, err := os.Create(tmpPath)
+	if err != nil {
+		return "", fmt.Errorf("create snapshot file: %w", err)
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	m.prog.Start(fmt.Sprintf("Downloading snapshot %s", snapshotName), aws.ToInt64(obj.ContentLength))
+	if _, err := io.Copy(io.MultiWriter(f, h), progress.NewReader(obj.Body, m.prog)); err != nil {
+		os.Remove(tmpPath)
+		m.prog.Error(err)
+		return "", fmt.Errorf("download %s: %w", loc, err)
+	}
+	m.prog.Complete()
+
+	checksum := hex.EncodeToString(h.Sum(nil))
+	if checksum != want {
+		os.Remove(tmpPath)
+		return "", &ErrSnapshotCorrupted{Name: snapshotName, Expected: want, Got: checksum}
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmpPath)
+		return "", fmt.Errorf("close snapshot file: %w", err)
+	}
+	if err := os.Rename(tmpPath, snapPath); err != nil {
+		os.Remove(tmpPath)
+		return "", fmt.Errorf("finalize snapshot: %w", err)
+	}
+
+	entry := SnapshotEntry{
+		Name:      snapshotName,
+		VMName:    vmName,
+		CreatedAt: time.Now(),
+		Checksum:  checksum,
+		Codec:     codec,
+	}
+	if desc, err := url.PathUnescape(objectMeta(obj.Metadata, s3MetaDescription)); err == nil {
+		entry.Description = desc
+	}
+	if created, err := time.Parse(time.RFC3339, objectMeta(obj.Metadata, s3MetaCreatedAt)); err == nil {
+		entry.CreatedAt = created
+	}
+	if size, err := strconv.ParseInt(objectMeta(obj.Metadata, s3MetaDiskSize), 10, 64); err == nil {
+		entry.DiskSize = size
+	}
+
+	data.Snapshots = append(data.Snapshots, entry)
+	if err := m.Save(vmName, data); err != nil {
+		os.Remove(snapPath)
+		return "", err
+	}
+	return snapshotName, nil
+}
@@ -0,0 +1,83 @@
+package vm
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSnapshotPushPull(t *testing.T) {
+	dir := t.TempDir()
+	mgr := NewSnapshotManager(dir, nil)
+
+	diskDir := filepath.Join(dir, "data", "dev")
+	if err := os.MkdirAll(diskDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	disk := []byte(strings.Repeat("disk contents ", 100))
+	if err := os.WriteFile(filepath.Join(diskDir, "disk.raw"), disk, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := mgr.CreateSnapshot("dev", "clean", "fresh install, ünïcode"); err != nil {
+		t.Fatal(err)
+	}
+	orig, _ := mgr.GetSnapshot("dev", "clean")
+
+	fake, client := newFakeS3(t)
+	uri, err := mgr.SnapshotObjectURI("dev", "clean", "s3://backups/vmterminal/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uri != "s3://backups/vmterminal/dev/clean.raw.gz" {
+		t.Errorf("object URI = %s", uri)
+	}
+	loc, _ := parseS3URI(uri)
+	if err := mgr.pushSnapshot(t.Context(), client, "dev", "clean", loc); err != nil {
+		t.Fatalf("pushSnapshot: %v", err)
+	}
+	if got := fake.tags["/backups/vmterminal/dev/clean.raw.gz"].Get(s3TagSHA256); got != orig.Checksum {
+		t.Errorf("sha256 tag = %q, want %q", got, orig.Checksum)
+	}
+
+	// Pull into another VM and restore from it
+	name, err := mgr.pullSnapshot(t.Context(), client, "web", loc, "")
+	if err != nil {
+		t.Fatalf("pullSnapshot: %v", err)
+	}
+	if name != "clean" {
+		t.Errorf("pulled name = %q, want clean", name)
+	}
+	pulled, err := mgr.GetSnapshot("web", "clean")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pulled.Checksum != orig.Checksum || pulled.DiskSize != orig.DiskSize || pulled.Description != orig.Description || !pulled.CreatedAt.Equal(orig.CreatedAt.Truncate(1e9)) {
+		t.Errorf("pulled = %+v, want metadata of %+v", pulled, orig)
+	}
+	if err := mgr.RestoreSnapshot("web", "clean"); err != nil {
+		t.Fatalf("RestoreSnapshot: %v", err)
+	}
+	if got, _ := os.ReadFile(filepath.Join(dir, "data", "web", "disk.raw")); string(got) != string(disk) {
+		t.Error("restored disk does not match the original")
+	}
+
+	if _, err := mgr.pullSnapshot(t.Context(), client, "web", loc, "clean"); err == nil {
+		t.Error("expected error pulling over an existing snapshot")
+	}
+	if _, err := mgr.pullSnapshot(t.Context(), client, "web", loc, "../evil"); err == nil {
+		t.Error("expected error for a name with a path separator")
+	}
+
+	// A corrupted object is rejected and leaves nothing behind
+	fake.objects["/backups/vmterminal/dev/clean.raw.gz"][0] ^= 0xff
+	if _, err := mgr.pullSnapshot(t.Context(), client, "web", loc, "corrupt"); err == nil {
+		t.Fatal("expected checksum error")
+	}
+	if _, err := mgr.GetSnapshot("web", "corrupt"); err == nil {
+		t.Error("corrupt snapshot was recorded")
+	}
+	if mgr.HasPartialFiles("web") {
+		t.Error("corrupt download left partial files")
+	}
+}
@@ -1,8 +1,12 @@
 package vm
 
 import (
+	"bytes"
+	"errors"
 	"os"
 	"path/filepath"
+	"reflect"
+	"sort"
 	"strings"
 	"testing"
 	"time"
@@ -10,7 +14,7 @@ import (
 
 func TestSnapshotManagerCreateAndList(t *testing.T) {
 	tmpDir := t.TempDir()
-	mgr := NewSnapshotManager(tmpDir)
+	mgr := NewSnapshotManager(tmpDir, nil)
 
 	// Create a fake disk to snapshot
 	vmName := "test-vm"
@@ -50,7 +54,7 @@ func TestSnapshotManagerCreateAndList(t *testing.T) {
 
 func TestSnapshotManagerDuplicateName(t *testing.T) {
 	tmpDir := t.TempDir()
-	mgr := NewSnapshotManager(tmpDir)
+	mgr := NewSnapshotManager(tmpDir, nil)
 
 	vmName := "test-vm"
 	diskDir := filepath.Join(tmpDir, "data", vmName)
@@ -76,7 +80,7 @@ func TestSnapshotManagerDuplicateName(t *testing.T) {
 
 func TestSnapshotManagerRestore(t *testing.T) {
 	tmpDir := t.TempDir()
-	mgr := NewSnapshotManager(tmpDir)
+	mgr := NewSnapshotManager(tmpDir, nil)
 
 	vmName := "test-vm"
 	diskDir := filepath.Join(tmpDir, "data", vmName)
@@ -114,7 +118,7 @@ func TestSnapshotManagerRestore(t *testing.T) {
 
 func TestSnapshotManagerRestoreChecksumMismatch(t *testing.T) {
 	tmpDir := t.TempDir()
-	mgr := NewSnapshotManager(tmpDir)
+	mgr := NewSnapshotManager(tmpDir, nil)
 
 	vmName := "test-vm"
 	diskDir := filepath.Join(tmpDir, "data", vmName)
@@ -139,17 +143,18 @@ func TestSnapshotManagerRestoreChecksumMismatch(t *testing.T) {
 
 	// Restore should fail due to checksum mismatch
 	err = mgr.RestoreSnapshot(vmName, "snap1")
-	if err == nil {
-		t.Error("expected checksum mismatch error")
+	var corrupted *ErrSnapshotCorrupted
+	if !errors.As(err, &corrupted) {
+		t.Fatalf("RestoreSnapshot = %v, want ErrSnapshotCorrupted", err)
 	}
-	if !strings.Contains(err.Error(), "checksum mismatch") {
-		t.Errorf("wrong error: %v", err)
+	if corrupted.Name != "snap1" || corrupted.Expected == corrupted.Got {
+		t.Errorf("ErrSnapshotCorrupted = %+v", corrupted)
 	}
 }
 
 func TestSnapshotManagerDelete(t *testing.T) {
 	tmpDir := t.TempDir()
-	mgr := NewSnapshotManager(tmpDir)
+	mgr := NewSnapshotManager(tmpDir, nil)
 
 	vmName := "test-vm"
 	diskDir := filepath.Join(tmpDir, "data", vmName)
@@ -181,7 +186,7 @@ func TestSnapshotManagerDelete(t *testing.T) {
 
 func TestSnapshotManagerVerify(t *testing.T) {
 	tmpDir := t.TempDir()
-	mgr := NewSnapshotManager(tmpDir)
+	mgr := NewSnapshotManager(tmpDir, nil)
 
 	vmName := "test-vm"
 	diskDir := filepath.Join(tmpDir, "data", vmName)
@@ -213,9 +218,51 @@ func TestSnapshotManagerVerify(t *testing.T) {
 	}
 }
 
+func TestSnapshotManagerVerifyAll(t *testing.T) {
+	tmpDir := t.TempDir()
+	mgr := NewSnapshotManager(tmpDir, nil)
+
+	vmName := "test-vm"
+	diskDir := filepath.Join(tmpDir, "data", vmName)
+	os.MkdirAll(diskDir, 0755)
+	os.WriteFile(filepath.Join(diskDir, "disk.raw"), []byte("test disk"), 0644)
+	for _, name := range []string{"good", "corrupt", "missing"} {
+		if err := mgr.CreateSnapshot(vmName, name, ""); err != nil {
+			t.Fatalf("CreateSnapshot %s: %v", name, err)
+		}
+	}
+
+	snapDir := filepath.Join(diskDir, "snapshots")
+	os.WriteFile(filepath.Join(snapDir, "corrupt.raw.gz"), []byte("not a snapshot"), 0644)
+	os.Remove(filepath.Join(snapDir, "missing.raw.gz"))
+
+	results, err := mgr.VerifyAll(vmName)
+	if err != nil {
+		t.Fatalf("VerifyAll: %v", err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("VerifyAll returned %d results, want 3", len(results))
+	}
+	if err := results["good"]; err != nil {
+		t.Errorf("good: %v", err)
+	}
+	var corrupted *ErrSnapshotCorrupted
+	if err := results["corrupt"]; !errors.As(err, &corrupted) {
+		t.Errorf("corrupt: %v, want ErrSnapshotCorrupted", err)
+	}
+	if err := results["missing"]; !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("missing: %v, want os.ErrNotExist", err)
+	}
+
+	// A VM without snapshots has nothing to verify
+	if results, err := mgr.VerifyAll("other"); err != nil || len(results) != 0 {
+		t.Errorf("VerifyAll(other) = %v, %v; want no results", results, err)
+	}
+}
+
 func TestSnapshotManagerLoadEmpty(t *testing.T) {
 	tmpDir := t.TempDir()
-	mgr := NewSnapshotManager(tmpDir)
+	mgr := NewSnapshotManager(tmpDir, nil)
 
 	// No snapshots.json exists
 	snapshots, err := mgr.ListSnapshots("nonexistent-vm")
@@ -229,7 +276,7 @@ func TestSnapshotManagerLoadEmpty(t *testing.T) {
 
 func TestSnapshotManagerAtomicCreate(t *testing.T) {
 	tmpDir := t.TempDir()
-	mgr := NewSnapshotManager(tmpDir)
+	mgr := NewSnapshotManager(tmpDir, nil)
 
 	vmName := "test-vm"
 	diskDir := filepath.Join(tmpDir, "data", vmName)
@@ -259,7 +306,7 @@ func TestSnapshotManagerAtomicCreate(t *testing.T) {
 
 func TestSnapshotManagerCleanupPartial(t *testing.T) {
 	tmpDir := t.TempDir()
-	mgr := NewSnapshotManager(tmpDir)
+	mgr := NewSnapshotManager(tmpDir, nil)
 
 	vmName := "test-vm"
 	diskDir := filepath.Join(tmpDir, "data", vmName)
@@ -300,7 +347,7 @@ func TestSnapshotManagerCleanupPartial(t *testing.T) {
 
 func TestSnapshotManagerHasPartialFiles(t *testing.T) {
 	tmpDir := t.TempDir()
-	mgr := NewSnapshotManager(tmpDir)
+	mgr := NewSnapshotManager(tmpDir, nil)
 
 	vmName := "test-vm"
 	diskDir := filepath.Join(tmpDir, "data", vmName)
@@ -340,7 +387,7 @@ func TestSnapshotManagerHasPartialFiles(t *testing.T) {
 
 func TestSnapshotManagerGetSnapshot(t *testing.T) {
 	tmpDir := t.TempDir()
-	mgr := NewSnapshotManager(tmpDir)
+	mgr := NewSnapshotManager(tmpDir, nil)
 
 	vmName := "test-vm"
 	diskDir := filepath.Join(tmpDir, "data", vmName)
@@ -375,7 +422,7 @@ func TestSnapshotManagerGetSnapshot(t *testing.T) {
 
 func TestSnapshotManagerTimestamp(t *testing.T) {
 	tmpDir := t.TempDir()
-	mgr := NewSnapshotManager(tmpDir)
+	mgr := NewSnapshotManager(tmpDir, nil)
 
 	vmName := "test-vm"
 	diskDir := filepath.Join(tmpDir, "data", vmName)
@@ -396,7 +443,7 @@ func TestSnapshotManagerTimestamp(t *testing.T) {
 
 func TestSnapshotManagerSnapshotFileSize(t *testing.T) {
 	tmpDir := t.TempDir()
-	mgr := NewSnapshotManager(tmpDir)
+	mgr := NewSnapshotManager(tmpDir, nil)
 
 	vmName := "test-vm"
 	diskDir := filepath.Join(tmpDir, "data", vmName)
@@ -417,7 +464,7 @@ func TestSnapshotManagerSnapshotFileSize(t *testing.T) {
 
 func TestSnapshotManagerNoDisk(t *testing.T) {
 	tmpDir := t.TempDir()
-	mgr := NewSnapshotManager(tmpDir)
+	mgr := NewSnapshotManager(tmpDir, nil)
 
 	// Try to create snapshot without disk
 	err := mgr.CreateSnapshot("no-disk-vm", "snap1", "test")
@@ -431,7 +478,7 @@ func TestSnapshotManagerNoDisk(t *testing.T) {
 
 func TestSnapshotManagerDeleteNonExistent(t *testing.T) {
 	tmpDir := t.TempDir()
-	mgr := NewSnapshotManager(tmpDir)
+	mgr := NewSnapshotManager(tmpDir, nil)
 
 	err := mgr.DeleteSnapshot("test-vm", "nonexistent")
 	if err == nil {
@@ -444,7 +491,7 @@ func TestSnapshotManagerDeleteNonExistent(t *testing.T) {
 
 func TestSnapshotManagerRestoreNonExistent(t *testing.T) {
 	tmpDir := t.TempDir()
-	mgr := NewSnapshotManager(tmpDir)
+	mgr := NewSnapshotManager(tmpDir, nil)
 
 	err := mgr.RestoreSnapshot("test-vm", "nonexistent")
 	if err == nil {
@@ -457,7 +504,7 @@ func TestSnapshotManagerRestoreNonExistent(t *testing.T) {
 
 func TestSnapshotManagerVerifyNoChecksum(t *testing.T) {
 	tmpDir := t.TempDir()
-	mgr := NewSnapshotManager(tmpDir)
+	mgr := NewSnapshotManager(tmpDir, nil)
 
 	vmName := "test-vm"
 	diskDir := filepath.Join(tmpDir, "data", vmName)
@@ -490,7 +537,7 @@ func TestSnapshotManagerVerifyNoChecksum(t *testing.T) {
 
 func TestSnapshotManagerMultipleSnapshots(t *testing.T) {
 	tmpDir := t.TempDir()
-	mgr := NewSnapshotManager(tmpDir)
+	mgr := NewSnapshotManager(tmpDir, nil)
 
 	vmName := "test-vm"
 	diskDir := filepath.Join(tmpDir, "data", vmName)
@@ -528,3 +575,511 @@ func TestSnapshotManagerMultipleSnapshots(t *testing.T) {
 		}
 	}
 }
+
+// newRetentionTestVM creates a VM disk and one snapshot per name, each
+// backdated by the matching number of days.
+func newRetentionTestVM(t *testing.T, mgr *SnapshotManager, tmpDir string, ages map[string]int) {
+	t.Helper()
+
+	diskDir := filepath.Join(tmpDir, "data", "test-vm")
+	if err := os.MkdirAll(diskDir, 0755); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(diskDir, "disk.raw"), []byte("disk"), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	for name := range ages {
+		if err := mgr.CreateSnapshot("test-vm", name, ""); err != nil {
+			t.Fatalf("CreateSnapshot: %v", err)
+		}
+	}
+
+	data, err := mgr.Load("test-vm")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	for i := range data.Snapshots {
+		data.Snapshots[i].CreatedAt = time.Now().AddDate(0, 0, -ages[data.Snapshots[i].Name])
+	}
+	if err := mgr.Save("test-vm", data); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+}
+
+func snapshotNames(t *testing.T, mgr *SnapshotManager) []string {
+	t.Helper()
+	snaps, err := mgr.ListSnapshots("test-vm")
+	if err != nil {
+		t.Fatalf("ListSnapshots: %v", err)
+	}
+	var names []string
+	for _, s := range snaps {
+		names = append(names, s.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestSnapshotManagerRetentionMaxCount(t *testing.T) {
+	tmpDir := t.TempDir()
+	mgr := NewSnapshotManager(tmpDir, nil)
+	newRetentionTestVM(t, mgr, tmpDir, map[string]int{"a": 4, "b": 3, "c": 2, "d": 1})
+
+	deleted, err := mgr.ApplyRetention("test-vm", RetentionPolicy{MaxCount: 2})
+	if err != nil {
+		t.Fatalf("ApplyRetention: %v", err)
+	}
+	if !reflect.DeepEqual(deleted, []string{"a", "b"}) {
+		t.Errorf("deleted = %v, want [a b]", deleted)
+	}
+	if got := snapshotNames(t, mgr); !reflect.DeepEqual(got, []string{"c", "d"}) {
+		t.Errorf("remaining = %v, want [c d]", got)
+	}
+	if _, err := os.Stat(mgr.snapshotPath("test-vm", "a", "")); !os.IsNotExist(err) {
+		t.Error("pruned snapshot file should be removed")
+	}
+}
+
+func TestSnapshotManagerRetentionMaxAge(t *testing.T) {
+	tmpDir := t.TempDir()
+	mgr := NewSnapshotManager(tmpDir, nil)
+	newRetentionTestVM(t, mgr, tmpDir, map[string]int{"old": 30, "mid": 8, "new": 1})
+
+	deleted, err := mgr.ApplyRetention("test-vm", RetentionPolicy{MaxAgeDays: 7})
+	if err != nil {
+		t.Fatalf("ApplyRetention: %v", err)
+	}
+	if !reflect.DeepEqual(deleted, []string{"old", "mid"}) {
+		t.Errorf("deleted = %v, want [old mid]", deleted)
+	}
+	if got := snapshotNames(t, mgr); !reflect.DeepEqual(got, []string{"new"}) {
+		t.Errorf("remaining = %v, want [new]", got)
+	}
+}
+
+func TestSnapshotManagerRetentionBothLimits(t *testing.T) {
+	tmpDir := t.TempDir()
+	mgr := NewSnapshotManager(tmpDir, nil)
+	newRetentionTestVM(t, mgr, tmpDir, map[string]int{"a": 20, "b": 3, "c": 2, "d": 1})
+
+	deleted, err := mgr.ApplyRetention("test-vm", RetentionPolicy{MaxCount: 2, MaxAgeDays: 10})
+	if err != nil {
+		t.Fatalf("ApplyRetention: %v", err)
+	}
+	if !reflect.DeepEqual(deleted, []string{"a", "b"}) {
+		t.Errorf("deleted = %v, want [a b]", deleted)
+	}
+}
+
+func TestSnapshotManagerRetentionNoPolicy(t *testing.T) {
+	tmpDir := t.TempDir()
+	mgr := NewSnapshotManager(tmpDir, nil)
+	newRetentionTestVM(t, mgr, tmpDir, map[string]int{"a": 400, "b": 1})
+
+	deleted, err := mgr.ApplyRetention("test-vm", RetentionPolicy{})
+	if err != nil {
+		t.Fatalf("ApplyRetention: %v", err)
+	}
+	if len(deleted) != 0 {
+		t.Errorf("deleted = %v, want none", deleted)
+	}
+}
+
+func TestSnapshotManagerRetentionAfterCreate(t *testing.T) {
+	tmpDir := t.TempDir()
+	mgr := NewSnapshotManager(tmpDir, nil)
+	newRetentionTestVM(t, mgr, tmpDir, map[string]int{"a": 2, "b": 1})
+
+	mgr.SetRetention(RetentionPolicy{MaxCount: 2})
+	if err := mgr.CreateSnapshot("test-vm", "c", ""); err != nil {
+		t.Fatalf("CreateSnapshot: %v", err)
+	}
+	if got := snapshotNames(t, mgr); !reflect.DeepEqual(got, []string{"b", "c"}) {
+		t.Errorf("remaining = %v, want [b c]", got)
+	}
+}
+
+// newCompressionTestVM writes a compressible disk for a VM named test-vm.
+func newCompressionTestVM(t *testing.T, tmpDir string) []byte {
+	t.Helper()
+	diskDir := filepath.Join(tmpDir, "data", "test-vm")
+	if err := os.MkdirAll(diskDir, 0755); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+	content := []byte(strings.Repeat("compressible disk content ", 4096))
+	if err := os.WriteFile(filepath.Join(diskDir, "disk.raw"), content, 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	return content
+}
+
+// roundTripSnapshot creates snapshot snap1 with opts, clobbers the disk and
+// restores it, failing the test if the content differs.
+func roundTripSnapshot(t *testing.T, mgr *SnapshotManager, tmpDir string, want []byte, opts ...SnapshotOption) *SnapshotEntry {
+	t.Helper()
+	if err := mgr.CreateSnapshot("test-vm", "snap1", "", opts...); err != nil {
+		t.Fatalf("CreateSnapshot: %v", err)
+	}
+
+	diskPath := filepath.Join(tmpDir, "data", "test-vm", "disk.raw")
+	os.WriteFile(diskPath, []byte("modified"), 0644)
+	if err := mgr.RestoreSnapshot("test-vm", "snap1"); err != nil {
+		t.Fatalf("RestoreSnapshot: %v", err)
+	}
+	got, err := os.ReadFile(diskPath)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if string(got) != string(want) {
+		t.Error("restored disk differs from the original")
+	}
+
+	snap, err := mgr.GetSnapshot("test-vm", "snap1")
+	if err != nil {
+		t.Fatalf("GetSnapshot: %v", err)
+	}
+	return snap
+}
+
+func TestSnapshotCompressionLevel(t *testing.T) {
+	tmpDir := t.TempDir()
+	mgr := NewSnapshotManager(tmpDir, nil)
+	content := newCompressionTestVM(t, tmpDir)
+
+	snap := roundTripSnapshot(t, mgr, tmpDir, content, WithCompressionLevel(1))
+	if snap.CompressionLevel != 1 {
+		t.Errorf("CompressionLevel = %d, want 1", snap.CompressionLevel)
+	}
+	if snap.Codec != "" || snap.CompressionCodec() != CodecGzip {
+		t.Errorf("codec = %q, want gzip recorded as default", snap.Codec)
+	}
+	if _, err := os.Stat(mgr.snapshotPath("test-vm", "snap1", "")); err != nil {
+		t.Errorf("gzip snapshot should be stored as .raw.gz: %v", err)
+	}
+}
+
+func TestSnapshotCompressionZstd(t *testing.T) {
+	if err := EnsureZstd(); err != nil {
+		t.Skip(err)
+	}
+	tmpDir := t.TempDir()
+	mgr := NewSnapshotManager(tmpDir, nil)
+	content := newCompressionTestVM(t, tmpDir)
+
+	snap := roundTripSnapshot(t, mgr, tmpDir, content, WithCompressionCodec(CodecZstd), WithCompressionLevel(15))
+	if snap.Codec != CodecZstd || snap.CompressionLevel != 15 {
+		t.Errorf("entry = %s level %d, want zstd level 15", snap.Codec, snap.CompressionLevel)
+	}
+
+	snapPath := filepath.Join(tmpDir, "data", "test-vm", "snapshots", "snap1.raw.zst")
+	if _, err := os.Stat(snapPath); err != nil {
+		t.Fatalf("zstd snapshot should be stored as .raw.zst: %v", err)
+	}
+	if err := mgr.VerifySnapshot("test-vm", "snap1"); err != nil {
+		t.Errorf("VerifySnapshot: %v", err)
+	}
+	if err := mgr.DeleteSnapshot("test-vm", "snap1"); err != nil {
+		t.Fatalf("DeleteSnapshot: %v", err)
+	}
+	if _, err := os.Stat(snapPath); !os.IsNotExist(err) {
+		t.Error("DeleteSnapshot should remove the .raw.zst file")
+	}
+}
+
+func TestSnapshotLegacyMetadataIsGzip(t *testing.T) {
+	tmpDir := t.TempDir()
+	mgr := NewSnapshotManager(tmpDir, nil)
+	content := newCompressionTestVM(t, tmpDir)
+
+	if err := mgr.CreateSnapshot("test-vm", "snap1", ""); err != nil {
+		t.Fatalf("CreateSnapshot: %v", err)
+	}
+
+	// Metadata written before codecs were recorded has no codec field
+	raw, err := os.ReadFile(mgr.snapshotsFile("test-vm"))
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if strings.Contains(string(raw), "codec") {
+		t.Fatalf("default gzip snapshot should not record a codec: %s", raw)
+	}
+
+	os.WriteFile(filepath.Join(tmpDir, "data", "test-vm", "disk.raw"), nil, 0644)
+	if err := mgr.RestoreSnapshot("test-vm", "snap1"); err != nil {
+		t.Fatalf("RestoreSnapshot: %v", err)
+	}
+	got, _ := os.ReadFile(filepath.Join(tmpDir, "data", "test-vm", "disk.raw"))
+	if string(got) != string(content) {
+		t.Error("legacy snapshot not restored as gzip")
+	}
+}
+
+func TestValidateCompression(t *testing.T) {
+	tests := []struct {
+		codec   string
+		level   int
+		wantErr bool
+	}{
+		{"", 0, false},
+		{CodecGzip, 1, false},
+		{CodecGzip, 9, false},
+		{CodecGzip, 10, true},
+		{CodecGzip, -1, true},
+		{CodecZstd, 19, false},
+		{CodecZstd, 20, true},
+		{"lz4", 0, true},
+	}
+
+	for _, tt := range tests {
+		err := ValidateCompression(tt.codec, tt.level)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ValidateCompression(%q, %d) error = %v, wantErr %v", tt.codec, tt.level, err, tt.wantErr)
+		}
+	}
+}
+
+func TestListSnapshotsFiltered(t *testing.T) {
+	mgr := NewSnapshotManager(t.TempDir(), nil)
+	day := func(d int) time.Time { return time.Date(2024, 1, d, 12, 0, 0, 0, time.UTC) }
+	data := &SnapshotData{Snapshots: []SnapshotEntry{
+		{Name: "base", Description: "Fresh install", CreatedAt: day(1), DiskSize: 1 << 30},
+		{Name: "pre-upgrade", Description: "Before the PRE-UPGRADE run", CreatedAt: day(10), DiskSize: 3 << 30},
+		{Name: "tools", Description: "dev tools", CreatedAt: day(5), DiskSize: 2 << 30},
+		{Name: "post", Description: "pre-upgrade rollback point", CreatedAt: day(20), DiskSize: 2 << 30},
+	}}
+	if err := mgr.Save("test-vm", data); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	names := func(snaps []SnapshotEntry) []string {
+		out := []string{}
+		for _, s := range snaps {
+			out = append(out, s.Name)
+		}
+		return out
+	}
+	after, before := day(5), day(20)
+	minSize, maxSize := int64(2<<30), int64(2<<30)
+
+	tests := []struct {
+		name   string
+		filter SnapshotFilter
+		want   []string
+	}{
+		{"all newest first", SnapshotFilter{}, []string{"post", "pre-upgrade", "tools", "base"}},
+		{"after is inclusive", SnapshotFilter{After: &after}, []string{"post", "pre-upgrade", "tools"}},
+		{"before is exclusive", SnapshotFilter{Before: &before}, []string{"pre-upgrade", "tools", "base"}},
+		{"description ignores case", SnapshotFilter{Description: "pre-upgrade"}, []string{"post", "pre-upgrade"}},
+		{"size range", SnapshotFilter{MinSize: &minSize, MaxSize: &maxSize}, []string{"post", "tools"}},
+		{"sort by size", SnapshotFilter{SortBy: SnapshotSortSize}, []string{"pre-upgrade", "tools", "post", "base"}},
+		{"no match", SnapshotFilter{Description: "nothing"}, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := mgr.ListSnapshotsFiltered("test-vm", tt.filter)
+			if err != nil {
+				t.Fatalf("ListSnapshotsFiltered: %v", err)
+			}
+			if !reflect.DeepEqual(names(got), tt.want) {
+				t.Errorf("got %v, want %v", names(got), tt.want)
+			}
+		})
+	}
+
+	if _, err := mgr.ListSnapshotsFiltered("test-vm", SnapshotFilter{SortBy: "name"}); err == nil {
+		t.Error("unknown sort order should be rejected")
+	}
+}
+
+func TestPruneScheduled(t *testing.T) {
+	tmpDir := t.TempDir()
+	mgr := NewSnapshotManager(tmpDir, nil)
+	newRetentionTestVM(t, mgr, tmpDir, map[string]int{"manual": 10})
+
+	for _, name := range []string{"sched-1", "sched-2", "sched-3"} {
+		if err := mgr.CreateSnapshot("test-vm", name, "", WithScheduled()); err != nil {
+			t.Fatalf("CreateSnapshot: %v", err)
+		}
+	}
+	// Make creation order unambiguous
+	hoursAgo := map[string]int{"sched-1": 3, "sched-2": 2, "sched-3": 1}
+	data, err := mgr.Load("test-vm")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	for i := range data.Snapshots {
+		if s := &data.Snapshots[i]; s.Scheduled {
+			s.CreatedAt = time.Now().Add(-time.Duration(hoursAgo[s.Name]) * time.Hour)
+		}
+	}
+	if err := mgr.Save("test-vm", data); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	deleted, err := mgr.PruneScheduled("test-vm", 1)
+	if err != nil {
+		t.Fatalf("PruneScheduled: %v", err)
+	}
+	if want := []string{"sched-1", "sched-2"}; !reflect.DeepEqual(deleted, want) {
+		t.Errorf("deleted = %v, want %v", deleted, want)
+	}
+	if got, want := snapshotNames(t, mgr), []string{"manual", "sched-3"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("remaining = %v, want %v", got, want)
+	}
+
+	if deleted, _ := mgr.PruneScheduled("test-vm", 0); deleted != nil {
+		t.Errorf("keep 0 deleted %v", deleted)
+	}
+}
+
+// writeDeltaDisk writes a disk of n blocks whose block i is filled with
+// fill(i).
+func writeDeltaDisk(t *testing.T, path string, n int, fill func(i int) byte) {
+	t.Helper()
+	data := make([]byte, 0, n*deltaBlockSize)
+	for i := 0; i < n; i++ {
+		data = append(data, bytes.Repeat([]byte{fill(i)}, deltaBlockSize)...)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+}
+
+// countingProgress records the progress of the last operation.
+type countingProgress struct {
+	name string
+	done int64
+}
+
+func (p *countingProgress) Start(name string, total int64) { p.name, p.done = name, 0 }
+func (p *countingProgress) Update(done int64)              { p.done = done }
+func (p *countingProgress) Complete()                      {}
+func (p *countingProgress) Error(err error)                {}
+func (p *countingProgress) Message(msg string)             {}
+
+func TestSnapshotManagerDeltaRestore(t *testing.T) {
+	tmpDir := t.TempDir()
+	prog := &countingProgress{}
+	mgr := NewSnapshotManager(tmpDir, prog)
+	vmName := "test-vm"
+	diskDir := filepath.Join(tmpDir, "data", vmName)
+	os.MkdirAll(diskDir, 0755)
+	diskPath := filepath.Join(diskDir, "disk.raw")
+
+	writeDeltaDisk(t, diskPath, 4, func(i int) byte { return 'a' })
+	if err := mgr.CreateSnapshot(vmName, "base", ""); err != nil {
+		t.Fatalf("CreateSnapshot base: %v", err)
+	}
+	writeDeltaDisk(t, diskPath, 4, func(i int) byte {
+		if i == 2 {
+			return 'b'
+		}
+		return 'a'
+	})
+	if err := mgr.CreateSnapshot(vmName, "changed", ""); err != nil {
+		t.Fatalf("CreateSnapshot changed: %v", err)
+	}
+	want, _ := os.ReadFile(diskPath)
+
+	snap, err := mgr.GetSnapshot(vmName, "changed")
+	if err != nil {
+		t.Fatalf("GetSnapshot: %v", err)
+	}
+	if !snap.IsDeltaRestore || snap.DiskChecksum == "" {
+		t.Fatalf("new snapshot not delta capable: %+v", snap)
+	}
+
+	// Put the disk back at base, then move forward with a delta
+	if err := mgr.RestoreSnapshot(vmName, "base"); err != nil {
+		t.Fatalf("RestoreSnapshot: %v", err)
+	}
+	if err := mgr.DeltaRestore(vmName, "base", "changed"); err != nil {
+		t.Fatalf("DeltaRestore: %v", err)
+	}
+	got, _ := os.ReadFile(diskPath)
+	if !bytes.Equal(got, want) {
+		t.Fatal("disk does not match the target snapshot after delta restore")
+	}
+	if !strings.Contains(prog.name, "delta from base") {
+		t.Errorf("expected a delta restore, progress was %q", prog.name)
+	}
+}
+
+func TestSnapshotManagerDeltaRestoreFallsBack(t *testing.T) {
+	tmpDir := t.TempDir()
+	prog := &countingProgress{}
+	mgr := NewSnapshotManager(tmpDir, prog)
+	vmName := "test-vm"
+	diskDir := filepath.Join(tmpDir, "data", vmName)
+	os.MkdirAll(diskDir, 0755)
+	diskPath := filepath.Join(diskDir, "disk.raw")
+
+	writeDeltaDisk(t, diskPath, 2, func(i int) byte { return 'a' })
+	if err := mgr.CreateSnapshot(vmName, "base", ""); err != nil {
+		t.Fatalf("CreateSnapshot base: %v", err)
+	}
+	writeDeltaDisk(t, diskPath, 3, func(i int) byte { return byte('a' + i) })
+	if err := mgr.CreateSnapshot(vmName, "grown", ""); err != nil {
+		t.Fatalf("CreateSnapshot grown: %v", err)
+	}
+	want, _ := os.ReadFile(diskPath)
+
+	// The disk no longer holds base, so a full restore is needed
+	writeDeltaDisk(t, diskPath, 2, func(i int) byte { return 'z' })
+	if err := mgr.DeltaRestore(vmName, "base", "grown"); err != nil {
+		t.Fatalf("DeltaRestore: %v", err)
+	}
+	got, _ := os.ReadFile(diskPath)
+	if !bytes.Equal(got, want) {
+		t.Fatal("disk does not match the target snapshot after fallback")
+	}
+	if strings.Contains(prog.name, "delta") {
+		t.Errorf("expected a full restore, progress was %q", prog.name)
+	}
+
+	// Snapshots without the capability also restore in full
+	data, _ := mgr.Load(vmName)
+	for i := range data.Snapshots {
+		data.Snapshots[i].IsDeltaRestore = false
+	}
+	mgr.Save(vmName, data)
+	if err := mgr.RestoreSnapshot(vmName, "base"); err != nil {
+		t.Fatalf("RestoreSnapshot: %v", err)
+	}
+	if err := mgr.DeltaRestore(vmName, "base", "grown"); err != nil {
+		t.Fatalf("DeltaRestore: %v", err)
+	}
+	if strings.Contains(prog.name, "delta") {
+		t.Errorf("expected a full restore, progress was %q", prog.name)
+	}
+	got, _ = os.ReadFile(diskPath)
+	if !bytes.Equal(got, want) {
+		t.Fatal("disk does not match the target snapshot")
+	}
+}
+
+func TestSnapshotManagerDeltaRestoreShrinks(t *testing.T) {
+	tmpDir := t.TempDir()
+	mgr := NewSnapshotManager(tmpDir, nil)
+	vmName := "test-vm"
+	diskDir := filepath.Join(tmpDir, "data", vmName)
+	os.MkdirAll(diskDir, 0755)
+	diskPath := filepath.Join(diskDir, "disk.raw")
+
+	os.WriteFile(diskPath, []byte("short"), 0644)
+	if err := mgr.CreateSnapshot(vmName, "small", ""); err != nil {
+		t.Fatalf("CreateSnapshot small: %v", err)
+	}
+	writeDeltaDisk(t, diskPath, 2, func(i int) byte { return 'x' })
+	if err := mgr.CreateSnapshot(vmName, "large", ""); err != nil {
+		t.Fatalf("CreateSnapshot large: %v", err)
+	}
+	if err := mgr.DeltaRestore(vmName, "large", "small"); err != nil {
+		t.Fatalf("DeltaRestore: %v", err)
+	}
+	got, _ := os.ReadFile(diskPath)
+	if string(got) != "short" {
+		t.Errorf("disk = %d bytes, want %q", len(got), "short")
+	}
+}
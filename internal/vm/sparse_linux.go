@@ -0,0 +1,30 @@
+//go:build linux
+
+package vm
+
+import (
+	"errors"
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// deallocate punches a hole over the first size bytes of f so none of its
+// blocks stay allocated, whatever the filesystem did on truncate.
+// Filesystems without hole punching keep the file as truncate left it.
+func deallocate(f *os.File, size int64) error {
+	err := unix.Fallocate(int(f.Fd()), unix.FALLOC_FL_PUNCH_HOLE|unix.FALLOC_FL_KEEP_SIZE, 0, size)
+	if errors.Is(err, unix.EOPNOTSUPP) || errors.Is(err, unix.ENOSYS) {
+		return nil
+	}
+	return err
+}
+
+// allocatedBytes returns the bytes actually allocated on disk for info.
+func allocatedBytes(info os.FileInfo) int64 {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return st.Blocks * 512
+	}
+	return info.Size()
+}
@@ -0,0 +1,16 @@
+//go:build !linux && !darwin
+
+package vm
+
+import "os"
+
+// deallocate is not supported on this platform.
+func deallocate(f *os.File, size int64) error {
+	return nil
+}
+
+// allocatedBytes assumes the file is fully allocated, as block counts
+// are not available on this platform.
+func allocatedBytes(info os.FileInfo) int64 {
+	return info.Size()
+}
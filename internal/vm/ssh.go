@@ -1,6 +1,7 @@
 package vm
 
 import (
+	"bytes"
 	"crypto/ed25519"
 	"crypto/rand"
 	"encoding/pem"
@@ -8,6 +9,8 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
+	"strings"
 
 	"golang.org/x/crypto/ssh"
 )
@@ -194,6 +197,56 @@ func (m *SSHKeyManager) InjectSSHKey(mountPoint string) error {
 	return nil
 }
 
+// sshKeyMarkerSuffix names the file next to a disk image that records
+// which public key has already been authorized inside it.
+const sshKeyMarkerSuffix = ".sshkey"
+
+// InjectSSHKeyImage authorizes the public key for root inside an unmounted
+// disk image using guestfish, and serves SSH on vsock as InstallVsockSSH
+// does. Cloud images boot directly from their disk
+// and are never mounted on the host, so InjectSSHKey cannot be used.
+// The image is left untouched if it already carries the current key.
+func (m *SSHKeyManager) InjectSSHKeyImage(diskPath string) error {
+	pubKeyContent, err := m.PublicKeyContent()
+	if err != nil {
+		return err
+	}
+
+	marker := diskPath + sshKeyMarkerSuffix
+	if data, err := os.ReadFile(marker); err == nil && string(data) == pubKeyContent {
+		return nil
+	}
+
+	if _, err := exec.LookPath("guestfish"); err != nil {
+		return fmt.Errorf("guestfish not found; install libguestfs-tools to add SSH keys to %s", filepath.Base(diskPath))
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.Command("guestfish", "-a", diskPath, "-i")
+	cmd.Stdin = strings.NewReader(guestfishInjectScript(pubKeyContent) + guestfishVsockSSHScript())
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("guestfish: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	return os.WriteFile(marker, []byte(pubKeyContent), 0644)
+}
+
+// guestfishInjectScript returns the guestfish commands that append pubKey
+// to root's authorized_keys, keeping any keys the image already has, with
+// the permissions sshd requires. The key goes on a line of its own in case
+// the existing file does not end in a newline; sshd ignores blank lines.
+func guestfishInjectScript(pubKey string) string {
+	line := strconv.Quote("\n" + strings.TrimSpace(pubKey) + "\n")
+	return fmt.Sprintf(`mkdir-p /root/.ssh
+chmod 0700 /root/.ssh
+write-append /root/.ssh/authorized_keys %s
+chmod 0600 /root/.ssh/authorized_keys
+chown 0 0 /root/.ssh
+chown 0 0 /root/.ssh/authorized_keys
+`, line)
+}
+
 // stringReader returns an io.Reader for a string.
 type stringReaderType struct {
 	s string
@@ -230,6 +230,54 @@ func TestSSHKeyManagerInjectWithoutKey(t *testing.T) {
 	}
 }
 
+func TestGuestfishInjectScript(t *testing.T) {
+	script := guestfishInjectScript("ssh-ed25519 AAAAC3Nza vmterminal\n")
+
+	for _, want := range []string{
+		"mkdir-p /root/.ssh\n",
+		"chmod 0700 /root/.ssh\n",
+		`write-append /root/.ssh/authorized_keys "\nssh-ed25519 AAAAC3Nza vmterminal\n"` + "\n",
+		"chmod 0600 /root/.ssh/authorized_keys\n",
+		"chown 0 0 /root/.ssh/authorized_keys\n",
+	} {
+		if !strings.Contains(script, want) {
+			t.Errorf("script missing %q:\n%s", want, script)
+		}
+	}
+}
+
+func TestSSHKeyManagerInjectSSHKeyImageMarker(t *testing.T) {
+	tmpDir := t.TempDir()
+	manager := NewSSHKeyManager(tmpDir)
+	if _, _, err := manager.EnsureKeyPair(); err != nil {
+		t.Fatalf("EnsureKeyPair() error = %v", err)
+	}
+	pub, err := manager.PublicKeyContent()
+	if err != nil {
+		t.Fatalf("PublicKeyContent() error = %v", err)
+	}
+
+	disk := filepath.Join(tmpDir, "rootfs.raw")
+	if err := os.WriteFile(disk+sshKeyMarkerSuffix, []byte(pub), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// A matching marker means the key is already in the image, so
+	// guestfish is never needed.
+	if err := manager.InjectSSHKeyImage(disk); err != nil {
+		t.Errorf("InjectSSHKeyImage() with current marker error = %v", err)
+	}
+}
+
+func TestSSHKeyManagerInjectSSHKeyImageWithoutKey(t *testing.T) {
+	tmpDir := t.TempDir()
+	manager := NewSSHKeyManager(tmpDir)
+
+	if err := manager.InjectSSHKeyImage(filepath.Join(tmpDir, "rootfs.raw")); err == nil {
+		t.Error("InjectSSHKeyImage() should error without generated keys")
+	}
+}
+
 func min(a, b int) int {
 	if a < b {
 		return a
@@ -6,10 +6,27 @@ import (
 	"os"
 	"path/filepath"
 	"time"
+
+	"github.com/javanstorm/vmterminal/internal/filelock"
 )
 
+// migrations upgrade a loaded state from one schema version to the next:
+// migrations[i] takes a version i state to version i+1, so the version this
+// build writes is len(migrations). State files from before schema
+// versioning have version 0. Append a migration when a field is added
+// whose zero value is not what an older file should get.
+var migrations = []func(*PersistentState){
+	// 0 to 1: every field those files can lack was added with a zero value
+	// meaning "none" or "unknown", so only the version is new.
+	func(*PersistentState) {},
+}
+
 // PersistentState holds VM state that survives restarts.
 type PersistentState struct {
+	// SchemaVersion is the state.json layout version the state was
+	// written with. See migrations.
+	SchemaVersion int `json:"schema_version"`
+
 	// LastBoot is when the VM was last started.
 	LastBoot time.Time `json:"last_boot,omitempty"`
 
@@ -19,7 +36,8 @@ type PersistentState struct {
 	// BootCount is the number of times the VM has booted.
 	BootCount int `json:"boot_count"`
 
-	// KernelVersion is the cached kernel version string.
+	// KernelVersion is the kernel version the guest reported on its
+	// console during the last boot, empty until the banner is seen.
 	KernelVersion string `json:"kernel_version,omitempty"`
 
 	// DiskSizeMB is the configured disk size.
@@ -27,6 +45,49 @@ type PersistentState struct {
 
 	// CleanShutdown indicates if the last shutdown was clean.
 	CleanShutdown bool `json:"clean_shutdown"`
+
+	// LastSuspend is when the VM was last suspended.
+	LastSuspend time.Time `json:"last_suspend,omitempty"`
+
+	// LastResume is when the VM was last resumed.
+	LastResume time.Time `json:"last_resume,omitempty"`
+
+	// Suspended indicates the VM is currently paused.
+	Suspended bool `json:"suspended"`
+
+	// PauseAck is when the VM process last handled a suspend or resume
+	// request, and PauseError why that request failed, if it did.
+	PauseAck   time.Time `json:"pause_ack,omitempty"`
+	PauseError string    `json:"pause_error,omitempty"`
+
+	// TapDevice is the tap interface used by the running VM (Linux only).
+	TapDevice string `json:"tap_device,omitempty"`
+
+	// MACAddress is the hardware address of the running VM's first NIC,
+	// which the driver may have generated.
+	MACAddress string `json:"mac_address,omitempty"`
+
+	// PanicCount is the number of kernel panics detected on the console.
+	PanicCount int `json:"panic_count,omitempty"`
+
+	// LastPanic is when the last kernel panic was detected.
+	LastPanic time.Time `json:"last_panic,omitempty"`
+
+	// ConsoleLogPath is the absolute path of the --console-log file of the
+	// last boot. It is kept after shutdown so the log can still be read.
+	ConsoleLogPath string `json:"console_log_path,omitempty"`
+
+	// ConsoleLogTimestamps is set when each line of the console log starts
+	// with the time it was received (run --console-log-timestamps).
+	ConsoleLogTimestamps bool `json:"console_log_timestamps,omitempty"`
+
+	// PackageManager is the name of the package manager last detected in
+	// the guest, as returned by PackageManager.Name.
+	PackageManager string `json:"package_manager,omitempty"`
+
+	// BootMilestones maps the boot milestones of the last boot, such as
+	// MilestoneLoginPrompt, to how long after the start they were reached.
+	BootMilestones map[string]time.Duration `json:"boot_milestones,omitempty"`
 }
 
 // StateFile manages persistent state storage.
@@ -41,26 +102,94 @@ func NewStateFile(dataDir string) *StateFile {
 	}
 }
 
-// Load reads the state from disk.
+// Load reads the state from disk. A state written with an older schema
+// version is migrated to the current one and saved back.
 func (s *StateFile) Load() (*PersistentState, error) {
+	state, migrated, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+	// Saving the upgrade only spares the next Load the migration, so a
+	// read-only or locked state file must not stop the state being read
+	if migrated {
+		if err := s.update(func(*PersistentState) {}); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: could not save migrated state: %v\n", err)
+		}
+	}
+	return state, nil
+}
+
+// read parses the state file, migrating it in memory, and reports whether
+// a migration ran.
+func (s *StateFile) read() (*PersistentState, bool, error) {
 	data, err := os.ReadFile(s.path)
 	if os.IsNotExist(err) {
-		return &PersistentState{}, nil
+		return &PersistentState{SchemaVersion: len(migrations)}, false, nil
 	}
 	if err != nil {
-		return nil, fmt.Errorf("read state file: %w", err)
+		return nil, false, fmt.Errorf("read state file: %w", err)
 	}
 
 	var state PersistentState
 	if err := json.Unmarshal(data, &state); err != nil {
-		return nil, fmt.Errorf("parse state file: %w", err)
+		return nil, false, fmt.Errorf("parse state file: %w", err)
+	}
+	return &state, migrateState(&state), nil
+}
+
+// migrateState runs the migrations from state's schema version up to the
+// latest and reports whether any ran. A state from a newer build is left
+// as it is.
+func migrateState(state *PersistentState) bool {
+	if state.SchemaVersion < 0 {
+		state.SchemaVersion = 0
+	}
+	migrated := false
+	for state.SchemaVersion < len(migrations) {
+		migrations[state.SchemaVersion](state)
+		state.SchemaVersion++
+		migrated = true
+	}
+	return migrated
+}
+
+// lock takes the state lock, which serialises every read-modify-write of
+// the state file: the VM process and commands such as suspend or pkg
+// record into the same file. Callers must Release it.
+func (s *StateFile) lock() (*FileLock, error) {
+	return filelock.Acquire(filepath.Dir(s.path), StateLockFile, "VM state", stateLockTimeout)
+}
+
+// update applies fn to the current state and saves the result, holding
+// the state lock throughout so concurrent updates are not lost.
+func (s *StateFile) update(fn func(*PersistentState)) error {
+	lock, err := s.lock()
+	if err != nil {
+		return err
 	}
+	defer lock.Release()
 
-	return &state, nil
+	state, _, err := s.read()
+	if err != nil {
+		return err
+	}
+	fn(state)
+	return s.write(state)
 }
 
-// Save writes the state to disk.
+// Save replaces the state on disk.
 func (s *StateFile) Save(state *PersistentState) error {
+	lock, err := s.lock()
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+	return s.write(state)
+}
+
+// write writes state atomically through a temporary file of its own, so
+// writers never share one.
+func (s *StateFile) write(state *PersistentState) error {
 	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
 		return fmt.Errorf("create state dir: %w", err)
 	}
@@ -70,40 +199,132 @@ func (s *StateFile) Save(state *PersistentState) error {
 		return fmt.Errorf("marshal state: %w", err)
 	}
 
-	// Write atomically
-	tmpPath := s.path + ".tmp"
-	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("write state file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write state file: %w", err)
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write state file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
 		return fmt.Errorf("write state file: %w", err)
 	}
 
-	return os.Rename(tmpPath, s.path)
+	return os.Rename(tmp.Name(), s.path)
 }
 
 // RecordBoot updates state for a new boot.
 func (s *StateFile) RecordBoot() error {
-	state, err := s.Load()
-	if err != nil {
-		return err
-	}
-
-	state.LastBoot = time.Now()
-	state.BootCount++
-	state.CleanShutdown = false
-
-	return s.Save(state)
+	return s.update(func(state *PersistentState) {
+		state.LastBoot = time.Now()
+		state.BootCount++
+		state.CleanShutdown = false
+		state.Suspended = false
+		state.TapDevice = ""     // Recorded again if this boot uses one
+		state.MACAddress = ""    // Recorded again once the VM has started
+		state.KernelVersion = "" // Recorded again once the banner is seen
+		state.BootMilestones = nil
+	})
 }
 
 // RecordShutdown updates state for a shutdown.
 func (s *StateFile) RecordShutdown(clean bool) error {
-	state, err := s.Load()
-	if err != nil {
-		return err
-	}
+	return s.update(func(state *PersistentState) {
+		state.LastShutdown = time.Now()
+		state.CleanShutdown = clean
+		state.Suspended = false
+		state.TapDevice = ""
+		state.MACAddress = ""
+	})
+}
+
+// RecordSuspend updates state when the VM is paused.
+func (s *StateFile) RecordSuspend() error {
+	return s.update(func(state *PersistentState) {
+		state.LastSuspend = time.Now()
+		state.Suspended = true
+	})
+}
+
+// RecordResume updates state when a paused VM continues.
+func (s *StateFile) RecordResume() error {
+	return s.update(func(state *PersistentState) {
+		state.LastResume = time.Now()
+		state.Suspended = false
+	})
+}
+
+// RecordPauseAck records that a suspend or resume request was handled,
+// and the error it failed with, if any.
+func (s *StateFile) RecordPauseAck(err error) error {
+	return s.update(func(state *PersistentState) {
+		state.PauseAck = time.Now()
+		state.PauseError = ""
+		if err != nil {
+			state.PauseError = err.Error()
+		}
+	})
+}
+
+// RecordTapDevice records the tap interface attached to the running VM.
+func (s *StateFile) RecordTapDevice(name string) error {
+	return s.update(func(state *PersistentState) {
+		state.TapDevice = name
+	})
+}
 
-	state.LastShutdown = time.Now()
-	state.CleanShutdown = clean
+// RecordMACAddress records the MAC of the running VM's first NIC.
+func (s *StateFile) RecordMACAddress(mac string) error {
+	return s.update(func(state *PersistentState) {
+		state.MACAddress = mac
+	})
+}
+
+// RecordConsoleLog records where the running VM writes its console log,
+// and whether its lines are timestamped.
+func (s *StateFile) RecordConsoleLog(path string, timestamps bool) error {
+	return s.update(func(state *PersistentState) {
+		state.ConsoleLogPath = path
+		state.ConsoleLogTimestamps = timestamps
+	})
+}
+
+// RecordKernelVersion records the kernel version the guest booted.
+func (s *StateFile) RecordKernelVersion(version string) error {
+	return s.update(func(state *PersistentState) {
+		state.KernelVersion = version
+	})
+}
+
+// RecordPackageManager records the package manager detected in the guest.
+func (s *StateFile) RecordPackageManager(name string) error {
+	return s.update(func(state *PersistentState) {
+		state.PackageManager = name
+	})
+}
+
+// RecordBootMilestone records when the current boot reached a milestone.
+func (s *StateFile) RecordBootMilestone(name string, elapsed time.Duration) error {
+	return s.update(func(state *PersistentState) {
+		if state.BootMilestones == nil {
+			state.BootMilestones = make(map[string]time.Duration)
+		}
+		state.BootMilestones[name] = elapsed
+	})
+}
 
-	return s.Save(state)
+// RecordPanic records a kernel panic detected on the VM console.
+func (s *StateFile) RecordPanic() error {
+	return s.update(func(state *PersistentState) {
+		state.PanicCount++
+		state.LastPanic = time.Now()
+	})
 }
 
 // Path returns the state file path.
@@ -1,6 +1,10 @@
 package vm
 
-import "testing"
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
 
 func TestStateString(t *testing.T) {
 	tests := []struct {
@@ -14,6 +18,7 @@ func TestStateString(t *testing.T) {
 		{"stopping", StateStopping, "stopping"},
 		{"stopped", StateStopped, "stopped"},
 		{"error", StateError, "error"},
+		{"suspended", StateSuspended, "suspended"},
 		{"unknown/invalid", State(99), "unknown"},
 		{"negative", State(-1), "unknown"},
 	}
@@ -48,4 +53,49 @@ func TestStateConstants(t *testing.T) {
 	if StateError != 5 {
 		t.Errorf("StateError = %d, want 5", StateError)
 	}
+	if StateSuspended != 6 {
+		t.Errorf("StateSuspended = %d, want 6", StateSuspended)
+	}
+}
+
+func TestWriteDOT(t *testing.T) {
+	var buf strings.Builder
+	if err := WriteDOT(&buf); err != nil {
+		t.Fatalf("WriteDOT: %v", err)
+	}
+	out := buf.String()
+
+	if !strings.HasPrefix(out, "digraph vm_state {") || !strings.HasSuffix(out, "}\n") {
+		t.Errorf("output is not a digraph:\n%s", out)
+	}
+	for _, s := range allStates {
+		if !strings.Contains(out, fmt.Sprintf("%q [label=", s.String())) {
+			t.Errorf("missing node for state %s", s)
+		}
+	}
+	for _, want := range []string{
+		`"ready" -> "running" [label="Start", style=solid]`,
+		`"running" -> "stopping" [label="Stop", style=solid]`,
+		`"stopping" -> "error" [label="Stop (driver failure)\n(mu released)", style=dashed]`,
+		`"ready" -> "error" [label="Start (failure)", style=solid]`,
+		`"stopping" -> "error" [label="Stop timeout (kill failure)\n(mu released)", style=dashed]`,
+		`"error" -> "stopped" [label="VM exit (clean)", style=solid]`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing edge %s", want)
+		}
+	}
+}
+
+func TestTransitionsCoverStates(t *testing.T) {
+	seen := make(map[State]bool)
+	for _, tr := range Transitions {
+		seen[tr.From] = true
+		seen[tr.To] = true
+	}
+	for _, s := range allStates {
+		if !seen[s] {
+			t.Errorf("state %s has no transitions", s)
+		}
+	}
 }
@@ -0,0 +1,93 @@
+package vm
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// Transition is one edge of the Manager state machine.
+type Transition struct {
+	From    State
+	To      State
+	Trigger string // Manager method or event that causes the transition
+	// Locked reports whether the state check and the assignment happen in
+	// one critical section of Manager.mu. Unlocked transitions release the
+	// lock around a driver call, so another caller can observe or change
+	// the state in between.
+	Locked bool
+}
+
+// Transitions is the Manager state machine as implemented in manager.go.
+// Keep it in sync when adding or changing a state assignment there.
+var Transitions = []Transition{
+	{StateNew, StateReady, "Prepare", true},
+	{StateNew, StateError, "Prepare (failure)", true},
+	{StateStopped, StateReady, "Prepare", true},
+	{StateStopped, StateError, "Prepare (failure)", true},
+	{StateReady, StateRunning, "Start", true},
+	{StateReady, StateError, "Start (failure)", true},
+	{StateRunning, StateSuspended, "Suspend", true},
+	{StateSuspended, StateRunning, "Resume", true},
+	{StateRunning, StateStopping, "Stop", true},
+	{StateStopping, StateError, "Stop (driver failure)", false},
+	{StateRunning, StateError, "Kill (driver failure)", false},
+	{StateSuspended, StateError, "Kill (driver failure)", false},
+	{StateStopping, StateError, "Kill (driver failure)", false},
+	{StateStopping, StateError, "Stop timeout (kill failure)", false},
+	{StateRunning, StateStopped, "VM exit (clean)", true},
+	{StateRunning, StateError, "VM exit (error)", true},
+	{StateStopping, StateStopped, "VM exit (clean)", true},
+	{StateStopping, StateError, "VM exit (error)", true},
+	{StateSuspended, StateStopped, "VM exit (clean)", true},
+	{StateSuspended, StateError, "VM exit (error)", true},
+	{StateError, StateStopped, "VM exit (clean)", true},
+}
+
+// allStates lists every State in declaration order.
+var allStates = []State{
+	StateNew, StateReady, StateRunning, StateSuspended,
+	StateStopping, StateStopped, StateError,
+}
+
+// WriteDOT writes the state machine as a Graphviz digraph. Transitions made
+// entirely under Manager.mu are drawn solid; those that drop the lock
+// between checking and setting the state are drawn dashed.
+func WriteDOT(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+
+	fmt.Fprintln(bw, "digraph vm_state {")
+	fmt.Fprintln(bw, "\trankdir=LR;")
+	fmt.Fprintln(bw, "\tnode [shape=box, style=rounded];")
+	for _, s := range allStates {
+		attrs := ""
+		switch s {
+		case StateNew:
+			attrs = ", penwidth=2"
+		case StateError:
+			attrs = ", color=red"
+		}
+		fmt.Fprintf(bw, "\t%q [label=%q%s];\n", s.String(), s.String(), attrs)
+	}
+	fmt.Fprintln(bw)
+
+	for _, t := range Transitions {
+		style := "solid"
+		label := t.Trigger
+		if !t.Locked {
+			style = "dashed"
+			label += "\\n(mu released)"
+		}
+		fmt.Fprintf(bw, "\t%q -> %q [label=\"%s\", style=%s];\n",
+			t.From.String(), t.To.String(), label, style)
+	}
+
+	fmt.Fprintln(bw)
+	fmt.Fprintln(bw, "\tsubgraph cluster_legend {")
+	fmt.Fprintln(bw, "\t\tlabel=\"Legend\";")
+	fmt.Fprintln(bw, "\t\tlegend [shape=plaintext, label=\"solid: check and set under mu\\ndashed: mu released around driver call\"];")
+	fmt.Fprintln(bw, "\t}")
+	fmt.Fprintln(bw, "}")
+
+	return bw.Flush()
+}
@@ -1,8 +1,11 @@
 package vm
 
 import (
+	"encoding/json"
+	"errors"
 	"os"
 	"path/filepath"
+	"sync"
 	"testing"
 	"time"
 )
@@ -114,6 +117,131 @@ func TestStateFileDirtyShutdown(t *testing.T) {
 	}
 }
 
+func TestStateFileSuspendResume(t *testing.T) {
+	dir := t.TempDir()
+	sf := NewStateFile(dir)
+
+	if err := sf.RecordBoot(); err != nil {
+		t.Fatalf("RecordBoot failed: %v", err)
+	}
+	if err := sf.RecordSuspend(); err != nil {
+		t.Fatalf("RecordSuspend failed: %v", err)
+	}
+
+	state, err := sf.Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if !state.Suspended {
+		t.Error("state should be marked as suspended")
+	}
+	if state.LastSuspend.IsZero() {
+		t.Error("LastSuspend should be set")
+	}
+
+	if err := sf.RecordResume(); err != nil {
+		t.Fatalf("RecordResume failed: %v", err)
+	}
+	state, err = sf.Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if state.Suspended {
+		t.Error("state should not be suspended after resume")
+	}
+	if state.LastResume.IsZero() {
+		t.Error("LastResume should be set")
+	}
+
+	// Shutting down while suspended clears the flag
+	if err := sf.RecordSuspend(); err != nil {
+		t.Fatalf("RecordSuspend failed: %v", err)
+	}
+	if err := sf.RecordShutdown(false); err != nil {
+		t.Fatalf("RecordShutdown failed: %v", err)
+	}
+	state, err = sf.Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if state.Suspended {
+		t.Error("shutdown should clear suspended flag")
+	}
+}
+
+func TestStateFileRecordPanic(t *testing.T) {
+	dir := t.TempDir()
+	sf := NewStateFile(dir)
+
+	for i := 0; i < 2; i++ {
+		if err := sf.RecordPanic(); err != nil {
+			t.Fatalf("RecordPanic failed: %v", err)
+		}
+	}
+
+	state, err := sf.Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if state.PanicCount != 2 {
+		t.Errorf("PanicCount = %d, want 2", state.PanicCount)
+	}
+	if state.LastPanic.IsZero() {
+		t.Error("LastPanic should be set")
+	}
+}
+
+func TestStateFileRecordPauseAck(t *testing.T) {
+	sf := NewStateFile(t.TempDir())
+	before := time.Now()
+
+	if err := sf.RecordPauseAck(errors.New("suspend VM: not supported")); err != nil {
+		t.Fatalf("RecordPauseAck failed: %v", err)
+	}
+	state, err := sf.Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if !state.PauseAck.After(before) || state.PauseError != "suspend VM: not supported" {
+		t.Errorf("PauseAck = %v, PauseError = %q; want a new ack with the error", state.PauseAck, state.PauseError)
+	}
+
+	// A later success clears the error
+	if err := sf.RecordPauseAck(nil); err != nil {
+		t.Fatalf("RecordPauseAck failed: %v", err)
+	}
+	if state, _ = sf.Load(); state.PauseError != "" {
+		t.Errorf("PauseError = %q after a successful request", state.PauseError)
+	}
+}
+
+func TestStateFileRecordKernelVersion(t *testing.T) {
+	dir := t.TempDir()
+	sf := NewStateFile(dir)
+
+	if err := sf.RecordBoot(); err != nil {
+		t.Fatalf("RecordBoot failed: %v", err)
+	}
+	if err := sf.RecordKernelVersion("6.6.58-0-virt"); err != nil {
+		t.Fatalf("RecordKernelVersion failed: %v", err)
+	}
+
+	state, err := sf.Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if state.KernelVersion != "6.6.58-0-virt" || state.BootCount != 1 {
+		t.Errorf("state = %+v, want kernel 6.6.58-0-virt after 1 boot", state)
+	}
+	// A new boot must not report the last boot's kernel until it is seen
+	if err := sf.RecordBoot(); err != nil {
+		t.Fatalf("RecordBoot failed: %v", err)
+	}
+	if state, _ := sf.Load(); state.KernelVersion != "" {
+		t.Errorf("KernelVersion = %q after a new boot, want it cleared", state.KernelVersion)
+	}
+}
+
 func TestStateFilePath(t *testing.T) {
 	dir := t.TempDir()
 	sf := NewStateFile(dir)
@@ -139,10 +267,35 @@ func TestStateFileAtomicWrite(t *testing.T) {
 		t.Fatalf("state file should exist: %v", err)
 	}
 
-	// Verify temp file doesn't exist (atomic write cleanup)
-	tmpPath := statePath + ".tmp"
-	if _, err := os.Stat(tmpPath); !os.IsNotExist(err) {
-		t.Error("temp file should not exist after successful write")
+	// Verify no temp file is left (atomic write cleanup)
+	if tmps, _ := filepath.Glob(statePath + ".*.tmp"); len(tmps) != 0 {
+		t.Errorf("temp files left after successful write: %v", tmps)
+	}
+}
+
+func TestStateFileConcurrentUpdates(t *testing.T) {
+	dir := t.TempDir()
+
+	// Separate StateFiles stand in for separate processes
+	const n = 20
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := NewStateFile(dir).RecordPanic(); err != nil {
+				t.Errorf("RecordPanic: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	state, err := NewStateFile(dir).Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.PanicCount != n {
+		t.Errorf("PanicCount = %d, want %d (updates were lost)", state.PanicCount, n)
 	}
 }
 
@@ -251,3 +404,133 @@ func TestStateFileCreatesDirIfNeeded(t *testing.T) {
 		t.Error("path should be a directory")
 	}
 }
+
+func TestStateFileMigratesUnversionedState(t *testing.T) {
+	dir := t.TempDir()
+	sf := NewStateFile(dir)
+
+	// Written before schema versioning and before kernel_version and
+	// panic_count were added
+	v0 := `{
+  "last_boot": "2024-01-02T03:04:05Z",
+  "boot_count": 3,
+  "disk_size_mb": 8192,
+  "clean_shutdown": true,
+  "suspended": false
+}`
+	if err := os.WriteFile(sf.Path(), []byte(v0), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	state, err := sf.Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if state.SchemaVersion != len(migrations) {
+		t.Errorf("schema version = %d, want %d", state.SchemaVersion, len(migrations))
+	}
+	if state.BootCount != 3 || state.DiskSizeMB != 8192 || !state.CleanShutdown {
+		t.Errorf("existing fields not kept: %+v", state)
+	}
+	if state.KernelVersion != "" || state.PanicCount != 0 {
+		t.Errorf("new fields = %q, %d, want empty", state.KernelVersion, state.PanicCount)
+	}
+
+	// The upgraded state is written back
+	data, err := os.ReadFile(sf.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var onDisk PersistentState
+	if err := json.Unmarshal(data, &onDisk); err != nil {
+		t.Fatal(err)
+	}
+	if onDisk.SchemaVersion != len(migrations) || onDisk.BootCount != 3 {
+		t.Errorf("state on disk = %+v, want migrated", onDisk)
+	}
+}
+
+func TestStateFileLoadsWhenMigrationCannotBeSaved(t *testing.T) {
+	dir := t.TempDir()
+	sf := NewStateFile(dir)
+	if err := os.WriteFile(sf.Path(), []byte(`{"boot_count": 3}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// A directory in place of the lock file makes every save fail
+	if err := os.Mkdir(filepath.Join(dir, StateLockFile), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	state, err := sf.Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if state.SchemaVersion != len(migrations) || state.BootCount != 3 {
+		t.Errorf("state = %+v, want migrated in memory", state)
+	}
+}
+
+func TestStateFileRunsMigrationsInOrder(t *testing.T) {
+	saved := migrations
+	t.Cleanup(func() { migrations = saved })
+
+	// A later version that fills in a field older files lack
+	migrations = append(migrations[:len(migrations):len(migrations)], func(s *PersistentState) {
+		if s.BootCount > 0 && s.KernelVersion == "" {
+			s.KernelVersion = "unknown"
+		}
+	})
+
+	for version := 0; version < len(migrations); version++ {
+		dir := t.TempDir()
+		sf := NewStateFile(dir)
+		if err := sf.Save(&PersistentState{SchemaVersion: version, BootCount: 1}); err != nil {
+			t.Fatal(err)
+		}
+
+		state, err := sf.Load()
+		if err != nil {
+			t.Fatalf("version %d: Load failed: %v", version, err)
+		}
+		if state.SchemaVersion != len(migrations) {
+			t.Errorf("version %d: schema version = %d, want %d", version, state.SchemaVersion, len(migrations))
+		}
+		if state.KernelVersion != "unknown" {
+			t.Errorf("version %d: kernel version = %q, want filled in by migration", version, state.KernelVersion)
+		}
+	}
+}
+
+func TestStateFileLeavesCurrentStateAlone(t *testing.T) {
+	dir := t.TempDir()
+	sf := NewStateFile(dir)
+
+	state, err := sf.Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if state.SchemaVersion != len(migrations) {
+		t.Errorf("new state schema version = %d, want %d", state.SchemaVersion, len(migrations))
+	}
+	if _, err := os.Stat(sf.Path()); !os.IsNotExist(err) {
+		t.Error("Load of a new state should not create the file")
+	}
+
+	// A state from a newer build is not downgraded or rewritten
+	newer := &PersistentState{SchemaVersion: len(migrations) + 1, BootCount: 2}
+	if err := sf.Save(newer); err != nil {
+		t.Fatal(err)
+	}
+	before, _ := os.Stat(sf.Path())
+	state, err = sf.Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if state.SchemaVersion != len(migrations)+1 {
+		t.Errorf("schema version = %d, want %d", state.SchemaVersion, len(migrations)+1)
+	}
+	after, _ := os.Stat(sf.Path())
+	if !after.ModTime().Equal(before.ModTime()) {
+		t.Error("Load rewrote a state from a newer build")
+	}
+}
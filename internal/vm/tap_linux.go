@@ -0,0 +1,78 @@
+package vm
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/sys/unix"
+)
+
+// tunDevice is the clone device used to create and attach tap interfaces.
+const tunDevice = "/dev/net/tun"
+
+// openTap attaches to (creating if needed) the tap interface name.
+func openTap(name string) (*os.File, error) {
+	fd, err := unix.Open(tunDevice, unix.O_RDWR|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", tunDevice, err)
+	}
+
+	ifr, err := unix.NewIfreq(name)
+	if err != nil {
+		unix.Close(fd)
+		return nil, fmt.Errorf("tap %s: %w", name, err)
+	}
+	ifr.SetUint16(unix.IFF_TAP | unix.IFF_NO_PI)
+
+	if err := unix.IoctlIfreq(fd, unix.TUNSETIFF, ifr); err != nil {
+		unix.Close(fd)
+		return nil, fmt.Errorf("TUNSETIFF %s: %w (requires CAP_NET_ADMIN or a tap owned by this user)", name, err)
+	}
+
+	return os.NewFile(uintptr(fd), tunDevice), nil
+}
+
+// setTapPersist marks the tap interface as persistent (or not), so it
+// outlives (or dies with) the file descriptor that created it.
+func setTapPersist(name string, persist bool) error {
+	f, err := openTap(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	v := 0
+	if persist {
+		v = 1
+	}
+	if err := unix.IoctlSetInt(int(f.Fd()), unix.TUNSETPERSIST, v); err != nil {
+		return fmt.Errorf("TUNSETPERSIST %s: %w", name, err)
+	}
+	return nil
+}
+
+// TapDeviceExists reports whether a network interface called name exists.
+func TapDeviceExists(name string) bool {
+	_, err := os.Stat(filepath.Join("/sys/class/net", name))
+	return err == nil
+}
+
+// SetupTapDevice creates a persistent tap interface, equivalent to
+// 'ip tuntap add dev <name> mode tap'. Attaching to an existing tap is
+// not an error. The interface still needs an address and to be brought up.
+func SetupTapDevice(name string) error {
+	return setTapPersist(name, true)
+}
+
+// TeardownTapDevice removes a tap interface created by SetupTapDevice,
+// equivalent to 'ip tuntap del dev <name> mode tap'.
+func TeardownTapDevice(name string) error {
+	return setTapPersist(name, false)
+}
+
+// OpenTapDevice opens the tap interface for use as a virtio-net backend.
+// Each Read or Write on the returned file is one Ethernet frame.
+func OpenTapDevice(name string) (*os.File, error) {
+	return openTap(name)
+}
@@ -0,0 +1,31 @@
+//go:build !linux
+
+package vm
+
+import (
+	"errors"
+	"os"
+)
+
+// errTapUnsupported is returned by tap helpers on platforms without TUN/TAP.
+var errTapUnsupported = errors.New("tap devices are only supported on Linux")
+
+// TapDeviceExists always reports false on this platform.
+func TapDeviceExists(name string) bool {
+	return false
+}
+
+// SetupTapDevice is not supported on this platform.
+func SetupTapDevice(name string) error {
+	return errTapUnsupported
+}
+
+// TeardownTapDevice is not supported on this platform.
+func TeardownTapDevice(name string) error {
+	return errTapUnsupported
+}
+
+// OpenTapDevice is not supported on this platform.
+func OpenTapDevice(name string) (*os.File, error) {
+	return nil, errTapUnsupported
+}
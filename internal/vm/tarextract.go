@@ -0,0 +1,181 @@
+package vm
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+)
+
+// errUnsupportedTar is returned for tarball compressions the Go reader
+// cannot handle, such as xz; callers fall back to the system tar.
+var errUnsupportedTar = errors.New("unsupported tarball compression")
+
+// maxTarLinkDepth bounds how many links ExtractFile follows.
+const maxTarLinkDepth = 8
+
+// Compression magic numbers, checked before trusting the file extension.
+var (
+	gzipMagic  = []byte{0x1f, 0x8b}
+	bzip2Magic = []byte("BZh")
+	zstdMagic  = []byte{0x28, 0xb5, 0x2f, 0xfd}
+	xzMagic    = []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}
+)
+
+// ListTarFiles returns the regular files and links in a tarball, with any
+// leading "./" or "/" removed. Compression is detected from the content.
+func ListTarFiles(archivePath string) ([]string, error) {
+	tr, closer, err := openTarball(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	defer closer.Close()
+
+	var files []string
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return files, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", archivePath, err)
+		}
+		if hdr.Typeflag == tar.TypeDir {
+			continue
+		}
+		if name := cleanTarName(hdr.Name); name != "" {
+			files = append(files, name)
+		}
+	}
+}
+
+// ExtractFile writes filename from the tarball at archivePath to destPath.
+// Symbolic and hard links inside the archive are followed.
+func ExtractFile(archivePath, filename, destPath string) error {
+	want := cleanTarName(filename)
+	for depth := 0; depth <= maxTarLinkDepth; depth++ {
+		target, err := extractTarEntry(archivePath, want, destPath)
+		if err != nil || target == "" {
+			return err
+		}
+		want = target
+	}
+	return fmt.Errorf("%s: too many levels of links", filename)
+}
+
+// extractTarEntry extracts name to destPath. If name is a link it writes
+// nothing and returns the archive path the link points to.
+func extractTarEntry(archivePath, name, destPath string) (string, error) {
+	tr, closer, err := openTarball(archivePath)
+	if err != nil {
+		return "", err
+	}
+	defer closer.Close()
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return "", fmt.Errorf("%s not found in %s", name, archivePath)
+		}
+		if err != nil {
+			return "", fmt.Errorf("read %s: %w", archivePath, err)
+		}
+		if cleanTarName(hdr.Name) != name {
+			continue
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeSymlink:
+			target := hdr.Linkname
+			if !path.IsAbs(target) {
+				target = path.Join(path.Dir(name), target)
+			}
+			return cleanTarName(target), nil
+		case tar.TypeLink:
+			return cleanTarName(hdr.Linkname), nil
+		case tar.TypeReg:
+		default:
+			return "", fmt.Errorf("%s is not a regular file", name)
+		}
+
+		out, err := os.Create(destPath)
+		if err != nil {
+			return "", err
+		}
+		if _, err := io.Copy(out, tr); err != nil {
+			out.Close()
+			os.Remove(destPath)
+			return "", fmt.Errorf("extract %s: %w", name, err)
+		}
+		return "", out.Close()
+	}
+}
+
+// openTarball opens archivePath and returns a tar reader over its
+// decompressed contents. gzip, bzip2, zstd (via the zstd tool) and
+// uncompressed tarballs are supported.
+func openTarball(archivePath string) (*tar.Reader, io.Closer, error) {
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	br := bufio.NewReader(f)
+	magic, _ := br.Peek(6)
+
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			f.Close()
+			return nil, nil, fmt.Errorf("read %s: %w", archivePath, err)
+		}
+		return tar.NewReader(zr), f, nil
+	case bytes.HasPrefix(magic, bzip2Magic):
+		return tar.NewReader(bzip2.NewReader(br)), f, nil
+	case bytes.HasPrefix(magic, zstdMagic):
+		if EnsureZstd() != nil {
+			f.Close()
+			return nil, nil, fmt.Errorf("%w: zstd", errUnsupportedTar)
+		}
+		zr, err := newDecompressor(br, CodecZstd)
+		if err != nil {
+			f.Close()
+			return nil, nil, err
+		}
+		return tar.NewReader(zr), multiCloser{zr, f}, nil
+	case bytes.HasPrefix(magic, xzMagic):
+		f.Close()
+		return nil, nil, fmt.Errorf("%w: xz", errUnsupportedTar)
+	default:
+		return tar.NewReader(br), f, nil
+	}
+}
+
+// cleanTarName normalises an archive path for comparison.
+func cleanTarName(name string) string {
+	name = strings.TrimLeft(path.Clean("/"+name), "/")
+	if name == "." {
+		return ""
+	}
+	return name
+}
+
+// multiCloser closes each closer in order.
+type multiCloser []io.Closer
+
+func (m multiCloser) Close() error {
+	var first error
+	for _, c := range m {
+		if err := c.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
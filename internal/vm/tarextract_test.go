@@ -0,0 +1,165 @@
+package vm
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/javanstorm/vmterminal/internal/distro"
+)
+
+// writeTestTar builds an uncompressed tarball with a kernel, an initrd and
+// a symlink to the kernel.
+func writeTestTar(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	add := func(hdr *tar.Header, body string) {
+		hdr.Size = int64(len(body))
+		if hdr.Mode == 0 {
+			hdr.Mode = 0644
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatalf("WriteHeader: %v", err)
+		}
+		tw.Write([]byte(body))
+	}
+	add(&tar.Header{Name: "./boot/", Typeflag: tar.TypeDir, Mode: 0755}, "")
+	add(&tar.Header{Name: "./boot/vmlinuz-6.6.1", Typeflag: tar.TypeReg}, "kernel image")
+	add(&tar.Header{Name: "./boot/initramfs-6.6.1", Typeflag: tar.TypeReg}, "initrd image")
+	add(&tar.Header{Name: "./boot/vmlinuz", Typeflag: tar.TypeSymlink, Linkname: "vmlinuz-6.6.1"}, "")
+	if err := tw.Close(); err != nil {
+		t.Fatalf("tar Close: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// compressWith pipes data through an external compressor, skipping the
+// test if it is not installed.
+func compressWith(t *testing.T, tool string, data []byte) []byte {
+	t.Helper()
+	if _, err := exec.LookPath(tool); err != nil {
+		t.Skipf("%s not installed", tool)
+	}
+	cmd := exec.Command(tool, "-c")
+	cmd.Stdin = bytes.NewReader(data)
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("%s: %v", tool, err)
+	}
+	return out
+}
+
+func TestExtractFileFormats(t *testing.T) {
+	plain := writeTestTar(t)
+	formats := map[string]func(t *testing.T) []byte{
+		"rootfs.tar": func(t *testing.T) []byte { return plain },
+		"rootfs.tar.gz": func(t *testing.T) []byte {
+			var buf bytes.Buffer
+			zw := gzip.NewWriter(&buf)
+			zw.Write(plain)
+			zw.Close()
+			return buf.Bytes()
+		},
+		"rootfs.tar.bz2": func(t *testing.T) []byte { return compressWith(t, "bzip2", plain) },
+		"rootfs.tar.zst": func(t *testing.T) []byte { return compressWith(t, "zstd", plain) },
+	}
+
+	for name, build := range formats {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			archive := filepath.Join(dir, name)
+			if err := os.WriteFile(archive, build(t), 0644); err != nil {
+				t.Fatalf("WriteFile: %v", err)
+			}
+
+			files, err := ListTarFiles(archive)
+			if err != nil {
+				t.Fatalf("ListTarFiles: %v", err)
+			}
+			if len(files) != 3 || files[0] != "boot/vmlinuz-6.6.1" {
+				t.Errorf("ListTarFiles = %v", files)
+			}
+
+			dest := filepath.Join(dir, "out")
+			if err := ExtractFile(archive, "boot/initramfs-6.6.1", dest); err != nil {
+				t.Fatalf("ExtractFile: %v", err)
+			}
+			if got, _ := os.ReadFile(dest); string(got) != "initrd image" {
+				t.Errorf("extracted %q, want %q", got, "initrd image")
+			}
+		})
+	}
+}
+
+func TestExtractFileSymlinkAndMissing(t *testing.T) {
+	dir := t.TempDir()
+	archive := filepath.Join(dir, "rootfs.tar")
+	os.WriteFile(archive, writeTestTar(t), 0644)
+
+	dest := filepath.Join(dir, "vmlinuz")
+	if err := ExtractFile(archive, "./boot/vmlinuz", dest); err != nil {
+		t.Fatalf("ExtractFile(symlink): %v", err)
+	}
+	if got, _ := os.ReadFile(dest); string(got) != "kernel image" {
+		t.Errorf("symlink extracted %q, want the link target", got)
+	}
+
+	if err := ExtractFile(archive, "boot/missing", filepath.Join(dir, "x")); err == nil {
+		t.Error("expected error for a missing file")
+	}
+}
+
+func TestExtractFileUnsupportedCompression(t *testing.T) {
+	archive := filepath.Join(t.TempDir(), "rootfs.tar.xz")
+	os.WriteFile(archive, append(append([]byte{}, xzMagic...), 0, 0, 0, 0), 0644)
+
+	if _, err := ListTarFiles(archive); !errors.Is(err, errUnsupportedTar) {
+		t.Errorf("ListTarFiles(xz) error = %v, want errUnsupportedTar", err)
+	}
+}
+
+func TestKernelExtractorTarball(t *testing.T) {
+	dir := t.TempDir()
+	archive := filepath.Join(dir, "rootfs.tar")
+	os.WriteFile(archive, writeTestTar(t), 0644)
+
+	e := NewKernelExtractor(dir)
+	kernel, initrd, err := e.ExtractKernel(archive, &distro.KernelLocator{
+		KernelPatterns: []string{"boot/vmlinuz-*"},
+		InitrdPatterns: []string{"boot/initramfs-*"},
+		ArchiveType:    "tarball",
+	})
+	if err != nil {
+		t.Fatalf("ExtractKernel: %v", err)
+	}
+	if got, _ := os.ReadFile(kernel); string(got) != "kernel image" {
+		t.Errorf("kernel = %q", got)
+	}
+	if got, _ := os.ReadFile(initrd); string(got) != "initrd image" {
+		t.Errorf("initrd = %q", got)
+	}
+}
+
+func TestParseTarURL(t *testing.T) {
+	tests := []struct {
+		url, archive, member string
+		ok                   bool
+	}{
+		{"tar:http://example.com/root.tar.gz#boot/Image", "http://example.com/root.tar.gz", "boot/Image", true},
+		{"tar:http://example.com/root.tar.gz", "", "", false},
+		{"tar:#boot/Image", "", "", false},
+		{"iso:http://example.com/a.iso#/boot/vmlinuz", "", "", false},
+	}
+	for _, tt := range tests {
+		archive, member, ok := parseTarURL(tt.url)
+		if ok != tt.ok || (ok && (archive != tt.archive || member != tt.member)) {
+			t.Errorf("parseTarURL(%q) = %q, %q, %v", tt.url, archive, member, ok)
+		}
+	}
+}
@@ -0,0 +1,270 @@
+package vm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/javanstorm/vmterminal/internal/config"
+	"github.com/javanstorm/vmterminal/internal/distro"
+)
+
+// ProvisionScriptFile is the file in a VM's data directory holding the
+// provisioning script of the template it was created from.
+const ProvisionScriptFile = "provision.sh"
+
+// templateNamePattern restricts template names to characters that are
+// safe in file names.
+var templateNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// VMTemplate describes how to create a VM: the settings of its registry
+// entry, an optional disk to start from, and a script the guest runs on
+// first boot. Name is the template's own name; VMs created from it are
+// named separately.
+type VMTemplate struct {
+	VMEntry
+
+	// BaseImage is a snapshot whose disk new VMs start from, written as
+	// "<vm>@<snapshot>". Empty means a fresh disk from the distro.
+	BaseImage string `json:"base_image,omitempty"`
+
+	// ProvisionScript is a shell script run once by cloud-init on the
+	// first boot of each VM created from the template.
+	ProvisionScript string `json:"provision_script,omitempty"`
+}
+
+// ValidateTemplateName checks that name can be used as a template name.
+func ValidateTemplateName(name string) error {
+	if !templateNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid template name %q: use letters, digits, '.', '_' and '-'", name)
+	}
+	return nil
+}
+
+// ParseBaseImage splits a "<vm>@<snapshot>" base image reference.
+func ParseBaseImage(ref string) (vmName, snapshot string, err error) {
+	vmName, snapshot, ok := strings.Cut(ref, "@")
+	if !ok || vmName == "" || snapshot == "" {
+		return "", "", fmt.Errorf("invalid base image %q: expected <vm>@<snapshot>", ref)
+	}
+	return vmName, snapshot, nil
+}
+
+// TemplatesDir returns the directory holding template files.
+func (r *Registry) TemplatesDir() string {
+	return filepath.Join(r.baseDir, "templates")
+}
+
+// templatePath returns the file of the named template.
+func (r *Registry) templatePath(name string) string {
+	return filepath.Join(r.TemplatesDir(), name+".json")
+}
+
+// CreateTemplate saves a new template. It fails if one with the same
+// name exists or if the base image snapshot does not.
+func (r *Registry) CreateTemplate(t VMTemplate) error {
+	if err := ValidateTemplateName(t.Name); err != nil {
+		return err
+	}
+	if t.BaseImage != "" {
+		vmName, snapshot, err := ParseBaseImage(t.BaseImage)
+		if err != nil {
+			return err
+		}
+		if _, err := NewSnapshotManager(r.baseDir, nil).GetSnapshot(vmName, snapshot); err != nil {
+			return fmt.Errorf("base image: %w", err)
+		}
+		if err := checkBaseImageDistro(t.Distro); err != nil {
+			return err
+		}
+	}
+
+	return r.withLock(func() error {
+		path := r.templatePath(t.Name)
+		if _, err := os.Stat(path); err == nil {
+			return fmt.Errorf("template '%s' already exists", t.Name)
+		}
+		if err := os.MkdirAll(r.TemplatesDir(), 0755); err != nil {
+			return fmt.Errorf("create templates directory: %w", err)
+		}
+
+		t.CreatedAt = time.Now()
+		data, err := json.MarshalIndent(t, "", "  ")
+		if err != nil {
+			return fmt.Errorf("marshal template: %w", err)
+		}
+		tmp := path + ".tmp"
+		if err := os.WriteFile(tmp, data, 0644); err != nil {
+			return fmt.Errorf("write template: %w", err)
+		}
+		if err := os.Rename(tmp, path); err != nil {
+			os.Remove(tmp)
+			return fmt.Errorf("write template: %w", err)
+		}
+		return nil
+	})
+}
+
+// GetTemplate returns a template by name.
+func (r *Registry) GetTemplate(name string) (*VMTemplate, error) {
+	if err := ValidateTemplateName(name); err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(r.templatePath(name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("template '%s' not found", name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read template: %w", err)
+	}
+
+	var t VMTemplate
+	if err := json.Unmarshal(data, &t); err != nil {
+		return nil, fmt.Errorf("parse template %s: %w", name, err)
+	}
+	// The file name is authoritative
+	t.Name = name
+	return &t, nil
+}
+
+// ListTemplates returns all templates sorted by name.
+func (r *Registry) ListTemplates() ([]VMTemplate, error) {
+	entries, err := os.ReadDir(r.TemplatesDir())
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read templates: %w", err)
+	}
+
+	var templates []VMTemplate
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), ".json")
+		if !ok || !e.Type().IsRegular() || ValidateTemplateName(name) != nil {
+			continue
+		}
+		t, err := r.GetTemplate(name)
+		if err != nil {
+			return nil, err
+		}
+		templates = append(templates, *t)
+	}
+	sort.Slice(templates, func(i, j int) bool { return templates[i].Name < templates[j].Name })
+	return templates, nil
+}
+
+// DeleteTemplate removes a template. VMs created from it are not affected.
+func (r *Registry) DeleteTemplate(name string) error {
+	if err := ValidateTemplateName(name); err != nil {
+		return err
+	}
+	return r.withLock(func() error {
+		err := os.Remove(r.templatePath(name))
+		if errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("template '%s' not found", name)
+		}
+		if err != nil {
+			return fmt.Errorf("delete template: %w", err)
+		}
+		return nil
+	})
+}
+
+// checkBaseImageDistro rejects a base image for a distro that boots its
+// live ISO, since such a VM never boots its own disk.
+func checkBaseImageDistro(id string) error {
+	if id == "" {
+		return nil
+	}
+	p, err := distro.Get(distro.ID(id))
+	if err != nil {
+		return nil
+	}
+	if reqs := p.SetupRequirements(); reqs != nil && !reqs.NeedsExtraction && p.KernelLocator().ArchiveType == "iso" {
+		return fmt.Errorf("%s boots its live ISO rather than a disk, so it cannot use a base image", p.Name())
+	}
+	return nil
+}
+
+// CreateFromTemplate creates a VM named vmName with the template's
+// settings. If the template has a base image, the snapshot's disk becomes
+// the new VM's root disk; if it has a provisioning script, the script is
+// saved in the VM's data directory for its first boot. The VM gets its own
+// MAC address and hostname, and an SSH port of its own above sshPortBase
+// as with CreateVMWithSSHPort.
+func (r *Registry) CreateFromTemplate(templateName, vmName string, sshPortBase int) (*VMEntry, error) {
+	t, err := r.GetTemplate(templateName)
+	if err != nil {
+		return nil, err
+	}
+
+	var baseVM, baseSnapshot string
+	snapshots := NewSnapshotManager(r.baseDir, nil)
+	if t.BaseImage != "" {
+		if baseVM, baseSnapshot, err = ParseBaseImage(t.BaseImage); err != nil {
+			return nil, err
+		}
+		if _, err := snapshots.GetSnapshot(baseVM, baseSnapshot); err != nil {
+			return nil, fmt.Errorf("template '%s' base image: %w", templateName, err)
+		}
+	}
+
+	entry := t.VMEntry
+	entry.Name = vmName
+	entry.Template = templateName
+	// Like clones, each VM answers to its own name
+	entry.Hostname = ""
+	entry.KernelArgs = append([]string(nil), t.KernelArgs...)
+	if t.Config != nil {
+		cfg := *t.Config
+		cfg.SharedDirs = append([]config.SharedDir(nil), t.Config.SharedDirs...)
+		cfg.MACAddress = ""
+		cfg.SSHHostPort = nil
+		entry.Config = &cfg
+	}
+	if _, err := r.CreateVMWithSSHPort(entry, sshPortBase); err != nil {
+		return nil, err
+	}
+
+	if err := r.populateFromTemplate(t, vmName, snapshots, baseVM, baseSnapshot); err != nil {
+		// Leave no half-populated VM behind
+		r.DeleteVM(vmName)
+		r.DeleteVMData(vmName)
+		return nil, err
+	}
+	return r.GetVM(vmName)
+}
+
+// populateFromTemplate writes the base image disk and provisioning script
+// of t into the data directory of the new VM vmName.
+func (r *Registry) populateFromTemplate(t *VMTemplate, vmName string, snapshots *SnapshotManager, baseVM, baseSnapshot string) error {
+	dataDir := r.VMDataDir(vmName)
+	if baseVM != "" {
+		if err := checkBaseImageDistro(t.Distro); err != nil {
+			return err
+		}
+		// Image-based distros boot a copy of the cached image made on first
+		// boot; an existing root disk takes its place
+		rootDisk := NewImageManager(dataDir).DiskPath(RootDiskName)
+		if err := snapshots.RestoreSnapshotTo(baseVM, baseSnapshot, rootDisk); err != nil {
+			return fmt.Errorf("copy base image: %w", err)
+		}
+		// The disk is a complete system that setup must not format
+		if err := MarkImported(dataDir); err != nil {
+			return err
+		}
+	}
+	if t.ProvisionScript != "" {
+		if err := os.WriteFile(filepath.Join(dataDir, ProvisionScriptFile), []byte(t.ProvisionScript), 0644); err != nil {
+			return fmt.Errorf("write provisioning script: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+package vm
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRegistryTemplates(t *testing.T) {
+	reg := NewRegistry(t.TempDir())
+
+	if templates, err := reg.ListTemplates(); err != nil || len(templates) != 0 {
+		t.Fatalf("ListTemplates on empty dir = %v, %v", templates, err)
+	}
+
+	for _, name := range []string{"web", "db"} {
+		if err := reg.CreateTemplate(VMTemplate{VMEntry: VMEntry{Name: name, Distro: "debian"}}); err != nil {
+			t.Fatalf("CreateTemplate(%s): %v", name, err)
+		}
+	}
+	if err := reg.CreateTemplate(VMTemplate{VMEntry: VMEntry{Name: "web"}}); err == nil {
+		t.Error("expected error creating duplicate template")
+	}
+	for _, name := range []string{"", "../web", "a/b", ".hidden"} {
+		if err := reg.CreateTemplate(VMTemplate{VMEntry: VMEntry{Name: name}}); err == nil {
+			t.Errorf("expected error for template name %q", name)
+		}
+	}
+	if err := reg.CreateTemplate(VMTemplate{VMEntry: VMEntry{Name: "gold"}, BaseImage: "dev@missing"}); err == nil {
+		t.Error("expected error for missing base image snapshot")
+	}
+
+	templates, err := reg.ListTemplates()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(templates) != 2 || templates[0].Name != "db" || templates[1].Name != "web" {
+		t.Fatalf("ListTemplates = %+v, want db and web", templates)
+	}
+	if templates[0].CreatedAt.IsZero() {
+		t.Error("CreatedAt not set")
+	}
+
+	if err := reg.DeleteTemplate("db"); err != nil {
+		t.Fatalf("DeleteTemplate: %v", err)
+	}
+	if _, err := reg.GetTemplate("db"); err == nil {
+		t.Error("deleted template still found")
+	}
+	if err := reg.DeleteTemplate("db"); err == nil {
+		t.Error("expected error deleting missing template")
+	}
+}
+
+func TestRegistryCreateFromTemplate(t *testing.T) {
+	dir := t.TempDir()
+	reg := NewRegistry(dir)
+
+	// A snapshot of another VM serves as the base image
+	if err := reg.CreateVM(VMEntry{Name: "dev"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(reg.VMDataDir("dev"), "disk.raw"), []byte("golden disk"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := NewSnapshotManager(dir, nil).CreateSnapshot("dev", "clean", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	port := 2230
+	tmpl := VMTemplate{
+		VMEntry: VMEntry{
+			Name:       "web",
+			Distro:     "debian",
+			CPUs:       2,
+			MemoryMB:   2048,
+			KernelArgs: []string{"quiet"},
+			Hostname:   "web.lab",
+			Config:     &VMConfig{SSHHostPort: &port, MACAddress: "52:54:00:12:34:56"},
+		},
+		BaseImage:       "dev@clean",
+		ProvisionScript: "apt-get install -y nginx\n",
+	}
+	if err := reg.CreateTemplate(tmpl); err != nil {
+		t.Fatalf("CreateTemplate: %v", err)
+	}
+
+	entry, err := reg.CreateFromTemplate("web", "web1", 2222)
+	if err != nil {
+		t.Fatalf("CreateFromTemplate: %v", err)
+	}
+	if entry.Name != "web1" || entry.Template != "web" || entry.Distro != "debian" || entry.CPUs != 2 || entry.MemoryMB != 2048 {
+		t.Errorf("entry = %+v", entry)
+	}
+	if len(entry.KernelArgs) != 1 || entry.KernelArgs[0] != "quiet" {
+		t.Errorf("kernel args = %v", entry.KernelArgs)
+	}
+	// Each VM gets its own SSH port rather than the template's
+	if p := entry.Config.SSHHostPort; p == nil || *p <= 2222 || *p == 2230 {
+		t.Errorf("SSH port = %v, want a new port above 2222", p)
+	}
+	if entry.Config.MACAddress != "" {
+		t.Errorf("VM kept the template MAC address %q", entry.Config.MACAddress)
+	}
+	if got := entry.EffectiveHostname(); got != "web1" {
+		t.Errorf("hostname = %q, want web1", got)
+	}
+
+	dataDir := reg.VMDataDir("web1")
+	if data, err := os.ReadFile(filepath.Join(dataDir, "disk.raw")); err != nil || string(data) != "golden disk" {
+		t.Errorf("disk = %q, %v", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(dataDir, importedMarker)); err != nil {
+		t.Errorf("base image disk not marked imported: %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(dataDir, ProvisionScriptFile)); err != nil || string(data) != tmpl.ProvisionScript {
+		t.Errorf("provisioning script = %q, %v", data, err)
+	}
+
+	if _, err := reg.CreateFromTemplate("web", "web1", 2222); err == nil {
+		t.Error("expected error creating an existing VM")
+	}
+	if _, err := reg.CreateFromTemplate("missing", "web2", 2222); err == nil {
+		t.Error("expected error for unknown template")
+	}
+}
+
+func TestRegistryCreateFromTemplateMissingBaseImage(t *testing.T) {
+	dir := t.TempDir()
+	reg := NewRegistry(dir)
+
+	if err := os.MkdirAll(reg.TemplatesDir(), 0755); err != nil {
+		t.Fatal(err)
+	}
+	// The snapshot was deleted after the template was created
+	data := []byte(`{"name": "gold", "base_image": "dev@gone"}`)
+	if err := os.WriteFile(filepath.Join(reg.TemplatesDir(), "gold.json"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := reg.CreateFromTemplate("gold", "vm1", 2222); err == nil {
+		t.Fatal("expected error for missing base image")
+	}
+	if _, err := reg.GetVM("vm1"); err == nil {
+		t.Error("failed CreateFromTemplate left a registry entry")
+	}
+}
+
+func TestCreateTemplateRejectsBaseImageForLiveISO(t *testing.T) {
+	dir := t.TempDir()
+	reg := NewRegistry(dir)
+	if err := reg.CreateVM(VMEntry{Name: "dev"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(reg.VMDataDir("dev"), "disk.raw"), []byte("disk"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := NewSnapshotManager(dir, nil).CreateSnapshot("dev", "clean", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := VMTemplate{VMEntry: VMEntry{Name: "nix", Distro: "nixos"}, BaseImage: "dev@clean"}
+	if err := reg.CreateTemplate(tmpl); err == nil {
+		t.Error("expected error for a base image on a live ISO distro")
+	}
+}
+
+func TestParseBaseImage(t *testing.T) {
+	vmName, snapshot, err := ParseBaseImage("dev@clean")
+	if err != nil || vmName != "dev" || snapshot != "clean" {
+		t.Errorf("ParseBaseImage = %q, %q, %v", vmName, snapshot, err)
+	}
+	for _, ref := range []string{"dev", "@clean", "dev@", ""} {
+		if _, _, err := ParseBaseImage(ref); err == nil {
+			t.Errorf("expected error for %q", ref)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package vm
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Tunnel forwards a host port to a port in the guest through SSH.
+type Tunnel struct {
+	GuestPort int
+	HostPort  int
+}
+
+// String returns the tunnel in <guest-port>:<host-port> notation.
+func (t Tunnel) String() string {
+	return fmt.Sprintf("%d:%d", t.GuestPort, t.HostPort)
+}
+
+// ParseTunnels parses comma-separated tunnels in <guest-port>:<host-port>
+// notation, e.g. "8080:8080,5432:15432". A bare port forwards the same
+// port on both sides.
+func ParseTunnels(spec string) ([]Tunnel, error) {
+	var tunnels []Tunnel
+	hostPorts := make(map[int]bool)
+	for _, s := range strings.Split(spec, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		guest, host, found := strings.Cut(s, ":")
+		if !found {
+			host = guest
+		}
+		t := Tunnel{}
+		var err error
+		if t.GuestPort, err = parseTunnelPort(guest); err != nil {
+			return nil, fmt.Errorf("tunnel %q: guest port: %w", s, err)
+		}
+		if t.HostPort, err = parseTunnelPort(host); err != nil {
+			return nil, fmt.Errorf("tunnel %q: host port: %w", s, err)
+		}
+		if hostPorts[t.HostPort] {
+			return nil, fmt.Errorf("tunnel %q: host port %d is used twice", s, t.HostPort)
+		}
+		hostPorts[t.HostPort] = true
+		tunnels = append(tunnels, t)
+	}
+	if len(tunnels) == 0 {
+		return nil, fmt.Errorf("no tunnels in %q", spec)
+	}
+	return tunnels, nil
+}
+
+// parseTunnelPort parses a TCP port number.
+func parseTunnelPort(s string) (int, error) {
+	port, err := strconv.Atoi(s)
+	if err != nil || port < 1 || port > 65535 {
+		return 0, fmt.Errorf("must be 1-65535, got %q", s)
+	}
+	return port, nil
+}
+
+// BuildSSHTunnelArgs returns the ssh arguments that open tunnels to the
+// VM reached through cfg and hold them open without running a command.
+// Host ports are bound to the loopback interface only. cfg.Socket and the
+// I/O fields are ignored.
+func BuildSSHTunnelArgs(tunnels []Tunnel, cfg SSHConfig) []string {
+	if cfg.Host == "" {
+		cfg.Host = "localhost"
+	}
+	if cfg.User == "" {
+		cfg.User = "root"
+	}
+
+	args := []string{
+		"-i", cfg.KeyPath,
+		"-p", strconv.Itoa(cfg.Port),
+		"-o", "StrictHostKeyChecking=no",
+		"-o", "UserKnownHostsFile=/dev/null",
+		"-o", "LogLevel=ERROR",
+		// Fail rather than run without a tunnel whose port is taken
+		"-o", "ExitOnForwardFailure=yes",
+		"-N",
+	}
+	for _, t := range tunnels {
+		args = append(args, "-L", fmt.Sprintf("127.0.0.1:%d:localhost:%d", t.HostPort, t.GuestPort))
+	}
+	return append(args, cfg.User+"@"+cfg.Host)
+}
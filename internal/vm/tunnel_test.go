@@ -0,0 +1,42 @@
+package vm
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTunnels(t *testing.T) {
+	got, err := ParseTunnels("8080:8080, 5432:15432,3000")
+	if err != nil {
+		t.Fatalf("ParseTunnels: %v", err)
+	}
+	want := []Tunnel{{8080, 8080}, {5432, 15432}, {3000, 3000}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseTunnels = %v, want %v", got, want)
+	}
+
+	for _, spec := range []string{"", ",", "8080:", "0:8080", "8080:70000", "http:80", "80:8080,81:8080"} {
+		if _, err := ParseTunnels(spec); err == nil {
+			t.Errorf("ParseTunnels(%q) succeeded, want error", spec)
+		}
+	}
+}
+
+func TestBuildSSHTunnelArgs(t *testing.T) {
+	args := BuildSSHTunnelArgs([]Tunnel{{8080, 8080}, {5432, 15432}}, SSHConfig{Port: 2222, KeyPath: "/keys/id_ed25519"})
+	want := []string{
+		"-i", "/keys/id_ed25519",
+		"-p", "2222",
+		"-o", "StrictHostKeyChecking=no",
+		"-o", "UserKnownHostsFile=/dev/null",
+		"-o", "LogLevel=ERROR",
+		"-o", "ExitOnForwardFailure=yes",
+		"-N",
+		"-L", "127.0.0.1:8080:localhost:8080",
+		"-L", "127.0.0.1:15432:localhost:5432",
+		"root@localhost",
+	}
+	if !reflect.DeepEqual(args, want) {
+		t.Errorf("BuildSSHTunnelArgs =\n%q\nwant\n%q", args, want)
+	}
+}
@@ -0,0 +1,220 @@
+package vm
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/javanstorm/vmterminal/internal/distro"
+)
+
+// maxVagrantfileSize caps how much of a box's Vagrantfile is read.
+const maxVagrantfileSize = 1 << 20
+
+// BoxMetadata holds the hints VMTerminal takes from a box's Vagrantfile.
+// Zero values mean the Vagrantfile did not set them.
+type BoxMetadata struct {
+	Box      string // config.vm.box
+	CPUs     int
+	MemoryMB int
+}
+
+var (
+	vagrantBoxRe    = regexp.MustCompile(`config\.vm\.box\s*=\s*["']([^"']+)["']`)
+	vagrantCPUsRe   = regexp.MustCompile(`\.cpus\s*=\s*["']?(\d+)`)
+	vagrantMemoryRe = regexp.MustCompile(`\.memory\s*=\s*["']?(\d+)`)
+
+	// VirtualBox customisations: vb.customize ["modifyvm", :id, "--memory", "2048"]
+	vagrantModifyCPUsRe   = regexp.MustCompile(`["']--cpus["']\s*,\s*["']?(\d+)`)
+	vagrantModifyMemoryRe = regexp.MustCompile(`["']--memory["']\s*,\s*["']?(\d+)`)
+)
+
+// ParseVagrantfile extracts the box name and CPU and memory hints from a
+// Vagrantfile. It matches the common provider settings (vb.cpus,
+// libvirt.memory, modifyvm customisations) rather than evaluating Ruby.
+func ParseVagrantfile(data []byte) BoxMetadata {
+	var meta BoxMetadata
+	for _, line := range strings.Split(string(data), "\n") {
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		if m := vagrantBoxRe.FindStringSubmatch(line); m != nil && meta.Box == "" {
+			meta.Box = m[1]
+		}
+		if n := firstInt(line, vagrantCPUsRe, vagrantModifyCPUsRe); n > 0 {
+			meta.CPUs = n
+		}
+		if n := firstInt(line, vagrantMemoryRe, vagrantModifyMemoryRe); n > 0 {
+			meta.MemoryMB = n
+		}
+	}
+	return meta
+}
+
+// firstInt returns the number captured by the first pattern matching line.
+func firstInt(line string, patterns ...*regexp.Regexp) int {
+	for _, re := range patterns {
+		if m := re.FindStringSubmatch(line); m != nil {
+			n, _ := strconv.Atoi(m[1])
+			return n
+		}
+	}
+	return 0
+}
+
+// BoxDistro guesses the distro from a box name such as "generic/rocky9"
+// or "bento/ubuntu-22.04". It returns "" when no known distro matches, so
+// the global default applies.
+func BoxDistro(box string) string {
+	box = strings.ToLower(box)
+	for _, id := range distro.AllDistros() {
+		if strings.Contains(box, string(id)) {
+			return string(id)
+		}
+	}
+	return ""
+}
+
+// BoxImporter creates VMs from Vagrant .box files: tar archives, usually
+// gzip-compressed, holding a disk image, a Vagrantfile and metadata.json.
+type BoxImporter struct{}
+
+// NewBoxImporter creates a box importer.
+func NewBoxImporter() *BoxImporter {
+	return &BoxImporter{}
+}
+
+// boxContents is what Import extracts from a box.
+type boxContents struct {
+	disk        string // Path of the extracted disk image
+	format      string // qemu-img format of disk: vmdk or qcow2
+	vagrantfile []byte
+}
+
+// Import unpacks the box at path, converts its disk to dataDir/disk.raw and
+// returns a registry entry for a VM called name, with the distro, CPUs and
+// memory taken from the box's Vagrantfile. The caller adds the entry to
+// the registry. Converting needs qemu-img.
+func (b *BoxImporter) Import(path, name, dataDir string) (*VMEntry, error) {
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		return nil, fmt.Errorf("create data dir: %w", err)
+	}
+	tmpDir, err := os.MkdirTemp(dataDir, ".box-")
+	if err != nil {
+		return nil, fmt.Errorf("create temp dir: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	box, err := extractBox(path, tmpDir)
+	if err != nil {
+		return nil, err
+	}
+
+	diskPath := filepath.Join(dataDir, "disk.raw")
+	if box.format == "qcow2" {
+		err = ConvertQcow2ToRaw(box.disk, diskPath)
+	} else if err = EnsureQemuImg(); err != nil {
+		err = fmt.Errorf("converting %s needs qemu-img: %w", filepath.Base(box.disk), err)
+	} else {
+		err = qemuImgToRaw(box.disk, diskPath, box.format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(diskPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := MarkImported(dataDir); err != nil {
+		return nil, err
+	}
+
+	meta := ParseVagrantfile(box.vagrantfile)
+	boxName := meta.Box
+	if boxName == "" {
+		boxName = strings.TrimSuffix(filepath.Base(path), ".box")
+	}
+	return &VMEntry{
+		Name:       name,
+		Distro:     BoxDistro(boxName),
+		CPUs:       meta.CPUs,
+		MemoryMB:   meta.MemoryMB,
+		DiskSizeMB: int(info.Size() / (1024 * 1024)),
+	}, nil
+}
+
+// extractBox writes the first disk image in the box at boxPath into dir
+// and reads its Vagrantfile. VirtualBox boxes carry .vmdk disks; libvirt
+// boxes carry a qcow2 disk named box.img.
+func extractBox(boxPath, dir string) (*boxContents, error) {
+	tr, closer, err := openTarball(boxPath)
+	if err != nil {
+		return nil, err
+	}
+	defer closer.Close()
+
+	box := &boxContents{}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", boxPath, err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		name := path.Base(cleanTarName(hdr.Name))
+		ext := strings.ToLower(path.Ext(name))
+		switch {
+		case name == "Vagrantfile":
+			box.vagrantfile, err = io.ReadAll(io.LimitReader(tr, maxVagrantfileSize))
+			if err != nil {
+				return nil, fmt.Errorf("read Vagrantfile: %w", err)
+			}
+		case box.disk == "" && (ext == ".vmdk" || ext == ".qcow2" || ext == ".img"):
+			dst := filepath.Join(dir, name)
+			if err := writeTarEntry(tr, dst); err != nil {
+				return nil, fmt.Errorf("extract %s: %w", name, err)
+			}
+			format := strings.TrimPrefix(ext, ".")
+			if ext == ".img" {
+				// Only a qcow2 .img is a disk; anything else is skipped
+				if _, err := ReadQcow2Header(dst); err != nil {
+					os.Remove(dst)
+					continue
+				}
+				format = "qcow2"
+			}
+			box.disk, box.format = dst, format
+		}
+	}
+
+	if box.disk == "" {
+		return nil, fmt.Errorf("%s: no .vmdk or .qcow2 disk image found in box", filepath.Base(boxPath))
+	}
+	return box, nil
+}
+
+// writeTarEntry copies the current entry of tr to dst.
+func writeTarEntry(tr *tar.Reader, dst string) error {
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, tr); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,146 @@
+package vm
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeBox writes a gzip-compressed box holding files, in order.
+func writeBox(t *testing.T, files [][2]string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "test.box")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zw := gzip.NewWriter(f)
+	tw := tar.NewWriter(zw)
+	for _, file := range files {
+		hdr := &tar.Header{Name: file[0], Mode: 0644, Size: int64(len(file[1]))}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(file[1])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestParseVagrantfile(t *testing.T) {
+	vagrantfile := `
+Vagrant.configure("2") do |config|
+  config.vm.box = "generic/rocky9"
+  # config.vm.box = "ignored/comment"
+  config.vm.provider "virtualbox" do |vb|
+    vb.memory = "2048"
+    vb.cpus = 2
+  end
+  config.vm.provider "libvirt" do |lv|
+    lv.customize ["modifyvm", :id, "--cpus", "4"]
+  end
+end
+`
+	got := ParseVagrantfile([]byte(vagrantfile))
+	want := BoxMetadata{Box: "generic/rocky9", CPUs: 4, MemoryMB: 2048}
+	if got != want {
+		t.Errorf("ParseVagrantfile = %+v, want %+v", got, want)
+	}
+
+	if got := ParseVagrantfile(nil); got != (BoxMetadata{}) {
+		t.Errorf("empty Vagrantfile = %+v, want zero", got)
+	}
+}
+
+func TestBoxDistro(t *testing.T) {
+	tests := map[string]string{
+		"generic/rocky9":      "rocky",
+		"bento/ubuntu-22.04":  "ubuntu",
+		"debian/bookworm64":   "debian",
+		"archlinux/archlinux": "arch",
+		"generic/Alpine318":   "alpine",
+		"hashicorp/precise64": "",
+	}
+	for box, want := range tests {
+		if got := BoxDistro(box); got != want {
+			t.Errorf("BoxDistro(%q) = %q, want %q", box, got, want)
+		}
+	}
+}
+
+func TestExtractBox(t *testing.T) {
+	qcow2, err := os.ReadFile(writeQcow2Header(t, qcow2Magic, 3, 1<<30, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	box := writeBox(t, [][2]string{
+		{"./metadata.json", `{"provider": "libvirt"}`},
+		{"./info.img", "not a disk"},
+		{"./box.img", string(qcow2)},
+		{"./Vagrantfile", `config.vm.box = "generic/debian12"`},
+	})
+
+	got, err := extractBox(box, t.TempDir())
+	if err != nil {
+		t.Fatalf("extractBox: %v", err)
+	}
+	if filepath.Base(got.disk) != "box.img" || got.format != "qcow2" {
+		t.Errorf("disk = %s (%s), want box.img (qcow2)", got.disk, got.format)
+	}
+	if !strings.Contains(string(got.vagrantfile), "generic/debian12") {
+		t.Errorf("vagrantfile = %q", got.vagrantfile)
+	}
+
+	vmdk := writeBox(t, [][2]string{
+		{"box.ovf", "<xml/>"},
+		{"box-disk001.vmdk", "vmdk data"},
+		{"box-disk002.vmdk", "second disk"},
+	})
+	got, err = extractBox(vmdk, t.TempDir())
+	if err != nil {
+		t.Fatalf("extractBox: %v", err)
+	}
+	if filepath.Base(got.disk) != "box-disk001.vmdk" || got.format != "vmdk" {
+		t.Errorf("disk = %s (%s), want box-disk001.vmdk (vmdk)", got.disk, got.format)
+	}
+}
+
+func TestExtractBoxNoDisk(t *testing.T) {
+	box := writeBox(t, [][2]string{{"Vagrantfile", ""}, {"metadata.json", "{}"}})
+	_, err := extractBox(box, t.TempDir())
+	if err == nil || !strings.Contains(err.Error(), "no .vmdk or .qcow2") {
+		t.Errorf("extractBox error = %v, want missing disk error", err)
+	}
+}
+
+func TestBoxImporterNeedsQemuImg(t *testing.T) {
+	if _, err := exec.LookPath("qemu-img"); err == nil {
+		t.Skip("qemu-img is installed")
+	}
+
+	box := writeBox(t, [][2]string{{"box-disk001.vmdk", "vmdk data"}})
+	dataDir := filepath.Join(t.TempDir(), "vm")
+	_, err := NewBoxImporter().Import(box, "vm", dataDir)
+	if err == nil || !strings.Contains(err.Error(), "qemu-img") {
+		t.Fatalf("Import error = %v, want qemu-img error", err)
+	}
+
+	// The unpacked box is cleaned up and no disk is left behind
+	entries, _ := os.ReadDir(dataDir)
+	if len(entries) != 0 {
+		t.Errorf("data dir not empty after failed import: %v", entries)
+	}
+}
@@ -0,0 +1,47 @@
+package vm
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+)
+
+// signatureCheck is a detached OpenPGP signature an asset must match.
+type signatureCheck struct {
+	URL string // Location of the detached signature
+	Key string // ASCII-armored public key
+}
+
+// VerifyDetachedSignature checks that sigPath holds a valid detached OpenPGP
+// signature of the file at path, made by a key in armoredKey. The signature
+// may be ASCII-armored (.asc) or binary (.sig).
+func VerifyDetachedSignature(path, sigPath, armoredKey string) error {
+	keyring, err := openpgp.ReadArmoredKeyRing(strings.NewReader(armoredKey))
+	if err != nil {
+		return fmt.Errorf("read public key: %w", err)
+	}
+
+	sig, err := os.ReadFile(sigPath)
+	if err != nil {
+		return fmt.Errorf("read signature: %w", err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if bytes.HasPrefix(bytes.TrimSpace(sig), []byte("-----BEGIN PGP SIGNATURE-----")) {
+		_, err = openpgp.CheckArmoredDetachedSignature(keyring, f, bytes.NewReader(sig), nil)
+	} else {
+		_, err = openpgp.CheckDetachedSignature(keyring, f, bytes.NewReader(sig), nil)
+	}
+	if err != nil {
+		return fmt.Errorf("bad signature: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package vm
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/javanstorm/vmterminal/internal/distro"
+)
+
+// newSigningKey returns a fresh signing entity and its armored public key.
+func newSigningKey(t *testing.T) (*openpgp.Entity, string) {
+	t.Helper()
+
+	entity, err := openpgp.NewEntity("VMTerminal Test", "", "test@example.com", nil)
+	if err != nil {
+		t.Fatalf("NewEntity: %v", err)
+	}
+
+	var buf bytes.Buffer
+	w, err := armor.Encode(&buf, openpgp.PublicKeyType, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := entity.Serialize(w); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	return entity, buf.String()
+}
+
+func TestVerifyDetachedSignature(t *testing.T) {
+	entity, pubKey := newSigningKey(t)
+	_, otherKey := newSigningKey(t)
+
+	dir := t.TempDir()
+	data := []byte("rootfs contents")
+	path := filepath.Join(dir, "rootfs.tar.gz")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var armored, binary bytes.Buffer
+	if err := openpgp.ArmoredDetachSign(&armored, entity, bytes.NewReader(data), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := openpgp.DetachSign(&binary, entity, bytes.NewReader(data), nil); err != nil {
+		t.Fatal(err)
+	}
+	ascPath := filepath.Join(dir, "rootfs.tar.gz.asc")
+	sigPath := filepath.Join(dir, "rootfs.tar.gz.sig")
+	os.WriteFile(ascPath, armored.Bytes(), 0644)
+	os.WriteFile(sigPath, binary.Bytes(), 0644)
+
+	if err := VerifyDetachedSignature(path, ascPath, pubKey); err != nil {
+		t.Errorf("armored signature: %v", err)
+	}
+	if err := VerifyDetachedSignature(path, sigPath, pubKey); err != nil {
+		t.Errorf("binary signature: %v", err)
+	}
+	if err := VerifyDetachedSignature(path, ascPath, otherKey); err == nil {
+		t.Error("expected failure with the wrong key")
+	}
+
+	tampered := filepath.Join(dir, "tampered")
+	os.WriteFile(tampered, []byte("rootfs c0ntents"), 0644)
+	if err := VerifyDetachedSignature(tampered, ascPath, pubKey); err == nil {
+		t.Error("expected failure for tampered file")
+	}
+}
+
+func TestEnsureFileVerifiesSignature(t *testing.T) {
+	entity, pubKey := newSigningKey(t)
+
+	data := []byte("rootfs contents")
+	var sig bytes.Buffer
+	if err := openpgp.ArmoredDetachSign(&sig, entity, bytes.NewReader(data), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/rootfs.tar.gz":
+			w.Write(data)
+		case "/evil.tar.gz":
+			w.Write([]byte("evil contents"))
+		case "/rootfs.tar.gz.asc":
+			w.Write(sig.Bytes())
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	m := NewAssetManager(t.TempDir(), nil, nil)
+	check := &signatureCheck{URL: srv.URL + "/rootfs.tar.gz.asc", Key: pubKey}
+
+	good := filepath.Join(t.TempDir(), "rootfs.tar.gz")
+	if err := m.ensureFile(good, srv.URL+"/rootfs.tar.gz", check); err != nil {
+		t.Fatalf("ensureFile with valid signature: %v", err)
+	}
+	// Only the download and its checksum record remain
+	if entries, _ := os.ReadDir(filepath.Dir(good)); len(entries) != 2 {
+		t.Errorf("signature or temporary files left behind: %v", entries)
+	}
+
+	bad := filepath.Join(t.TempDir(), "rootfs.tar.gz")
+	err := m.ensureFile(bad, srv.URL+"/evil.tar.gz", check)
+	if err == nil || !strings.Contains(err.Error(), "--skip-verify") {
+		t.Fatalf("error = %v, want verification failure", err)
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(bad)); len(entries) != 0 {
+		t.Errorf("file failing verification should be removed, found %v", entries)
+	}
+
+	// Skipping verification accepts the same download
+	m.SetSkipVerify(true)
+	if m.rootfsSignature(&distro.AssetURLs{SignatureURL: check.URL, GPGKey: pubKey}) != nil {
+		t.Error("rootfsSignature should be nil with verification skipped")
+	}
+
+	m.SetSkipVerify(false)
+
+	// Providers that publish signatures but embed no key use the one
+	// installed in the keys directory
+	m = NewAssetManager(filepath.Join(t.TempDir(), "cache"), distro.NewAlpineProvider(), nil)
+	urls := &distro.AssetURLs{SignatureURL: check.URL}
+	if m.rootfsSignature(urls) != nil {
+		t.Error("rootfsSignature should be nil without a key")
+	}
+	keyPath := filepath.Join(KeysDir(m.cacheDir), "alpine.asc")
+	os.MkdirAll(filepath.Dir(keyPath), 0755)
+	if err := os.WriteFile(keyPath, []byte(pubKey), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := m.rootfsSignature(urls); got == nil || got.Key != pubKey {
+		t.Errorf("rootfsSignature = %+v, want the installed key", got)
+	}
+}
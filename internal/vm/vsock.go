@@ -0,0 +1,77 @@
+package vm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+
+	"github.com/javanstorm/vmterminal/pkg/hypervisor"
+)
+
+// VsockSSHPort is the guest vsock port expected to serve SSH, as set up by
+// InstallVsockSSH, systemd's own sshd-vsock socket or
+// 'socat VSOCK-LISTEN:22,fork TCP:localhost:22'.
+const VsockSSHPort = 22
+
+// VsockSocketPath returns the Unix socket through which other vmterminal
+// processes reach the guest's SSH vsock port while the VM runs.
+func VsockSocketPath(dataDir string) string {
+	return filepath.Join(dataDir, "vsock-ssh.sock")
+}
+
+// ServeVsockProxy listens on the Unix socket at socketPath and forwards
+// each connection to port on the guest through dialer, until ctx is done.
+// Only the VM process holds the vsock device, so this is how other
+// processes use it. The socket is removed on return.
+func ServeVsockProxy(ctx context.Context, dialer hypervisor.VsockDialer, port uint32, socketPath string) error {
+	// A socket left by a VM process that crashed would block Listen
+	if err := os.Remove(socketPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("remove stale vsock socket: %w", err)
+	}
+	ln, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return fmt.Errorf("listen on vsock socket: %w", err)
+	}
+	defer os.Remove(socketPath)
+
+	stop := context.AfterFunc(ctx, func() { ln.Close() })
+	defer stop()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return fmt.Errorf("accept vsock client: %w", err)
+		}
+		go proxyVsock(ctx, dialer, port, conn)
+	}
+}
+
+// proxyVsock copies between client and a new vsock connection to port
+// until either side closes.
+func proxyVsock(ctx context.Context, dialer hypervisor.VsockDialer, port uint32, client net.Conn) {
+	defer client.Close()
+
+	guest, err := dialer.Connect(ctx, port)
+	if err != nil {
+		return
+	}
+	defer guest.Close()
+
+	done := make(chan struct{}, 2)
+	go func() {
+		_, _ = io.Copy(guest, client)
+		done <- struct{}{}
+	}()
+	go func() {
+		_, _ = io.Copy(client, guest)
+		done <- struct{}{}
+	}()
+	<-done
+}
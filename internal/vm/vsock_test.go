@@ -0,0 +1,139 @@
+package vm
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// tcpVsockDialer stands in for a hypervisor vsock device by mapping guest
+// ports to local TCP ports.
+type tcpVsockDialer map[uint32]int
+
+func (d tcpVsockDialer) Connect(ctx context.Context, port uint32) (net.Conn, error) {
+	tcpPort, ok := d[port]
+	if !ok {
+		return nil, fmt.Errorf("nothing listening on vsock port %d", port)
+	}
+	var dialer net.Dialer
+	return dialer.DialContext(ctx, "tcp", fmt.Sprintf("127.0.0.1:%d", tcpPort))
+}
+
+// startEchoSSH starts an SSH server that prints each command it runs and
+// returns its port and a private key it accepts.
+func startEchoSSH(t *testing.T) (port int, keyPath string) {
+	t.Helper()
+	keyPath, _, err := NewSSHKeyManager(t.TempDir()).EnsureKeyPair()
+	if err != nil {
+		t.Fatalf("EnsureKeyPair: %v", err)
+	}
+	keyData, err := os.ReadFile(keyPath)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	signer, err := ssh.ParsePrivateKey(keyData)
+	if err != nil {
+		t.Fatalf("ParsePrivateKey: %v", err)
+	}
+	port = startExecServer(t, signer.PublicKey(), func(cmd string) (string, string, uint32) {
+		return "ran: " + cmd + "\n", "", 0
+	})
+	return port, keyPath
+}
+
+// waitForSocket waits until path is a Unix socket.
+func waitForSocket(t *testing.T, path string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if fi, err := os.Stat(path); err == nil && fi.Mode()&os.ModeSocket != 0 {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("socket %s never appeared", path)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestServeVsockProxy(t *testing.T) {
+	port, keyPath := startEchoSSH(t)
+	socketPath := VsockSocketPath(t.TempDir())
+
+	// A socket left behind by a crashed VM process must not block startup
+	if err := os.WriteFile(socketPath, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- ServeVsockProxy(ctx, tcpVsockDialer{VsockSSHPort: port}, VsockSSHPort, socketPath)
+	}()
+	waitForSocket(t, socketPath)
+
+	var stdout bytes.Buffer
+	code, err := ExecOverSSH(context.Background(), SSHConfig{
+		Socket:  socketPath,
+		KeyPath: keyPath,
+		Stdout:  &stdout,
+	}, "uname")
+	if err != nil {
+		t.Fatalf("ExecOverSSH through proxy: %v", err)
+	}
+	if code != 0 || stdout.String() != "ran: uname\n" {
+		t.Errorf("ExecOverSSH = %d, %q", code, stdout.String())
+	}
+
+	cancel()
+	select {
+	case err := <-errCh:
+		if err != nil {
+			t.Errorf("ServeVsockProxy: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("ServeVsockProxy did not stop after cancel")
+	}
+	if _, err := os.Stat(socketPath); !os.IsNotExist(err) {
+		t.Errorf("socket not removed after stop: %v", err)
+	}
+}
+
+func TestExecOverSSHSocketFallback(t *testing.T) {
+	port, keyPath := startEchoSSH(t)
+
+	// No proxy is running, so the TCP port must be used instead
+	var stdout bytes.Buffer
+	code, err := ExecOverSSH(context.Background(), SSHConfig{
+		Host:    "127.0.0.1",
+		Port:    port,
+		Socket:  filepath.Join(t.TempDir(), "vsock-ssh.sock"),
+		KeyPath: keyPath,
+		Stdout:  &stdout,
+	}, "id")
+	if err != nil {
+		t.Fatalf("ExecOverSSH: %v", err)
+	}
+	if code != 0 || stdout.String() != "ran: id\n" {
+		t.Errorf("ExecOverSSH = %d, %q", code, stdout.String())
+	}
+}
+
+func TestExecOverSSHSocketOnly(t *testing.T) {
+	_, keyPath := startEchoSSH(t)
+
+	_, err := ExecOverSSH(context.Background(), SSHConfig{
+		Socket:  filepath.Join(t.TempDir(), "vsock-ssh.sock"),
+		KeyPath: keyPath,
+	}, "true")
+	if err == nil {
+		t.Error("ExecOverSSH should fail without a proxy or TCP port")
+	}
+}
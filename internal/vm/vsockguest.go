@@ -0,0 +1,92 @@
+package vm
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// vsockSSHSocketUnit is the systemd socket that serves SSH on VsockSSHPort
+// in the guest. systemd 256 and later set up the same socket through
+// systemd-ssh-generator, so it is skipped where the generator exists.
+const vsockSSHSocketUnit = "vmterminal-ssh-vsock.socket"
+
+// vsockSSHUnits returns the unit files that serve sshd on VsockSSHPort:
+// the socket starts "sshd -i" for each vsock connection.
+func vsockSSHUnits() []struct{ name, content string } {
+	return []struct{ name, content string }{
+		{vsockSSHSocketUnit, fmt.Sprintf(`[Unit]
+Description=vmterminal SSH over vsock
+ConditionPathExists=!/usr/lib/systemd/system-generators/systemd-ssh-generator
+
+[Socket]
+ListenStream=vsock::%d
+Accept=yes
+
+[Install]
+WantedBy=sockets.target
+`, VsockSSHPort)},
+		{"vmterminal-ssh-vsock@.service", `[Unit]
+Description=vmterminal SSH over vsock per-connection server
+
+[Service]
+ExecStart=-/usr/sbin/sshd -i
+StandardInput=socket
+`},
+	}
+}
+
+// InstallVsockSSH installs and enables the vsock SSH socket in the rootfs
+// mounted at mountPoint. Guests without systemd are left alone; SSH then
+// only works over the forwarded port.
+// This requires root privileges since the mount point is owned by root.
+func InstallVsockSSH(mountPoint string) error {
+	if !hasSystemd(mountPoint) {
+		return nil
+	}
+
+	unitDir := filepath.Join(mountPoint, "etc", "systemd", "system")
+	wantsDir := filepath.Join(unitDir, "sockets.target.wants")
+	if err := exec.Command("sudo", "mkdir", "-p", wantsDir).Run(); err != nil {
+		return fmt.Errorf("create %s: %w", wantsDir, err)
+	}
+	for _, u := range vsockSSHUnits() {
+		// Use tee with sudo since the mount point is owned by root
+		cmd := exec.Command("sudo", "tee", filepath.Join(unitDir, u.name))
+		cmd.Stdin = strings.NewReader(u.content)
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("write %s: %w", u.name, err)
+		}
+	}
+	link := filepath.Join(wantsDir, vsockSSHSocketUnit)
+	if err := exec.Command("sudo", "ln", "-sf", "../"+vsockSSHSocketUnit, link).Run(); err != nil {
+		return fmt.Errorf("enable %s: %w", vsockSSHSocketUnit, err)
+	}
+	return nil
+}
+
+// hasSystemd reports whether the rootfs at mountPoint boots systemd.
+func hasSystemd(mountPoint string) bool {
+	for _, p := range []string{"usr/lib/systemd/systemd", "lib/systemd/systemd"} {
+		if _, err := os.Lstat(filepath.Join(mountPoint, p)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// guestfishVsockSSHScript returns the guestfish commands that install and
+// enable the vsock SSH socket in a disk image. Only cloud images are
+// edited with guestfish, and they all boot systemd.
+func guestfishVsockSSHScript() string {
+	var b strings.Builder
+	b.WriteString("mkdir-p /etc/systemd/system/sockets.target.wants\n")
+	for _, u := range vsockSSHUnits() {
+		fmt.Fprintf(&b, "write /etc/systemd/system/%s %s\n", u.name, strconv.Quote(u.content))
+	}
+	fmt.Fprintf(&b, "ln-sf ../%s /etc/systemd/system/sockets.target.wants/%s\n", vsockSSHSocketUnit, vsockSSHSocketUnit)
+	return b.String()
+}
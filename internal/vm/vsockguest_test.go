@@ -0,0 +1,43 @@
+package vm
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGuestfishVsockSSHScript(t *testing.T) {
+	script := guestfishVsockSSHScript()
+
+	for _, want := range []string{
+		"mkdir-p /etc/systemd/system/sockets.target.wants\n",
+		`write /etc/systemd/system/vmterminal-ssh-vsock.socket "[Unit]\n`,
+		`ListenStream=vsock::22\nAccept=yes\n`,
+		`write /etc/systemd/system/vmterminal-ssh-vsock@.service "[Unit]\n`,
+		`ExecStart=-/usr/sbin/sshd -i\n`,
+		"ln-sf ../vmterminal-ssh-vsock.socket /etc/systemd/system/sockets.target.wants/vmterminal-ssh-vsock.socket\n",
+	} {
+		if !strings.Contains(script, want) {
+			t.Errorf("script missing %q:\n%s", want, script)
+		}
+	}
+}
+
+func TestHasSystemd(t *testing.T) {
+	root := t.TempDir()
+	if hasSystemd(root) {
+		t.Error("hasSystemd() on an empty rootfs = true")
+	}
+
+	bin := filepath.Join(root, "usr", "lib", "systemd", "systemd")
+	if err := os.MkdirAll(filepath.Dir(bin), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(bin, nil, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if !hasSystemd(root) {
+		t.Error("hasSystemd() with /usr/lib/systemd/systemd = false")
+	}
+}
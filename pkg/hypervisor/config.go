@@ -1,5 +1,15 @@
 package hypervisor
 
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
 // VMConfig holds VM configuration parameters.
 type VMConfig struct {
 	// CPUs is the number of virtual CPUs.
@@ -8,7 +18,8 @@ type VMConfig struct {
 	// MemoryMB is the amount of memory in megabytes.
 	MemoryMB int
 
-	// Kernel is the path to the Linux kernel image.
+	// Kernel is the path to the Linux kernel image. Not needed when
+	// booting through UEFI firmware.
 	Kernel string
 
 	// Initrd is the path to the initial ramdisk (optional).
@@ -20,6 +31,17 @@ type VMConfig struct {
 	// DiskPath is the path to the root disk image.
 	DiskPath string
 
+	// DiskReadOnly attaches the root disk read-only.
+	DiskReadOnly bool
+
+	// UEFI boots the VM through the hypervisor's built-in UEFI firmware,
+	// which loads the bootloader from the root disk, instead of booting
+	// Kernel directly.
+	UEFI bool
+
+	// ExtraDisks are attached after the root disk, in order.
+	ExtraDisks []StorageDevice
+
 	// SharedDirs maps mount tags to host directory paths.
 	// Key: mount tag (used by guest to mount via "mount -t virtiofs <tag> <mountpoint>")
 	// Value: host directory path to share
@@ -30,6 +52,7 @@ type VMConfig struct {
 	SharedDirsReadOnly map[string]bool
 
 	// EnableNetwork enables VM networking.
+	// Ignored when Networks is non-empty.
 	EnableNetwork bool
 
 	// NetworkMode specifies the network mode ("nat" or "bridged").
@@ -38,12 +61,204 @@ type VMConfig struct {
 
 	// MACAddress is an optional custom MAC address.
 	// If empty, a random locally-administered MAC will be generated.
+	// Ignored when Networks is non-empty.
 	MACAddress string
 
-	// PortForwards maps host ports to guest ports for NAT networking.
-	// Key: host port, Value: guest port
-	// Example: {2222: 22} forwards host:2222 to guest:22
-	PortForwards map[int]int
+	// Networks lists the VM's network interfaces, one NIC per entry.
+	// When empty, EnableNetwork and MACAddress describe a single NAT NIC.
+	Networks []NetworkInterface
+
+	// PortForwards lists host-to-guest port forwarding rules.
+	// Example: {Host: 2222, Guest: 22, Proto: "tcp"} forwards host:2222 to guest:22
+	PortForwards []PortForward
+
+	// TapFile is an open tap device used as the virtio-net backend.
+	// Only used by the Linux KVM driver; the caller owns and closes it.
+	TapFile *os.File
+
+	// EnableVsock adds a virtio-vsock device, giving the host a socket
+	// channel to the guest that does not depend on the virtual NIC.
+	EnableVsock bool
+
+	// VsockGuestCID is the guest's vsock context ID (0 = DefaultVsockGuestCID).
+	VsockGuestCID uint32
+}
+
+// DefaultVsockGuestCID is the guest context ID used when VsockGuestCID is
+// unset. CIDs 0-2 are reserved for the hypervisor and host.
+const DefaultVsockGuestCID = 3
+
+// GuestCID returns the vsock context ID the guest will have.
+func (c *VMConfig) GuestCID() uint32 {
+	if c.VsockGuestCID == 0 {
+		return DefaultVsockGuestCID
+	}
+	return c.VsockGuestCID
+}
+
+// StorageDevice is an additional disk image attached to the VM.
+type StorageDevice struct {
+	// Path is the disk or ISO image on the host.
+	Path string `json:"path"`
+
+	// ReadOnly attaches the image read-only.
+	ReadOnly bool `json:"read_only,omitempty"`
+}
+
+// Network interface modes.
+const (
+	NetworkNAT    = "nat"
+	NetworkBridge = "bridge"
+)
+
+// NetworkInterface describes one virtual NIC.
+type NetworkInterface struct {
+	// MAC is an optional MAC address (empty = random locally-administered).
+	MAC string
+
+	// Mode is NetworkNAT or NetworkBridge.
+	Mode string
+
+	// Interface is the host interface to bridge to (bridge mode only).
+	Interface string
+}
+
+// ParseNetworkInterface parses <mode>[:<host-iface>][,mac=<addr>] notation,
+// e.g. "nat", "bridge:br0" or "nat,mac=02:00:00:00:00:01".
+func ParseNetworkInterface(s string) (NetworkInterface, error) {
+	spec, opts, _ := strings.Cut(s, ",")
+	mode, iface, _ := strings.Cut(spec, ":")
+	n := NetworkInterface{Mode: mode, Interface: iface}
+
+	if opts != "" {
+		mac, ok := strings.CutPrefix(opts, "mac=")
+		if !ok {
+			return NetworkInterface{}, fmt.Errorf("network %q: unknown option %q", s, opts)
+		}
+		n.MAC = mac
+	}
+	if err := n.Validate(); err != nil {
+		return NetworkInterface{}, fmt.Errorf("network %q: %w", s, err)
+	}
+	return n, nil
+}
+
+// String formats the interface in the notation accepted by ParseNetworkInterface.
+func (n NetworkInterface) String() string {
+	s := n.Mode
+	if n.Interface != "" {
+		s += ":" + n.Interface
+	}
+	if n.MAC != "" {
+		s += ",mac=" + n.MAC
+	}
+	return s
+}
+
+// Validate checks the mode, bridge interface and MAC address.
+func (n NetworkInterface) Validate() error {
+	switch n.Mode {
+	case NetworkNAT:
+		if n.Interface != "" {
+			return fmt.Errorf("%s mode does not take a host interface", n.Mode)
+		}
+	case NetworkBridge:
+		if n.Interface == "" {
+			return fmt.Errorf("bridge mode needs a host interface (bridge:<iface>)")
+		}
+	case "host-only":
+		// Neither driver can isolate a NIC to the host: vz has no
+		// host-only attachment, and a KVM tap is whatever the host makes it
+		return fmt.Errorf("host-only networking is not supported; use nat or bridge:<iface>")
+	default:
+		return fmt.Errorf("%w: %q", ErrInvalidNetworkMode, n.Mode)
+	}
+	if n.MAC != "" {
+		if _, err := net.ParseMAC(n.MAC); err != nil {
+			return fmt.Errorf("invalid MAC address %q", n.MAC)
+		}
+	}
+	return nil
+}
+
+// NetworkInterfaces returns the NICs to create: Networks if set, otherwise
+// a single NAT NIC when EnableNetwork is true.
+func (c *VMConfig) NetworkInterfaces() []NetworkInterface {
+	if len(c.Networks) > 0 {
+		return c.Networks
+	}
+	if c.EnableNetwork {
+		return []NetworkInterface{{MAC: c.MACAddress, Mode: NetworkNAT}}
+	}
+	return nil
+}
+
+// vmConfigJSON is the JSON form of VMConfig, for dry runs and bug reports.
+type vmConfigJSON struct {
+	CPUs         int               `json:"cpus"`
+	MemoryMB     int               `json:"memory_mb"`
+	Kernel       string            `json:"kernel"`
+	Initrd       string            `json:"initrd,omitempty"`
+	Cmdline      string            `json:"cmdline"`
+	UEFI         bool              `json:"uefi,omitempty"`
+	Disk         string            `json:"disk"`
+	DiskReadOnly bool              `json:"disk_read_only,omitempty"`
+	ExtraDisks   []StorageDevice   `json:"extra_disks,omitempty"`
+	SharedDirs   []sharedDirJSON   `json:"shared_dirs,omitempty"`
+	Networks     []string          `json:"networks,omitempty"`
+	PortForwards []portForwardJSON `json:"port_forwards,omitempty"`
+	TapDevice    bool              `json:"tap_device,omitempty"`
+	VsockCID     uint32            `json:"vsock_guest_cid,omitempty"`
+}
+
+type sharedDirJSON struct {
+	Tag      string `json:"tag"`
+	Path     string `json:"path"`
+	ReadOnly bool   `json:"read_only,omitempty"`
+}
+
+type portForwardJSON struct {
+	Host  int    `json:"host"`
+	Guest int    `json:"guest"`
+	Proto string `json:"proto"`
+}
+
+// MarshalJSON renders the configuration as the driver would see it, with
+// networks resolved to the NICs that will be created. The root disk is
+// reduced to its base name so the output can be pasted into a bug report.
+func (c VMConfig) MarshalJSON() ([]byte, error) {
+	out := vmConfigJSON{
+		CPUs:         c.CPUs,
+		MemoryMB:     c.MemoryMB,
+		Kernel:       c.Kernel,
+		Initrd:       c.Initrd,
+		Cmdline:      c.Cmdline,
+		UEFI:         c.UEFI,
+		DiskReadOnly: c.DiskReadOnly,
+		ExtraDisks:   c.ExtraDisks,
+		TapDevice:    c.TapFile != nil,
+	}
+	if c.DiskPath != "" {
+		out.Disk = filepath.Base(c.DiskPath)
+	}
+	if c.EnableVsock {
+		out.VsockCID = c.GuestCID()
+	}
+	tags := make([]string, 0, len(c.SharedDirs))
+	for tag := range c.SharedDirs {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	for _, tag := range tags {
+		out.SharedDirs = append(out.SharedDirs, sharedDirJSON{Tag: tag, Path: c.SharedDirs[tag], ReadOnly: c.SharedDirsReadOnly[tag]})
+	}
+	for _, n := range c.NetworkInterfaces() {
+		out.Networks = append(out.Networks, n.String())
+	}
+	for _, pf := range c.PortForwards {
+		out.PortForwards = append(out.PortForwards, portForwardJSON(pf))
+	}
+	return json.Marshal(out)
 }
 
 // Validate performs basic validation of the configuration.
@@ -54,16 +269,24 @@ func (c *VMConfig) Validate() error {
 	if c.MemoryMB < 128 {
 		return ErrInsufficientMemory
 	}
-	if c.Kernel == "" {
+	if c.Kernel == "" && !c.UEFI {
 		return ErrMissingKernel
 	}
+	for _, n := range c.Networks {
+		if err := n.Validate(); err != nil {
+			return err
+		}
+	}
+	if c.EnableVsock && c.GuestCID() < DefaultVsockGuestCID {
+		return fmt.Errorf("%w: %d", ErrInvalidVsockCID, c.VsockGuestCID)
+	}
 	// Validate network config if enabled
-	if c.EnableNetwork {
+	if len(c.Networks) == 0 && c.EnableNetwork {
 		if c.NetworkMode == "" {
 			c.NetworkMode = "nat" // Default to NAT
 		}
 		if c.NetworkMode != "nat" && c.NetworkMode != "bridged" {
-			return ErrInvalidNetworkMode
+			return fmt.Errorf("%w: %q", ErrInvalidNetworkMode, c.NetworkMode)
 		}
 	}
 	return nil
@@ -0,0 +1,164 @@
+package hypervisor
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestParseNetworkInterface(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    NetworkInterface
+		wantErr bool
+	}{
+		{"nat", NetworkInterface{Mode: NetworkNAT}, false},
+		{"bridge:br0", NetworkInterface{Mode: NetworkBridge, Interface: "br0"}, false},
+		{"host-only", NetworkInterface{}, true},
+		{"nat,mac=02:00:00:00:00:01", NetworkInterface{Mode: NetworkNAT, MAC: "02:00:00:00:00:01"}, false},
+		{"bridge:en0,mac=02:00:00:00:00:02", NetworkInterface{Mode: NetworkBridge, Interface: "en0", MAC: "02:00:00:00:00:02"}, false},
+		{"bridge", NetworkInterface{}, true},
+		{"nat:eth0", NetworkInterface{}, true},
+		{"vlan", NetworkInterface{}, true},
+		{"nat,mac=nope", NetworkInterface{}, true},
+		{"nat,mtu=9000", NetworkInterface{}, true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseNetworkInterface(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseNetworkInterface(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseNetworkInterface(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+		if err == nil && got.String() != tt.in {
+			t.Errorf("String() = %q, want %q", got.String(), tt.in)
+		}
+	}
+
+	if _, err := ParseNetworkInterface("vlan"); !errors.Is(err, ErrInvalidNetworkMode) {
+		t.Errorf("unknown mode error = %v, want ErrInvalidNetworkMode", err)
+	}
+}
+
+func TestVMConfigNetworkInterfaces(t *testing.T) {
+	legacy := &VMConfig{EnableNetwork: true, MACAddress: "02:00:00:00:00:01"}
+	nics := legacy.NetworkInterfaces()
+	if len(nics) != 1 || nics[0].Mode != NetworkNAT || nics[0].MAC != legacy.MACAddress {
+		t.Errorf("legacy NetworkInterfaces() = %+v, want one NAT NIC with the configured MAC", nics)
+	}
+
+	if nics := (&VMConfig{}).NetworkInterfaces(); len(nics) != 0 {
+		t.Errorf("networking disabled: NetworkInterfaces() = %+v, want none", nics)
+	}
+
+	// Networks wins over the legacy fields
+	cfg := &VMConfig{
+		EnableNetwork: true,
+		MACAddress:    "02:00:00:00:00:01",
+		Networks: []NetworkInterface{
+			{Mode: NetworkNAT},
+			{Mode: NetworkBridge, Interface: "br0"},
+		},
+	}
+	nics = cfg.NetworkInterfaces()
+	if len(nics) != 2 || nics[0].MAC != "" || nics[1].Interface != "br0" {
+		t.Errorf("NetworkInterfaces() = %+v, want the Networks list", nics)
+	}
+}
+
+func TestVMConfigMarshalJSON(t *testing.T) {
+	tap, err := os.CreateTemp(t.TempDir(), "tap")
+	if err != nil {
+		t.Fatalf("CreateTemp: %v", err)
+	}
+	defer tap.Close()
+
+	cfg := &VMConfig{
+		CPUs:               2,
+		MemoryMB:           1024,
+		Kernel:             "/cache/alpine/vmlinuz",
+		Cmdline:            "console=hvc0 root=/dev/vda",
+		DiskPath:           "/home/alice/.vmterminal/data/default/disk.raw",
+		ExtraDisks:         []StorageDevice{{Path: "/data/seed.iso", ReadOnly: true}},
+		SharedDirs:         map[string]string{"src": "/home/alice/src", "docs": "/home/alice/docs"},
+		SharedDirsReadOnly: map[string]bool{"docs": true},
+		EnableNetwork:      true,
+		PortForwards:       []PortForward{{Host: 2222, Guest: 22, Proto: "tcp"}},
+		TapFile:            tap,
+	}
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+
+	got := string(data)
+	if strings.Contains(got, "alice/.vmterminal") {
+		t.Errorf("disk path not redacted: %s", got)
+	}
+	for _, want := range []string{
+		`"disk":"disk.raw"`,
+		`"kernel":"/cache/alpine/vmlinuz"`,
+		`"cmdline":"console=hvc0 root=/dev/vda"`,
+		`"extra_disks":[{"path":"/data/seed.iso","read_only":true}]`,
+		`"shared_dirs":[{"tag":"docs","path":"/home/alice/docs","read_only":true},{"tag":"src","path":"/home/alice/src"}]`,
+		`"networks":["nat"]`,
+		`"port_forwards":[{"host":2222,"guest":22,"proto":"tcp"}]`,
+		`"tap_device":true`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("JSON missing %s\ngot: %s", want, got)
+		}
+	}
+}
+
+func TestVMConfigValidateUEFI(t *testing.T) {
+	cfg := &VMConfig{CPUs: 1, MemoryMB: 512}
+	if err := cfg.Validate(); !errors.Is(err, ErrMissingKernel) {
+		t.Errorf("Validate without kernel = %v, want ErrMissingKernel", err)
+	}
+
+	cfg.UEFI = true
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Validate with UEFI = %v, want nil", err)
+	}
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	if !strings.Contains(string(data), `"uefi":true`) {
+		t.Errorf("JSON missing uefi: %s", data)
+	}
+}
+
+func TestVMConfigVsock(t *testing.T) {
+	cfg := &VMConfig{CPUs: 1, MemoryMB: 512, Kernel: "/vmlinuz", EnableVsock: true}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Validate with default CID = %v, want nil", err)
+	}
+	if got := cfg.GuestCID(); got != DefaultVsockGuestCID {
+		t.Errorf("GuestCID() = %d, want %d", got, DefaultVsockGuestCID)
+	}
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	if !strings.Contains(string(data), `"vsock_guest_cid":3`) {
+		t.Errorf("JSON missing vsock_guest_cid: %s", data)
+	}
+
+	cfg.VsockGuestCID = 2
+	if err := cfg.Validate(); !errors.Is(err, ErrInvalidVsockCID) {
+		t.Errorf("Validate with CID 2 = %v, want ErrInvalidVsockCID", err)
+	}
+
+	// A reserved CID is harmless while vsock is off
+	cfg.EnableVsock = false
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Validate with vsock disabled = %v, want nil", err)
+	}
+}
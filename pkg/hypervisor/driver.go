@@ -5,6 +5,7 @@ package hypervisor
 import (
 	"context"
 	"io"
+	"net"
 )
 
 // Driver is the main interface for hypervisor operations.
@@ -25,8 +26,40 @@ type Driver interface {
 // Used for early validation before VM configuration.
 type Capabilities struct {
 	SharedDirs bool // virtio-fs or similar
+	Sharing9P  bool // virtio-9p shared directories, where virtio-fs is missing
 	Networking bool // virtio-net or similar
 	Snapshots  bool // VM state snapshots
+	Suspend    bool // Pause/resume of a running VM
+	Vsock      bool // virtio-vsock host-guest sockets
+}
+
+// VsockDialer is implemented by drivers that can open virtio-vsock
+// connections to the guest. Connect only works after Start() on a VM
+// created with EnableVsock.
+type VsockDialer interface {
+	// Connect opens a connection to port on the guest.
+	Connect(ctx context.Context, port uint32) (net.Conn, error)
+}
+
+// MACReporter is implemented by drivers that can report the hardware
+// address of the VM's first network interface, including one the driver
+// generated itself. MACAddress returns "" before Create() or when the VM
+// has no network interface.
+type MACReporter interface {
+	MACAddress() string
+}
+
+// ConsoleResizer is implemented by drivers whose console device can tell
+// the guest its window size. ResizeConsole only works after Create().
+type ConsoleResizer interface {
+	ResizeConsole(rows, cols uint16) error
+}
+
+// PortForward forwards a host port to a guest port.
+type PortForward struct {
+	Host  int    // Host port
+	Guest int    // Guest port
+	Proto string // "tcp" or "udp"
 }
 
 // Lifecycle defines VM lifecycle operations.
@@ -45,6 +78,13 @@ type Lifecycle interface {
 
 	// Kill forcefully terminates the VM.
 	Kill(ctx context.Context) error
+
+	// Suspend pauses a running VM, keeping its memory resident.
+	// Returns ErrNotSupported if the driver cannot pause VMs.
+	Suspend(ctx context.Context) error
+
+	// Resume continues a suspended VM.
+	Resume(ctx context.Context) error
 }
 
 // Info contains driver metadata.
@@ -8,6 +8,7 @@ import (
 	"io"
 	"net"
 	"os"
+	"path/filepath"
 	"runtime"
 	"sync"
 
@@ -21,6 +22,7 @@ type vzDriver struct {
 	vm         *vz.VirtualMachine
 	vmCfg      *vz.VirtualMachineConfiguration
 	state      driverState
+	mac        string    // MAC of the first network interface
 	consoleIn  io.Writer // Write to this to send to VM
 	consoleOut io.Reader // Read from this to get VM output
 	// Raw pipe handles for closing
@@ -34,11 +36,12 @@ const (
 	stateNew driverState = iota
 	stateCreated
 	stateRunning
+	stateSuspended
 	stateStopped
 )
 
-// NewDriver creates a new vz-based driver for macOS.
-func NewDriver() (Driver, error) {
+// newPlatformDriver creates a new vz-based driver for macOS.
+func newPlatformDriver() (Driver, error) {
 	return &vzDriver{
 		state: stateNew,
 	}, nil
@@ -56,10 +59,37 @@ func (d *vzDriver) Validate(ctx context.Context, cfg *VMConfig) error {
 	if err := cfg.Validate(); err != nil {
 		return err
 	}
-	// vz-specific validation could go here
+	if cfg.EnableVsock && cfg.GuestCID() != DefaultVsockGuestCID {
+		return fmt.Errorf("vzDriver: guest CID %d requested, but Virtualization.framework always assigns %d", cfg.VsockGuestCID, DefaultVsockGuestCID)
+	}
 	return nil
 }
 
+// efiVarsFile holds the UEFI NVRAM variables, next to the root disk.
+const efiVarsFile = "efi-vars.fd"
+
+// newBootLoader returns an EFI boot loader when cfg boots through UEFI and
+// a Linux kernel boot loader otherwise.
+func newBootLoader(cfg *VMConfig) (vz.BootLoader, error) {
+	if !cfg.UEFI {
+		return vz.NewLinuxBootLoader(cfg.Kernel,
+			vz.WithCommandLine(cfg.Cmdline),
+			vz.WithInitrd(cfg.Initrd),
+		)
+	}
+
+	varsPath := filepath.Join(filepath.Dir(cfg.DiskPath), efiVarsFile)
+	var opts []vz.NewEFIVariableStoreOption
+	if _, err := os.Stat(varsPath); os.IsNotExist(err) {
+		opts = append(opts, vz.WithCreatingEFIVariableStore())
+	}
+	store, err := vz.NewEFIVariableStore(varsPath, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("EFI variable store: %w", err)
+	}
+	return vz.NewEFIBootLoader(vz.WithEFIVariableStore(store))
+}
+
 func (d *vzDriver) Create(ctx context.Context, cfg *VMConfig) error {
 	d.mu.Lock()
 	defer d.mu.Unlock()
@@ -69,10 +99,7 @@ func (d *vzDriver) Create(ctx context.Context, cfg *VMConfig) error {
 	}
 
 	// Create boot loader
-	bootLoader, err := vz.NewLinuxBootLoader(cfg.Kernel,
-		vz.WithCommandLine(cfg.Cmdline),
-		vz.WithInitrd(cfg.Initrd),
-	)
+	bootLoader, err := newBootLoader(cfg)
 	if err != nil {
 		return fmt.Errorf("vzDriver: create boot loader: %w", err)
 	}
@@ -125,53 +152,51 @@ func (d *vzDriver) Create(ctx context.Context, cfg *VMConfig) error {
 		serialCfg,
 	})
 
-	// Add network device if enabled
-	if cfg.EnableNetwork {
-		// Create NAT attachment for network
-		natAttachment, err := vz.NewNATNetworkDeviceAttachment()
-		if err != nil {
-			return fmt.Errorf("vzDriver: create NAT attachment: %w", err)
+	// Add one network device per interface
+	var mac string
+	if nics := cfg.NetworkInterfaces(); len(nics) > 0 {
+		var netDevices []*vz.VirtioNetworkDeviceConfiguration
+		for i, nic := range nics {
+			netConfig, macAddr, err := vzNetworkDevice(nic)
+			if err != nil {
+				return fmt.Errorf("vzDriver: network %d (%s): %w", i, nic.Mode, err)
+			}
+			if i == 0 {
+				mac = macAddr.String()
+			}
+			netDevices = append(netDevices, netConfig)
 		}
+		vmCfg.SetNetworkDevicesVirtualMachineConfiguration(netDevices)
+	}
 
-		// Create network device configuration
-		netConfig, err := vz.NewVirtioNetworkDeviceConfiguration(natAttachment)
+	// Add a virtio-vsock device for host-guest sockets
+	if cfg.EnableVsock {
+		socketCfg, err := vz.NewVirtioSocketDeviceConfiguration()
 		if err != nil {
-			return fmt.Errorf("vzDriver: create network config: %w", err)
+			return fmt.Errorf("vzDriver: create vsock device: %w", err)
 		}
+		vmCfg.SetSocketDevicesVirtualMachineConfiguration([]vz.SocketDeviceConfiguration{socketCfg})
+	}
 
-		// Set MAC address (auto-generate or use provided)
-		var macAddr *vz.MACAddress
-		if cfg.MACAddress != "" {
-			hwAddr, err := net.ParseMAC(cfg.MACAddress)
-			if err != nil {
-				return fmt.Errorf("vzDriver: parse MAC address: %w", err)
-			}
-			macAddr, err = vz.NewMACAddress(hwAddr)
+	// Add the root disk followed by any extra disks
+	disks := cfg.ExtraDisks
+	if cfg.DiskPath != "" {
+		disks = append([]StorageDevice{{Path: cfg.DiskPath, ReadOnly: cfg.DiskReadOnly}}, disks...)
+	}
+	if len(disks) > 0 {
+		var storageDevices []vz.StorageDeviceConfiguration
+		for _, disk := range disks {
+			diskAttachment, err := vz.NewDiskImageStorageDeviceAttachment(disk.Path, disk.ReadOnly)
 			if err != nil {
-				return fmt.Errorf("vzDriver: create MAC address: %w", err)
+				return fmt.Errorf("vzDriver: create disk attachment: %w", err)
 			}
-		} else {
-			macAddr, err = vz.NewRandomLocallyAdministeredMACAddress()
+			blockDevice, err := vz.NewVirtioBlockDeviceConfiguration(diskAttachment)
 			if err != nil {
-				return fmt.Errorf("vzDriver: generate random MAC: %w", err)
+				return fmt.Errorf("vzDriver: create block device: %w", err)
 			}
+			storageDevices = append(storageDevices, blockDevice)
 		}
-		netConfig.SetMACAddress(macAddr)
-
-		vmCfg.SetNetworkDevicesVirtualMachineConfiguration([]*vz.VirtioNetworkDeviceConfiguration{netConfig})
-	}
-
-	// Add disk if specified
-	if cfg.DiskPath != "" {
-		diskAttachment, err := vz.NewDiskImageStorageDeviceAttachment(cfg.DiskPath, false)
-		if err != nil {
-			return fmt.Errorf("vzDriver: create disk attachment: %w", err)
-		}
-		blockDevice, err := vz.NewVirtioBlockDeviceConfiguration(diskAttachment)
-		if err != nil {
-			return fmt.Errorf("vzDriver: create block device: %w", err)
-		}
-		vmCfg.SetStorageDevicesVirtualMachineConfiguration([]vz.StorageDeviceConfiguration{blockDevice})
+		vmCfg.SetStorageDevicesVirtualMachineConfiguration(storageDevices)
 	}
 
 	// Add shared directories via virtio-fs
@@ -216,6 +241,7 @@ func (d *vzDriver) Create(ctx context.Context, cfg *VMConfig) error {
 	}
 
 	d.cfg = cfg
+	d.mac = mac
 	d.vmCfg = vmCfg
 	d.vm = vm
 	d.state = stateCreated
@@ -239,15 +265,17 @@ func (d *vzDriver) Start(ctx context.Context) (chan error, error) {
 
 	d.state = stateRunning
 
-	// Monitor VM state in background
+	// Monitor VM state in background. Pause/resume also emit state
+	// changes, so keep watching until the VM actually stops.
 	go func() {
-		<-d.vm.StateChangedNotify()
-		state := d.vm.State()
-		if state == vz.VirtualMachineStateStopped || state == vz.VirtualMachineStateError {
-			d.mu.Lock()
-			d.state = stateStopped
-			d.mu.Unlock()
-			errCh <- nil
+		for state := range d.vm.StateChangedNotify() {
+			if state == vz.VirtualMachineStateStopped || state == vz.VirtualMachineStateError {
+				d.mu.Lock()
+				d.state = stateStopped
+				d.mu.Unlock()
+				errCh <- nil
+				return
+			}
 		}
 	}()
 
@@ -272,8 +300,14 @@ func (d *vzDriver) Stop(ctx context.Context) error {
 		if err != nil || !ok {
 			return fmt.Errorf("vzDriver: request stop failed: %w", err)
 		}
+		// The guest shuts down in its own time; the state monitor marks
+		// the driver stopped once it has, and until then Kill still works
+		return nil
 	}
 
+	if err := d.vm.Stop(); err != nil {
+		return fmt.Errorf("vzDriver: force stop: %w", err)
+	}
 	d.state = stateStopped
 	return nil
 }
@@ -282,7 +316,7 @@ func (d *vzDriver) Kill(ctx context.Context) error {
 	d.mu.Lock()
 	defer d.mu.Unlock()
 
-	if d.state != stateRunning {
+	if d.state != stateRunning && d.state != stateSuspended {
 		return ErrNotRunning
 	}
 
@@ -294,6 +328,44 @@ func (d *vzDriver) Kill(ctx context.Context) error {
 	return nil
 }
 
+func (d *vzDriver) Suspend(ctx context.Context) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.state != stateRunning {
+		return ErrNotRunning
+	}
+
+	if !d.vm.CanPause() {
+		return fmt.Errorf("vzDriver: VM cannot be paused in its current state")
+	}
+	if err := d.vm.Pause(); err != nil {
+		return fmt.Errorf("vzDriver: pause VM: %w", err)
+	}
+
+	d.state = stateSuspended
+	return nil
+}
+
+func (d *vzDriver) Resume(ctx context.Context) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.state != stateSuspended {
+		return ErrNotSuspended
+	}
+
+	if !d.vm.CanResume() {
+		return fmt.Errorf("vzDriver: VM cannot be resumed in its current state")
+	}
+	if err := d.vm.Resume(); err != nil {
+		return fmt.Errorf("vzDriver: resume VM: %w", err)
+	}
+
+	d.state = stateRunning
+	return nil
+}
+
 func (d *vzDriver) Console() (io.Writer, io.Reader, error) {
 	d.mu.Lock()
 	defer d.mu.Unlock()
@@ -333,10 +405,120 @@ func (d *vzDriver) CloseConsole() error {
 	return nil
 }
 
+// MACAddress returns the MAC of the VM's first network interface. It
+// implements MACReporter.
+func (d *vzDriver) MACAddress() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.mac
+}
+
 func (d *vzDriver) Capabilities() Capabilities {
 	return Capabilities{
 		SharedDirs: true,  // virtio-fs supported
 		Networking: true,  // virtio-net supported
 		Snapshots:  false, // Not yet implemented
+		Suspend:    true,  // VZVirtualMachine pause/resume
+		Vsock:      true,  // VZVirtioSocketDevice
+	}
+}
+
+// Connect opens a vsock connection to port on the guest. It implements
+// VsockDialer.
+func (d *vzDriver) Connect(ctx context.Context, port uint32) (net.Conn, error) {
+	d.mu.Lock()
+	if d.state != stateRunning {
+		d.mu.Unlock()
+		return nil, ErrNotRunning
+	}
+	devices := d.vm.SocketDevices()
+	d.mu.Unlock()
+	if len(devices) == 0 {
+		return nil, fmt.Errorf("vzDriver: %w: VM was created without EnableVsock", ErrNotSupported)
+	}
+
+	// VZVirtioSocketDevice has no cancellable connect, so give up waiting
+	// on ctx and close the connection if it arrives later
+	type result struct {
+		conn net.Conn
+		err  error
+	}
+	ch := make(chan result, 1)
+	go func() {
+		conn, err := devices[0].Connect(port)
+		if err != nil {
+			ch <- result{err: fmt.Errorf("vzDriver: vsock connect to port %d: %w", port, err)}
+			return
+		}
+		ch <- result{conn: conn}
+	}()
+
+	select {
+	case r := <-ch:
+		return r.conn, r.err
+	case <-ctx.Done():
+		go func() {
+			if r := <-ch; r.conn != nil {
+				r.conn.Close()
+			}
+		}()
+		return nil, ctx.Err()
+	}
+}
+
+// vzNetworkDevice creates a virtio-net device for nic and returns it with
+// the MAC address it was given.
+func vzNetworkDevice(nic NetworkInterface) (*vz.VirtioNetworkDeviceConfiguration, *vz.MACAddress, error) {
+	var attachment vz.NetworkDeviceAttachment
+	switch nic.Mode {
+	case NetworkNAT:
+		nat, err := vz.NewNATNetworkDeviceAttachment()
+		if err != nil {
+			return nil, nil, fmt.Errorf("create NAT attachment: %w", err)
+		}
+		attachment = nat
+	case NetworkBridge:
+		var host vz.BridgedNetwork
+		for _, iface := range vz.NetworkInterfaces() {
+			if iface.Identifier() == nic.Interface {
+				host = iface
+				break
+			}
+		}
+		if host == nil {
+			return nil, nil, fmt.Errorf("host interface %q is not available for bridging", nic.Interface)
+		}
+		bridged, err := vz.NewBridgedNetworkDeviceAttachment(host)
+		if err != nil {
+			return nil, nil, fmt.Errorf("create bridged attachment: %w", err)
+		}
+		attachment = bridged
+	default:
+		return nil, nil, fmt.Errorf("%w: %q", ErrInvalidNetworkMode, nic.Mode)
+	}
+
+	netConfig, err := vz.NewVirtioNetworkDeviceConfiguration(attachment)
+	if err != nil {
+		return nil, nil, fmt.Errorf("create network config: %w", err)
+	}
+
+	// Set MAC address (auto-generate or use provided)
+	var macAddr *vz.MACAddress
+	if nic.MAC != "" {
+		hwAddr, err := net.ParseMAC(nic.MAC)
+		if err != nil {
+			return nil, nil, fmt.Errorf("parse MAC address: %w", err)
+		}
+		macAddr, err = vz.NewMACAddress(hwAddr)
+		if err != nil {
+			return nil, nil, fmt.Errorf("create MAC address: %w", err)
+		}
+	} else {
+		macAddr, err = vz.NewRandomLocallyAdministeredMACAddress()
+		if err != nil {
+			return nil, nil, fmt.Errorf("generate random MAC: %w", err)
+		}
 	}
+	netConfig.SetMACAddress(macAddr)
+	return netConfig, macAddr, nil
 }
@@ -4,10 +4,13 @@ package hypervisor
 
 import (
 	"context"
+	"crypto/rand"
 	"fmt"
 	"io"
+	"net"
 	"os"
 	"runtime"
+	"sort"
 	"sync"
 
 	hypeos "github.com/c35s/hype/os/linux"
@@ -21,10 +24,12 @@ type kvmDriver struct {
 	cfg        *VMConfig
 	vm         *vmm.VM
 	state      driverState
+	mac        net.HardwareAddr // MAC of the virtio-net device, if any
 	cancel     context.CancelFunc
-	diskFile   *os.File
+	diskFiles  []*os.File
 	consoleIn  io.Writer // Write to this to send to VM
 	consoleOut io.Reader // Read from this to get VM output
+	consoleSz  *virtio.ConsoleSize
 	// Raw pipe handles for closing
 	inputWriter  *os.File
 	outputReader *os.File
@@ -39,11 +44,11 @@ const (
 	stateStopped
 )
 
-// NewDriver creates a new KVM-based driver for Linux.
-func NewDriver() (Driver, error) {
+// newPlatformDriver creates a new KVM-based driver for Linux.
+func newPlatformDriver() (Driver, error) {
 	// Check if /dev/kvm exists and is accessible
 	if _, err := os.Stat("/dev/kvm"); err != nil {
-		return nil, fmt.Errorf("kvmDriver: /dev/kvm not accessible: %w", err)
+		return nil, &ErrHypervisorUnavailable{Reason: fmt.Sprintf("/dev/kvm not accessible: %v", err)}
 	}
 	return &kvmDriver{
 		state: stateNew,
@@ -62,6 +67,12 @@ func (d *kvmDriver) Validate(ctx context.Context, cfg *VMConfig) error {
 	if err := cfg.Validate(); err != nil {
 		return err
 	}
+	if cfg.UEFI {
+		return fmt.Errorf("kvmDriver: %w", ErrUEFINotSupported)
+	}
+	if cfg.EnableVsock {
+		return fmt.Errorf("kvmDriver: %w", ErrVsockNotSupported)
+	}
 	// Check kernel file exists
 	if _, err := os.Stat(cfg.Kernel); err != nil {
 		return fmt.Errorf("kvmDriver: kernel not found: %w", err)
@@ -76,6 +87,13 @@ func (d *kvmDriver) Create(ctx context.Context, cfg *VMConfig) error {
 	if d.state != stateNew {
 		return fmt.Errorf("kvmDriver: invalid state for Create")
 	}
+	// The warm path skips Validate, so check here as well
+	if cfg.UEFI {
+		return fmt.Errorf("kvmDriver: %w", ErrUEFINotSupported)
+	}
+	if cfg.EnableVsock {
+		return fmt.Errorf("kvmDriver: %w", ErrVsockNotSupported)
+	}
 
 	// Read kernel
 	kernel, err := os.ReadFile(cfg.Kernel)
@@ -111,14 +129,16 @@ func (d *kvmDriver) Create(ctx context.Context, cfg *VMConfig) error {
 	d.consoleOut = outputReader
 	d.inputWriter = inputWriter
 	d.outputReader = outputReader
+	d.consoleSz = &virtio.ConsoleSize{}
 
 	// Build hype configuration
 	hypeCfg := vmm.Config{
 		MemSize: int(cfg.MemoryMB) * 1024 * 1024,
 		Devices: []virtio.DeviceConfig{
 			&virtio.ConsoleDevice{
-				In:  inputReader,
-				Out: outputWriter,
+				In:   inputReader,
+				Out:  outputWriter,
+				Size: d.consoleSz,
 			},
 		},
 		Loader: &hypeos.Loader{
@@ -128,28 +148,69 @@ func (d *kvmDriver) Create(ctx context.Context, cfg *VMConfig) error {
 		},
 	}
 
-	// Add block device if disk path specified
+	// Add the root disk followed by any extra disks
+	disks := cfg.ExtraDisks
 	if cfg.DiskPath != "" {
-		diskFile, err := os.OpenFile(cfg.DiskPath, os.O_RDWR, 0)
+		disks = append([]StorageDevice{{Path: cfg.DiskPath, ReadOnly: cfg.DiskReadOnly}}, disks...)
+	}
+	for _, disk := range disks {
+		flag := os.O_RDWR
+		if disk.ReadOnly {
+			flag = os.O_RDONLY
+		}
+		diskFile, err := os.OpenFile(disk.Path, flag, 0)
 		if err != nil {
+			d.closeDisks()
 			return fmt.Errorf("kvmDriver: open disk: %w", err)
 		}
 		hypeCfg.Devices = append(hypeCfg.Devices, &virtio.BlockDevice{
-			Storage: &virtio.FileStorage{File: diskFile},
+			Storage:  &virtio.FileStorage{File: diskFile},
+			ReadOnly: disk.ReadOnly,
 		})
-		d.diskFile = diskFile
+		d.diskFiles = append(d.diskFiles, diskFile)
 	}
 
-	// Note: Warnings about unsupported SharedDirs and Networking are now
-	// handled earlier by config.ValidateConfig() in the run command.
+	// Add virtio-net device backed by a tap interface if one was provided.
+	// The tap device is the only backend, so there is at most one NIC; for
+	// bridge mode the caller attaches the tap to the bridge on the host.
+	if nics := cfg.NetworkInterfaces(); len(nics) > 0 && cfg.TapFile != nil {
+		if len(nics) > 1 {
+			d.closeDisks()
+			return fmt.Errorf("kvmDriver: %d network interfaces requested, but only one tap device is supported", len(nics))
+		}
+		mac, err := kvmMACAddress(nics[0].MAC)
+		if err != nil {
+			d.closeDisks()
+			return err
+		}
+		hypeCfg.Devices = append(hypeCfg.Devices, &virtio.NetDevice{
+			MAC:     mac,
+			Backend: cfg.TapFile,
+		})
+		d.mac = mac
+	}
+
+	// Share directories over 9P, since hype has no virtio-fs device
+	tags := make([]string, 0, len(cfg.SharedDirs))
+	for tag := range cfg.SharedDirs {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	for _, tag := range tags {
+		hypeCfg.Devices = append(hypeCfg.Devices, &Plan9ShareDevice{
+			Tag:      tag,
+			Path:     cfg.SharedDirs[tag],
+			ReadOnly: cfg.SharedDirsReadOnly[tag],
+		})
+	}
+
+	// Note: Warnings about Networking without a tap device are handled
+	// earlier by config.ValidateConfig() in the run command.
 
 	// Create the VM (but don't run yet)
 	vm, err := vmm.New(hypeCfg)
 	if err != nil {
-		if d.diskFile != nil {
-			d.diskFile.Close()
-			d.diskFile = nil
-		}
+		d.closeDisks()
 		return fmt.Errorf("kvmDriver: create VM: %w", err)
 	}
 
@@ -215,17 +276,32 @@ func (d *kvmDriver) Kill(ctx context.Context) error {
 	// For KVM, Kill is the same as Stop (context cancellation)
 	err := d.Stop(ctx)
 
-	// Close disk file if open
+	// Close disk files if open
 	d.mu.Lock()
-	if d.diskFile != nil {
-		d.diskFile.Close()
-		d.diskFile = nil
-	}
+	d.closeDisks()
 	d.mu.Unlock()
 
 	return err
 }
 
+// closeDisks closes every open disk image. Callers must hold d.mu.
+func (d *kvmDriver) closeDisks() {
+	for _, f := range d.diskFiles {
+		f.Close()
+	}
+	d.diskFiles = nil
+}
+
+// Suspend is not supported: hype has no way to pause vCPUs.
+func (d *kvmDriver) Suspend(ctx context.Context) error {
+	return ErrNotSupported
+}
+
+// Resume is not supported; see Suspend.
+func (d *kvmDriver) Resume(ctx context.Context) error {
+	return ErrNotSupported
+}
+
 func (d *kvmDriver) Console() (io.Writer, io.Reader, error) {
 	d.mu.Lock()
 	defer d.mu.Unlock()
@@ -265,10 +341,62 @@ func (d *kvmDriver) CloseConsole() error {
 	return nil
 }
 
+// MACAddress returns the MAC of the VM's virtio-net device. It implements
+// MACReporter.
+func (d *kvmDriver) MACAddress() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.mac == nil {
+		return ""
+	}
+	return d.mac.String()
+}
+
+// ResizeConsole sets the window size of the virtio console. The guest
+// applies it to hvc0 when the config change interrupt arrives. It
+// implements ConsoleResizer.
+func (d *kvmDriver) ResizeConsole(rows, cols uint16) error {
+	d.mu.Lock()
+	sz := d.consoleSz
+	d.mu.Unlock()
+	if sz == nil {
+		return ErrNotCreated
+	}
+	sz.Set(cols, rows)
+	return nil
+}
+
+// Capabilities reports Networking only once the VM was created with a tap
+// device, since virtio-net needs a host-side backend.
 func (d *kvmDriver) Capabilities() Capabilities {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
 	return Capabilities{
-		SharedDirs: false, // hype lacks virtio-fs/9p
-		Networking: false, // hype lacks virtio-net
+		SharedDirs: false, // hype lacks virtio-fs
+		Sharing9P:  true,  // Plan9ShareDevice
+		Networking: d.cfg != nil && len(d.cfg.NetworkInterfaces()) > 0 && d.cfg.TapFile != nil,
 		Snapshots:  false, // Not implemented
+		Suspend:    false, // hype cannot pause vCPUs
+		Vsock:      false, // hype has no vhost-vsock device
+	}
+}
+
+// kvmMACAddress parses mac, or generates a random locally-administered
+// unicast address if mac is empty.
+func kvmMACAddress(mac string) (net.HardwareAddr, error) {
+	if mac != "" {
+		hw, err := net.ParseMAC(mac)
+		if err != nil {
+			return nil, fmt.Errorf("kvmDriver: parse MAC address: %w", err)
+		}
+		return hw, nil
+	}
+
+	hw := make(net.HardwareAddr, 6)
+	if _, err := rand.Read(hw); err != nil {
+		return nil, fmt.Errorf("kvmDriver: generate random MAC: %w", err)
 	}
+	hw[0] = (hw[0] | 0x02) &^ 0x01
+	return hw, nil
 }
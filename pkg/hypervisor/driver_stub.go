@@ -2,7 +2,7 @@
 
 package hypervisor
 
-// NewDriver returns an error on unsupported platforms.
-func NewDriver() (Driver, error) {
+// newPlatformDriver returns an error on unsupported platforms.
+func newPlatformDriver() (Driver, error) {
 	return nil, ErrUnsupportedPlatform
 }
@@ -1,13 +1,17 @@
 package hypervisor
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
 
 // Configuration errors
 var (
 	ErrInvalidCPUCount    = errors.New("hypervisor: CPU count must be at least 1")
 	ErrInsufficientMemory = errors.New("hypervisor: memory must be at least 128MB")
 	ErrMissingKernel      = errors.New("hypervisor: kernel path is required")
-	ErrInvalidNetworkMode = errors.New("hypervisor: network mode must be 'nat' or 'bridged'")
+	ErrInvalidNetworkMode = errors.New("hypervisor: invalid network mode")
+	ErrInvalidVsockCID    = errors.New("hypervisor: vsock guest CID must be at least 3")
 )
 
 // Runtime errors
@@ -15,9 +19,23 @@ var (
 	ErrNotCreated     = errors.New("hypervisor: VM not created")
 	ErrAlreadyRunning = errors.New("hypervisor: VM is already running")
 	ErrNotRunning     = errors.New("hypervisor: VM is not running")
+	ErrNotSuspended   = errors.New("hypervisor: VM is not suspended")
 )
 
 // Platform errors
 var (
 	ErrUnsupportedPlatform = errors.New("hypervisor: platform not supported")
+	ErrNotSupported        = errors.New("hypervisor: operation not supported by this driver")
+	ErrUEFINotSupported    = errors.New("hypervisor: UEFI boot not supported by this driver")
+	ErrVsockNotSupported   = errors.New("hypervisor: virtio-vsock not supported by this driver")
 )
+
+// ErrHypervisorUnavailable is returned when the host's hypervisor cannot be
+// used, e.g. because /dev/kvm is missing or not accessible.
+type ErrHypervisorUnavailable struct {
+	Reason string
+}
+
+func (e *ErrHypervisorUnavailable) Error() string {
+	return fmt.Sprintf("hypervisor: unavailable: %s", e.Reason)
+}
@@ -0,0 +1,9 @@
+package hypervisor
+
+// HostCheck is the outcome of one check that the host can run VMs.
+type HostCheck struct {
+	Name     string `json:"name"`
+	OK       bool   `json:"ok"`
+	Required bool   `json:"required"`
+	Detail   string `json:"detail,omitempty"` // What is wrong and how to fix it
+}
@@ -0,0 +1,44 @@
+//go:build darwin
+
+package hypervisor
+
+import (
+	"os"
+	"strings"
+
+	"github.com/Code-Hex/vz/v3"
+)
+
+// CheckHost reports whether Virtualization.framework can be used, by
+// building and validating a minimal VM configuration.
+func CheckHost() []HostCheck {
+	check := HostCheck{Name: "Virtualization.framework", Required: true}
+
+	// The boot loader only checks that the kernel file exists
+	kernel, err := os.CreateTemp("", "vmterminal-doctor-")
+	if err != nil {
+		check.Detail = err.Error()
+		return []HostCheck{check}
+	}
+	kernel.Close()
+	defer os.Remove(kernel.Name())
+
+	bootLoader, err := vz.NewLinuxBootLoader(kernel.Name())
+	if err != nil {
+		check.Detail = err.Error()
+		return []HostCheck{check}
+	}
+	cfg, err := vz.NewVirtualMachineConfiguration(bootLoader, 1, 512*1024*1024)
+	if err != nil {
+		check.Detail = err.Error()
+		return []HostCheck{check}
+	}
+	// The configuration has no devices, so only an entitlement error matters
+	if _, err := cfg.Validate(); err != nil && strings.Contains(err.Error(), "entitlement") {
+		check.Detail = "binary lacks the com.apple.security.virtualization entitlement (re-sign with codesign)"
+		return []HostCheck{check}
+	}
+
+	check.OK = true
+	return []HostCheck{check}
+}
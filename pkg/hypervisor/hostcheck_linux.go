@@ -0,0 +1,70 @@
+//go:build linux
+
+package hypervisor
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+)
+
+// CheckHost reports whether KVM is available and usable by this user.
+func CheckHost() []HostCheck {
+	exists := HostCheck{Name: "/dev/kvm present", Required: true}
+	if _, err := os.Stat("/dev/kvm"); err != nil {
+		exists.Detail = "KVM is unavailable: enable virtualization in the firmware and load kvm_intel or kvm_amd"
+		return []HostCheck{exists}
+	}
+	exists.OK = true
+
+	access := HostCheck{Name: "/dev/kvm accessible", Required: true}
+	if f, err := os.OpenFile("/dev/kvm", os.O_RDWR, 0); err != nil {
+		if errors.Is(err, os.ErrPermission) {
+			access.Detail = "permission denied: sudo usermod -aG kvm $USER, then log in again"
+		} else {
+			access.Detail = err.Error()
+		}
+	} else {
+		f.Close()
+		access.OK = true
+	}
+
+	// Access can also come from root or an ACL, so group membership alone
+	// is not required
+	group := HostCheck{Name: "user in kvm group"}
+	inGroup, err := inKVMGroup()
+	switch {
+	case err != nil:
+		group.Detail = err.Error()
+	case !inGroup:
+		group.Detail = "sudo usermod -aG kvm $USER, then log in again"
+	default:
+		group.OK = true
+	}
+
+	return []HostCheck{exists, access, group}
+}
+
+// inKVMGroup reports whether the current process has the kvm group.
+func inKVMGroup() (bool, error) {
+	g, err := user.LookupGroup("kvm")
+	if err != nil {
+		return false, fmt.Errorf("no kvm group on this host")
+	}
+	gid, err := strconv.Atoi(g.Gid)
+	if err != nil {
+		return false, fmt.Errorf("parse kvm gid: %w", err)
+	}
+	groups, err := os.Getgroups()
+	if err != nil {
+		return false, fmt.Errorf("get groups: %w", err)
+	}
+	for _, id := range groups {
+		if id == gid {
+			return true, nil
+		}
+	}
+	return os.Getegid() == gid, nil
+}
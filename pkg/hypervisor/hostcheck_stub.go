@@ -0,0 +1,12 @@
+//go:build !darwin && !linux
+
+package hypervisor
+
+// CheckHost reports that no hypervisor is supported on this platform.
+func CheckHost() []HostCheck {
+	return []HostCheck{{
+		Name:     "hypervisor",
+		Required: true,
+		Detail:   ErrUnsupportedPlatform.Error(),
+	}}
+}
@@ -0,0 +1,252 @@
+package hypervisor
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"runtime"
+	"sync"
+)
+
+// MockDriver is an in-memory Driver for tests. It "boots" instantly without
+// spawning a process or touching a hypervisor: Start connects the console
+// to pipes whose guest ends are returned by Guest, and the guest runs until
+// it is stopped, killed, or powered off with Exit. Install it with
+// SetDriverFactory to run commands end to end on any platform.
+type MockDriver struct {
+	// Errors returned by the matching operations, to inject failures. A
+	// nil error lets the operation go ahead.
+	ValidateErr error
+	CreateErr   error
+	StartErr    error
+	StopErr     error
+	KillErr     error
+
+	// IgnoreStop makes the guest accept stop requests without shutting
+	// down, so that only Kill or Exit ends it.
+	IgnoreStop bool
+
+	// Caps is what Capabilities reports. Suspend and Resume return
+	// ErrNotSupported unless Caps.Suspend is set.
+	Caps Capabilities
+
+	mu        sync.Mutex
+	config    *VMConfig
+	running   bool
+	suspended bool
+	boots     int
+	errCh     chan error
+	started   chan struct{}
+
+	// Host and guest ends of the console pipes of the current boot
+	hostIn   *io.PipeWriter
+	hostOut  *io.PipeReader
+	guestIn  *io.PipeReader
+	guestOut *io.PipeWriter
+}
+
+// NewMockDriver creates a MockDriver whose operations all succeed.
+func NewMockDriver() *MockDriver {
+	return &MockDriver{started: make(chan struct{})}
+}
+
+// Info returns the mock driver's metadata.
+func (d *MockDriver) Info() Info {
+	return Info{
+		Name:    "mock",
+		Version: "1.0.0",
+		Arch:    runtime.GOARCH,
+	}
+}
+
+// Capabilities returns d.Caps.
+func (d *MockDriver) Capabilities() Capabilities {
+	return d.Caps
+}
+
+// Validate checks cfg like a real driver would.
+func (d *MockDriver) Validate(ctx context.Context, cfg *VMConfig) error {
+	if d.ValidateErr != nil {
+		return d.ValidateErr
+	}
+	return cfg.Validate()
+}
+
+// Create records cfg for Config.
+func (d *MockDriver) Create(ctx context.Context, cfg *VMConfig) error {
+	if d.CreateErr != nil {
+		return d.CreateErr
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.running {
+		return ErrAlreadyRunning
+	}
+	d.config = cfg
+	return nil
+}
+
+// Start boots the guest at once.
+func (d *MockDriver) Start(ctx context.Context) (chan error, error) {
+	if d.StartErr != nil {
+		return nil, d.StartErr
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.config == nil {
+		return nil, ErrNotCreated
+	}
+	if d.running {
+		return nil, ErrAlreadyRunning
+	}
+
+	d.guestIn, d.hostIn = io.Pipe()
+	d.hostOut, d.guestOut = io.Pipe()
+	d.errCh = make(chan error, 1)
+	d.running = true
+	d.suspended = false
+	d.boots++
+	if d.boots == 1 {
+		close(d.started)
+	}
+	return d.errCh, nil
+}
+
+// Stop asks the guest to shut down, which it does at once unless
+// IgnoreStop is set.
+func (d *MockDriver) Stop(ctx context.Context) error {
+	if d.StopErr != nil {
+		return d.StopErr
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.running {
+		return ErrNotRunning
+	}
+	if !d.IgnoreStop {
+		d.exit(nil)
+	}
+	return nil
+}
+
+// Kill ends the guest at once.
+func (d *MockDriver) Kill(ctx context.Context) error {
+	if d.KillErr != nil {
+		return d.KillErr
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.running {
+		return ErrNotRunning
+	}
+	d.exit(nil)
+	return nil
+}
+
+// Suspend pauses the guest if Caps.Suspend is set.
+func (d *MockDriver) Suspend(ctx context.Context) error {
+	if !d.Caps.Suspend {
+		return ErrNotSupported
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.running {
+		return ErrNotRunning
+	}
+	d.suspended = true
+	return nil
+}
+
+// Resume continues a paused guest.
+func (d *MockDriver) Resume(ctx context.Context) error {
+	if !d.Caps.Suspend {
+		return ErrNotSupported
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.suspended {
+		return ErrNotSuspended
+	}
+	d.suspended = false
+	return nil
+}
+
+// Console returns the host ends of the console of the running guest.
+func (d *MockDriver) Console() (io.Writer, io.Reader, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.hostIn == nil {
+		return nil, nil, fmt.Errorf("mockDriver: console not initialized")
+	}
+	return d.hostIn, d.hostOut, nil
+}
+
+// CloseConsole closes the host ends of the console.
+func (d *MockDriver) CloseConsole() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.hostIn != nil {
+		d.hostIn.Close()
+		d.hostOut.Close()
+	}
+	return nil
+}
+
+// Guest returns the guest ends of the console of the current boot: what
+// the host types is read from in, and what is written to out appears on
+// the host's console. Both are nil before the first Start.
+func (d *MockDriver) Guest() (in io.Reader, out io.Writer) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.guestIn == nil {
+		return nil, nil
+	}
+	return d.guestIn, d.guestOut
+}
+
+// Started returns a channel that is closed when the guest first boots.
+func (d *MockDriver) Started() <-chan struct{} {
+	return d.started
+}
+
+// Config returns the configuration of the last Create, or nil.
+func (d *MockDriver) Config() *VMConfig {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.config
+}
+
+// Boots returns how many times the guest has been started.
+func (d *MockDriver) Boots() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.boots
+}
+
+// Running reports whether the guest is running.
+func (d *MockDriver) Running() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.running
+}
+
+// Exit powers the guest off on its own, as a guest shutdown or crash
+// would, ending the boot with err. It does nothing if the guest is not
+// running.
+func (d *MockDriver) Exit(err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.running {
+		d.exit(err)
+	}
+}
+
+// exit ends the running guest with err: its console reaches EOF on the
+// host and err is sent on the channel returned by Start. Callers hold mu.
+func (d *MockDriver) exit(err error) {
+	d.guestOut.Close()
+	d.guestIn.Close()
+	d.running = false
+	d.suspended = false
+	d.errCh <- err
+}
@@ -0,0 +1,114 @@
+package hypervisor
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestMockDriverLifecycle(t *testing.T) {
+	ctx := context.Background()
+	d := NewMockDriver()
+	cfg := &VMConfig{CPUs: 1, MemoryMB: 512, Kernel: "vmlinuz"}
+
+	if _, err := d.Start(ctx); !errors.Is(err, ErrNotCreated) {
+		t.Fatalf("Start before Create = %v, want ErrNotCreated", err)
+	}
+	if err := d.Validate(ctx, cfg); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	if err := d.Create(ctx, cfg); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	errCh, err := d.Start(ctx)
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	select {
+	case <-d.Started():
+	default:
+		t.Error("Started not closed after Start")
+	}
+
+	// The console connects the host to the guest in both directions
+	vmIn, vmOut, err := d.Console()
+	if err != nil {
+		t.Fatalf("Console: %v", err)
+	}
+	guestIn, guestOut := d.Guest()
+	go vmIn.Write([]byte("ls\n"))
+	buf := make([]byte, 3)
+	if _, err := io.ReadFull(guestIn, buf); err != nil || string(buf) != "ls\n" {
+		t.Errorf("guest read %q, %v", buf, err)
+	}
+	go guestOut.Write([]byte("ok"))
+	buf = make([]byte, 2)
+	if _, err := io.ReadFull(vmOut, buf); err != nil || string(buf) != "ok" {
+		t.Errorf("host read %q, %v", buf, err)
+	}
+
+	if err := d.Stop(ctx); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	if err := <-errCh; err != nil {
+		t.Errorf("exit error = %v, want nil", err)
+	}
+	if _, err := vmOut.Read(buf); err != io.EOF {
+		t.Errorf("console read after stop = %v, want EOF", err)
+	}
+	if d.Running() {
+		t.Error("guest still running after Stop")
+	}
+	if err := d.Stop(ctx); !errors.Is(err, ErrNotRunning) {
+		t.Errorf("second Stop = %v, want ErrNotRunning", err)
+	}
+}
+
+func TestMockDriverFailureInjection(t *testing.T) {
+	ctx := context.Background()
+	d := NewMockDriver()
+	d.StartErr = errors.New("no memory")
+	d.IgnoreStop = true
+
+	if err := d.Create(ctx, &VMConfig{CPUs: 1, MemoryMB: 512, Kernel: "vmlinuz"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Start(ctx); err != d.StartErr {
+		t.Fatalf("Start = %v, want injected error", err)
+	}
+
+	d.StartErr = nil
+	errCh, err := d.Start(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Stop(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if !d.Running() {
+		t.Fatal("guest with IgnoreStop shut down on Stop")
+	}
+	crash := errors.New("guest crashed")
+	d.Exit(crash)
+	if err := <-errCh; err != crash {
+		t.Errorf("exit error = %v, want %v", err, crash)
+	}
+	if err := d.Suspend(ctx); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("Suspend = %v, want ErrNotSupported", err)
+	}
+}
+
+func TestSetDriverFactory(t *testing.T) {
+	mock := NewMockDriver()
+	SetDriverFactory(func() (Driver, error) { return mock, nil })
+	defer SetDriverFactory(nil)
+
+	d, err := NewDriver()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d != Driver(mock) {
+		t.Errorf("NewDriver() = %v, want the factory's driver", d)
+	}
+}
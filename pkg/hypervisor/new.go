@@ -1,6 +1,9 @@
 package hypervisor
 
-import "runtime"
+import (
+	"runtime"
+	"sync"
+)
 
 // SupportedPlatform returns true if the current platform has a hypervisor driver.
 func SupportedPlatform() bool {
@@ -12,6 +15,30 @@ func SupportedPlatform() bool {
 	}
 }
 
-// NewDriver creates a new hypervisor driver for the current platform.
-// This function is implemented in platform-specific files using build tags.
-// See driver_darwin.go and driver_linux.go.
+var (
+	factoryMu     sync.Mutex
+	driverFactory func() (Driver, error)
+)
+
+// NewDriver creates a new hypervisor driver for the current platform, or
+// one from the factory set with SetDriverFactory.
+// The platform drivers are implemented in platform-specific files using
+// build tags. See driver_darwin.go and driver_linux.go.
+func NewDriver() (Driver, error) {
+	factoryMu.Lock()
+	factory := driverFactory
+	factoryMu.Unlock()
+	if factory != nil {
+		return factory()
+	}
+	return newPlatformDriver()
+}
+
+// SetDriverFactory makes NewDriver return drivers from factory instead of
+// the platform driver, such as a MockDriver to run commands in tests
+// without a hypervisor. A nil factory restores the platform driver.
+func SetDriverFactory(factory func() (Driver, error)) {
+	factoryMu.Lock()
+	defer factoryMu.Unlock()
+	driverFactory = factory
+}
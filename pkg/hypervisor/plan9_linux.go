@@ -0,0 +1,134 @@
+//go:build linux
+
+package hypervisor
+
+import (
+	"encoding/binary"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"github.com/c35s/hype/virtio"
+	"github.com/c35s/hype/virtio/virtq"
+	"github.com/javanstorm/vmterminal/pkg/p9"
+)
+
+// p9FMountTag is VIRTIO_9P_MOUNT_TAG: the device config holds the tag the
+// guest mounts it by.
+const p9FMountTag = 1 << 0
+
+// Plan9ShareDevice configures a virtio-9p device that shares a host
+// directory with the guest, which mounts it with
+// "mount -t 9p -o trans=virtio,version=9p2000.L <tag> <dir>". It stands in
+// for virtio-fs, which hype lacks.
+type Plan9ShareDevice struct {
+	Tag      string
+	Path     string
+	ReadOnly bool
+}
+
+type plan9Handler struct {
+	tag    string
+	server *p9.Server
+	wg     sync.WaitGroup
+}
+
+// NewHandler returns the device's handler, serving Path over 9P2000.L.
+func (cfg Plan9ShareDevice) NewHandler() (virtio.DeviceHandler, error) {
+	if cfg.Tag == "" || len(cfg.Tag) > 0xffff {
+		return nil, fmt.Errorf("9p share %s: invalid mount tag %q", cfg.Path, cfg.Tag)
+	}
+	server, err := p9.NewServer(cfg.Path, cfg.ReadOnly)
+	if err != nil {
+		return nil, fmt.Errorf("9p share %s: %w", cfg.Path, err)
+	}
+	return &plan9Handler{tag: cfg.Tag, server: server}, nil
+}
+
+func (h *plan9Handler) GetType() virtio.DeviceID {
+	return virtio.P9DeviceID
+}
+
+func (h *plan9Handler) GetFeatures() uint64 {
+	return p9FMountTag
+}
+
+func (h *plan9Handler) Ready(negotiatedFeatures uint64) error {
+	return nil
+}
+
+func (h *plan9Handler) QueueReady(num int, q *virtq.Queue, notify <-chan struct{}) error {
+	if num == 0 {
+		h.wg.Add(1)
+		go func() {
+			defer h.wg.Done()
+			for range notify {
+				if err := h.handle(q); err != nil {
+					slog.Error("9p handler", "tag", h.tag, "error", err)
+				}
+			}
+		}()
+	}
+	return nil
+}
+
+// ReadConfig reads struct virtio_9p_config: the tag length and the tag.
+func (h *plan9Handler) ReadConfig(p []byte, off int) error {
+	raw := binary.LittleEndian.AppendUint16(nil, uint16(len(h.tag)))
+	raw = append(raw, h.tag...)
+	if off < len(raw) {
+		copy(p, raw[off:])
+	}
+	return nil
+}
+
+func (h *plan9Handler) Close() error {
+	h.wg.Wait()
+	return h.server.Close()
+}
+
+// handle answers every available request. A chain holds the T-message in
+// its device-readable buffers, followed by writable buffers for the reply.
+func (h *plan9Handler) handle(q *virtq.Queue) error {
+	for {
+		c, err := q.Next()
+		if err != nil {
+			return err
+		}
+		if c == nil {
+			return nil
+		}
+
+		var req []byte
+		for i, d := range c.Desc {
+			if d.IsWO() {
+				continue
+			}
+			buf, err := c.Buf(i)
+			if err != nil {
+				return err
+			}
+			req = append(req, buf...)
+		}
+
+		resp := h.server.Handle(req)
+		n := 0
+		for i, d := range c.Desc {
+			if !d.IsWO() || n == len(resp) {
+				continue
+			}
+			buf, err := c.Buf(i)
+			if err != nil {
+				return err
+			}
+			n += copy(buf, resp[n:])
+		}
+		if n < len(resp) {
+			slog.Error("9p reply truncated", "tag", h.tag, "size", len(resp), "space", n)
+		}
+
+		if err := c.Release(n); err != nil {
+			return err
+		}
+	}
+}
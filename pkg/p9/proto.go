@@ -0,0 +1,165 @@
+// Package p9 implements a minimal 9P2000.L file server, enough for a Linux
+// guest to mount a host directory with the kernel's 9p filesystem over
+// virtio (mount -t 9p -o trans=virtio,version=9p2000.L).
+//
+// The server handles one message at a time through Server.Handle, leaving
+// the transport to the caller. It is used on hosts whose hypervisor has no
+// virtio-fs device.
+package p9
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// Version is the protocol version the server speaks.
+const Version = "9P2000.L"
+
+// Message types. Each R-message is its T-message plus one.
+const (
+	msgRlerror      = 7
+	msgTstatfs      = 8
+	msgTlopen       = 12
+	msgTlcreate     = 14
+	msgTsymlink     = 16
+	msgTmknod       = 18
+	msgTrename      = 20
+	msgTreadlink    = 22
+	msgTgetattr     = 24
+	msgTsetattr     = 26
+	msgTxattrwalk   = 30
+	msgTxattrcreate = 32
+	msgTreaddir     = 40
+	msgTfsync       = 50
+	msgTlock        = 52
+	msgTgetlock     = 54
+	msgTlink        = 70
+	msgTmkdir       = 72
+	msgTrenameat    = 74
+	msgTunlinkat    = 76
+	msgTversion     = 100
+	msgTauth        = 102
+	msgTattach      = 104
+	msgTflush       = 108
+	msgTwalk        = 110
+	msgTread        = 116
+	msgTwrite       = 118
+	msgTclunk       = 120
+	msgTremove      = 122
+)
+
+// Qid types.
+const (
+	qidTypeDir     = 0x80
+	qidTypeSymlink = 0x02
+	qidTypeFile    = 0x00
+)
+
+// headerSize is size[4] type[1] tag[2].
+const headerSize = 7
+
+// ioHeaderSize is the overhead of an Rread or Twrite beyond its data:
+// the header, a fid or count, and an offset.
+const ioHeaderSize = 24
+
+// errShort is returned when a message ends before one of its fields.
+var errShort = errors.New("p9: message too short")
+
+// qid is a server's unique identification of a file.
+type qid struct {
+	Type    uint8
+	Version uint32
+	Path    uint64
+}
+
+// decoder reads the fields of a message in order. The first read past the
+// end sets err and every later read returns zero values.
+type decoder struct {
+	buf []byte
+	err error
+}
+
+func (d *decoder) take(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if len(d.buf) < n {
+		d.err = errShort
+		return nil
+	}
+	b := d.buf[:n]
+	d.buf = d.buf[n:]
+	return b
+}
+
+func (d *decoder) u8() uint8 {
+	if b := d.take(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (d *decoder) u16() uint16 {
+	if b := d.take(2); b != nil {
+		return binary.LittleEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (d *decoder) u32() uint32 {
+	if b := d.take(4); b != nil {
+		return binary.LittleEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (d *decoder) u64() uint64 {
+	if b := d.take(8); b != nil {
+		return binary.LittleEndian.Uint64(b)
+	}
+	return 0
+}
+
+func (d *decoder) str() string {
+	n := d.u16()
+	return string(d.take(int(n)))
+}
+
+// encoder appends the fields of a message to buf.
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) u8(v uint8) { e.buf = append(e.buf, v) }
+
+func (e *encoder) u16(v uint16) { e.buf = binary.LittleEndian.AppendUint16(e.buf, v) }
+
+func (e *encoder) u32(v uint32) { e.buf = binary.LittleEndian.AppendUint32(e.buf, v) }
+
+func (e *encoder) u64(v uint64) { e.buf = binary.LittleEndian.AppendUint64(e.buf, v) }
+
+func (e *encoder) str(s string) {
+	e.u16(uint16(len(s)))
+	e.buf = append(e.buf, s...)
+}
+
+func (e *encoder) qid(q qid) {
+	e.u8(q.Type)
+	e.u32(q.Version)
+	e.u64(q.Path)
+}
+
+// newMessage starts a message of type typ with the given tag, leaving the
+// size to finish.
+func newMessage(typ uint8, tag uint16) *encoder {
+	e := &encoder{buf: make([]byte, 4, 64)}
+	e.u8(typ)
+	e.u16(tag)
+	return e
+}
+
+// finish fills in the message size and returns the message.
+func (e *encoder) finish() []byte {
+	binary.LittleEndian.PutUint32(e.buf, uint32(len(e.buf)))
+	return e.buf
+}
@@ -0,0 +1,1199 @@
+//go:build linux
+
+package p9
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/sys/unix"
+)
+
+// MaxMessageSize is the largest msize the server agrees to.
+const MaxMessageSize = 512 * 1024
+
+// minMessageSize is the smallest msize the server accepts.
+const minMessageSize = 4096
+
+// v9fsMagic is the filesystem type Rstatfs reports.
+const v9fsMagic = 0x01021997
+
+// Open flags as sent by the Linux client, which uses the x86 values on
+// every architecture.
+const (
+	dotlWronly    = 01
+	dotlRdwr      = 02
+	dotlAccMode   = 03
+	dotlCreat     = 0100
+	dotlExcl      = 0200
+	dotlTrunc     = 01000
+	dotlAppend    = 02000
+	dotlNonblock  = 04000
+	dotlDsync     = 010000
+	dotlDirectory = 0200000
+	dotlSync      = 04000000
+)
+
+// Tsetattr valid bits.
+const (
+	setattrMode     = 0x1
+	setattrUID      = 0x2
+	setattrGID      = 0x4
+	setattrSize     = 0x8
+	setattrAtime    = 0x10
+	setattrMtime    = 0x20
+	setattrAtimeSet = 0x80
+	setattrMtimeSet = 0x100
+)
+
+// getattrBasic is P9_GETATTR_BASIC: every field but btime, gen and
+// data_version.
+const getattrBasic = 0x7ff
+
+// atRemoveDir is the Tunlinkat flag for removing a directory.
+const atRemoveDir = 0x200
+
+// resolveBeneath confines every lookup to the share: paths are resolved
+// from the root descriptor, no ".." may climb above it and no symlink is
+// followed, so neither a symlink the guest created nor a rename racing a
+// request can lead out of the share.
+const resolveBeneath = unix.RESOLVE_BENEATH | unix.RESOLVE_NO_SYMLINKS | unix.RESOLVE_NO_MAGICLINKS
+
+// lockSuccess and lockTypeUnlocked are the Rlock status and Rgetlock type
+// the server always answers with: it keeps no locks.
+const (
+	lockSuccess      = 0
+	lockTypeUnlocked = 2
+)
+
+// Server serves one host directory over 9P2000.L. It is safe for
+// concurrent use.
+type Server struct {
+	rootFD   int
+	readOnly bool
+
+	mu    sync.Mutex
+	msize uint32
+	fids  map[uint32]*fid
+}
+
+// fid is the server's state for a client file handle.
+type fid struct {
+	path    string   // Path relative to the root; "." is the root
+	file    *os.File // Set once opened
+	entries []dirEntry
+}
+
+// dirEntry is one entry of a directory listing, as snapshotted at the
+// start of a Treaddir sequence.
+type dirEntry struct {
+	qid  qid
+	typ  uint8
+	name string
+}
+
+// NewServer returns a server for the directory root. With readOnly, every
+// request that would change the directory fails with EROFS. It needs
+// openat2, which Linux has since 5.6.
+func NewServer(root string, readOnly bool) (*Server, error) {
+	fd, err := unix.Open(root, unix.O_PATH|unix.O_DIRECTORY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: root, Err: err}
+	}
+	probe, err := unix.Openat2(fd, ".", &unix.OpenHow{Flags: unix.O_PATH | unix.O_CLOEXEC, Resolve: resolveBeneath})
+	if err == nil {
+		unix.Close(probe)
+	} else {
+		unix.Close(fd)
+		if errors.Is(err, unix.ENOSYS) {
+			return nil, fmt.Errorf("9p shares need openat2 (Linux 5.6 or later)")
+		}
+		return nil, &os.PathError{Op: "openat2", Path: root, Err: err}
+	}
+	return &Server{
+		rootFD:   fd,
+		readOnly: readOnly,
+		msize:    MaxMessageSize,
+		fids:     make(map[uint32]*fid),
+	}, nil
+}
+
+// Close releases every open file and the root.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.clunkAll()
+	if s.rootFD < 0 {
+		return nil
+	}
+	err := unix.Close(s.rootFD)
+	s.rootFD = -1
+	return err
+}
+
+// Handle processes one T-message and returns the R-message to send back.
+// Malformed messages get an Rlerror.
+func (s *Server) Handle(req []byte) []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	d := &decoder{buf: req}
+	d.u32() // size, already framed by the transport
+	typ := d.u8()
+	tag := d.u16()
+	if d.err != nil {
+		return rlerror(tag, unix.EINVAL)
+	}
+
+	resp, err := s.dispatch(typ, tag, d)
+	if err == nil && d.err != nil {
+		err = unix.EINVAL
+	}
+	if err != nil {
+		return rlerror(tag, err)
+	}
+	return resp.finish()
+}
+
+// rlerror returns an Rlerror carrying the errno for err.
+func rlerror(tag uint16, err error) []byte {
+	e := newMessage(msgRlerror, tag)
+	e.u32(uint32(errnoOf(err)))
+	return e.finish()
+}
+
+// errnoOf maps err to a Linux errno.
+func errnoOf(err error) unix.Errno {
+	var errno unix.Errno
+	switch {
+	case errors.As(err, &errno):
+		return errno
+	case errors.Is(err, os.ErrNotExist):
+		return unix.ENOENT
+	case errors.Is(err, os.ErrExist):
+		return unix.EEXIST
+	case errors.Is(err, os.ErrPermission):
+		return unix.EACCES
+	}
+	return unix.EIO
+}
+
+func (s *Server) dispatch(typ uint8, tag uint16, d *decoder) (*encoder, error) {
+	r := newMessage(typ+1, tag)
+	switch typ {
+	case msgTversion:
+		return r, s.version(d, r)
+	case msgTattach:
+		return r, s.attach(d, r)
+	case msgTauth, msgTxattrwalk, msgTxattrcreate:
+		return nil, unix.EOPNOTSUPP
+	case msgTflush:
+		d.u16() // Requests are answered in order, so nothing is pending
+		return r, nil
+	case msgTwalk:
+		return r, s.walk(d, r)
+	case msgTclunk:
+		f, err := s.fid(d.u32())
+		if err != nil {
+			return nil, err
+		}
+		s.clunk(f)
+		return r, nil
+	case msgTremove:
+		return r, s.remove(d)
+	case msgTgetattr:
+		return r, s.getattr(d, r)
+	case msgTsetattr:
+		return r, s.setattr(d)
+	case msgTstatfs:
+		return r, s.statfs(d, r)
+	case msgTlopen:
+		return r, s.lopen(d, r)
+	case msgTlcreate:
+		return r, s.lcreate(d, r)
+	case msgTread:
+		return r, s.read(d, r)
+	case msgTwrite:
+		return r, s.write(d, r)
+	case msgTreaddir:
+		return r, s.readdir(d, r)
+	case msgTfsync:
+		return r, s.fsync(d)
+	case msgTmkdir:
+		return r, s.mkdir(d, r)
+	case msgTsymlink:
+		return r, s.symlink(d, r)
+	case msgTmknod:
+		return r, s.mknod(d, r)
+	case msgTreadlink:
+		return r, s.readlink(d, r)
+	case msgTlink:
+		return r, s.link(d)
+	case msgTrename:
+		return r, s.rename(d)
+	case msgTrenameat:
+		return r, s.renameatMsg(d)
+	case msgTunlinkat:
+		return r, s.unlinkat(d)
+	case msgTlock:
+		d.u32() // fid
+		r.u8(lockSuccess)
+		return r, nil
+	case msgTgetlock:
+		d.u32() // fid
+		d.u8()  // type
+		start, length, procID, clientID := d.u64(), d.u64(), d.u32(), d.str()
+		r.u8(lockTypeUnlocked)
+		r.u64(start)
+		r.u64(length)
+		r.u32(procID)
+		r.str(clientID)
+		return r, nil
+	}
+	return nil, unix.EOPNOTSUPP
+}
+
+// fid returns the fid numbered n.
+func (s *Server) fid(n uint32) (*fid, error) {
+	f, ok := s.fids[n]
+	if !ok {
+		return nil, unix.EBADF
+	}
+	return f, nil
+}
+
+// clunk forgets f, closing its file.
+func (s *Server) clunk(f *fid) {
+	if f.file != nil {
+		f.file.Close()
+	}
+	for n, other := range s.fids {
+		if other == f {
+			delete(s.fids, n)
+		}
+	}
+}
+
+func (s *Server) clunkAll() {
+	for n, f := range s.fids {
+		if f.file != nil {
+			f.file.Close()
+		}
+		delete(s.fids, n)
+	}
+}
+
+// writable returns EROFS for a read-only server.
+func (s *Server) writable() error {
+	if s.readOnly {
+		return unix.EROFS
+	}
+	return nil
+}
+
+// child returns the path of name in the directory dir. name must be a
+// single path element.
+func child(dir, name string) (string, error) {
+	switch {
+	case name == "" || strings.Contains(name, "/"):
+		return "", unix.EINVAL
+	case name == ".":
+		return dir, nil
+	case name == "..":
+		return filepath.Dir(dir), nil // The root's parent is the root
+	}
+	return filepath.Join(dir, name), nil
+}
+
+// newChild returns the path for creating name in dir.
+func newChild(dir, name string) (string, error) {
+	if name == "." || name == ".." {
+		return "", unix.EEXIST
+	}
+	return child(dir, name)
+}
+
+// open opens path, relative to the root, without leaving the root or
+// following any symlink. mode only counts with O_CREAT.
+func (s *Server) open(path string, flags int, mode uint32) (int, error) {
+	how := &unix.OpenHow{Flags: uint64(flags | unix.O_CLOEXEC), Resolve: resolveBeneath}
+	if flags&unix.O_CREAT != 0 {
+		how.Mode = uint64(mode)
+	}
+	for {
+		fd, err := unix.Openat2(s.rootFD, path, how)
+		// The kernel asks for a retry when a rename races a ".." lookup
+		if err != unix.EAGAIN && err != unix.EINTR {
+			return fd, err
+		}
+	}
+}
+
+// openFile opens path like open and wraps it in an *os.File.
+func (s *Server) openFile(path string, flags int, mode uint32) (*os.File, error) {
+	fd, err := s.open(path, flags, mode)
+	if err != nil {
+		return nil, err
+	}
+	return os.NewFile(uintptr(fd), path), nil
+}
+
+// at opens the directory that contains path and returns it with the final
+// element of path, for the *at system calls. The caller closes dirfd. The
+// root has no parent inside the share, so at refuses it with EBUSY.
+func (s *Server) at(path string) (dirfd int, name string, err error) {
+	if path == "." {
+		return -1, "", unix.EBUSY
+	}
+	dirfd, err = s.open(filepath.Dir(path), unix.O_PATH|unix.O_DIRECTORY, 0)
+	if err != nil {
+		return -1, "", err
+	}
+	return dirfd, filepath.Base(path), nil
+}
+
+// lstat returns the qid and attributes of path, without following a
+// final symlink.
+func (s *Server) lstat(path string) (qid, *unix.Stat_t, error) {
+	fd, err := s.open(path, unix.O_PATH|unix.O_NOFOLLOW, 0)
+	if err != nil {
+		return qid{}, nil, err
+	}
+	defer unix.Close(fd)
+	var st unix.Stat_t
+	if err := unix.Fstat(fd, &st); err != nil {
+		return qid{}, nil, err
+	}
+	return qidOf(&st), &st, nil
+}
+
+// procPath returns the /proc path that reopens fd. Calls given it act on
+// the file fd refers to, wherever that is now.
+func procPath(fd int) string {
+	return fmt.Sprintf("/proc/self/fd/%d", fd)
+}
+
+func qidOf(st *unix.Stat_t) qid {
+	q := qid{Type: qidTypeFile, Path: st.Ino}
+	switch st.Mode & unix.S_IFMT {
+	case unix.S_IFDIR:
+		q.Type = qidTypeDir
+	case unix.S_IFLNK:
+		q.Type = qidTypeSymlink
+	}
+	return q
+}
+
+func (s *Server) version(d *decoder, r *encoder) error {
+	msize, version := d.u32(), d.str()
+	if msize < minMessageSize {
+		return unix.EINVAL
+	}
+
+	// A new session starts
+	s.clunkAll()
+	s.msize = min(msize, MaxMessageSize)
+	r.u32(s.msize)
+	if strings.HasPrefix(version, Version) {
+		r.str(Version)
+	} else {
+		r.str("unknown")
+	}
+	return nil
+}
+
+func (s *Server) attach(d *decoder, r *encoder) error {
+	n := d.u32()
+	d.u32() // afid
+	d.str() // uname
+	d.str() // aname
+	d.u32() // n_uname
+	if d.err != nil {
+		return d.err
+	}
+	if _, ok := s.fids[n]; ok {
+		return unix.EBADF
+	}
+	q, _, err := s.lstat(".")
+	if err != nil {
+		return err
+	}
+	s.fids[n] = &fid{path: "."}
+	r.qid(q)
+	return nil
+}
+
+// walk follows the names from a fid. Symlinks are not followed: the
+// client resolves them with Treadlink.
+func (s *Server) walk(d *decoder, r *encoder) error {
+	n := d.u32()
+	f, err := s.fid(n)
+	if err != nil {
+		return err
+	}
+	newN := d.u32()
+	names := make([]string, d.u16())
+	for i := range names {
+		names[i] = d.str()
+	}
+	if d.err != nil {
+		return d.err
+	}
+	if existing, ok := s.fids[newN]; ok && existing != f {
+		return unix.EBADF
+	}
+
+	path := f.path
+	var qids []qid
+	for i, name := range names {
+		if i > 0 && qids[i-1].Type != qidTypeDir {
+			break
+		}
+		next, err := child(path, name)
+		if err == nil {
+			var q qid
+			if q, _, err = s.lstat(next); err == nil {
+				qids = append(qids, q)
+				path = next
+				continue
+			}
+		}
+		if i == 0 {
+			return err
+		}
+		break
+	}
+
+	if len(qids) == len(names) {
+		if newN == n {
+			f.path = path
+		} else {
+			s.fids[newN] = &fid{path: path}
+		}
+	}
+	r.u16(uint16(len(qids)))
+	for _, q := range qids {
+		r.qid(q)
+	}
+	return nil
+}
+
+func (s *Server) remove(d *decoder) error {
+	f, err := s.fid(d.u32())
+	if err != nil {
+		return err
+	}
+	defer s.clunk(f)
+	if err := s.writable(); err != nil {
+		return err
+	}
+	dirfd, name, err := s.at(f.path)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(dirfd)
+	var st unix.Stat_t
+	if err := unix.Fstatat(dirfd, name, &st, unix.AT_SYMLINK_NOFOLLOW); err != nil {
+		return err
+	}
+	flags := 0
+	if st.Mode&unix.S_IFMT == unix.S_IFDIR {
+		flags = unix.AT_REMOVEDIR
+	}
+	return unix.Unlinkat(dirfd, name, flags)
+}
+
+func (s *Server) getattr(d *decoder, r *encoder) error {
+	f, err := s.fid(d.u32())
+	if err != nil {
+		return err
+	}
+	d.u64() // request_mask; every basic field is always returned
+
+	st := new(unix.Stat_t)
+	if f.file != nil {
+		err = unix.Fstat(int(f.file.Fd()), st)
+	} else {
+		_, st, err = s.lstat(f.path)
+	}
+	if err != nil {
+		return err
+	}
+
+	r.u64(getattrBasic)
+	r.qid(qidOf(st))
+	r.u32(st.Mode)
+	r.u32(st.Uid)
+	r.u32(st.Gid)
+	r.u64(uint64(st.Nlink))
+	r.u64(uint64(st.Rdev))
+	r.u64(uint64(st.Size))
+	r.u64(uint64(st.Blksize))
+	r.u64(uint64(st.Blocks))
+	r.u64(uint64(st.Atim.Sec))
+	r.u64(uint64(st.Atim.Nsec))
+	r.u64(uint64(st.Mtim.Sec))
+	r.u64(uint64(st.Mtim.Nsec))
+	r.u64(uint64(st.Ctim.Sec))
+	r.u64(uint64(st.Ctim.Nsec))
+	r.u64(0) // btime
+	r.u64(0)
+	r.u64(0) // gen
+	r.u64(0) // data_version
+	return nil
+}
+
+func (s *Server) setattr(d *decoder) error {
+	f, err := s.fid(d.u32())
+	if err != nil {
+		return err
+	}
+	valid, mode, uid, gid := d.u32(), d.u32(), d.u32(), d.u32()
+	size := d.u64()
+	atime := unix.Timespec{Sec: int64(d.u64()), Nsec: int64(d.u64())}
+	mtime := unix.Timespec{Sec: int64(d.u64()), Nsec: int64(d.u64())}
+	if d.err != nil {
+		return d.err
+	}
+	if err := s.writable(); err != nil {
+		return err
+	}
+
+	// Everything is changed through a descriptor for the file itself, so
+	// a symlink swapped in for it, or for a directory above it, is never
+	// followed out of the share
+	fd, err := s.open(f.path, unix.O_PATH|unix.O_NOFOLLOW, 0)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(fd)
+	var st unix.Stat_t
+	if err := unix.Fstat(fd, &st); err != nil {
+		return err
+	}
+	isLink := st.Mode&unix.S_IFMT == unix.S_IFLNK
+
+	if valid&setattrMode != 0 {
+		// Linux has no modes on symlinks
+		if isLink {
+			return unix.EOPNOTSUPP
+		}
+		if err := unix.Fchmodat(unix.AT_FDCWD, procPath(fd), mode&07777, 0); err != nil {
+			return err
+		}
+	}
+	if valid&(setattrUID|setattrGID) != 0 {
+		u, g := -1, -1
+		if valid&setattrUID != 0 {
+			u = int(uid)
+		}
+		if valid&setattrGID != 0 {
+			g = int(gid)
+		}
+		// Files belong to the host user; the guest's owners are not
+		// kept unless the server may change them
+		err := unix.Fchownat(fd, "", u, g, unix.AT_EMPTY_PATH)
+		if err != nil && !errors.Is(err, os.ErrPermission) {
+			return err
+		}
+	}
+	if valid&setattrSize != 0 {
+		if err := s.truncate(f, int64(size)); err != nil {
+			return err
+		}
+	}
+	if valid&(setattrAtime|setattrMtime) != 0 {
+		times := []unix.Timespec{{Nsec: unix.UTIME_OMIT}, {Nsec: unix.UTIME_OMIT}}
+		for i, set := range []struct {
+			change, explicit uint32
+			ts               unix.Timespec
+		}{
+			{setattrAtime, setattrAtimeSet, atime},
+			{setattrMtime, setattrMtimeSet, mtime},
+		} {
+			switch {
+			case valid&set.change == 0:
+			case valid&set.explicit != 0:
+				times[i] = set.ts
+			default:
+				times[i] = unix.Timespec{Nsec: unix.UTIME_NOW}
+			}
+		}
+		if isLink {
+			// A symlink cannot be reopened through /proc without
+			// following it; its directory can
+			dirfd, name, err := s.at(f.path)
+			if err != nil {
+				return err
+			}
+			defer unix.Close(dirfd)
+			return unix.UtimesNanoAt(dirfd, name, times, unix.AT_SYMLINK_NOFOLLOW)
+		}
+		if err := unix.UtimesNanoAt(unix.AT_FDCWD, procPath(fd), times, 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// truncate sets the size of the regular file f refers to.
+func (s *Server) truncate(f *fid, size int64) error {
+	if f.file != nil {
+		return f.file.Truncate(size)
+	}
+	// O_NONBLOCK keeps a FIFO from blocking the open
+	file, err := s.openFile(f.path, unix.O_WRONLY|unix.O_NOFOLLOW|unix.O_NONBLOCK, 0)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	var st unix.Stat_t
+	if err := unix.Fstat(int(file.Fd()), &st); err != nil {
+		return err
+	}
+	if st.Mode&unix.S_IFMT != unix.S_IFREG {
+		return unix.EINVAL
+	}
+	return file.Truncate(size)
+}
+
+func (s *Server) statfs(d *decoder, r *encoder) error {
+	f, err := s.fid(d.u32())
+	if err != nil {
+		return err
+	}
+	fd, err := s.open(f.path, unix.O_PATH, 0)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(fd)
+	var st unix.Statfs_t
+	if err := unix.Fstatfs(fd, &st); err != nil {
+		return err
+	}
+	r.u32(v9fsMagic)
+	r.u32(uint32(st.Bsize))
+	r.u64(st.Blocks)
+	r.u64(st.Bfree)
+	r.u64(st.Bavail)
+	r.u64(st.Files)
+	r.u64(st.Ffree)
+	r.u64(uint64(uint32(st.Fsid.Val[0])) | uint64(uint32(st.Fsid.Val[1]))<<32)
+	r.u32(uint32(st.Namelen))
+	return nil
+}
+
+// openFlags translates the client's open flags to the host's, always
+// refusing to follow a final symlink.
+func openFlags(flags uint32) int {
+	host := unix.O_NOFOLLOW | unix.O_CLOEXEC
+	switch flags & dotlAccMode {
+	case dotlWronly:
+		host |= unix.O_WRONLY
+	case dotlRdwr:
+		host |= unix.O_RDWR
+	}
+	for _, f := range []struct {
+		dotl uint32
+		host int
+	}{
+		{dotlCreat, unix.O_CREAT},
+		{dotlExcl, unix.O_EXCL},
+		{dotlTrunc, unix.O_TRUNC},
+		{dotlAppend, unix.O_APPEND},
+		{dotlNonblock, unix.O_NONBLOCK},
+		{dotlDsync, unix.O_DSYNC},
+		{dotlDirectory, unix.O_DIRECTORY},
+		{dotlSync, unix.O_SYNC},
+	} {
+		if flags&f.dotl != 0 {
+			host |= f.host
+		}
+	}
+	return host
+}
+
+// writes reports whether the client's open flags can change the file.
+func writes(flags uint32) bool {
+	return flags&dotlAccMode != 0 || flags&(dotlTrunc|dotlCreat) != 0
+}
+
+// iounit is the most data one Tread or Twrite can carry.
+func (s *Server) iounit() uint32 {
+	return s.msize - ioHeaderSize
+}
+
+func (s *Server) lopen(d *decoder, r *encoder) error {
+	f, err := s.fid(d.u32())
+	if err != nil {
+		return err
+	}
+	flags := d.u32()
+	if d.err != nil {
+		return d.err
+	}
+	if f.file != nil {
+		return unix.EBADF
+	}
+	if writes(flags) {
+		if err := s.writable(); err != nil {
+			return err
+		}
+	}
+
+	file, err := s.openFile(f.path, openFlags(flags&^(dotlCreat|dotlExcl)), 0)
+	if err != nil {
+		return err
+	}
+	var st unix.Stat_t
+	if err := unix.Fstat(int(file.Fd()), &st); err != nil {
+		file.Close()
+		return err
+	}
+	f.file = file
+	f.entries = nil
+	r.qid(qidOf(&st))
+	r.u32(s.iounit())
+	return nil
+}
+
+func (s *Server) lcreate(d *decoder, r *encoder) error {
+	f, err := s.fid(d.u32())
+	if err != nil {
+		return err
+	}
+	name, flags, mode := d.str(), d.u32(), d.u32()
+	d.u32() // gid
+	if d.err != nil {
+		return d.err
+	}
+	if err := s.writable(); err != nil {
+		return err
+	}
+	if f.file != nil {
+		return unix.EBADF
+	}
+	path, err := newChild(f.path, name)
+	if err != nil {
+		return err
+	}
+
+	file, err := s.openFile(path, openFlags(flags)|unix.O_CREAT, mode&0777)
+	if err != nil {
+		return err
+	}
+	var st unix.Stat_t
+	if err := unix.Fstat(int(file.Fd()), &st); err != nil {
+		file.Close()
+		return err
+	}
+
+	// The fid now refers to the new, open file
+	f.path = path
+	f.file = file
+	r.qid(qidOf(&st))
+	r.u32(s.iounit())
+	return nil
+}
+
+func (s *Server) read(d *decoder, r *encoder) error {
+	f, err := s.fid(d.u32())
+	if err != nil {
+		return err
+	}
+	offset, count := d.u64(), d.u32()
+	if d.err != nil {
+		return d.err
+	}
+	if f.file == nil {
+		return unix.EBADF
+	}
+
+	buf := make([]byte, min(count, s.iounit()))
+	n, err := unix.Pread(int(f.file.Fd()), buf, int64(offset))
+	if err != nil {
+		return err
+	}
+	r.u32(uint32(n))
+	r.buf = append(r.buf, buf[:n]...)
+	return nil
+}
+
+func (s *Server) write(d *decoder, r *encoder) error {
+	f, err := s.fid(d.u32())
+	if err != nil {
+		return err
+	}
+	offset, count := d.u64(), d.u32()
+	data := d.take(int(count))
+	if d.err != nil {
+		return d.err
+	}
+	if err := s.writable(); err != nil {
+		return err
+	}
+	if f.file == nil {
+		return unix.EBADF
+	}
+
+	// pwrite appends on files opened with O_APPEND, as the client expects
+	n, err := unix.Pwrite(int(f.file.Fd()), data, int64(offset))
+	if err != nil {
+		return err
+	}
+	r.u32(uint32(n))
+	return nil
+}
+
+// readdir lists the directory from the given offset, which is the index
+// of the entry to start from. The listing is taken when a read starts at
+// offset 0 and reused for later offsets, so entries keep their positions.
+func (s *Server) readdir(d *decoder, r *encoder) error {
+	f, err := s.fid(d.u32())
+	if err != nil {
+		return err
+	}
+	offset, count := d.u64(), d.u32()
+	if d.err != nil {
+		return d.err
+	}
+	if f.file == nil {
+		return unix.EBADF
+	}
+	if offset == 0 || f.entries == nil {
+		if f.entries, err = s.listDir(f.path); err != nil {
+			return err
+		}
+	}
+
+	data := &encoder{}
+	count = min(count, s.iounit())
+	for i := offset; i < uint64(len(f.entries)); i++ {
+		e := f.entries[i]
+		size := 13 + 8 + 1 + 2 + len(e.name)
+		if len(data.buf)+size > int(count) {
+			break
+		}
+		data.qid(e.qid)
+		data.u64(i + 1)
+		data.u8(e.typ)
+		data.str(e.name)
+	}
+	r.u32(uint32(len(data.buf)))
+	r.buf = append(r.buf, data.buf...)
+	return nil
+}
+
+// listDir returns the entries of the directory at path, including "."
+// and "..". Entries that vanish while it runs are left out.
+func (s *Server) listDir(path string) ([]dirEntry, error) {
+	dir, err := s.openFile(path, unix.O_RDONLY|unix.O_DIRECTORY|unix.O_NOFOLLOW, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer dir.Close()
+	names, err := dir.Readdirnames(-1)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []dirEntry
+	add := func(name string, st *unix.Stat_t) {
+		entries = append(entries, dirEntry{qid: qidOf(st), typ: direntType(st.Mode), name: name})
+	}
+	var st unix.Stat_t
+	if err := unix.Fstat(int(dir.Fd()), &st); err != nil {
+		return nil, err
+	}
+	add(".", &st)
+	if _, parent, err := s.lstat(filepath.Dir(path)); err == nil {
+		add("..", parent)
+	}
+	for _, name := range names {
+		var st unix.Stat_t
+		if err := unix.Fstatat(int(dir.Fd()), name, &st, unix.AT_SYMLINK_NOFOLLOW); err == nil {
+			add(name, &st)
+		}
+	}
+	return entries, nil
+}
+
+// direntType returns the d_type of a file mode.
+func direntType(mode uint32) uint8 {
+	switch mode & unix.S_IFMT {
+	case unix.S_IFDIR:
+		return unix.DT_DIR
+	case unix.S_IFREG:
+		return unix.DT_REG
+	case unix.S_IFLNK:
+		return unix.DT_LNK
+	case unix.S_IFCHR:
+		return unix.DT_CHR
+	case unix.S_IFBLK:
+		return unix.DT_BLK
+	case unix.S_IFIFO:
+		return unix.DT_FIFO
+	case unix.S_IFSOCK:
+		return unix.DT_SOCK
+	}
+	return unix.DT_UNKNOWN
+}
+
+func (s *Server) fsync(d *decoder) error {
+	f, err := s.fid(d.u32())
+	if err != nil {
+		return err
+	}
+	d.u32() // datasync
+	if f.file == nil {
+		return nil
+	}
+	return f.file.Sync()
+}
+
+func (s *Server) mkdir(d *decoder, r *encoder) error {
+	f, err := s.fid(d.u32())
+	if err != nil {
+		return err
+	}
+	name, mode := d.str(), d.u32()
+	d.u32() // gid
+	if d.err != nil {
+		return d.err
+	}
+	if err := s.writable(); err != nil {
+		return err
+	}
+	path, err := newChild(f.path, name)
+	if err != nil {
+		return err
+	}
+	dirfd, err := s.open(f.path, unix.O_PATH|unix.O_DIRECTORY, 0)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(dirfd)
+	if err := unix.Mkdirat(dirfd, name, mode&07777); err != nil {
+		return err
+	}
+	q, _, err := s.lstat(path)
+	if err != nil {
+		return err
+	}
+	r.qid(q)
+	return nil
+}
+
+func (s *Server) symlink(d *decoder, r *encoder) error {
+	f, err := s.fid(d.u32())
+	if err != nil {
+		return err
+	}
+	name, target := d.str(), d.str()
+	d.u32() // gid
+	if d.err != nil {
+		return d.err
+	}
+	if err := s.writable(); err != nil {
+		return err
+	}
+	path, err := newChild(f.path, name)
+	if err != nil {
+		return err
+	}
+	dirfd, err := s.open(f.path, unix.O_PATH|unix.O_DIRECTORY, 0)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(dirfd)
+	if err := unix.Symlinkat(target, dirfd, name); err != nil {
+		return err
+	}
+	q, _, err := s.lstat(path)
+	if err != nil {
+		return err
+	}
+	r.qid(q)
+	return nil
+}
+
+func (s *Server) mknod(d *decoder, r *encoder) error {
+	f, err := s.fid(d.u32())
+	if err != nil {
+		return err
+	}
+	name, mode, major, minor := d.str(), d.u32(), d.u32(), d.u32()
+	d.u32() // gid
+	if d.err != nil {
+		return d.err
+	}
+	if err := s.writable(); err != nil {
+		return err
+	}
+	path, err := newChild(f.path, name)
+	if err != nil {
+		return err
+	}
+	dirfd, err := s.open(f.path, unix.O_PATH|unix.O_DIRECTORY, 0)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(dirfd)
+	if err := unix.Mknodat(dirfd, name, mode, int(unix.Mkdev(major, minor))); err != nil {
+		return err
+	}
+	q, _, err := s.lstat(path)
+	if err != nil {
+		return err
+	}
+	r.qid(q)
+	return nil
+}
+
+func (s *Server) readlink(d *decoder, r *encoder) error {
+	f, err := s.fid(d.u32())
+	if err != nil {
+		return err
+	}
+	dirfd, name, err := s.at(f.path)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(dirfd)
+	buf := make([]byte, unix.PathMax)
+	n, err := unix.Readlinkat(dirfd, name, buf)
+	if err != nil {
+		return err
+	}
+	r.str(string(buf[:n]))
+	return nil
+}
+
+func (s *Server) link(d *decoder) error {
+	dir, err := s.fid(d.u32())
+	if err != nil {
+		return err
+	}
+	f, err := s.fid(d.u32())
+	if err != nil {
+		return err
+	}
+	name := d.str()
+	if d.err != nil {
+		return d.err
+	}
+	if err := s.writable(); err != nil {
+		return err
+	}
+	if _, err := newChild(dir.path, name); err != nil {
+		return err
+	}
+	oldDirfd, oldName, err := s.at(f.path)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(oldDirfd)
+	dirfd, err := s.open(dir.path, unix.O_PATH|unix.O_DIRECTORY, 0)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(dirfd)
+	return unix.Linkat(oldDirfd, oldName, dirfd, name, 0)
+}
+
+func (s *Server) rename(d *decoder) error {
+	f, err := s.fid(d.u32())
+	if err != nil {
+		return err
+	}
+	dir, err := s.fid(d.u32())
+	if err != nil {
+		return err
+	}
+	name := d.str()
+	if d.err != nil {
+		return d.err
+	}
+	if err := s.writable(); err != nil {
+		return err
+	}
+	path, err := newChild(dir.path, name)
+	if err != nil {
+		return err
+	}
+	if err := s.renameat(f.path, path); err != nil {
+		return err
+	}
+	f.path = path
+	return nil
+}
+
+func (s *Server) renameatMsg(d *decoder) error {
+	oldDir, err := s.fid(d.u32())
+	if err != nil {
+		return err
+	}
+	oldName := d.str()
+	newDir, err := s.fid(d.u32())
+	if err != nil {
+		return err
+	}
+	newName := d.str()
+	if d.err != nil {
+		return d.err
+	}
+	if err := s.writable(); err != nil {
+		return err
+	}
+	oldPath, err := newChild(oldDir.path, oldName)
+	if err != nil {
+		return err
+	}
+	newPath, err := newChild(newDir.path, newName)
+	if err != nil {
+		return err
+	}
+	return s.renameat(oldPath, newPath)
+}
+
+// renameat moves oldPath to newPath, both relative to the root.
+func (s *Server) renameat(oldPath, newPath string) error {
+	oldDirfd, oldName, err := s.at(oldPath)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(oldDirfd)
+	newDirfd, newName, err := s.at(newPath)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(newDirfd)
+	return unix.Renameat(oldDirfd, oldName, newDirfd, newName)
+}
+
+func (s *Server) unlinkat(d *decoder) error {
+	dir, err := s.fid(d.u32())
+	if err != nil {
+		return err
+	}
+	name, flags := d.str(), d.u32()
+	if d.err != nil {
+		return d.err
+	}
+	if err := s.writable(); err != nil {
+		return err
+	}
+	if _, err := newChild(dir.path, name); err != nil {
+		return err
+	}
+	dirfd, err := s.open(dir.path, unix.O_PATH|unix.O_DIRECTORY, 0)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(dirfd)
+	return unix.Unlinkat(dirfd, name, int(flags&atRemoveDir))
+}
@@ -0,0 +1,331 @@
+//go:build linux
+
+package p9
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+// testClient sends T-messages to a server and decodes the replies.
+type testClient struct {
+	t *testing.T
+	s *Server
+}
+
+// call sends a message of type typ with the fields written by build and
+// returns a decoder over the reply's fields, or the Rlerror errno.
+func (c *testClient) call(typ uint8, build func(e *encoder)) (*decoder, unix.Errno) {
+	c.t.Helper()
+	e := newMessage(typ, 1)
+	build(e)
+	d := &decoder{buf: c.s.Handle(e.finish())}
+	d.u32()
+	rtyp, tag := d.u8(), d.u16()
+	if tag != 1 {
+		c.t.Fatalf("reply tag = %d, want 1", tag)
+	}
+	if rtyp == msgRlerror {
+		return nil, unix.Errno(d.u32())
+	}
+	if rtyp != typ+1 {
+		c.t.Fatalf("reply type = %d, want %d", rtyp, typ+1)
+	}
+	return d, 0
+}
+
+// mustCall is call for requests that must succeed.
+func (c *testClient) mustCall(typ uint8, build func(e *encoder)) *decoder {
+	c.t.Helper()
+	d, errno := c.call(typ, build)
+	if errno != 0 {
+		c.t.Fatalf("message %d failed: %v", typ, errno)
+	}
+	return d
+}
+
+// walk walks newfid from fid through names and returns the errno.
+func (c *testClient) walk(fid, newfid uint32, names ...string) unix.Errno {
+	c.t.Helper()
+	d, errno := c.call(msgTwalk, func(e *encoder) {
+		e.u32(fid)
+		e.u32(newfid)
+		e.u16(uint16(len(names)))
+		for _, n := range names {
+			e.str(n)
+		}
+	})
+	if errno == 0 && int(d.u16()) != len(names) {
+		return unix.ENOENT
+	}
+	return errno
+}
+
+// newTestClient attaches fid 0 to a server for root.
+func newTestClient(t *testing.T, root string, readOnly bool) *testClient {
+	s, err := NewServer(root, readOnly)
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	c := &testClient{t: t, s: s}
+
+	d := c.mustCall(msgTversion, func(e *encoder) {
+		e.u32(1 << 20)
+		e.str("9P2000.L")
+	})
+	if msize, version := d.u32(), d.str(); msize != MaxMessageSize || version != Version {
+		t.Fatalf("Rversion = %d %q, want %d %q", msize, version, MaxMessageSize, Version)
+	}
+	c.mustCall(msgTattach, func(e *encoder) {
+		e.u32(0)
+		e.u32(^uint32(0))
+		e.str("root")
+		e.str("")
+		e.u32(0)
+	})
+	return c
+}
+
+func TestServerReadFile(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "sub", "hello.txt"), []byte("hello, guest"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c := newTestClient(t, root, true)
+
+	if errno := c.walk(0, 1, "sub", "hello.txt"); errno != 0 {
+		t.Fatalf("walk: %v", errno)
+	}
+	c.mustCall(msgTlopen, func(e *encoder) {
+		e.u32(1)
+		e.u32(0)
+	})
+	d := c.mustCall(msgTread, func(e *encoder) {
+		e.u32(1)
+		e.u64(7)
+		e.u32(100)
+	})
+	n := d.u32()
+	if got := string(d.take(int(n))); got != "guest" {
+		t.Errorf("read = %q, want %q", got, "guest")
+	}
+
+	d = c.mustCall(msgTgetattr, func(e *encoder) {
+		e.u32(1)
+		e.u64(getattrBasic)
+	})
+	d.u64()
+	d.take(13)
+	mode := d.u32()
+	d.u32()
+	d.u32()
+	d.u64()
+	d.u64()
+	if size := d.u64(); size != 12 || mode&unix.S_IFMT != unix.S_IFREG {
+		t.Errorf("getattr size = %d mode = %o, want a 12-byte regular file", size, mode)
+	}
+	c.mustCall(msgTclunk, func(e *encoder) { e.u32(1) })
+}
+
+func TestServerCreateAndList(t *testing.T) {
+	root := t.TempDir()
+	c := newTestClient(t, root, false)
+
+	// Create dir/new.txt and write to it
+	c.mustCall(msgTmkdir, func(e *encoder) {
+		e.u32(0)
+		e.str("dir")
+		e.u32(0755)
+		e.u32(0)
+	})
+	if errno := c.walk(0, 1, "dir"); errno != 0 {
+		t.Fatalf("walk: %v", errno)
+	}
+	c.mustCall(msgTlcreate, func(e *encoder) {
+		e.u32(1)
+		e.str("new.txt")
+		e.u32(dotlRdwr)
+		e.u32(0644)
+		e.u32(0)
+	})
+	d := c.mustCall(msgTwrite, func(e *encoder) {
+		e.u32(1)
+		e.u64(0)
+		e.u32(4)
+		e.buf = append(e.buf, "data"...)
+	})
+	if n := d.u32(); n != 4 {
+		t.Errorf("write count = %d, want 4", n)
+	}
+	c.mustCall(msgTclunk, func(e *encoder) { e.u32(1) })
+
+	data, err := os.ReadFile(filepath.Join(root, "dir", "new.txt"))
+	if err != nil || string(data) != "data" {
+		t.Fatalf("host file = %q, %v; want %q", data, err, "data")
+	}
+
+	// List the directory
+	if errno := c.walk(0, 2, "dir"); errno != 0 {
+		t.Fatalf("walk: %v", errno)
+	}
+	c.mustCall(msgTlopen, func(e *encoder) {
+		e.u32(2)
+		e.u32(0)
+	})
+	d = c.mustCall(msgTreaddir, func(e *encoder) {
+		e.u32(2)
+		e.u64(0)
+		e.u32(4096)
+	})
+	entries := &decoder{buf: d.take(int(d.u32()))}
+	var names []string
+	for len(entries.buf) > 0 && entries.err == nil {
+		entries.take(13 + 8 + 1)
+		names = append(names, entries.str())
+	}
+	if len(names) != 3 || names[0] != "." || names[1] != ".." || names[2] != "new.txt" {
+		t.Errorf("readdir = %v, want [. .. new.txt]", names)
+	}
+
+	// Remove the file again
+	c.mustCall(msgTunlinkat, func(e *encoder) {
+		e.u32(2)
+		e.str("new.txt")
+		e.u32(0)
+	})
+	if _, err := os.Stat(filepath.Join(root, "dir", "new.txt")); !os.IsNotExist(err) {
+		t.Errorf("file still exists after unlinkat: %v", err)
+	}
+}
+
+func TestServerReadOnly(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "f"), []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c := newTestClient(t, root, true)
+
+	if _, errno := c.call(msgTmkdir, func(e *encoder) {
+		e.u32(0)
+		e.str("dir")
+		e.u32(0755)
+		e.u32(0)
+	}); errno != unix.EROFS {
+		t.Errorf("mkdir errno = %v, want EROFS", errno)
+	}
+	if errno := c.walk(0, 1, "f"); errno != 0 {
+		t.Fatalf("walk: %v", errno)
+	}
+	if _, errno := c.call(msgTlopen, func(e *encoder) {
+		e.u32(1)
+		e.u32(dotlWronly)
+	}); errno != unix.EROFS {
+		t.Errorf("lopen for writing errno = %v, want EROFS", errno)
+	}
+}
+
+func TestServerStaysInRoot(t *testing.T) {
+	parent := t.TempDir()
+	root := filepath.Join(parent, "share")
+	if err := os.Mkdir(root, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(parent, "secret"), []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(parent, filepath.Join(root, "escape")); err != nil {
+		t.Fatal(err)
+	}
+	c := newTestClient(t, root, false)
+
+	if errno := c.walk(0, 1, "..", "secret"); errno == 0 {
+		t.Error("walk through .. left the root")
+	}
+	if errno := c.walk(0, 2, "escape", "secret"); errno == 0 {
+		t.Error("walk through a symlink left the root")
+	}
+	if errno := c.walk(0, 3, "../secret"); errno != unix.EINVAL {
+		t.Errorf("walk with a slash errno = %v, want EINVAL", errno)
+	}
+}
+
+// TestServerSetattrStaysInRoot checks that changing a file cannot reach
+// outside the root through a symlink, whether the guest made it or it
+// replaced a directory after the walk.
+func TestServerSetattrStaysInRoot(t *testing.T) {
+	parent := t.TempDir()
+	root := filepath.Join(parent, "share")
+	outside := filepath.Join(parent, "outside")
+	for _, dir := range []string{filepath.Join(root, "dir"), outside} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	secret := filepath.Join(outside, "secret")
+	for _, path := range []string{secret, filepath.Join(root, "dir", "secret")} {
+		if err := os.WriteFile(path, []byte("keep me"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c := newTestClient(t, root, false)
+
+	// A symlink the guest creates to a host file
+	c.mustCall(msgTsymlink, func(e *encoder) {
+		e.u32(0)
+		e.str("link")
+		e.str(secret)
+		e.u32(0)
+	})
+	if errno := c.walk(0, 1, "link"); errno != 0 {
+		t.Fatalf("walk: %v", errno)
+	}
+	setattr := func(fid uint32) {
+		t.Helper()
+		c.call(msgTsetattr, func(e *encoder) {
+			e.u32(fid)
+			e.u32(setattrMode | setattrSize)
+			e.u32(0777)
+			e.u32(0)
+			e.u32(0)
+			e.u64(0)
+			e.buf = append(e.buf, make([]byte, 32)...)
+		})
+	}
+	setattr(1)
+
+	// An ordinary file in the share still changes
+	if errno := c.walk(0, 3, "dir", "secret"); errno != 0 {
+		t.Fatalf("walk: %v", errno)
+	}
+	setattr(3)
+	if info, err := os.Stat(filepath.Join(root, "dir", "secret")); err != nil || info.Mode().Perm() != 0777 || info.Size() != 0 {
+		t.Errorf("setattr in the share = %v, %v; want mode 0777 and size 0", info, err)
+	}
+
+	// A directory swapped for a symlink after the guest walked into it
+	if errno := c.walk(0, 2, "dir", "secret"); errno != 0 {
+		t.Fatalf("walk: %v", errno)
+	}
+	if err := os.Rename(filepath.Join(root, "dir"), filepath.Join(parent, "moved")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(root, "dir")); err != nil {
+		t.Fatal(err)
+	}
+	setattr(2)
+
+	info, err := os.Stat(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 || info.Size() != 7 {
+		t.Errorf("file outside the share changed: mode %v, size %d", info.Mode().Perm(), info.Size())
+	}
+}
@@ -1,6 +1,7 @@
 package virtio
 
 import (
+	"encoding/binary"
 	"io"
 	"log/slog"
 	"sync"
@@ -12,6 +13,40 @@ import (
 type ConsoleDevice struct {
 	In  io.Reader
 	Out io.Writer
+
+	// Size, if set, is advertised to the guest as the console's window
+	// size. Changing it raises a config change interrupt, which the Linux
+	// driver turns into a hvc resize.
+	Size *ConsoleSize
+}
+
+// ConsoleSize holds the window size of a console device. The zero value
+// is a console of unknown size.
+type ConsoleSize struct {
+	mu         sync.Mutex
+	cols, rows uint16
+	notify     func()
+}
+
+// Set changes the console size and notifies the guest if it changed.
+func (s *ConsoleSize) Set(cols, rows uint16) {
+	s.mu.Lock()
+	changed := s.cols != cols || s.rows != rows
+	s.cols, s.rows = cols, rows
+	notify := s.notify
+	s.mu.Unlock()
+
+	// The bus holds its device lock while reading config, so notify
+	// is called without s.mu held
+	if changed && notify != nil {
+		notify()
+	}
+}
+
+func (s *ConsoleSize) get() (cols, rows uint16) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cols, s.rows
 }
 
 type consoleHandler struct {
@@ -19,6 +54,12 @@ type consoleHandler struct {
 	wg  sync.WaitGroup
 }
 
+// features
+
+const (
+	consoleFSize = 1 << 0 // console size is in cols and rows
+)
+
 const (
 	consoleRxQ = 0
 	consoleTxQ = 1
@@ -32,10 +73,22 @@ func (h *consoleHandler) GetType() DeviceID {
 	return ConsoleDeviceID
 }
 
-func (*consoleHandler) GetFeatures() uint64 {
+func (h *consoleHandler) GetFeatures() uint64 {
+	if h.cfg.Size != nil {
+		return consoleFSize
+	}
 	return 0
 }
 
+// SetConfigNotify implements ConfigNotifier so size changes reach the guest.
+func (h *consoleHandler) SetConfigNotify(notify func()) {
+	if s := h.cfg.Size; s != nil {
+		s.mu.Lock()
+		s.notify = notify
+		s.mu.Unlock()
+	}
+}
+
 func (*consoleHandler) Ready(negotiatedFeatures uint64) error {
 	return nil
 }
@@ -49,7 +102,7 @@ func (h *consoleHandler) QueueReady(num int, q *virtq.Queue, notify <-chan struc
 				defer h.wg.Done()
 				for range notify {
 					if err := h.handleRx(q); err != nil {
-						slog.Error("console rx: %v", err)
+						slog.Error("console rx", "error", err)
 					}
 				}
 			}()
@@ -62,7 +115,7 @@ func (h *consoleHandler) QueueReady(num int, q *virtq.Queue, notify <-chan struc
 				defer h.wg.Done()
 				for range notify {
 					if err := h.handleTx(q); err != nil {
-						slog.Error("console tx: %v", err)
+						slog.Error("console tx", "error", err)
 					}
 				}
 			}()
@@ -72,7 +125,20 @@ func (h *consoleHandler) QueueReady(num int, q *virtq.Queue, notify <-chan struc
 	return nil
 }
 
+// ReadConfig reads struct virtio_console_config. Only cols and rows are
+// provided; max_nr_ports and emerg_wr read as zero.
 func (h *consoleHandler) ReadConfig(p []byte, off int) error {
+	raw := make([]byte, 12)
+	if h.cfg.Size != nil {
+		cols, rows := h.cfg.Size.get()
+		binary.LittleEndian.PutUint16(raw[0:], cols)
+		binary.LittleEndian.PutUint16(raw[2:], rows)
+	}
+
+	if off < len(raw) {
+		copy(p, raw[off:])
+	}
+
 	return nil
 }
 
@@ -109,6 +109,10 @@ func NewBus(devices []virtio.DeviceConfig, cfg Config) (*Bus, error) {
 			qC:      make(map[int]chan struct{}),
 		}
 
+		if cn, ok := h.(virtio.ConfigNotifier); ok {
+			cn.SetConfigNotify(d.configChanged)
+		}
+
 		b.dev[i] = d
 
 		irq++
@@ -185,6 +189,24 @@ func (d *device) HandleMMIO(off int, data []byte, isWrite bool) (err error) {
 	return d.readMMIO(off, data)
 }
 
+// configChanged bumps the config generation and, once the driver is up,
+// interrupts the guest so it rereads the device configuration.
+func (d *device) configChanged() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.state.version++
+	if !d.isOperatingNormally() {
+		return
+	}
+
+	d.state.intStatus |= intStatusConfigChange
+	if err := d.bus.cfg.Notify(d.info.IRQ); err != nil {
+		slog.Error("virtio config change notification failed",
+			"irq", d.info.IRQ, "err", err)
+	}
+}
+
 // Close closes the device's notification channels,
 // then calls the handler's Close method,
 // returning any error.
@@ -0,0 +1,295 @@
+package virtio
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+
+	"github.com/c35s/hype/virtio/virtq"
+)
+
+// NetDevice configures a virtio network device.
+type NetDevice struct {
+
+	// MAC is the device's hardware address. If nil, the guest driver
+	// picks a random address.
+	MAC net.HardwareAddr
+
+	// Backend exchanges raw Ethernet frames with the host, one frame per
+	// Read or Write call. A tap device opened with IFF_TAP|IFF_NO_PI
+	// satisfies this.
+	Backend io.ReadWriter
+}
+
+type netHandler struct {
+	cfg NetDevice
+	wg  sync.WaitGroup
+
+	// The backend is read by its own goroutine, which hands each frame
+	// to the rx queue goroutine over frames and gets the buffer back over
+	// free. Only the queue goroutines are waited for on Close: a backend
+	// Read cannot be interrupted, and returns once its owner closes it.
+	frames    chan []byte
+	free      chan struct{}
+	done      chan struct{}
+	startRead sync.Once
+	closeOnce sync.Once
+
+	// dropped counts frames too large for netMaxFrame or for the guest's
+	// receive buffers.
+	dropped atomic.Uint64
+}
+
+// features
+
+const (
+	netFMAC = 1 << 5 // device has given MAC address
+)
+
+const (
+	netRxQ = 0
+	netTxQ = 1
+)
+
+// netHdrLen is the size of struct virtio_net_hdr when VIRTIO_F_VERSION_1
+// is negotiated (num_buffers is always present).
+const netHdrLen = 12
+
+// netMaxFrame is the largest frame read from the backend: a 64KiB GSO
+// frame plus Ethernet header.
+const netMaxFrame = 65536 + 14
+
+func (cfg NetDevice) NewHandler() (DeviceHandler, error) {
+	if cfg.Backend == nil {
+		return nil, fmt.Errorf("virtio-net: backend is required")
+	}
+	if cfg.MAC != nil && len(cfg.MAC) != 6 {
+		return nil, fmt.Errorf("virtio-net: invalid MAC %s", cfg.MAC)
+	}
+	return &netHandler{
+		cfg:    cfg,
+		frames: make(chan []byte),
+		free:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}, nil
+}
+
+func (h *netHandler) GetType() DeviceID {
+	return NetworkDeviceID
+}
+
+func (h *netHandler) GetFeatures() uint64 {
+	if h.cfg.MAC != nil {
+		return netFMAC
+	}
+	return 0
+}
+
+func (*netHandler) Ready(negotiatedFeatures uint64) error {
+	return nil
+}
+
+func (h *netHandler) QueueReady(num int, q *virtq.Queue, notify <-chan struct{}) error {
+	switch num {
+	case netRxQ:
+		h.startRead.Do(func() { go h.readBackend() })
+		h.wg.Add(1)
+		go func() {
+			defer h.wg.Done()
+			h.serveRx(q, notify)
+		}()
+
+	case netTxQ:
+		h.wg.Add(1)
+		go func() {
+			defer h.wg.Done()
+			for range notify {
+				if err := h.handleTx(q); err != nil {
+					slog.Error("net tx", "error", err)
+				}
+			}
+		}()
+	}
+
+	return nil
+}
+
+// ReadConfig reads struct virtio_net_config. Only the mac field is
+// provided; status and later fields read as zero.
+func (h *netHandler) ReadConfig(p []byte, off int) error {
+	raw := make([]byte, 8)
+	copy(raw, h.cfg.MAC)
+
+	if off < len(raw) {
+		copy(p, raw[off:])
+	}
+
+	return nil
+}
+
+func (h *netHandler) Close() error {
+	h.closeOnce.Do(func() { close(h.done) })
+	h.wg.Wait()
+	if n := h.dropped.Load(); n > 0 {
+		slog.Warn("net rx: dropped oversized frames", "count", n)
+	}
+	return nil
+}
+
+// readBackend reads frames from the backend and hands them to serveRx
+// until the backend fails or the handler is closed. A frame longer than
+// netMaxFrame is dropped rather than passed on truncated.
+func (h *netHandler) readBackend() {
+	buf := make([]byte, netMaxFrame+1)
+	for {
+		n, err := h.cfg.Backend.Read(buf)
+		if err != nil {
+			select {
+			case <-h.done:
+			default:
+				if err != io.EOF && !errors.Is(err, os.ErrClosed) {
+					slog.Error("net rx", "error", err)
+				}
+			}
+			return
+		}
+		if n > netMaxFrame {
+			h.dropped.Add(1)
+			continue
+		}
+
+		select {
+		case h.frames <- buf[:n]:
+		case <-h.done:
+			return
+		}
+		select {
+		case <-h.free:
+		case <-h.done:
+			return
+		}
+	}
+}
+
+// serveRx delivers frames from readBackend into the receive queue. A frame
+// waits for the guest to post a buffer; without a frame, posted buffers
+// are left on the queue. It returns when notify closes or the handler is
+// closed, even while no frame arrives.
+func (h *netHandler) serveRx(q *virtq.Queue, notify <-chan struct{}) {
+	var pending []byte
+	for {
+		select {
+		case <-h.done:
+			return
+		case _, ok := <-notify:
+			if !ok {
+				return
+			}
+		case frame := <-h.frames:
+			pending = frame
+		}
+		if pending == nil {
+			continue
+		}
+
+		delivered, err := h.handleRx(q, pending)
+		if err != nil {
+			slog.Error("net rx", "error", err)
+		}
+		if delivered || err != nil {
+			pending = nil
+			select {
+			case h.free <- struct{}{}:
+			case <-h.done:
+				return
+			}
+		}
+	}
+}
+
+// handleRx copies frame into the next available receive chain and
+// reports whether there was one. A frame that does not fit the chain is
+// dropped and counted; the chain is returned empty so the guest can reuse
+// it.
+func (h *netHandler) handleRx(q *virtq.Queue, frame []byte) (bool, error) {
+	c, err := q.Next()
+	if err != nil {
+		return false, err
+	}
+
+	if c == nil {
+		return false, nil
+	}
+
+	// Zeroed header (no offloads) with num_buffers = 1, then the frame
+	var hdr [netHdrLen]byte
+	hdr[10] = 1
+	pkt := append(hdr[:], frame...)
+
+	written := 0
+	for i, d := range c.Desc {
+		if !d.IsWO() {
+			continue
+		}
+
+		buf, err := c.Buf(i)
+		if err != nil {
+			return true, err
+		}
+
+		written += copy(buf, pkt[written:])
+		if written == len(pkt) {
+			break
+		}
+	}
+
+	if written < len(pkt) {
+		h.dropped.Add(1)
+		written = 0
+	}
+
+	return true, c.Release(written)
+}
+
+// handleTx forwards each transmitted frame to the backend.
+func (h *netHandler) handleTx(q *virtq.Queue) error {
+	for {
+		c, err := q.Next()
+		if err != nil {
+			return err
+		}
+
+		if c == nil {
+			return nil
+		}
+
+		var pkt []byte
+		for i, d := range c.Desc {
+			if d.IsWO() {
+				break
+			}
+
+			buf, err := c.Buf(i)
+			if err != nil {
+				return err
+			}
+
+			pkt = append(pkt, buf...)
+		}
+
+		if len(pkt) > netHdrLen {
+			if _, err := h.cfg.Backend.Write(pkt[netHdrLen:]); err != nil {
+				return err
+			}
+		}
+
+		if err := c.Release(0); err != nil {
+			return err
+		}
+	}
+}
@@ -33,6 +33,15 @@ type DeviceHandler interface {
 	Close() error
 }
 
+// ConfigNotifier is implemented by handlers whose configuration can change
+// while the device is running. The bus calls SetConfigNotify once, before
+// the guest sees the device, with a function that raises a configuration
+// change interrupt. The function must not be called with a lock held that
+// ReadConfig also takes.
+type ConfigNotifier interface {
+	SetConfigNotify(notify func())
+}
+
 // DeviceID identifies the type of a virtio device.
 type DeviceID uint32
 
@@ -41,6 +50,7 @@ const (
 	NetworkDeviceID = DeviceID(1)
 	BlockDeviceID   = DeviceID(2)
 	ConsoleDeviceID = DeviceID(3)
+	P9DeviceID      = DeviceID(9)
 	SocketDeviceID  = DeviceID(19)
 )
 
@@ -150,6 +160,9 @@ func (id DeviceID) String() string {
 	case ConsoleDeviceID:
 		return "console"
 
+	case P9DeviceID:
+		return "9p"
+
 	case SocketDeviceID:
 		return "socket"
 